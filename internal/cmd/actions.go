@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/github"
+)
+
+// actionsSubject is a subject as written to the subjects step output
+type actionsSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// writeActionsOutputs exposes the attestation to the following steps
+// of a GitHub Actions job. Besides the subjects in JSON, the hashes
+// output has their sha256 digests in the base64 encoded sha256sum
+// format expected by the slsa-github-generator workflows.
+func writeActionsOutputs(specURL string, att *attestation.Attestation, path string) error {
+	subjects := []actionsSubject{}
+	var hashes strings.Builder
+	for _, s := range att.Subject {
+		subjects = append(subjects, actionsSubject{Name: s.Name, Digest: s.Digest})
+		if d, ok := s.Digest["sha256"]; ok {
+			fmt.Fprintf(&hashes, "%s  %s\n", d, s.Name)
+		}
+	}
+	data, err := json.Marshal(subjects)
+	if err != nil {
+		return fmt.Errorf("marshalling subjects: %w", err)
+	}
+	return github.WriteActionsOutputs(map[string]string{
+		"spec-url":    specURL,
+		"attestation": path,
+		"subjects":    string(data),
+		"hashes":      base64.StdEncoding.EncodeToString([]byte(hashes.String())),
+	})
+}
@@ -17,33 +17,133 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"sigs.k8s.io/release-utils/util"
-
+	"sigs.k8s.io/tejolote/pkg/annotator"
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/environment"
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/hostquote"
+	"sigs.k8s.io/tejolote/pkg/output"
+	"sigs.k8s.io/tejolote/pkg/policy"
+	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/watcher"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 type attestOptions struct {
 	waitForBuild     bool
+	waitForStart     time.Duration
 	sign             bool
 	continueExisting string
-	vcsurl           string
+	vcsURLs          []string
 	encodedExisting  string
 	encodedSnapshots string
 	artifacts        []string
+	refSubjects      []string
+	requireEmpty     []string
+	interruptState   string
+	immutableDelay   time.Duration
+	immutableWarn    bool
+	settlePeriod     time.Duration
+	settleInterval   time.Duration
+	pollInterval     time.Duration
+	maxPollInterval  time.Duration
+	configPath       string
+	licenseScan      string
+	discoverStores   bool
+	signArtifacts    bool
+	sourceDateEpoch  string
+	uploadRelease    bool
+	originCheck      string
+	checkout         string
+	vendorDigest     bool
+	goModules        []string
+	baseImages       bool
+	logDigests       bool
+	captureEnv       bool
+	builderID        string
+	buildType        string
+	policies         []string
+	splitSubjects    bool
+	timestampServer  string
+	failOnFailure    bool
+	envOpts          environment.Options
+	captureDir       string
+	releaseURL       string
+	blobAttestations string
+	compat           string
+	streamLogs       bool
+	snapshotIndex    string
+	pubsub           string
+	publish          []string
+	publishDests     []publishDestination
+	claimCheck       string
+	cloudEvents      bool
+	hostQuote        string
+	hostQuoteOpts    hostquote.Options
+	githubActions    bool
+	actionsOutputs   bool
 }
 
 func (o *attestOptions) Verify() error {
 	if o.encodedExisting != "" && o.continueExisting != "" {
 		return errors.New("only --encoded-existing or --continue can be set at a time")
 	}
+	if o.vendorDigest && o.checkout == "" {
+		return errors.New("--vendor-digest requires a repository --checkout")
+	}
+	if o.waitForStart < 0 {
+		return errors.New("--wait-for-start must not be negative")
+	}
+	if err := validateOriginCheck(o.originCheck); err != nil {
+		return err
+	}
+	switch o.hostQuoteOpts.Provider {
+	case hostquote.ProviderAuto, hostquote.ProviderTSM, hostquote.ProviderTPM:
+	default:
+		return fmt.Errorf("invalid --host-quote-provider %q, must be auto, tsm or tpm", o.hostQuoteOpts.Provider)
+	}
+	if err := config.ValidateURI(o.builderID); err != nil {
+		return fmt.Errorf("invalid --builder-id: %w", err)
+	}
+	if err := config.ValidateURI(o.buildType); err != nil {
+		return fmt.Errorf("invalid --build-type: %w", err)
+	}
+	dests, err := parsePublishDestinations(o.publish)
+	if err != nil {
+		return err
+	}
+	for _, d := range dests {
+		if d.Kind == publishArchivista && !o.sign {
+			return errors.New("publishing to archivista requires --sign")
+		}
+		if d.Kind == publishChains && !o.sign {
+			return errors.New("publishing to Tekton Chains storage requires --sign")
+		}
+	}
+	o.publishDests = dests
+	if o.timestampServer != "" && !o.sign {
+		return errors.New("--timestamp-server requires --sign")
+	}
+	if o.splitSubjects && o.pubsub != "" {
+		return errors.New("--split-subjects cannot be used with --pubsub")
+	}
+	if o.compat != "" && !slices.Contains(attestation.CompatModes, o.compat) {
+		return fmt.Errorf("invalid --compat mode %q, must be one of %s", o.compat, strings.Join(attestation.CompatModes, ", "))
+	}
 	return nil
 }
 
@@ -66,119 +166,43 @@ where they came from.
 	`,
 		Use:               "attest",
 		SilenceUsage:      false,
-		PersistentPreRunE: initLogging,
-		RunE: func(_ *cobra.Command, args []string) (err error) {
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if len(args) == 0 && attestOpts.githubActions {
+				if gha := github.CurrentActionsRun(); gha != nil {
+					args = append(args, gha.SpecURL())
+					logrus.Infof("Running in GitHub Actions, attesting workflow run %s", args[0])
+					// The run cannot finish while we are observing it
+					if !cmd.Flags().Changed("wait") {
+						attestOpts.waitForBuild = false
+					}
+				}
+			}
 			if len(args) == 0 {
 				return errors.New("build run spec URL not specified")
 			}
+			attestOpts.actionsOutputs = attestOpts.githubActions && github.CurrentActionsRun() != nil
 
 			if err := attestOpts.Verify(); err != nil {
 				return fmt.Errorf("verifying options: %w", err)
 			}
-
-			w, err := watcher.New(args[0])
-			if err != nil {
-				return fmt.Errorf("building watcher")
-			}
-
-			w.Builder.VCSURL = attestOpts.vcsurl
-
-			w.Options.WaitForBuild = attestOpts.waitForBuild
-			if !attestOpts.waitForBuild {
-				logrus.Warn("watcher will not wait for build, data may be incomplete")
-			}
-
-			// Add artifact monitors to the watcher
-			for _, uri := range attestOpts.artifacts {
-				if err := w.AddArtifactSource(uri); err != nil {
-					return fmt.Errorf("adding artifacts source: %w", err)
-				}
-			}
-
-			// Get the run from the build system
-			r, err := w.GetRun(args[0])
-			if err != nil {
-				return fmt.Errorf("fetching run: %w", err)
-			}
-
-			// Watch the run run :)
-			if err := w.Watch(r); err != nil {
-				return fmt.Errorf("generating attestation: %w", err)
-			}
-
-			if attestOpts.encodedExisting != "" {
-				f, err := os.CreateTemp("", "attestation-*.intoto.json")
-				if err != nil {
-					return fmt.Errorf("marshallling encoded attestation: %w", err)
-				}
-				defer f.Close()
-				decodedAtt, err := base64.StdEncoding.DecodeString(attestOpts.encodedExisting)
-				if err != nil {
-					return fmt.Errorf("decoding existing attestation")
-				}
-				if err := os.WriteFile(f.Name(), decodedAtt, os.FileMode(0o644)); err != nil {
-					return fmt.Errorf("writing encoded attestation to disk")
-				}
-				attestOpts.continueExisting = f.Name()
-			}
-
-			if attestOpts.encodedSnapshots != "" {
-				f, err := os.CreateTemp("", "snapshots-*.intoto.json")
-				if err != nil {
-					return fmt.Errorf("marshallling encoded snapshots: %w", err)
-				}
-				defer f.Close()
-				decodedSnaps, err := base64.StdEncoding.DecodeString(attestOpts.encodedSnapshots)
-				if err != nil {
-					return fmt.Errorf("decoding received snapshots: %w", err)
-				}
-				if err := os.WriteFile(f.Name(), decodedSnaps, os.FileMode(0o644)); err != nil {
-					return fmt.Errorf("writing encoded attestation to disk")
-				}
-				outputOpts.SnapshotStatePath = f.Name()
-			}
-
-			if err = w.LoadAttestation(attestOpts.continueExisting); err != nil {
-				return fmt.Errorf("loading previous attestation")
-			}
-
-			if util.Exists(outputOpts.FinalSnapshotStatePath(attestOpts.continueExisting)) {
-				if err := w.LoadSnapshots(
-					outputOpts.FinalSnapshotStatePath(attestOpts.continueExisting),
-				); err != nil {
-					return fmt.Errorf("loading storage snapshots: %w", err)
-				}
-			}
-
-			if err := w.CollectArtifacts(r); err != nil {
-				return fmt.Errorf("while collecting run artifacts: %w", err)
-			}
-
-			attestation, err := w.AttestRun(r)
-			if err != nil {
-				return fmt.Errorf("generating run attestation: %w", err)
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("verifying options: %w", err)
 			}
-
-			var json []byte
-
-			if attestOpts.sign {
-				json, err = attestation.Sign()
-			} else {
-				json, err = attestation.ToJSON()
+			if attestOpts.timestampServer != "" && outputOpts.OutputPath == "" && !attestOpts.splitSubjects {
+				return errors.New("verifying options: --timestamp-server needs an --output file to write the timestamp next to")
 			}
 
+			json, err := attestRun(cmd.Context(), args[0], &attestOpts, outputOpts)
 			if err != nil {
-				return fmt.Errorf("serializing attestation: %w", err)
+				return err
 			}
 
-			if outputOpts.OutputPath != "" {
-				if err := os.WriteFile(outputOpts.OutputPath, json, os.FileMode(0o644)); err != nil {
-					return fmt.Errorf("writing attestation file: %w", err)
-				}
-				return nil
+			// With an output path the attestation is written by attestRun
+			// before announcing it, split attestations are always written
+			if outputOpts.OutputPath == "" && !attestOpts.splitSubjects {
+				fmt.Println(string(json))
 			}
-
-			fmt.Println(string(json))
 			return nil
 		},
 	}
@@ -199,23 +223,290 @@ where they came from.
 		"sign the attestation",
 	)
 
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.signArtifacts,
+		"sign-artifacts",
+		false,
+		"sign the artifacts attested as subjects, writing detached signatures next to them",
+	)
+
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.blobAttestations,
+		"blob-attestations",
+		"",
+		"directory to write a signed statement per subject as cosign attest-blob does (NAME.intoto.jsonl, .sig, .cert)",
+	)
+
 	attestCmd.PersistentFlags().StringSliceVar(
 		&attestOpts.artifacts,
 		"artifacts",
 		[]string{},
 		"a storage URL to monitor for files",
 	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.refSubjects,
+		"subject-refs",
+		[]string{},
+		"git tags to add (with their release) as subjects (github://owner/repo/tag, github://ghe.example.com/owner/repo/tag)",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.requireEmpty,
+		"require-empty",
+		[]string{},
+		"artifact storage locations that must have been empty before the build",
+	)
 	attestCmd.PersistentFlags().BoolVar(
 		&attestOpts.waitForBuild,
 		"wait",
 		true,
 		"when watrching the run, wait for the build to finish",
 	)
-	attestCmd.PersistentFlags().StringVar(
-		&attestOpts.vcsurl,
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.waitForStart,
+		"wait-for-start",
+		0,
+		"wait up to this long for a run the build system does not know yet (eg a queued workflow) to appear (0 fails right away)",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.githubActions,
+		"github-actions",
+		true,
+		"when running in GitHub Actions, attest the current workflow run if no spec URL is given and write the step outputs to GITHUB_OUTPUT",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.discoverStores,
+		"discover-artifacts",
+		true,
+		"watch the artifact locations declared in the build definition (GCB images and objects)",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.vcsURLs,
 		"vcs-url",
+		[]string{},
+		"append vcs URLs to the attestation materials, repeat for builds checking out several repositories",
+	)
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.pollInterval,
+		"poll-interval",
+		3*time.Second,
+		"initial interval to poll the build system while waiting for the run",
+	)
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.maxPollInterval,
+		"max-poll-interval",
+		time.Minute,
+		"maximum interval between polls, the interval doubles after each poll up to this value",
+	)
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.settlePeriod,
+		"settle-period",
+		0,
+		"after the build, keep listing the artifact stores up to this long until their contents stop changing (0 disables it)",
+	)
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.settleInterval,
+		"settle-interval",
+		10*time.Second,
+		"time between listings of the artifact stores while waiting for them to settle",
+	)
+	attestCmd.PersistentFlags().DurationVar(
+		&attestOpts.immutableDelay,
+		"verify-immutable",
+		0,
+		"after attesting, wait this long and check the artifacts did not change (0 disables the check)",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.immutableWarn,
+		"verify-immutable-warn",
+		false,
+		"only log a warning instead of failing when artifacts changed after attesting",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.configPath,
+		"config",
+		"",
+		"path to a tejolote configuration file (YAML or JSON)",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.licenseScan,
+		"license-scan",
+		"",
+		"scan the artifacts for licenses and write the findings statement to this file",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.hostQuote,
+		"host-quote",
+		"",
+		"capture a TPM or confidential VM quote of the host, write it to this file and link its digest in the materials",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.hostQuoteOpts.Provider,
+		"host-quote-provider",
+		hostquote.ProviderAuto,
+		"provider to quote the host with: tsm (confidential VMs), tpm or auto to use the first available",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.hostQuoteOpts.AKHandle,
+		"host-quote-ak",
+		"",
+		"persistent handle of the TPM attestation key used to sign the quote (eg 0x81010002)",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.sourceDateEpoch,
+		"source-date-epoch",
+		"",
+		"SOURCE_DATE_EPOCH used in the build, when not set it is read from the build steps environment",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.captureDir,
+		"capture",
+		"",
+		"directory to save the run data and store snapshots to regenerate the attestation with tejolote replay",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.checkout,
+		"checkout",
+		"",
+		"path to a checkout of the built repository to record its Git LFS objects and submodules as materials",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.vendorDigest,
+		"vendor-digest",
+		false,
+		"record a digest of the vendor directory of --checkout as a material",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.builderID,
+		"builder-id",
+		"",
+		"URI to record as the builder id of the predicate instead of the one set by the build system driver",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.buildType,
+		"build-type",
+		"",
+		"URI to record as the build type of the predicate instead of the one set by the build system driver",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.policies,
+		"policy",
+		[]string{},
+		"policy files the attestation must pass before it is written: rules in YAML or JSON, or a WASM module (.wasm)",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.splitSubjects,
+		"split-subjects",
+		false,
+		"write one statement per subject, --output is a template of the file names (fields .Name, .Index, .Subject, .SHA256; defaults to "+defaultSplitOutput+")",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.captureEnv,
+		"capture-env",
+		false,
+		"record the runner platform and the --env-allow variables of the machine running tejolote in the invocation environment",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.envOpts.Allow,
+		"env-allow",
+		[]string{},
+		"glob patterns of the environment variables recorded with --capture-env (eg RUNNER_*,CI)",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.envOpts.Redact,
+		"env-redact",
+		[]string{},
+		"regular expressions matching additional secret values to redact from the recorded variables",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.logDigests,
+		"log-digests",
+		false,
+		"record the digests of the run logs as materials, logs are saved to the --capture directory if set",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.baseImages,
+		"base-images",
+		false,
+		"record the base images annotated in the container images attested as materials",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.goModules,
+		"go-modules",
+		[]string{},
+		"directories of Go modules in the source repository (eg .) whose dependencies are recorded as materials",
+	)
+	addOriginCheckFlag(attestCmd, &attestOpts.originCheck)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.streamLogs,
+		"stream-logs",
+		false,
+		"follow the build logs to notice when the run finishes within seconds instead of waiting for the next poll (gcb)",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.snapshotIndex,
+		"snapshot-index",
 		"",
-		"append a vcs URL to the atetstation materials",
+		"directory with the artifact store indexes written by tejolote start --snapshot-index, the delta is computed on disk",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.compat,
+		"compat",
+		"",
+		fmt.Sprintf("shape the attestation for a third-party verifier (%s)", strings.Join(attestation.CompatModes, ", ")),
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.uploadRelease,
+		"upload-to-release",
+		false,
+		"upload the attestation and its checksum as assets of the GitHub or GitLab release of the tag the run built",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.releaseURL,
+		"release",
+		"",
+		"release to upload to instead of detecting it from the run (github://owner/repo/tag, gitlab://host/project/-/releases/tag)",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.failOnFailure,
+		"fail-on-build-failure",
+		true,
+		"exit with the code of the run outcome (2 failed, 3 cancelled, 4 timed out) without attesting runs that did not succeed",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.timestampServer,
+		"timestamp-server",
+		"",
+		"URL of an RFC 3161 timestamp authority to timestamp the signed attestation, written to OUTPUT.timestamp.json",
+	)
+	attestCmd.PersistentFlags().StringSliceVar(
+		&attestOpts.publish,
+		"publish",
+		[]string{},
+		"upload the attestation to durable storage: gs://, s3:// or oci:// locations (with the snapshot state, named after their digest), archivista=URL of an Archivista server or chains=oci to attach it to the image subjects as Tekton Chains does",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.pubsub,
+		"pubsub",
+		"",
+		"publish a finish message announcing the attestation to a Pub/Sub, SNS or NATS topic, SQS queue or webhook (https://)",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.claimCheck,
+		"pubsub-claim-check",
+		"",
+		"bucket url (gs://bucket/path or s3://bucket/path) to upload pubsub messages too large to publish",
+	)
+	attestCmd.PersistentFlags().BoolVar(
+		&attestOpts.cloudEvents,
+		"cloudevents",
+		false,
+		"wrap the published messages in a CloudEvents 1.0 envelope",
+	)
+	attestCmd.PersistentFlags().StringVar(
+		&attestOpts.interruptState,
+		"interrupt-state",
+		"",
+		"if interrupted while waiting for the build, write the partial attestation here to --continue later",
 	)
 	attestCmd.PersistentFlags().StringVar(
 		&attestOpts.encodedExisting,
@@ -235,3 +526,451 @@ where they came from.
 
 	parentCmd.AddCommand(attestCmd)
 }
+
+// addOriginCheckFlag adds the --origin-check flag to commands attesting runs
+func addOriginCheckFlag(command *cobra.Command, mode *string) {
+	command.PersistentFlags().StringVar(
+		mode,
+		"origin-check",
+		"off",
+		"cross-check artifacts against those reported by the build system: off, annotate unreported artifacts, or fail",
+	)
+}
+
+// validateOriginCheck checks the --origin-check mode
+func validateOriginCheck(mode string) error {
+	switch mode {
+	case "", "off", "annotate", "fail":
+		return nil
+	default:
+		return fmt.Errorf("invalid --origin-check mode %q, must be off, annotate or fail", mode)
+	}
+}
+
+// attestRun observes the run from the spec URL and returns the
+// serialized attestation describing it
+func attestRun(ctx context.Context, specURL string, attestOpts *attestOptions, outputOpts *outputOptions) ([]byte, error) {
+	if err := checkAttestOutputs(attestOpts, outputOpts); err != nil {
+		return nil, err
+	}
+
+	w, err := watcher.New(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("building watcher")
+	}
+
+	w.Builder.VCSURLs = attestOpts.vcsURLs
+
+	w.Options.WaitForBuild = attestOpts.waitForBuild
+	w.Options.RefSubjects = attestOpts.refSubjects
+	w.Options.RequireEmpty = attestOpts.requireEmpty
+	w.Options.ImmutabilityDelay = attestOpts.immutableDelay
+	w.Options.SettlePeriod = attestOpts.settlePeriod
+	w.Options.SettleInterval = attestOpts.settleInterval
+	w.Options.StreamLogs = attestOpts.streamLogs
+	w.Options.IndexDir = attestOpts.snapshotIndex
+	w.Options.ClaimCheckLocation = attestOpts.claimCheck
+	w.Options.CloudEvents = attestOpts.cloudEvents
+	w.Options.Sigstore = commandLineOpts.sigstore
+	if attestOpts.pollInterval > 0 {
+		w.Options.PollInterval = attestOpts.pollInterval
+	}
+	if attestOpts.maxPollInterval > 0 {
+		w.Options.MaxPollInterval = attestOpts.maxPollInterval
+	}
+	if !attestOpts.waitForBuild {
+		logrus.Warn("watcher will not wait for build, data may be incomplete")
+	}
+
+	if attestOpts.configPath != "" {
+		conf, err := config.Load(attestOpts.configPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading configuration: %w", err)
+		}
+		for _, ac := range conf.Annotators {
+			a, err := annotator.New(ac)
+			if err != nil {
+				return nil, fmt.Errorf("configuring annotators: %w", err)
+			}
+			w.Options.Annotators = append(w.Options.Annotators, a)
+		}
+		w.Options.Hooks = conf.Hooks
+		w.Options.BuilderID = conf.BuilderID
+		w.Options.BuildType = conf.BuildType
+		if w.Options.Sigstore, err = sigstoreOptions(conf.Sigstore); err != nil {
+			return nil, err
+		}
+		for _, pc := range conf.Policies {
+			p, err := policy.New(pc)
+			if err != nil {
+				return nil, fmt.Errorf("configuring policies: %w", err)
+			}
+			w.Options.Policies = append(w.Options.Policies, p)
+		}
+	}
+
+	// Flags take precedence over the configuration file
+	if attestOpts.builderID != "" {
+		w.Options.BuilderID = attestOpts.builderID
+	}
+	if attestOpts.buildType != "" {
+		w.Options.BuildType = attestOpts.buildType
+	}
+	w.Options.TimestampServer = attestOpts.timestampServer
+
+	for _, file := range attestOpts.policies {
+		p, err := policy.FromFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading policy: %w", err)
+		}
+		w.Options.Policies = append(w.Options.Policies, p)
+	}
+
+	// Add artifact monitors to the watcher
+	for _, uri := range attestOpts.artifacts {
+		if err := w.AddArtifactSource(uri); err != nil {
+			return nil, fmt.Errorf("adding artifacts source: %w", err)
+		}
+	}
+
+	if attestOpts.encodedExisting != "" {
+		f, err := workdir.CreateTemp("attestation-*.intoto.json")
+		if err != nil {
+			return nil, fmt.Errorf("marshallling encoded attestation: %w", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		decodedAtt, err := base64.StdEncoding.DecodeString(attestOpts.encodedExisting)
+		if err != nil {
+			return nil, fmt.Errorf("decoding existing attestation")
+		}
+		if err := os.WriteFile(f.Name(), decodedAtt, os.FileMode(0o644)); err != nil {
+			return nil, fmt.Errorf("writing encoded attestation to disk")
+		}
+		attestOpts.continueExisting = f.Name()
+	}
+
+	if attestOpts.encodedSnapshots != "" {
+		f, err := workdir.CreateTemp("snapshots-*.intoto.json")
+		if err != nil {
+			return nil, fmt.Errorf("marshallling encoded snapshots: %w", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		decodedSnaps, err := base64.StdEncoding.DecodeString(attestOpts.encodedSnapshots)
+		if err != nil {
+			return nil, fmt.Errorf("decoding received snapshots: %w", err)
+		}
+		if err := os.WriteFile(f.Name(), decodedSnaps, os.FileMode(0o644)); err != nil {
+			return nil, fmt.Errorf("writing encoded attestation to disk")
+		}
+		outputOpts.SnapshotStatePath = f.Name()
+	}
+
+	if err = w.LoadAttestation(attestOpts.continueExisting); err != nil {
+		return nil, fmt.Errorf("loading previous attestation")
+	}
+
+	if snapshotStateExists(outputOpts.FinalSnapshotStatePath(attestOpts.continueExisting)) {
+		if err := w.LoadSnapshots(
+			ctx, outputOpts.FinalSnapshotStatePath(attestOpts.continueExisting),
+		); err != nil {
+			return nil, fmt.Errorf("loading storage snapshots: %w", err)
+		}
+	}
+
+	if err := w.CheckEmptyStores(); err != nil {
+		return nil, fmt.Errorf("checking artifact stores: %w", err)
+	}
+
+	// Get the run from the build system
+	r, err := w.WaitForRun(ctx, specURL, attestOpts.waitForStart)
+	if err != nil {
+		return nil, fmt.Errorf("fetching run: %w", err)
+	}
+
+	if attestOpts.discoverStores {
+		if err := w.DiscoverArtifactStores(r); err != nil {
+			return nil, fmt.Errorf("discovering artifact stores: %w", err)
+		}
+	}
+
+	// Watch the run run :)
+	if err := w.Watch(ctx, r); err != nil {
+		if ctx.Err() != nil && attestOpts.interruptState != "" {
+			if err2 := saveInterruptState(w, attestOpts.interruptState); err2 != nil {
+				logrus.Error(err2)
+			}
+		}
+		return nil, fmt.Errorf("generating attestation: %w", err)
+	}
+
+	if outcome := r.Result(); outcome != "" && outcome != run.OutcomeSuccess {
+		if attestOpts.failOnFailure {
+			return nil, &runOutcomeError{SpecURL: specURL, Outcome: outcome}
+		}
+		logrus.Warnf("run %s finished with outcome %s, attesting it anyway", specURL, outcome)
+	}
+
+	if err := w.CollectArtifacts(ctx, r); err != nil {
+		return nil, fmt.Errorf("while collecting run artifacts: %w", err)
+	}
+
+	if attestOpts.originCheck == "annotate" || attestOpts.originCheck == "fail" {
+		unreported := w.CheckArtifactOrigins(r)
+		if len(unreported) > 0 && attestOpts.originCheck == "fail" {
+			return nil, fmt.Errorf(
+				"%d artifacts were not reported by the build system: %s",
+				len(unreported), strings.Join(unreported, ", "),
+			)
+		}
+	}
+
+	if attestOpts.captureDir != "" {
+		if err := w.CaptureRun(r, attestOpts.captureDir); err != nil {
+			return nil, fmt.Errorf("capturing run data: %w", err)
+		}
+	}
+
+	att, err := w.AttestRun(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("generating run attestation: %w", err)
+	}
+
+	if attestOpts.checkout != "" {
+		if err := w.AddSourceMaterials(att, attestOpts.checkout, attestOpts.vendorDigest); err != nil {
+			return nil, fmt.Errorf("recording source materials: %w", err)
+		}
+	}
+
+	if len(attestOpts.goModules) > 0 {
+		if err := w.AddGoModuleMaterials(ctx, att, attestOpts.checkout, attestOpts.goModules); err != nil {
+			return nil, fmt.Errorf("recording Go module materials: %w", err)
+		}
+	}
+
+	if attestOpts.logDigests {
+		logsDir := ""
+		if attestOpts.captureDir != "" {
+			logsDir = filepath.Join(attestOpts.captureDir, "logs")
+		}
+		if err := w.AddLogMaterials(ctx, att, r, logsDir); err != nil {
+			return nil, fmt.Errorf("recording run log digests: %w", err)
+		}
+	}
+
+	if attestOpts.baseImages {
+		if err := w.AddBaseImageMaterials(ctx, att); err != nil {
+			return nil, fmt.Errorf("recording base image materials: %w", err)
+		}
+	}
+
+	if err := w.CheckSourceDateEpoch(ctx, att, r, attestOpts.sourceDateEpoch); err != nil {
+		return nil, fmt.Errorf("checking SOURCE_DATE_EPOCH: %w", err)
+	}
+
+	if attestOpts.compat != "" {
+		if err := w.Builder.FormatCompat(ctx, attestOpts.compat, r, att); err != nil {
+			return nil, fmt.Errorf("applying %s compatibility: %w", attestOpts.compat, err)
+		}
+	}
+
+	if attestOpts.captureEnv {
+		env, err := environment.Capture(attestOpts.envOpts)
+		if err != nil {
+			return nil, fmt.Errorf("capturing environment: %w", err)
+		}
+		if err := w.AddEnvironment(att, env); err != nil {
+			return nil, fmt.Errorf("recording environment: %w", err)
+		}
+	}
+
+	if attestOpts.signArtifacts {
+		if err := w.AnnotateArtifactSignatures(att); err != nil {
+			return nil, fmt.Errorf("annotating artifact signatures: %w", err)
+		}
+	}
+
+	var hostQuote []byte
+	if attestOpts.hostQuote != "" {
+		hostQuote, err = w.QuoteHost(ctx, att, attestOpts.hostQuoteOpts)
+		switch {
+		case errors.Is(err, hostquote.ErrNotAvailable) && attestOpts.hostQuoteOpts.Provider == hostquote.ProviderAuto:
+			logrus.Warn("host has no TPM or confidential computing quote provider, not quoting it")
+		case err != nil:
+			return nil, fmt.Errorf("capturing host quote: %w", err)
+		}
+	}
+
+	// Check the policies once the attestation is complete and before
+	// signing anything or writing any file, a denied attestation must
+	// leave no trace
+	if err := w.EvaluatePolicies(ctx, att); err != nil {
+		return nil, fmt.Errorf("evaluating policies: %w", err)
+	}
+
+	if attestOpts.signArtifacts {
+		signer, err := attestation.NewSigstoreBlobSigner(ctx, w.Options.Sigstore)
+		if err != nil {
+			return nil, fmt.Errorf("creating artifact signer: %w", err)
+		}
+		defer signer.Close()
+		if err := w.SignArtifacts(ctx, att, signer); err != nil {
+			return nil, fmt.Errorf("signing artifacts: %w", err)
+		}
+	}
+
+	if attestOpts.licenseScan != "" {
+		st, err := w.ScanLicenses(ctx, att)
+		if err != nil {
+			return nil, fmt.Errorf("scanning licenses: %w", err)
+		}
+		data, err := st.ToJSON()
+		if err != nil {
+			return nil, fmt.Errorf("serializing license findings: %w", err)
+		}
+		if _, err := output.WriteFile(attestOpts.licenseScan, data, outputOpts.Mode()); err != nil {
+			return nil, fmt.Errorf("writing license findings: %w", err)
+		}
+	}
+
+	if hostQuote != nil {
+		if _, err := output.WriteFile(attestOpts.hostQuote, hostQuote, outputOpts.Mode()); err != nil {
+			return nil, fmt.Errorf("writing host quote: %w", err)
+		}
+	}
+
+	if attestOpts.blobAttestations != "" {
+		signer, err := attestation.NewSigstoreBlobSigner(ctx, w.Options.Sigstore)
+		if err != nil {
+			return nil, fmt.Errorf("creating statement signer: %w", err)
+		}
+		defer signer.Close()
+		if err := att.WriteBlobAttestations(ctx, signer, attestOpts.blobAttestations); err != nil {
+			return nil, fmt.Errorf("writing blob attestations: %w", err)
+		}
+	}
+
+	if attestOpts.splitSubjects {
+		return nil, writeSplitAttestations(ctx, specURL, w, r, att, attestOpts, outputOpts)
+	}
+
+	var json []byte
+	var sig *attestation.BlobSignature
+
+	if attestOpts.sign {
+		if sig, err = w.SignAttestation(ctx, att, r); err == nil {
+			json = sig.Signature
+		}
+	} else {
+		json, err = att.ToJSON()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("serializing attestation: %w", err)
+	}
+
+	if err := w.VerifyImmutable(ctx, r); err != nil {
+		if !attestOpts.immutableWarn {
+			return nil, fmt.Errorf("verifying artifact immutability: %w", err)
+		}
+		logrus.Warnf("artifacts changed after attesting: %v", err)
+	}
+
+	if attestOpts.uploadRelease {
+		releaseURL, err := w.ReleaseURL(r, attestOpts.releaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("locating release: %w", err)
+		}
+		name := "provenance.intoto.json"
+		if outputOpts.OutputPath != "" {
+			name = filepath.Base(outputOpts.OutputPath)
+		}
+		if err := watcher.UploadReleaseAssets(ctx, releaseURL, watcher.ReleaseAssets(name, json)); err != nil {
+			return nil, fmt.Errorf("uploading attestation to release: %w", err)
+		}
+	}
+
+	if outputOpts.OutputPath != "" {
+		if err := outputOpts.WriteOutput(json); err != nil {
+			return nil, fmt.Errorf("writing attestation file: %w", err)
+		}
+		if err := outputOpts.WriteTimestamp(sig); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := publishAttestation(ctx, attestOpts.publishDests, att, json, sig); err != nil {
+		return nil, err
+	}
+	if err := publishSnapshots(ctx, w, attestOpts.publishDests); err != nil {
+		return nil, err
+	}
+
+	// Mark the run signed as Chains does, so a Chains controller
+	// watching the same cluster leaves it alone
+	if attestOpts.compat == attestation.CompatTektonChains && sig != nil {
+		ok, err := w.Builder.AnnotateRun(ctx, r, attestation.ChainsAnnotations(sig, w.Options.Sigstore))
+		if err != nil {
+			return nil, fmt.Errorf("setting Tekton Chains annotations: %w", err)
+		}
+		if !ok {
+			logrus.Debug("build system runs do not support annotations, not setting the Chains annotations")
+		}
+	}
+
+	if attestOpts.pubsub != "" {
+		if err := w.PublishToTopic(ctx, attestOpts.pubsub, w.NewFinishMessage(att, json, sig)); err != nil {
+			return nil, fmt.Errorf("publishing finish message: %w", err)
+		}
+		logrus.Infof("Published finish message of %s to %s", specURL, attestOpts.pubsub)
+	}
+
+	if attestOpts.actionsOutputs {
+		if err := writeActionsOutputs(specURL, att, outputOpts.OutputPath); err != nil {
+			return nil, fmt.Errorf("writing GitHub Actions outputs: %w", err)
+		}
+	}
+	return json, nil
+}
+
+// checkAttestOutputs fails if any of the files attestRun writes exists
+// and --overwrite never replaces it. The names of split attestations
+// depend on the subjects, they are checked when written.
+func checkAttestOutputs(attestOpts *attestOptions, outputOpts *outputOptions) error {
+	checkOpts := *outputOpts
+	paths := []string{attestOpts.licenseScan, attestOpts.hostQuote}
+	switch {
+	case attestOpts.splitSubjects:
+		checkOpts.OutputPath = ""
+	case outputOpts.OutputPath != "" && attestOpts.timestampServer != "":
+		paths = append(paths, outputOpts.OutputPath+".timestamp.json")
+	}
+	return checkOpts.CheckOutput(paths...)
+}
+
+// saveInterruptState writes the draft attestation and the storage
+// snapshots of the watcher so that the attestation can be resumed
+// later with tejolote attest --continue
+func saveInterruptState(w *watcher.Watcher, path string) error {
+	att := w.DraftAttestation
+	if att == nil {
+		att = attestation.New().SLSA()
+	}
+	json, err := att.ToJSON()
+	if err != nil {
+		return fmt.Errorf("serializing partial attestation: %w", err)
+	}
+	if err := os.WriteFile(path, json, os.FileMode(0o644)); err != nil {
+		return fmt.Errorf("writing partial attestation: %w", err)
+	}
+
+	// Snapshots are saved in the default location --continue reads them
+	// from. The run context is already canceled at this point.
+	stateOpts := outputOptions{SnapshotStatePath: "default"}
+	if err := w.SaveSnapshots(context.Background(), stateOpts.FinalSnapshotStatePath(path)); err != nil {
+		return fmt.Errorf("saving storage snapshots: %w", err)
+	}
+	logrus.Infof("Interrupted, partial attestation state written to %s", path)
+	return nil
+}
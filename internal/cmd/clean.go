@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/workdir"
+)
+
+type cleanOptions struct {
+	olderThan time.Duration
+	dryRun    bool
+}
+
+func addClean(parentCmd *cobra.Command) {
+	cleanOpts := cleanOptions{}
+
+	cleanCmd := &cobra.Command{
+		Short: "Remove the working directories left behind by killed processes",
+		Long: `tejolote clean --workdir /var/tmp/tejolote
+
+tejolote downloads artifacts to a working directory which is removed
+when the command finishes. Processes killed before they could clean up
+leave it behind. The clean subcommand removes the working directories
+in the system temporary directory (or --workdir) whose process is no
+longer running, printing their paths.
+
+	`,
+		Use:               "clean",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			removed, err := workdir.Clean(workdir.Base(), cleanOpts.olderThan, cleanOpts.dryRun)
+			for _, dir := range removed {
+				fmt.Println(dir)
+			}
+			if err != nil {
+				return fmt.Errorf("cleaning working directories: %w", err)
+			}
+			logrus.Infof("%d orphaned working directories found in %s", len(removed), workdir.Base())
+			return nil
+		},
+	}
+
+	cleanCmd.PersistentFlags().DurationVar(
+		&cleanOpts.olderThan,
+		"older-than",
+		time.Hour,
+		"only remove directories not modified for this long",
+	)
+
+	cleanCmd.PersistentFlags().BoolVar(
+		&cleanOpts.dryRun,
+		"dry-run",
+		false,
+		"print the directories that would be removed without removing them",
+	)
+
+	parentCmd.AddCommand(cleanCmd)
+}
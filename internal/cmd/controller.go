@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/tejolote/pkg/controller"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type controllerOptions struct {
+	namespace   string
+	interval    time.Duration
+	stateDir    string
+	concurrency int
+	configPath  string
+	originCheck string
+}
+
+func addController(parentCmd *cobra.Command) {
+	controllerOpts := &controllerOptions{}
+
+	controllerCmd := &cobra.Command{
+		Short: "Attest the builds requested with BuildObservation resources",
+		Long: `tejolote controller --namespace builds
+
+The controller subcommand runs tejolote as an in-cluster provenance
+service. It watches BuildObservation resources (see the CRD in
+config/crd), each pointing at a build run and its artifact stores.
+
+When an observation is created, the controller snapshots its artifact
+stores, waits for the run to finish and stores the attestation in a
+ConfigMap or Secret of the namespace, or uploads it to a bucket. The
+progress is recorded in the status of the resource.
+
+The cluster is read from the kubeconfig or the in-cluster service
+account. Snapshot states are kept in --state-dir, mount a volume there
+to resume the observations when the controller restarts.
+
+	`,
+		Use:               "controller",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validateOriginCheck(controllerOpts.originCheck); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+				clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{},
+			).ClientConfig()
+			if err != nil {
+				return fmt.Errorf("reading kubernetes client configuration: %w", err)
+			}
+
+			c, err := controller.New(config, controller.Options{
+				Namespace:   controllerOpts.namespace,
+				Interval:    controllerOpts.interval,
+				StateDir:    controllerOpts.stateDir,
+				Concurrency: controllerOpts.concurrency,
+			}, controllerOpts.snapshot, controllerOpts.attest)
+			if err != nil {
+				return fmt.Errorf("creating controller: %w", err)
+			}
+			return c.Run(cmd.Context())
+		},
+	}
+
+	controllerCmd.PersistentFlags().StringVar(
+		&controllerOpts.namespace,
+		"namespace",
+		"",
+		"namespace to watch for build observations (all namespaces if empty)",
+	)
+
+	controllerCmd.PersistentFlags().DurationVar(
+		&controllerOpts.interval,
+		"interval",
+		10*time.Second,
+		"time between listings of the build observations",
+	)
+
+	controllerCmd.PersistentFlags().StringVar(
+		&controllerOpts.stateDir,
+		"state-dir",
+		"",
+		"directory to keep the snapshot states of the observations in progress (defaults to a temporary directory)",
+	)
+
+	controllerCmd.PersistentFlags().IntVar(
+		&controllerOpts.concurrency,
+		"concurrency",
+		10,
+		"number of builds to observe at the same time",
+	)
+
+	controllerCmd.PersistentFlags().StringVar(
+		&controllerOpts.configPath,
+		"config",
+		"",
+		"configuration file with the annotators, hooks and policies applied to the attestations",
+	)
+
+	addOriginCheckFlag(controllerCmd, &controllerOpts.originCheck)
+
+	parentCmd.AddCommand(controllerCmd)
+}
+
+// snapshot takes the snapshot of the stores of a new observation
+func (opts *controllerOptions) snapshot(ctx context.Context, obs *controller.BuildObservation, statePath string) error {
+	if err := snapshotStores(ctx, obs.Spec.Run, obs.Spec.Artifacts, statePath); err != nil {
+		return err
+	}
+	logrus.Infof("Snapshotted %d artifact stores of %s/%s", len(obs.Spec.Artifacts), obs.Namespace, obs.Name)
+	return nil
+}
+
+// snapshotStores snapshots the artifact stores of a run and saves the
+// snapshots to statePath, to be read by attestRun when the run is done
+func snapshotStores(ctx context.Context, specURL string, artifacts []string, statePath string) error {
+	w, err := watcher.New(specURL)
+	if err != nil {
+		return fmt.Errorf("building watcher: %w", err)
+	}
+	for _, uri := range artifacts {
+		if err := w.AddArtifactSource(uri); err != nil {
+			return fmt.Errorf("adding artifacts source: %w", err)
+		}
+	}
+	if err := w.Snap(ctx); err != nil {
+		return fmt.Errorf("snapshotting the artifact repositories: %w", err)
+	}
+	if err := w.SaveSnapshots(ctx, statePath); err != nil {
+		return fmt.Errorf("saving storage snapshots: %w", err)
+	}
+	return nil
+}
+
+// attest waits for the run of an observation and returns its attestation
+func (opts *controllerOptions) attest(ctx context.Context, obs *controller.BuildObservation, statePath string) ([]byte, error) {
+	attestOpts := &attestOptions{
+		waitForBuild:   true,
+		discoverStores: true,
+		originCheck:    opts.originCheck,
+		sign:           obs.Spec.Sign,
+		artifacts:      obs.Spec.Artifacts,
+		configPath:     opts.configPath,
+	}
+	return attestRun(ctx, obs.Spec.Run, attestOpts, &outputOptions{SnapshotStatePath: statePath})
+}
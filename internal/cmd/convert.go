@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+)
+
+type convertOptions struct {
+	to        string
+	output    string
+	overwrite string
+}
+
+func addConvert(parentCmd *cobra.Command) {
+	convertOpts := convertOptions{}
+
+	convertCmd := &cobra.Command{
+		Short: "Convert provenance attestations between SLSA versions",
+		Long: `tejolote convert --to slsa-1.0 attestation.json
+
+The convert subcommand translates SLSA 0.2 provenance to SLSA 1.0 and
+back, to migrate archived attestations as verifiers drop the older
+format. Signed attestations (DSSE envelopes) are read but the converted
+statement is not signed.
+
+The builder, build type, invocation and materials are mapped to their
+SLSA 1.0 equivalents so converting back restores the original predicate.
+Fields without an equivalent in the target format (like the
+completeness claims of SLSA 0.2) are dropped with a warning.
+
+	`,
+		Use:               "convert",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("an attestation file is needed to convert")
+			}
+			outputOpts := outputOptions{OutputPath: convertOpts.output, Overwrite: convertOpts.overwrite}
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if err := outputOpts.CheckOutput(); err != nil {
+				return err
+			}
+			if !slices.Contains(attestation.ConvertFormats, convertOpts.to) {
+				return fmt.Errorf(
+					"unknown format %q, must be one of %s",
+					convertOpts.to, strings.Join(attestation.ConvertFormats, ", "),
+				)
+			}
+
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("reading attestation: %w", err)
+			}
+			data, dropped, err := attestation.Convert(data, convertOpts.to)
+			if err != nil {
+				return fmt.Errorf("converting %s: %w", args[0], err)
+			}
+			for _, field := range dropped {
+				logrus.Warnf("%s has no equivalent in %s and was dropped", field, convertOpts.to)
+			}
+
+			if convertOpts.output != "" {
+				if err := outputOpts.WriteOutput(data); err != nil {
+					return fmt.Errorf("writing attestation file: %w", err)
+				}
+				return nil
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	convertCmd.PersistentFlags().StringVar(
+		&convertOpts.to,
+		"to",
+		attestation.FormatSLSA1,
+		fmt.Sprintf("format to convert the attestation to (%s)", strings.Join(attestation.ConvertFormats, ", ")),
+	)
+
+	convertCmd.PersistentFlags().StringVar(
+		&convertOpts.output,
+		"output",
+		"",
+		"file to store the converted attestation (instead of STDOUT)",
+	)
+
+	addOverwriteFlag(convertCmd, &convertOpts.overwrite)
+
+	parentCmd.AddCommand(convertCmd)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Exit codes of tejolote. Codes 2 to 4 report the outcome of the
+// attested run, any other error exits with 1.
+const (
+	ExitSuccess        = 0
+	ExitError          = 1
+	ExitBuildFailed    = 2
+	ExitBuildCancelled = 3
+	ExitBuildTimeout   = 4
+)
+
+// runOutcomeError is returned when the attested run did not succeed
+type runOutcomeError struct {
+	SpecURL string
+	Outcome string
+}
+
+func (e *runOutcomeError) Error() string {
+	return fmt.Sprintf("run %s finished with outcome %s", e.SpecURL, e.Outcome)
+}
+
+// exitCode returns the exit code for the error returned by a command
+func exitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	var roe *runOutcomeError
+	if !errors.As(err, &roe) {
+		return ExitError
+	}
+	switch roe.Outcome {
+	case run.OutcomeCancelled:
+		return ExitBuildCancelled
+	case run.OutcomeTimeout:
+		return ExitBuildTimeout
+	default:
+		return ExitBuildFailed
+	}
+}
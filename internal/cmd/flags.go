@@ -17,15 +17,126 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
 	"strings"
 
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/release-utils/util"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/output"
+	"sigs.k8s.io/tejolote/pkg/watcher"
 )
 
+// envPrefix is the prefix of the environment variables setting flags
+const envPrefix = "TEJOLOTE_"
+
+// envFlagName returns the environment variable that sets a flag, eg
+// TEJOLOTE_LOG_LEVEL for --log-level
+func envFlagName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnvFlags sets the flags of the command not set in the command
+// line from their TEJOLOTE_* environment variables. Flags in the command
+// line take precedence over the environment, which takes precedence over
+// the defaults. List flags take comma separated values.
+func applyEnvFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		value, ok := os.LookupEnv(envFlagName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := cmd.Flags().Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("setting --%s from %s: %w", f.Name, envFlagName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// flagAliases maps the alternative names accepted for some flags to
+// their canonical name
+var flagAliases = map[string]string{
+	"impersonate-service-account": "gcp-impersonate-service-account",
+}
+
+// normalizeFlagName resolves the flag aliases, it is set as the global
+// normalization function of the root command
+func normalizeFlagName(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+	if canonical, ok := flagAliases[name]; ok {
+		name = canonical
+	}
+	return pflag.NormalizedName(name)
+}
+
 type outputOptions struct {
 	OutputPath        string
 	SnapshotStatePath string
 	Workspace         string
+	Overwrite         string
+}
+
+// Validate checks the output options
+func (oo *outputOptions) Validate() error {
+	if oo.Overwrite != "" && !slices.Contains(output.Modes, oo.Overwrite) {
+		return fmt.Errorf("invalid --overwrite mode %q, must be one of %s", oo.Overwrite, strings.Join(output.Modes, ", "))
+	}
+	return nil
+}
+
+// Mode returns the overwrite mode, if-different when not set
+func (oo *outputOptions) Mode() string {
+	if oo.Overwrite == "" {
+		return output.OverwriteIfDifferent
+	}
+	return oo.Overwrite
+}
+
+// CheckOutput fails if the output path or any of the other files the
+// command writes exist and the overwrite mode never replaces them
+func (oo *outputOptions) CheckOutput(paths ...string) error {
+	for _, path := range append([]string{oo.OutputPath}, paths...) {
+		if err := output.Check(path, oo.Mode()); err != nil {
+			return fmt.Errorf("checking output: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteOutput writes the data atomically to the output path, honoring
+// the overwrite mode
+func (oo *outputOptions) WriteOutput(data []byte) error {
+	if _, err := output.WriteFile(oo.OutputPath, data, oo.Mode()); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// WriteTimestamp writes the RFC 3161 timestamp of the signature next to
+// the output as OUTPUT.timestamp.json, in the format read by cosign
+// verify-blob-attestation --rfc3161-timestamp
+func (oo *outputOptions) WriteTimestamp(sig *attestation.BlobSignature) error {
+	if sig == nil || sig.Timestamp == nil {
+		return nil
+	}
+	data, err := json.Marshal(bundle.TimestampToRFC3161Timestamp(sig.Timestamp))
+	if err != nil {
+		return fmt.Errorf("serializing timestamp: %w", err)
+	}
+	tsOpts := outputOptions{OutputPath: oo.OutputPath + ".timestamp.json", Overwrite: oo.Overwrite}
+	if err := tsOpts.WriteOutput(data); err != nil {
+		return fmt.Errorf("writing timestamp file: %w", err)
+	}
+	return nil
 }
 
 // FinalSnapshotStatePath returns the final path to store/read the storage
@@ -44,6 +155,15 @@ func (oo *outputOptions) FinalSnapshotStatePath(defaultSeed string) string {
 	return snapshotState
 }
 
+// snapshotStateExists returns true if there is a snapshot state to load
+// at path. Remote states are always read, failing if they are missing.
+func snapshotStateExists(path string) bool {
+	if watcher.IsRemoteState(path) {
+		return true
+	}
+	return path != "" && util.Exists(path)
+}
+
 func addOutputFlags(command *cobra.Command) *outputOptions {
 	opts := &outputOptions{}
 	command.PersistentFlags().StringVar(
@@ -56,7 +176,18 @@ func addOutputFlags(command *cobra.Command) *outputOptions {
 		&opts.SnapshotStatePath,
 		"snapshots",
 		"default",
-		"path to store the storage snapshots state",
+		"path or URL (gs://, s3://, oci://) to store the storage snapshots state",
 	)
+	addOverwriteFlag(command, &opts.Overwrite)
 	return opts
 }
+
+// addOverwriteFlag adds the --overwrite flag to commands writing files
+func addOverwriteFlag(command *cobra.Command, mode *string) {
+	command.PersistentFlags().StringVar(
+		mode,
+		"overwrite",
+		output.OverwriteIfDifferent,
+		fmt.Sprintf("what to do when the output file exists (%s), identical outputs are never rewritten", strings.Join(output.Modes, ", ")),
+	)
+}
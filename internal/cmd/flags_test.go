@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFlagName(t *testing.T) {
+	var impersonate []string
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.SetNormalizeFunc(normalizeFlagName)
+	flags.StringSliceVar(&impersonate, "gcp-impersonate-service-account", []string{}, "")
+
+	require.NoError(t, flags.Parse([]string{
+		"--impersonate-service-account", "example-project=reader@example-project.iam.gserviceaccount.com",
+		"--gcp-impersonate-service-account", "gs://bucket=reader@other-project.iam.gserviceaccount.com",
+	}))
+	require.Equal(t, []string{
+		"example-project=reader@example-project.iam.gserviceaccount.com",
+		"gs://bucket=reader@other-project.iam.gserviceaccount.com",
+	}, impersonate)
+	require.Equal(t, pflag.NormalizedName("read-only"), normalizeFlagName(flags, "read-only"))
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/graph"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type graphOptions struct {
+	runPath  string
+	prePath  string
+	postPath string
+	draft    string
+	vcsURLs  []string
+	format   string
+	output   string
+}
+
+func (opts *graphOptions) Validate() error {
+	if opts.runPath == "" {
+		return errors.New("no captured run specified (--run)")
+	}
+	if opts.postPath == "" {
+		return errors.New("no post build snapshots specified (--post)")
+	}
+	if !slices.Contains(graph.Formats, opts.format) {
+		return fmt.Errorf("unknown graph format %q, supported: %s", opts.format, strings.Join(graph.Formats, ", "))
+	}
+	return nil
+}
+
+func addGraph(parentCmd *cobra.Command) {
+	graphOpts := &graphOptions{}
+
+	graphCmd := &cobra.Command{
+		Short: "Render a captured run as a Mermaid or DOT diagram",
+		Long: `tejolote graph --run run.json --pre pre.json --post post.json --format mermaid
+
+The graph subcommand renders the materials, steps and produced
+artifacts of a run as a diagram, useful in release reviews and
+documentation. It reads the data captured with tejolote attest
+--capture, the same inputs as tejolote replay.
+
+Materials feed the first step of the run, steps are drawn in the
+order they ran and the artifacts hang from the last step. Failed
+steps are highlighted.
+
+Supported formats are mermaid (a flowchart to embed in markdown) and
+dot (Graphviz, eg: tejolote graph ... --format dot | dot -Tsvg).
+
+	`,
+		Use:               "graph",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := graphOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+
+			w, r, err := watcher.ReplayRun(graphOpts.runPath, graphOpts.prePath, graphOpts.postPath)
+			if err != nil {
+				return fmt.Errorf("replaying run: %w", err)
+			}
+			w.Builder.VCSURLs = graphOpts.vcsURLs
+			if err := w.LoadAttestation(graphOpts.draft); err != nil {
+				return fmt.Errorf("loading draft attestation: %w", err)
+			}
+
+			// The materials are read from the attestation predicate
+			att, err := w.AttestRun(cmd.Context(), r)
+			if err != nil {
+				return fmt.Errorf("generating run attestation: %w", err)
+			}
+			g := graph.New(r, att.Predicate.Materials)
+
+			out := os.Stdout
+			if graphOpts.output != "" {
+				f, err := os.Create(graphOpts.output)
+				if err != nil {
+					return fmt.Errorf("creating graph file: %w", err)
+				}
+				defer f.Close()
+				out = f
+			}
+			return g.Write(out, graphOpts.format)
+		},
+	}
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.runPath,
+		"run",
+		"",
+		"path to the run data captured with tejolote attest --capture",
+	)
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.prePath,
+		"pre",
+		"",
+		"path to the snapshots of the artifact stores before the build",
+	)
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.postPath,
+		"post",
+		"",
+		"path to the snapshots of the artifact stores after the build",
+	)
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.draft,
+		"continue",
+		"",
+		"path to the partial attestation the run was started with",
+	)
+
+	graphCmd.PersistentFlags().StringSliceVar(
+		&graphOpts.vcsURLs,
+		"vcs-url",
+		[]string{},
+		"VCS locators to add to the materials, as set when attesting the run",
+	)
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.format,
+		"format",
+		graph.FormatMermaid,
+		fmt.Sprintf("diagram format (%s)", strings.Join(graph.Formats, ", ")),
+	)
+
+	graphCmd.PersistentFlags().StringVar(
+		&graphOpts.output,
+		"output",
+		"",
+		"file to write the diagram to (instead of STDOUT)",
+	)
+
+	parentCmd.AddCommand(graphCmd)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"sigs.k8s.io/tejolote/pkg/service"
+)
+
+func addGrpc(parentCmd *cobra.Command) {
+	grpcOpts := &serviceOptions{}
+
+	grpcCmd := &cobra.Command{
+		Short: "Serve the attestation of build runs over gRPC",
+		Long: `tejolote grpc --listen :9090
+
+The grpc subcommand runs tejolote as a network service, letting build
+orchestrators drive the observation of their runs from other machines
+instead of shelling out to the CLI. The API is defined in
+pkg/service/v1/service.proto:
+
+  StartObservation   snapshots the artifact stores of a run. Call it
+                     before the build writes its artifacts.
+  FinishObservation  signals the build is done, the run is attested
+                     in the background.
+  GetAttestation     returns the phase of the observation and, once
+                     Attested, the attestation.
+
+Observations in progress are kept in memory and are lost when the
+server stops. Finished ones are stored in --results-dir when set.
+
+The server requires TLS (--tls-cert and --tls-key) and authenticates
+clients with their certificates (--client-ca) or bearer tokens sent in
+the authorization metadata (--token-file). Pass --insecure to serve
+without them on a trusted network.
+
+Artifact stores reading the filesystem of the server (file://) are
+rejected unless listed in --allowed-store-schemes, and attestations are
+only signed with the identity of the server when --allow-sign is set.
+
+	`,
+		Use:               "grpc",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return serveGrpc(cmd.Context(), grpcOpts)
+		},
+	}
+
+	grpcOpts.addFlags(grpcCmd, ":9090")
+
+	parentCmd.AddCommand(grpcCmd)
+}
+
+// serveGrpc runs the gRPC server until the context is canceled
+func serveGrpc(ctx context.Context, opts *serviceOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	serverOpts := []grpc.ServerOption{}
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	auth, err := opts.authenticator()
+	if err != nil {
+		return err
+	}
+	if auth != nil {
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(auth.UnaryInterceptor()))
+	}
+
+	s, lis, err := opts.newService()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	srv := service.NewGRPCServer(s, serverOpts...)
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	logrus.Infof("Serving gRPC on %s", lis.Addr())
+	if err := srv.Serve(lis); err != nil {
+		return fmt.Errorf("serving grpc: %w", err)
+	}
+	return nil
+}
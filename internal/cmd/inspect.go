@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type inspectRunOptions struct {
+	format     string
+	systemData bool
+}
+
+func addInspect(parentCmd *cobra.Command) {
+	inspectRunOpts := &inspectRunOptions{}
+
+	// Verb
+	inspectCmd := &cobra.Command{
+		Short:             "Print the data tejolote reads from build systems",
+		Use:               "inspect",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+	}
+
+	// Noun
+	inspectRunCmd := &cobra.Command{
+		Short: "Print the normalized data of a build system run",
+		Long: `tejolote inspect run buildsys://build-run/identifier
+
+The inspect run subcommand fetches a run from the build system and
+prints the normalized run structure tejolote builds the predicate
+from: its status, steps, parameters and timings.
+
+Use it to find out why a predicate is missing fields before filing
+a driver bug. Add --system-data to include the raw data returned by
+the build system API.
+
+	`,
+		Use:          "run",
+		SilenceUsage: false,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("build run spec URL not specified")
+			}
+
+			w, err := watcher.New(args[0])
+			if err != nil {
+				return fmt.Errorf("building watcher: %w", err)
+			}
+			r, err := w.GetRun(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("fetching run: %w", err)
+			}
+			if !inspectRunOpts.systemData {
+				r.SystemData = nil
+			}
+
+			data, err := json.MarshalIndent(r, "", "  ")
+			if err != nil {
+				return fmt.Errorf("encoding run: %w", err)
+			}
+			switch inspectRunOpts.format {
+			case "json":
+			case "yaml":
+				data, err = yaml.JSONToYAML(data)
+				if err != nil {
+					return fmt.Errorf("converting run to yaml: %w", err)
+				}
+			default:
+				return fmt.Errorf("unknown output format %q", inspectRunOpts.format)
+			}
+
+			fmt.Fprintln(os.Stdout, string(data))
+			return nil
+		},
+	}
+
+	inspectRunCmd.PersistentFlags().StringVarP(
+		&inspectRunOpts.format,
+		"output",
+		"o",
+		"json",
+		"output format (json or yaml)",
+	)
+
+	inspectRunCmd.PersistentFlags().BoolVar(
+		&inspectRunOpts.systemData,
+		"system-data",
+		false,
+		"include the raw data returned by the build system",
+	)
+
+	inspectCmd.AddCommand(inspectRunCmd)
+	parentCmd.AddCommand(inspectCmd)
+}
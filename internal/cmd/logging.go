@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/release-utils/log"
+)
+
+// initLogging configures the global logger with the level and format
+// set in the command line. When a run spec URL is passed to the command,
+// it is added to every entry to correlate the logs with the build.
+func initLogging(level, format string, args []string) error {
+	if err := log.SetupGlobalLogger(level); err != nil {
+		return fmt.Errorf("setting up logger: %w", err)
+	}
+	switch format {
+	case "", "text":
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q, must be text or json", format)
+	}
+	if len(args) > 0 && strings.Contains(args[0], "://") {
+		logrus.AddHook(&fieldsHook{fields: logrus.Fields{"spec_url": args[0]}})
+	}
+	return nil
+}
+
+// fieldsHook adds a fixed set of fields to every log entry
+type fieldsHook struct {
+	fields logrus.Fields
+}
+
+func (h *fieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fieldsHook) Fire(entry *logrus.Entry) error {
+	for k, v := range h.fields {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+	return nil
+}
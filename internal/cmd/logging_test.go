@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs points the standard logger to a buffer and restores its
+// configuration when the test finishes
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	logger := logrus.StandardLogger()
+	out, formatter, level := logger.Out, logger.Formatter, logger.GetLevel()
+	hooks := logger.ReplaceHooks(make(logrus.LevelHooks))
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+		logger.ReplaceHooks(hooks)
+	})
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	return &buf
+}
+
+func TestInitLogging(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		specURL string
+	}{
+		{"spec URL", []string{"gcb://example-project/3190d867", "gs://bucket/path"}, "gcb://example-project/3190d867"},
+		{"no spec URL", []string{"make", "build"}, ""},
+		{"no args", nil, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := captureLogs(t)
+			require.NoError(t, initLogging("info", "json", tc.args))
+
+			logrus.WithField("step", "snap").Info("snapshot done")
+			entry := map[string]any{}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			require.Equal(t, "snapshot done", entry["msg"])
+			require.Equal(t, "info", entry["level"])
+			require.Equal(t, "snap", entry["step"])
+			if tc.specURL == "" {
+				require.NotContains(t, entry, "spec_url")
+			} else {
+				require.Equal(t, tc.specURL, entry["spec_url"])
+			}
+		})
+	}
+}
+
+func TestInitLoggingFormat(t *testing.T) {
+	buf := captureLogs(t)
+	require.NoError(t, initLogging("info", "text", nil))
+	logrus.Info("plain")
+	require.Contains(t, buf.String(), `msg=plain`)
+	require.Error(t, json.Unmarshal(buf.Bytes(), &map[string]any{}))
+
+	require.Error(t, initLogging("info", "yaml", nil))
+}
+
+func TestFieldsHook(t *testing.T) {
+	hook := &fieldsHook{fields: logrus.Fields{"spec_url": "github://org/repo/1234"}}
+	require.Equal(t, logrus.AllLevels, hook.Levels())
+
+	entry := logrus.NewEntry(logrus.New())
+	require.NoError(t, hook.Fire(entry))
+	require.Equal(t, "github://org/repo/1234", entry.Data["spec_url"])
+
+	// Fields set in the entry are not replaced
+	entry = logrus.NewEntry(logrus.New()).WithField("spec_url", "gcb://project/1")
+	require.NoError(t, hook.Fire(entry))
+	require.Equal(t, "gcb://project/1", entry.Data["spec_url"])
+}
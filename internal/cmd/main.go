@@ -17,13 +17,30 @@ limitations under the License.
 package cmd
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"sigs.k8s.io/release-utils/log"
 	"sigs.k8s.io/release-utils/version"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/gcp"
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/gitlab"
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 func Execute() error {
@@ -49,10 +66,14 @@ meaningful attestation. For example:
 Tejolote will try to make sane asumptions but for best results, it
 allows for full control of the process you run.
 
+Every flag can also be set with an environment variable named after it
+with the TEJOLOTE_ prefix, eg --log-level with TEJOLOTE_LOG_LEVEL. Flags
+in the command line take precedence over the environment.
+
 	`,
 		Use:               "tejolote",
 		SilenceUsage:      false,
-		PersistentPreRunE: initLogging,
+		PersistentPreRunE: initCommand,
 	}
 
 	rootCmd.PersistentFlags().StringVar(
@@ -62,24 +83,300 @@ allows for full control of the process you run.
 		fmt.Sprintf("the logging verbosity, either %s", log.LevelNames()),
 	)
 
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.logFormat,
+		"log-format",
+		"text",
+		"format of the log output, either text or json",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.githubAPIURL,
+		"github-api-url",
+		"",
+		"base URL of the GitHub API, eg https://ghe.example.com/api/v3 (defaults to $GITHUB_API_URL or api.github.com)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.githubAppID,
+		"github-app-id",
+		"",
+		"authenticate to GitHub as an installation of this GitHub App instead of using $GITHUB_TOKEN",
+	)
+
+	rootCmd.PersistentFlags().Int64Var(
+		&commandLineOpts.githubAppInstallation,
+		"github-app-installation-id",
+		0,
+		"installation ID of the GitHub App (looked up from the repository of each request if not set)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.githubAppKey,
+		"github-app-private-key",
+		"",
+		"PEM file with the private key of the GitHub App",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.gitlabCABundle,
+		"gitlab-ca-bundle",
+		"",
+		"PEM file with CA certificates to trust when connecting to self-managed GitLab instances (defaults to $GITLAB_CA_BUNDLE)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.FulcioURL,
+		"fulcio-url",
+		"",
+		"Fulcio instance issuing the signing certificates (defaults to the public sigstore instance)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.RekorURL,
+		"rekor-url",
+		"",
+		"Rekor transparency log recording the signatures (defaults to the public sigstore instance)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.OIDCIssuer,
+		"oidc-issuer",
+		"",
+		"OIDC provider issuing the identity tokens exchanged for signing certificates",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.OIDCClientID,
+		"oidc-client-id",
+		"",
+		"client ID of tejolote in the OIDC provider",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.TUFMirror,
+		"tuf-mirror",
+		"",
+		"TUF repository distributing the trust root of a private sigstore deployment",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.sigstore.TUFRoot,
+		"tuf-root",
+		"",
+		"initial root.json of the --tuf-mirror repository",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&commandLineOpts.registryAuth,
+		"registry-auth",
+		[]string{},
+		"credentials for a container registry as registry=username:password, the password can be read from a variable with env:NAME",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.dockerConfig,
+		"docker-config",
+		"",
+		"directory of the docker config.json to read registry credentials from (defaults to $DOCKER_CONFIG or ~/.docker)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.gcpCredentials,
+		"gcp-credentials-file",
+		"",
+		"Google Cloud credentials file to use instead of the application default credentials, supports workload identity federation configs",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&commandLineOpts.gcpImpersonate,
+		"gcp-impersonate-service-account",
+		[]string{},
+		"service account to impersonate when talking to Google Cloud (GCS, Cloud Build, Pub/Sub), scope it to a project or bucket with project=SA or gs://bucket=SA",
+	)
+
+	rootCmd.PersistentFlags().StringSliceVar(
+		&commandLineOpts.gcpDelegates,
+		"gcp-impersonate-delegates",
+		[]string{},
+		"service accounts in the delegation chain to impersonate --gcp-impersonate-service-account",
+	)
+
+	rootCmd.PersistentFlags().BoolVar(
+		&commandLineOpts.readOnly,
+		"read-only",
+		false,
+		"refuse any write to the observed stores, build systems and attestation stores (bucket uploads, release assets, claim checks, mutating API calls)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.workdir,
+		"workdir",
+		"",
+		"directory for the temporary files, removed when the command finishes (defaults to the system temporary directory)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&commandLineOpts.progress,
+		"progress",
+		progress.ModeAuto,
+		fmt.Sprintf("how to report the progress of snapshots and downloads (%s)", strings.Join(progress.Modes, ", ")),
+	)
+
+	rootCmd.PersistentFlags().DurationVar(
+		&commandLineOpts.progressInterval,
+		"progress-interval",
+		30*time.Second,
+		"interval between the progress log lines when not drawing a progress bar",
+	)
+
 	addRun(rootCmd)
 	addAttest(rootCmd)
 	addStart(rootCmd)
+	addWorker(rootCmd)
+	addSchemes(rootCmd)
+	addMerge(rootCmd)
+	addConvert(rootCmd)
+	addPromotion(rootCmd)
+	addResume(rootCmd)
+	addReplay(rootCmd)
+	addInspect(rootCmd)
+	addGraph(rootCmd)
+	addRefreshSubjects(rootCmd)
+	addController(rootCmd)
+	addClean(rootCmd)
+	addGrpc(rootCmd)
+	addRest(rootCmd)
 	rootCmd.AddCommand(version.WithFont("larry3d"))
+	rootCmd.SetGlobalNormalizationFunc(normalizeFlagName)
 
-	if err := rootCmd.Execute(); err != nil {
-		logrus.Fatal(err)
-		return err
+	// Cancel the command context on SIGINT/SIGTERM so that the running
+	// command can clean up and exit gracefully
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := rootCmd.ExecuteContext(ctx)
+	if progressReporter != nil {
+		progressReporter.Stop()
+	}
+
+	// Temporary files are removed whether the command failed or not
+	if cerr := workdir.Cleanup(); cerr != nil {
+		logrus.Warn(cerr)
+	}
+	if err != nil {
+		logrus.Error(err)
+		cancel()
+		os.Exit(exitCode(err))
 	}
 	return nil
 }
 
 type commandLineOptions struct {
-	logLevel string
+	logLevel              string
+	logFormat             string
+	githubAPIURL          string
+	githubAppID           string
+	githubAppKey          string
+	githubAppInstallation int64
+	gitlabCABundle        string
+	sigstore              attestation.SigstoreOptions
+	registryAuth          []string
+	dockerConfig          string
+	gcpCredentials        string
+	gcpImpersonate        []string
+	gcpDelegates          []string
+	readOnly              bool
+	workdir               string
+	progress              string
+	progressInterval      time.Duration
 }
 
 var commandLineOpts = &commandLineOptions{}
 
-func initLogging(*cobra.Command, []string) error {
-	return log.SetupGlobalLogger(commandLineOpts.logLevel)
+// progressReporter reports the progress of the running command
+var progressReporter *progress.Reporter
+
+func initCommand(cmd *cobra.Command, args []string) error {
+	if err := applyEnvFlags(cmd); err != nil {
+		return err
+	}
+	if commandLineOpts.readOnly {
+		readonly.Enable()
+	}
+	if progressReporter == nil {
+		r, err := progress.Start(commandLineOpts.progress, commandLineOpts.progressInterval)
+		if err != nil {
+			return err
+		}
+		progressReporter = r
+	}
+	if commandLineOpts.workdir != "" {
+		if err := workdir.SetBase(commandLineOpts.workdir); err != nil {
+			return fmt.Errorf("setting workdir: %w", err)
+		}
+	}
+	if commandLineOpts.githubAPIURL != "" {
+		github.SetAPIURL(commandLineOpts.githubAPIURL)
+	}
+	if commandLineOpts.githubAppID != "" {
+		if err := github.SetAppCredentials(
+			commandLineOpts.githubAppID, commandLineOpts.githubAppInstallation, commandLineOpts.githubAppKey,
+		); err != nil {
+			return fmt.Errorf("configuring GitHub App authentication: %w", err)
+		}
+	}
+	if commandLineOpts.gitlabCABundle != "" {
+		gitlab.SetCABundle(commandLineOpts.gitlabCABundle)
+	}
+	if err := commandLineOpts.sigstore.Validate(); err != nil {
+		return fmt.Errorf("configuring sigstore: %w", err)
+	}
+	if commandLineOpts.gcpCredentials != "" {
+		gcp.SetCredentialsFile(commandLineOpts.gcpCredentials)
+	}
+	for _, sa := range commandLineOpts.gcpImpersonate {
+		scope, scopedSA, ok := strings.Cut(sa, "=")
+		if !ok {
+			gcp.SetImpersonateServiceAccount(sa, commandLineOpts.gcpDelegates)
+			continue
+		}
+		if scope == "" || scopedSA == "" {
+			return fmt.Errorf("invalid scoped service account %q, format: SCOPE=SERVICE_ACCOUNT", sa)
+		}
+		gcp.SetScopedServiceAccount(scope, scopedSA)
+	}
+	for _, creds := range commandLineOpts.registryAuth {
+		if err := ociauth.AddCredentials(creds); err != nil {
+			return fmt.Errorf("reading registry credentials: %w", err)
+		}
+	}
+	if commandLineOpts.dockerConfig != "" {
+		if err := ociauth.SetDockerConfig(commandLineOpts.dockerConfig); err != nil {
+			return fmt.Errorf("setting docker config: %w", err)
+		}
+	}
+	return initLogging(commandLineOpts.logLevel, commandLineOpts.logFormat, args)
+}
+
+// sigstoreOptions returns the sigstore settings to sign with, those of
+// the configuration file when set. Flags set in the command line take
+// precedence.
+func sigstoreOptions(conf *config.Sigstore) (attestation.SigstoreOptions, error) {
+	flags := commandLineOpts.sigstore
+	if conf == nil {
+		return flags, nil
+	}
+	opts := attestation.SigstoreOptions{
+		FulcioURL:    cmp.Or(flags.FulcioURL, conf.FulcioURL),
+		RekorURL:     cmp.Or(flags.RekorURL, conf.RekorURL),
+		OIDCIssuer:   cmp.Or(flags.OIDCIssuer, conf.OIDCIssuer),
+		OIDCClientID: cmp.Or(flags.OIDCClientID, conf.OIDCClientID),
+		TUFMirror:    cmp.Or(flags.TUFMirror, conf.TUFMirror),
+		TUFRoot:      cmp.Or(flags.TUFRoot, conf.TUFRoot),
+	}
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("configuring sigstore: %w", err)
+	}
+	return opts, nil
 }
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+)
+
+type mergeOptions struct {
+	output    string
+	overwrite string
+	sign      bool
+}
+
+func addMerge(parentCmd *cobra.Command) {
+	mergeOpts := mergeOptions{}
+
+	mergeCmd := &cobra.Command{
+		Short: "Combine partial attestations into a single statement",
+		Long: `tejolote merge att1.json att2.json ...
+
+The merge subcommand combines attestations of the same build, for
+example one per artifact store or per matrix job, into a single
+statement. Subjects are de-duplicated and materials are unioned.
+
+All attestations must describe the same invocation. If the builder,
+build type, config source or invocation data differ, or if a subject
+or material is listed with conflicting digests, the merge fails.
+
+	`,
+		Use:               "merge",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 2 {
+				return errors.New("at least two attestations are needed to merge")
+			}
+			outputOpts := outputOptions{OutputPath: mergeOpts.output, Overwrite: mergeOpts.overwrite}
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if err := outputOpts.CheckOutput(); err != nil {
+				return err
+			}
+
+			atts := []*attestation.Attestation{}
+			for _, path := range args {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("reading attestation: %w", err)
+				}
+				att := attestation.New()
+				if err := json.Unmarshal(data, att); err != nil {
+					return fmt.Errorf("unmarshaling attestation %s: %w", path, err)
+				}
+				atts = append(atts, att)
+			}
+
+			merged, err := attestation.Merge(atts...)
+			if err != nil {
+				return fmt.Errorf("merging attestations: %w", err)
+			}
+
+			var data []byte
+			if mergeOpts.sign {
+				data, err = merged.Sign(cmd.Context(), commandLineOpts.sigstore)
+			} else {
+				data, err = merged.ToJSON()
+			}
+			if err != nil {
+				return fmt.Errorf("serializing attestation: %w", err)
+			}
+
+			if mergeOpts.output != "" {
+				if err := outputOpts.WriteOutput(data); err != nil {
+					return fmt.Errorf("writing attestation file: %w", err)
+				}
+				return nil
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	mergeCmd.PersistentFlags().StringVar(
+		&mergeOpts.output,
+		"output",
+		"",
+		"file to store the merged attestation (instead of STDOUT)",
+	)
+
+	addOverwriteFlag(mergeCmd, &mergeOpts.overwrite)
+
+	mergeCmd.PersistentFlags().BoolVar(
+		&mergeOpts.sign,
+		"sign",
+		false,
+		"sign the merged attestation",
+	)
+
+	parentCmd.AddCommand(mergeCmd)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/promotion"
+)
+
+type promotionOptions struct {
+	images    string
+	output    string
+	overwrite string
+	sign      bool
+}
+
+func addPromotion(parentCmd *cobra.Command) {
+	promotionOpts := promotionOptions{}
+
+	promotionCmd := &cobra.Command{
+		Short: "Attest an image promotion described in an image promoter manifest",
+		Long: `tejolote promotion promoter-manifest.yaml --images images.yaml
+
+The promotion subcommand reads a Kubernetes image promoter (cip)
+manifest and checks that each digest listed exists in the source
+registry and that the destination registries serve it under the
+listed tags.
+
+If all promoted images match the manifest, tejolote writes a
+provenance attestation with the promoted images as subjects and the
+staging images as materials. Any mismatch makes the command fail.
+
+Manifests in the split layout of the k8s.io repository keep the
+image list in a separate file, pass it with --images.
+
+	`,
+		Use:               "promotion",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("promotion needs the path to a promoter manifest")
+			}
+			outputOpts := outputOptions{OutputPath: promotionOpts.output, Overwrite: promotionOpts.overwrite}
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if err := outputOpts.CheckOutput(); err != nil {
+				return err
+			}
+
+			manifest, err := promotion.LoadManifest(args[0], promotionOpts.images)
+			if err != nil {
+				return fmt.Errorf("loading promoter manifest: %w", err)
+			}
+
+			att, err := promotion.Attest(cmd.Context(), args[0], manifest)
+			if err != nil {
+				return fmt.Errorf("attesting promotion: %w", err)
+			}
+
+			var data []byte
+			if promotionOpts.sign {
+				data, err = att.Sign(cmd.Context(), commandLineOpts.sigstore)
+			} else {
+				data, err = att.ToJSON()
+			}
+			if err != nil {
+				return fmt.Errorf("serializing attestation: %w", err)
+			}
+
+			if promotionOpts.output != "" {
+				if err := outputOpts.WriteOutput(data); err != nil {
+					return fmt.Errorf("writing attestation file: %w", err)
+				}
+				return nil
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	promotionCmd.PersistentFlags().StringVar(
+		&promotionOpts.images,
+		"images",
+		"",
+		"images.yaml file listing the images to promote (split manifest layout)",
+	)
+
+	promotionCmd.PersistentFlags().StringVar(
+		&promotionOpts.output,
+		"output",
+		"",
+		"file to store the promotion attestation (instead of STDOUT)",
+	)
+
+	addOverwriteFlag(promotionCmd, &promotionOpts.overwrite)
+
+	promotionCmd.PersistentFlags().BoolVar(
+		&promotionOpts.sign,
+		"sign",
+		false,
+		"sign the promotion attestation",
+	)
+
+	parentCmd.AddCommand(promotionCmd)
+}
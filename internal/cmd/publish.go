@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/archivista"
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+// Kinds of --publish destinations
+const (
+	publishArchivista = "archivista"
+	publishChains     = "chains"
+	publishStorage    = "storage"
+)
+
+// chainsStorageOCI is the only Tekton Chains storage backend supported,
+// attaching the attestations to the images as chains=oci does
+const chainsStorageOCI = "oci"
+
+// publishDestination is a place the attestation is uploaded to with
+// --publish. Storage locations (gs://, s3://, oci://) are specified by
+// their URL, other destinations as kind=url.
+type publishDestination struct {
+	Kind string
+	URL  string
+}
+
+// parsePublishDestinations parses the values of --publish
+func parsePublishDestinations(specs []string) ([]publishDestination, error) {
+	dests := []publishDestination{}
+	for _, spec := range specs {
+		if watcher.IsPublishLocation(spec) {
+			dests = append(dests, publishDestination{Kind: publishStorage, URL: spec})
+			continue
+		}
+		kind, u, ok := strings.Cut(spec, "=")
+		if !ok || u == "" {
+			return nil, fmt.Errorf("invalid --publish destination %q, must be a storage URL or kind=url", spec)
+		}
+		switch kind {
+		case publishArchivista:
+		case publishChains:
+			if u != chainsStorageOCI {
+				return nil, fmt.Errorf("unsupported Tekton Chains storage %q, must be %s", u, chainsStorageOCI)
+			}
+		default:
+			return nil, fmt.Errorf("unknown --publish destination kind %q", kind)
+		}
+		dests = append(dests, publishDestination{Kind: kind, URL: u})
+	}
+	return dests, nil
+}
+
+// publishAttestation uploads the serialized attestation to the
+// destinations. Archivista servers only store signed envelopes, storage
+// locations get the file named after its digest and the Tekton Chains
+// OCI storage attaches the signature to the image subjects.
+func publishAttestation(
+	ctx context.Context, dests []publishDestination, att *attestation.Attestation, data []byte, sig *attestation.BlobSignature,
+) error {
+	for _, d := range dests {
+		switch d.Kind {
+		case publishArchivista:
+			c, err := archivista.New(d.URL)
+			if err != nil {
+				return fmt.Errorf("creating archivista client: %w", err)
+			}
+			if _, err := c.Upload(ctx, data); err != nil {
+				return fmt.Errorf("publishing to archivista: %w", err)
+			}
+		case publishChains:
+			images, err := attestation.AttachChains(ctx, att, sig)
+			if err != nil {
+				return fmt.Errorf("publishing to Tekton Chains OCI storage: %w", err)
+			}
+			logrus.Infof("Attached the attestation to %d images", len(images))
+		case publishStorage:
+			if _, err := watcher.PublishFile(ctx, d.URL, data, watcher.PublishAttestationSuffix); err != nil {
+				return fmt.Errorf("publishing attestation: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// publishSnapshots uploads the final storage snapshot state to the
+// storage locations of the destinations
+func publishSnapshots(ctx context.Context, w *watcher.Watcher, dests []publishDestination) error {
+	for _, d := range dests {
+		if d.Kind != publishStorage {
+			continue
+		}
+		if _, err := w.PublishSnapshots(ctx, d.URL); err != nil {
+			return fmt.Errorf("publishing snapshot state: %w", err)
+		}
+	}
+	return nil
+}
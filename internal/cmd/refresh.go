@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type refreshSubjectsOptions struct {
+	artifacts    []string
+	format       string
+	allowMissing bool
+}
+
+func addRefreshSubjects(parentCmd *cobra.Command) {
+	refreshOpts := refreshSubjectsOptions{}
+
+	refreshCmd := &cobra.Command{
+		Short: "Check the subjects of an attestation against the live stores",
+		Long: `tejolote refresh-subjects attestation.intoto.json
+
+The refresh-subjects subcommand re-reads the artifact stores of an
+existing attestation, recomputes the digests of the artifacts named as
+subjects and reports whether the published artifacts still match the
+attested digests. Run it periodically to monitor releases.
+
+The stores are read from the subject completeness recorded in the
+predicate. Attestations without it need the stores in --artifacts.
+
+The command fails if any subject changed or, unless --allow-missing
+is set, if any subject is no longer found in the stores.
+
+	`,
+		Use:               "refresh-subjects",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("the path to an attestation is required")
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("reading attestation: %w", err)
+			}
+			att := attestation.New().SLSA()
+			if err := json.Unmarshal(data, att); err != nil {
+				return fmt.Errorf("unmarshaling attestation %s: %w", args[0], err)
+			}
+
+			report, err := watcher.RefreshSubjects(cmd.Context(), att, refreshOpts.artifacts)
+			if err != nil {
+				return fmt.Errorf("refreshing subjects: %w", err)
+			}
+
+			switch refreshOpts.format {
+			case "json":
+				out, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshaling report: %w", err)
+				}
+				fmt.Println(string(out))
+			case "text":
+				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "STATUS\tSUBJECT")
+				for _, s := range report.Subjects {
+					fmt.Fprintf(tw, "%s\t%s\n", s.Status, s.Name)
+				}
+				tw.Flush()
+			default:
+				return fmt.Errorf("unknown output format %q", refreshOpts.format)
+			}
+
+			if n := report.Counts[watcher.SubjectChanged]; n > 0 {
+				return fmt.Errorf("%d subjects do not match the attested digests", n)
+			}
+			if n := report.Counts[watcher.SubjectMissing]; n > 0 && !refreshOpts.allowMissing {
+				return fmt.Errorf("%d subjects were not found in the artifact stores", n)
+			}
+			return nil
+		},
+	}
+
+	refreshCmd.PersistentFlags().StringSliceVar(
+		&refreshOpts.artifacts,
+		"artifacts",
+		[]string{},
+		"artifact stores to read, overrides those recorded in the attestation",
+	)
+
+	refreshCmd.PersistentFlags().StringVar(
+		&refreshOpts.format,
+		"format",
+		"text",
+		"output format (text or json)",
+	)
+
+	refreshCmd.PersistentFlags().BoolVar(
+		&refreshOpts.allowMissing,
+		"allow-missing",
+		false,
+		"do not fail when subjects are not found in the stores",
+	)
+
+	parentCmd.AddCommand(refreshCmd)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type replayOptions struct {
+	runPath   string
+	prePath   string
+	postPath  string
+	output    string
+	overwrite string
+	draft     string
+	vcsURLs   []string
+	sign      bool
+}
+
+func (opts *replayOptions) Validate() error {
+	if opts.runPath == "" {
+		return errors.New("no captured run specified (--run)")
+	}
+	if opts.postPath == "" {
+		return errors.New("no post build snapshots specified (--post)")
+	}
+	return nil
+}
+
+func addReplay(parentCmd *cobra.Command) {
+	replayOpts := &replayOptions{}
+
+	replayCmd := &cobra.Command{
+		Short: "Regenerate an attestation from captured run data",
+		Long: `tejolote replay --run run.json --pre pre.json --post post.json
+
+The replay subcommand rebuilds an attestation entirely from the data
+captured with tejolote attest --capture, without contacting the build
+system or the artifact stores. This makes it possible to regenerate
+attestations when the predicate format changes or a bug is fixed.
+
+The artifacts of the run are computed from the delta between the pre
+and post snapshots. If --pre is not set, every artifact in the post
+snapshots is considered new.
+
+Data the build system drivers fetch from other APIs to enrich the
+predicate (like the digest of the GitHub workflow file) is not
+recorded in replayed attestations.
+
+	`,
+		Use:               "replay",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := replayOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			outputOpts := outputOptions{OutputPath: replayOpts.output, Overwrite: replayOpts.overwrite}
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if err := outputOpts.CheckOutput(); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+
+			w, r, err := watcher.ReplayRun(replayOpts.runPath, replayOpts.prePath, replayOpts.postPath)
+			if err != nil {
+				return fmt.Errorf("replaying run: %w", err)
+			}
+			w.Builder.VCSURLs = replayOpts.vcsURLs
+			if err := w.LoadAttestation(replayOpts.draft); err != nil {
+				return fmt.Errorf("loading draft attestation: %w", err)
+			}
+
+			att, err := w.AttestRun(ctx, r)
+			if err != nil {
+				return fmt.Errorf("generating run attestation: %w", err)
+			}
+
+			var json []byte
+			if replayOpts.sign {
+				var sig *attestation.BlobSignature
+				if sig, err = w.SignAttestation(ctx, att, r); err == nil {
+					json = sig.Signature
+				}
+			} else {
+				json, err = att.ToJSON()
+			}
+			if err != nil {
+				return fmt.Errorf("serializing attestation: %w", err)
+			}
+
+			if replayOpts.output != "" {
+				if err := outputOpts.WriteOutput(json); err != nil {
+					return fmt.Errorf("writing attestation file: %w", err)
+				}
+				return nil
+			}
+
+			fmt.Println(string(json))
+			return nil
+		},
+	}
+
+	replayCmd.PersistentFlags().StringVar(
+		&replayOpts.runPath,
+		"run",
+		"",
+		"path to the run data captured with tejolote attest --capture",
+	)
+
+	replayCmd.PersistentFlags().StringVar(
+		&replayOpts.prePath,
+		"pre",
+		"",
+		"path to the snapshots of the artifact stores before the build",
+	)
+
+	replayCmd.PersistentFlags().StringVar(
+		&replayOpts.postPath,
+		"post",
+		"",
+		"path to the snapshots of the artifact stores after the build",
+	)
+
+	replayCmd.PersistentFlags().StringVar(
+		&replayOpts.draft,
+		"continue",
+		"",
+		"path to the partial attestation the run was started with",
+	)
+
+	replayCmd.PersistentFlags().StringSliceVar(
+		&replayOpts.vcsURLs,
+		"vcs-url",
+		[]string{},
+		"VCS locators to add to the materials, as set when attesting the run",
+	)
+
+	replayCmd.PersistentFlags().StringVar(
+		&replayOpts.output,
+		"output",
+		"",
+		"file to store the attestation (instead of STDOUT)",
+	)
+
+	addOverwriteFlag(replayCmd, &replayOpts.overwrite)
+
+	replayCmd.PersistentFlags().BoolVar(
+		&replayOpts.sign,
+		"sign",
+		false,
+		"sign the attestation",
+	)
+
+	parentCmd.AddCommand(replayCmd)
+}
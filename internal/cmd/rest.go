@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/service"
+)
+
+func addRest(parentCmd *cobra.Command) {
+	restOpts := &serviceOptions{}
+
+	restCmd := &cobra.Command{
+		Short: "Serve the attestation of build runs over an HTTP API",
+		Long: `tejolote rest --listen :8080 --results-dir /var/lib/tejolote
+
+The rest subcommand runs tejolote behind an HTTP API to back a
+provenance service:
+
+  POST /v1/attestations       registers the observation of a run and
+                              responds 202 right away. The artifact
+                              stores are snapshotted in the background
+                              and the run is attested once it is done.
+                              The body is a JSON object:
+                              {"run": "github://org/repo/1234",
+                               "artifacts": ["gs://bucket/path"],
+                               "sign": true}
+  GET  /v1/attestations/{id}  returns the observation. Its phase is
+                              Snapshotting, Attesting, Attested (with
+                              the attestation) or Failed (with a
+                              message).
+
+Finished observations are stored in --results-dir when set and served
+again after a restart.
+
+The server requires TLS (--tls-cert and --tls-key) and authenticates
+clients with their certificates (--client-ca) or bearer tokens in the
+Authorization header (--token-file). Pass --insecure to serve without
+them on a trusted network.
+
+Artifact stores reading the filesystem of the server (file://) are
+rejected unless listed in --allowed-store-schemes, and attestations are
+only signed with the identity of the server when --allow-sign is set.
+
+	`,
+		Use:               "rest",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return serveRest(cmd.Context(), restOpts)
+		},
+	}
+
+	restOpts.addFlags(restCmd, ":8080")
+
+	restCmd.PersistentFlags().DurationVar(
+		&restOpts.snapshotTimeout,
+		"snapshot-timeout",
+		10*time.Minute,
+		"maximum time to snapshot the artifact stores of an observation",
+	)
+
+	parentCmd.AddCommand(restCmd)
+}
+
+// serveRest runs the HTTP server until the context is canceled
+func serveRest(ctx context.Context, opts *serviceOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return err
+	}
+	auth, err := opts.authenticator()
+	if err != nil {
+		return err
+	}
+	s, lis, err := opts.newService()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	handler := service.NewHTTPHandler(s)
+	if auth != nil {
+		handler = auth.Handler(handler)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx) //nolint: errcheck
+	}()
+
+	logrus.Infof("Serving HTTP on %s", lis.Addr())
+	if tlsConfig != nil {
+		err = srv.ServeTLS(lis, "", "")
+	} else {
+		err = srv.Serve(lis)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving http: %w", err)
+	}
+	return nil
+}
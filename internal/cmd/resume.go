@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/release-utils/util"
+
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type resumeOptions struct {
+	from        string
+	storageSnap string
+	output      string
+	overwrite   string
+	artifacts   []string
+	sign        bool
+}
+
+func (opts *resumeOptions) Validate() error {
+	if opts.from == "" {
+		return errors.New("no partial attestation specified (--from)")
+	}
+	if !util.Exists(opts.from) {
+		return fmt.Errorf("partial attestation %s not found", opts.from)
+	}
+	if opts.storageSnap != "" && !util.Exists(opts.storageSnap) {
+		return fmt.Errorf("storage snapshot state %s not found", opts.storageSnap)
+	}
+	return nil
+}
+
+func addResume(parentCmd *cobra.Command) {
+	resumeOpts := &resumeOptions{}
+
+	resumeCmd := &cobra.Command{
+		Short: "Complete an attestation started with tejolote start attestation",
+		Long: `tejolote resume --from partial.json --storage-snap state.json buildsys://build-run/identifier
+
+The resume subcommand completes the partial attestation saved by
+tejolote start attestation. It reloads the partial attestation and the
+storage snapshot state, waits for the run to finish, computes the
+artifacts that changed in the stores and emits the final attestation.
+
+If --storage-snap is not set, the snapshot state is read from the file
+start attestation writes next to the partial attestation. The artifact
+stores to watch are read from the snapshot state unless they are
+overridden with --artifacts.
+
+	`,
+		Use:               "resume",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("build run spec URL not specified")
+			}
+			if err := resumeOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+
+			// The attestation is written by attestRun, which checks the
+			// output before observing the run
+			outputOpts := &outputOptions{
+				OutputPath:        resumeOpts.output,
+				SnapshotStatePath: "default",
+				Overwrite:         resumeOpts.overwrite,
+			}
+			if err := outputOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if resumeOpts.storageSnap != "" {
+				outputOpts.SnapshotStatePath = resumeOpts.storageSnap
+			}
+			snapPath := outputOpts.FinalSnapshotStatePath(resumeOpts.from)
+
+			artifacts := resumeOpts.artifacts
+			if len(artifacts) == 0 && snapshotStateExists(snapPath) {
+				stores, err := watcher.SnapshotStores(cmd.Context(), snapPath)
+				if err != nil {
+					return fmt.Errorf("reading stores from snapshot state: %w", err)
+				}
+				logrus.Infof("Resuming with %d artifact stores from %s", len(stores), snapPath)
+				artifacts = stores
+			}
+
+			attestOpts := &attestOptions{
+				waitForBuild:     true,
+				sign:             resumeOpts.sign,
+				continueExisting: resumeOpts.from,
+				artifacts:        artifacts,
+			}
+
+			json, err := attestRun(cmd.Context(), args[0], attestOpts, outputOpts)
+			if err != nil {
+				return err
+			}
+
+			if resumeOpts.output == "" {
+				fmt.Println(string(json))
+			}
+			return nil
+		},
+	}
+
+	resumeCmd.PersistentFlags().StringVar(
+		&resumeOpts.from,
+		"from",
+		"",
+		"path to the partial attestation written by tejolote start attestation",
+	)
+
+	resumeCmd.PersistentFlags().StringVar(
+		&resumeOpts.storageSnap,
+		"storage-snap",
+		"",
+		"path to the storage snapshot state (defaults to the one saved next to --from)",
+	)
+
+	resumeCmd.PersistentFlags().StringVar(
+		&resumeOpts.output,
+		"output",
+		"",
+		"file to store the final attestation (instead of STDOUT)",
+	)
+
+	addOverwriteFlag(resumeCmd, &resumeOpts.overwrite)
+
+	resumeCmd.PersistentFlags().StringSliceVar(
+		&resumeOpts.artifacts,
+		"artifacts",
+		[]string{},
+		"artifact stores to watch, overrides those recorded in the snapshot state",
+	)
+
+	resumeCmd.PersistentFlags().BoolVar(
+		&resumeOpts.sign,
+		"sign",
+		false,
+		"sign the attestation",
+	)
+
+	parentCmd.AddCommand(resumeCmd)
+}
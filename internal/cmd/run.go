@@ -52,7 +52,7 @@ where they came from.
 	`,
 		Use:               "run",
 		SilenceUsage:      false,
-		PersistentPreRunE: initLogging,
+		PersistentPreRunE: initCommand,
 		RunE: func(_ *cobra.Command, args []string) (err error) {
 			runner := buildRunner(runOpts)
 
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	buildDriver "sigs.k8s.io/tejolote/pkg/builder/driver"
+	"sigs.k8s.io/tejolote/pkg/publisher"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+type schemeInfo struct {
+	Scheme       string      `json:"scheme"`
+	Type         string      `json:"type"`
+	Capabilities interface{} `json:"capabilities"`
+}
+
+func addSchemes(parentCmd *cobra.Command) {
+	var format string
+
+	schemesCmd := &cobra.Command{
+		Short: "List the supported spec URL schemes and driver capabilities",
+		Long: `tejolote schemes
+
+The schemes subcommand lists the URL schemes tejolote understands for
+build systems, artifact stores and message publishers, along with the
+features each driver supports.
+
+	`,
+		Use:               "schemes",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			schemes := []schemeInfo{}
+			for scheme, caps := range buildDriver.Schemes() {
+				schemes = append(schemes, schemeInfo{Scheme: scheme, Type: "builder", Capabilities: caps})
+			}
+			for scheme, caps := range store.Schemes() {
+				schemes = append(schemes, schemeInfo{Scheme: scheme, Type: "store", Capabilities: caps})
+			}
+			for scheme, caps := range publisher.Schemes() {
+				schemes = append(schemes, schemeInfo{Scheme: scheme, Type: "publisher", Capabilities: caps})
+			}
+			sort.Slice(schemes, func(i, j int) bool {
+				if schemes[i].Type != schemes[j].Type {
+					return schemes[i].Type < schemes[j].Type
+				}
+				return schemes[i].Scheme < schemes[j].Scheme
+			})
+
+			switch format {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(schemes); err != nil {
+					return fmt.Errorf("encoding schemes: %w", err)
+				}
+			case "text":
+				tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+				fmt.Fprintln(tw, "SCHEME\tTYPE\tCAPABILITIES")
+				for _, s := range schemes {
+					fmt.Fprintf(tw, "%s\t%s\t%+v\n", s.Scheme, s.Type, s.Capabilities)
+				}
+				return tw.Flush()
+			default:
+				return fmt.Errorf("unknown output format %q", format)
+			}
+			return nil
+		},
+	}
+
+	schemesCmd.PersistentFlags().StringVarP(
+		&format,
+		"output",
+		"o",
+		"text",
+		"output format (text or json)",
+	)
+
+	parentCmd.AddCommand(schemesCmd)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/service"
+	"sigs.k8s.io/tejolote/pkg/workdir"
+)
+
+// serviceOptions are the options shared by the grpc and rest servers
+type serviceOptions struct {
+	listen      string
+	stateDir    string
+	resultsDir  string
+	concurrency int
+	configPath  string
+	tlsCert     string
+	tlsKey      string
+	clientCA    string
+	tokenFile   string
+	insecure    bool
+
+	runSchemes   []string
+	storeSchemes []string
+	allowSign    bool
+
+	// snapshotTimeout bounds the background snapshots of the rest server
+	snapshotTimeout time.Duration
+}
+
+func (opts *serviceOptions) addFlags(cmd *cobra.Command, listen string) {
+	cmd.PersistentFlags().StringVar(
+		&opts.listen,
+		"listen",
+		listen,
+		"address to listen on",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.stateDir,
+		"state-dir",
+		"",
+		"directory to keep the snapshot states of the observations in progress (defaults to a temporary directory)",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.resultsDir,
+		"results-dir",
+		"",
+		"directory to store the finished observations and their attestations (kept in memory if empty)",
+	)
+
+	cmd.PersistentFlags().IntVar(
+		&opts.concurrency,
+		"concurrency",
+		10,
+		"number of runs to attest at the same time",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.configPath,
+		"config",
+		"",
+		"configuration file with the annotators, hooks and policies applied to the attestations",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.tlsCert,
+		"tls-cert",
+		"",
+		"TLS certificate file to serve with (requires --tls-key)",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.tlsKey,
+		"tls-key",
+		"",
+		"TLS private key file of the certificate",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.clientCA,
+		"client-ca",
+		"",
+		"CA bundle to verify client certificates with, requires clients to authenticate with mTLS",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&opts.tokenFile,
+		"token-file",
+		"",
+		"file with the bearer tokens accepted from clients, one per line",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&opts.insecure,
+		"insecure",
+		false,
+		"serve without TLS or client authentication, only for trusted networks",
+	)
+
+	cmd.PersistentFlags().StringSliceVar(
+		&opts.runSchemes,
+		"allowed-run-schemes",
+		[]string{},
+		"spec URL schemes of the runs accepted (default any)",
+	)
+
+	cmd.PersistentFlags().StringSliceVar(
+		&opts.storeSchemes,
+		"allowed-store-schemes",
+		[]string{},
+		"spec URL schemes of the artifact stores accepted (default all but file)",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&opts.allowSign,
+		"allow-sign",
+		false,
+		"accept requests to sign the attestations with the identity of the server",
+	)
+}
+
+// validate checks the TLS and authentication flags. The server only
+// runs without TLS or client authentication with --insecure.
+func (opts *serviceOptions) validate() error {
+	if (opts.tlsCert == "") != (opts.tlsKey == "") {
+		return errors.New("--tls-cert and --tls-key must be set together")
+	}
+	if opts.clientCA != "" && opts.tlsCert == "" {
+		return errors.New("--client-ca requires --tls-cert and --tls-key")
+	}
+	if opts.insecure {
+		logrus.Warn("Serving with --insecure, clients may not be authenticated")
+		return nil
+	}
+	if opts.tlsCert == "" {
+		return errors.New("serving requires --tls-cert and --tls-key, pass --insecure to serve without TLS")
+	}
+	if opts.clientCA == "" && opts.tokenFile == "" {
+		return errors.New(
+			"serving requires client authentication with --client-ca or --token-file, pass --insecure to serve without it",
+		)
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration of the server, nil when
+// serving without TLS
+func (opts *serviceOptions) tlsConfig() (*tls.Config, error) {
+	if opts.tlsCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(opts.tlsCert, opts.tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if opts.clientCA != "" {
+		data, err := os.ReadFile(opts.clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.clientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// authenticator returns the bearer token authenticator, nil when no
+// token file is set
+func (opts *serviceOptions) authenticator() (*service.TokenAuthenticator, error) {
+	if opts.tokenFile == "" {
+		return nil, nil
+	}
+	return service.LoadTokenAuthenticator(opts.tokenFile)
+}
+
+// newService returns the service and the listener to serve it on
+func (opts *serviceOptions) newService() (*service.Service, net.Listener, error) {
+	stateDir := opts.stateDir
+	if stateDir == "" {
+		// Observations in progress are not resumed, keep their
+		// state with the temporary files of the process
+		dir, err := workdir.MkdirTemp("service-state-")
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating state directory: %w", err)
+		}
+		stateDir = dir
+	}
+
+	s, err := service.New(service.Options{
+		StateDir:        stateDir,
+		ResultsDir:      opts.resultsDir,
+		Concurrency:     opts.concurrency,
+		SnapshotTimeout: opts.snapshotTimeout,
+		RunSchemes:      opts.runSchemes,
+		StoreSchemes:    opts.storeSchemes,
+		AllowSign:       opts.allowSign,
+	}, opts.snapshot, opts.attest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating service: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", opts.listen)
+	if err != nil {
+		s.Close()
+		return nil, nil, fmt.Errorf("listening on %s: %w", opts.listen, err)
+	}
+	return s, lis, nil
+}
+
+// snapshot takes the snapshot of the stores of a new observation
+func (opts *serviceOptions) snapshot(ctx context.Context, obs *service.Observation, statePath string) error {
+	return snapshotStores(ctx, obs.Run, obs.Artifacts, statePath)
+}
+
+// attest waits for the run of an observation and returns its attestation
+func (opts *serviceOptions) attest(ctx context.Context, obs *service.Observation, statePath string) ([]byte, error) {
+	attestOpts := &attestOptions{
+		waitForBuild:   true,
+		discoverStores: true,
+		originCheck:    "annotate",
+		sign:           obs.Sign,
+		artifacts:      obs.Artifacts,
+		configPath:     opts.configPath,
+	}
+	return attestRun(ctx, obs.Run, attestOpts, &outputOptions{SnapshotStatePath: statePath})
+}
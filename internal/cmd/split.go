@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+// defaultSplitOutput names the split attestations when --output is not set
+const defaultSplitOutput = "{{.Name}}.intoto.json"
+
+// writeSplitAttestations writes a statement for each subject of the
+// attestation to the files named by the --output template, signing
+// them if requested. Its steps mirror the end of attestRun.
+func writeSplitAttestations(
+	ctx context.Context, specURL string, w *watcher.Watcher, r *run.Run,
+	att *attestation.Attestation, attestOpts *attestOptions, outputOpts *outputOptions,
+) error {
+	tmpl := outputOpts.OutputPath
+	if tmpl == "" {
+		tmpl = defaultSplitOutput
+	}
+	paths, err := att.SplitPaths(tmpl)
+	if err != nil {
+		return fmt.Errorf("naming split attestations: %w", err)
+	}
+
+	parts := att.Split()
+	files := make([][]byte, len(parts))
+	sigs := make([]*attestation.BlobSignature, len(parts))
+	for i, part := range parts {
+		if attestOpts.sign {
+			if sigs[i], err = w.SignAttestation(ctx, part, r); err == nil {
+				files[i] = sigs[i].Signature
+			}
+		} else {
+			files[i], err = part.ToJSON()
+		}
+		if err != nil {
+			return fmt.Errorf("serializing attestation of %s: %w", part.Subject[0].Name, err)
+		}
+	}
+
+	if err := w.VerifyImmutable(ctx, r); err != nil {
+		if !attestOpts.immutableWarn {
+			return fmt.Errorf("verifying artifact immutability: %w", err)
+		}
+		logrus.Warnf("artifacts changed after attesting: %v", err)
+	}
+
+	if attestOpts.uploadRelease {
+		releaseURL, err := w.ReleaseURL(r, attestOpts.releaseURL)
+		if err != nil {
+			return fmt.Errorf("locating release: %w", err)
+		}
+		assets := map[string][]byte{}
+		for i := range paths {
+			maps.Copy(assets, watcher.ReleaseAssets(filepath.Base(paths[i]), files[i]))
+		}
+		if err := watcher.UploadReleaseAssets(ctx, releaseURL, assets); err != nil {
+			return fmt.Errorf("uploading attestations to release: %w", err)
+		}
+	}
+
+	for i, path := range paths {
+		fileOpts := outputOptions{OutputPath: path, Overwrite: outputOpts.Overwrite}
+		if err := fileOpts.WriteOutput(files[i]); err != nil {
+			return fmt.Errorf("writing attestation file: %w", err)
+		}
+		if err := fileOpts.WriteTimestamp(sigs[i]); err != nil {
+			return err
+		}
+		if err := publishAttestation(ctx, attestOpts.publishDests, parts[i], files[i], sigs[i]); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("Wrote %d attestations, one per subject", len(paths))
+	if err := publishSnapshots(ctx, w, attestOpts.publishDests); err != nil {
+		return err
+	}
+
+	if attestOpts.actionsOutputs {
+		if err := writeActionsOutputs(specURL, att, strings.Join(paths, "\n")); err != nil {
+			return fmt.Errorf("writing GitHub Actions outputs: %w", err)
+		}
+	}
+	return nil
+}
@@ -17,10 +17,8 @@ limitations under the License.
 package cmd
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -29,23 +27,29 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
-	"sigs.k8s.io/release-utils/util"
-
 	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
 	"sigs.k8s.io/tejolote/pkg/watcher"
 )
 
 type startAttestationOptions struct {
 	clone           bool
 	repo            string
-	repoPath        string
+	repoPaths       []string
 	pubsub          string
-	vcsURL          string
+	claimCheck      string
+	snapshotsRef    string
+	cloudEvents     bool
+	vcsURLs         []string
 	builder         string
 	configSrcEntry  string
 	configSrcURI    string
 	configSrcDigest string
 	artifacts       []string
+	requireEmpty    []string
+	snapshotIndex   string
+	snapshotChunks  string
+	configPath      string
 }
 
 func (opts *startAttestationOptions) Validate() error {
@@ -53,7 +57,7 @@ func (opts *startAttestationOptions) Validate() error {
 		return errors.New("repository clone requested but no repository was specified")
 	}
 
-	if opts.clone && opts.repoPath == "" {
+	if opts.clone && len(opts.repoPaths) == 0 {
 		return errors.New("repository clone requested but no repository path was specified")
 	}
 	return nil
@@ -68,7 +72,7 @@ func addStart(parentCmd *cobra.Command) {
 		Short:             "Start a partial document",
 		Use:               "start",
 		SilenceUsage:      false,
-		PersistentPreRunE: initLogging,
+		PersistentPreRunE: initCommand,
 	}
 
 	// Noun
@@ -91,11 +95,17 @@ attestation but with ".storage-snap.json" appended.
 	`,
 		Use:               "attestation",
 		SilenceUsage:      false,
-		PersistentPreRunE: initLogging,
-		RunE: func(_ *cobra.Command, args []string) (err error) {
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			if err := startAttestationOpts.Validate(); err != nil {
 				return fmt.Errorf("validating options: %w", err)
 			}
+			if err := outputOps.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+			if err := outputOps.CheckOutput(); err != nil {
+				return err
+			}
 
 			if len(args) == 0 {
 				return errors.New("build run spec URL not specified")
@@ -106,6 +116,19 @@ attestation but with ".storage-snap.json" appended.
 				return fmt.Errorf("building watcher")
 			}
 
+			w.Options.ClaimCheckLocation = startAttestationOpts.claimCheck
+			w.Options.SnapshotsLocation = startAttestationOpts.snapshotsRef
+			w.Options.CloudEvents = startAttestationOpts.cloudEvents
+			w.Options.IndexDir = startAttestationOpts.snapshotIndex
+			w.Options.SnapshotChunkLocation = startAttestationOpts.snapshotChunks
+			if startAttestationOpts.configPath != "" {
+				conf, err := config.Load(startAttestationOpts.configPath)
+				if err != nil {
+					return fmt.Errorf("loading configuration: %w", err)
+				}
+				w.Options.Hooks = conf.Hooks
+			}
+
 			// Add artifact monitors to the watcher
 			for _, uri := range startAttestationOpts.artifacts {
 				if err := w.AddArtifactSource(uri); err != nil {
@@ -113,16 +136,21 @@ attestation but with ".storage-snap.json" appended.
 				}
 			}
 
-			if err := w.Snap(); err != nil {
+			if err := w.Snap(cmd.Context()); err != nil {
 				return fmt.Errorf("snapshotting the artifact repositories: %w", err)
 			}
 
+			w.Options.RequireEmpty = startAttestationOpts.requireEmpty
+			if err := w.CheckEmptyStores(); err != nil {
+				return fmt.Errorf("checking artifact stores: %w", err)
+			}
+
 			if outputOps.FinalSnapshotStatePath(outputOps.OutputPath) == "" {
 				if len(w.Snapshots) > 0 {
 					logrus.Warning("Not saving storage state but artifact sources defined")
 				}
 			} else {
-				if err := w.SaveSnapshots(outputOps.FinalSnapshotStatePath(outputOps.OutputPath)); err != nil {
+				if err := w.SaveSnapshots(cmd.Context(), outputOps.FinalSnapshotStatePath(outputOps.OutputPath)); err != nil {
 					return fmt.Errorf("saving storage snapshots: %w", err)
 				}
 			}
@@ -135,31 +163,18 @@ attestation but with ".storage-snap.json" appended.
 				return fmt.Errorf("repository cloning not yet implemented")
 			}
 
-			vcsURL := startAttestationOpts.vcsURL
-			if vcsURL == "" {
-				vcsURL, err = readVCSURL(outputOps, startAttestationOpts)
+			vcsURLs := startAttestationOpts.vcsURLs
+			if len(vcsURLs) == 0 {
+				vcsURLs, err = readVCSURLs(outputOps, startAttestationOpts)
 				if err != nil {
 					return fmt.Errorf("fetching VCS URL: %w", err)
 				}
 			}
 
-			if vcsURL != "" {
-				material := common.ProvenanceMaterial{
-					URI:    vcsURL,
-					Digest: map[string]string{},
-				}
-				commithash := map[string]string{}
-				if repoURL, repoDigest, ok := strings.Cut(vcsURL, "@"); ok {
-					// The thing after the @ may not be a commit
-					if len(repoDigest) == 40 {
-						commithash["sha1"] = repoDigest
-					} else {
-						repoURL = vcsURL
-					}
-					material.URI = repoURL
-					material.Digest = commithash
-				}
-				predicate.Materials = append(predicate.Materials, material)
+			// One material per repository checked out by the build
+			for _, vcsURL := range vcsURLs {
+				uri, digest, _ := attestation.ParseVCSURL(vcsURL)
+				predicate.AddMaterial(uri, digest)
 			}
 
 			att.Predicate = predicate
@@ -182,31 +197,24 @@ attestation but with ".storage-snap.json" appended.
 			if outputOps.OutputPath == "" {
 				fmt.Println(string(json))
 			} else {
-				err = os.WriteFile(outputOps.OutputPath, json, os.FileMode(0o644))
-				if err != nil {
+				if err := outputOps.WriteOutput(json); err != nil {
 					return fmt.Errorf("writing output data: %w", err)
 				}
 			}
 
 			if startAttestationOpts.pubsub != "" {
 				var sdata []byte
-				if util.Exists(outputOps.FinalSnapshotStatePath(outputOps.OutputPath)) {
-					sdata, err = os.ReadFile(outputOps.FinalSnapshotStatePath(outputOps.OutputPath))
+				if snapshotStateExists(outputOps.FinalSnapshotStatePath(outputOps.OutputPath)) {
+					sdata, err = watcher.ReadSnapshotState(cmd.Context(), outputOps.FinalSnapshotStatePath(outputOps.OutputPath))
 					if err != nil {
 						return fmt.Errorf("reading snapshot data: %w", err)
 					}
 				}
-				message := watcher.StartMessage{
-					SpecURL:      w.Builder.SpecURL,
-					Attestation:  base64.StdEncoding.EncodeToString(json),
-					Artifacts:    startAttestationOpts.artifacts,
-					ArtifactList: strings.Join(startAttestationOpts.artifacts, ","),
-				}
-				if sdata != nil {
-					message.Snapshots = base64.StdEncoding.EncodeToString(sdata)
-				}
+				message := watcher.NewStartMessage(
+					w.Builder.SpecURL, startAttestationOpts.artifacts, json, sdata,
+				)
 
-				if err := w.PublishToTopic(startAttestationOpts.pubsub, message); err != nil {
+				if err := w.PublishToTopic(cmd.Context(), startAttestationOpts.pubsub, message); err != nil {
 					return fmt.Errorf("publishing message to pubsub topic: %w", err)
 				}
 			}
@@ -224,11 +232,11 @@ attestation but with ".storage-snap.json" appended.
 		"url of repository containing the main project source",
 	)
 
-	startAttestationCmd.PersistentFlags().StringVar(
-		&startAttestationOpts.repoPath,
+	startAttestationCmd.PersistentFlags().StringSliceVar(
+		&startAttestationOpts.repoPaths,
 		"repo-path",
-		".",
-		"path to the main code repository (relative to workspace)",
+		[]string{"."},
+		"paths to the code repositories to probe for VCS URLs (relative to workspace), the first is the main one",
 	)
 
 	startAttestationCmd.PersistentFlags().BoolVar(
@@ -245,18 +253,67 @@ attestation but with ".storage-snap.json" appended.
 		"artifact storage locations",
 	)
 
+	startAttestationCmd.PersistentFlags().StringSliceVar(
+		&startAttestationOpts.requireEmpty,
+		"require-empty",
+		[]string{},
+		"artifact storage locations that must be empty before the build starts",
+	)
+
+	startAttestationCmd.PersistentFlags().StringVar(
+		&startAttestationOpts.snapshotIndex,
+		"snapshot-index",
+		"",
+		"directory to write on-disk indexes of the artifact stores instead of holding their snapshots in memory",
+	)
+
+	startAttestationCmd.PersistentFlags().StringVar(
+		&startAttestationOpts.snapshotChunks,
+		"snapshot-chunks",
+		"",
+		"directory or bucket URL (gs://, s3://) to save the snapshots as content-addressed chunks shared across runs",
+	)
+
+	startAttestationCmd.PersistentFlags().StringVar(
+		&startAttestationOpts.configPath,
+		"config",
+		"",
+		"path to a tejolote configuration file (YAML or JSON) defining the lifecycle hooks",
+	)
+
 	startAttestationCmd.PersistentFlags().StringVar(
 		&startAttestationOpts.pubsub,
 		"pubsub",
 		"",
-		"publish event to a pubsub topic",
+		"publish event to a Pub/Sub, SNS or NATS topic, SQS queue or webhook (https://)",
 	)
 
 	startAttestationCmd.PersistentFlags().StringVar(
-		&startAttestationOpts.vcsURL,
-		"vcs-url",
+		&startAttestationOpts.claimCheck,
+		"pubsub-claim-check",
 		"",
-		"VCS locator to add to SLSA materials (if empty will be probed)",
+		"bucket url (gs://bucket/path or s3://bucket/path) to upload pubsub messages too large to publish",
+	)
+
+	startAttestationCmd.PersistentFlags().StringVar(
+		&startAttestationOpts.snapshotsRef,
+		"pubsub-snapshots-location",
+		"",
+		"bucket url (gs://bucket/path or s3://bucket/path) to upload the storage snapshots to, sending only their location and digest in the start message",
+	)
+
+	startAttestationCmd.PersistentFlags().BoolVar(
+		&startAttestationOpts.cloudEvents,
+		"cloudevents",
+		false,
+		"wrap the published messages in a CloudEvents 1.0 envelope",
+	)
+
+	startAttestationCmd.PersistentFlags().StringSliceVar(
+		&startAttestationOpts.vcsURLs,
+		"vcs-url",
+		[]string{},
+		"VCS locators to add to SLSA materials, one per repository (if empty will be probed from --repo-path)",
 	)
 
 	startAttestationCmd.PersistentFlags().StringVar(
@@ -291,18 +348,28 @@ attestation but with ".storage-snap.json" appended.
 	parentCmd.AddCommand(startCmd)
 }
 
-// readVCSURL checks the repository path to get the VCS url for the
+// readVCSURLs checks the repository paths to get the VCS urls for the
 // materials
-func readVCSURL(outputOpts *outputOptions, opts *startAttestationOptions) (string, error) {
-	if opts.repoPath == "" {
-		return "", nil
+func readVCSURLs(outputOpts *outputOptions, opts *startAttestationOptions) ([]string, error) {
+	vcsURLs := []string{}
+	for _, repoPath := range opts.repoPaths {
+		if repoPath == "" {
+			continue
+		}
+		vcsURL, err := readVCSURL(outputOpts, repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("probing %s: %w", repoPath, err)
+		}
+		vcsURLs = append(vcsURLs, vcsURL)
 	}
+	return vcsURLs, nil
+}
 
-	repoPath := opts.repoPath
-
+// readVCSURL probes a repository path to get its VCS url
+func readVCSURL(outputOpts *outputOptions, repoPath string) (string, error) {
 	// If its a relative URL, append the workspace
-	if !strings.HasPrefix(opts.repoPath, string(filepath.Separator)) {
-		repoPath = filepath.Join(outputOpts.Workspace, opts.repoPath)
+	if !strings.HasPrefix(repoPath, string(filepath.Separator)) {
+		repoPath = filepath.Join(outputOpts.Workspace, repoPath)
 	}
 
 	repoPath, err := filepath.Abs(repoPath)
@@ -0,0 +1,352 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/tejolote/pkg/gcp"
+	"sigs.k8s.io/tejolote/pkg/output"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type workerOptions struct {
+	subscription string
+	output       string
+	overwrite    string
+	originCheck  string
+	sign         bool
+	concurrency  int
+	maxWait      time.Duration
+	pprofAddr    string
+	cloudBuilds  bool
+}
+
+func (opts *workerOptions) Validate() error {
+	parts := strings.Split(opts.subscription, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "subscriptions" {
+		return errors.New("invalid subscription, format: projects/PROJECTID/subscriptions/NAME")
+	}
+	if opts.output == "" {
+		return errors.New("no output location specified")
+	}
+	outputOpts := outputOptions{Overwrite: opts.overwrite}
+	if err := outputOpts.Validate(); err != nil {
+		return err
+	}
+	if opts.concurrency < 1 {
+		return errors.New("concurrency has to be at least 1")
+	}
+	return validateOriginCheck(opts.originCheck)
+}
+
+func addWorker(parentCmd *cobra.Command) {
+	workerOpts := &workerOptions{}
+
+	workerCmd := &cobra.Command{
+		Short: "Finish attestations started with tejolote start from a Pub/Sub subscription",
+		Long: `tejolote worker --subscription projects/PROJECT/subscriptions/NAME
+
+The worker subcommand listens to a Pub/Sub subscription receiving the
+messages published by tejolote start attestation --pubsub. For each
+message, the worker waits for the referenced build to finish, completes
+the partial attestation and writes it to the output location.
+
+The output location can be a local directory or a GCS bucket path
+(gs://bucket/path). Attestations are named after the run spec URL.
+
+Messages are acknowledged once the attestation is written. If the
+attestation fails, the message is nacked to have Pub/Sub redeliver it.
+With --overwrite=never, messages of runs already attested in the output
+location are acknowledged without observing the run again.
+
+With --cloud-builds, the subscription is expected to be attached to the
+cloud-builds topic where Google Cloud Build publishes build status
+updates. Builds reaching SUCCESS are attested, including the images and
+artifact objects declared in their build config.
+
+	`,
+		Use:               "worker",
+		SilenceUsage:      false,
+		PersistentPreRunE: initCommand,
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			if err := workerOpts.Validate(); err != nil {
+				return fmt.Errorf("validating options: %w", err)
+			}
+
+			ctx := cmd.Context()
+
+			if workerOpts.pprofAddr != "" {
+				servePprof(ctx, workerOpts.pprofAddr)
+			}
+
+			parts := strings.Split(workerOpts.subscription, "/")
+			client, err := gcp.NewPubSubClient(ctx, parts[1])
+			if err != nil {
+				return fmt.Errorf("creating pubsub client: %w", err)
+			}
+			defer client.Close()
+
+			sub := client.Subscription(parts[3])
+			sub.ReceiveSettings.MaxOutstandingMessages = workerOpts.concurrency
+			sub.ReceiveSettings.MaxExtension = workerOpts.maxWait
+
+			process := func(ctx context.Context, m *pubsub.Message) error {
+				return workerOpts.processMessage(ctx, m.Data)
+			}
+			if workerOpts.cloudBuilds {
+				process = func(ctx context.Context, m *pubsub.Message) error {
+					return workerOpts.processBuildNotification(ctx, m.Attributes, m.Data)
+				}
+			}
+
+			logrus.Infof("Listening for messages in %s", workerOpts.subscription)
+			if err := sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+				if err := process(ctx, m); err != nil {
+					if !ackFailedMessage(err) {
+						logrus.Errorf("Processing message %s: %v", m.ID, err)
+						m.Nack()
+						return
+					}
+					logrus.Infof("Ignoring message %s: %v", m.ID, err)
+				}
+				m.Ack()
+			}); err != nil {
+				return fmt.Errorf("receiving messages: %w", err)
+			}
+			return nil
+		},
+	}
+
+	workerCmd.PersistentFlags().StringVar(
+		&workerOpts.subscription,
+		"subscription",
+		"",
+		"pubsub subscription to read start messages from (projects/PROJECTID/subscriptions/NAME)",
+	)
+
+	workerCmd.PersistentFlags().StringVar(
+		&workerOpts.output,
+		"output",
+		"",
+		"directory or bucket path (gs://bucket/path) to write the attestations",
+	)
+
+	addOverwriteFlag(workerCmd, &workerOpts.overwrite)
+	addOriginCheckFlag(workerCmd, &workerOpts.originCheck)
+
+	workerCmd.PersistentFlags().BoolVar(
+		&workerOpts.sign,
+		"sign",
+		false,
+		"sign the attestations",
+	)
+
+	workerCmd.PersistentFlags().IntVar(
+		&workerOpts.concurrency,
+		"concurrency",
+		1,
+		"number of builds to observe at the same time",
+	)
+
+	workerCmd.PersistentFlags().DurationVar(
+		&workerOpts.maxWait,
+		"max-wait",
+		2*time.Hour,
+		"maximum time to hold a message while waiting for its build to finish",
+	)
+
+	workerCmd.PersistentFlags().BoolVar(
+		&workerOpts.cloudBuilds,
+		"cloud-builds",
+		false,
+		"attest the builds notified in a subscription to the Cloud Build cloud-builds topic instead of reading start messages",
+	)
+
+	workerCmd.PersistentFlags().StringVar(
+		&workerOpts.pprofAddr,
+		"pprof",
+		"",
+		"address to serve the pprof profiling endpoints on while the worker runs (eg localhost:6060)",
+	)
+
+	parentCmd.AddCommand(workerCmd)
+}
+
+// servePprof serves the runtime profiling endpoints under /debug/pprof/
+// on addr until the context is canceled
+func servePprof(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	context.AfterFunc(ctx, func() { srv.Close() })
+	go func() {
+		logrus.Infof("Serving pprof endpoints on http://%s/debug/pprof/", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("serving pprof: %v", err)
+		}
+	}()
+}
+
+// ackFailedMessage returns true if a message that could not be processed
+// has to be acknowledged anyway because redelivering it would fail again
+func ackFailedMessage(err error) bool {
+	return errors.Is(err, watcher.ErrNotStartMessage) || errors.Is(err, watcher.ErrBuildNotFinished) ||
+		errors.Is(err, output.ErrExists)
+}
+
+// attestOptions returns the options to attest the runs received by the
+// worker, waiting for them to finish
+func (opts *workerOptions) attestOptions() *attestOptions {
+	return &attestOptions{
+		waitForBuild:   true,
+		discoverStores: true,
+		originCheck:    opts.originCheck,
+		sign:           opts.sign,
+	}
+}
+
+// messageAttestOptions returns the options to finish the attestation
+// started with a start message, continuing its partial attestation and
+// snapshots
+func (opts *workerOptions) messageAttestOptions(msg *watcher.StartMessage) *attestOptions {
+	attestOpts := opts.attestOptions()
+	attestOpts.artifacts = msg.Artifacts
+	if data, ok := msg.Attachment(watcher.AttachmentAttestation); ok {
+		attestOpts.encodedExisting = base64.StdEncoding.EncodeToString(data)
+	}
+	if data, ok := msg.Attachment(watcher.AttachmentSnapshots); ok {
+		attestOpts.encodedSnapshots = base64.StdEncoding.EncodeToString(data)
+	}
+	return attestOpts
+}
+
+// processMessage finishes the attestation of a received start message
+func (opts *workerOptions) processMessage(ctx context.Context, data []byte) error {
+	msg, err := watcher.DecodeStartMessage(ctx, data)
+	if err != nil {
+		return fmt.Errorf("decoding message: %w", err)
+	}
+	logrus.Infof("Received start message for %s", msg.SpecURL)
+	if err := opts.checkAttestation(ctx, msg.SpecURL); err != nil {
+		return err
+	}
+
+	outputOpts := &outputOptions{
+		SnapshotStatePath: "default",
+	}
+	json, err := attestRun(ctx, msg.SpecURL, opts.messageAttestOptions(msg), outputOpts)
+	if err != nil {
+		return fmt.Errorf("attesting %s: %w", msg.SpecURL, err)
+	}
+	return opts.writeAttestation(ctx, msg.SpecURL, json)
+}
+
+// processBuildNotification attests a build notified by Cloud Build as
+// finished successfully. There is no partial attestation, the artifacts
+// are the ones the build declares.
+func (opts *workerOptions) processBuildNotification(ctx context.Context, attributes map[string]string, data []byte) error {
+	n, err := watcher.DecodeBuildNotification(attributes, data)
+	if err != nil {
+		return fmt.Errorf("decoding build notification: %w", err)
+	}
+	specURL := n.SpecURL()
+	logrus.Infof("Build %s finished successfully", specURL)
+	if err := opts.checkAttestation(ctx, specURL); err != nil {
+		return err
+	}
+
+	json, err := attestRun(ctx, specURL, opts.attestOptions(), &outputOptions{})
+	if err != nil {
+		return fmt.Errorf("attesting %s: %w", specURL, err)
+	}
+	return opts.writeAttestation(ctx, specURL, json)
+}
+
+// attestationFilename returns the name of the attestation of a run,
+// its spec URL with the separators replaced by dashes
+func attestationFilename(specURL string) string {
+	return strings.NewReplacer("://", "-", "/", "-", ":", "-").Replace(specURL) + ".intoto.json"
+}
+
+// attestationDest returns the location of the attestation of a run in
+// the output location
+func (opts *workerOptions) attestationDest(specURL string) string {
+	filename := attestationFilename(specURL)
+	if strings.HasPrefix(opts.output, "gs://") {
+		return strings.TrimSuffix(opts.output, "/") + "/" + filename
+	}
+	return filepath.Join(opts.output, filename)
+}
+
+// checkAttestation fails with output.ErrExists before observing a run
+// if its attestation exists and --overwrite never replaces it
+func (opts *workerOptions) checkAttestation(ctx context.Context, specURL string) error {
+	dest := opts.attestationDest(specURL)
+	if !strings.HasPrefix(dest, "gs://") {
+		return output.Check(dest, opts.overwrite)
+	}
+	if opts.overwrite != output.OverwriteNever {
+		return nil
+	}
+	exists, err := driver.ExistsURL(ctx, dest)
+	if err != nil {
+		return fmt.Errorf("checking existing attestation: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%s: %w", dest, output.ErrExists)
+	}
+	return nil
+}
+
+// writeAttestation stores the attestation of a run in the output
+// location. Attestations in buckets are replaced unless --overwrite is
+// never, which checkAttestation refuses before observing the run.
+func (opts *workerOptions) writeAttestation(ctx context.Context, specURL string, json []byte) error {
+	dest := opts.attestationDest(specURL)
+	if strings.HasPrefix(dest, "gs://") {
+		if err := driver.UploadURL(ctx, dest, bytes.NewReader(json)); err != nil {
+			return fmt.Errorf("uploading attestation: %w", err)
+		}
+		logrus.Infof("Attestation of %s uploaded to %s", specURL, dest)
+		return nil
+	}
+
+	if _, err := output.WriteFile(dest, json, opts.overwrite); err != nil {
+		return fmt.Errorf("writing attestation file: %w", err)
+	}
+	logrus.Infof("Attestation of %s written to %s", specURL, dest)
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/output"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+func TestMessageAttestOptions(t *testing.T) {
+	opts := &workerOptions{sign: true, originCheck: "annotate"}
+	msg := watcher.NewStartMessage(
+		"gcb://project/build", []string{"gs://bucket/path/"}, []byte(`{"predicate":{}}`), []byte(`{"version":2}`),
+	)
+	attestOpts := opts.messageAttestOptions(&msg)
+	require.True(t, attestOpts.waitForBuild)
+	require.True(t, attestOpts.discoverStores)
+	require.True(t, attestOpts.sign)
+	require.Equal(t, "annotate", attestOpts.originCheck)
+	require.Equal(t, []string{"gs://bucket/path/"}, attestOpts.artifacts)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"predicate":{}}`)), attestOpts.encodedExisting)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"version":2}`)), attestOpts.encodedSnapshots)
+
+	// Messages without attachments attest the run from scratch
+	msg = watcher.NewStartMessage("gcb://project/build", nil, nil, nil)
+	attestOpts = opts.messageAttestOptions(&msg)
+	require.Empty(t, attestOpts.encodedExisting)
+	require.Empty(t, attestOpts.encodedSnapshots)
+}
+
+func TestAckFailedMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err error
+		ack bool
+	}{
+		{fmt.Errorf("decoding message: %w", watcher.ErrNotStartMessage), true},
+		{fmt.Errorf("decoding build notification: %w", watcher.ErrBuildNotFinished), true},
+		{fmt.Errorf("attesting: %w", output.ErrExists), true},
+		{errors.New("fetching run: connection refused"), false},
+	} {
+		require.Equal(t, tc.ack, ackFailedMessage(tc.err), tc.err.Error())
+	}
+}
+
+func TestAttestationDest(t *testing.T) {
+	for _, tc := range []struct {
+		output   string
+		specURL  string
+		expected string
+	}{
+		{"/attestations", "gcb://project/1234", filepath.Join("/attestations", "gcb-project-1234.intoto.json")},
+		{"gs://bucket/path/", "github://org/repo/42", "gs://bucket/path/github-org-repo-42.intoto.json"},
+		{"gs://bucket", "gcb://project:region/1234", "gs://bucket/gcb-project-region-1234.intoto.json"},
+	} {
+		opts := &workerOptions{output: tc.output}
+		require.Equal(t, tc.expected, opts.attestationDest(tc.specURL))
+	}
+}
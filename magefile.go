@@ -64,6 +64,18 @@ func Test() error {
 	return nil
 }
 
+// TestE2E runs the end to end test suite. The tests build tejolote and
+// run the start → build → attest flow against a mock GitHub API, a fake
+// GCS server and a local registry. No cloud credentials are needed.
+func TestE2E() error {
+	return sh.RunV("go", "test", "-v", "-count=1", "-tags", "e2e", "./test/e2e/...")
+}
+
+// Benchmark runs the go benchmarks of the snapshot and delta code paths
+func Benchmark() error {
+	return sh.RunV("go", "test", "-run", "^$", "-bench", ".", "-benchmem", "./pkg/store/...")
+}
+
 // Verify runs repository verification scripts
 func Verify() error {
 	fmt.Println("Ensuring mage is available...")
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Implementation is the interface of the annotator types. Annotate
+// returns the annotations to attach to the subject of the artifact.
+// Returning an empty map means the annotator has nothing to say
+// about the artifact.
+type Implementation interface {
+	Annotate(context.Context, run.Artifact) (map[string]string, error)
+}
+
+// Annotator wraps an annotator implementation with its configuration
+type Annotator struct {
+	Type  string
+	match *regexp.Regexp
+	impl  Implementation
+}
+
+// New returns an annotator from its configuration
+func New(conf config.Annotator) (*Annotator, error) {
+	a := &Annotator{Type: conf.Type}
+	if conf.Match != "" {
+		re, err := regexp.Compile(conf.Match)
+		if err != nil {
+			return nil, fmt.Errorf("compiling match expression of %s annotator: %w", conf.Type, err)
+		}
+		a.match = re
+	}
+	var err error
+	switch conf.Type {
+	case "version":
+		a.impl, err = NewVersion(conf.Options)
+	case "wheel":
+		a.impl = &Wheel{}
+	case "oci-labels":
+		a.impl = NewOCILabels(conf.Options)
+	case "oci-related":
+		a.impl = NewOCIRelated(conf.Options)
+	case "wasm":
+		a.impl, err = NewWASM(conf.Options)
+	default:
+		return nil, fmt.Errorf("unknown annotator type %q", conf.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s annotator: %w", conf.Type, err)
+	}
+	return a, nil
+}
+
+// Types returns the supported annotator types
+func Types() []string {
+	return []string{"version", "wheel", "oci-labels", "oci-related", "wasm"}
+}
+
+// Annotate returns the annotations of the artifact, it returns nil
+// if the artifact does not match the annotator
+func (a *Annotator) Annotate(ctx context.Context, artifact run.Artifact) (map[string]string, error) {
+	if a.match != nil && !a.match.MatchString(artifact.Path) {
+		return nil, nil
+	}
+	annotations, err := a.impl.Annotate(ctx, artifact)
+	if err != nil {
+		return nil, fmt.Errorf("running %s annotator on %s: %w", a.Type, artifact.Path, err)
+	}
+	return annotations, nil
+}
+
+// AnnotateAll runs the annotators over an artifact and merges their
+// annotations. Annotators are best effort: failures are logged and
+// do not stop the rest from running. When two annotators return the
+// same key, the last one wins.
+func AnnotateAll(ctx context.Context, annotators []*Annotator, artifact run.Artifact) map[string]string {
+	var annotations map[string]string
+	for _, a := range annotators {
+		res, err := a.Annotate(ctx, artifact)
+		if err != nil {
+			logrus.Warn(err)
+			continue
+		}
+		for k, v := range res {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
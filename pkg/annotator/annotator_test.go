@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestAnnotateAll(t *testing.T) {
+	annotators := []*Annotator{}
+	for _, conf := range []config.Annotator{
+		{Type: "version", Match: `\.tar\.gz$`},
+		{Type: "wheel"},
+	} {
+		a, err := New(conf)
+		require.NoError(t, err)
+		annotators = append(annotators, a)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		expect map[string]string
+	}{
+		{path: "bin/tejolote-v0.3.1-rc.1.tar.gz", expect: map[string]string{"version": "0.3.1-rc.1"}},
+		{path: "bin/tejolote-v0.3.1", expect: nil},
+		{path: "dist/README.txt", expect: nil},
+		{
+			path: "dist/tejolote-1.0.2-1-py3-none-any.whl",
+			expect: map[string]string{
+				"version": "1.0.2", "wheel.distribution": "tejolote", "wheel.build": "1",
+				"wheel.python": "py3", "wheel.abi": "none", "wheel.platform": "any",
+			},
+		},
+		// Malformed wheels are logged and skipped
+		{path: "dist/broken.whl", expect: nil},
+	} {
+		require.Equal(t, tc.expect, AnnotateAll(context.Background(), annotators, run.Artifact{Path: tc.path}), tc.path)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		conf     config.Annotator
+		mustFail bool
+	}{
+		{conf: config.Annotator{Type: "oci-labels"}},
+		{conf: config.Annotator{Type: "version", Options: map[string]string{"pattern": `_(\d+)\.`}}},
+		{conf: config.Annotator{Type: "version", Options: map[string]string{"pattern": `\d+`}}, mustFail: true},
+		{conf: config.Annotator{Type: "wheel", Match: "("}, mustFail: true},
+		{conf: config.Annotator{Type: "unknown"}, mustFail: true},
+	} {
+		_, err := New(tc.conf)
+		if tc.mustFail {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// defaultVersionPattern matches semantic versions with an optional
+// v prefix and prerelease/build suffixes. Only numeric identifiers are
+// matched after the first suffix identifier to avoid swallowing the
+// file extension (eg v1.0.0-rc.1.tar.gz).
+const defaultVersionPattern = `v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z]+(?:\.\d+)*)?(?:\+[0-9A-Za-z]+(?:\.\d+)*)?)`
+
+// Version extracts a version string from the artifact filename.
+// The pattern can be replaced with the "pattern" option, its first
+// capture group is the version.
+type Version struct {
+	pattern *regexp.Regexp
+}
+
+func NewVersion(options map[string]string) (*Version, error) {
+	pattern := defaultVersionPattern
+	if p, ok := options["pattern"]; ok {
+		pattern = p
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compiling version pattern: %w", err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, errors.New("version pattern has no capture group")
+	}
+	return &Version{pattern: re}, nil
+}
+
+func (v *Version) Annotate(_ context.Context, artifact run.Artifact) (map[string]string, error) {
+	m := v.pattern.FindStringSubmatch(path.Base(artifact.Path))
+	if m == nil || m[1] == "" {
+		return nil, nil
+	}
+	return map[string]string{"version": m[1]}, nil
+}
+
+// Wheel parses the metadata encoded in the filename of python
+// wheels as specified in PEP 427:
+// {distribution}-{version}(-{build tag})?-{python tag}-{abi tag}-{platform tag}.whl
+type Wheel struct{}
+
+func (w *Wheel) Annotate(_ context.Context, artifact run.Artifact) (map[string]string, error) {
+	name := path.Base(artifact.Path)
+	if !strings.HasSuffix(name, ".whl") {
+		return nil, nil
+	}
+	parts := strings.Split(strings.TrimSuffix(name, ".whl"), "-")
+	annotations := map[string]string{}
+	switch len(parts) {
+	case 5:
+	case 6:
+		annotations["wheel.build"] = parts[2]
+		parts = append(parts[:2], parts[3:]...)
+	default:
+		return nil, fmt.Errorf("malformed wheel filename %s", name)
+	}
+	annotations["wheel.distribution"] = parts[0]
+	annotations["version"] = parts[1]
+	annotations["wheel.python"] = parts[2]
+	annotations["wheel.abi"] = parts[3]
+	annotations["wheel.platform"] = parts[4]
+	return annotations, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// OCILabels reads the labels from the config of container images
+// collected from OCI registries. Label keys are prefixed with the
+// "prefix" option, by default "oci.label.".
+type OCILabels struct {
+	prefix string
+}
+
+func NewOCILabels(options map[string]string) *OCILabels {
+	prefix := "oci.label."
+	if p, ok := options["prefix"]; ok {
+		prefix = p
+	}
+	return &OCILabels{prefix: prefix}
+}
+
+func (o *OCILabels) Annotate(ctx context.Context, artifact run.Artifact) (map[string]string, error) {
+	ref, ok := strings.CutPrefix(artifact.Path, "oci://")
+	if !ok {
+		return nil, nil
+	}
+	data, err := crane.Config(
+		ref, crane.WithAuthFromKeychain(ociauth.Keychain()), crane.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fetching image config: %w", err)
+	}
+	conf := &v1.ConfigFile{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("parsing image config: %w", err)
+	}
+	annotations := map[string]string{}
+	for k, v := range conf.Config.Labels {
+		annotations[o.prefix+k] = v
+	}
+	return annotations, nil
+}
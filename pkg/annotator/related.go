@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Annotation keys of the related supply chain metadata
+const (
+	AnnotationRelatedSBOM        = "oci.related.sbom"
+	AnnotationRelatedAttestation = "oci.related.attestation"
+	AnnotationRelatedSignature   = "oci.related.signature"
+	AnnotationRelatedReferrers   = "oci.related.referrers"
+)
+
+// cosignTagSuffixes maps the suffixes of the tags cosign attaches
+// to images to the annotation linking them
+var cosignTagSuffixes = map[string]string{
+	".sbom": AnnotationRelatedSBOM,
+	".att":  AnnotationRelatedAttestation,
+	".sig":  AnnotationRelatedSignature,
+}
+
+// OCIRelated discovers the supply chain metadata already published for
+// the images collected from OCI registries and links it in the subject
+// annotations. It looks for the SBOMs, attestations and signatures
+// attached by cosign (sha256-<digest>.sbom tags) and, unless the
+// "referrers" option is "false", for the artifacts listed by the OCI
+// referrers API. The related artifacts are recorded by digest as
+// oci://repository@sha256:... references.
+type OCIRelated struct {
+	referrers bool
+}
+
+func NewOCIRelated(options map[string]string) *OCIRelated {
+	return &OCIRelated{referrers: options["referrers"] != "false"}
+}
+
+func (o *OCIRelated) Annotate(ctx context.Context, artifact run.Artifact) (map[string]string, error) {
+	refString, ok := strings.CutPrefix(artifact.Path, "oci://")
+	if !ok {
+		return nil, nil
+	}
+	ref, err := name.ParseReference(refString)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference: %w", err)
+	}
+	opts := []crane.Option{crane.WithAuthFromKeychain(ociauth.Keychain()), crane.WithContext(ctx)}
+
+	digest := ""
+	if sha, ok := artifact.Checksum["sha256"]; ok {
+		digest = "sha256:" + sha
+	} else {
+		digest, err = crane.Digest(ref.String(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("resolving image digest: %w", err)
+		}
+	}
+	repo := ref.Context()
+
+	annotations := map[string]string{}
+	tagPrefix := strings.Replace(digest, ":", "-", 1)
+	for suffix, key := range cosignTagSuffixes {
+		related, err := crane.Digest(repo.Tag(tagPrefix+suffix).String(), opts...)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("looking for %s tag: %w", suffix, err)
+		}
+		annotations[key] = fmt.Sprintf("oci://%s@%s", repo.Name(), related)
+	}
+
+	if o.referrers {
+		index, err := remote.Referrers(
+			repo.Digest(digest), remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx),
+		)
+		if err != nil && !isNotFound(err) {
+			return nil, fmt.Errorf("listing referrers: %w", err)
+		}
+		if err == nil {
+			manifest, err := index.IndexManifest()
+			if err != nil {
+				return nil, fmt.Errorf("reading referrers index: %w", err)
+			}
+			referrers := []string{}
+			for _, d := range manifest.Manifests {
+				referrers = append(referrers, fmt.Sprintf("%s=oci://%s@%s", d.ArtifactType, repo.Name(), d.Digest))
+			}
+			sort.Strings(referrers)
+			if len(referrers) > 0 {
+				annotations[AnnotationRelatedReferrers] = strings.Join(referrers, ",")
+			}
+		}
+	}
+	return annotations, nil
+}
+
+// isNotFound returns true if a registry error means the
+// manifest does not exist
+func isNotFound(err error) bool {
+	terr := &transport.Error{}
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestOCIRelated(t *testing.T) {
+	srv := httptest.NewServer(registry.New(
+		registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true),
+	))
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/app"
+
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, repo+":v1"))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	// An SBOM attached with cosign
+	sbom, err := random.Image(128, 1)
+	require.NoError(t, err)
+	sbomTag := repo + ":" + strings.Replace(digest.String(), ":", "-", 1) + ".sbom"
+	require.NoError(t, crane.Push(sbom, sbomTag))
+	sbomDigest, err := sbom.Digest()
+	require.NoError(t, err)
+
+	// An attestation pushed as a referrer
+	desc, err := partial.Descriptor(img)
+	require.NoError(t, err)
+	att, err := random.Image(128, 1)
+	require.NoError(t, err)
+	att, ok := mutate.Subject(
+		mutate.ConfigMediaType(att, "application/vnd.example.attestation"), *desc,
+	).(v1.Image)
+	require.True(t, ok)
+	require.NoError(t, crane.Push(att, repo+":referrer"))
+	attDigest, err := att.Digest()
+	require.NoError(t, err)
+
+	for _, path := range []string{"oci://" + repo + ":v1", "oci://" + repo + "@" + digest.String()} {
+		annotations, err := NewOCIRelated(nil).Annotate(context.Background(), run.Artifact{Path: path})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			AnnotationRelatedSBOM:      "oci://" + repo + "@" + sbomDigest.String(),
+			AnnotationRelatedReferrers: "application/vnd.example.attestation=oci://" + repo + "@" + attDigest.String(),
+		}, annotations, path)
+	}
+
+	// Referrers lookups can be disabled
+	annotations, err := NewOCIRelated(map[string]string{"referrers": "false"}).Annotate(
+		context.Background(), run.Artifact{Path: "oci://" + repo + ":v1"},
+	)
+	require.NoError(t, err)
+	require.NotContains(t, annotations, AnnotationRelatedReferrers)
+
+	// Other artifacts are ignored
+	annotations, err = NewOCIRelated(nil).Annotate(context.Background(), run.Artifact{Path: "file.tar.gz"})
+	require.NoError(t, err)
+	require.Nil(t, annotations)
+}
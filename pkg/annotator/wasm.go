@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/wasm"
+)
+
+// WASM runs the "annotate" entry point of a WASM plugin. The plugin
+// receives the artifact as JSON ({"path", "checksum", "annotations"})
+// and returns an object of string annotations. The module path is set
+// in the "module" option, "timeout" and "memory-pages" limit the
+// resources of each call.
+type WASM struct {
+	plugin *wasm.Plugin
+}
+
+// wasmArtifact is the artifact document passed to plugins
+type wasmArtifact struct {
+	Path        string            `json:"path"`
+	Checksum    map[string]string `json:"checksum"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func NewWASM(options map[string]string) (*WASM, error) {
+	path, ok := options["module"]
+	if !ok || path == "" {
+		return nil, errors.New("wasm annotator needs a module option")
+	}
+	opts, err := wasm.PluginOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	plugin, err := wasm.LoadPlugin(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("loading wasm plugin: %w", err)
+	}
+	return &WASM{plugin: plugin}, nil
+}
+
+func (w *WASM) Annotate(ctx context.Context, artifact run.Artifact) (map[string]string, error) {
+	annotations := map[string]string{}
+	if err := w.plugin.Call(ctx, "annotate", wasmArtifact{
+		Path:        artifact.Path,
+		Checksum:    artifact.Checksum,
+		Annotations: artifact.Annotations,
+	}, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package archivista uploads signed attestations to an Archivista
+// server, which indexes them by subject digest for later discovery.
+package archivista
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+// TokenEnv is the environment variable holding a bearer token sent to
+// Archivista servers behind an authenticating proxy
+const TokenEnv = "ARCHIVISTA_TOKEN"
+
+const uploadTimeout = 2 * time.Minute
+
+// Client uploads DSSE envelopes to an Archivista server
+type Client struct {
+	URL   string
+	Token string
+}
+
+// storeResponse is the reply of the Archivista upload endpoint
+type storeResponse struct {
+	Gitoid string `json:"gitoid"`
+}
+
+// New returns a client of the Archivista server at serverURL
+func New(serverURL string) (*Client, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing archivista url: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, errors.New("archivista url is not an http(s) url")
+	}
+	if u.Host == "" {
+		return nil, errors.New("archivista url does not specify a host")
+	}
+	return &Client{URL: serverURL, Token: os.Getenv(TokenEnv)}, nil
+}
+
+// Upload stores a DSSE envelope in Archivista and returns the gitoid
+// it is indexed under
+func (c *Client) Upload(ctx context.Context, envelope []byte) (string, error) {
+	uploadURL, err := url.JoinPath(c.URL, "upload")
+	if err != nil {
+		return "", fmt.Errorf("building upload url: %w", err)
+	}
+	if err := readonly.Check("uploading to archivista " + uploadURL); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(envelope))
+	if err != nil {
+		return "", fmt.Errorf("creating upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tejolote")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("uploading to archivista: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint: errcheck
+		return "", fmt.Errorf("archivista responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	res := storeResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("decoding archivista response: %w", err)
+	}
+	if res.Gitoid == "" {
+		return "", errors.New("archivista response has no gitoid")
+	}
+	logrus.Infof("stored attestation in archivista %s as %s", req.URL.Redacted(), res.Gitoid)
+	return res.Gitoid, nil
+}
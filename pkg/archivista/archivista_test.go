@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archivista
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpload(t *testing.T) {
+	envelope := []byte(`{"payloadType":"application/vnd.in-toto+json","payload":"e30=","signatures":[]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/archivista/upload":
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, envelope, body)
+			w.Write([]byte(`{"gitoid":"abc123"}`)) //nolint: errcheck
+		default:
+			http.Error(w, "database unavailable", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv(TokenEnv, "secret")
+	c, err := New(server.URL + "/archivista")
+	require.NoError(t, err)
+	gitoid, err := c.Upload(context.Background(), envelope)
+	require.NoError(t, err)
+	require.Equal(t, "abc123", gitoid)
+
+	c, err = New(server.URL)
+	require.NoError(t, err)
+	_, err = c.Upload(context.Background(), envelope)
+	require.ErrorContains(t, err, "database unavailable")
+
+	_, err = New("gs://bucket")
+	require.Error(t, err)
+}
@@ -17,9 +17,7 @@ limitations under the License.
 package attestation
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"strings"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
@@ -30,9 +28,27 @@ import (
 type (
 	Attestation struct {
 		intoto.StatementHeader
+		// Subject shadows the statement header subjects to
+		// support annotating them
+		Subject   []Subject     `json:"subject"`
 		Predicate SLSAPredicate `json:"predicate"`
 	}
-	SLSAPredicate slsa.ProvenancePredicate
+
+	// SLSAPredicate is the SLSA 0.2 provenance predicate extended with
+	// the completeness of the subject list and the outcome of the run
+	SLSAPredicate struct {
+		slsa.ProvenancePredicate
+		SubjectCompleteness *SubjectCompleteness `json:"subjectCompleteness,omitempty"`
+		RunOutcome          *RunOutcome          `json:"runOutcome,omitempty"`
+	}
+
+	// Subject is an in-toto subject which can carry annotations
+	// describing the artifact, following the in-toto v1 resource
+	// descriptor field of the same name
+	Subject struct {
+		intoto.Subject
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
 )
 
 func New() *Attestation {
@@ -40,8 +56,8 @@ func New() *Attestation {
 		StatementHeader: intoto.StatementHeader{
 			Type:          intoto.StatementInTotoV01,
 			PredicateType: slsa.PredicateSLSAProvenance,
-			Subject:       []intoto.Subject{},
 		},
+		Subject: []Subject{},
 	}
 	return attestation
 }
@@ -53,7 +69,7 @@ func (att *Attestation) SLSA() *Attestation {
 
 // NewSLSAPredicate returns a new SLSA predicate fully initialized
 func NewSLSAPredicate() SLSAPredicate {
-	predicate := SLSAPredicate{
+	predicate := SLSAPredicate{ProvenancePredicate: slsa.ProvenancePredicate{
 		Builder: common.ProvenanceBuilder{
 			ID: "", // TODO: Read builder from trusted environment
 		},
@@ -80,21 +96,34 @@ func NewSLSAPredicate() SLSAPredicate {
 			Reproducible: false,
 		},
 		Materials: []common.ProvenanceMaterial{},
-	}
+	}}
 
 	return predicate
 }
 
 func (att *Attestation) ToJSON() ([]byte, error) {
-	var b bytes.Buffer
-	enc := json.NewEncoder(&b)
-	enc.SetIndent("", "  ")
-	enc.SetEscapeHTML(false)
+	return encodeJSON(att)
+}
+
+// AddSubjects appends in-toto subjects to the attestation
+func (att *Attestation) AddSubjects(subjects ...intoto.Subject) {
+	for _, s := range subjects {
+		att.Subject = append(att.Subject, Subject{Subject: s})
+	}
+}
 
-	if err := enc.Encode(att); err != nil {
-		return nil, fmt.Errorf("encoding spdx sbom: %w", err)
+// ParseVCSURL splits a VCS locator (git+https://github.com/org/repo@sha)
+// into the material URI and its digest. The part after the @ is only
+// taken as the digest if it looks like a commit hash, ok is false when
+// the locator does not pin a commit.
+func ParseVCSURL(vcsURL string) (uri string, digest common.DigestSet, ok bool) {
+	digest = common.DigestSet{}
+	u, commit, found := strings.Cut(vcsURL, "@")
+	if !found || len(commit) != 40 {
+		return vcsURL, digest, false
 	}
-	return b.Bytes(), nil
+	digest["sha1"] = commit
+	return u, digest, true
 }
 
 // AddMaterial add an entry to the materials
@@ -0,0 +1,44 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVCSURL(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	for _, tc := range []struct {
+		vcsURL string
+		uri    string
+		digest common.DigestSet
+		ok     bool
+	}{
+		{"git+https://github.com/org/repo@" + commit, "git+https://github.com/org/repo", common.DigestSet{"sha1": commit}, true},
+		{"git+https://github.com/org/repo@main", "git+https://github.com/org/repo@main", common.DigestSet{}, false},
+		{"git+https://github.com/org/repo", "git+https://github.com/org/repo", common.DigestSet{}, false},
+	} {
+		uri, digest, ok := ParseVCSURL(tc.vcsURL)
+		require.Equal(t, tc.uri, uri)
+		require.Equal(t, tc.digest, digest)
+		require.Equal(t, tc.ok, ok)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Suffixes of the files written for each subject by WriteBlobAttestations
+const (
+	BlobProvenanceSuffix  = ".intoto.jsonl"
+	BlobSignatureSuffix   = ".sig"
+	BlobCertificateSuffix = ".cert"
+)
+
+// SubjectStatements splits the attestation into one statement per subject,
+// each carrying the full predicate. This is the shape cosign attest-blob
+// produces and slsa-verifier expects when verifying a single artifact.
+func (att *Attestation) SubjectStatements() []*Attestation {
+	statements := []*Attestation{}
+	for _, s := range att.Subject {
+		st := *att
+		st.Subject = []Subject{s}
+		statements = append(statements, &st)
+	}
+	return statements
+}
+
+// WriteBlobAttestations signs a statement for each subject and writes
+// the result to dir named after the subject base name:
+//
+//	NAME.intoto.jsonl  the DSSE envelope, as read by slsa-verifier
+//	NAME.sig           the same envelope, as written by cosign attest-blob
+//	NAME.cert          the signing certificate (keyless signing only)
+//
+// Subjects sharing a base name would overwrite each other so they are
+// reported as an error.
+func (att *Attestation) WriteBlobAttestations(ctx context.Context, signer StatementSigner, dir string) error {
+	if err := os.MkdirAll(dir, os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating blob attestations directory: %w", err)
+	}
+	seen := map[string]string{}
+	for _, st := range att.SubjectStatements() {
+		subject := st.Subject[0].Name
+		name := path.Base(subject)
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("subjects %s and %s have the same file name", prev, subject)
+		}
+		seen[name] = subject
+
+		// Statements are serialized compact, one per line
+		payload, err := json.Marshal(st)
+		if err != nil {
+			return fmt.Errorf("serializing statement of %s: %w", subject, err)
+		}
+		sig, err := signer.SignStatement(ctx, payload)
+		if err != nil {
+			return fmt.Errorf("signing statement of %s: %w", subject, err)
+		}
+
+		base := filepath.Join(dir, name)
+		if err := os.WriteFile(base+BlobProvenanceSuffix, append(sig.Signature, '\n'), os.FileMode(0o644)); err != nil {
+			return fmt.Errorf("writing provenance of %s: %w", subject, err)
+		}
+		if err := os.WriteFile(base+BlobSignatureSuffix, sig.Signature, os.FileMode(0o644)); err != nil {
+			return fmt.Errorf("writing signature of %s: %w", subject, err)
+		}
+		if sig.Certificate != nil {
+			if err := os.WriteFile(base+BlobCertificateSuffix, sig.Certificate, os.FileMode(0o644)); err != nil {
+				return fmt.Errorf("writing certificate of %s: %w", subject, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatementSigner returns the statement itself as the envelope
+type fakeStatementSigner struct{}
+
+func (fakeStatementSigner) SignStatement(_ context.Context, statement []byte) (*BlobSignature, error) {
+	return &BlobSignature{Signature: statement, Certificate: []byte("CERT")}, nil
+}
+
+func TestWriteBlobAttestations(t *testing.T) {
+	att := New().SLSA()
+	att.Predicate.BuildType = "https://example.com/build@v1"
+	att.AddSubjects(
+		intoto.Subject{Name: "gs://bucket/release/app.tar.gz", Digest: map[string]string{"sha256": "aa"}},
+		intoto.Subject{Name: "/tmp/out/app.sbom", Digest: map[string]string{"sha256": "bb"}},
+	)
+
+	dir := filepath.Join(t.TempDir(), "blobs")
+	require.NoError(t, att.WriteBlobAttestations(context.Background(), fakeStatementSigner{}, dir))
+
+	for name, digest := range map[string]string{"app.tar.gz": "aa", "app.sbom": "bb"} {
+		data, err := os.ReadFile(filepath.Join(dir, name+BlobProvenanceSuffix))
+		require.NoError(t, err)
+		st := Attestation{}
+		require.NoError(t, json.Unmarshal(data, &st))
+		require.Len(t, st.Subject, 1)
+		require.Equal(t, digest, st.Subject[0].Digest["sha256"])
+		require.Equal(t, "https://example.com/build@v1", st.Predicate.BuildType)
+		require.FileExists(t, filepath.Join(dir, name+BlobSignatureSuffix))
+		require.FileExists(t, filepath.Join(dir, name+BlobCertificateSuffix))
+	}
+	require.Len(t, att.Subject, 2, "original attestation modified")
+
+	// Subjects with the same base name cannot be written side by side
+	att.AddSubjects(intoto.Subject{Name: "/other/app.sbom", Digest: map[string]string{"sha256": "cc"}})
+	require.Error(t, att.WriteBlobAttestations(context.Background(), fakeStatementSigner{}, t.TempDir()))
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/rekor"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/sign"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/types"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature/dsse"
+	signatureoptions "github.com/sigstore/sigstore/pkg/signature/options"
+)
+
+// BlobSignature is a detached signature of an artifact
+type BlobSignature struct {
+	Signature   []byte               // Raw signature bytes
+	Certificate []byte               // PEM encoded signing certificate, if any
+	LogIndex    *int64               // Index of the Rekor entry recording the signature
+	LogEntry    *models.LogEntryAnon // Rekor entry recording the signature, if uploaded
+	Timestamp   []byte               // DER encoded RFC 3161 timestamp response of the signature, if any
+}
+
+// BlobSigner signs artifacts producing detached signatures
+type BlobSigner interface {
+	SignBlob(context.Context, io.ReadSeeker) (*BlobSignature, error)
+}
+
+// StatementSigner signs in-toto statements. The signature returned is
+// the DSSE envelope wrapping the statement.
+type StatementSigner interface {
+	SignStatement(context.Context, []byte) (*BlobSignature, error)
+}
+
+// SigstoreBlobSigner signs blobs keyless with the public sigstore
+// instance, recording each signature in the Rekor transparency log
+// just as cosign sign-blob does. The signer is reused for all blobs
+// to go through the identity flow only once.
+type SigstoreBlobSigner struct {
+	sv          *sign.SignerVerifier
+	rekorClient *client.Rekor
+	certificate []byte
+}
+
+// NewSigstoreBlobSigner returns a signer ready to sign blobs with
+// the Sigstore deployment in the options
+func NewSigstoreBlobSigner(ctx context.Context, opts SigstoreOptions) (*SigstoreBlobSigner, error) {
+	if err := initializeTUF(ctx, opts); err != nil {
+		return nil, err
+	}
+	ko := opts.keyOpts()
+	sv, err := sign.SignerFromKeyOpts(ctx, "", "", ko)
+	if err != nil {
+		return nil, fmt.Errorf("getting signer: %w", err)
+	}
+	rekorClient, err := rekor.NewClient(ko.RekorURL)
+	if err != nil {
+		sv.Close()
+		return nil, fmt.Errorf("creating rekor client: %w", err)
+	}
+	pemBytes, err := sv.Bytes(ctx)
+	if err != nil {
+		sv.Close()
+		return nil, fmt.Errorf("reading signer public data: %w", err)
+	}
+	// Only keep the signer data when it is a certificate
+	var certificate []byte
+	if _, err := cryptoutils.UnmarshalCertificatesFromPEM(pemBytes); err == nil {
+		certificate = pemBytes
+	}
+	return &SigstoreBlobSigner{sv: sv, rekorClient: rekorClient, certificate: certificate}, nil
+}
+
+// SignBlob signs the data read from r and uploads the signature to Rekor.
+// The blob is read twice, once to hash it and once to sign it, to avoid
+// loading large artifacts in memory.
+func (bs *SigstoreBlobSigner) SignBlob(ctx context.Context, r io.ReadSeeker) (*BlobSignature, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return nil, fmt.Errorf("hashing blob: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewinding blob: %w", err)
+	}
+	sig, err := bs.sv.SignMessage(r, signatureoptions.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("signing blob: %w", err)
+	}
+	pemBytes, err := bs.sv.Bytes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading signer public data: %w", err)
+	}
+	entry, err := cosign.TLogUpload(ctx, bs.rekorClient, sig, hasher, pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("uploading signature to the transparency log: %w", err)
+	}
+	return &BlobSignature{Signature: sig, Certificate: bs.certificate, LogIndex: entry.LogIndex, LogEntry: entry}, nil
+}
+
+// SignStatement wraps an in-toto statement in a DSSE envelope and records
+// it in Rekor as a dsse entry, just as cosign attest-blob does.
+func (bs *SigstoreBlobSigner) SignStatement(ctx context.Context, statement []byte) (*BlobSignature, error) {
+	wrapped := dsse.WrapSigner(bs.sv, types.IntotoPayloadType)
+	envelope, err := wrapped.SignMessage(bytes.NewReader(statement), signatureoptions.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("signing statement: %w", err)
+	}
+	pemBytes, err := bs.sv.Bytes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading signer public data: %w", err)
+	}
+	entry, err := cosign.TLogUploadDSSEEnvelope(ctx, bs.rekorClient, envelope, pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("uploading envelope to the transparency log: %w", err)
+	}
+	return &BlobSignature{Signature: envelope, Certificate: bs.certificate, LogIndex: entry.LogIndex, LogEntry: entry}, nil
+}
+
+// Close releases the signer resources
+func (bs *SigstoreBlobSigner) Close() {
+	bs.sv.Close()
+}
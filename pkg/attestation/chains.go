@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	ctypes "github.com/sigstore/cosign/v2/pkg/types"
+
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+// Annotations Tekton Chains sets on the objects it attested, to tell
+// Chains controllers sharing the cluster the object is signed
+const (
+	ChainsSignedAnnotation       = "chains.tekton.dev/signed"
+	ChainsTransparencyAnnotation = "chains.tekton.dev/transparency"
+)
+
+// TektonChains shapes the attestation as the slsa/v1 format of Tekton
+// Chains: container images are named after their repository, without
+// the oci:// scheme or tag, and digests use lowercase algorithm names.
+// Subjects left with the same name and digest are merged.
+func (att *Attestation) TektonChains() error {
+	if att.PredicateType != slsa.PredicateSLSAProvenance {
+		return fmt.Errorf("predicate type must be %s", slsa.PredicateSLSAProvenance)
+	}
+	subjects := []Subject{}
+	seen := map[string]bool{}
+	for _, s := range att.Subject {
+		s.Digest = lowercaseDigests(s.Digest)
+		if ref, ok := strings.CutPrefix(s.Name, "oci://"); ok {
+			parsed, err := name.ParseReference(ref)
+			if err != nil {
+				return fmt.Errorf("parsing image subject %s: %w", s.Name, err)
+			}
+			s.Name = parsed.Context().Name()
+		}
+		key := s.Name + "@" + s.Digest["sha256"]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		subjects = append(subjects, s)
+	}
+	att.Subject = subjects
+	for i := range att.Predicate.Materials {
+		att.Predicate.Materials[i].Digest = lowercaseDigests(att.Predicate.Materials[i].Digest)
+	}
+	return nil
+}
+
+// lowercaseDigests returns the digest set with lowercase algorithms
+func lowercaseDigests(ds common.DigestSet) common.DigestSet {
+	lower := common.DigestSet{}
+	for algo, val := range ds {
+		lower[strings.ToLower(algo)] = strings.ToLower(val)
+	}
+	return lower
+}
+
+// ChainsAnnotations returns the annotations Tekton Chains sets on an
+// object once its provenance is signed. The transparency annotation
+// points to the Rekor entry of the signature in the Rekor instance of
+// the options.
+func ChainsAnnotations(sig *BlobSignature, opts SigstoreOptions) map[string]string {
+	annotations := map[string]string{ChainsSignedAnnotation: "true"}
+	if sig != nil && sig.LogIndex != nil {
+		annotations[ChainsTransparencyAnnotation] = fmt.Sprintf(
+			"%s/api/v1/log/entries?logIndex=%d", strings.TrimSuffix(opts.keyOpts().RekorURL, "/"), *sig.LogIndex,
+		)
+	}
+	return annotations
+}
+
+// ImageSubjects returns the references by digest of the container
+// image subjects of a Tekton Chains formatted attestation
+func (att *Attestation) ImageSubjects() []name.Digest {
+	refs := []name.Digest{}
+	for _, s := range att.Subject {
+		sha := s.Digest["sha256"]
+		if sha == "" || !strings.Contains(s.Name, "/") {
+			continue
+		}
+		ref, err := name.NewDigest(s.Name + "@sha256:" + sha)
+		if err != nil {
+			// Not an image, Chains only stores attestations of images
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// AttachChains stores the signed attestation next to each image subject
+// as Tekton Chains' OCI storage does: a cosign attestation image tagged
+// sha256-<digest>.att in the repository of the image, carrying the
+// certificate and Rekor bundle of the signature. It returns the images
+// the attestation was attached to.
+func AttachChains(ctx context.Context, att *Attestation, sig *BlobSignature) ([]string, error) {
+	if sig == nil || len(sig.Signature) == 0 {
+		return nil, errors.New("the attestation must be signed to attach it to images")
+	}
+	opts := []static.Option{
+		static.WithLayerMediaType(ctypes.DssePayloadType),
+		static.WithAnnotations(map[string]string{"predicateType": att.PredicateType}),
+	}
+	if sig.Certificate != nil {
+		opts = append(opts, static.WithCertChain(sig.Certificate, nil))
+	}
+	if sig.LogEntry != nil {
+		opts = append(opts, static.WithBundle(bundle.EntryToBundle(sig.LogEntry)))
+	}
+	ociSig, err := static.NewAttestation(sig.Signature, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("building OCI attestation: %w", err)
+	}
+
+	remoteOpts := ociremote.WithRemoteOptions(
+		remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx),
+	)
+	attached := []string{}
+	for _, ref := range att.ImageSubjects() {
+		if err := readonly.Check("attaching attestation to " + ref.String()); err != nil {
+			return attached, err
+		}
+		se, err := mutate.AttachAttestationToEntity(ociremote.SignedUnknown(ref, remoteOpts), ociSig)
+		if err != nil {
+			return attached, fmt.Errorf("attaching attestation to %s: %w", ref, err)
+		}
+		if err := ociremote.WriteAttestations(ref.Repository, se, remoteOpts); err != nil {
+			return attached, fmt.Errorf("writing attestation of %s: %w", ref, err)
+		}
+		attached = append(attached, ref.String())
+	}
+	return attached, nil
+}
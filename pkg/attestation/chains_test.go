@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTektonChains(t *testing.T) {
+	sha := strings.Repeat("a", 64)
+	att := New().SLSA()
+	att.Subject = []Subject{
+		{Subject: intoto.Subject{Name: "oci://gcr.io/project/app:v1", Digest: common.DigestSet{"SHA256": strings.ToUpper(sha)}}},
+		{Subject: intoto.Subject{Name: "oci://gcr.io/project/app@sha256:" + sha, Digest: common.DigestSet{"sha256": sha}}},
+		{Subject: intoto.Subject{Name: "gs://bucket/app.tar.gz", Digest: common.DigestSet{"SHA512": "BB"}}},
+	}
+	att.Predicate.Materials = []common.ProvenanceMaterial{{URI: "git+https://github.com/org/repo", Digest: common.DigestSet{"SHA1": "CC"}}}
+
+	require.NoError(t, att.TektonChains())
+	require.Equal(t, []Subject{
+		{Subject: intoto.Subject{Name: "gcr.io/project/app", Digest: common.DigestSet{"sha256": sha}}},
+		{Subject: intoto.Subject{Name: "gs://bucket/app.tar.gz", Digest: common.DigestSet{"sha512": "bb"}}},
+	}, att.Subject)
+	require.Equal(t, common.DigestSet{"sha1": "cc"}, att.Predicate.Materials[0].Digest)
+
+	refs := att.ImageSubjects()
+	require.Len(t, refs, 1)
+	require.Equal(t, "gcr.io/project/app@sha256:"+sha, refs[0].String())
+
+	att.PredicateType = "https://slsa.dev/provenance/v1"
+	require.Error(t, att.TektonChains())
+}
+
+func TestChainsAnnotations(t *testing.T) {
+	require.Equal(t, map[string]string{ChainsSignedAnnotation: "true"}, ChainsAnnotations(&BlobSignature{}, SigstoreOptions{}))
+	index := int64(42)
+	require.Equal(t, map[string]string{
+		ChainsSignedAnnotation:       "true",
+		ChainsTransparencyAnnotation: "https://rekor.sigstore.dev/api/v1/log/entries?logIndex=42",
+	}, ChainsAnnotations(&BlobSignature{LogIndex: &index}, SigstoreOptions{}))
+	require.Equal(t, map[string]string{
+		ChainsSignedAnnotation:       "true",
+		ChainsTransparencyAnnotation: "https://rekor.example.com/api/v1/log/entries?logIndex=42",
+	}, ChainsAnnotations(&BlobSignature{LogIndex: &index}, SigstoreOptions{RekorURL: "https://rekor.example.com/"}))
+}
+
+func TestAttachChains(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	sha := strings.Repeat("a", 64)
+	att := New().SLSA()
+	att.Subject = []Subject{
+		{Subject: intoto.Subject{Name: host + "/app", Digest: common.DigestSet{"sha256": sha}}},
+	}
+	_, err := AttachChains(context.Background(), att, nil)
+	require.Error(t, err)
+
+	envelope := []byte(`{"payloadType":"application/vnd.in-toto+json","payload":"e30=","signatures":[]}`)
+	images, err := AttachChains(context.Background(), att, &BlobSignature{Signature: envelope})
+	require.NoError(t, err)
+	require.Equal(t, []string{host + "/app@sha256:" + sha}, images)
+
+	ref, err := name.ParseReference(host + "/app:sha256-" + sha + ".att")
+	require.NoError(t, err)
+	img, err := remote.Image(ref)
+	require.NoError(t, err)
+	manifest, err := img.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 1)
+	require.Equal(t, "application/vnd.dsse.envelope.v1+json", string(manifest.Layers[0].MediaType))
+	require.Equal(t, att.PredicateType, manifest.Layers[0].Annotations["predicateType"])
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+// CompatSLSAVerifier is the compatibility mode that shapes GitHub
+// provenance as slsa-verifier expects it
+const CompatSLSAVerifier = "slsa-verifier"
+
+// SLSAVerifierBuildType is the build type of the slsa-github-generator
+// generic generator, the provenance flavor slsa-verifier verifies for
+// artifacts built on GitHub Actions
+const SLSAVerifierBuildType = "https://github.com/slsa-framework/slsa-github-generator/generic@v1"
+
+// CompatTektonChains is the compatibility mode that shapes the
+// provenance as Tekton Chains writes it, see chains.go
+const CompatTektonChains = "tekton-chains"
+
+// CompatModes are the supported compatibility modes
+var CompatModes = []string{CompatSLSAVerifier, CompatTektonChains}
+
+var (
+	slsaVerifierBuilderID = regexp.MustCompile(`^https://github\.com/[^/]+/[^/]+/\.github/workflows/[^@]+@refs/(heads|tags)/.+$`)
+	slsaVerifierSourceURI = regexp.MustCompile(`^git\+https://github\.com/([^/]+/[^/]+)@(refs/(heads|tags)/.+)$`)
+	hexSHA1               = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	hexSHA256             = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// slsaVerifierEnvironment are the invocation environment entries
+// slsa-verifier reads to check the source and the trigger of the build
+var slsaVerifierEnvironment = []string{
+	"github_event_name", "github_ref", "github_ref_type", "github_sha1", "github_run_id", "github_run_attempt",
+}
+
+// BaseNameSubjects renames the subjects to the base name of the artifact
+// and keeps only their sha256 digest, the subject naming slsa-verifier
+// matches artifact files against.
+func (att *Attestation) BaseNameSubjects() error {
+	seen := map[string]string{}
+	for i := range att.Subject {
+		s := &att.Subject[i]
+		name := path.Base(s.Name)
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("subjects %s and %s have the same file name", prev, s.Name)
+		}
+		seen[name] = s.Name
+		sha := ""
+		for algo, val := range s.Digest {
+			if strings.EqualFold(algo, "sha256") {
+				sha = strings.ToLower(val)
+			}
+		}
+		if sha == "" {
+			return fmt.Errorf("subject %s has no sha256 digest", s.Name)
+		}
+		s.Name = name
+		s.Digest = common.DigestSet{"sha256": sha}
+	}
+	return nil
+}
+
+// CheckSLSAVerifier checks the attestation has the shape slsa-verifier
+// expects of GitHub Actions provenance: the builder is identified by its
+// workflow ref, the config source points to the built ref and commit
+// and the subjects are file names with sha256 digests.
+func (att *Attestation) CheckSLSAVerifier() error {
+	if att.PredicateType != slsa.PredicateSLSAProvenance {
+		return fmt.Errorf("predicate type must be %s", slsa.PredicateSLSAProvenance)
+	}
+	// The builder may be a reusable workflow in another repository
+	// (eg the slsa-github-generator) so it is not matched to the source
+	if !slsaVerifierBuilderID.MatchString(att.Predicate.Builder.ID) {
+		return fmt.Errorf("builder id %q is not a github.com workflow ref", att.Predicate.Builder.ID)
+	}
+	source := att.Predicate.Invocation.ConfigSource
+	uri := slsaVerifierSourceURI.FindStringSubmatch(source.URI)
+	if uri == nil {
+		return fmt.Errorf("config source uri %q is not a github.com repository ref", source.URI)
+	}
+	if !strings.HasPrefix(source.EntryPoint, ".github/workflows/") {
+		return fmt.Errorf("config source entry point %q is not a workflow file", source.EntryPoint)
+	}
+	if !hexSHA1.MatchString(source.Digest["sha1"]) {
+		return errors.New("config source has no sha1 commit digest")
+	}
+
+	env, ok := att.Predicate.Invocation.Environment.(map[string]interface{})
+	if !ok {
+		return errors.New("invocation environment is not a map of github_* values")
+	}
+	for _, key := range slsaVerifierEnvironment {
+		if v, ok := env[key].(string); !ok || v == "" {
+			return fmt.Errorf("invocation environment has no %s", key)
+		}
+	}
+	if env["github_sha1"] != source.Digest["sha1"] {
+		return errors.New("github_sha1 does not match the config source digest")
+	}
+	if env["github_ref"] != uri[2] {
+		return errors.New("github_ref does not match the config source ref")
+	}
+
+	if len(att.Subject) == 0 {
+		return errors.New("attestation has no subjects")
+	}
+	for _, s := range att.Subject {
+		if s.Name == "" || strings.Contains(s.Name, "/") {
+			return fmt.Errorf("subject name %q is not a file name", s.Name)
+		}
+		if !hexSHA256.MatchString(s.Digest["sha256"]) {
+			return fmt.Errorf("subject %s has no sha256 digest", s.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSLSAVerifier(t *testing.T) {
+	data, err := os.ReadFile("testdata/slsa-verifier/generator-generic.json")
+	require.NoError(t, err)
+
+	// Provenance produced by the slsa-github-generator passes the check
+	read := func() *Attestation {
+		att := &Attestation{}
+		require.NoError(t, json.Unmarshal(data, att))
+		return att
+	}
+	require.NoError(t, read().CheckSLSAVerifier())
+
+	for name, mutate := range map[string]func(*Attestation){
+		"builder not a workflow": func(att *Attestation) {
+			att.Predicate.Builder.ID = "https://github.com/Attestations/GitHubHostedActions@v1"
+		},
+		"source without ref": func(att *Attestation) {
+			att.Predicate.Invocation.ConfigSource.URI = "git+https://github.com/org/repo.git"
+		},
+		"short commit": func(att *Attestation) {
+			att.Predicate.Invocation.ConfigSource.Digest["sha1"] = "5e1b7a6"
+		},
+		"ref mismatch": func(att *Attestation) {
+			att.Predicate.Invocation.Environment.(map[string]interface{})["github_ref"] = "refs/heads/main"
+		},
+		"missing environment": func(att *Attestation) {
+			delete(att.Predicate.Invocation.Environment.(map[string]interface{}), "github_run_attempt")
+		},
+		"subject path": func(att *Attestation) {
+			att.Subject[0].Name = "dist/binary-linux-amd64"
+		},
+	} {
+		att := read()
+		mutate(att)
+		require.Error(t, att.CheckSLSAVerifier(), name)
+	}
+}
+
+func TestBaseNameSubjects(t *testing.T) {
+	att := New().SLSA()
+	att.AddSubjects(
+		intoto.Subject{Name: "gs://bucket/release/app.tar.gz", Digest: map[string]string{"SHA256": "AA", "MD5": "bb"}},
+		intoto.Subject{Name: "/tmp/out/app.sbom", Digest: map[string]string{"sha256": "cc"}},
+	)
+	require.NoError(t, att.BaseNameSubjects())
+	require.Equal(t, "app.tar.gz", att.Subject[0].Name)
+	require.Equal(t, map[string]string{"sha256": "aa"}, map[string]string(att.Subject[0].Digest))
+	require.Equal(t, "app.sbom", att.Subject[1].Name)
+
+	att.AddSubjects(intoto.Subject{Name: "other/app.sbom", Digest: map[string]string{"sha256": "dd"}})
+	require.Error(t, att.BaseNameSubjects())
+
+	att = New().SLSA()
+	att.AddSubjects(intoto.Subject{Name: "app", Digest: map[string]string{"sha512": "ee"}})
+	require.Error(t, att.BaseNameSubjects())
+}
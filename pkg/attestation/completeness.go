@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+// Sources of the subject digests
+const (
+	// DigestComputed digests were computed by tejolote from the
+	// artifact contents
+	DigestComputed = "computed"
+
+	// DigestReported digests were read from the storage metadata or
+	// reported by the build system without reading the artifacts
+	DigestReported = "reported"
+)
+
+// SubjectCompleteness records how the subjects of the attestation were
+// collected from each artifact store, letting verifiers judge how
+// trustworthy the subject list is
+type SubjectCompleteness struct {
+	Stores []StoreCompleteness `json:"stores"`
+}
+
+// StoreCompleteness are the collection metrics of an artifact store
+type StoreCompleteness struct {
+	// Store is the spec URL of the artifact store
+	Store string `json:"store"`
+
+	// Driver is the storage driver that read the store
+	Driver string `json:"driver"`
+
+	// Native is true for the stores of the build system
+	Native bool `json:"native,omitempty"`
+
+	// Observed is the number of artifacts listed in the store after
+	// the build. It is not recorded for indexed stores.
+	Observed *int `json:"observed,omitempty"`
+
+	// Collected is the number of artifacts recorded as subjects
+	Collected int `json:"collected"`
+
+	// DigestSource tells how the subject digests were obtained
+	// (computed or reported)
+	DigestSource string `json:"digestSource"`
+
+	// WithoutDigest is the number of subjects recorded without digests
+	WithoutDigest int `json:"withoutDigest,omitempty"`
+
+	// Skipped lists the artifacts found in the store but not recorded
+	Skipped []SkippedArtifact `json:"skipped,omitempty"`
+}
+
+// SkippedArtifact is an artifact left out of the subjects
+type SkippedArtifact struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// Predicate formats supported by Convert
+const (
+	FormatSLSA02 = "slsa-0.2"
+	FormatSLSA1  = "slsa-1.0"
+)
+
+// StatementInTotoV1 is the statement type of in-toto v1 attestations
+const StatementInTotoV1 = "https://in-toto.io/Statement/v1"
+
+// ConvertFormats are the formats attestations can be converted to
+var ConvertFormats = []string{FormatSLSA02, FormatSLSA1}
+
+// SLSA1Attestation is an in-toto statement with a SLSA 1.0 provenance
+// predicate
+type SLSA1Attestation struct {
+	intoto.StatementHeader
+	Subject   []Subject                 `json:"subject"`
+	Predicate slsa1.ProvenancePredicate `json:"predicate"`
+}
+
+// Convert reads a SLSA 0.2 or 1.0 provenance attestation, bare or
+// wrapped in a DSSE envelope, and returns it serialized in the format
+// passed. It also returns the fields that have no equivalent in the
+// target format and were dropped.
+func Convert(data []byte, to string) ([]byte, []string, error) {
+	payload, err := statementPayload(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := intoto.StatementHeader{}
+	if err := json.Unmarshal(payload, &header); err != nil {
+		return nil, nil, fmt.Errorf("parsing statement: %w", err)
+	}
+
+	var from string
+	switch header.PredicateType {
+	case slsa.PredicateSLSAProvenance:
+		from = FormatSLSA02
+	case slsa1.PredicateSLSAProvenance:
+		from = FormatSLSA1
+	default:
+		return nil, nil, fmt.Errorf("unsupported predicate type %q", header.PredicateType)
+	}
+
+	switch {
+	case from == to:
+		var out any
+		if err := json.Unmarshal(payload, &out); err != nil {
+			return nil, nil, fmt.Errorf("parsing statement: %w", err)
+		}
+		data, err := encodeJSON(out)
+		return data, nil, err
+	case to == FormatSLSA1:
+		att := New()
+		if err := json.Unmarshal(payload, att); err != nil {
+			return nil, nil, fmt.Errorf("parsing SLSA 0.2 attestation: %w", err)
+		}
+		converted, dropped, err := att.ToSLSA1()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := encodeJSON(converted)
+		return data, dropped, err
+	case to == FormatSLSA02:
+		att := &SLSA1Attestation{}
+		if err := json.Unmarshal(payload, att); err != nil {
+			return nil, nil, fmt.Errorf("parsing SLSA 1.0 attestation: %w", err)
+		}
+		converted, dropped, err := att.ToSLSA02()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := converted.ToJSON()
+		return data, dropped, err
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", to)
+	}
+}
+
+// statementPayload returns the statement in a DSSE envelope, or the
+// data unchanged if it is not an envelope
+func statementPayload(data []byte) ([]byte, error) {
+	envelope := struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+	}{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("parsing attestation: %w", err)
+	}
+	if envelope.PayloadType == "" {
+		return data, nil
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding envelope payload: %w", err)
+	}
+	return payload, nil
+}
+
+// ToSLSA1 converts the attestation to SLSA 1.0. The config source and
+// invocation parameters become the external parameters, the invocation
+// environment and build config the internal parameters and the materials
+// the resolved dependencies. The completeness and reproducible claims are
+// not part of SLSA 1.0 and are dropped.
+func (att *Attestation) ToSLSA1() (*SLSA1Attestation, []string, error) {
+	pred := att.Predicate
+	dropped := []string{}
+
+	external := map[string]any{}
+	if pred.Invocation.ConfigSource.URI != "" {
+		external["configSource"] = pred.Invocation.ConfigSource
+	}
+	if pred.Invocation.Parameters != nil {
+		external["parameters"] = pred.Invocation.Parameters
+	}
+	internal := map[string]any{}
+	if pred.Invocation.Environment != nil {
+		internal["environment"] = pred.Invocation.Environment
+	}
+	if pred.BuildConfig != nil {
+		internal["buildConfig"] = pred.BuildConfig
+	}
+
+	converted := &SLSA1Attestation{
+		StatementHeader: intoto.StatementHeader{
+			Type:          StatementInTotoV1,
+			PredicateType: slsa1.PredicateSLSAProvenance,
+		},
+		Subject: att.Subject,
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{
+				BuildType:          pred.BuildType,
+				ExternalParameters: external,
+			},
+			RunDetails: slsa1.ProvenanceRunDetails{
+				Builder: slsa1.Builder{ID: pred.Builder.ID},
+			},
+		},
+	}
+	if len(internal) > 0 {
+		converted.Predicate.BuildDefinition.InternalParameters = internal
+	}
+	for _, m := range pred.Materials {
+		converted.Predicate.BuildDefinition.ResolvedDependencies = append(
+			converted.Predicate.BuildDefinition.ResolvedDependencies,
+			slsa1.ResourceDescriptor{URI: m.URI, Digest: m.Digest},
+		)
+	}
+
+	if md := pred.Metadata; md != nil {
+		converted.Predicate.RunDetails.BuildMetadata = slsa1.BuildMetadata{
+			InvocationID: md.BuildInvocationID,
+			StartedOn:    md.BuildStartedOn,
+			FinishedOn:   md.BuildFinishedOn,
+		}
+		if md.Completeness != (slsa.ProvenanceComplete{}) {
+			dropped = append(dropped, "metadata.completeness")
+		}
+		if md.Reproducible {
+			dropped = append(dropped, "metadata.reproducible")
+		}
+	}
+	if pred.SubjectCompleteness != nil {
+		dropped = append(dropped, "subjectCompleteness")
+	}
+	if pred.RunOutcome != nil {
+		converted.Predicate.RunDetails.Byproducts = append(
+			converted.Predicate.RunDetails.Byproducts, pred.RunOutcome.byproduct(),
+		)
+	}
+	return converted, dropped, nil
+}
+
+// ToSLSA02 converts the attestation to SLSA 0.2, reversing the mapping
+// of ToSLSA1. External and internal parameters not shaped as ToSLSA1
+// writes them are recorded as the invocation parameters and the build
+// config. SLSA 0.2 has no completeness claims so all are false.
+func (att *SLSA1Attestation) ToSLSA02() (*Attestation, []string, error) {
+	def := att.Predicate.BuildDefinition
+	run := att.Predicate.RunDetails
+	dropped := []string{}
+
+	converted := New()
+	converted.Subject = att.Subject
+	converted.Predicate = SLSAPredicate{ProvenancePredicate: slsa.ProvenancePredicate{
+		Builder:   common.ProvenanceBuilder{ID: run.Builder.ID},
+		BuildType: def.BuildType,
+		Metadata: &slsa.ProvenanceMetadata{
+			BuildInvocationID: run.BuildMetadata.InvocationID,
+			BuildStartedOn:    run.BuildMetadata.StartedOn,
+			BuildFinishedOn:   run.BuildMetadata.FinishedOn,
+		},
+		Materials: []common.ProvenanceMaterial{},
+	}}
+	pred := &converted.Predicate
+
+	if external, ok := parameterFields(def.ExternalParameters, "configSource", "parameters"); ok {
+		if cs, ok := external["configSource"]; ok {
+			if err := remarshal(cs, &pred.Invocation.ConfigSource); err != nil {
+				return nil, nil, fmt.Errorf("reading config source: %w", err)
+			}
+		}
+		pred.Invocation.Parameters = external["parameters"]
+	} else {
+		pred.Invocation.Parameters = def.ExternalParameters
+	}
+	if internal, ok := parameterFields(def.InternalParameters, "environment", "buildConfig"); ok {
+		pred.Invocation.Environment = internal["environment"]
+		pred.BuildConfig = internal["buildConfig"]
+	} else {
+		pred.BuildConfig = def.InternalParameters
+	}
+
+	for i, dep := range def.ResolvedDependencies {
+		uri := dep.URI
+		if uri == "" {
+			uri = dep.DownloadLocation
+		}
+		if uri == "" {
+			dropped = append(dropped, fmt.Sprintf("buildDefinition.resolvedDependencies[%d]", i))
+			continue
+		}
+		pred.Materials = append(pred.Materials, common.ProvenanceMaterial{URI: uri, Digest: dep.Digest})
+		if dep.Name != "" || dep.MediaType != "" || len(dep.Content) > 0 || len(dep.Annotations) > 0 ||
+			(dep.URI != "" && dep.DownloadLocation != "") {
+			dropped = append(dropped, fmt.Sprintf("buildDefinition.resolvedDependencies[%d] descriptor fields", i))
+		}
+	}
+	if len(run.Builder.Version) > 0 {
+		dropped = append(dropped, "runDetails.builder.version")
+	}
+	if len(run.Builder.BuilderDependencies) > 0 {
+		dropped = append(dropped, "runDetails.builder.builderDependencies")
+	}
+	for i, bp := range run.Byproducts {
+		if outcome, ok := outcomeFromByproduct(bp); ok && pred.RunOutcome == nil {
+			pred.RunOutcome = outcome
+			continue
+		}
+		dropped = append(dropped, fmt.Sprintf("runDetails.byproducts[%d]", i))
+	}
+	return converted, dropped, nil
+}
+
+// parameterFields returns the parameters as a map if they are an object
+// with no keys other than the ones passed
+func parameterFields(params any, keys ...string) (map[string]any, bool) {
+	m := map[string]any{}
+	if err := remarshal(params, &m); err != nil {
+		return nil, false
+	}
+	for k := range m {
+		if !slices.Contains(keys, k) {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// remarshal copies a value into another type through its JSON encoding
+func remarshal(in, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// encodeJSON serializes a value as indented JSON without HTML escaping
+func encodeJSON(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("encoding attestation: %w", err)
+	}
+	return b.Bytes(), nil
+}
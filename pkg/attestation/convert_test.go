@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	started := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	att := testAttestation(map[string]string{"gs://bucket/a": "1"}, "git+https://github.com/org/repo")
+	att.Predicate.Builder.ID = "https://example.com/builder"
+	att.Predicate.Invocation.ConfigSource.Digest = map[string]string{"sha1": "abc"}
+	att.Predicate.Invocation.ConfigSource.EntryPoint = "cloudbuild.yaml"
+	att.Predicate.Invocation.Parameters = map[string]any{"_VERSION": "v1.0.0"}
+	att.Predicate.Invocation.Environment = map[string]any{"runner": "linux"}
+	att.Predicate.Metadata.BuildInvocationID = "1234"
+	att.Predicate.Metadata.BuildStartedOn = &started
+	att.Predicate.Metadata.Completeness.Parameters = false
+	att.Predicate.RunOutcome = &RunOutcome{Status: "failure"}
+	att.Subject[0].Annotations = map[string]string{"version": "v1.0.0"}
+	data, err := att.ToJSON()
+	require.NoError(t, err)
+
+	v1, dropped, err := Convert(data, FormatSLSA1)
+	require.NoError(t, err)
+	require.Empty(t, dropped)
+	converted := &SLSA1Attestation{}
+	require.NoError(t, json.Unmarshal(v1, converted))
+	require.Equal(t, StatementInTotoV1, converted.Type)
+	require.Equal(t, "https://slsa.dev/provenance/v1", converted.PredicateType)
+	require.Equal(t, "https://example.com/builder", converted.Predicate.RunDetails.Builder.ID)
+	require.Equal(t, "1234", converted.Predicate.RunDetails.BuildMetadata.InvocationID)
+	require.Len(t, converted.Predicate.BuildDefinition.ResolvedDependencies, 1)
+	require.Len(t, converted.Predicate.RunDetails.Byproducts, 1)
+	require.Equal(t, OutcomeByproduct, converted.Predicate.RunDetails.Byproducts[0].Name)
+	require.Equal(t, att.Subject, converted.Subject)
+
+	// Converting back restores the original predicate
+	v02, dropped, err := Convert(v1, FormatSLSA02)
+	require.NoError(t, err)
+	require.Empty(t, dropped)
+	require.JSONEq(t, string(data), string(v02))
+
+	// Envelopes are unwrapped and claims without equivalent reported
+	att.Predicate.Metadata.Reproducible = true
+	data, err = att.ToJSON()
+	require.NoError(t, err)
+	envelope, err := json.Marshal(map[string]string{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString(data),
+	})
+	require.NoError(t, err)
+	_, dropped, err = Convert(envelope, FormatSLSA1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"metadata.reproducible"}, dropped)
+
+	// Parameters of other builders are kept as a whole
+	converted.Predicate.BuildDefinition.ExternalParameters = map[string]any{"workflow": "release.yaml"}
+	converted.Predicate.RunDetails.Byproducts = converted.Predicate.BuildDefinition.ResolvedDependencies
+	back, dropped, err := converted.ToSLSA02()
+	require.NoError(t, err)
+	require.Equal(t, []string{"runDetails.byproducts[0]"}, dropped)
+	require.Nil(t, back.Predicate.RunOutcome)
+	require.Equal(t, map[string]any{"workflow": "release.yaml"}, back.Predicate.Invocation.Parameters)
+	require.Empty(t, back.Predicate.Invocation.ConfigSource.URI)
+
+	_, _, err = Convert([]byte(`{"predicateType": "https://spdx.dev/Document"}`), FormatSLSA1)
+	require.Error(t, err)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Merge combines several partial attestations of the same build into a
+// single statement. Subjects are de-duplicated by name and materials are
+// unioned by URI. The attestations must describe the same invocation:
+// if the builder, build type, config source or parameters differ, Merge
+// returns an error.
+func Merge(atts ...*Attestation) (*Attestation, error) {
+	if len(atts) == 0 {
+		return nil, errors.New("no attestations to merge")
+	}
+	merged := New().SLSA()
+	subjects := map[string]int{}
+	materials := map[string]int{}
+	for i, att := range atts {
+		if att.PredicateType != "" && att.PredicateType != slsa.PredicateSLSAProvenance {
+			return nil, fmt.Errorf("attestation #%d has unsupported predicate type %s", i, att.PredicateType)
+		}
+		if err := merged.Predicate.mergeInvocation(&att.Predicate); err != nil {
+			return nil, fmt.Errorf("merging attestation #%d: %w", i, err)
+		}
+
+		for _, s := range att.Subject {
+			j, ok := subjects[s.Name]
+			if !ok {
+				subjects[s.Name] = len(merged.Subject)
+				merged.Subject = append(merged.Subject, s)
+				continue
+			}
+			if err := mergeDigests(merged.Subject[j].Digest, s.Digest); err != nil {
+				return nil, fmt.Errorf("subject %s in attestation #%d: %w", s.Name, i, err)
+			}
+			for k, v := range s.Annotations {
+				if merged.Subject[j].Annotations == nil {
+					merged.Subject[j].Annotations = map[string]string{}
+				}
+				merged.Subject[j].Annotations[k] = v
+			}
+		}
+
+		for _, m := range att.Predicate.Materials {
+			j, ok := materials[m.URI]
+			if !ok {
+				materials[m.URI] = len(merged.Predicate.Materials)
+				digest := common.DigestSet{}
+				for k, v := range m.Digest {
+					digest[k] = v
+				}
+				merged.Predicate.Materials = append(merged.Predicate.Materials, common.ProvenanceMaterial{
+					URI: m.URI, Digest: digest,
+				})
+				continue
+			}
+			if err := mergeDigests(merged.Predicate.Materials[j].Digest, m.Digest); err != nil {
+				return nil, fmt.Errorf("material %s in attestation #%d: %w", m.URI, i, err)
+			}
+		}
+	}
+
+	// The merged statement records the collection of every part
+	for _, att := range atts {
+		if att.Predicate.SubjectCompleteness == nil {
+			continue
+		}
+		if merged.Predicate.SubjectCompleteness == nil {
+			merged.Predicate.SubjectCompleteness = &SubjectCompleteness{}
+		}
+		merged.Predicate.SubjectCompleteness.Stores = append(
+			merged.Predicate.SubjectCompleteness.Stores, att.Predicate.SubjectCompleteness.Stores...,
+		)
+	}
+
+	// The merged statement records the first outcome other than
+	// success, so a failed part is never hidden by the others
+	for _, att := range atts {
+		if att.Predicate.RunOutcome == nil {
+			continue
+		}
+		if merged.Predicate.RunOutcome == nil || merged.Predicate.RunOutcome.Status == run.OutcomeSuccess {
+			merged.Predicate.RunOutcome = &RunOutcome{Status: att.Predicate.RunOutcome.Status}
+		}
+	}
+
+	// The merged statement only claims what all the parts claim
+	merged.Predicate.Metadata.Completeness = slsa.ProvenanceComplete{Parameters: true, Environment: true, Materials: true}
+	merged.Predicate.Metadata.Reproducible = true
+	for _, att := range atts {
+		md := att.Predicate.Metadata
+		if md == nil {
+			md = &slsa.ProvenanceMetadata{}
+		}
+		c := &merged.Predicate.Metadata.Completeness
+		c.Parameters = c.Parameters && md.Completeness.Parameters
+		c.Environment = c.Environment && md.Completeness.Environment
+		c.Materials = c.Materials && md.Completeness.Materials
+		merged.Predicate.Metadata.Reproducible = merged.Predicate.Metadata.Reproducible && md.Reproducible
+	}
+	return merged, nil
+}
+
+// mergeDigests adds the hashes in src to dst, failing if an
+// algorithm has different values in both sets
+func mergeDigests(dst, src common.DigestSet) error {
+	for algo, val := range src {
+		if v, ok := dst[algo]; ok && v != val {
+			return fmt.Errorf("conflicting %s digests %s and %s", algo, v, val)
+		}
+		dst[algo] = val
+	}
+	return nil
+}
+
+// mergeInvocation merges the build data of other into the predicate.
+// Empty fields are filled in from other, fields set in both
+// predicates must match.
+func (pred *SLSAPredicate) mergeInvocation(other *SLSAPredicate) error {
+	if err := mergeString(&pred.Builder.ID, other.Builder.ID, "builder id"); err != nil {
+		return err
+	}
+	if err := mergeString(&pred.BuildType, other.BuildType, "build type"); err != nil {
+		return err
+	}
+	cs, ocs := &pred.Invocation.ConfigSource, &other.Invocation.ConfigSource
+	if err := mergeString(&cs.URI, ocs.URI, "config source uri"); err != nil {
+		return err
+	}
+	if err := mergeString(&cs.EntryPoint, ocs.EntryPoint, "config source entry point"); err != nil {
+		return err
+	}
+	if cs.Digest == nil {
+		cs.Digest = common.DigestSet{}
+	}
+	if err := mergeDigests(cs.Digest, ocs.Digest); err != nil {
+		return fmt.Errorf("config source: %w", err)
+	}
+	if err := mergeValue(&pred.Invocation.Parameters, other.Invocation.Parameters, "invocation parameters"); err != nil {
+		return err
+	}
+	if err := mergeValue(&pred.Invocation.Environment, other.Invocation.Environment, "invocation environment"); err != nil {
+		return err
+	}
+	if err := mergeValue(&pred.BuildConfig, other.BuildConfig, "build config"); err != nil {
+		return err
+	}
+
+	if other.Metadata == nil {
+		return nil
+	}
+	if pred.Metadata == nil {
+		pred.Metadata = &slsa.ProvenanceMetadata{}
+	}
+	md, omd := pred.Metadata, other.Metadata
+	if err := mergeString(&md.BuildInvocationID, omd.BuildInvocationID, "build invocation id"); err != nil {
+		return err
+	}
+	if omd.BuildStartedOn != nil && (md.BuildStartedOn == nil || omd.BuildStartedOn.Before(*md.BuildStartedOn)) {
+		md.BuildStartedOn = omd.BuildStartedOn
+	}
+	if omd.BuildFinishedOn != nil && (md.BuildFinishedOn == nil || omd.BuildFinishedOn.After(*md.BuildFinishedOn)) {
+		md.BuildFinishedOn = omd.BuildFinishedOn
+	}
+	return nil
+}
+
+func mergeString(dst *string, src, field string) error {
+	if src == "" {
+		return nil
+	}
+	if *dst != "" && *dst != src {
+		return fmt.Errorf("mismatched %s: %q and %q", field, *dst, src)
+	}
+	*dst = src
+	return nil
+}
+
+// mergeValue compares values of free form fields through their
+// JSON representation as they may have been decoded from files
+func mergeValue(dst *interface{}, src interface{}, field string) error {
+	if src == nil {
+		return nil
+	}
+	if *dst == nil {
+		*dst = src
+		return nil
+	}
+	a, err := json.Marshal(*dst)
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", field, err)
+	}
+	b, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", field, err)
+	}
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		return fmt.Errorf("normalizing %s: %w", field, err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return fmt.Errorf("normalizing %s: %w", field, err)
+	}
+	if !reflect.DeepEqual(av, bv) {
+		return fmt.Errorf("mismatched %s", field)
+	}
+	return nil
+}
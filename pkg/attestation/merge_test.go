@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+)
+
+func testAttestation(subjects map[string]string, materials ...string) *Attestation {
+	att := New().SLSA()
+	att.Predicate.BuildType = "https://example.com/build@v1"
+	att.Predicate.Invocation.ConfigSource.URI = "git+https://github.com/org/repo"
+	for name, sha := range subjects {
+		att.AddSubjects(intoto.Subject{Name: name, Digest: common.DigestSet{"sha256": sha}})
+	}
+	for _, m := range materials {
+		att.Predicate.AddMaterial(m, common.DigestSet{"sha1": "abc"})
+	}
+	return att
+}
+
+func TestMerge(t *testing.T) {
+	early := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	att1 := testAttestation(map[string]string{"a": "1", "b": "2"}, "git+https://github.com/org/repo")
+	att1.Predicate.Metadata.BuildStartedOn = &late
+	att2 := testAttestation(map[string]string{"b": "2", "c": "3"}, "git+https://github.com/org/repo", "pkg:golang/x@v1")
+	att2.Predicate.Metadata.BuildStartedOn = &early
+	att2.Predicate.Builder.ID = "https://example.com/builder"
+
+	merged, err := Merge(att1, att2)
+	require.NoError(t, err)
+	require.Len(t, merged.Subject, 3)
+	require.Len(t, merged.Predicate.Materials, 2)
+	require.Equal(t, "https://example.com/builder", merged.Predicate.Builder.ID)
+	require.Equal(t, early, *merged.Predicate.Metadata.BuildStartedOn)
+	require.Nil(t, merged.Predicate.RunOutcome)
+
+	// A failed part marks the merged run as failed
+	att1.Predicate.RunOutcome = &RunOutcome{Status: "success"}
+	att2.Predicate.RunOutcome = &RunOutcome{Status: "failure"}
+	merged, err = Merge(att1, att2)
+	require.NoError(t, err)
+	require.Equal(t, "failure", merged.Predicate.RunOutcome.Status)
+
+	// Conflicting subject digests
+	_, err = Merge(att1, testAttestation(map[string]string{"a": "9"}))
+	require.Error(t, err)
+
+	// Mismatched invocations
+	other := testAttestation(map[string]string{"d": "4"})
+	other.Predicate.Invocation.ConfigSource.URI = "git+https://github.com/org/other"
+	_, err = Merge(att1, other)
+	require.Error(t, err)
+
+	other = testAttestation(map[string]string{"d": "4"})
+	other.Predicate.Invocation.Parameters = map[string]string{"arch": "arm64"}
+	att3 := testAttestation(map[string]string{"e": "5"})
+	att3.Predicate.Invocation.Parameters = map[string]string{"arch": "amd64"}
+	_, err = Merge(other, att3)
+	require.Error(t, err)
+
+	_, err = Merge()
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// OutcomeByproduct is the name of the SLSA 1.0 byproduct recording the
+// outcome of the run
+const OutcomeByproduct = "runOutcome"
+
+// RunOutcome records how the attested run ended. Attestations of runs
+// that failed or were cancelled let verifiers and policy engines keep
+// provenance of every run, not only the successful ones.
+type RunOutcome struct {
+	// Status is the outcome reported by the build system: success,
+	// failure, cancelled or timeout
+	Status string `json:"status"`
+}
+
+// byproduct returns the outcome as a SLSA 1.0 byproduct
+func (o *RunOutcome) byproduct() slsa1.ResourceDescriptor {
+	return slsa1.ResourceDescriptor{
+		Name:        OutcomeByproduct,
+		Annotations: map[string]interface{}{"status": o.Status},
+	}
+}
+
+// outcomeFromByproduct reads the outcome from a SLSA 1.0 byproduct
+func outcomeFromByproduct(rd slsa1.ResourceDescriptor) (*RunOutcome, bool) {
+	if rd.Name != OutcomeByproduct {
+		return nil, false
+	}
+	status, ok := rd.Annotations["status"].(string)
+	if !ok || status == "" {
+		return nil, false
+	}
+	return &RunOutcome{Status: status}, true
+}
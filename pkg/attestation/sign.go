@@ -18,47 +18,131 @@ package attestation
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/sigstore/cosign/v2/cmd/cosign/cli/options"
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/rekor"
 	"github.com/sigstore/cosign/v2/cmd/cosign/cli/sign"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/signature/dsse"
 	signatureoptions "github.com/sigstore/sigstore/pkg/signature/options"
 	"github.com/sigstore/sigstore/pkg/tuf"
 )
 
-func (att *Attestation) Sign() ([]byte, error) {
-	var certPath, certChainPath string
+// SigstoreOptions point signing to a Sigstore deployment other than
+// the public good instance. Empty fields keep the public defaults.
+type SigstoreOptions struct {
+	FulcioURL    string
+	RekorURL     string
+	OIDCIssuer   string
+	OIDCClientID string
+	TUFMirror    string // URL of the TUF repository distributing the trust root
+	TUFRoot      string // Path to the initial root.json of the TUF repository
+}
 
-	ctx := context.Background()
-	var timeout time.Duration // TODO: move to options
-	if timeout != 0 {
-		var cancelFn context.CancelFunc
-		ctx, cancelFn = context.WithTimeout(ctx, timeout)
-		defer cancelFn()
+// Validate checks the options can be used to sign. A TUF mirror
+// other than the public one needs its initial root.
+func (opts SigstoreOptions) Validate() error {
+	if opts.TUFMirror != "" && opts.TUFRoot == "" {
+		return errors.New("a custom TUF mirror needs its initial root.json")
 	}
+	if opts.TUFRoot != "" {
+		if _, err := os.Stat(opts.TUFRoot); err != nil {
+			return fmt.Errorf("reading TUF root: %w", err)
+		}
+	}
+	return nil
+}
 
-	// Initialize the TUF cache to ensure we have the
-	// latests root, otherwise proof of inclusion may fail.
-	if err := tuf.Initialize(ctx, tuf.DefaultRemoteRoot, nil); err != nil {
-		return nil, fmt.Errorf("initializing TUF client: %w", err)
+// tufMu guards tufMirror
+var tufMu sync.Mutex
+
+// tufMirror is the mirror the TUF client was initialized with. The
+// sigstore TUF client is a process wide singleton, so once initialized
+// it cannot be pointed to another mirror.
+var tufMirror string
+
+// initializeTUF initializes the TUF cache to ensure we have the
+// latest root, otherwise proof of inclusion may fail
+func initializeTUF(ctx context.Context, opts SigstoreOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
 	}
+	mirror := cmp.Or(opts.TUFMirror, tuf.DefaultRemoteRoot)
 
-	ko := options.KeyOpts{
+	tufMu.Lock()
+	defer tufMu.Unlock()
+	if tufMirror != "" && tufMirror != mirror {
+		return fmt.Errorf("TUF client already initialized with %s, cannot switch to %s", tufMirror, mirror)
+	}
+
+	var root []byte
+	if opts.TUFRoot != "" {
+		var err error
+		if root, err = os.ReadFile(opts.TUFRoot); err != nil {
+			return fmt.Errorf("reading TUF root: %w", err)
+		}
+	}
+	if err := tuf.Initialize(ctx, mirror, root); err != nil {
+		return fmt.Errorf("initializing TUF client: %w", err)
+	}
+	tufMirror = mirror
+	return nil
+}
+
+// keyOpts returns the options to sign keyless with the public
+// sigstore instance or the one set in the options
+func (opts SigstoreOptions) keyOpts() options.KeyOpts {
+	return options.KeyOpts{
 		// KeyRef:     s.options.PrivateKeyPath,
 		// IDToken:    identityToken,
-		FulcioURL:    options.DefaultFulcioURL,
-		RekorURL:     options.DefaultRekorURL,
-		OIDCIssuer:   options.DefaultOIDCIssuerURL,
-		OIDCClientID: "sigstore",
+		FulcioURL:    cmp.Or(opts.FulcioURL, options.DefaultFulcioURL),
+		RekorURL:     cmp.Or(opts.RekorURL, options.DefaultRekorURL),
+		OIDCIssuer:   cmp.Or(opts.OIDCIssuer, options.DefaultOIDCIssuerURL),
+		OIDCClientID: cmp.Or(opts.OIDCClientID, "sigstore"),
 
 		InsecureSkipFulcioVerify: false,
 		SkipConfirmation:         true,
 		// FulcioAuthFlow:           "", //nolint: gocritic
 	}
+}
+
+// Sign signs the attestation and prints the resulting DSSE envelope
+func (att *Attestation) Sign(ctx context.Context, opts SigstoreOptions) ([]byte, error) {
+	sig, err := att.SignEnvelope(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println(string(sig.Signature))
+	return sig.Signature, nil
+}
+
+// SignEnvelope wraps the attestation in a DSSE envelope signed keyless
+// and records it in the Rekor transparency log. The signature returned
+// carries the envelope, the signing certificate and the log index.
+// The signature is issued by the Sigstore deployment in the options.
+func (att *Attestation) SignEnvelope(ctx context.Context, opts SigstoreOptions) (*BlobSignature, error) {
+	var certPath, certChainPath string
+
+	var timeout time.Duration // TODO: move to options
+	if timeout != 0 {
+		var cancelFn context.CancelFunc
+		ctx, cancelFn = context.WithTimeout(ctx, timeout)
+		defer cancelFn()
+	}
+
+	if err := initializeTUF(ctx, opts); err != nil {
+		return nil, err
+	}
 
+	ko := opts.keyOpts()
 	sv, err := sign.SignerFromKeyOpts(ctx, certPath, certChainPath, ko)
 	if err != nil {
 		return nil, fmt.Errorf("getting signer: %w", err)
@@ -80,28 +164,40 @@ func (att *Attestation) Sign() ([]byte, error) {
 		return nil, fmt.Errorf("signing attestation: %w", err)
 	}
 
-	fmt.Println(string(signedPayload))
-	return signedPayload, nil
+	pemBytes, err := sv.Bytes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading signer public data: %w", err)
+	}
+	rekorClient, err := rekor.NewClient(ko.RekorURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating rekor client: %w", err)
+	}
+	entry, err := cosign.TLogUploadDSSEEnvelope(ctx, rekorClient, signedPayload, pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("uploading attestation to the transparency log: %w", err)
+	}
 
-	// TODO: review this
-	//nolint: gocritic
-	/*
-		opts := []static.Option{static.WithLayerMediaType(types.DssePayloadType)}
-		if sv.Cert != nil {
-			opts = append(opts, static.WithCertChain(sv.Cert, sv.Chain))
-		}
-	*/
-	// Should we upload?
-	/*
-		// Check whether we should be uploading to the transparency log
-		if sign.ShouldUploadToTlog(ctx, digest, force, noTlogUpload, ko.RekorURL) {
-			bundle, err := uploadToTlog(ctx, sv, ko.RekorURL, func(r *client.Rekor, b []byte) (*models.LogEntryAnon, error) {
-				return cosign.TLogUploadInTotoAttestation(ctx, r, signedPayload, b)
-			})
-			if err != nil {
-				return err
-			}
-			opts = append(opts, static.WithBundle(bundle))
-		}
-	*/
+	sig := &BlobSignature{Signature: signedPayload, LogIndex: entry.LogIndex, LogEntry: entry}
+	if _, err := cryptoutils.UnmarshalCertificatesFromPEM(pemBytes); err == nil {
+		sig.Certificate = pemBytes
+	}
+	return sig, nil
+}
+
+// SigningIdentity returns the identity a PEM encoded Fulcio certificate
+// was issued to, its first subject alternative name (an email address
+// or, for workload identities, a URI).
+func SigningIdentity(certificate []byte) (string, error) {
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM(certificate)
+	if err != nil {
+		return "", fmt.Errorf("parsing certificate: %w", err)
+	}
+	if len(certs) == 0 {
+		return "", errors.New("no certificate found")
+	}
+	sans := cryptoutils.GetSubjectAlternateNames(certs[0])
+	if len(sans) == 0 {
+		return "", errors.New("certificate has no subject alternative names")
+	}
+	return sans[0], nil
 }
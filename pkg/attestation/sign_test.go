@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/cosign/v2/cmd/cosign/cli/options"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigstoreOptions(t *testing.T) {
+	ko := SigstoreOptions{}.keyOpts()
+	require.Equal(t, options.DefaultFulcioURL, ko.FulcioURL)
+	require.Equal(t, options.DefaultRekorURL, ko.RekorURL)
+
+	require.Error(t, SigstoreOptions{TUFMirror: "https://tuf.example.com"}.Validate())
+	require.Error(t, SigstoreOptions{TUFMirror: "https://tuf.example.com", TUFRoot: "missing.json"}.Validate())
+
+	root := filepath.Join(t.TempDir(), "root.json")
+	require.NoError(t, os.WriteFile(root, []byte(`{"signed":{}}`), 0o600))
+	opts := SigstoreOptions{
+		FulcioURL:  "https://fulcio.example.com",
+		RekorURL:   "https://rekor.example.com",
+		OIDCIssuer: "https://dex.example.com",
+		TUFMirror:  "https://tuf.example.com",
+		TUFRoot:    root,
+	}
+	require.NoError(t, opts.Validate())
+	ko = opts.keyOpts()
+	require.Equal(t, "https://fulcio.example.com", ko.FulcioURL)
+	require.Equal(t, "https://rekor.example.com", ko.RekorURL)
+	require.Equal(t, "https://dex.example.com", ko.OIDCIssuer)
+	require.Equal(t, "sigstore", ko.OIDCClientID)
+}
+
+func TestInitializeTUFMirror(t *testing.T) {
+	defer func() { tufMirror = "" }()
+	tufMirror = "https://tuf.example.com"
+
+	root := filepath.Join(t.TempDir(), "root.json")
+	require.NoError(t, os.WriteFile(root, []byte(`{"signed":{}}`), 0o600))
+	err := initializeTUF(context.Background(), SigstoreOptions{TUFMirror: "https://tuf.example.org", TUFRoot: root})
+	require.ErrorContains(t, err, "already initialized")
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"text/template"
+)
+
+// unsafeFileChars are replaced in the subject names used in file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// SplitFile are the fields available to the templates naming the files
+// of split attestations
+type SplitFile struct {
+	Index   int    // Position of the subject in the attestation
+	Name    string // Base name of the subject, safe to use in paths
+	Subject string // Full name of the subject
+	SHA256  string // sha256 digest of the subject
+}
+
+// Split returns an attestation for each subject. All of them share the
+// predicate of the original attestation.
+func (att *Attestation) Split() []*Attestation {
+	atts := make([]*Attestation, 0, len(att.Subject))
+	for _, s := range att.Subject {
+		atts = append(atts, &Attestation{
+			StatementHeader: att.StatementHeader,
+			Subject:         []Subject{s},
+			Predicate:       att.Predicate,
+		})
+	}
+	return atts
+}
+
+// SplitPaths renders the file name template for each subject, in the
+// order Split returns their attestations. It fails if two subjects get
+// the same path.
+func (att *Attestation) SplitPaths(tmpl string) ([]string, error) {
+	t, err := template.New("split").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing file name template: %w", err)
+	}
+	paths := make([]string, 0, len(att.Subject))
+	seen := map[string]string{}
+	for i, s := range att.Subject {
+		var b bytes.Buffer
+		if err := t.Execute(&b, SplitFile{
+			Index:   i,
+			Name:    unsafeFileChars.ReplaceAllString(path.Base(s.Name), "_"),
+			Subject: s.Name,
+			SHA256:  s.Digest["sha256"],
+		}); err != nil {
+			return nil, fmt.Errorf("rendering file name of %s: %w", s.Name, err)
+		}
+		p := b.String()
+		if prev, ok := seen[p]; ok {
+			return nil, fmt.Errorf("subjects %s and %s are both written to %s", prev, s.Name, p)
+		}
+		seen[p] = s.Name
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
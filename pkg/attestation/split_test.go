@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	att := testAttestation(nil, "git+https://github.com/org/repo")
+	att.AddSubjects(
+		intoto.Subject{Name: "gs://bucket/bin/app-linux", Digest: common.DigestSet{"sha256": "111"}},
+		intoto.Subject{Name: "oci://registry.example.com/app@sha256:222", Digest: common.DigestSet{"sha256": "222"}},
+	)
+
+	parts := att.Split()
+	require.Len(t, parts, 2)
+	for i, part := range parts {
+		require.Equal(t, []Subject{att.Subject[i]}, part.Subject)
+		require.Equal(t, att.Predicate.Materials, part.Predicate.Materials)
+		require.Equal(t, att.PredicateType, part.PredicateType)
+	}
+
+	paths, err := att.SplitPaths("out/{{.Index}}-{{.Name}}.intoto.json")
+	require.NoError(t, err)
+	require.Equal(t, []string{"out/0-app-linux.intoto.json", "out/1-app_sha256_222.intoto.json"}, paths)
+
+	paths, err = att.SplitPaths("{{.SHA256}}.json")
+	require.NoError(t, err)
+	require.Equal(t, []string{"111.json", "222.json"}, paths)
+
+	_, err = att.SplitPaths("provenance.json")
+	require.Error(t, err)
+	_, err = att.SplitPaths("{{.Nope}}")
+	require.Error(t, err)
+}
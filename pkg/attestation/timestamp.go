@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/timestamp"
+)
+
+const timestampTimeout = 30 * time.Second
+
+// maxTimestampResponse caps the size of the responses read from
+// timestamp authorities
+const maxTimestampResponse = 1024 * 1024
+
+// Timestamp requests an RFC 3161 timestamp of the data from the
+// timestamp authority at serverURL (eg the sigstore TSA endpoint
+// https://timestamp.example.com/api/v1/timestamp). It returns the DER
+// encoded response after checking it is signed and covers the data.
+func Timestamp(ctx context.Context, serverURL string, data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	tsq, err := timestamp.CreateRequest(bytes.NewReader(data), &timestamp.RequestOptions{
+		Hash:         crypto.SHA256,
+		Certificates: true,
+		Nonce:        nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating timestamp request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timestampTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL, bytes.NewReader(tsq))
+	if err != nil {
+		return nil, fmt.Errorf("creating timestamp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	req.Header.Set("User-Agent", "tejolote")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting timestamp: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTimestampResponse))
+	if err != nil {
+		return nil, fmt.Errorf("reading timestamp response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("timestamp authority responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	ts, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing timestamp response: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	if !bytes.Equal(ts.HashedMessage, digest[:]) {
+		return nil, fmt.Errorf("timestamp covers %x instead of the signature digest", ts.HashedMessage)
+	}
+	if ts.Nonce == nil || ts.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp response nonce does not match the request")
+	}
+	return body, nil
+}
@@ -17,9 +17,13 @@ limitations under the License.
 package builder
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"io"
 
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	"github.com/sirupsen/logrus"
 
 	"sigs.k8s.io/tejolote/pkg/attestation"
@@ -30,7 +34,9 @@ import (
 
 type Builder struct {
 	SpecURL string
-	VCSURL  string
+	// VCSURLs locate the repositories checked out by the run, the
+	// first one is the main project source
+	VCSURLs []string
 	driver  driver.BuildSystem
 }
 
@@ -54,37 +60,28 @@ func (b *Builder) Snap() error {
 	return nil
 }
 
-func (b *Builder) GetRun(identifier string) (*run.Run, error) {
-	return b.driver.GetRun(identifier)
+func (b *Builder) GetRun(ctx context.Context, identifier string) (*run.Run, error) {
+	return b.driver.GetRun(ctx, identifier)
 }
 
 // RefreshRun refreshes a run with the latest data from
 // the build system
-func (b *Builder) RefreshRun(r *run.Run) error {
-	return b.driver.RefreshRun(r)
+func (b *Builder) RefreshRun(ctx context.Context, r *run.Run) error {
+	return b.driver.RefreshRun(ctx, r)
 }
 
-func (b *Builder) BuildPredicate(r *run.Run, draft *attestation.SLSAPredicate) (*attestation.SLSAPredicate, error) {
-	pred, err := b.driver.BuildPredicate(r, draft)
+func (b *Builder) BuildPredicate(ctx context.Context, r *run.Run, draft *attestation.SLSAPredicate) (*attestation.SLSAPredicate, error) {
+	pred, err := b.driver.BuildPredicate(ctx, r, draft)
 	if err != nil {
 		return nil, err
 	}
-	// If there is a VCS URL set, add it to the predicate
-	if b.VCSURL != "" {
-		commithash := map[string]string{}
-		u, commit, ok := strings.Cut(b.VCSURL, "@")
-		if ok {
-			// The thing after the @ may not be a commit
-			if len(commit) == 40 {
-				commithash["sha1"] = commit
-			} else {
-				u = b.VCSURL
-			}
-			pred.AddMaterial(u, commithash)
-		} else {
-			logrus.Warn("unable to read commit from vcs url")
-			pred.AddMaterial(u, commithash)
+	// Add a material for each repository in the VCS URLs
+	for _, vcsURL := range b.VCSURLs {
+		u, commithash, ok := attestation.ParseVCSURL(vcsURL)
+		if !ok {
+			logrus.Warnf("unable to read commit from vcs url %s", vcsURL)
 		}
+		pred.AddMaterial(u, commithash)
 	}
 	return pred, nil
 }
@@ -92,3 +89,127 @@ func (b *Builder) BuildPredicate(r *run.Run, draft *attestation.SLSAPredicate) (
 func (b *Builder) ArtifactStores() []store.Store {
 	return b.driver.ArtifactStores()
 }
+
+// DeclaredArtifactStores returns the spec URLs of the artifact locations
+// declared in the run definition, if the build system supports it
+func (b *Builder) DeclaredArtifactStores(r *run.Run) []string {
+	d, ok := b.driver.(driver.StoreDiscoverer)
+	if !ok {
+		return []string{}
+	}
+	return d.DeclaredArtifactStores(r)
+}
+
+// ArtifactReport returns the artifacts the build system reports the run
+// pushed outside its native store and the scopes the report covers
+func (b *Builder) ArtifactReport(r *run.Run) (reported, scopes []string) {
+	ar, ok := b.driver.(driver.ArtifactReporter)
+	if !ok {
+		return []string{}, []string{}
+	}
+	return ar.ArtifactReport(r)
+}
+
+// WaitTransition follows the logs of a run until it changes phase. It
+// returns driver.ErrStreamingUnavailable if the build system does not
+// support streaming its logs.
+func (b *Builder) WaitTransition(ctx context.Context, r *run.Run) error {
+	s, ok := b.driver.(driver.RunStreamer)
+	if !ok {
+		return driver.ErrStreamingUnavailable
+	}
+	return s.WaitTransition(ctx, r)
+}
+
+// Completeness returns what the driver records completely of the run
+// and if the build is reproducible
+func (b *Builder) Completeness(r *run.Run) (slsa.ProvenanceComplete, bool) {
+	cr, ok := b.driver.(driver.CompletenessReporter)
+	if !ok {
+		return slsa.ProvenanceComplete{}, false
+	}
+	return cr.Completeness(r)
+}
+
+// ErrLogsUnavailable is returned when the build system driver cannot
+// read the logs of its runs
+var ErrLogsUnavailable = errors.New("build system logs cannot be read")
+
+// RunLogs lists the logs written by a run. It returns
+// ErrLogsUnavailable if the build system does not support reading them.
+func (b *Builder) RunLogs(ctx context.Context, r *run.Run) ([]driver.RunLog, error) {
+	lr, ok := b.driver.(driver.LogReader)
+	if !ok {
+		return nil, ErrLogsUnavailable
+	}
+	return lr.RunLogs(ctx, r)
+}
+
+// ReadLog copies the contents of a run log to w
+func (b *Builder) ReadLog(ctx context.Context, l driver.RunLog, w io.Writer) error {
+	lr, ok := b.driver.(driver.LogReader)
+	if !ok {
+		return ErrLogsUnavailable
+	}
+	return lr.ReadLog(ctx, l, w)
+}
+
+// FormatCompat shapes the attestation of a run for a third-party
+// verifier, if the build system supports it. The Tekton Chains format
+// does not depend on the build system.
+func (b *Builder) FormatCompat(ctx context.Context, mode string, r *run.Run, att *attestation.Attestation) error {
+	if mode == attestation.CompatTektonChains {
+		return att.TektonChains()
+	}
+	f, ok := b.driver.(driver.CompatFormatter)
+	if !ok {
+		return fmt.Errorf("build system driver does not support the %s compatibility mode", mode)
+	}
+	return f.FormatCompat(ctx, mode, r, att)
+}
+
+// DecodeRun restores a run from its captured JSON form. The build
+// system data is decoded by the driver, which must support it.
+func (b *Builder) DecodeRun(data []byte) (*run.Run, error) {
+	captured := struct {
+		run.Run
+		SystemData json.RawMessage
+	}{}
+	if err := json.Unmarshal(data, &captured); err != nil {
+		return nil, fmt.Errorf("unmarshaling run data: %w", err)
+	}
+	d, ok := b.driver.(driver.RunDecoder)
+	if !ok {
+		return nil, errors.New("build system driver does not support decoding captured runs")
+	}
+	r := captured.Run
+	if r.SpecURL == "" {
+		r.SpecURL = b.SpecURL
+	}
+	sd, err := d.DecodeSystemData(r.SpecURL, captured.SystemData)
+	if err != nil {
+		return nil, fmt.Errorf("decoding build system data: %w", err)
+	}
+	r.SystemData = sd
+	return &r, nil
+}
+
+// AnnotateRun sets annotations on the object running the build. It
+// returns false if the build system does not support annotations.
+func (b *Builder) AnnotateRun(ctx context.Context, r *run.Run, annotations map[string]string) (bool, error) {
+	a, ok := b.driver.(driver.RunAnnotator)
+	if !ok {
+		return false, nil
+	}
+	return true, a.AnnotateRun(ctx, r, annotations)
+}
+
+// ReleaseURL returns the spec URL of the release published from the
+// tag the run built, if the build system can tell it
+func (b *Builder) ReleaseURL(r *run.Run) (string, bool) {
+	l, ok := b.driver.(driver.ReleaseLocator)
+	if !ok {
+		return "", false
+	}
+	return l.ReleaseURL(r)
+}
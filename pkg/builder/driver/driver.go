@@ -17,9 +17,14 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+
 	"sigs.k8s.io/tejolote/pkg/attestation"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store"
@@ -29,13 +34,114 @@ const (
 	GITHUB = "github"
 )
 
-// BuildSystemDriver is an interface to a type that can query a buildsystem
-// for data required to build a provenance attestation
+// BuildSystem is the interface of the drivers that query a build system
+// for the data required to build a provenance attestation. GetRun reads
+// the run identified by a spec URL, RefreshRun updates it while it runs
+// and BuildPredicate completes the draft predicate with the run data.
+// Drivers can implement the optional interfaces below to support more
+// features.
 type BuildSystem interface {
-	GetRun(string) (*run.Run, error)
-	RefreshRun(*run.Run) error
-	BuildPredicate(*run.Run, *attestation.SLSAPredicate) (*attestation.SLSAPredicate, error)
+	GetRun(context.Context, string) (*run.Run, error)
+	RefreshRun(context.Context, *run.Run) error
+	BuildPredicate(context.Context, *run.Run, *attestation.SLSAPredicate) (*attestation.SLSAPredicate, error)
 	ArtifactStores() []store.Store
+	Capabilities() Capabilities
+}
+
+// StoreDiscoverer is implemented by build system drivers that can read
+// the artifact locations declared in the definition of a run. It returns
+// the spec URLs of the artifact stores.
+type StoreDiscoverer interface {
+	DeclaredArtifactStores(*run.Run) []string
+}
+
+// ReleaseLocator is implemented by build system drivers that can tell
+// the release created from the tag a run built. It returns the release
+// spec URL (github://owner/repo/tag or gitlab://host/project/-/releases/tag)
+// and false if the run did not build a tag.
+type ReleaseLocator interface {
+	ReleaseURL(*run.Run) (string, bool)
+}
+
+// ArtifactReporter is implemented by build system drivers that know which
+// artifacts a run pushed beyond those listed by their native artifact
+// store. It returns the paths of the artifacts and the scopes (path
+// prefixes) the build system report is authoritative for: artifacts
+// observed in a scope that the build system did not report were
+// uploaded from outside the build system.
+type ArtifactReporter interface {
+	ArtifactReport(*run.Run) (reported, scopes []string)
+}
+
+// RunDecoder is implemented by build system drivers that can restore a
+// run from data captured in a previous observation. It returns the build
+// system data of the run decoded from its JSON form. Once a run is
+// decoded, the driver does not query the build system APIs, data it
+// would fetch to enrich the predicate is left out.
+type RunDecoder interface {
+	DecodeSystemData(specURL string, data []byte) (interface{}, error)
+}
+
+// ErrStreamingUnavailable is returned by a RunStreamer when the logs of
+// a run cannot be followed
+var ErrStreamingUnavailable = errors.New("build logs cannot be streamed")
+
+// RunStreamer is implemented by build system drivers that can follow the
+// logs of a run to detect when it changes phase. WaitTransition blocks
+// until the logs show a step started or finished or the run completed,
+// or until the context is done.
+type RunStreamer interface {
+	WaitTransition(ctx context.Context, r *run.Run) error
+}
+
+// RunLog is a log written by a run, stored by the build system at URI
+type RunLog struct {
+	Name string
+	URI  string
+}
+
+// LogReader is implemented by build system drivers that can read the
+// logs written by a run. RunLogs lists the logs of the run and ReadLog
+// copies the contents of one of them to w.
+type LogReader interface {
+	RunLogs(ctx context.Context, r *run.Run) ([]RunLog, error)
+	ReadLog(ctx context.Context, l RunLog, w io.Writer) error
+}
+
+// CompletenessReporter is implemented by build system drivers that know
+// how much of a run they record in the predicate. It returns whether the
+// invocation parameters, the environment and the materials recorded are
+// complete and whether the build is reproducible. Drivers not reporting
+// it are assumed to record none of them completely.
+type CompletenessReporter interface {
+	Completeness(r *run.Run) (complete slsa.ProvenanceComplete, reproducible bool)
+}
+
+// CompatFormatter is implemented by build system drivers that can shape
+// the attestation of a run as expected by third-party verifiers. The mode
+// names the verifier (see attestation.CompatModes).
+type CompatFormatter interface {
+	FormatCompat(ctx context.Context, mode string, r *run.Run, att *attestation.Attestation) error
+}
+
+// RunAnnotator is implemented by build system drivers whose runs are
+// objects that can carry annotations, like Kubernetes Jobs
+type RunAnnotator interface {
+	AnnotateRun(ctx context.Context, r *run.Run, annotations map[string]string) error
+}
+
+// Capabilities describes the features supported by a build system driver
+type Capabilities struct {
+	// NativeArtifacts is true when the build system has its own
+	// artifact storage which is read automatically.
+	NativeArtifacts bool `json:"native_artifacts"`
+
+	// LiveStatus is true when the run status can be polled while
+	// the build is running.
+	LiveStatus bool `json:"live_status"`
+
+	// Streaming is true when the driver can stream the build logs.
+	Streaming bool `json:"streaming"`
 }
 
 func NewFromSpecURL(specURL string) (BuildSystem, error) {
@@ -53,6 +159,14 @@ func NewFromSpecURL(specURL string) (BuildSystem, error) {
 		}
 	case GITHUB:
 		driver = &GitHubWorkflow{}
+	case GITLAB:
+		driver = &GitLabPipeline{}
+	case WOODPECKER:
+		driver = &WoodpeckerPipeline{}
+	case TEAMCITY:
+		driver = &TeamCityBuild{}
+	case K8S:
+		driver = &KubernetesJob{}
 	default:
 		return nil, fmt.Errorf("unable to get driver from url %s", specURL)
 	}
@@ -66,8 +180,29 @@ func NewFromMoniker(moniker string) (BuildSystem, error) {
 		driver = &GCB{}
 	case GITHUB:
 		driver = &GitHubWorkflow{}
+	case GITLAB:
+		driver = &GitLabPipeline{}
+	case WOODPECKER:
+		driver = &WoodpeckerPipeline{}
+	case TEAMCITY:
+		driver = &TeamCityBuild{}
+	case K8S:
+		driver = &KubernetesJob{}
 	default:
 		return nil, fmt.Errorf("unable to get driver from moniker %s", moniker)
 	}
 	return driver, nil
 }
+
+// Schemes returns the URL schemes supported by the build system
+// drivers and the capabilities of each driver
+func Schemes() map[string]Capabilities {
+	return map[string]Capabilities{
+		"gcb":      (&GCB{}).Capabilities(),
+		GITHUB:     (&GitHubWorkflow{}).Capabilities(),
+		GITLAB:     (&GitLabPipeline{}).Capabilities(),
+		WOODPECKER: (&WoodpeckerPipeline{}).Capabilities(),
+		TEAMCITY:   (&TeamCityBuild{}).Capabilities(),
+		K8S:        (&KubernetesJob{}).Capabilities(),
+	}
+}
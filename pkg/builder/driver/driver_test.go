@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemes checks that every scheme listed has a driver and that the
+// driver reports the capabilities listed
+func TestSchemes(t *testing.T) {
+	specs := map[string]string{
+		"gcb":      "gcb://puerco-chainguard/5dda8a10-abff-4c32-b003-758eea81ac83",
+		GITHUB:     "github://puerco/tejolote/2969514606",
+		GITLAB:     "gitlab://gitlab.example.com/group/project/pipelines/42",
+		WOODPECKER: "woodpecker://ci.example.com/org/repo/42",
+		TEAMCITY:   "teamcity://teamcity.example.com/12345",
+		K8S:        "k8s://builds/release-42",
+	}
+	schemes := Schemes()
+	require.Len(t, schemes, len(specs))
+	for scheme, caps := range schemes {
+		t.Run(scheme, func(t *testing.T) {
+			spec, ok := specs[scheme]
+			require.True(t, ok, "no spec URL to test scheme %s", scheme)
+			d, err := NewFromSpecURL(spec)
+			require.NoError(t, err)
+			require.Equal(t, caps, d.Capabilities())
+
+			d, err = NewFromMoniker(scheme)
+			require.NoError(t, err)
+			require.Equal(t, caps, d.Capabilities())
+		})
+	}
+
+	_, err := NewFromSpecURL("jenkins://ci.example.com/job/1")
+	require.Error(t, err)
+}
@@ -25,15 +25,21 @@ package driver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/cloudbuild/v1"
 
 	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/gcp"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store"
 )
@@ -41,6 +47,13 @@ import (
 type GCB struct {
 	ProjectID string
 	BuildID   string
+
+	// offline is set when the run was decoded from captured
+	// data, the driver does not query the cloud build API then
+	offline bool
+
+	// log is the position in the build log when following it
+	log *gcbLog
 }
 
 func NewGCB(specURL string) (*GCB, error) {
@@ -55,7 +68,7 @@ func NewGCB(specURL string) (*GCB, error) {
 	}, nil
 }
 
-func (gcb *GCB) GetRun(specURL string) (*run.Run, error) {
+func (gcb *GCB) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
 	r := &run.Run{
 		SpecURL:   specURL,
 		IsSuccess: false,
@@ -64,7 +77,7 @@ func (gcb *GCB) GetRun(specURL string) (*run.Run, error) {
 		StartTime: time.Time{},
 		EndTime:   time.Time{},
 	}
-	if err := gcb.RefreshRun(r); err != nil {
+	if err := gcb.RefreshRun(ctx, r); err != nil {
 		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
 	}
 	return r, nil
@@ -85,24 +98,36 @@ func parseGCBURL(gcbURL string) (host, path string, err error) {
 	if err != nil {
 		return "", "", fmt.Errorf("parsing GCB spec URL: %w", err)
 	}
-	return u.Hostname(), strings.TrimPrefix(u.Path, "/"), nil
+	if u.Scheme != "gcb" {
+		return "", "", errors.New("spec url is not a gcb url")
+	}
+	build := strings.Trim(u.Path, "/")
+	if u.Hostname() == "" || build == "" || strings.Contains(build, "/") {
+		return "", "", errors.New("gcb spec url must be gcb://project/buildID")
+	}
+	return u.Hostname(), build, nil
 }
 
 // RefreshRun queries the API from the build system and
 // updates the run metadata.
-func (gcb *GCB) RefreshRun(r *run.Run) error {
+func (gcb *GCB) RefreshRun(ctx context.Context, r *run.Run) error {
 	project, buildID, err := parseGCBURL(r.SpecURL)
 	if err != nil {
 		return fmt.Errorf("parsing GCB spec URL: %w", err)
 	}
 
-	ctx := context.Background()
-	cloudbuildService, err := cloudbuild.NewService(ctx)
+	cloudbuildService, err := gcp.NewCloudBuildService(ctx, project)
 	if err != nil {
 		return fmt.Errorf("creating cloudbuild client: %w", err)
 	}
-	build, err := cloudbuildService.Projects.Builds.Get(project, buildID).Do()
+	build, err := cloudbuildService.Projects.Builds.Get(project, buildID).Context(ctx).Do()
 	if err != nil {
+		if nErr := notFoundFromGoogleAPI(err); nErr != nil {
+			return nErr
+		}
+		if rErr := retryAfterFromGoogleAPI(err); rErr != nil {
+			return rErr
+		}
 		return fmt.Errorf("getting build %s from GCB: %w", buildID, err)
 	}
 	logrus.Debugf("%+v", build)
@@ -122,6 +147,7 @@ func (gcb *GCB) RefreshRun(r *run.Run) error {
 		//
 		r.Steps[i].Image = s.Name
 		r.Steps[i].Params = s.Args
+		r.Steps[i].Environment = stepEnvironment(build, s)
 		if s.Timing != nil {
 			if s.Timing.StartTime == "" {
 				stime, err := time.Parse(time.RFC3339Nano, s.Timing.StartTime)
@@ -171,6 +197,12 @@ func (gcb *GCB) RefreshRun(r *run.Run) error {
 		r.IsSuccess = false
 		r.IsRunning = false
 	}
+	switch build.Status {
+	case "TIMEOUT", "EXPIRED":
+		r.Outcome = run.OutcomeTimeout
+	case "CANCELLED":
+		r.Outcome = run.OutcomeCancelled
+	}
 
 	r.SystemData = build
 
@@ -179,7 +211,7 @@ func (gcb *GCB) RefreshRun(r *run.Run) error {
 
 // BuildPredicate returns a SLSA predicate populated with the GCB
 // run data as recommended by the SLSA 0.2 spec
-func (gcb *GCB) BuildPredicate(r *run.Run, draft *attestation.SLSAPredicate) (predicate *attestation.SLSAPredicate, err error) {
+func (gcb *GCB) BuildPredicate(ctx context.Context, r *run.Run, draft *attestation.SLSAPredicate) (predicate *attestation.SLSAPredicate, err error) {
 	type stepData struct {
 		Image     string   `json:"image"`
 		Arguments []string `json:"arguments"`
@@ -229,8 +261,10 @@ func (gcb *GCB) BuildPredicate(r *run.Run, draft *attestation.SLSAPredicate) (pr
 		}
 
 		// Check if we can extract the original repository from the trigger
-		if build.BuildTriggerId != "" {
-			repo, err := gcb.TriggerDetails(build.BuildTriggerId)
+		if build.BuildTriggerId != "" && gcb.offline {
+			logrus.Warn("replaying captured run, not reading the repository from the build trigger")
+		} else if build.BuildTriggerId != "" {
+			repo, err := gcb.TriggerDetails(ctx, build.BuildTriggerId)
 			if err == nil {
 				predicate.Invocation.ConfigSource.URI = repo
 			} else {
@@ -244,13 +278,46 @@ func (gcb *GCB) BuildPredicate(r *run.Run, draft *attestation.SLSAPredicate) (pr
 	return predicate, nil
 }
 
+// Completeness reports the build parameters as complete when the build
+// has no user-defined substitutions, which are not recorded. The step
+// environments and the images pulled by the steps are not recorded.
+func (gcb *GCB) Completeness(r *run.Run) (complete slsa.ProvenanceComplete, reproducible bool) {
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok {
+		return complete, false
+	}
+	complete.Parameters = true
+	for k := range build.Substitutions {
+		if strings.HasPrefix(k, "_") {
+			complete.Parameters = false
+		}
+	}
+	return complete, false
+}
+
+// DecodeSystemData decodes the cloud build data of a captured run
+func (gcb *GCB) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	project, buildID, err := parseGCBURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing gcb url: %w", err)
+	}
+	build := &cloudbuild.Build{}
+	if err := json.Unmarshal(data, build); err != nil {
+		return nil, fmt.Errorf("unmarshaling build data: %w", err)
+	}
+	gcb.ProjectID = project
+	gcb.BuildID = buildID
+	gcb.offline = true
+	return build, nil
+}
+
 // TriggerDetails
-func (gcb *GCB) TriggerDetails(triggerID string) (repoURL string, err error) {
-	cloudbuildService, err := cloudbuild.NewService(context.Background())
+func (gcb *GCB) TriggerDetails(ctx context.Context, triggerID string) (repoURL string, err error) {
+	cloudbuildService, err := gcp.NewCloudBuildService(ctx, gcb.ProjectID)
 	if err != nil {
 		return repoURL, fmt.Errorf("creating cloudbuild client: %w", err)
 	}
-	trigger, err := cloudbuildService.Projects.Triggers.Get(gcb.ProjectID, triggerID).Do()
+	trigger, err := cloudbuildService.Projects.Triggers.Get(gcb.ProjectID, triggerID).Context(ctx).Do()
 	if err != nil {
 		return repoURL, fmt.Errorf("getting trigger %s from GCB: %w", triggerID, err)
 	}
@@ -275,3 +342,141 @@ func (gcb *GCB) ArtifactStores() []store.Store {
 	}
 	return []store.Store{d}
 }
+
+// ReleaseURL returns the GitHub release of the tag built by builds
+// triggered from a tag in a GitHub repository connected to GCB
+func (gcb *GCB) ReleaseURL(r *run.Run) (string, bool) {
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok || build.Substitutions == nil {
+		return "", false
+	}
+	tag := build.Substitutions["TAG_NAME"]
+	repo := build.Substitutions["REPO_FULL_NAME"]
+	if tag == "" || repo == "" {
+		return "", false
+	}
+	return fmt.Sprintf("github://%s/%s", repo, tag), true
+}
+
+// DeclaredArtifactStores returns the locations where the build config
+// declares it pushes artifacts: the container images listed in images
+// and artifacts.images, and the bucket location of artifacts.objects.
+func (gcb *GCB) DeclaredArtifactStores(r *run.Run) []string {
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok {
+		return []string{}
+	}
+	stores := []string{}
+	seen := map[string]struct{}{}
+	add := func(spec string) {
+		if _, ok := seen[spec]; ok {
+			return
+		}
+		seen[spec] = struct{}{}
+		stores = append(stores, spec)
+	}
+
+	images := append([]string{}, build.Images...)
+	if build.Artifacts != nil {
+		images = append(images, build.Artifacts.Images...)
+	}
+	for _, image := range images {
+		image = expandSubstitutions(build, image)
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			logrus.Warnf("not watching declared image %s: %v", image, err)
+			continue
+		}
+		add("oci://" + ref.Context().Name())
+	}
+
+	if build.Artifacts != nil && build.Artifacts.Objects != nil && build.Artifacts.Objects.Location != "" {
+		location := expandSubstitutions(build, build.Artifacts.Objects.Location)
+		if strings.HasPrefix(location, "gs://") && !strings.Contains(location, "$") {
+			add(location)
+		} else {
+			logrus.Warnf("not watching declared artifacts location %s", location)
+		}
+	}
+	return stores
+}
+
+// ArtifactReport returns the images GCB reports the build pushed. The
+// objects uploaded from artifacts.objects are listed by the native store
+// from the artifact manifest, the report covers the declared location.
+func (gcb *GCB) ArtifactReport(r *run.Run) (reported, scopes []string) {
+	reported, scopes = []string{}, []string{}
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok {
+		return reported, scopes
+	}
+	if build.Results != nil {
+		for _, image := range build.Results.Images {
+			ref, err := name.ParseReference(image.Name)
+			if err != nil {
+				logrus.Warnf("unable to parse built image %s: %v", image.Name, err)
+				continue
+			}
+			if tag, ok := ref.(name.Tag); ok {
+				reported = append(reported, "oci://"+tag.Context().Name()+":"+tag.TagStr())
+			}
+			if image.Digest != "" {
+				reported = append(reported, "oci://"+ref.Context().Name()+"@"+image.Digest)
+			}
+		}
+	}
+	for _, spec := range gcb.DeclaredArtifactStores(r) {
+		if strings.HasPrefix(spec, "oci://") {
+			// Match the tags and digests of the repository only
+			scopes = append(scopes, spec+":", spec+"@")
+			continue
+		}
+		if !strings.HasSuffix(spec, "/") {
+			spec += "/"
+		}
+		scopes = append(scopes, spec)
+	}
+	return reported, scopes
+}
+
+// expandSubstitutions replaces the build substitutions in a string
+// from the build config. Unknown variables are left untouched.
+func expandSubstitutions(build *cloudbuild.Build, s string) string {
+	return os.Expand(s, func(key string) string {
+		switch key {
+		case "PROJECT_ID":
+			return build.ProjectId
+		case "BUILD_ID":
+			return build.Id
+		}
+		if v, ok := build.Substitutions[key]; ok {
+			return v
+		}
+		return "${" + key + "}"
+	})
+}
+
+// Capabilities returns the features supported by the driver
+func (gcb *GCB) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: true,
+		LiveStatus:      true,
+		Streaming:       true,
+	}
+}
+
+// stepEnvironment returns the environment variables defined for a build
+// step, including the ones set for all steps in the build options
+func stepEnvironment(build *cloudbuild.Build, step *cloudbuild.BuildStep) map[string]string {
+	env := map[string]string{}
+	vars := step.Env
+	if build.Options != nil {
+		vars = append(append([]string{}, build.Options.Env...), step.Env...)
+	}
+	for _, v := range vars {
+		if k, val, ok := strings.Cut(v, "="); ok {
+			env[k] = val
+		}
+	}
+	return env
+}
@@ -17,15 +17,171 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/cloudbuild/v1"
+
+	"sigs.k8s.io/tejolote/pkg/run"
 )
 
 func TestReadStep(t *testing.T) {
 	gcb := GCB{}
 
-	r, err := gcb.GetRun("")
+	r, err := gcb.GetRun(context.Background(), "")
 	require.Error(t, err)
 	require.Nil(t, r)
 }
+
+func TestDeclaredArtifactStores(t *testing.T) {
+	gcb := GCB{}
+	r := &run.Run{SystemData: &cloudbuild.Build{
+		Id:            "1234",
+		ProjectId:     "my-project",
+		Substitutions: map[string]string{"_TAG": "v1.0.0"},
+		Images: []string{
+			"gcr.io/$PROJECT_ID/app:${_TAG}",
+			"gcr.io/my-project/app:latest",
+			"gcr.io/$PROJECT_ID/other@sha256:" + strings.Repeat("a", 64),
+			"gcr.io/${_UNKNOWN}/bad",
+		},
+		Artifacts: &cloudbuild.Artifacts{
+			Images:  []string{"us-docker.pkg.dev/my-project/repo/tool"},
+			Objects: &cloudbuild.ArtifactObjects{Location: "gs://my-bucket/$BUILD_ID/", Paths: []string{"bin/*"}},
+		},
+	}}
+	require.Equal(t, []string{
+		"oci://gcr.io/my-project/app",
+		"oci://gcr.io/my-project/other",
+		"oci://us-docker.pkg.dev/my-project/repo/tool",
+		"gs://my-bucket/1234/",
+	}, gcb.DeclaredArtifactStores(r))
+
+	require.Empty(t, gcb.DeclaredArtifactStores(&run.Run{}))
+}
+
+func FuzzParseGCBURL(f *testing.F) {
+	for _, seed := range []string{
+		"gcb://kubernetes-release-test/3190d867-f2e5-4969-aafd-0117b6c8ed12",
+		"gcb://project",
+		"gcb:///build",
+		"gcb://%zz/build",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		project, build, err := parseGCBURL(spec)
+		if err != nil {
+			return
+		}
+		if project == "" || build == "" || strings.Contains(build, "/") {
+			t.Errorf("invalid project %q or build %q parsed from %q", project, build, spec)
+		}
+	})
+}
+
+func TestStepEnvironment(t *testing.T) {
+	build := &cloudbuild.Build{Options: &cloudbuild.BuildOptions{Env: []string{"SOURCE_DATE_EPOCH=1700000000", "GOFLAGS=-trimpath"}}}
+	env := stepEnvironment(build, &cloudbuild.BuildStep{Env: []string{"GOFLAGS=-mod=vendor", "INVALID"}})
+	require.Equal(t, map[string]string{"SOURCE_DATE_EPOCH": "1700000000", "GOFLAGS": "-mod=vendor"}, env)
+	require.Empty(t, stepEnvironment(&cloudbuild.Build{}, &cloudbuild.BuildStep{}))
+}
+
+func TestArtifactReport(t *testing.T) {
+	gcb := GCB{}
+	r := &run.Run{SystemData: &cloudbuild.Build{
+		Id:        "1234",
+		ProjectId: "my-project",
+		Images:    []string{"gcr.io/my-project/app:v1"},
+		Artifacts: &cloudbuild.Artifacts{
+			Objects: &cloudbuild.ArtifactObjects{Location: "gs://my-bucket/$BUILD_ID", Paths: []string{"bin/*"}},
+		},
+		Results: &cloudbuild.Results{Images: []*cloudbuild.BuiltImage{
+			{Name: "gcr.io/my-project/app:v1", Digest: "sha256:" + strings.Repeat("a", 64)},
+		}},
+	}}
+	reported, scopes := gcb.ArtifactReport(r)
+	require.Equal(t, []string{
+		"oci://gcr.io/my-project/app:v1",
+		"oci://gcr.io/my-project/app@sha256:" + strings.Repeat("a", 64),
+	}, reported)
+	require.Equal(t, []string{"oci://gcr.io/my-project/app:", "oci://gcr.io/my-project/app@", "gs://my-bucket/1234/"}, scopes)
+}
+
+func TestWaitTransition(t *testing.T) {
+	defer func(d time.Duration) { gcbLogInterval = d }(gcbLogInterval)
+	gcbLogInterval = time.Millisecond
+
+	// The log grows in chunks that split lines
+	chunks := []string{
+		"starting build \"1234\"\n\nFETCHSOURCE\nStart", "ing Step #0\nStep #0: building\n",
+		"", "Step #0: done\nFinished Step #0\nPUSH\nDO", "NE\n",
+	}
+	var gotObject string
+	read := 0
+	gcb := &GCB{log: &gcbLog{}}
+	gcb.log.read = func(_ context.Context, bucket, object string, offset int64) ([]byte, error) {
+		gotObject = bucket + "/" + object
+		if read >= len(chunks) {
+			return nil, nil
+		}
+		read++
+		return []byte(chunks[read-1]), nil
+	}
+	r := &run.Run{SystemData: &cloudbuild.Build{Id: "1234", LogsBucket: "gs://logs-bucket/builds"}}
+
+	ctx := context.Background()
+	require.NoError(t, gcb.WaitTransition(ctx, r))
+	require.Equal(t, 2, read, "returned before reading the step start")
+	require.Equal(t, "logs-bucket/builds/log-1234.txt", gotObject)
+	require.NoError(t, gcb.WaitTransition(ctx, r))
+	require.Equal(t, 4, read)
+	require.NoError(t, gcb.WaitTransition(ctx, r))
+	require.Equal(t, 5, read, "build completion not detected")
+	require.Equal(t, int64(len(strings.Join(chunks, ""))), gcb.log.offset)
+
+	// Without new lines it waits for the context
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, gcb.WaitTransition(tctx, r), context.DeadlineExceeded)
+
+	// Logs not written to a bucket cannot be followed
+	for _, build := range []*cloudbuild.Build{
+		{Id: "1234"},
+		{Id: "1234", LogsBucket: "gs://logs", Options: &cloudbuild.BuildOptions{Logging: "CLOUD_LOGGING_ONLY"}},
+	} {
+		require.ErrorIs(t, (&GCB{}).WaitTransition(ctx, &run.Run{SystemData: build}), ErrStreamingUnavailable)
+	}
+}
+
+func TestGCBRunLogs(t *testing.T) {
+	gcb := &GCB{}
+	build := &cloudbuild.Build{Id: "1234", LogsBucket: "gs://logs-bucket/builds"}
+	logs, err := gcb.RunLogs(context.Background(), &run.Run{SystemData: build})
+	require.NoError(t, err)
+	require.Equal(t, []RunLog{{Name: "build", URI: "gs://logs-bucket/builds/log-1234.txt"}}, logs)
+
+	build.Options = &cloudbuild.BuildOptions{Logging: "CLOUD_LOGGING_ONLY"}
+	logs, err = gcb.RunLogs(context.Background(), &run.Run{SystemData: build})
+	require.NoError(t, err)
+	require.Empty(t, logs)
+
+	_, err = gcb.RunLogs(context.Background(), &run.Run{})
+	require.Error(t, err)
+}
+
+func TestGCBCompleteness(t *testing.T) {
+	gcb := &GCB{}
+	build := &cloudbuild.Build{Substitutions: map[string]string{"COMMIT_SHA": "abc"}}
+	complete, reproducible := gcb.Completeness(&run.Run{SystemData: build})
+	require.True(t, complete.Parameters)
+	require.False(t, complete.Materials)
+	require.False(t, reproducible)
+
+	build.Substitutions["_VERSION"] = "1.0"
+	complete, _ = gcb.Completeness(&run.Run{SystemData: build})
+	require.False(t, complete.Parameters)
+}
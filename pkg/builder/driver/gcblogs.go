@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/cloudbuild/v1"
+
+	"sigs.k8s.io/tejolote/pkg/gcp"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// gcbLogInterval is the time between reads of the build log
+var gcbLogInterval = time.Second
+
+// gcbTransition matches the log lines Cloud Build writes when a build
+// step starts or finishes and when the build completes
+var gcbTransition = regexp.MustCompile(`^(Starting Step #\d+|Finished Step #\d+|DONE$|ERROR)`)
+
+// logReader reads the data of a log object from an offset
+type logReader func(ctx context.Context, bucket, object string, offset int64) ([]byte, error)
+
+// gcbLog is the position in the build log followed by the driver
+type gcbLog struct {
+	offset  int64
+	partial []byte
+	read    logReader
+	client  *storage.Client
+}
+
+// WaitTransition follows the build log written by Cloud Build to the logs
+// bucket, the same log gcloud builds log --stream tails, and returns
+// as soon as a line shows the build changed phase. If the build logs are
+// not written to a bucket, it returns ErrStreamingUnavailable.
+func (gcb *GCB) WaitTransition(ctx context.Context, r *run.Run) error {
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok || build.LogsBucket == "" {
+		return ErrStreamingUnavailable
+	}
+	if build.Options != nil {
+		switch build.Options.Logging {
+		case "CLOUD_LOGGING_ONLY", "NONE":
+			return ErrStreamingUnavailable
+		}
+	}
+	bucket, object := gcbLogObject(build)
+
+	if gcb.log == nil {
+		gcb.log = &gcbLog{}
+		gcb.log.read = gcb.log.readGCS
+	}
+	for {
+		data, err := gcb.log.read(ctx, bucket, object, gcb.log.offset)
+		if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("reading build log: %w", err)
+		}
+		gcb.log.offset += int64(len(data))
+		if line, ok := gcb.log.scan(data); ok {
+			logrus.Debugf("Build log transition: %s", line)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(gcbLogInterval):
+		}
+	}
+}
+
+// gcbLogObject returns the bucket and object name of the log of a build
+// written to its logs bucket
+func gcbLogObject(build *cloudbuild.Build) (bucket, object string) {
+	bucket = strings.TrimSuffix(strings.TrimPrefix(build.LogsBucket, "gs://"), "/")
+	object = fmt.Sprintf("log-%s.txt", build.Id)
+	// Logs in a bucket path are written under it
+	if b, prefix, ok := strings.Cut(bucket, "/"); ok {
+		bucket, object = b, prefix+"/"+object
+	}
+	return bucket, object
+}
+
+// RunLogs returns the build log written to the logs bucket. Builds
+// logging only to Cloud Logging have no log object.
+func (gcb *GCB) RunLogs(_ context.Context, r *run.Run) ([]RunLog, error) {
+	build, ok := r.SystemData.(*cloudbuild.Build)
+	if !ok {
+		return nil, errors.New("run has no build data")
+	}
+	if build.LogsBucket == "" || (build.Options != nil &&
+		(build.Options.Logging == "CLOUD_LOGGING_ONLY" || build.Options.Logging == "NONE")) {
+		return []RunLog{}, nil
+	}
+	bucket, object := gcbLogObject(build)
+	return []RunLog{{Name: "build", URI: fmt.Sprintf("gs://%s/%s", bucket, object)}}, nil
+}
+
+// ReadLog copies the build log object to w
+func (gcb *GCB) ReadLog(ctx context.Context, l RunLog, w io.Writer) error {
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(l.URI, "gs://"), "/")
+	if !ok {
+		return fmt.Errorf("invalid log location %s", l.URI)
+	}
+	client, err := gcp.NewStorageClient(ctx, "gs://"+bucket)
+	if err != nil {
+		return fmt.Errorf("creating storage client: %w", err)
+	}
+	defer client.Close()
+	rd, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("opening log: %w", err)
+	}
+	defer rd.Close()
+	if _, err := io.Copy(w, rd); err != nil {
+		return fmt.Errorf("reading log: %w", err)
+	}
+	return nil
+}
+
+// scan adds data to the log and returns the first complete line
+// marking a transition
+func (l *gcbLog) scan(data []byte) (string, bool) {
+	l.partial = append(l.partial, data...)
+	found, transition := false, ""
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			return transition, found
+		}
+		line := string(bytes.TrimRight(l.partial[:i], "\r"))
+		l.partial = l.partial[i+1:]
+		if !found && gcbTransition.MatchString(line) {
+			found, transition = true, line
+		}
+	}
+}
+
+// readGCS reads a log object from an offset. The storage client is
+// kept to be reused in the following reads.
+func (l *gcbLog) readGCS(ctx context.Context, bucket, object string, offset int64) ([]byte, error) {
+	if l.client == nil {
+		client, err := gcp.NewStorageClient(ctx, "gs://"+bucket)
+		if err != nil {
+			return nil, fmt.Errorf("creating storage client: %w", err)
+		}
+		l.client = client
+	}
+	obj := l.client.Bucket(bucket).Object(object)
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading log attributes: %w", err)
+	}
+	if attrs.Size <= offset {
+		return nil, nil
+	}
+	rd, err := obj.NewRangeReader(ctx, offset, -1)
+	if err != nil {
+		return nil, fmt.Errorf("opening log: %w", err)
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
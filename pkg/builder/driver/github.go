@@ -17,6 +17,8 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,6 +29,7 @@ import (
 	"time"
 
 	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	"github.com/sirupsen/logrus"
 
 	"sigs.k8s.io/tejolote/pkg/attestation"
@@ -35,32 +38,61 @@ import (
 	"sigs.k8s.io/tejolote/pkg/store"
 )
 
-const ghRunURL string = "https://api.github.com/repos/%s/%s/actions/runs/%d"
+const ghRunURL string = "%s/repos/%s/%s/actions/runs/%d"
 
 type GitHubWorkflow struct {
+	Host         string // GitHub Enterprise Server hostname, empty for github.com
 	Organization string
 	Repository   string
 	RunID        int
+
+	// etag of the last run data fetched, used to make
+	// conditional requests when polling the run
+	etag string
+
+	// offline is set when the run was decoded from captured
+	// data, the driver does not query the GitHub API then
+	offline bool
 }
 
-func parseGitHubURL(specURL string) (org, repo string, runID int64, err error) {
+// parseGitHubURL parses a github run spec URL. Runs in github.com are
+// specified as github://org/repo/runID. Runs in a GitHub Enterprise
+// Server instance include its hostname: github://ghe.example.com/org/repo/runs/runID
+// As organization names cannot contain dots, a hostname with a dot
+// is always a server.
+func parseGitHubURL(specURL string) (host, org, repo string, runID int64, err error) {
 	u, err := url.Parse(specURL)
+	if err != nil {
+		return host, org, repo, runID, fmt.Errorf("parsing spec url: %w", err)
+	}
 	if u.Scheme != GITHUB {
-		return org, repo, runID, errors.New("URL is not a github URL")
+		return host, org, repo, runID, errors.New("URL is not a github URL")
 	}
-	if err != nil {
-		return org, repo, runID, fmt.Errorf("parsing spec url: %w", err)
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if strings.Contains(u.Hostname(), ".") {
+		host = u.Host
+		if len(parts) == 4 && parts[2] == "runs" {
+			parts = []string{parts[0], parts[1], parts[3]}
+		}
+		if len(parts) != 3 {
+			return host, org, repo, runID, errors.New("unable to parse org/repo/runs/id from spec url")
+		}
+		org, parts = parts[0], parts[1:]
+	} else {
+		org = u.Hostname()
+	}
+	if len(parts) != 2 {
+		return host, org, repo, runID, errors.New("unable to parse repo/id from spec url")
 	}
-	parts := strings.SplitN(u.Path, "/", 3)
-	rID, err := strconv.Atoi(strings.TrimSuffix(parts[2], "/"))
+	rID, err := strconv.Atoi(parts[1])
 	if err != nil {
-		return org, repo, runID, fmt.Errorf("parsing run ID from URL: %w", err)
+		return host, org, repo, runID, fmt.Errorf("parsing run ID from URL: %w", err)
 	}
 
-	return u.Hostname(), parts[1], int64(rID), nil
+	return host, org, parts[0], int64(rID), nil
 }
 
-func (ghw *GitHubWorkflow) GetRun(specURL string) (*run.Run, error) {
+func (ghw *GitHubWorkflow) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
 	r := &run.Run{
 		SpecURL:   specURL,
 		IsSuccess: false,
@@ -69,32 +101,54 @@ func (ghw *GitHubWorkflow) GetRun(specURL string) (*run.Run, error) {
 		StartTime: time.Time{},
 		EndTime:   time.Time{},
 	}
-	if err := ghw.RefreshRun(r); err != nil {
+	if err := ghw.RefreshRun(ctx, r); err != nil {
 		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
 	}
 	return r, nil
 }
 
 // RefreshRun queries the github API to get the latest data
-func (ghw *GitHubWorkflow) RefreshRun(r *run.Run) error {
+func (ghw *GitHubWorkflow) RefreshRun(ctx context.Context, r *run.Run) error {
 	// https://api.github.com/repos/distroless/static/actions/runs/2858064062
 	// https://api.github.com/repos/distroless/static/actions/runs/7492361110 (failure)
-	org, repo, id, err := parseGitHubURL(r.SpecURL)
+	host, org, repo, id, err := parseGitHubURL(r.SpecURL)
 	if err != nil {
 		return fmt.Errorf("parsing spec url: %w", err)
 	}
+	ghw.Host = host
 	ghw.Organization = org
 	ghw.Repository = repo
 	ghw.RunID = int(id)
 
-	res, err := github.APIGetRequest(fmt.Sprintf(ghRunURL, ghw.Organization, ghw.Repository, ghw.RunID))
+	// Only send the etag if the run already has the data it refers to
+	etag := ""
+	if r.SystemData != nil {
+		etag = ghw.etag
+	}
+
+	res, notModified, err := github.APIGetRequestWithETag(
+		ctx, fmt.Sprintf(ghRunURL, github.ServerAPIURL(ghw.Host), ghw.Organization, ghw.Repository, ghw.RunID), etag,
+	)
 	if err != nil {
+		rlErr := &github.RateLimitError{}
+		if errors.As(err, &rlErr) {
+			return &RetryAfterError{RetryAfter: rlErr.RetryAfter, Err: err}
+		}
+		if errors.Is(err, github.ErrNotFound) {
+			return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+		}
 		return fmt.Errorf("querying github api: %w", err)
 	}
 
+	if notModified {
+		logrus.Debug("Run data not modified since last poll")
+		return nil
+	}
+
 	if res.StatusCode != 200 {
 		return fmt.Errorf("got https error %d from github API", res.StatusCode)
 	}
+	ghw.etag = res.Header.Get("ETag")
 
 	rawData, err := io.ReadAll(res.Body)
 	defer res.Body.Close()
@@ -109,16 +163,20 @@ func (ghw *GitHubWorkflow) RefreshRun(r *run.Run) error {
 		return fmt.Errorf("unmarshalling GitHub response: %w", err)
 	}
 
-	if runData.Status == "completed" {
-		r.IsRunning = false
-	}
+	r.IsRunning = runData.Status != "completed"
 
 	switch runData.Conclusion {
-	case "failure", "cancelled":
+	case "failure", "cancelled", "timed_out":
 		r.IsSuccess = false
 	case "success":
 		r.IsSuccess = true
 	}
+	switch runData.Conclusion {
+	case "cancelled":
+		r.Outcome = run.OutcomeCancelled
+	case "timed_out":
+		r.Outcome = run.OutcomeTimeout
+	}
 
 	r.SystemData = runData
 
@@ -131,7 +189,7 @@ func (ghw *GitHubWorkflow) RefreshRun(r *run.Run) error {
 
 // BuildPredicate builds a predicate from the run data
 func (ghw *GitHubWorkflow) BuildPredicate(
-	r *run.Run, draft *attestation.SLSAPredicate,
+	ctx context.Context, r *run.Run, draft *attestation.SLSAPredicate,
 ) (predicate *attestation.SLSAPredicate, err error) {
 	type githubEnvironment struct {
 		// The architecture of the runner.
@@ -144,9 +202,12 @@ func (ghw *GitHubWorkflow) BuildPredicate(
 			Runner map[string]string `json:"runner"`
 		} `json:"context"`
 	}
-	org, repo, runID, err := parseGitHubURL(r.SpecURL)
+	host, org, repo, runID, err := parseGitHubURL(r.SpecURL)
 	if err != nil {
-		return nil, fmt.Errorf("parsing run spec URL: %w", nil)
+		return nil, fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	if host == "" {
+		host = "github.com"
 	}
 	if draft == nil {
 		pred := attestation.NewSLSAPredicate()
@@ -159,12 +220,14 @@ func (ghw *GitHubWorkflow) BuildPredicate(
 	predicate.Invocation.ConfigSource.Digest = common.DigestSet{
 		"sha1": r.SystemData.(*github.Run).HeadSHA,
 	}
-	predicate.Invocation.ConfigSource.EntryPoint = r.SystemData.(*github.Run).Path
+	// Reusable and dynamic workflows append the ref to the path
+	workflowPath, _, _ := strings.Cut(r.SystemData.(*github.Run).Path, "@")
+	predicate.Invocation.ConfigSource.EntryPoint = workflowPath
 	predicate.Invocation.ConfigSource.URI = fmt.Sprintf(
-		"git+https://github.com/%s/%s.git", org, repo,
+		"git+https://%s/%s/%s.git", host, org, repo,
 	)
 	// TODO: I think we need to checkout the file from git to fill
-	predicate.Invocation.Environment = githubEnvironment{
+	predicate.Invocation.Environment = &githubEnvironment{
 		Arch: "",
 		Env:  map[string]string{},
 		Context: struct {
@@ -176,20 +239,245 @@ func (ghw *GitHubWorkflow) BuildPredicate(
 			},
 		},
 	}
+
+	// Pin the workflow definition used in the run by recording the
+	// digest of the workflow file at the triggering commit
+	headSHA := r.SystemData.(*github.Run).HeadSHA
+	if workflowPath != "" && headSHA != "" && ghw.offline {
+		logrus.Warn("replaying captured run, not recording the workflow file digest")
+	} else if workflowPath != "" && headSHA != "" {
+		digest, err := workflowDigest(ctx, host, org, repo, workflowPath, headSHA)
+		if err != nil {
+			logrus.Warnf("unable to record the workflow file digest: %v", err)
+		} else {
+			predicate.Invocation.Environment.(*githubEnvironment).Context.GitHub["workflow_sha256"] = digest["sha256"]
+			predicate.AddMaterial(
+				fmt.Sprintf("git+https://%s/%s/%s@%s#%s", host, org, repo, headSHA, workflowPath), digest,
+			)
+		}
+	}
 	return predicate, nil
 }
 
+// DecodeSystemData decodes the workflow run data of a captured run
+func (ghw *GitHubWorkflow) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	host, org, repo, id, err := parseGitHubURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	runData := &github.Run{}
+	if err := json.Unmarshal(data, runData); err != nil {
+		return nil, fmt.Errorf("unmarshaling run data: %w", err)
+	}
+	ghw.Host = host
+	ghw.Organization = org
+	ghw.Repository = repo
+	ghw.RunID = int(id)
+	ghw.offline = true
+	return runData, nil
+}
+
+// workflowDigest returns the digests of a workflow file at a commit
+func workflowDigest(ctx context.Context, host, org, repo, path, commit string) (common.DigestSet, error) {
+	data, blobSHA, err := github.FileContents(ctx, github.ServerAPIURL(host), org, repo, path, commit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching workflow file: %w", err)
+	}
+	return common.DigestSet{
+		"sha256":  fmt.Sprintf("%x", sha256.Sum256(data)),
+		"gitBlob": blobSHA,
+	}, nil
+}
+
+// ReleaseURL returns the release of the tag that triggered the run.
+// Runs triggered by a tag push or a release report the tag name as the
+// head branch, the release is looked up with it when uploading.
+func (ghw *GitHubWorkflow) ReleaseURL(r *run.Run) (string, bool) {
+	runData, ok := r.SystemData.(*github.Run)
+	if !ok || runData.HeadBranch == "" {
+		return "", false
+	}
+	switch runData.Event {
+	case "push", "release", "create", "workflow_dispatch":
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("github://%s/%s/%s", ghw.Organization, ghw.Repository, runData.HeadBranch), true
+}
+
+// FormatCompat shapes the attestation for a third-party verifier. In the
+// slsa-verifier mode the builder is identified by the workflow ref, the
+// config source and environment record the built ref as the
+// slsa-github-generator does and subjects are named after the files.
+func (ghw *GitHubWorkflow) FormatCompat(
+	ctx context.Context, mode string, r *run.Run, att *attestation.Attestation,
+) error {
+	if mode != attestation.CompatSLSAVerifier {
+		return fmt.Errorf("unsupported compatibility mode %q", mode)
+	}
+	runData, ok := r.SystemData.(*github.Run)
+	if !ok {
+		return errors.New("run has no github workflow data")
+	}
+	host, org, repo, runID, err := parseGitHubURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	if host != "" && host != "github.com" {
+		return fmt.Errorf("slsa-verifier only verifies provenance from github.com, not %s", host)
+	}
+	ref, refType, err := ghw.runRef(ctx, org, repo, runData)
+	if err != nil {
+		return fmt.Errorf("reading the ref of the run: %w", err)
+	}
+	workflowPath, _, _ := strings.Cut(runData.Path, "@")
+
+	att.Predicate.Builder.ID = fmt.Sprintf("https://github.com/%s/%s/%s@%s", org, repo, workflowPath, ref)
+	att.Predicate.BuildType = attestation.SLSAVerifierBuildType
+	att.Predicate.Invocation.ConfigSource.URI = fmt.Sprintf("git+https://github.com/%s/%s@%s", org, repo, ref)
+	att.Predicate.Invocation.ConfigSource.Digest = common.DigestSet{"sha1": runData.HeadSHA}
+	att.Predicate.Invocation.ConfigSource.EntryPoint = workflowPath
+	att.Predicate.AddMaterial(att.Predicate.Invocation.ConfigSource.URI, common.DigestSet{"sha1": runData.HeadSHA})
+	attempt := runData.RunAttempt
+	if attempt == 0 {
+		attempt = 1
+	}
+	att.Predicate.Invocation.Environment = map[string]interface{}{
+		"github_actor":               runData.Actor.Login,
+		"github_actor_id":            fmt.Sprintf("%d", runData.Actor.ID),
+		"github_event_name":          runData.Event,
+		"github_ref":                 ref,
+		"github_ref_type":            refType,
+		"github_repository_id":       fmt.Sprintf("%d", runData.Repository.ID),
+		"github_repository_owner":    org,
+		"github_repository_owner_id": fmt.Sprintf("%d", runData.Repository.Owner.ID),
+		"github_run_attempt":         fmt.Sprintf("%d", attempt),
+		"github_run_id":              fmt.Sprintf("%d", runID),
+		"github_run_number":          fmt.Sprintf("%d", runData.RunNumber),
+		"github_sha1":                runData.HeadSHA,
+	}
+	if att.Predicate.Metadata != nil {
+		att.Predicate.Metadata.BuildInvocationID = fmt.Sprintf("%d-%d", runID, attempt)
+	}
+	if err := att.BaseNameSubjects(); err != nil {
+		return fmt.Errorf("renaming subjects: %w", err)
+	}
+	if err := att.CheckSLSAVerifier(); err != nil {
+		return fmt.Errorf("attestation would not pass slsa-verifier: %w", err)
+	}
+	return nil
+}
+
+// runRef returns the full git ref the run built and its type (branch or
+// tag). Reusable workflows report the ref in the workflow path, otherwise
+// the head branch is looked up among the repository tags as runs
+// triggered by tags report the tag name as the head branch.
+func (ghw *GitHubWorkflow) runRef(ctx context.Context, org, repo string, runData *github.Run) (ref, refType string, err error) {
+	if _, pathRef, ok := strings.Cut(runData.Path, "@"); ok {
+		if name, ok := strings.CutPrefix(pathRef, "refs/tags/"); ok && name == runData.HeadBranch {
+			return pathRef, "tag", nil
+		}
+		if name, ok := strings.CutPrefix(pathRef, "refs/heads/"); ok && name == runData.HeadBranch {
+			return pathRef, "branch", nil
+		}
+	}
+	if runData.HeadBranch == "" {
+		return "", "", errors.New("run has no head branch")
+	}
+	isTag := false
+	switch {
+	case runData.Event == "release":
+		isTag = true
+	case ghw.offline:
+		logrus.Warnf("replaying captured run, assuming %s is a branch", runData.HeadBranch)
+	default:
+		isTag, err = github.TagExists(ctx, github.APIURL(), org, repo, runData.HeadBranch)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if isTag {
+		return "refs/tags/" + runData.HeadBranch, "tag", nil
+	}
+	return "refs/heads/" + runData.HeadBranch, "branch", nil
+}
+
+// Completeness reports the parameters of runs as complete when they were
+// not triggered by an event carrying inputs, which the runs API does not
+// return. The runner environment and the actions used are not recorded.
+func (ghw *GitHubWorkflow) Completeness(r *run.Run) (complete slsa.ProvenanceComplete, reproducible bool) {
+	runData, ok := r.SystemData.(*github.Run)
+	if !ok {
+		return complete, false
+	}
+	switch runData.Event {
+	case "workflow_dispatch", "repository_dispatch", "workflow_call":
+	default:
+		complete.Parameters = true
+	}
+	return complete, false
+}
+
+// RunLogs returns the logs of the jobs of the latest attempt of the run
+func (ghw *GitHubWorkflow) RunLogs(ctx context.Context, r *run.Run) ([]RunLog, error) {
+	host, org, repo, id, err := parseGitHubURL(r.SpecURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	apiBase := github.ServerAPIURL(host)
+	res, err := github.APIGetRequest(ctx, fmt.Sprintf(ghRunURL+"/jobs?per_page=100", apiBase, org, repo, id))
+	if err != nil {
+		return nil, fmt.Errorf("listing run jobs: %w", err)
+	}
+	defer res.Body.Close()
+	jobs := struct {
+		Jobs []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"jobs"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("decoding jobs data: %w", err)
+	}
+	logs := []RunLog{}
+	for _, j := range jobs.Jobs {
+		logs = append(logs, RunLog{
+			Name: j.Name,
+			URI:  fmt.Sprintf("%s/repos/%s/%s/actions/jobs/%d/logs", apiBase, org, repo, j.ID),
+		})
+	}
+	return logs, nil
+}
+
+// ReadLog downloads the log of a job to w
+func (ghw *GitHubWorkflow) ReadLog(ctx context.Context, l RunLog, w io.Writer) error {
+	if err := github.Download(ctx, l.URI, w); err != nil {
+		return fmt.Errorf("downloading log of job %s: %w", l.Name, err)
+	}
+	return nil
+}
+
 // ArtifactStores returns the native artifact store of github actions
 func (ghw *GitHubWorkflow) ArtifactStores() []store.Store {
-	d, err := store.New(
-		fmt.Sprintf(
-			"actions://%s/%s/%d",
-			ghw.Organization, ghw.Repository, ghw.RunID,
-		),
-	)
+	spec := fmt.Sprintf("actions://%s/%s/%d", ghw.Organization, ghw.Repository, ghw.RunID)
+	if ghw.Host != "" {
+		spec = fmt.Sprintf(
+			"actions://%s/%s/%s/%d", ghw.Host, ghw.Organization, ghw.Repository, ghw.RunID,
+		)
+	}
+	d, err := store.New(spec)
 	if err != nil {
 		logrus.Error(err)
 		return []store.Store{}
 	}
 	return []store.Store{d}
 }
+
+// Capabilities returns the features supported by the driver
+func (ghw *GitHubWorkflow) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: true,
+		LiveStatus:      true,
+		Streaming:       false,
+	}
+}
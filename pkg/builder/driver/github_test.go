@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestParseGitHubURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec     string
+		host     string
+		org      string
+		repo     string
+		runID    int64
+		mustFail bool
+	}{
+		{spec: "github://distroless/static/2858064062", org: "distroless", repo: "static", runID: 2858064062},
+		{spec: "github://distroless/static/2858064062/", org: "distroless", repo: "static", runID: 2858064062},
+		{spec: "github://ghe.example.com/org/repo/runs/123", host: "ghe.example.com", org: "org", repo: "repo", runID: 123},
+		{spec: "github://ghe.example.com:8443/org/repo/123", host: "ghe.example.com:8443", org: "org", repo: "repo", runID: 123},
+		{spec: "github://distroless/static", mustFail: true},
+		{spec: "github://ghe.example.com/org/repo", mustFail: true},
+		{spec: "gcb://project/build", mustFail: true},
+	} {
+		host, org, repo, runID, err := parseGitHubURL(tc.spec)
+		if tc.mustFail {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.host, host, tc.spec)
+		require.Equal(t, tc.org, org, tc.spec)
+		require.Equal(t, tc.repo, repo, tc.spec)
+		require.Equal(t, tc.runID, runID, tc.spec)
+	}
+}
+
+func TestBuildPredicateWorkflowDigest(t *testing.T) {
+	workflow := []byte("on: push\njobs: {}\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/repo/contents/.github/workflows/release.yaml" || r.URL.Query().Get("ref") != "abc123" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"type":"file","encoding":"base64","sha":"blobsha","content":%q}`,
+			base64.StdEncoding.EncodeToString(workflow))
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+
+	ghw := &GitHubWorkflow{}
+	pred, err := ghw.BuildPredicate(context.Background(), &run.Run{
+		SpecURL:    "github://org/repo/1",
+		SystemData: &github.Run{HeadSHA: "abc123", Path: ".github/workflows/release.yaml@refs/heads/main"},
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, ".github/workflows/release.yaml", pred.Invocation.ConfigSource.EntryPoint)
+	require.Len(t, pred.Materials, 1)
+	require.Equal(t, "git+https://github.com/org/repo@abc123#.github/workflows/release.yaml", pred.Materials[0].URI)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(workflow)), pred.Materials[0].Digest["sha256"])
+	require.Equal(t, "blobsha", pred.Materials[0].Digest["gitBlob"])
+
+	// Failing to read the workflow does not fail the predicate
+	pred, err = ghw.BuildPredicate(context.Background(), &run.Run{
+		SpecURL:    "github://org/repo/1",
+		SystemData: &github.Run{HeadSHA: "other", Path: ".github/workflows/release.yaml"},
+	}, nil)
+	require.NoError(t, err)
+	require.Empty(t, pred.Materials)
+}
+
+// TestFormatCompatSLSAVerifier checks the provenance of a captured run
+// against the expected slsa-verifier compatible output
+func TestFormatCompatSLSAVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/repo/git/ref/tags/v1.0.0" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"ref": "refs/tags/v1.0.0"}`)
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+
+	data, err := os.ReadFile("testdata/slsa-verifier/run.json")
+	require.NoError(t, err)
+	runData := &github.Run{}
+	require.NoError(t, json.Unmarshal(data, runData))
+	r := &run.Run{SpecURL: "github://org/repo/5123456789", SystemData: runData}
+
+	ghw := &GitHubWorkflow{}
+	pred, err := ghw.BuildPredicate(context.Background(), r, nil)
+	require.NoError(t, err)
+	att := attestation.New()
+	att.Predicate = *pred
+	att.AddSubjects(intoto.Subject{
+		Name:   "/workspace/dist/binary-linux-amd64",
+		Digest: map[string]string{"SHA256": "2E8C9DE6AB0D4C2A3EB0E1F8A4B0A3C1E4A7A2F6C1B5D9E3F7A1C5B9D3E7F1A5"},
+	})
+	require.NoError(t, ghw.FormatCompat(context.Background(), attestation.CompatSLSAVerifier, r, att))
+
+	got, err := att.ToJSON()
+	require.NoError(t, err)
+	expected, err := os.ReadFile("testdata/slsa-verifier/provenance.json")
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(got))
+
+	// Runs on GitHub Enterprise Server cannot be verified
+	r.SpecURL = "github://ghe.example.com/org/repo/5123456789"
+	require.Error(t, ghw.FormatCompat(context.Background(), attestation.CompatSLSAVerifier, r, att))
+}
+
+func FuzzParseGitHubURL(f *testing.F) {
+	for _, seed := range []string{
+		"github://distroless/static/2858064062",
+		"github://ghe.example.com/org/repo/runs/123",
+		"github://ghe.example.com:8443/org/repo/123",
+		"github:///",
+		"github://a/b/c/d/e",
+		"github://%zz/repo/1",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		host, org, repo, _, err := parseGitHubURL(spec)
+		if err != nil {
+			return
+		}
+		if repo == "" && org == "" && host == "" {
+			t.Errorf("parsed %q without error but got no data", spec)
+		}
+	})
+}
+
+func TestGitHubReleaseURL(t *testing.T) {
+	ghw := &GitHubWorkflow{Organization: "org", Repository: "repo"}
+	spec, ok := ghw.ReleaseURL(&run.Run{SystemData: &github.Run{HeadBranch: "v1.0.0", Event: "push"}})
+	require.True(t, ok)
+	require.Equal(t, "github://org/repo/v1.0.0", spec)
+
+	_, ok = ghw.ReleaseURL(&run.Run{SystemData: &github.Run{HeadBranch: "main", Event: "pull_request"}})
+	require.False(t, ok)
+	_, ok = ghw.ReleaseURL(&run.Run{})
+	require.False(t, ok)
+}
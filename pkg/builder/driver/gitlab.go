@@ -0,0 +1,250 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/gitlab"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+const GITLAB = "gitlab"
+
+// GitLabPipeline is a driver to attest GitLab CI pipelines running
+// in gitlab.com or in a self-managed instance
+type GitLabPipeline struct {
+	Host       string // Hostname of the GitLab instance
+	Project    string // Full path of the project, including subgroups
+	PipelineID int64
+}
+
+// parseGitLabURL parses a gitlab pipeline spec URL. The host of the
+// instance is always part of the URL:
+// gitlab://gitlab.example.com/group/subgroup/project/pipelines/42
+func parseGitLabURL(specURL string) (host, project string, pipelineID int64, err error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return host, project, pipelineID, fmt.Errorf("parsing spec url: %w", err)
+	}
+	if u.Scheme != GITLAB {
+		return host, project, pipelineID, errors.New("URL is not a gitlab URL")
+	}
+	if u.Host == "" {
+		return host, project, pipelineID, errors.New("spec URL does not include the GitLab host")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-2] != "pipelines" {
+		return host, project, pipelineID, errors.New("unable to parse project/pipelines/id from spec url")
+	}
+	for _, p := range parts[:len(parts)-2] {
+		if p == "" {
+			return host, project, pipelineID, errors.New("invalid project path in spec url")
+		}
+	}
+	id, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return host, project, pipelineID, fmt.Errorf("parsing pipeline ID from URL: %w", err)
+	}
+	return u.Host, strings.Join(parts[:len(parts)-2], "/"), id, nil
+}
+
+// gitlabPipelineData is the data of the pipeline stored in the run
+type gitlabPipelineData struct {
+	Pipeline gitlab.Pipeline
+	Jobs     []gitlab.Job
+}
+
+func (glp *GitLabPipeline) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
+	r := &run.Run{
+		SpecURL:   specURL,
+		IsSuccess: false,
+		Steps:     []run.Step{},
+		Artifacts: []run.Artifact{},
+		StartTime: time.Time{},
+		EndTime:   time.Time{},
+	}
+	if err := glp.RefreshRun(ctx, r); err != nil {
+		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
+	}
+	return r, nil
+}
+
+// getJSON fetches an API path from the instance and unmarshals it
+func (glp *GitLabPipeline) getJSON(ctx context.Context, path string, data interface{}) error {
+	res, err := gitlab.APIGetRequest(ctx, glp.Host, path)
+	if err != nil {
+		return fmt.Errorf("querying gitlab api: %w", err)
+	}
+	defer res.Body.Close()
+	rawData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading api response data: %w", err)
+	}
+	if err := json.Unmarshal(rawData, data); err != nil {
+		return fmt.Errorf("unmarshalling GitLab response: %w", err)
+	}
+	return nil
+}
+
+// RefreshRun queries the GitLab API to get the latest pipeline data
+func (glp *GitLabPipeline) RefreshRun(ctx context.Context, r *run.Run) error {
+	host, project, id, err := parseGitLabURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing spec url: %w", err)
+	}
+	glp.Host = host
+	glp.Project = project
+	glp.PipelineID = id
+
+	pipelinePath := fmt.Sprintf("projects/%s/pipelines/%d", url.PathEscape(glp.Project), glp.PipelineID)
+	data := &gitlabPipelineData{}
+	if err := glp.getJSON(ctx, pipelinePath, &data.Pipeline); err != nil {
+		if errors.Is(err, gitlab.ErrNotFound) {
+			return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+		}
+		return fmt.Errorf("fetching pipeline: %w", err)
+	}
+	if err := glp.getJSON(ctx, pipelinePath+"/jobs?per_page=100", &data.Jobs); err != nil {
+		return fmt.Errorf("fetching pipeline jobs: %w", err)
+	}
+
+	switch data.Pipeline.Status {
+	case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+		r.IsRunning = true
+	default:
+		r.IsRunning = false
+	}
+	r.IsSuccess = data.Pipeline.Status == "success"
+	if data.Pipeline.Status == "canceled" {
+		r.Outcome = run.OutcomeCancelled
+	}
+
+	if data.Pipeline.StartedAt != nil {
+		r.StartTime = *data.Pipeline.StartedAt
+	}
+	if data.Pipeline.FinishedAt != nil {
+		r.EndTime = *data.Pipeline.FinishedAt
+	}
+
+	r.Steps = []run.Step{}
+	for _, job := range data.Jobs {
+		s := run.Step{
+			Command:   job.Name,
+			IsSuccess: job.Status == "success",
+			Params:    []string{},
+		}
+		if job.StartedAt != nil {
+			s.StartTime = *job.StartedAt
+		}
+		if job.FinishedAt != nil {
+			s.EndTime = *job.FinishedAt
+		}
+		r.Steps = append(r.Steps, s)
+	}
+
+	logrus.Debugf("Pipeline %d status: %s", glp.PipelineID, data.Pipeline.Status)
+	r.SystemData = data
+	return nil
+}
+
+// DecodeSystemData decodes the pipeline data of a captured run
+func (glp *GitLabPipeline) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	host, project, id, err := parseGitLabURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	pipelineData := &gitlabPipelineData{}
+	if err := json.Unmarshal(data, pipelineData); err != nil {
+		return nil, fmt.Errorf("unmarshaling pipeline data: %w", err)
+	}
+	glp.Host = host
+	glp.Project = project
+	glp.PipelineID = id
+	return pipelineData, nil
+}
+
+// ReleaseURL returns the release of the tag built by tag pipelines
+func (glp *GitLabPipeline) ReleaseURL(r *run.Run) (string, bool) {
+	data, ok := r.SystemData.(*gitlabPipelineData)
+	if !ok || !data.Pipeline.Tag {
+		return "", false
+	}
+	return fmt.Sprintf("gitlab://%s/%s/-/releases/%s", glp.Host, glp.Project, data.Pipeline.Ref), true
+}
+
+// BuildPredicate builds a predicate from the run data
+func (glp *GitLabPipeline) BuildPredicate(
+	_ context.Context, r *run.Run, draft *attestation.SLSAPredicate,
+) (predicate *attestation.SLSAPredicate, err error) {
+	host, project, pipelineID, err := parseGitLabURL(r.SpecURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	data, ok := r.SystemData.(*gitlabPipelineData)
+	if !ok {
+		return nil, errors.New("run has no GitLab pipeline data")
+	}
+	if draft == nil {
+		pred := attestation.NewSLSAPredicate()
+		predicate = &pred
+	} else {
+		predicate = draft
+	}
+	predicate.Builder.ID = fmt.Sprintf("https://%s/%s/-/runners", host, project)
+	predicate.BuildType = "https://gitlab.com/gitlab-org/gitlab-runner/-/blob/main/PROVENANCE.md"
+	predicate.Invocation.ConfigSource.Digest = common.DigestSet{
+		"sha1": data.Pipeline.SHA,
+	}
+	predicate.Invocation.ConfigSource.EntryPoint = ".gitlab-ci.yml"
+	predicate.Invocation.ConfigSource.URI = fmt.Sprintf("git+https://%s/%s.git", host, project)
+	predicate.Invocation.Environment = map[string]string{
+		"CI_PIPELINE_ID":     fmt.Sprintf("%d", pipelineID),
+		"CI_PIPELINE_SOURCE": data.Pipeline.Source,
+		"CI_COMMIT_REF_NAME": data.Pipeline.Ref,
+		"CI_SERVER_HOST":     host,
+	}
+	return predicate, nil
+}
+
+// ArtifactStores returns the native artifact stores of the pipeline.
+// Reading GitLab job artifacts is not supported yet.
+func (glp *GitLabPipeline) ArtifactStores() []store.Store {
+	return []store.Store{}
+}
+
+// Capabilities returns the features supported by the driver
+func (glp *GitLabPipeline) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: false,
+		LiveStatus:      true,
+		Streaming:       false,
+	}
+}
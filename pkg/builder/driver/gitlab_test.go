@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitLabURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec       string
+		host       string
+		project    string
+		pipelineID int64
+		mustFail   bool
+	}{
+		{spec: "gitlab://gitlab.com/group/project/pipelines/42", host: "gitlab.com", project: "group/project", pipelineID: 42},
+		{spec: "gitlab://gitlab.example.com:8443/group/sub/project/pipelines/7/", host: "gitlab.example.com:8443", project: "group/sub/project", pipelineID: 7},
+		{spec: "gitlab://gitlab.example.com/group/project/42", mustFail: true},
+		{spec: "gitlab://gitlab.example.com/pipelines/42", mustFail: true},
+		{spec: "gitlab://gitlab.example.com/group//project/pipelines/42", mustFail: true},
+		{spec: "gitlab:///group/project/pipelines/42", mustFail: true},
+		{spec: "github://org/repo/42", mustFail: true},
+	} {
+		host, project, id, err := parseGitLabURL(tc.spec)
+		if tc.mustFail {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.host, host, tc.spec)
+		require.Equal(t, tc.project, project, tc.spec)
+		require.Equal(t, tc.pipelineID, id, tc.spec)
+	}
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+const K8S = "k8s"
+
+// Annotations read from the job to record its source revision
+const (
+	K8SSourceAnnotation     = "tejolote.sigs.k8s.io/source"      // Repository URL (git+https://...)
+	K8SRevisionAnnotation   = "tejolote.sigs.k8s.io/revision"    // Commit hash built
+	K8SEntryPointAnnotation = "tejolote.sigs.k8s.io/entry-point" // Build definition in the repository
+)
+
+// KubernetesJob is a driver to attest builds running as Kubernetes
+// Jobs. The cluster is read from the kubeconfig (KUBECONFIG or
+// ~/.kube/config) or the in-cluster service account.
+type KubernetesJob struct {
+	Namespace string
+	Name      string
+
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+// kubernetesJobData is the data of the job stored in the run
+type kubernetesJobData struct {
+	Job batchv1.Job `json:"job"`
+	// ImageIDs are the image references with digest the pods ran,
+	// by container name
+	ImageIDs map[string]string `json:"image_ids"`
+	// Host is the URL of the API server
+	Host string `json:"host"`
+}
+
+// parseKubernetesURL parses a job spec URL: k8s://namespace/job-name
+func parseKubernetesURL(specURL string) (namespace, name string, err error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return namespace, name, fmt.Errorf("parsing spec url: %w", err)
+	}
+	if u.Scheme != K8S {
+		return namespace, name, errors.New("URL is not a k8s URL")
+	}
+	name = strings.Trim(u.Path, "/")
+	if u.Host == "" || name == "" || strings.Contains(name, "/") {
+		return namespace, name, errors.New("unable to parse namespace/job from spec url")
+	}
+	return u.Host, name, nil
+}
+
+// getClient returns a client of the cluster configured in the
+// environment
+func (kj *KubernetesJob) getClient() (kubernetes.Interface, error) {
+	if kj.client != nil {
+		return kj.client, nil
+	}
+	if kj.config == nil {
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("reading kubernetes client configuration: %w", err)
+		}
+		kj.config = config
+	}
+	client, err := kubernetes.NewForConfig(kj.config)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+	kj.client = client
+	return client, nil
+}
+
+func (kj *KubernetesJob) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
+	r := &run.Run{
+		SpecURL:   specURL,
+		IsSuccess: false,
+		Steps:     []run.Step{},
+		Artifacts: []run.Artifact{},
+		StartTime: time.Time{},
+		EndTime:   time.Time{},
+	}
+	if err := kj.RefreshRun(ctx, r); err != nil {
+		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
+	}
+	return r, nil
+}
+
+// RefreshRun reads the job and its pods from the cluster
+func (kj *KubernetesJob) RefreshRun(ctx context.Context, r *run.Run) error {
+	namespace, name, err := parseKubernetesURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing spec url: %w", err)
+	}
+	kj.Namespace = namespace
+	kj.Name = name
+	client, err := kj.getClient()
+	if err != nil {
+		return err
+	}
+
+	job, err := client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+	}
+	if err != nil {
+		return fmt.Errorf("getting job %s/%s: %w", namespace, name, err)
+	}
+	data := &kubernetesJobData{Job: *job, ImageIDs: map[string]string{}, Host: kj.config.Host}
+
+	// The image digests are only known from the pod statuses
+	if job.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+		if err != nil {
+			return fmt.Errorf("parsing job selector: %w", err)
+		}
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return fmt.Errorf("listing job pods: %w", err)
+		}
+		for i := range pods.Items {
+			statuses := append([]corev1.ContainerStatus{}, pods.Items[i].Status.InitContainerStatuses...)
+			statuses = append(statuses, pods.Items[i].Status.ContainerStatuses...)
+			for _, cs := range statuses {
+				if strings.Contains(cs.ImageID, "@sha256:") {
+					data.ImageIDs[cs.Name] = cs.ImageID
+				}
+			}
+		}
+	}
+
+	kj.setRunData(r, data)
+	logrus.Debugf(
+		"Job %s/%s: %d active, %d succeeded, %d failed",
+		namespace, name, job.Status.Active, job.Status.Succeeded, job.Status.Failed,
+	)
+	return nil
+}
+
+// AnnotateRun merges the annotations into the metadata of the job
+func (kj *KubernetesJob) AnnotateRun(ctx context.Context, r *run.Run, annotations map[string]string) error {
+	namespace, name, err := parseKubernetesURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing spec url: %w", err)
+	}
+	if err := readonly.Check(fmt.Sprintf("annotating job %s/%s", namespace, name)); err != nil {
+		return err
+	}
+	client, err := kj.getClient()
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling annotations patch: %w", err)
+	}
+	if _, err := client.BatchV1().Jobs(namespace).Patch(
+		ctx, name, types.MergePatchType, patch, metav1.PatchOptions{},
+	); err != nil {
+		return fmt.Errorf("annotating job %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// setRunData fills the run from the job data
+func (kj *KubernetesJob) setRunData(r *run.Run, data *kubernetesJobData) {
+	job := &data.Job
+	r.IsRunning = true
+	r.IsSuccess = false
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			r.IsRunning = false
+			r.IsSuccess = true
+			r.EndTime = c.LastTransitionTime.UTC()
+		case batchv1.JobFailed:
+			r.IsRunning = false
+			r.EndTime = c.LastTransitionTime.UTC()
+			if c.Reason == "DeadlineExceeded" {
+				r.Outcome = run.OutcomeTimeout
+			}
+		}
+	}
+	if job.Status.StartTime != nil {
+		r.StartTime = job.Status.StartTime.UTC()
+	}
+	if job.Status.CompletionTime != nil {
+		r.EndTime = job.Status.CompletionTime.UTC()
+	}
+
+	containers := append([]corev1.Container{}, job.Spec.Template.Spec.InitContainers...)
+	containers = append(containers, job.Spec.Template.Spec.Containers...)
+	r.Steps = []run.Step{}
+	for _, c := range containers {
+		s := run.Step{
+			Command:     strings.Join(c.Command, " "),
+			Image:       c.Image,
+			IsSuccess:   r.IsSuccess,
+			Params:      c.Args,
+			StartTime:   r.StartTime,
+			EndTime:     r.EndTime,
+			Environment: map[string]string{},
+		}
+		if id, ok := data.ImageIDs[c.Name]; ok {
+			s.Image = imageWithDigest(c.Image, id)
+		}
+		if s.Params == nil {
+			s.Params = []string{}
+		}
+		// Values read from secrets and config maps are not recorded
+		for _, e := range c.Env {
+			if e.ValueFrom == nil {
+				s.Environment[e.Name] = e.Value
+			}
+		}
+		r.Steps = append(r.Steps, s)
+	}
+	r.SystemData = data
+}
+
+// imageWithDigest returns the image reference pinned to the digest
+// of the image ID reported by the kubelet
+func imageWithDigest(image, imageID string) string {
+	_, digest, ok := strings.Cut(imageID, "@")
+	if !ok {
+		return image
+	}
+	image, _, _ = strings.Cut(image, "@")
+	// Drop the tag, the colon of a registry port is followed by a slash
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + "@" + digest
+}
+
+// DecodeSystemData decodes the job data of a captured run
+func (kj *KubernetesJob) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	namespace, name, err := parseKubernetesURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	jobData := &kubernetesJobData{}
+	if err := json.Unmarshal(data, jobData); err != nil {
+		return nil, fmt.Errorf("unmarshaling job data: %w", err)
+	}
+	kj.Namespace = namespace
+	kj.Name = name
+	return jobData, nil
+}
+
+// BuildPredicate builds a predicate from the run data
+func (kj *KubernetesJob) BuildPredicate(
+	_ context.Context, r *run.Run, draft *attestation.SLSAPredicate,
+) (predicate *attestation.SLSAPredicate, err error) {
+	namespace, name, err := parseKubernetesURL(r.SpecURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	data, ok := r.SystemData.(*kubernetesJobData)
+	if !ok {
+		return nil, errors.New("run has no Kubernetes job data")
+	}
+	if draft == nil {
+		pred := attestation.NewSLSAPredicate()
+		predicate = &pred
+	} else {
+		predicate = draft
+	}
+	predicate.Builder.ID = fmt.Sprintf("%s/apis/batch/v1/namespaces/%s/jobs", strings.TrimSuffix(data.Host, "/"), namespace)
+	predicate.BuildType = "https://kubernetes.io/docs/concepts/workloads/controllers/job/"
+
+	annotations := data.Job.Annotations
+	if source := annotations[K8SSourceAnnotation]; source != "" {
+		predicate.Invocation.ConfigSource.URI = source
+		predicate.Invocation.ConfigSource.EntryPoint = annotations[K8SEntryPointAnnotation]
+		if rev := annotations[K8SRevisionAnnotation]; rev != "" {
+			predicate.Invocation.ConfigSource.Digest = common.DigestSet{"sha1": rev}
+			predicate.AddMaterial(source, common.DigestSet{"sha1": rev})
+		}
+	}
+
+	containers := []map[string]interface{}{}
+	for _, s := range r.Steps {
+		containers = append(containers, map[string]interface{}{
+			"image":   s.Image,
+			"command": s.Command,
+			"args":    s.Params,
+			"env":     s.Environment,
+		})
+		if ref, digest, ok := strings.Cut(s.Image, "@"); ok {
+			algo, value, _ := strings.Cut(digest, ":")
+			predicate.AddMaterial("oci://"+ref, common.DigestSet{algo: value})
+		}
+	}
+	predicate.Invocation.Parameters = map[string]interface{}{"containers": containers}
+	predicate.Invocation.Environment = map[string]string{
+		"namespace": namespace,
+		"job":       name,
+		"uid":       string(data.Job.UID),
+	}
+	return predicate, nil
+}
+
+// Completeness reports the container specs as complete parameters unless
+// they read variables from secrets or config maps, which are not
+// recorded. Materials are complete when the job is annotated with its
+// source revision and every container image is pinned by digest.
+func (kj *KubernetesJob) Completeness(r *run.Run) (complete slsa.ProvenanceComplete, reproducible bool) {
+	data, ok := r.SystemData.(*kubernetesJobData)
+	if !ok {
+		return complete, false
+	}
+	complete.Parameters = true
+	spec := data.Job.Spec.Template.Spec
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		if len(c.EnvFrom) > 0 {
+			complete.Parameters = false
+		}
+		for _, e := range c.Env {
+			if e.ValueFrom != nil {
+				complete.Parameters = false
+			}
+		}
+	}
+	complete.Materials = data.Job.Annotations[K8SSourceAnnotation] != "" &&
+		data.Job.Annotations[K8SRevisionAnnotation] != ""
+	for _, s := range r.Steps {
+		if !strings.Contains(s.Image, "@") {
+			complete.Materials = false
+		}
+	}
+	return complete, false
+}
+
+// ArtifactStores returns the native artifact stores of the job,
+// jobs have no artifact storage
+func (kj *KubernetesJob) ArtifactStores() []store.Store {
+	return []store.Store{}
+}
+
+// Capabilities returns the features supported by the driver
+func (kj *KubernetesJob) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: false,
+		LiveStatus:      true,
+		Streaming:       false,
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestParseKubernetesURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec      string
+		namespace string
+		name      string
+		mustFail  bool
+	}{
+		{spec: "k8s://builds/release-42", namespace: "builds", name: "release-42"},
+		{spec: "k8s://builds/release-42/", namespace: "builds", name: "release-42"},
+		{spec: "k8s://builds/", mustFail: true},
+		{spec: "k8s://builds/jobs/release", mustFail: true},
+		{spec: "k8s:///release", mustFail: true},
+		{spec: "teamcity://builds/release", mustFail: true},
+	} {
+		namespace, name, err := parseKubernetesURL(tc.spec)
+		if tc.mustFail {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.namespace, namespace, tc.spec)
+		require.Equal(t, tc.name, name, tc.spec)
+	}
+}
+
+func TestImageWithDigest(t *testing.T) {
+	digest := "sha256:" + "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2"
+	for image, expected := range map[string]string{
+		"golang:1.22":                    "golang@" + digest,
+		"registry.local:5000/builder":    "registry.local:5000/builder@" + digest,
+		"registry.local:5000/builder:v1": "registry.local:5000/builder@" + digest,
+		"ghcr.io/org/builder@sha256:00":  "ghcr.io/org/builder@" + digest,
+	} {
+		require.Equal(t, expected, imageWithDigest(image, "docker.io/library/x@"+digest), image)
+	}
+	require.Equal(t, "golang:1.22", imageWithDigest("golang:1.22", "sha256:abc"))
+}
+
+func TestKubernetesRefreshRun(t *testing.T) {
+	start := metav1.NewTime(time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(5 * time.Minute))
+	digest := "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "release-42", Namespace: "builds", UID: "1234",
+			Annotations: map[string]string{
+				K8SSourceAnnotation:   "git+https://github.com/org/repo",
+				K8SRevisionAnnotation: "abcdef",
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "1234"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "build", Image: "golang:1.22", Command: []string{"make"}, Args: []string{"release"},
+				Env: []corev1.EnvVar{
+					{Name: "VERSION", Value: "1.0.0"},
+					{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "token"}}},
+				},
+			}}}},
+		},
+		Status: batchv1.JobStatus{
+			StartTime: &start, CompletionTime: &end,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: end}},
+		},
+	}
+	pods := corev1.PodList{Items: []corev1.Pod{{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "build", ImageID: "docker.io/library/golang@" + digest},
+	}}}}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/apis/batch/v1/namespaces/builds/jobs/release-42":
+			require.NoError(t, json.NewEncoder(w).Encode(job))
+		case "/api/v1/namespaces/builds/pods":
+			require.Equal(t, "controller-uid=1234", r.URL.Query().Get("labelSelector"))
+			require.NoError(t, json.NewEncoder(w).Encode(pods))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	kj := &KubernetesJob{config: &rest.Config{Host: srv.URL}}
+	r, err := kj.GetRun(context.Background(), "k8s://builds/release-42")
+	require.NoError(t, err)
+	require.False(t, r.IsRunning)
+	require.True(t, r.IsSuccess)
+	require.Equal(t, end.UTC(), r.EndTime)
+	require.Len(t, r.Steps, 1)
+	require.Equal(t, "golang@"+digest, r.Steps[0].Image)
+	require.Equal(t, map[string]string{"VERSION": "1.0.0"}, r.Steps[0].Environment)
+
+	pred, err := kj.BuildPredicate(context.Background(), r, nil)
+	require.NoError(t, err)
+	require.Equal(t, srv.URL+"/apis/batch/v1/namespaces/builds/jobs", pred.Builder.ID)
+	require.Equal(t, "git+https://github.com/org/repo", pred.Invocation.ConfigSource.URI)
+	require.Equal(t, "abcdef", pred.Invocation.ConfigSource.Digest["sha1"])
+	require.Len(t, pred.Materials, 2)
+	require.Equal(t, "oci://golang", pred.Materials[1].URI)
+
+	// The token read from a secret is not recorded
+	complete, _ := kj.Completeness(r)
+	require.False(t, complete.Parameters)
+	require.True(t, complete.Materials)
+	r.Steps[0].Image = "golang:1.22"
+	complete, _ = kj.Completeness(r)
+	require.False(t, complete.Materials)
+
+	_, err = kj.GetRun(context.Background(), "k8s://builds/missing")
+	require.ErrorIs(t, err, ErrRunNotFound)
+}
+
+func TestKubernetesAnnotateRun(t *testing.T) {
+	var patch map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPatch, r.Method)
+		require.Equal(t, "/apis/batch/v1/namespaces/builds/jobs/release-42", r.URL.Path)
+		require.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&patch))
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(batchv1.Job{}))
+	}))
+	defer srv.Close()
+
+	kj := &KubernetesJob{config: &rest.Config{Host: srv.URL}}
+	r := &run.Run{SpecURL: "k8s://builds/release-42"}
+	require.NoError(t, kj.AnnotateRun(context.Background(), r, map[string]string{"chains.tekton.dev/signed": "true"}))
+	require.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"chains.tekton.dev/signed": "true"},
+		},
+	}, patch)
+
+	readonly.Enable()
+	defer readonly.Disable()
+	require.ErrorIs(t, kj.AnnotateRun(context.Background(), r, nil), readonly.ErrReadOnly)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// defaultRetryAfter is the time to wait when the build system
+// throttles us but does not say for how long
+const defaultRetryAfter = 30 * time.Second
+
+// ErrRunNotFound is returned by the drivers when the build system does
+// not know the run. Runs that are still queued may not exist yet.
+var ErrRunNotFound = errors.New("run not found in the build system")
+
+// errNotFound is returned by the API helpers of the drivers when the
+// resource requested does not exist
+var errNotFound = errors.New("resource not found")
+
+// RetryAfterError is returned by the drivers when the build system
+// API throttled the request. Callers polling the run should wait
+// RetryAfter before trying again instead of failing.
+type RetryAfterError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("build system asked to retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// notFoundFromGoogleAPI returns an error wrapping ErrRunNotFound if err
+// is a not found error from a Google API
+func notFoundFromGoogleAPI(err error) error {
+	gErr := &googleapi.Error{}
+	if !errors.As(err, &gErr) || gErr.Code != http.StatusNotFound {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+}
+
+// retryAfterFromGoogleAPI returns a RetryAfterError if err is a
+// throttling error from a Google API
+func retryAfterFromGoogleAPI(err error) error {
+	gErr := &googleapi.Error{}
+	if !errors.As(err, &gErr) || gErr.Code != http.StatusTooManyRequests {
+		return nil
+	}
+	wait := defaultRetryAfter
+	if secs, err := strconv.Atoi(gErr.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(secs) * time.Second
+	}
+	return &RetryAfterError{RetryAfter: wait, Err: err}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+const TEAMCITY = "teamcity"
+
+// teamCityTimeFormat is the layout of the dates in the REST API
+const teamCityTimeFormat = "20060102T150405-0700"
+
+// TeamCityBuild is a driver to attest builds of a TeamCity server.
+// Requests are authenticated with the access token in TEAMCITY_TOKEN.
+type TeamCityBuild struct {
+	Host    string // Hostname of the TeamCity server
+	BuildID int64
+
+	apiBase string // Overrides the URL of the server, used in tests
+}
+
+// teamCityProperties is a list of name/value pairs
+type teamCityProperties struct {
+	Property []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"property"`
+}
+
+// teamCityRevision is a VCS revision built
+type teamCityRevision struct {
+	Version     string `json:"version"`
+	Branch      string `json:"vcsBranchName"`
+	VCSInstance struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"vcs-root-instance"`
+	URL string `json:"url,omitempty"` // Repository URL, read from the VCS root
+}
+
+// teamCityBuildData is the data of the build stored in the run
+type teamCityBuildData struct {
+	ID          int64              `json:"id"`
+	BuildTypeID string             `json:"buildTypeId"`
+	Number      string             `json:"number"`
+	Status      string             `json:"status"`
+	State       string             `json:"state"`
+	BranchName  string             `json:"branchName"`
+	WebURL      string             `json:"webUrl"`
+	StartDate   string             `json:"startDate"`
+	FinishDate  string             `json:"finishDate"`
+	Properties  teamCityProperties `json:"properties"`
+	// CanceledInfo is only set on canceled builds
+	CanceledInfo *struct {
+		Text string `json:"text"`
+	} `json:"canceledInfo,omitempty"`
+	Revisions struct {
+		Revision []teamCityRevision `json:"revision"`
+	} `json:"revisions"`
+}
+
+// parseTeamCityURL parses a teamcity build spec URL:
+// teamcity://teamcity.example.com/12345
+func parseTeamCityURL(specURL string) (host string, buildID int64, err error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return host, buildID, fmt.Errorf("parsing spec url: %w", err)
+	}
+	if u.Scheme != TEAMCITY {
+		return host, buildID, errors.New("URL is not a teamcity URL")
+	}
+	if u.Host == "" {
+		return host, buildID, errors.New("spec URL does not include the TeamCity host")
+	}
+	buildID, err = strconv.ParseInt(strings.Trim(u.Path, "/"), 10, 64)
+	if err != nil {
+		return host, buildID, fmt.Errorf("parsing build ID from URL: %w", err)
+	}
+	return u.Host, buildID, nil
+}
+
+func (tc *TeamCityBuild) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
+	r := &run.Run{
+		SpecURL:   specURL,
+		IsSuccess: false,
+		Steps:     []run.Step{},
+		Artifacts: []run.Artifact{},
+		StartTime: time.Time{},
+		EndTime:   time.Time{},
+	}
+	if err := tc.RefreshRun(ctx, r); err != nil {
+		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
+	}
+	return r, nil
+}
+
+// getJSON fetches a path of the REST API and unmarshals it
+func (tc *TeamCityBuild) getJSON(ctx context.Context, path string, data interface{}) error {
+	base := tc.apiBase
+	if base == "" {
+		base = "https://" + tc.Host
+	}
+	apiURL := base + "/app/rest/" + path
+	logrus.Infof("TeamCityAPI: %s", apiURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if token := os.Getenv("TEAMCITY_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		logrus.Warn("making unauthenticated request to teamcity")
+	}
+	res, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("executing http request to TeamCity API: %w", err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errNotFound, apiURL)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.New("access denied by the TeamCity API, check TEAMCITY_TOKEN")
+	default:
+		return fmt.Errorf("http error %d making request to TeamCity API", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(data); err != nil {
+		return fmt.Errorf("unmarshalling TeamCity response: %w", err)
+	}
+	return nil
+}
+
+// RefreshRun queries the TeamCity API to get the latest build data
+func (tc *TeamCityBuild) RefreshRun(ctx context.Context, r *run.Run) error {
+	host, buildID, err := parseTeamCityURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing spec url: %w", err)
+	}
+	tc.Host = host
+	tc.BuildID = buildID
+
+	data := &teamCityBuildData{}
+	if err := tc.getJSON(ctx, fmt.Sprintf("builds/id:%d", buildID), data); err != nil {
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+		}
+		return fmt.Errorf("fetching build: %w", err)
+	}
+
+	// The repository URLs are only listed in the VCS root properties
+	for i, rev := range data.Revisions.Revision {
+		if rev.VCSInstance.ID == "" {
+			continue
+		}
+		root := struct {
+			Properties teamCityProperties `json:"properties"`
+		}{}
+		if err := tc.getJSON(ctx, "vcs-root-instances/id:"+url.PathEscape(rev.VCSInstance.ID), &root); err != nil {
+			return fmt.Errorf("fetching VCS root %s: %w", rev.VCSInstance.Name, err)
+		}
+		for _, p := range root.Properties.Property {
+			if p.Name == "url" {
+				data.Revisions.Revision[i].URL = p.Value
+			}
+		}
+	}
+
+	r.IsRunning = data.State != "finished"
+	r.IsSuccess = data.State == "finished" && data.Status == "SUCCESS"
+	if data.CanceledInfo != nil {
+		r.Outcome = run.OutcomeCancelled
+	}
+	if t, err := time.Parse(teamCityTimeFormat, data.StartDate); err == nil {
+		r.StartTime = t.UTC()
+	}
+	if t, err := time.Parse(teamCityTimeFormat, data.FinishDate); err == nil {
+		r.EndTime = t.UTC()
+	}
+
+	r.Params = []string{}
+	for _, p := range data.Properties.Property {
+		r.Params = append(r.Params, fmt.Sprintf("%s=%s", p.Name, p.Value))
+	}
+
+	// The REST API does not report the build steps, the build is
+	// recorded as a single step
+	r.Steps = []run.Step{{
+		Command:   data.BuildTypeID,
+		IsSuccess: r.IsSuccess,
+		Params:    r.Params,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+	}}
+
+	logrus.Debugf("Build %d state: %s, status: %s", buildID, data.State, data.Status)
+	r.SystemData = data
+	return nil
+}
+
+// DecodeSystemData decodes the build data of a captured run
+func (tc *TeamCityBuild) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	host, buildID, err := parseTeamCityURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	buildData := &teamCityBuildData{}
+	if err := json.Unmarshal(data, buildData); err != nil {
+		return nil, fmt.Errorf("unmarshaling build data: %w", err)
+	}
+	tc.Host = host
+	tc.BuildID = buildID
+	return buildData, nil
+}
+
+// BuildPredicate builds a predicate from the run data
+func (tc *TeamCityBuild) BuildPredicate(
+	_ context.Context, r *run.Run, draft *attestation.SLSAPredicate,
+) (predicate *attestation.SLSAPredicate, err error) {
+	host, buildID, err := parseTeamCityURL(r.SpecURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	data, ok := r.SystemData.(*teamCityBuildData)
+	if !ok {
+		return nil, errors.New("run has no TeamCity build data")
+	}
+	if draft == nil {
+		pred := attestation.NewSLSAPredicate()
+		predicate = &pred
+	} else {
+		predicate = draft
+	}
+	predicate.Builder.ID = fmt.Sprintf("https://%s/buildConfiguration/%s", host, data.BuildTypeID)
+	predicate.BuildType = "https://www.jetbrains.com/help/teamcity/build-configuration.html"
+
+	params := map[string]string{}
+	for _, p := range data.Properties.Property {
+		params[p.Name] = p.Value
+	}
+	predicate.Invocation.Parameters = params
+	predicate.Invocation.Environment = map[string]string{
+		"BUILD_ID":     fmt.Sprintf("%d", buildID),
+		"BUILD_NUMBER": data.Number,
+		"BUILD_TYPE":   data.BuildTypeID,
+		"BRANCH":       data.BranchName,
+	}
+
+	// The first revision is the configuration source, all of them
+	// are recorded as materials
+	for _, rev := range data.Revisions.Revision {
+		if rev.URL == "" {
+			continue
+		}
+		uri := "git+" + rev.URL
+		if predicate.Invocation.ConfigSource.URI == "" {
+			predicate.Invocation.ConfigSource.URI = uri
+			predicate.Invocation.ConfigSource.Digest = common.DigestSet{"sha1": rev.Version}
+		}
+		predicate.AddMaterial(uri, common.DigestSet{"sha1": rev.Version})
+	}
+	return predicate, nil
+}
+
+// ArtifactStores returns the native artifact stores of the build.
+// Reading TeamCity build artifacts is not supported yet.
+func (tc *TeamCityBuild) ArtifactStores() []store.Store {
+	return []store.Store{}
+}
+
+// Completeness reports the parameters as complete, all the properties of
+// the build are recorded. Artifact and snapshot dependencies of the build
+// are not recorded as materials.
+func (tc *TeamCityBuild) Completeness(r *run.Run) (complete slsa.ProvenanceComplete, reproducible bool) {
+	if _, ok := r.SystemData.(*teamCityBuildData); ok {
+		complete.Parameters = true
+	}
+	return complete, false
+}
+
+// Capabilities returns the features supported by the driver
+func (tc *TeamCityBuild) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: false,
+		LiveStatus:      true,
+		Streaming:       false,
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTeamCityURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec     string
+		host     string
+		buildID  int64
+		mustFail bool
+	}{
+		{spec: "teamcity://teamcity.example.com/12345", host: "teamcity.example.com", buildID: 12345},
+		{spec: "teamcity://teamcity.example.com:8111/7/", host: "teamcity.example.com:8111", buildID: 7},
+		{spec: "teamcity://teamcity.example.com/project/7", mustFail: true},
+		{spec: "teamcity://teamcity.example.com/", mustFail: true},
+		{spec: "teamcity:///12345", mustFail: true},
+		{spec: "woodpecker://teamcity.example.com/12345", mustFail: true},
+	} {
+		host, id, err := parseTeamCityURL(tc.spec)
+		if tc.mustFail {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.host, host, tc.spec)
+		require.Equal(t, tc.buildID, id, tc.spec)
+	}
+}
+
+func TestTeamCityRefreshRun(t *testing.T) {
+	t.Setenv("TEAMCITY_TOKEN", "secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/app/rest/builds/id:12345":
+			fmt.Fprint(w, `{"id": 12345, "buildTypeId": "Project_Release", "number": "101",
+				"status": "SUCCESS", "state": "finished", "branchName": "main",
+				"startDate": "20240102T150405+0000", "finishDate": "20240102T151005+0000",
+				"properties": {"property": [{"name": "env.VERSION", "value": "1.2.3"}]},
+				"revisions": {"revision": [
+					{"version": "abcdef", "vcsBranchName": "refs/heads/main", "vcs-root-instance": {"id": "10", "name": "app"}}
+				]}}`)
+		case "/app/rest/vcs-root-instances/id:10":
+			fmt.Fprint(w, `{"properties": {"property": [{"name": "url", "value": "https://git.example.com/app.git"}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tc := &TeamCityBuild{apiBase: srv.URL}
+	r, err := tc.GetRun(context.Background(), "teamcity://teamcity.example.com/12345")
+	require.NoError(t, err)
+	require.False(t, r.IsRunning)
+	require.True(t, r.IsSuccess)
+	require.Equal(t, int64(360), r.EndTime.Unix()-r.StartTime.Unix())
+	require.Equal(t, []string{"env.VERSION=1.2.3"}, r.Params)
+
+	pred, err := tc.BuildPredicate(context.Background(), r, nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://teamcity.example.com/buildConfiguration/Project_Release", pred.Builder.ID)
+	require.Equal(t, "git+https://git.example.com/app.git", pred.Invocation.ConfigSource.URI)
+	require.Equal(t, "abcdef", pred.Invocation.ConfigSource.Digest["sha1"])
+	require.Len(t, pred.Materials, 1)
+	require.Equal(t, map[string]string{"env.VERSION": "1.2.3"}, pred.Invocation.Parameters)
+
+	_, err = tc.GetRun(context.Background(), "teamcity://teamcity.example.com/1")
+	require.ErrorIs(t, err, ErrRunNotFound)
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+const WOODPECKER = "woodpecker"
+
+// WoodpeckerPipeline is a driver to attest pipelines of a Woodpecker
+// CI server. Requests are authenticated with the personal access token
+// in WOODPECKER_TOKEN.
+type WoodpeckerPipeline struct {
+	Host   string // Hostname of the Woodpecker server
+	Repo   string // Full name of the repository (org/repo)
+	Number int64  // Pipeline number in the repository
+
+	apiBase string // Overrides the API URL of the server, used in tests
+}
+
+// woodpeckerRepo is the repository returned by the lookup endpoint
+type woodpeckerRepo struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	CloneURL string `json:"clone_url"`
+}
+
+// woodpeckerStep is a step of a workflow
+type woodpeckerStep struct {
+	Name     string `json:"name"`
+	State    string `json:"state"`
+	ExitCode int    `json:"exit_code"`
+	Started  int64  `json:"start_time"`
+	Stopped  int64  `json:"end_time"`
+}
+
+// woodpeckerWorkflow is a workflow of a pipeline
+type woodpeckerWorkflow struct {
+	ID       int64            `json:"id"`
+	Name     string           `json:"name"`
+	State    string           `json:"state"`
+	Children []woodpeckerStep `json:"children"`
+}
+
+// woodpeckerPipelineData is the data of the pipeline stored in the run
+type woodpeckerPipelineData struct {
+	Repo     woodpeckerRepo `json:"repo"`
+	Pipeline struct {
+		ID        int64                `json:"id"`
+		Number    int64                `json:"number"`
+		Event     string               `json:"event"`
+		Status    string               `json:"status"`
+		Commit    string               `json:"commit"`
+		Branch    string               `json:"branch"`
+		Ref       string               `json:"ref"`
+		ForgeURL  string               `json:"forge_url"`
+		Started   int64                `json:"started_at"`
+		Finished  int64                `json:"finished_at"`
+		Workflows []woodpeckerWorkflow `json:"workflows"`
+	} `json:"pipeline"`
+}
+
+// parseWoodpeckerURL parses a woodpecker pipeline spec URL:
+// woodpecker://ci.example.com/org/repo/42
+func parseWoodpeckerURL(specURL string) (host, repo string, number int64, err error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return host, repo, number, fmt.Errorf("parsing spec url: %w", err)
+	}
+	if u.Scheme != WOODPECKER {
+		return host, repo, number, errors.New("URL is not a woodpecker URL")
+	}
+	if u.Host == "" {
+		return host, repo, number, errors.New("spec URL does not include the Woodpecker host")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return host, repo, number, errors.New("unable to parse org/repo/pipeline from spec url")
+	}
+	number, err = strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return host, repo, number, fmt.Errorf("parsing pipeline number from URL: %w", err)
+	}
+	return u.Host, parts[0] + "/" + parts[1], number, nil
+}
+
+func (wp *WoodpeckerPipeline) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
+	r := &run.Run{
+		SpecURL:   specURL,
+		IsSuccess: false,
+		Steps:     []run.Step{},
+		Artifacts: []run.Artifact{},
+		StartTime: time.Time{},
+		EndTime:   time.Time{},
+	}
+	if err := wp.RefreshRun(ctx, r); err != nil {
+		return nil, fmt.Errorf("doing initial refresh of run data: %w", err)
+	}
+	return r, nil
+}
+
+// getJSON fetches a path of the server API and unmarshals it
+func (wp *WoodpeckerPipeline) getJSON(ctx context.Context, path string, data interface{}) error {
+	base := wp.apiBase
+	if base == "" {
+		base = "https://" + wp.Host
+	}
+	apiURL := base + "/api/" + path
+	logrus.Infof("WoodpeckerAPI: %s", apiURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	if token := os.Getenv("WOODPECKER_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		logrus.Warn("making unauthenticated request to woodpecker")
+	}
+	res, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("executing http request to Woodpecker API: %w", err)
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errNotFound, apiURL)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.New("access denied by the Woodpecker API, check WOODPECKER_TOKEN")
+	default:
+		return fmt.Errorf("http error %d making request to Woodpecker API", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(data); err != nil {
+		return fmt.Errorf("unmarshalling Woodpecker response: %w", err)
+	}
+	return nil
+}
+
+// RefreshRun queries the Woodpecker API to get the latest pipeline data
+func (wp *WoodpeckerPipeline) RefreshRun(ctx context.Context, r *run.Run) error {
+	host, repo, number, err := parseWoodpeckerURL(r.SpecURL)
+	if err != nil {
+		return fmt.Errorf("parsing spec url: %w", err)
+	}
+	wp.Host = host
+	wp.Repo = repo
+	wp.Number = number
+
+	data := &woodpeckerPipelineData{}
+	if err := wp.getJSON(ctx, "repos/lookup/"+repo, &data.Repo); err != nil {
+		return fmt.Errorf("looking up repository: %w", err)
+	}
+	if err := wp.getJSON(
+		ctx, fmt.Sprintf("repos/%d/pipelines/%d", data.Repo.ID, number), &data.Pipeline,
+	); err != nil {
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %w", ErrRunNotFound, err)
+		}
+		return fmt.Errorf("fetching pipeline: %w", err)
+	}
+
+	switch data.Pipeline.Status {
+	case "created", "pending", "running", "blocked":
+		r.IsRunning = true
+	default:
+		r.IsRunning = false
+	}
+	r.IsSuccess = data.Pipeline.Status == "success"
+	if data.Pipeline.Status == "killed" {
+		r.Outcome = run.OutcomeCancelled
+	}
+	if data.Pipeline.Started != 0 {
+		r.StartTime = time.Unix(data.Pipeline.Started, 0).UTC()
+	}
+	if data.Pipeline.Finished != 0 {
+		r.EndTime = time.Unix(data.Pipeline.Finished, 0).UTC()
+	}
+
+	r.Steps = []run.Step{}
+	for _, wf := range data.Pipeline.Workflows {
+		for _, step := range wf.Children {
+			s := run.Step{
+				Command:   wf.Name + "/" + step.Name,
+				IsSuccess: step.State == "success",
+				Params:    []string{},
+			}
+			if step.Started != 0 {
+				s.StartTime = time.Unix(step.Started, 0).UTC()
+			}
+			if step.Stopped != 0 {
+				s.EndTime = time.Unix(step.Stopped, 0).UTC()
+			}
+			r.Steps = append(r.Steps, s)
+		}
+	}
+
+	logrus.Debugf("Pipeline %s#%d status: %s", repo, number, data.Pipeline.Status)
+	r.SystemData = data
+	return nil
+}
+
+// DecodeSystemData decodes the pipeline data of a captured run
+func (wp *WoodpeckerPipeline) DecodeSystemData(specURL string, data []byte) (interface{}, error) {
+	host, repo, number, err := parseWoodpeckerURL(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing spec url: %w", err)
+	}
+	pipelineData := &woodpeckerPipelineData{}
+	if err := json.Unmarshal(data, pipelineData); err != nil {
+		return nil, fmt.Errorf("unmarshaling pipeline data: %w", err)
+	}
+	wp.Host = host
+	wp.Repo = repo
+	wp.Number = number
+	return pipelineData, nil
+}
+
+// BuildPredicate builds a predicate from the run data
+func (wp *WoodpeckerPipeline) BuildPredicate(
+	_ context.Context, r *run.Run, draft *attestation.SLSAPredicate,
+) (predicate *attestation.SLSAPredicate, err error) {
+	host, repo, number, err := parseWoodpeckerURL(r.SpecURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	data, ok := r.SystemData.(*woodpeckerPipelineData)
+	if !ok {
+		return nil, errors.New("run has no Woodpecker pipeline data")
+	}
+	if draft == nil {
+		pred := attestation.NewSLSAPredicate()
+		predicate = &pred
+	} else {
+		predicate = draft
+	}
+	predicate.Builder.ID = fmt.Sprintf("https://%s/repos/%d", host, data.Repo.ID)
+	predicate.BuildType = "https://woodpecker-ci.org/docs/usage/pipeline-syntax"
+	predicate.Invocation.ConfigSource.Digest = common.DigestSet{
+		"sha1": data.Pipeline.Commit,
+	}
+	predicate.Invocation.ConfigSource.EntryPoint = ".woodpecker"
+	if data.Repo.CloneURL != "" {
+		predicate.Invocation.ConfigSource.URI = "git+" + data.Repo.CloneURL
+	}
+	predicate.Invocation.Environment = map[string]string{
+		"CI_REPO":            repo,
+		"CI_PIPELINE_NUMBER": fmt.Sprintf("%d", number),
+		"CI_PIPELINE_EVENT":  data.Pipeline.Event,
+		"CI_COMMIT_REF":      data.Pipeline.Ref,
+		"CI_COMMIT_BRANCH":   data.Pipeline.Branch,
+		"CI_FORGE_URL":       data.Pipeline.ForgeURL,
+	}
+	return predicate, nil
+}
+
+// ArtifactStores returns the native artifact stores of the pipeline.
+// Woodpecker does not keep build artifacts.
+func (wp *WoodpeckerPipeline) ArtifactStores() []store.Store {
+	return []store.Store{}
+}
+
+// Capabilities returns the features supported by the driver
+func (wp *WoodpeckerPipeline) Capabilities() Capabilities {
+	return Capabilities{
+		NativeArtifacts: false,
+		LiveStatus:      true,
+		Streaming:       false,
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWoodpeckerURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec     string
+		host     string
+		repo     string
+		number   int64
+		mustFail bool
+	}{
+		{spec: "woodpecker://ci.example.com/org/repo/42", host: "ci.example.com", repo: "org/repo", number: 42},
+		{spec: "woodpecker://ci.example.com:8000/org/repo/7/", host: "ci.example.com:8000", repo: "org/repo", number: 7},
+		{spec: "woodpecker://ci.example.com/org/repo", mustFail: true},
+		{spec: "woodpecker://ci.example.com/org/repo/latest", mustFail: true},
+		{spec: "woodpecker://ci.example.com/org//42", mustFail: true},
+		{spec: "woodpecker:///org/repo/42", mustFail: true},
+		{spec: "gitlab://ci.example.com/org/repo/42", mustFail: true},
+	} {
+		host, repo, number, err := parseWoodpeckerURL(tc.spec)
+		if tc.mustFail {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.host, host, tc.spec)
+		require.Equal(t, tc.repo, repo, tc.spec)
+		require.Equal(t, tc.number, number, tc.spec)
+	}
+}
+
+func TestWoodpeckerRefreshRun(t *testing.T) {
+	t.Setenv("WOODPECKER_TOKEN", "secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/repos/lookup/org/repo":
+			fmt.Fprint(w, `{"id": 5, "full_name": "org/repo", "clone_url": "https://git.example.com/org/repo.git"}`)
+		case "/api/repos/5/pipelines/42":
+			fmt.Fprint(w, `{"id": 900, "number": 42, "event": "tag", "status": "success",
+				"commit": "abcdef", "ref": "refs/tags/v1.0.0", "started_at": 1700000000, "finished_at": 1700000100,
+				"workflows": [{"id": 1, "name": "release", "state": "success", "children": [
+					{"name": "build", "state": "success", "start_time": 1700000001, "end_time": 1700000050},
+					{"name": "publish", "state": "failure", "exit_code": 1, "start_time": 1700000051, "end_time": 1700000099}
+				]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	wp := &WoodpeckerPipeline{apiBase: srv.URL}
+	r, err := wp.GetRun(context.Background(), "woodpecker://ci.example.com/org/repo/42")
+	require.NoError(t, err)
+	require.False(t, r.IsRunning)
+	require.True(t, r.IsSuccess)
+	require.Equal(t, int64(1700000100), r.EndTime.Unix())
+	require.Len(t, r.Steps, 2)
+	require.Equal(t, "release/build", r.Steps[0].Command)
+	require.False(t, r.Steps[1].IsSuccess)
+
+	pred, err := wp.BuildPredicate(context.Background(), r, nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://ci.example.com/repos/5", pred.Builder.ID)
+	require.Equal(t, "abcdef", pred.Invocation.ConfigSource.Digest["sha1"])
+	require.Equal(t, "git+https://git.example.com/org/repo.git", pred.Invocation.ConfigSource.URI)
+
+	// A missing repository is not a run that has yet to start
+	_, err = wp.GetRun(context.Background(), "woodpecker://ci.example.com/org/other/42")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrRunNotFound)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the tejolote configuration file. It can be written
+// in YAML or JSON.
+type Config struct {
+	// Annotators is the list of annotators run over each artifact
+	// before it is added as a subject to the attestation
+	Annotators []Annotator `json:"annotators,omitempty"`
+
+	// Hooks are commands run at points of the attestation lifecycle
+	Hooks []Hook `json:"hooks,omitempty"`
+
+	// Policies are checks evaluated over the attestation before it
+	// is signed. A failing policy aborts the attestation.
+	Policies []Policy `json:"policies,omitempty"`
+
+	// BuilderID is the URI recorded as the builder.id of the
+	// predicate, replacing the one set by the build system driver
+	BuilderID string `json:"builderId,omitempty"`
+
+	// BuildType is the URI recorded as the buildType of the
+	// predicate, replacing the one set by the build system driver
+	BuildType string `json:"buildType,omitempty"`
+
+	// Sigstore points signing to a private Sigstore deployment
+	// instead of the public good instance
+	Sigstore *Sigstore `json:"sigstore,omitempty"`
+}
+
+// Sigstore configures the Sigstore deployment used to sign. Empty
+// fields keep the values of the public good instance.
+type Sigstore struct {
+	// FulcioURL is the certificate authority issuing the signing
+	// certificates
+	FulcioURL string `json:"fulcioUrl,omitempty"`
+
+	// RekorURL is the transparency log recording the signatures
+	RekorURL string `json:"rekorUrl,omitempty"`
+
+	// OIDCIssuer and OIDCClientID are used to get the identity
+	// token exchanged for the certificate
+	OIDCIssuer   string `json:"oidcIssuer,omitempty"`
+	OIDCClientID string `json:"oidcClientId,omitempty"`
+
+	// TUFMirror is the TUF repository distributing the trust root
+	// of the deployment, TUFRoot the path to its initial root.json
+	TUFMirror string `json:"tufMirror,omitempty"`
+	TUFRoot   string `json:"tufRoot,omitempty"`
+}
+
+// Policy configures a policy check
+type Policy struct {
+	// Type is the kind of policy (wasm)
+	Type string `json:"type"`
+
+	// Name identifies the policy in logs and errors
+	Name string `json:"name,omitempty"`
+
+	// Options are settings specific to the policy type
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Hook phases
+const (
+	HookPreSnapshot  = "pre-snapshot"
+	HookPostSnapshot = "post-snapshot"
+	HookPreSign      = "pre-sign"
+)
+
+// Hook configures a command executed at one of the lifecycle phases.
+// The command receives a JSON document describing the run on its
+// standard input, a non-zero exit code aborts the attestation.
+type Hook struct {
+	// Phase is the point where the hook runs (pre-snapshot,
+	// post-snapshot, pre-sign)
+	Phase string `json:"phase"`
+
+	// Command is the executable and its arguments
+	Command []string `json:"command"`
+
+	// Timeout is the maximum time the command can run, as a
+	// duration string (eg 30s). Defaults to five minutes.
+	Timeout string `json:"timeout,omitempty"`
+
+	// Optional hooks only log a warning when they fail
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Annotator configures an artifact annotator
+type Annotator struct {
+	// Type is the kind of annotator (version, wheel, oci-labels)
+	Type string `json:"type"`
+
+	// Match is an optional regular expression. When set, only
+	// artifacts whose path matches it are annotated.
+	Match string `json:"match,omitempty"`
+
+	// Options are settings specific to the annotator type
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Load reads a configuration file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	conf := &Config{}
+	if err := yaml.UnmarshalStrict(data, conf); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	for i, h := range conf.Hooks {
+		switch h.Phase {
+		case HookPreSnapshot, HookPostSnapshot, HookPreSign:
+		default:
+			return nil, fmt.Errorf("hook #%d has an unknown phase %q", i, h.Phase)
+		}
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("hook #%d has no command", i)
+		}
+		if h.Timeout != "" {
+			if _, err := time.ParseDuration(h.Timeout); err != nil {
+				return nil, fmt.Errorf("parsing timeout of hook #%d: %w", i, err)
+			}
+		}
+	}
+	for i, p := range conf.Policies {
+		if p.Type == "" {
+			return nil, fmt.Errorf("policy #%d has no type", i)
+		}
+	}
+	if err := ValidateURI(conf.BuilderID); err != nil {
+		return nil, fmt.Errorf("invalid builderId: %w", err)
+	}
+	if err := ValidateURI(conf.BuildType); err != nil {
+		return nil, fmt.Errorf("invalid buildType: %w", err)
+	}
+	if s := conf.Sigstore; s != nil {
+		for name, u := range map[string]string{
+			"fulcioUrl": s.FulcioURL, "rekorUrl": s.RekorURL, "oidcIssuer": s.OIDCIssuer, "tufMirror": s.TUFMirror,
+		} {
+			if err := ValidateURI(u); err != nil {
+				return nil, fmt.Errorf("invalid sigstore %s: %w", name, err)
+			}
+		}
+	}
+	return conf, nil
+}
+
+// ValidateURI checks that a builder ID or build type is an absolute URI.
+// Empty values are valid, they leave the driver value in place.
+func ValidateURI(value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", value, err)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("%q is not an absolute URI", value)
+	}
+	return nil
+}
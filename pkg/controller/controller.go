@@ -0,0 +1,296 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller implements an in-cluster provenance service.
+// Users create BuildObservation resources pointing at a build run and
+// its artifact stores, the controller snapshots the stores, waits for
+// the run to finish and stores its attestation.
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+// AttestationKey is the key of the attestation in the ConfigMaps and
+// Secrets written by the controller
+const AttestationKey = "attestation.intoto.json"
+
+// SnapshotFunc snapshots the artifact stores of an observation before
+// its build writes to them, saving the snapshot state to statePath
+type SnapshotFunc func(ctx context.Context, obs *BuildObservation, statePath string) error
+
+// AttestFunc waits for the build of an observation to finish and
+// returns its attestation. The snapshot state is read from statePath
+// if it exists.
+type AttestFunc func(ctx context.Context, obs *BuildObservation, statePath string) ([]byte, error)
+
+// Options configure the controller
+type Options struct {
+	Namespace   string        // Namespace to watch, all namespaces when empty
+	Interval    time.Duration // Time between listings of the observations
+	StateDir    string        // Directory to keep the snapshot states
+	Concurrency int           // Maximum number of builds observed at the same time
+}
+
+// Controller reconciles BuildObservation resources
+type Controller struct {
+	Options  Options
+	Snapshot SnapshotFunc
+	Attest   AttestFunc
+
+	dynamic  dynamic.Interface
+	kube     kubernetes.Interface
+	mu       sync.Mutex
+	inFlight map[types.UID]struct{}
+	slots    chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New returns a controller talking to the cluster in config
+func New(config *rest.Config, opts Options, snapshot SnapshotFunc, attest AttestFunc) (*Controller, error) {
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes client: %w", err)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Interval == 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.StateDir == "" {
+		opts.StateDir = os.TempDir()
+	}
+	return &Controller{
+		Options:  opts,
+		Snapshot: snapshot,
+		Attest:   attest,
+		dynamic:  dyn,
+		kube:     kube,
+		inFlight: map[types.UID]struct{}{},
+		slots:    make(chan struct{}, opts.Concurrency),
+	}, nil
+}
+
+// Run reconciles the observations until the context is canceled and
+// waits for the observations in progress to stop
+func (c *Controller) Run(ctx context.Context) error {
+	logrus.Infof("Watching build observations every %s", c.Options.Interval)
+	defer c.wg.Wait()
+	for {
+		if err := c.Reconcile(ctx); err != nil {
+			logrus.Errorf("Reconciling build observations: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.Options.Interval):
+		}
+	}
+}
+
+// Reconcile lists the observations and starts processing those not
+// finished nor already in progress
+func (c *Controller) Reconcile(ctx context.Context) error {
+	list, err := c.dynamic.Resource(Resource).Namespace(c.Options.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing build observations: %w", err)
+	}
+	for i := range list.Items {
+		obs := &BuildObservation{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, obs); err != nil {
+			logrus.Errorf("Decoding build observation %s: %v", list.Items[i].GetName(), err)
+			continue
+		}
+		if obs.Status.Phase == PhaseAttested || obs.Status.Phase == PhaseFailed {
+			continue
+		}
+		c.mu.Lock()
+		_, busy := c.inFlight[obs.UID]
+		if !busy {
+			c.inFlight[obs.UID] = struct{}{}
+		}
+		c.mu.Unlock()
+		if busy {
+			continue
+		}
+		c.wg.Add(1)
+		go c.process(ctx, obs)
+	}
+	return nil
+}
+
+// process takes an observation through its phases
+func (c *Controller) process(ctx context.Context, obs *BuildObservation) {
+	defer c.wg.Done()
+	defer func() {
+		c.mu.Lock()
+		delete(c.inFlight, obs.UID)
+		c.mu.Unlock()
+	}()
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return
+	}
+
+	id := obs.Namespace + "/" + obs.Name
+	statePath := filepath.Join(c.Options.StateDir, string(obs.UID)+".storage-snap.json")
+	err := c.observe(ctx, obs, statePath)
+	if ctx.Err() != nil {
+		// The observation is resumed when the controller restarts
+		return
+	}
+	os.Remove(statePath) //nolint: errcheck
+	if err != nil {
+		logrus.Errorf("Observing %s: %v", id, err)
+		obs.Status.Phase = PhaseFailed
+		obs.Status.Message = err.Error()
+	} else {
+		logrus.Infof("Attestation of %s stored in %s", id, obs.Status.Attestation)
+		obs.Status.Phase = PhaseAttested
+		obs.Status.Message = ""
+	}
+	now := metav1.Now()
+	obs.Status.CompletionTime = &now
+	if err := c.updateStatus(ctx, obs); err != nil {
+		logrus.Errorf("Updating status of %s: %v", id, err)
+	}
+}
+
+// observe snapshots the stores of a new observation and attests its run
+func (c *Controller) observe(ctx context.Context, obs *BuildObservation, statePath string) error {
+	if obs.Spec.Run == "" {
+		return errors.New("observation has no run spec URL")
+	}
+	if obs.Status.Phase == PhasePending {
+		if err := c.Snapshot(ctx, obs, statePath); err != nil {
+			return fmt.Errorf("snapshotting artifact stores: %w", err)
+		}
+		now := metav1.Now()
+		obs.Status.Phase = PhaseObserving
+		obs.Status.StartTime = &now
+		if err := c.updateStatus(ctx, obs); err != nil {
+			return fmt.Errorf("updating status: %w", err)
+		}
+	} else if _, err := os.Stat(statePath); err != nil {
+		logrus.Warnf("No snapshot state for %s/%s, artifacts from before the build may be attested", obs.Namespace, obs.Name)
+	}
+
+	data, err := c.Attest(ctx, obs, statePath)
+	if err != nil {
+		return fmt.Errorf("attesting run: %w", err)
+	}
+	location, err := c.store(ctx, obs, data)
+	if err != nil {
+		return fmt.Errorf("storing attestation: %w", err)
+	}
+	obs.Status.Attestation = location
+	return nil
+}
+
+// store writes the attestation to the output of the observation and
+// returns its location
+func (c *Controller) store(ctx context.Context, obs *BuildObservation, data []byte) (string, error) {
+	out := obs.Spec.Output
+	if out.URL != "" {
+		if err := driver.UploadURL(ctx, out.URL, bytes.NewReader(data)); err != nil {
+			return "", fmt.Errorf("uploading attestation: %w", err)
+		}
+		return out.URL, nil
+	}
+
+	meta := metav1.ObjectMeta{
+		Namespace: obs.Namespace,
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: Resource.GroupVersion().String(),
+			Kind:       "BuildObservation",
+			Name:       obs.Name,
+			UID:        obs.UID,
+		}},
+	}
+	if out.Secret != "" {
+		meta.Name = out.Secret
+		secrets := c.kube.CoreV1().Secrets(obs.Namespace)
+		secret := &corev1.Secret{ObjectMeta: meta, Data: map[string][]byte{AttestationKey: data}}
+		_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+		if k8serrors.IsAlreadyExists(err) {
+			_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return "", fmt.Errorf("writing secret: %w", err)
+		}
+		return "secret/" + meta.Name, nil
+	}
+
+	meta.Name = out.ConfigMap
+	if meta.Name == "" {
+		meta.Name = obs.Name + "-attestation"
+	}
+	configMaps := c.kube.CoreV1().ConfigMaps(obs.Namespace)
+	cm := &corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{AttestationKey: string(data)}}
+	_, err := configMaps.Create(ctx, cm, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return "", fmt.Errorf("writing config map: %w", err)
+	}
+	return "configmap/" + meta.Name, nil
+}
+
+// updateStatus writes the status of the observation
+func (c *Controller) updateStatus(ctx context.Context, obs *BuildObservation) error {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obs)
+	if err != nil {
+		return fmt.Errorf("encoding observation: %w", err)
+	}
+	u := &unstructured.Unstructured{Object: obj}
+	u.SetAPIVersion(Resource.GroupVersion().String())
+	u.SetKind("BuildObservation")
+	updated, err := c.dynamic.Resource(Resource).Namespace(obs.Namespace).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	obs.ResourceVersion = updated.GetResourceVersion()
+	return nil
+}
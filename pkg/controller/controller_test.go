@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServer serves the observations and records the statuses and
+// config maps written by the controller
+type fakeAPIServer struct {
+	mu           sync.Mutex
+	observations []BuildObservation
+	statuses     []BuildObservationStatus
+	configMaps   map[string]corev1.ConfigMap
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	prefix := "/apis/tejolote.sigs.k8s.io/v1alpha1/namespaces/builds/buildobservations"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == prefix:
+		items := []map[string]interface{}{}
+		for _, obs := range f.observations {
+			data, _ := json.Marshal(obs)
+			item := map[string]interface{}{}
+			json.Unmarshal(data, &item) //nolint: errcheck
+			item["apiVersion"] = "tejolote.sigs.k8s.io/v1alpha1"
+			item["kind"] = "BuildObservation"
+			items = append(items, item)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{ //nolint: errcheck
+			"apiVersion": "tejolote.sigs.k8s.io/v1alpha1", "kind": "BuildObservationList",
+			"metadata": map[string]interface{}{}, "items": items,
+		})
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, prefix) && strings.HasSuffix(r.URL.Path, "/status"):
+		data, _ := io.ReadAll(r.Body)
+		obs := BuildObservation{}
+		json.Unmarshal(data, &obs) //nolint: errcheck
+		f.statuses = append(f.statuses, obs.Status)
+		for i := range f.observations {
+			if f.observations[i].Name == obs.Name {
+				f.observations[i].Status = obs.Status
+			}
+		}
+		w.Write(data) //nolint: errcheck
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/builds/configmaps":
+		data, _ := io.ReadAll(r.Body)
+		cm := corev1.ConfigMap{}
+		json.Unmarshal(data, &cm) //nolint: errcheck
+		f.configMaps[cm.Name] = cm
+		w.WriteHeader(http.StatusCreated)
+		w.Write(data) //nolint: errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestController(t *testing.T) {
+	api := &fakeAPIServer{
+		observations: []BuildObservation{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "builds", UID: "uid-1"},
+				Spec:       BuildObservationSpec{Run: "github://org/repo/1", Artifacts: []string{"file:///tmp"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "builds", UID: "uid-2"},
+				Spec:       BuildObservationSpec{Run: "github://org/repo/2"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "done", Namespace: "builds", UID: "uid-3"},
+				Spec:       BuildObservationSpec{Run: "github://org/repo/3"},
+				Status:     BuildObservationStatus{Phase: PhaseAttested},
+			},
+		},
+		configMaps: map[string]corev1.ConfigMap{},
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	stateDir := t.TempDir()
+	var mu sync.Mutex
+	snapshotted := []string{}
+	c, err := New(&rest.Config{Host: srv.URL}, Options{Namespace: "builds", StateDir: stateDir},
+		func(_ context.Context, obs *BuildObservation, statePath string) error {
+			mu.Lock()
+			snapshotted = append(snapshotted, obs.Name)
+			mu.Unlock()
+			return os.WriteFile(statePath, []byte("{}"), os.FileMode(0o644))
+		},
+		func(_ context.Context, obs *BuildObservation, statePath string) ([]byte, error) {
+			if _, err := os.Stat(statePath); err != nil {
+				return nil, err
+			}
+			if obs.Name == "broken" {
+				return nil, errors.New("build failed")
+			}
+			return []byte(`{"_type": "https://in-toto.io/Statement/v0.1"}`), nil
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Reconcile(context.Background()))
+	c.wg.Wait()
+
+	require.ElementsMatch(t, []string{"release", "broken"}, snapshotted)
+	phases := map[string]string{}
+	for _, obs := range api.observations {
+		phases[obs.Name] = obs.Status.Phase
+	}
+	require.Equal(t, map[string]string{"release": PhaseAttested, "broken": PhaseFailed, "done": PhaseAttested}, phases)
+	require.Equal(t, "configmap/release-attestation", api.observations[0].Status.Attestation)
+	require.Contains(t, api.observations[1].Status.Message, "build failed")
+
+	cm, ok := api.configMaps["release-attestation"]
+	require.True(t, ok)
+	require.Contains(t, cm.Data[AttestationKey], "in-toto")
+	require.Equal(t, "release", cm.OwnerReferences[0].Name)
+
+	// The snapshot states are removed once the observations finish
+	states, err := os.ReadDir(stateDir)
+	require.NoError(t, err)
+	require.Empty(t, states)
+
+	// Finished observations are not processed again
+	require.NoError(t, c.Reconcile(context.Background()))
+	c.wg.Wait()
+	require.Len(t, snapshotted, 2)
+	require.Len(t, api.statuses, 4)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Resource is the BuildObservation custom resource
+var Resource = schema.GroupVersionResource{
+	Group:    "tejolote.sigs.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "buildobservations",
+}
+
+// Observation phases
+const (
+	PhasePending   = ""          // The observation was just created
+	PhaseObserving = "Observing" // The stores were snapshotted, waiting for the build
+	PhaseAttested  = "Attested"  // The attestation was stored
+	PhaseFailed    = "Failed"    // The attestation could not be generated
+)
+
+// BuildObservation asks the controller to attest a build run
+type BuildObservation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BuildObservationSpec   `json:"spec"`
+	Status BuildObservationStatus `json:"status,omitempty"`
+}
+
+// BuildObservationSpec describes the build to observe
+type BuildObservationSpec struct {
+	// Run is the spec URL of the build run (eg github://org/repo/1234)
+	Run string `json:"run"`
+
+	// Artifacts are the spec URLs of the artifact stores to watch
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// Sign the attestation
+	Sign bool `json:"sign,omitempty"`
+
+	// Output is where the attestation is stored
+	Output BuildObservationOutput `json:"output,omitempty"`
+}
+
+// BuildObservationOutput is the destination of the attestation. When
+// none is set, it is stored in a ConfigMap named after the observation.
+type BuildObservationOutput struct {
+	// ConfigMap is the name of a ConfigMap to store the attestation in
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Secret is the name of a Secret to store the attestation in
+	Secret string `json:"secret,omitempty"`
+
+	// URL is a bucket location (gs:// or s3://) to upload it to
+	URL string `json:"url,omitempty"`
+}
+
+// BuildObservationStatus is the progress of the observation
+type BuildObservationStatus struct {
+	Phase          string       `json:"phase,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Attestation is the location where the attestation was stored
+	// (configmap/name, secret/name or the upload URL)
+	Attestation string `json:"attestation,omitempty"`
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package environment
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Redacted replaces the values of the variables that look like secrets
+const Redacted = "[REDACTED]"
+
+// secretNames matches the names of variables conventionally holding
+// secrets. Their values are always redacted.
+var secretNames = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|PRIVATE|API_?KEY|ACCESS_?KEY|AUTH)`)
+
+// secretValues match the formats of well known credentials
+var secretValues = []*regexp.Regexp{
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),
+	regexp.MustCompile(`github_pat_[A-Za-z0-9_]{22,}`),
+	regexp.MustCompile(`glpat-[A-Za-z0-9_-]{20,}`),
+	regexp.MustCompile(`(A3T[A-Z0-9]|AKIA|ASIA)[A-Z0-9]{16}`),
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),
+	regexp.MustCompile(`xox[abprs]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`),
+}
+
+// Options control which variables are captured
+type Options struct {
+	// Allow are the glob patterns of the names of the variables to
+	// record (eg RUNNER_*)
+	Allow []string
+
+	// Redact are additional regular expressions matching values
+	// to redact
+	Redact []string
+}
+
+// Environment describes the machine a build ran on
+type Environment struct {
+	Runner    Runner            `json:"runner"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// Runner is the platform and image of the build machine
+type Runner struct {
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	Image        string `json:"image,omitempty"`
+	ImageVersion string `json:"image_version,omitempty"`
+}
+
+// Capture reads the environment of the current process. The runner
+// platform and image are read from the variables set by CI runners
+// (eg RUNNER_OS, ImageOS and ImageVersion in GitHub Actions) falling
+// back to the platform tejolote runs on. Only the allowed variables
+// are recorded, values looking like secrets are redacted.
+func Capture(opts Options) (*Environment, error) {
+	for _, p := range opts.Allow {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid variable pattern %q: %w", p, err)
+		}
+	}
+	patterns := append([]*regexp.Regexp{}, secretValues...)
+	for _, r := range opts.Redact {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", r, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	env := &Environment{
+		Runner: Runner{
+			OS:           firstSet(os.Getenv("RUNNER_OS"), runtime.GOOS),
+			Arch:         firstSet(os.Getenv("RUNNER_ARCH"), runtime.GOARCH),
+			Image:        os.Getenv("ImageOS"),
+			ImageVersion: os.Getenv("ImageVersion"),
+		},
+		Variables: map[string]string{},
+	}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !allowed(name, opts.Allow) {
+			continue
+		}
+		env.Variables[name] = redact(name, value, patterns)
+	}
+	return env, nil
+}
+
+// allowed returns true if the name matches any of the patterns
+func allowed(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the value of a variable or Redacted if its name or
+// value look like a secret
+func redact(name, value string, patterns []*regexp.Regexp) string {
+	if value == "" {
+		return value
+	}
+	if secretNames.MatchString(name) {
+		return Redacted
+	}
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return Redacted
+		}
+	}
+	return value
+}
+
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
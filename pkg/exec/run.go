@@ -159,5 +159,24 @@ func (r *Run) Predicate() (*slsa.ProvenancePredicate, error) {
 		Materials: []common.ProvenanceMaterial{},
 	}
 
+	// Record the sources pinned by the repository besides its commit:
+	// Git LFS objects, submodules and vendored code
+	if invocation.ConfigSource.URI != "" {
+		repo, err := git.NewRepository(r.Environment.Directory)
+		if err != nil {
+			return nil, fmt.Errorf("opening build repo: %w", err)
+		}
+		materials, err := repo.SourceMaterials(invocation.ConfigSource.URI, true)
+		if err != nil {
+			return nil, fmt.Errorf("reading repository source materials: %w", err)
+		}
+		for _, m := range materials {
+			predicate.Materials = append(predicate.Materials, common.ProvenanceMaterial{
+				URI:    m.URI,
+				Digest: m.Digest,
+			})
+		}
+	}
+
 	return &predicate, nil
 }
@@ -26,6 +26,7 @@ import (
 	"sigs.k8s.io/release-utils/command"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/watcher"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 type RunnerImplementation interface {
@@ -112,10 +113,11 @@ func (ri *defaultRunnerImplementation) Snapshot(_ *Options, _ *[]watcher.Watcher
 func (ri *defaultRunnerImplementation) WriteAttestation(opts *Options, runner *Run) error {
 	path := opts.AttestationPath
 	if path == "" {
-		f, err := os.CreateTemp("", "provenance-*.json")
+		f, err := workdir.CreateTemp("provenance-*.json")
 		if err != nil {
 			return fmt.Errorf("creating temp file to write attestation: %w", err)
 		}
+		f.Close()
 		path = f.Name()
 		opts.Logger.Debugf("Writing attestation to temp file: %s", path)
 	}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/cloudbuild/v1"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+// NewStorageClient returns a Cloud Storage client using the configured
+// credentials for the scopes it will access (gs://bucket or project ID).
+// Its transport refuses writes in read-only mode.
+func NewStorageClient(ctx context.Context, scopes ...string) (*storage.Client, error) {
+	opts, err := ClientOptions(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := storageHTTPClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewClient(ctx, option.WithHTTPClient(hc))
+}
+
+// storageHTTPClient returns the authenticated http client used by the
+// storage clients on top of the read-only transport. The emulator in
+// STORAGE_EMULATOR_HOST is reached without credentials, as the storage
+// client does.
+func storageHTTPClient(ctx context.Context, opts []option.ClientOption) (*http.Client, error) {
+	base := readonly.Transport(nil)
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		return &http.Client{Transport: base}, nil
+	}
+	transport, err := htransport.NewTransport(ctx, base, append([]option.ClientOption{
+		option.WithScopes(storage.ScopeFullControl, cloudPlatformScope),
+	}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage transport: %w", err)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// NewCloudBuildService returns a Cloud Build API client using the
+// configured credentials for a project
+func NewCloudBuildService(ctx context.Context, projectID string) (*cloudbuild.Service, error) {
+	opts, err := ClientOptions(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return cloudbuild.NewService(ctx, opts...)
+}
+
+// NewPubSubClient returns a Pub/Sub client for a project using the
+// configured credentials
+func NewPubSubClient(ctx context.Context, projectID string) (*pubsub.Client, error) {
+	opts, err := ClientOptions(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return pubsub.NewClient(ctx, projectID, opts...)
+}
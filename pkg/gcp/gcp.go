@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gcp configures the credentials used by the Google Cloud
+// drivers (GCS, Cloud Build and Pub/Sub).
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+var (
+	mu              sync.Mutex
+	credentialsFile string
+	impersonateSA   string
+	delegates       []string
+	scopedSAs       = map[string]string{}
+	tokenSources    = map[string]oauth2.TokenSource{}
+)
+
+// SetCredentialsFile sets the credentials file to use instead of the
+// application default credentials. External account (workload identity
+// federation) configuration files are supported, eg the ones written by
+// google-github-actions/auth in GitHub Actions.
+func SetCredentialsFile(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	credentialsFile = path
+	tokenSources = map[string]oauth2.TokenSource{}
+}
+
+// SetImpersonateServiceAccount makes the clients impersonate a service
+// account. Delegates lists the service accounts in the delegation chain,
+// if any.
+func SetImpersonateServiceAccount(serviceAccount string, delegateChain []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	impersonateSA = serviceAccount
+	delegates = delegateChain
+	tokenSources = map[string]oauth2.TokenSource{}
+}
+
+// SetScopedServiceAccount makes the clients accessing a scope impersonate
+// a service account instead of the global one. The scope is a project ID
+// for Cloud Build and Pub/Sub or a bucket (gs://bucket) for Cloud Storage.
+// This lets observers run with a low privilege identity and impersonate a
+// read-only service account per project.
+func SetScopedServiceAccount(scope, serviceAccount string) {
+	mu.Lock()
+	defer mu.Unlock()
+	scopedSAs[scope] = serviceAccount
+}
+
+// ClientOptions returns the options to pass to the Google Cloud clients.
+// When nothing is configured, no options are returned and the clients
+// use the application default credentials. If a service account is set
+// for any of the scopes the client accesses, the first one is
+// impersonated instead of the global one.
+func ClientOptions(ctx context.Context, scopes ...string) ([]option.ClientOption, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	opts := []option.ClientOption{}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	serviceAccount := impersonateSA
+	for _, s := range scopes {
+		if sa, ok := scopedSAs[s]; ok {
+			serviceAccount = sa
+			break
+		}
+	}
+	if serviceAccount == "" {
+		return opts, nil
+	}
+
+	// Reuse the token sources so that all clients share the tokens
+	if _, ok := tokenSources[serviceAccount]; !ok {
+		ts, err := impersonate.CredentialsTokenSource(
+			// The token source outlives the context of the first client
+			context.WithoutCancel(ctx),
+			impersonate.CredentialsConfig{
+				TargetPrincipal: serviceAccount,
+				Delegates:       delegates,
+				Scopes:          []string{cloudPlatformScope},
+			}, opts...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("impersonating %s: %w", serviceAccount, err)
+		}
+		tokenSources[serviceAccount] = ts
+	}
+	return []option.ClientOption{option.WithTokenSource(tokenSources[serviceAccount])}, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientOptions(t *testing.T) {
+	defer SetCredentialsFile("")
+	defer SetImpersonateServiceAccount("", nil)
+
+	opts, err := ClientOptions(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	// Workload identity federation config as written by CI auth actions
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "type": "external_account",
+  "audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/github",
+  "subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+  "token_url": "https://sts.googleapis.com/v1/token",
+  "credential_source": {"file": "/var/run/token"}
+}`), os.FileMode(0o600)))
+	SetCredentialsFile(path)
+	opts, err = ClientOptions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	globalSA := "builder@project.iam.gserviceaccount.com"
+	SetImpersonateServiceAccount(globalSA, nil)
+	opts, err = ClientOptions(context.Background())
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+	require.NotNil(t, tokenSources[globalSA])
+
+	// The token source is reused by later clients
+	ts := tokenSources[globalSA]
+	_, err = ClientOptions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ts, tokenSources[globalSA])
+
+	// Scoped service accounts are impersonated when accessing their scope
+	scopedSA := "reader@other-project.iam.gserviceaccount.com"
+	SetScopedServiceAccount("other-project", scopedSA)
+	defer delete(scopedSAs, "other-project")
+	_, err = ClientOptions(context.Background(), "unrelated", "other-project")
+	require.NoError(t, err)
+	require.NotNil(t, tokenSources[scopedSA])
+	require.Len(t, tokenSources, 2)
+
+	_, err = ClientOptions(context.Background(), "gs://bucket")
+	require.NoError(t, err)
+	require.Len(t, tokenSources, 2)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+	// lfsPointerMaxSize is the largest size of a pointer file
+	lfsPointerMaxSize = 1024
+)
+
+// LFSObject is a file stored in Git LFS. The repository only tracks a
+// pointer to it, the OID is the sha256 digest of the real contents.
+type LFSObject struct {
+	Path string
+	OID  string
+	Size int64
+}
+
+// LFSObjects returns the Git LFS objects referenced by pointer files in
+// the tree of the commit at HEAD
+func (r *Repository) LFSObjects() ([]LFSObject, error) {
+	hash, err := r.repo.ResolveRevision("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("fetching commit at HEAD: %w", err)
+	}
+	commit, err := r.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("reading HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("reading commit tree: %w", err)
+	}
+
+	objects := []LFSObject{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Size > lfsPointerMaxSize || !f.Mode.IsFile() {
+			return nil
+		}
+		rc, err := f.Reader()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		defer rc.Close()
+		obj, err := parseLFSPointer(rc)
+		if err != nil {
+			if errors.Is(err, errNotLFSPointer) {
+				return nil
+			}
+			return fmt.Errorf("parsing LFS pointer %s: %w", f.Name, err)
+		}
+		obj.Path = f.Name
+		objects = append(objects, *obj)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking commit tree: %w", err)
+	}
+	return objects, nil
+}
+
+var errNotLFSPointer = errors.New("file is not a Git LFS pointer")
+
+// parseLFSPointer parses the contents of a pointer file
+func parseLFSPointer(r io.Reader) (*LFSObject, error) {
+	data, err := io.ReadAll(io.LimitReader(r, lfsPointerMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(lfsPointerVersion+"\n")) {
+		return nil, errNotLFSPointer
+	}
+	obj := &LFSObject{Size: -1}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		key, value, _ := strings.Cut(s.Text(), " ")
+		switch key {
+		case "oid":
+			algo, oid, ok := strings.Cut(value, ":")
+			if !ok || algo != "sha256" || len(oid) != 64 {
+				return nil, fmt.Errorf("unsupported object id %q", value)
+			}
+			obj.OID = oid
+		case "size":
+			obj.Size, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing object size: %w", err)
+			}
+		}
+	}
+	if obj.OID == "" || obj.Size < 0 {
+		return nil, errors.New("pointer has no object id or size")
+	}
+	return obj, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Material is a source input pinned by the repository
+type Material struct {
+	URI    string
+	Digest map[string]string
+}
+
+// SourceMaterials returns the source inputs the repository pins besides
+// its own commit: the Git LFS objects, the submodules and, when vendor is
+// true, the contents of the vendor directory. base is the URI of the
+// repository at the built commit (eg git+https://github.com/org/repo@sha),
+// files in the repository are recorded as base#path.
+func (r *Repository) SourceMaterials(base string, vendor bool) ([]Material, error) {
+	materials := []Material{}
+
+	// Pointer files do not pin the contents of Git LFS objects, record
+	// the objects themselves
+	objects, err := r.LFSObjects()
+	if err != nil {
+		return nil, fmt.Errorf("reading git lfs objects: %w", err)
+	}
+	for _, o := range objects {
+		materials = append(materials, Material{
+			URI:    base + "#" + o.Path,
+			Digest: map[string]string{"sha256": o.OID},
+		})
+	}
+
+	submodules, err := r.Submodules()
+	if err != nil {
+		return nil, fmt.Errorf("reading submodules: %w", err)
+	}
+	for _, s := range submodules {
+		uri := base + "#" + s.Path
+		if s.URL != "" && !strings.HasPrefix(s.URL, ".") {
+			uri = s.URL
+			if !strings.HasPrefix(uri, "git+") {
+				uri = "git+" + uri
+			}
+			uri += "@" + s.Commit
+		}
+		materials = append(materials, Material{
+			URI:    uri,
+			Digest: map[string]string{"sha1": s.Commit},
+		})
+	}
+
+	if vendor {
+		digest, err := r.VendorDigest()
+		if err != nil {
+			return nil, fmt.Errorf("hashing vendored sources: %w", err)
+		}
+		if digest != "" {
+			materials = append(materials, Material{
+				URI:    base + "#" + vendorDir,
+				Digest: map[string]string{"sha256": digest},
+			})
+		}
+	}
+	return materials, nil
+}
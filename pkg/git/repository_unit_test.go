@@ -19,8 +19,14 @@ package git
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/require"
 )
 
@@ -54,3 +60,141 @@ func TestSourceURL(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, url, "git+ssh://git@github.com/kubernetes-sigs/tejolote")
 }
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := strings.Repeat("a", 64)
+	for _, tc := range []struct {
+		name     string
+		data     string
+		notLFS   bool
+		mustErr  bool
+		expected *LFSObject
+	}{
+		{
+			name:     "pointer",
+			data:     "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n",
+			expected: &LFSObject{OID: oid, Size: 12345},
+		},
+		{
+			name:   "regular file",
+			data:   "hello world\n",
+			notLFS: true,
+		},
+		{
+			name:    "unsupported oid",
+			data:    "version https://git-lfs.github.com/spec/v1\noid md5:abc\nsize 1\n",
+			mustErr: true,
+		},
+		{
+			name:    "no size",
+			data:    "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n",
+			mustErr: true,
+		},
+	} {
+		obj, err := parseLFSPointer(strings.NewReader(tc.data))
+		if tc.notLFS {
+			require.ErrorIs(t, err, errNotLFSPointer, tc.name)
+			continue
+		}
+		if tc.mustErr {
+			require.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.expected, obj, tc.name)
+	}
+}
+
+func TestLFSObjects(t *testing.T) {
+	tmpdir := t.TempDir()
+	repo, err := gogit.PlainInit(tmpdir, false)
+	require.NoError(t, err)
+
+	oid := strings.Repeat("b", 64)
+	require.NoError(t, os.Mkdir(filepath.Join(tmpdir, "data"), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(tmpdir, "data", "model.bin"),
+		[]byte("version https://git-lfs.github.com/spec/v1\noid sha256:"+oid+"\nsize 2048\n"),
+		os.FileMode(0o644),
+	))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(tmpdir, "README.md"), []byte("# test\n"), os.FileMode(0o644),
+	))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.AddGlob("."))
+	_, err = wt.Commit("initial", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	r, err := NewRepository(tmpdir)
+	require.NoError(t, err)
+	objects, err := r.LFSObjects()
+	require.NoError(t, err)
+	require.Equal(t, []LFSObject{{Path: "data/model.bin", OID: oid, Size: 2048}}, objects)
+}
+
+func TestSourceMaterials(t *testing.T) {
+	tmpdir := t.TempDir()
+	repo, err := gogit.PlainInit(tmpdir, false)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, ".gitmodules"), []byte(`[submodule "third_party/lib"]
+	path = third_party/lib
+	url = https://github.com/example/lib.git
+`), os.FileMode(0o644)))
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpdir, "vendor", "example.com", "mod"), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(tmpdir, "vendor", "example.com", "mod", "mod.go"), []byte("package mod\n"), os.FileMode(0o644),
+	))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.AddGlob(".gitmodules"))
+
+	// Add the gitlink of the submodule directly to the index
+	submoduleCommit := strings.Repeat("c", 40)
+	idx, err := repo.Storer.Index()
+	require.NoError(t, err)
+	e := idx.Add("third_party/lib")
+	e.Mode = filemode.Submodule
+	e.Hash = plumbing.NewHash(submoduleCommit)
+	require.NoError(t, repo.Storer.SetIndex(idx))
+
+	_, err = wt.Commit("initial", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	r, err := NewRepository(tmpdir)
+	require.NoError(t, err)
+	submodules, err := r.Submodules()
+	require.NoError(t, err)
+	require.Equal(t, []Submodule{{
+		Path: "third_party/lib", URL: "https://github.com/example/lib.git", Commit: submoduleCommit,
+	}}, submodules)
+
+	base := "git+https://github.com/example/repo@" + strings.Repeat("a", 40)
+	materials, err := r.SourceMaterials(base, false)
+	require.NoError(t, err)
+	require.Equal(t, []Material{{
+		URI:    "git+https://github.com/example/lib.git@" + submoduleCommit,
+		Digest: map[string]string{"sha1": submoduleCommit},
+	}}, materials)
+
+	materials, err = r.SourceMaterials(base, true)
+	require.NoError(t, err)
+	require.Len(t, materials, 2)
+	require.Equal(t, base+"#vendor", materials[1].URI)
+	require.Len(t, materials[1].Digest["sha256"], 64)
+
+	// The vendor digest changes with the vendored contents
+	require.NoError(t, os.WriteFile(
+		filepath.Join(tmpdir, "vendor", "example.com", "mod", "mod.go"), []byte("package mod // changed\n"), os.FileMode(0o644),
+	))
+	digest, err := r.VendorDigest()
+	require.NoError(t, err)
+	require.NotEqual(t, materials[1].Digest["sha256"], digest)
+}
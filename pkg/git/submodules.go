@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"sigs.k8s.io/release-utils/hash"
+)
+
+// vendorDir is the directory holding vendored sources
+const vendorDir = "vendor"
+
+// Submodule is a git submodule pinned in the superproject tree
+type Submodule struct {
+	Path   string
+	URL    string
+	Commit string
+}
+
+// Submodules returns the submodules recorded in the tree of the commit
+// at HEAD and the commits they are pinned to. The URLs are read from
+// the .gitmodules file of the same commit.
+func (r *Repository) Submodules() ([]Submodule, error) {
+	head, err := r.repo.ResolveRevision("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("fetching commit at HEAD: %w", err)
+	}
+	commit, err := r.repo.CommitObject(*head)
+	if err != nil {
+		return nil, fmt.Errorf("reading HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("reading commit tree: %w", err)
+	}
+
+	urls := map[string]string{}
+	if f, err := tree.File(".gitmodules"); err == nil {
+		data, err := f.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("reading .gitmodules: %w", err)
+		}
+		modules := config.NewModules()
+		if err := modules.Unmarshal([]byte(data)); err != nil {
+			return nil, fmt.Errorf("parsing .gitmodules: %w", err)
+		}
+		for _, m := range modules.Submodules {
+			urls[m.Path] = m.URL
+		}
+	} else if !errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("looking for .gitmodules: %w", err)
+	}
+
+	submodules := []Submodule{}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walking commit tree: %w", err)
+		}
+		if entry.Mode != filemode.Submodule {
+			continue
+		}
+		submodules = append(submodules, Submodule{
+			Path:   name,
+			URL:    urls[name],
+			Commit: entry.Hash.String(),
+		})
+	}
+	return submodules, nil
+}
+
+// VendorDigest returns a sha256 digest of the vendor directory in the
+// working tree. The digest is computed over the sorted list of the
+// relative paths and sha256 digests of the files, so it only changes
+// when the vendored contents do. It returns an empty string when the
+// repository has no vendor directory.
+func (r *Repository) VendorDigest() (string, error) {
+	dir := filepath.Join(r.Options.CWD, vendorDir)
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("walking vendor directory: %w", err)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		sha, err := hash.SHA256ForFile(path)
+		if err != nil {
+			return "", fmt.Errorf("hashing %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(r.Options.CWD, path)
+		if err != nil {
+			return "", fmt.Errorf("relativizing %s: %w", path, err)
+		}
+		fmt.Fprintf(h, "%s  %s\n", sha, filepath.ToSlash(rel))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
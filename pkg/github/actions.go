@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ActionsRun describes the GitHub Actions workflow run tejolote is
+// executing in, read from the variables set by the runner
+type ActionsRun struct {
+	ServerURL  string
+	Repository string
+	RunID      string
+	RunAttempt string
+}
+
+// CurrentActionsRun returns the workflow run of the GitHub Actions job
+// tejolote is running in or nil when not running in GitHub Actions
+func CurrentActionsRun() *ActionsRun {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+	r := &ActionsRun{
+		ServerURL:  os.Getenv("GITHUB_SERVER_URL"),
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		RunID:      os.Getenv("GITHUB_RUN_ID"),
+		RunAttempt: os.Getenv("GITHUB_RUN_ATTEMPT"),
+	}
+	if r.Repository == "" || r.RunID == "" {
+		return nil
+	}
+	return r
+}
+
+// SpecURL returns the spec URL of the workflow run. Runs in GitHub
+// Enterprise Server instances include the server hostname.
+func (r *ActionsRun) SpecURL() string {
+	if r.ServerURL != "" {
+		if u, err := url.Parse(r.ServerURL); err == nil && u.Host != "" && u.Host != "github.com" {
+			return fmt.Sprintf("github://%s/%s/runs/%s", u.Host, r.Repository, r.RunID)
+		}
+	}
+	return fmt.Sprintf("github://%s/%s", r.Repository, r.RunID)
+}
+
+// WriteActionsOutputs appends the step outputs to the file in
+// GITHUB_OUTPUT. Values spanning several lines are written with a
+// random delimiter. It is a noop when the variable is not set.
+func WriteActionsOutputs(outputs map[string]string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		value := outputs[name]
+		if !strings.ContainsAny(value, "\r\n") {
+			fmt.Fprintf(&b, "%s=%s\n", name, value)
+			continue
+		}
+		rnd := make([]byte, 8)
+		if _, err := rand.Read(rnd); err != nil {
+			return fmt.Errorf("generating output delimiter: %w", err)
+		}
+		delimiter := "ghadelimiter_" + hex.EncodeToString(rnd)
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(0o644))
+	if err != nil {
+		return fmt.Errorf("opening GitHub Actions output file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("writing GitHub Actions outputs: %w", err)
+	}
+	return nil
+}
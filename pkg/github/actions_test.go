@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrentActionsRun(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_RUN_ID", "4213")
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	require.Nil(t, CurrentActionsRun())
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	r := CurrentActionsRun()
+	require.NotNil(t, r)
+	require.Equal(t, "github://org/repo/4213", r.SpecURL())
+
+	t.Setenv("GITHUB_SERVER_URL", "https://ghe.example.com")
+	require.Equal(t, "github://ghe.example.com/org/repo/runs/4213", CurrentActionsRun().SpecURL())
+
+	t.Setenv("GITHUB_RUN_ID", "")
+	require.Nil(t, CurrentActionsRun())
+}
+
+func TestWriteActionsOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", "")
+	require.NoError(t, WriteActionsOutputs(map[string]string{"a": "b"}))
+	require.NoFileExists(t, path)
+
+	t.Setenv("GITHUB_OUTPUT", path)
+	require.NoError(t, os.WriteFile(path, []byte("previous=1\n"), os.FileMode(0o644)))
+	require.NoError(t, WriteActionsOutputs(map[string]string{
+		"single": "value",
+		"multi":  "line1\nline2",
+	}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(
+		`^previous=1\nmulti<<(ghadelimiter_[0-9a-f]+)\nline1\nline2\n(ghadelimiter_[0-9a-f]+)\nsingle=value\n$`,
+	), string(data))
+}
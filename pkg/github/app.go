@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tokenRenewal is how long before their expiration installation
+// tokens are renewed
+const tokenRenewal = 5 * time.Minute
+
+// appAuth mints installation tokens of a GitHub App
+type appAuth struct {
+	appID          string
+	installationID int64
+	key            *rsa.PrivateKey
+
+	mu            sync.Mutex
+	installations map[string]int64 // Installation IDs by API base and repository
+	tokens        map[int64]installationToken
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// app is the GitHub App set with SetAppCredentials
+var app *appAuth
+
+// SetAppCredentials configures requests to authenticate as an
+// installation of a GitHub App instead of using GITHUB_TOKEN. If
+// installationID is zero, the installation is looked up from the
+// repository of each request, allowing a single app installed in
+// several organizations. keyPath is the PEM private key of the app.
+func SetAppCredentials(appID string, installationID int64, keyPath string) error {
+	if appID == "" {
+		return errors.New("GitHub App ID not set")
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("reading GitHub App private key: %w", err)
+	}
+	key, err := parseAppKey(data)
+	if err != nil {
+		return err
+	}
+	app = &appAuth{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		installations:  map[string]int64{},
+		tokens:         map[int64]installationToken{},
+	}
+	return nil
+}
+
+// parseAppKey reads the RSA private key of an app. GitHub issues keys
+// in PKCS#1, PKCS#8 is accepted too.
+func parseAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns a token authenticating as the app itself, valid for
+// nine minutes. The issue time is backdated to allow for clock drift.
+func (a *appAuth) jwt() (string, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshalling claims: %w", err)
+	}
+	payload := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(payload))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing app token: %w", err)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// appRequest makes a request to the API authenticated as the app
+func (a *appAuth) appRequest(ctx context.Context, method, url string, data interface{}) error {
+	token, err := a.jwt()
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("GitHubAPI[%s]: %s", method, url)
+	req, err := http.NewRequestWithContext(ctx, method, url, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return fmt.Errorf("executing http request to GitHub API: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("http error %d authenticating as GitHub App %s", res.StatusCode, a.appID)
+	}
+	if err := json.NewDecoder(res.Body).Decode(data); err != nil {
+		return fmt.Errorf("decoding GitHub App response: %w", err)
+	}
+	return nil
+}
+
+// installation returns the installation ID to use for a request URL
+func (a *appAuth) installation(ctx context.Context, url string) (int64, error) {
+	if a.installationID != 0 {
+		return a.installationID, nil
+	}
+	base, path, ok := strings.Cut(url, "/repos/")
+	parts := strings.SplitN(path, "/", 3)
+	if !ok || len(parts) < 2 {
+		return 0, fmt.Errorf("unable to find the GitHub App installation for %s, set the installation ID", url)
+	}
+	repo := base + "/repos/" + parts[0] + "/" + parts[1]
+	a.mu.Lock()
+	id, ok := a.installations[repo]
+	a.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	inst := struct {
+		ID int64 `json:"id"`
+	}{}
+	if err := a.appRequest(ctx, http.MethodGet, repo+"/installation", &inst); err != nil {
+		return 0, fmt.Errorf("looking up app installation of %s/%s: %w", parts[0], parts[1], err)
+	}
+	a.mu.Lock()
+	a.installations[repo] = inst.ID
+	a.mu.Unlock()
+	return inst.ID, nil
+}
+
+// token returns an installation token for a request URL, minting a
+// new one when the cached one is about to expire
+func (a *appAuth) token(ctx context.Context, url string) (string, error) {
+	id, err := a.installation(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	t, ok := a.tokens[id]
+	a.mu.Unlock()
+	if ok && time.Until(t.ExpiresAt) > tokenRenewal {
+		return t.Token, nil
+	}
+
+	base := APIURL()
+	if b, _, ok := strings.Cut(url, "/repos/"); ok {
+		base = b
+	}
+	t = installationToken{}
+	if err := a.appRequest(
+		ctx, http.MethodPost, fmt.Sprintf("%s/app/installations/%d/access_tokens", base, id), &t,
+	); err != nil {
+		return "", fmt.Errorf("minting installation token: %w", err)
+	}
+	logrus.Debugf("Minted GitHub App installation token valid until %s", t.ExpiresAt)
+	a.mu.Lock()
+	a.tokens[id] = t
+	a.mu.Unlock()
+	return t.Token, nil
+}
+
+// authorization returns the Authorization header for a request to the
+// API, empty if there are no credentials
+func authorization(ctx context.Context, url string) (string, error) {
+	if app != nil {
+		token, err := app.token(ctx, url)
+		if err != nil {
+			return "", fmt.Errorf("authenticating as GitHub App: %w", err)
+		}
+		return "token " + token, nil
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return "token " + token, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), os.FileMode(0o600)))
+
+	// checkJWT verifies the app token of a request
+	checkJWT := func(r *http.Request) bool {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(token, ".")
+		if !ok || len(parts) != 3 {
+			return false
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig) != nil {
+			return false
+		}
+		claims := map[string]interface{}{}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		return err == nil && json.Unmarshal(payload, &claims) == nil && claims["iss"] == "1234"
+	}
+
+	var minted atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/repo/installation":
+			if !checkJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"id": 7}`)
+		case "/app/installations/7/access_tokens":
+			if r.Method != http.MethodPost || !checkJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			minted.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "ghs_test", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case "/repos/org/repo/actions/runs/1":
+			if r.Header.Get("Authorization") != "token ghs_test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	require.Error(t, SetAppCredentials("1234", 0, filepath.Join(t.TempDir(), "missing.pem")))
+	require.NoError(t, SetAppCredentials("1234", 0, keyPath))
+	t.Cleanup(func() { app = nil })
+
+	for i := 0; i < 2; i++ {
+		res, err := APIGetRequest(context.Background(), srv.URL+"/repos/org/repo/actions/runs/1")
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	require.Equal(t, int32(1), minted.Load(), "installation token not reused")
+
+	// Without an installation ID, only repository URLs can be authenticated
+	_, err = APIGetRequest(context.Background(), srv.URL+"/rate_limit")
+	require.Error(t, err)
+}
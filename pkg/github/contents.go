@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const contentsURL = "%s/repos/%s/%s/contents/%s?ref=%s"
+
+// FileContents fetches a file from a repository at a git ref from the
+// API at apiBase. It returns the file data and its git blob sha.
+func FileContents(ctx context.Context, apiBase, owner, repo, path, ref string) (data []byte, blobSHA string, err error) {
+	escaped := []string{}
+	for _, p := range strings.Split(strings.Trim(path, "/"), "/") {
+		escaped = append(escaped, url.PathEscape(p))
+	}
+	res, err := APIGetRequest(ctx, fmt.Sprintf(
+		contentsURL, apiBase, owner, repo, strings.Join(escaped, "/"), url.QueryEscape(ref),
+	))
+	if err != nil {
+		return nil, "", fmt.Errorf("querying contents of %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	file := struct {
+		Type     string `json:"type"`
+		Encoding string `json:"encoding"`
+		Content  string `json:"content"`
+		SHA      string `json:"sha"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&file); err != nil {
+		return nil, "", fmt.Errorf("decoding contents data: %w", err)
+	}
+	if file.Type != "file" || file.Encoding != "base64" {
+		return nil, "", fmt.Errorf("%s is not a file with base64 content", path)
+	}
+	// The API wraps the base64 content in lines
+	data, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding file content: %w", err)
+	}
+	return data, file.SHA, nil
+}
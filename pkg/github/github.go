@@ -17,18 +17,72 @@ limitations under the License.
 package github
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/readonly"
 )
 
+// DefaultAPIURL is the base URL of the public GitHub API
+const DefaultAPIURL = "https://api.github.com"
+
+// apiURL is the API base URL set with SetAPIURL
+var apiURL string
+
+// ErrNotFound is returned when the API responds that a resource does not exist
+var ErrNotFound = errors.New("resource not found in the GitHub API")
+
+// SetAPIURL sets the base URL of the GitHub API, eg to talk to a
+// GitHub Enterprise Server instance (https://ghe.example.com/api/v3)
+func SetAPIURL(u string) {
+	apiURL = strings.TrimSuffix(u, "/")
+}
+
+// APIURL returns the base URL of the GitHub API. If not set with
+// SetAPIURL, it can be overridden with the GITHUB_API_URL environment
+// variable, the same one set by GitHub Actions runners.
+func APIURL() string {
+	if apiURL != "" {
+		return apiURL
+	}
+	if u := os.Getenv("GITHUB_API_URL"); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return DefaultAPIURL
+}
+
+// ServerAPIURL returns the API base URL of a GitHub server hostname.
+// GitHub Enterprise Server instances serve the API under /api/v3.
+func ServerAPIURL(host string) string {
+	if host == "" || host == "github.com" {
+		return APIURL()
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// ServerHost returns the hostname of the GitHub server serving the API
+// at apiBase: github.com for the public API and the hostname of GitHub
+// Enterprise Server instances.
+func ServerHost(apiBase string) string {
+	u, err := url.Parse(apiBase)
+	if err != nil || u.Host == "" || u.Host == "api.github.com" {
+		return "github.com"
+	}
+	return u.Host
+}
+
 // TokenScopes returns the scopes of token in the eviroment
-func TokenScopes() ([]string, error) {
-	res, err := APIGetRequest("https://api.github.com/repos/github/docs")
+func TokenScopes(ctx context.Context) ([]string, error) {
+	res, err := APIGetRequest(ctx, APIURL()+"/repos/github/docs")
 	if err != nil {
 		return nil, fmt.Errorf("making request to API: %w", err)
 	}
@@ -41,8 +95,8 @@ func TokenScopes() ([]string, error) {
 }
 
 // TokenHas returns a bool if the token in use has the scope passed
-func TokenHas(scope string) (bool, error) {
-	scopes, err := TokenScopes()
+func TokenHas(ctx context.Context, scope string) (bool, error) {
+	scopes, err := TokenScopes(ctx)
 	if err != nil {
 		return false, fmt.Errorf("reading scopes: %w", err)
 	}
@@ -54,40 +108,72 @@ func TokenHas(scope string) (bool, error) {
 	return false, nil
 }
 
-func APIGetRequest(url string) (*http.Response, error) {
+func APIGetRequest(ctx context.Context, url string) (*http.Response, error) {
+	res, _, err := APIGetRequestWithETag(ctx, url, "")
+	return res, err
+}
+
+// APIGetRequestWithETag performs a conditional GET request to the API. If
+// the etag is not empty and the resource has not changed, notModified is
+// true and the response is nil. Conditional requests answered with a 304
+// do not count against the API rate limit.
+func APIGetRequestWithETag(ctx context.Context, url, etag string) (res *http.Response, notModified bool, err error) {
 	logrus.Infof("GitHubAPI[GET]: %s", url)
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	client := readonly.NewClient()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating http request: %w", err)
+		return nil, false, fmt.Errorf("creating http request: %w", err)
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
-	if os.Getenv("GITHUB_TOKEN") != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN")))
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	auth, err := authorization(ctx, url)
+	if err != nil {
+		return nil, false, err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
 	} else {
 		logrus.Warn("making unauthenticated request to github")
 	}
-	res, err := client.Do(req)
+	res, err = client.Do(req)
 	if err != nil {
-		return res, fmt.Errorf("executing http request to GitHub API: %w", err)
+		return res, false, fmt.Errorf("executing http request to GitHub API: %w", err)
+	}
+	warnRateLimit(res)
+	if etag != "" && res.StatusCode == http.StatusNotModified {
+		res.Body.Close()
+		return nil, true, nil
 	}
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf(
+		res.Body.Close()
+		if rlErr := rateLimitFromResponse(res); rlErr != nil {
+			return nil, false, rlErr
+		}
+		if res.StatusCode == http.StatusNotFound {
+			return nil, false, fmt.Errorf("%w: %s", ErrNotFound, url)
+		}
+		return nil, false, fmt.Errorf(
 			"http error %d making request to GitHub API", res.StatusCode,
 		)
 	}
-	return res, nil
+	return res, false, nil
 }
 
-func Download(url string, f io.Writer) error {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+func Download(ctx context.Context, url string, f io.Writer) error {
+	client := readonly.NewClient()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating http request: %w", err)
 	}
 
-	if os.Getenv("GITHUB_TOKEN") != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN")))
+	auth, err := authorization(ctx, url)
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
 	} else {
 		logrus.Warn("making unauthenticated request to github")
 	}
@@ -105,7 +191,7 @@ func Download(url string, f io.Writer) error {
 	defer resp.Body.Close()
 
 	// Writer the body to file
-	numBytes, err := io.Copy(f, resp.Body)
+	numBytes, err := io.Copy(progress.Writer(f), resp.Body)
 	if err != nil {
 		return fmt.Errorf("writing http response to disk: %w", err)
 	}
@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lowRateLimitThreshold is the number of remaining requests under
+// which we start warning about the rate limit
+const lowRateLimitThreshold = 10
+
+// defaultSecondaryRetry is the time to wait after hitting a secondary
+// rate limit when the API does not send a Retry-After header
+const defaultSecondaryRetry = time.Minute
+
+// RateLimitError is returned when the GitHub API asks the client
+// to slow down. RetryAfter is the time to wait before the next request.
+// When the primary rate limit is exhausted, Reset is the time when
+// the quota is restored.
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Reset      time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	if !e.Reset.IsZero() {
+		return fmt.Sprintf(
+			"GitHub API rate limit exceeded (http %d), the limit resets at %s",
+			e.StatusCode, e.Reset.Format(time.RFC3339),
+		)
+	}
+	return fmt.Sprintf(
+		"GitHub API secondary rate limit hit (http %d), retry after %s", e.StatusCode, e.RetryAfter,
+	)
+}
+
+// rateLimitFromResponse returns a RateLimitError if the response
+// signals the client is being throttled, nil otherwise.
+func rateLimitFromResponse(res *http.Response) *RateLimitError {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusForbidden {
+		return nil
+	}
+
+	// Secondary rate limits send a Retry-After header
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			return &RateLimitError{StatusCode: res.StatusCode, RetryAfter: time.Duration(secs) * time.Second}
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			return &RateLimitError{StatusCode: res.StatusCode, RetryAfter: time.Until(t)}
+		}
+		return &RateLimitError{StatusCode: res.StatusCode, RetryAfter: defaultSecondaryRetry}
+	}
+
+	// The primary rate limit is exhausted when no requests remain
+	if res.Header.Get("X-RateLimit-Remaining") == "0" {
+		rlErr := &RateLimitError{StatusCode: res.StatusCode, RetryAfter: defaultSecondaryRetry}
+		if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			rlErr.Reset = time.Unix(reset, 0)
+			rlErr.RetryAfter = time.Until(rlErr.Reset)
+		}
+		return rlErr
+	}
+
+	// A 403 without rate limit headers is a real permissions error
+	if res.StatusCode == http.StatusForbidden {
+		return nil
+	}
+	return &RateLimitError{StatusCode: res.StatusCode, RetryAfter: defaultSecondaryRetry}
+}
+
+// warnRateLimit logs a warning when the remaining API quota is low
+func warnRateLimit(res *http.Response) {
+	remaining, err := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining >= lowRateLimitThreshold {
+		return
+	}
+	reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		logrus.Warnf("Only %d GitHub API requests remaining", remaining)
+		return
+	}
+	logrus.Warnf(
+		"Only %d GitHub API requests remaining, the limit resets at %s",
+		remaining, time.Unix(reset, 0).Format(time.RFC3339),
+	)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitFromResponse(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	for _, tc := range []struct {
+		name    string
+		status  int
+		headers map[string]string
+		limited bool
+		reset   bool
+	}{
+		{"ok", http.StatusOK, nil, false, false},
+		{"forbidden", http.StatusForbidden, nil, false, false},
+		{"secondary", http.StatusForbidden, map[string]string{"Retry-After": "60"}, true, false},
+		{"too-many", http.StatusTooManyRequests, nil, true, false},
+		{
+			"primary", http.StatusForbidden,
+			map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprintf("%d", reset.Unix()),
+			},
+			true, true,
+		},
+	} {
+		res := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+		for k, v := range tc.headers {
+			res.Header.Set(k, v)
+		}
+		rlErr := rateLimitFromResponse(res)
+		if !tc.limited {
+			require.Nil(t, rlErr, tc.name)
+			continue
+		}
+		require.NotNil(t, rlErr, tc.name)
+		require.Positive(t, rlErr.RetryAfter, tc.name)
+		if tc.reset {
+			require.True(t, reset.Equal(rlErr.Reset), tc.name)
+			require.Contains(t, rlErr.Error(), reset.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestAPIGetRequestWithETag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.URL.Path == "/limited" {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+
+	res, notModified, err := APIGetRequestWithETag(context.Background(), srv.URL, "")
+	require.NoError(t, err)
+	require.False(t, notModified)
+	res.Body.Close()
+	require.Equal(t, `"abc"`, res.Header.Get("ETag"))
+
+	res, notModified, err = APIGetRequestWithETag(context.Background(), srv.URL, `"abc"`)
+	require.NoError(t, err)
+	require.True(t, notModified)
+	require.Nil(t, res)
+
+	_, _, err = APIGetRequestWithETag(context.Background(), srv.URL+"/limited", "")
+	require.Error(t, err)
+	rlErr := &RateLimitError{}
+	require.True(t, errors.As(err, &rlErr))
+	require.False(t, rlErr.Reset.IsZero())
+}
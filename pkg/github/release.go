@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+const (
+	commitURL     = "%s/repos/%s/%s/commits/%s"
+	releaseTagURL = "%s/repos/%s/%s/releases/tags/%s"
+	tagRefURL     = "%s/repos/%s/%s/git/ref/tags/%s"
+)
+
+// TagExists returns true if the repository has a tag with the name
+func TagExists(ctx context.Context, apiBase, owner, repo, tag string) (bool, error) {
+	res, err := APIGetRequest(ctx, fmt.Sprintf(tagRefURL, apiBase, owner, repo, url.PathEscape(tag)))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("querying tag %s: %w", tag, err)
+	}
+	res.Body.Close()
+	return true, nil
+}
+
+// Release is the subset of the release object returned by the API
+// that we record. It leaves out fields that change without the release
+// changing (eg download counts).
+type Release struct {
+	ID              int64          `json:"id"`
+	TagName         string         `json:"tag_name"`
+	TargetCommitish string         `json:"target_commitish"`
+	Name            string         `json:"name"`
+	Draft           bool           `json:"draft"`
+	Prerelease      bool           `json:"prerelease"`
+	HTMLURL         string         `json:"html_url"`
+	Assets          []ReleaseAsset `json:"assets"`
+}
+
+type ReleaseAsset struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Digest returns the sha256 digest of the canonical form of the release:
+// the JSON object with the fields of Release and its assets sorted by
+// name, serialized with the keys sorted and without whitespace or HTML
+// escaping (RFC 8785 for these types). It only changes when the
+// recorded fields of the release change.
+func (r *Release) Digest() (string, error) {
+	release := *r
+	release.Assets = slices.Clone(r.Assets)
+	slices.SortFunc(release.Assets, func(a, b ReleaseAsset) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	// Going through a map sorts the keys
+	data, err := json.Marshal(release)
+	if err != nil {
+		return "", fmt.Errorf("marshalling release: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	fields := map[string]interface{}{}
+	if err := dec.Decode(&fields); err != nil {
+		return "", fmt.Errorf("decoding release: %w", err)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return "", fmt.Errorf("encoding release: %w", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(bytes.TrimSuffix(b.Bytes(), []byte("\n")))), nil
+}
+
+// TagCommit returns the sha of the commit a tag points to
+func TagCommit(ctx context.Context, apiBase, owner, repo, tag string) (string, error) {
+	res, err := APIGetRequest(ctx, fmt.Sprintf(commitURL, apiBase, owner, repo, url.PathEscape(tag)))
+	if err != nil {
+		return "", fmt.Errorf("querying commit of tag %s: %w", tag, err)
+	}
+	defer res.Body.Close()
+
+	commit := struct {
+		SHA string `json:"sha"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&commit); err != nil {
+		return "", fmt.Errorf("decoding commit data: %w", err)
+	}
+	return commit.SHA, nil
+}
+
+// GetRelease fetches the release object published from a tag
+func GetRelease(ctx context.Context, apiBase, owner, repo, tag string) (*Release, error) {
+	res, err := APIGetRequest(ctx, fmt.Sprintf(releaseTagURL, apiBase, owner, repo, url.PathEscape(tag)))
+	if err != nil {
+		return nil, fmt.Errorf("querying release %s: %w", tag, err)
+	}
+	defer res.Body.Close()
+
+	rawData, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading api response data: %w", err)
+	}
+	release := &Release{}
+	if err := json.Unmarshal(rawData, release); err != nil {
+		return nil, fmt.Errorf("unmarshalling release data: %w", err)
+	}
+	return release, nil
+}
+
+// UploadReleaseAsset uploads a file as an asset of the release published
+// from a tag. Uploading requires a token with write access to the
+// repository in GITHUB_TOKEN or a GitHub App installation.
+func UploadReleaseAsset(ctx context.Context, owner, repo, tag, name string, data []byte) error {
+	if err := readonly.Check(fmt.Sprintf("uploading %s to release %s", name, tag)); err != nil {
+		return err
+	}
+	releaseURL := fmt.Sprintf(releaseTagURL, APIURL(), owner, repo, url.PathEscape(tag))
+	auth, err := authorization(ctx, releaseURL)
+	if err != nil {
+		return err
+	}
+	if auth == "" {
+		return errors.New("uploading release assets requires a token in GITHUB_TOKEN or a GitHub App")
+	}
+	res, err := APIGetRequest(ctx, releaseURL)
+	if err != nil {
+		return fmt.Errorf("querying release %s: %w", tag, err)
+	}
+	defer res.Body.Close()
+
+	// The upload URL is a hypermedia template, the host differs from
+	// the API host (uploads.github.com on github.com)
+	release := struct {
+		UploadURL string         `json:"upload_url"`
+		Assets    []ReleaseAsset `json:"assets"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&release); err != nil {
+		return fmt.Errorf("decoding release data: %w", err)
+	}
+	for _, a := range release.Assets {
+		if a.Name == name {
+			return fmt.Errorf("release %s already has an asset named %s", tag, name)
+		}
+	}
+	uploadURL, _, _ := strings.Cut(release.UploadURL, "{")
+	if uploadURL == "" {
+		return fmt.Errorf("release %s has no upload url", tag)
+	}
+	uploadURL += "?name=" + url.QueryEscape(name)
+
+	logrus.Infof("GitHubAPI[POST]: %s", uploadURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", auth)
+	res, err = readonly.NewClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("executing http request to GitHub API: %w", err)
+	}
+	defer res.Body.Close()
+	warnRateLimit(res)
+	if res.StatusCode != http.StatusCreated {
+		if rlErr := rateLimitFromResponse(res); rlErr != nil {
+			return rlErr
+		}
+		return fmt.Errorf("http error %d uploading release asset", res.StatusCode)
+	}
+	return nil
+}
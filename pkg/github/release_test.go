@@ -0,0 +1,53 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseDigest(t *testing.T) {
+	release := &Release{
+		ID:              1,
+		TagName:         "v1.0.0",
+		TargetCommitish: "main",
+		Name:            "v1.0.0 <stable>",
+		HTMLURL:         "https://github.com/org/repo/releases/tag/v1.0.0",
+		Assets: []ReleaseAsset{
+			{ID: 2, Name: "b.tar.gz", Size: 20},
+			{ID: 1, Name: "a.tar.gz", Size: 10},
+		},
+	}
+	canonical := `{"assets":[{"id":1,"name":"a.tar.gz","size":10},{"id":2,"name":"b.tar.gz","size":20}],` +
+		`"draft":false,"html_url":"https://github.com/org/repo/releases/tag/v1.0.0","id":1,` +
+		`"name":"v1.0.0 <stable>","prerelease":false,"tag_name":"v1.0.0","target_commitish":"main"}`
+
+	digest, err := release.Digest()
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(canonical))), digest)
+	// The assets of the caller are not reordered
+	require.Equal(t, "b.tar.gz", release.Assets[0].Name)
+
+	release.Draft = true
+	changed, err := release.Digest()
+	require.NoError(t, err)
+	require.NotEqual(t, digest, changed)
+}
@@ -29,19 +29,29 @@ type Artifact struct {
 }
 
 type Run struct {
-	ID              int64  `json:"id"`
-	Status          string `json:"status"`
-	Conclusion      string `json:"conclusion"`
-	HeadBranch      string `json:"head_branch"`
-	HeadSHA         string `json:"head_sha"`
-	Path            string `json:"path"`
-	RunNumber       int64  `json:"run_number"`
-	WorkFlowID      int64  `json:"workflow_id"`
-	CreatedAt       string `json:"created_at"`
-	UpdatedAt       string `json:"updated_at"`
-	LogsURL         string `json:"logs_url"`
-	Actor           Actor  `json:"actor"`
-	TriggeringActor Actor  `json:"triggering_actor"`
+	ID              int64      `json:"id"`
+	Status          string     `json:"status"`
+	Conclusion      string     `json:"conclusion"`
+	HeadBranch      string     `json:"head_branch"`
+	HeadSHA         string     `json:"head_sha"`
+	Event           string     `json:"event"`
+	Path            string     `json:"path"`
+	RunNumber       int64      `json:"run_number"`
+	RunAttempt      int64      `json:"run_attempt"`
+	WorkFlowID      int64      `json:"workflow_id"`
+	CreatedAt       string     `json:"created_at"`
+	UpdatedAt       string     `json:"updated_at"`
+	LogsURL         string     `json:"logs_url"`
+	Actor           Actor      `json:"actor"`
+	TriggeringActor Actor      `json:"triggering_actor"`
+	Repository      Repository `json:"repository"`
+}
+
+// Repository is the repository a run belongs to
+type Repository struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	Owner    Actor  `json:"owner"`
 }
 
 type Actor struct {
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+// caBundle is the path to the PEM bundle set with SetCABundle
+var caBundle string
+
+// ErrNotFound is returned when the API responds that a resource does not exist
+var ErrNotFound = errors.New("resource not found in the GitLab API")
+
+// SetCABundle sets the path to a PEM file with the certificates of
+// the authorities to trust when talking to self-managed instances.
+func SetCABundle(path string) {
+	caBundle = path
+}
+
+// CABundle returns the path of the CA bundle to trust. If not set with
+// SetCABundle it is read from the GITLAB_CA_BUNDLE environment variable.
+func CABundle() string {
+	if caBundle != "" {
+		return caBundle
+	}
+	return os.Getenv("GITLAB_CA_BUNDLE")
+}
+
+// APIURL returns the base URL of the API of a GitLab instance
+func APIURL(host string) string {
+	return "https://" + host + "/api/v4"
+}
+
+// httpClient returns an http client trusting the system roots and
+// the certificates in the CA bundle, if one is set. Unsafe requests
+// are refused in read-only mode.
+func httpClient() (*http.Client, error) {
+	path := CABundle()
+	if path == "" {
+		return readonly.NewClient(), nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: readonly.Transport(transport)}, nil
+}
+
+// APIGetRequest performs a GET request to the API of the GitLab instance
+// in host. The path is appended to the API base URL. If GITLAB_TOKEN is
+// set, it is used to authenticate; inside a GitLab CI job, CI_JOB_TOKEN
+// is used instead.
+func APIGetRequest(ctx context.Context, host, path string) (*http.Response, error) {
+	return apiRequest(ctx, http.MethodGet, host, path, "", nil)
+}
+
+// APIPostRequest performs a POST request to the API of the GitLab
+// instance in host, authenticated as APIGetRequest.
+func APIPostRequest(ctx context.Context, host, path, contentType string, body io.Reader) (*http.Response, error) {
+	return apiRequest(ctx, http.MethodPost, host, path, contentType, body)
+}
+
+func apiRequest(ctx context.Context, method, host, path, contentType string, body io.Reader) (*http.Response, error) {
+	url := APIURL(host) + "/" + strings.TrimPrefix(path, "/")
+	if method != http.MethodGet {
+		if err := readonly.Check(method + " " + url); err != nil {
+			return nil, err
+		}
+	}
+	logrus.Infof("GitLabAPI[%s]: %s", method, url)
+	client, err := httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("creating http client: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating http request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case os.Getenv("GITLAB_TOKEN") != "":
+		req.Header.Set("PRIVATE-TOKEN", os.Getenv("GITLAB_TOKEN"))
+	case os.Getenv("CI_JOB_TOKEN") != "":
+		req.Header.Set("JOB-TOKEN", os.Getenv("CI_JOB_TOKEN"))
+	default:
+		logrus.Warn("making unauthenticated request to gitlab")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing http request to GitLab API: %w", err)
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		res.Body.Close()
+		if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			return nil, errors.New("access denied by the GitLab API, check GITLAB_TOKEN")
+		}
+		if res.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, url)
+		}
+		return nil, fmt.Errorf("http error %d making request to GitLab API", res.StatusCode)
+	}
+	return res, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIGetRequestCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/pipelines/42" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	t.Setenv("GITLAB_CA_BUNDLE", "")
+	t.Setenv("GITLAB_TOKEN", "test")
+
+	// The test server certificate is not trusted by default
+	SetCABundle("")
+	_, err := APIGetRequest(context.Background(), host, "projects/group%2Fproject/pipelines/42")
+	require.Error(t, err)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: srv.Certificate().Raw,
+	}), os.FileMode(0o644)))
+
+	SetCABundle(bundle)
+	defer SetCABundle("")
+	res, err := APIGetRequest(context.Background(), host, "projects/group%2Fproject/pipelines/42")
+	require.NoError(t, err)
+	res.Body.Close()
+
+	// An empty bundle is an error
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte{}, os.FileMode(0o644)))
+	SetCABundle(empty)
+	_, err = APIGetRequest(context.Background(), host, "projects/group%2Fproject/pipelines/42")
+	require.Error(t, err)
+}
+
+func TestUploadReleaseAsset(t *testing.T) {
+	var link map[string]string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fproject/uploads":
+			f, _, err := r.FormFile("file")
+			require.NoError(t, err)
+			f.Close()
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"url": "/uploads/abc/provenance.json", "full_path": "/-/project/1/uploads/abc/provenance.json"}`)
+		case "/api/v4/projects/group%2Fproject/releases/v1.0.0/assets/links":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&link))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	t.Setenv("GITLAB_TOKEN", "test")
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: srv.Certificate().Raw,
+	}), os.FileMode(0o644)))
+	SetCABundle(bundle)
+	defer SetCABundle("")
+
+	require.NoError(t, UploadReleaseAsset(context.Background(), host, "group/project", "v1.0.0", "provenance.json", []byte("{}")))
+	require.Equal(t, "provenance.json", link["name"])
+	require.Equal(t, "https://"+host+"/-/project/1/uploads/abc/provenance.json", link["url"])
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+// UploadReleaseAsset uploads a file to a project and links it as an
+// asset of the release published from a tag. GitLab releases do not
+// store files themselves, assets are links to the uploaded files.
+func UploadReleaseAsset(ctx context.Context, host, project, tag, name string, data []byte) error {
+	projectPath := "projects/" + url.PathEscape(project)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return fmt.Errorf("creating upload form: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("writing upload form: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("closing upload form: %w", err)
+	}
+	res, err := APIPostRequest(ctx, host, projectPath+"/uploads", mw.FormDataContentType(), &body)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	defer res.Body.Close()
+	upload := struct {
+		URL      string `json:"url"`
+		FullPath string `json:"full_path"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&upload); err != nil {
+		return fmt.Errorf("decoding upload response: %w", err)
+	}
+
+	// Older instances return only the upload path relative to the project
+	fileURL := "https://" + host + "/" + project + upload.URL
+	if strings.HasPrefix(upload.FullPath, "/") {
+		fileURL = "https://" + host + upload.FullPath
+	}
+
+	link, err := json.Marshal(map[string]string{
+		"name":      name,
+		"url":       fileURL,
+		"link_type": "other",
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling release link: %w", err)
+	}
+	res2, err := APIPostRequest(
+		ctx, host, fmt.Sprintf("%s/releases/%s/assets/links", projectPath, url.PathEscape(tag)),
+		"application/json", bytes.NewReader(link),
+	)
+	if err != nil {
+		return fmt.Errorf("linking %s to release %s: %w", name, tag, err)
+	}
+	res2.Body.Close()
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gomod
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/package-url/packageurl-go"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/mod/modfile"
+)
+
+// Module is a module in the dependency closure of a Go module
+type Module struct {
+	Path    string
+	Version string
+	// Sum is the hash of the module contents from go.sum (h1:...)
+	Sum string
+}
+
+// PackageURL returns the purl of the module (pkg:golang/path@version)
+func (m *Module) PackageURL() string {
+	namespace, name := "", m.Path
+	if i := strings.LastIndex(m.Path, "/"); i != -1 {
+		namespace, name = m.Path[:i], m.Path[i+1:]
+	}
+	return packageurl.NewPackageURL(
+		packageurl.TypeGolang, namespace, name, m.Version, nil, "",
+	).ToString()
+}
+
+// Dependencies returns the modules required by the go.mod file with
+// their hashes from go.sum. Since Go 1.17 go.mod lists every module
+// providing packages to the build, indirect ones included. Replaced
+// modules are returned as their replacement, modules replaced by a
+// local directory are part of the source tree and skipped.
+func Dependencies(goMod, goSum []byte) ([]Module, error) {
+	mf, err := modfile.Parse("go.mod", goMod, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.mod: %w", err)
+	}
+	sums, err := parseSums(goSum)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.sum: %w", err)
+	}
+
+	modules := []Module{}
+	for _, req := range mf.Require {
+		mod := req.Mod
+		for _, rep := range mf.Replace {
+			if rep.Old.Path == mod.Path && (rep.Old.Version == "" || rep.Old.Version == mod.Version) {
+				mod = rep.New
+				break
+			}
+		}
+		if mod.Version == "" {
+			logrus.Debugf("Skipping %s, replaced by local directory %s", req.Mod.Path, mod.Path)
+			continue
+		}
+		m := Module{
+			Path:    mod.Path,
+			Version: mod.Version,
+			Sum:     sums[mod.Path+"@"+mod.Version],
+		}
+		if m.Sum == "" {
+			logrus.Warnf("go.sum has no hash of module %s@%s", m.Path, m.Version)
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// parseSums reads the module content hashes from a go.sum file, keyed
+// by path@version. The hashes of the go.mod files are ignored.
+func parseSums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed line %q", scanner.Text())
+		}
+		if strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		sums[fields[0]+"@"+fields[1]] = fields[2]
+	}
+	return sums, scanner.Err()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gomod
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencies(t *testing.T) {
+	goMod := []byte(`module example.com/app
+
+go 1.22
+
+require (
+	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/mod v0.17.0 // indirect
+	example.com/local v1.0.0
+	example.com/forked v1.2.0
+)
+
+replace example.com/local => ../local
+
+replace example.com/forked v1.2.0 => github.com/fork/forked v1.2.1
+`)
+	goSum := []byte(`github.com/fork/forked v1.2.1 h1:Zm9ya2Vk
+github.com/fork/forked v1.2.1/go.mod h1:Z29tb2Q=
+github.com/sirupsen/logrus v1.9.3 h1:dueUQJ1C2q9oE3F7wvmSGAaVtTmUizReu6fjN8uqzbQ=
+github.com/sirupsen/logrus v1.9.3/go.mod h1:naHLuLoDiP4jHNo9R0sCBMtWGeIprob74mVsIT4qYEQ=
+`)
+
+	modules, err := Dependencies(goMod, goSum)
+	require.NoError(t, err)
+	require.Equal(t, []Module{
+		{Path: "github.com/sirupsen/logrus", Version: "v1.9.3", Sum: "h1:dueUQJ1C2q9oE3F7wvmSGAaVtTmUizReu6fjN8uqzbQ="},
+		{Path: "golang.org/x/mod", Version: "v0.17.0"},
+		{Path: "github.com/fork/forked", Version: "v1.2.1", Sum: "h1:Zm9ya2Vk"},
+	}, modules)
+	require.Equal(t, "pkg:golang/github.com/sirupsen/logrus@v1.9.3", modules[0].PackageURL())
+
+	_, err = Dependencies(goMod, []byte("github.com/sirupsen/logrus v1.9.3\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Output formats supported by Write
+const (
+	FormatMermaid = "mermaid"
+	FormatDOT     = "dot"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatMermaid, FormatDOT}
+
+// Kinds of nodes in the graph
+const (
+	KindMaterial = "material"
+	KindStep     = "step"
+	KindArtifact = "artifact"
+)
+
+// Lengths of the digests and commands shown in the labels
+const (
+	digestLength     = 12
+	maxCommandLength = 60
+)
+
+// Node is a material, step or artifact of the run
+type Node struct {
+	ID     string
+	Kind   string
+	Label  string
+	Failed bool
+}
+
+// Edge links two nodes in the order the data flows through the build
+type Edge struct {
+	From string
+	To   string
+}
+
+// Graph is the diagram of a build run: its materials feed the first
+// step, steps run in sequence and the last one produces the artifacts.
+type Graph struct {
+	Title string
+	Nodes []Node
+	Edges []Edge
+}
+
+// New builds the graph of a run and the materials recorded in its
+// attestation
+func New(r *run.Run, materials []common.ProvenanceMaterial) *Graph {
+	g := &Graph{Title: r.SpecURL}
+
+	inputs := []string{}
+	for i, m := range materials {
+		id := fmt.Sprintf("m%d", i)
+		g.Nodes = append(g.Nodes, Node{ID: id, Kind: KindMaterial, Label: withDigest(m.URI, m.Digest)})
+		inputs = append(inputs, id)
+	}
+
+	for i := range r.Steps {
+		id := fmt.Sprintf("s%d", i)
+		g.Nodes = append(g.Nodes, Node{
+			ID: id, Kind: KindStep, Label: stepLabel(i, &r.Steps[i]),
+			Failed: !r.IsRunning && !r.Steps[i].IsSuccess,
+		})
+		for _, in := range inputs {
+			g.Edges = append(g.Edges, Edge{From: in, To: id})
+		}
+		inputs = []string{id}
+	}
+
+	for i, a := range r.Artifacts {
+		id := fmt.Sprintf("a%d", i)
+		g.Nodes = append(g.Nodes, Node{ID: id, Kind: KindArtifact, Label: withDigest(a.Path, a.Checksum)})
+		// Without steps there is nothing to say about which
+		// material produced which artifact
+		if len(r.Steps) == 0 {
+			continue
+		}
+		for _, in := range inputs {
+			g.Edges = append(g.Edges, Edge{From: in, To: id})
+		}
+	}
+	return g
+}
+
+// stepLabel describes a step by its image and the first line of its
+// command
+func stepLabel(i int, s *run.Step) string {
+	command, _, _ := strings.Cut(strings.TrimSpace(s.Command), "\n")
+	if len(command) > maxCommandLength {
+		command = command[:maxCommandLength] + "..."
+	}
+	parts := []string{}
+	for _, p := range []string{s.Image, command} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("step %d", i)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// withDigest appends the shortened SHA256 (or the first available)
+// digest to a label
+func withDigest(label string, digests map[string]string) string {
+	algo := ""
+	for _, a := range []string{"sha256", "SHA256", "sha1", "SHA1"} {
+		if _, ok := digests[a]; ok {
+			algo = a
+			break
+		}
+	}
+	if algo == "" {
+		for a := range digests {
+			if algo == "" || a < algo {
+				algo = a
+			}
+		}
+	}
+	if algo == "" {
+		return label
+	}
+	d := digests[algo]
+	if len(d) > digestLength {
+		d = d[:digestLength]
+	}
+	return fmt.Sprintf("%s\n%s:%s", label, strings.ToLower(algo), d)
+}
+
+// Write renders the graph in one of the supported formats
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatMermaid:
+		return g.Mermaid(w)
+	case FormatDOT:
+		return g.DOT(w)
+	default:
+		return fmt.Errorf("unknown graph format %q", format)
+	}
+}
+
+// subgraphs are the node groups in render order
+var subgraphs = []struct{ kind, title string }{
+	{KindMaterial, "Materials"},
+	{KindStep, "Steps"},
+	{KindArtifact, "Artifacts"},
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *Graph) Mermaid(w io.Writer) error {
+	var b strings.Builder
+	if g.Title != "" {
+		fmt.Fprintf(&b, "---\ntitle: %s\n---\n", g.Title)
+	}
+	b.WriteString("flowchart LR\n")
+	failed := []string{}
+	for _, sg := range subgraphs {
+		nodes := g.nodesOfKind(sg.kind)
+		if len(nodes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  subgraph %s\n", sg.title)
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", n.ID, mermaidEscape(n.Label))
+			if n.Failed {
+				failed = append(failed, n.ID)
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", e.From, e.To)
+	}
+	if len(failed) > 0 {
+		b.WriteString("  classDef failed stroke:#d33,stroke-width:2px\n")
+		fmt.Fprintf(&b, "  class %s failed\n", strings.Join(failed, ","))
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing graph: %w", err)
+	}
+	return nil
+}
+
+// DOT renders the graph in the Graphviz DOT language
+func (g *Graph) DOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph run {\n  rankdir=LR;\n  node [shape=box];\n")
+	if g.Title != "" {
+		fmt.Fprintf(&b, "  label=%s;\n  labelloc=t;\n", dotQuote(g.Title))
+	}
+	for _, sg := range subgraphs {
+		nodes := g.nodesOfKind(sg.kind)
+		if len(nodes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  subgraph cluster_%s {\n    label=%s;\n", sg.kind, dotQuote(sg.title))
+		for _, n := range nodes {
+			attrs := "label=" + dotQuote(n.Label)
+			if n.Failed {
+				attrs += ", color=red"
+			}
+			fmt.Fprintf(&b, "    %s [%s];\n", n.ID, attrs)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("writing graph: %w", err)
+	}
+	return nil
+}
+
+func (g *Graph) nodesOfKind(kind string) []Node {
+	nodes := []Node{}
+	for _, n := range g.Nodes {
+		if n.Kind == kind {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// mermaidEscape escapes a label to place it between double quotes
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s)
+}
+
+// dotQuote returns a label as a quoted DOT string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
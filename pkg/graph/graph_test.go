@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func testGraph() *Graph {
+	return New(&run.Run{
+		SpecURL: "gcb://project/1234",
+		Steps: []run.Step{
+			{Image: "golang:1.22", Command: "go build -o bin/app ./cmd/app\ngo test ./...", IsSuccess: true},
+			{Image: "gcr.io/cloud-builders/gsutil", Command: `cp "bin/app" gs://bucket/`},
+		},
+		Artifacts: []run.Artifact{
+			{Path: "gs://bucket/app", Checksum: map[string]string{"SHA256": "c71d239df91726fc519c6eb72d318ec65820627232b2f796219e87dcf35d0ab4"}},
+		},
+	}, []common.ProvenanceMaterial{
+		{URI: "git+https://github.com/org/repo", Digest: common.DigestSet{"sha1": "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"}},
+	})
+}
+
+func TestNew(t *testing.T) {
+	g := testGraph()
+	require.Len(t, g.Nodes, 4)
+	require.Equal(t, []Edge{{"m0", "s0"}, {"s0", "s1"}, {"s1", "a0"}}, g.Edges)
+	require.Equal(t, "golang:1.22\ngo build -o bin/app ./cmd/app", g.Nodes[1].Label)
+	require.False(t, g.Nodes[1].Failed)
+	require.True(t, g.Nodes[2].Failed)
+	require.Equal(t, "gs://bucket/app\nsha256:c71d239df917", g.Nodes[3].Label)
+
+	// Without steps, materials and artifacts are not linked
+	g = New(&run.Run{Artifacts: []run.Artifact{{Path: "bin"}}}, []common.ProvenanceMaterial{{URI: "git+https://example.com/repo"}})
+	require.Len(t, g.Nodes, 2)
+	require.Empty(t, g.Edges)
+	require.Equal(t, "bin", g.Nodes[1].Label)
+}
+
+func TestMermaid(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, testGraph().Write(&b, FormatMermaid))
+	require.Equal(t, `---
+title: gcb://project/1234
+---
+flowchart LR
+  subgraph Materials
+    m0["git+https://github.com/org/repo<br/>sha1:a94a8fe5ccb1"]
+  end
+  subgraph Steps
+    s0["golang:1.22<br/>go build -o bin/app ./cmd/app"]
+    s1["gcr.io/cloud-builders/gsutil<br/>cp #quot;bin/app#quot; gs://bucket/"]
+  end
+  subgraph Artifacts
+    a0["gs://bucket/app<br/>sha256:c71d239df917"]
+  end
+  m0 --> s0
+  s0 --> s1
+  s1 --> a0
+  classDef failed stroke:#d33,stroke-width:2px
+  class s1 failed
+`, b.String())
+}
+
+func TestDOT(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, testGraph().Write(&b, FormatDOT))
+	require.Equal(t, `digraph run {
+  rankdir=LR;
+  node [shape=box];
+  label="gcb://project/1234";
+  labelloc=t;
+  subgraph cluster_material {
+    label="Materials";
+    m0 [label="git+https://github.com/org/repo\nsha1:a94a8fe5ccb1"];
+  }
+  subgraph cluster_step {
+    label="Steps";
+    s0 [label="golang:1.22\ngo build -o bin/app ./cmd/app"];
+    s1 [label="gcr.io/cloud-builders/gsutil\ncp \"bin/app\" gs://bucket/", color=red];
+  }
+  subgraph cluster_artifact {
+    label="Artifacts";
+    a0 [label="gs://bucket/app\nsha256:c71d239df917"];
+  }
+  m0 -> s0;
+  s0 -> s1;
+  s1 -> a0;
+}
+`, b.String())
+
+	require.Error(t, testGraph().Write(&b, "svg"))
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hostquote captures hardware-rooted attestation quotes of the
+// host tejolote runs on. Confidential VMs (AMD SEV-SNP, Intel TDX) are
+// quoted through the Linux configfs-tsm interface and TPM equipped hosts
+// through the tpm2-tools quote command.
+package hostquote
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/tejolote/pkg/workdir"
+)
+
+const (
+	ProviderAuto = "auto"
+	ProviderTSM  = "tsm"
+	ProviderTPM  = "tpm"
+
+	// DefaultTSMPath is where the configfs-tsm report interface is mounted
+	DefaultTSMPath = "/sys/kernel/config/tsm/report"
+
+	// DefaultPCRs are the PCRs quoted on TPM hosts, the boot chain
+	DefaultPCRs = "sha256:0,1,2,3,4,5,6,7"
+
+	// NonceSize is the size of the report data of confidential VM reports
+	NonceSize = 64
+)
+
+// ErrNotAvailable is returned when the host has no quoting provider
+var ErrNotAvailable = errors.New("no attestation quote provider available in the host")
+
+// Options control how the host is quoted
+type Options struct {
+	Provider string // tsm, tpm or auto to use the first available
+	TSMPath  string // configfs-tsm report directory
+	AKHandle string // persistent handle of the TPM attestation key
+	PCRs     string // TPM PCR selection to quote
+}
+
+// Quote is the evidence captured from the host. The nonce is recorded
+// in the report data of the quote, binding it to what was attested.
+type Quote struct {
+	Provider string    `json:"provider"`
+	Platform string    `json:"platform,omitempty"`
+	Time     time.Time `json:"time"`
+	Nonce    string    `json:"nonce"`
+	Report   []byte    `json:"report"`
+	AuxBlob  []byte    `json:"auxblob,omitempty"`
+	// TPM quotes carry their signature and the quoted PCR values
+	// separately from the quote message
+	Signature []byte `json:"signature,omitempty"`
+	PCRs      []byte `json:"pcrs,omitempty"`
+}
+
+// ToJSON serializes the quote
+func (q *Quote) ToJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling quote: %w", err)
+	}
+	return data, nil
+}
+
+// Available returns the provider that would be used to quote the host,
+// or an empty string when none is usable
+func Available(opts Options) string {
+	if opts.Provider != "" && opts.Provider != ProviderAuto {
+		return opts.Provider
+	}
+	if _, err := os.Stat(tsmPath(opts)); err == nil {
+		return ProviderTSM
+	}
+	if opts.AKHandle != "" {
+		if _, err := exec.LookPath("tpm2_quote"); err == nil {
+			return ProviderTPM
+		}
+	}
+	return ""
+}
+
+// Capture quotes the host binding the nonce (up to NonceSize bytes)
+// into the report
+func Capture(ctx context.Context, opts Options, nonce []byte) (*Quote, error) {
+	if len(nonce) > NonceSize {
+		return nil, fmt.Errorf("nonce is larger than %d bytes", NonceSize)
+	}
+	var q *Quote
+	var err error
+	switch Available(opts) {
+	case ProviderTSM:
+		q, err = captureTSM(tsmPath(opts), nonce)
+	case ProviderTPM:
+		q, err = captureTPM(ctx, opts, nonce)
+	case "":
+		return nil, ErrNotAvailable
+	default:
+		return nil, fmt.Errorf("unknown quote provider %q", opts.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Time = time.Now().UTC()
+	q.Nonce = hex.EncodeToString(nonce)
+	return q, nil
+}
+
+func tsmPath(opts Options) string {
+	if opts.TSMPath != "" {
+		return opts.TSMPath
+	}
+	return DefaultTSMPath
+}
+
+// captureTSM requests a report through configfs-tsm. Each report is
+// requested in its own directory: the nonce is written to inblob and
+// the signed report read back from outblob.
+func captureTSM(root string, nonce []byte) (*Quote, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating report name: %w", err)
+	}
+	dir := filepath.Join(root, "tejolote-"+hex.EncodeToString(id))
+	if err := os.Mkdir(dir, os.FileMode(0o755)); err != nil {
+		return nil, fmt.Errorf("creating tsm report entry: %w", err)
+	}
+	defer os.Remove(dir)
+
+	// The report data is a fixed size field, pad the nonce to fill it
+	inblob := make([]byte, NonceSize)
+	copy(inblob, nonce)
+	if err := os.WriteFile(filepath.Join(dir, "inblob"), inblob, os.FileMode(0o600)); err != nil {
+		return nil, fmt.Errorf("writing report data: %w", err)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "outblob"))
+	if err != nil {
+		return nil, fmt.Errorf("reading tsm report: %w", err)
+	}
+	q := &Quote{Provider: ProviderTSM, Report: report}
+	if provider, err := os.ReadFile(filepath.Join(dir, "provider")); err == nil {
+		q.Platform = strings.TrimSpace(string(provider))
+	}
+	// The certificate chain is only returned by some platforms
+	if aux, err := os.ReadFile(filepath.Join(dir, "auxblob")); err == nil && len(aux) > 0 {
+		q.AuxBlob = aux
+	}
+	return q, nil
+}
+
+// captureTPM quotes the PCRs of the TPM with tpm2_quote. The attestation
+// key has to be provisioned and persisted at AKHandle beforehand.
+func captureTPM(ctx context.Context, opts Options, nonce []byte) (*Quote, error) {
+	if opts.AKHandle == "" {
+		return nil, errors.New("quoting the TPM requires the handle of the attestation key")
+	}
+	pcrs := opts.PCRs
+	if pcrs == "" {
+		pcrs = DefaultPCRs
+	}
+	tmp, err := workdir.MkdirTemp("tpm-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string]string{}
+	for _, f := range []string{"msg", "sig", "pcrs"} {
+		files[f] = filepath.Join(tmp, "quote."+f)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(
+		ctx, "tpm2_quote", "-c", opts.AKHandle, "-l", pcrs, "-g", "sha256",
+		"-q", hex.EncodeToString(nonce),
+		"-m", files["msg"], "-s", files["sig"], "-o", files["pcrs"],
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running tpm2_quote: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	q := &Quote{Provider: ProviderTPM, Platform: "tpm2"}
+	for f, dest := range map[string]*[]byte{"msg": &q.Report, "sig": &q.Signature, "pcrs": &q.PCRs} {
+		if *dest, err = os.ReadFile(files[f]); err != nil {
+			return nil, fmt.Errorf("reading quote %s: %w", f, err)
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostquote
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTPM2Quote installs a tpm2_quote script in the PATH that writes
+// the qualifying data it receives as the quote message
+func fakeTPM2Quote(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -q) nonce="$2" ;;
+    -m) msg="$2" ;;
+    -s) sig="$2" ;;
+    -o) pcrs="$2" ;;
+  esac
+  shift 2
+done
+printf '%s' "$nonce" > "$msg"
+printf 'signature' > "$sig"
+printf 'pcrs' > "$pcrs"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tpm2_quote"), []byte(script), os.FileMode(0o755)))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAvailable(t *testing.T) {
+	opts := Options{Provider: ProviderAuto, TSMPath: filepath.Join(t.TempDir(), "missing")}
+	require.Empty(t, Available(opts))
+
+	_, err := Capture(context.Background(), opts, []byte("nonce"))
+	require.ErrorIs(t, err, ErrNotAvailable)
+
+	// The TPM is only used when there is a key to sign the quote
+	fakeTPM2Quote(t)
+	require.Empty(t, Available(opts))
+	opts.AKHandle = "0x81010002"
+	require.Equal(t, ProviderTPM, Available(opts))
+
+	opts.TSMPath = t.TempDir()
+	require.Equal(t, ProviderTSM, Available(opts))
+}
+
+func TestCaptureTPM(t *testing.T) {
+	fakeTPM2Quote(t)
+	nonce := []byte(strings.Repeat("n", NonceSize))
+	q, err := Capture(context.Background(), Options{Provider: ProviderTPM, AKHandle: "0x81010002"}, nonce)
+	require.NoError(t, err)
+	require.Equal(t, ProviderTPM, q.Provider)
+	require.Equal(t, hex.EncodeToString(nonce), q.Nonce)
+	require.Equal(t, hex.EncodeToString(nonce), string(q.Report))
+	require.Equal(t, []byte("signature"), q.Signature)
+	require.Equal(t, []byte("pcrs"), q.PCRs)
+
+	_, err = Capture(context.Background(), Options{Provider: ProviderTPM}, nonce)
+	require.Error(t, err)
+
+	_, err = Capture(context.Background(), Options{Provider: ProviderTPM, AKHandle: "0x81010002"}, append(nonce, 'x'))
+	require.Error(t, err)
+}
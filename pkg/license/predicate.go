@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package license
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+)
+
+// PredicateType is the type of the license findings predicate
+const PredicateType = "https://sigs.k8s.io/tejolote/license-findings/v0.1"
+
+// Result holds the findings of one subject
+type Result struct {
+	Subject string `json:"subject"`
+	Findings
+}
+
+// Predicate records the licenses found in the subjects of the statement
+type Predicate struct {
+	Scanner   string    `json:"scanner"`
+	ScannedOn time.Time `json:"scannedOn"`
+	Results   []Result  `json:"results"`
+}
+
+// Statement is an in-toto statement with license findings
+type Statement struct {
+	intoto.StatementHeader
+	Predicate Predicate `json:"predicate"`
+}
+
+// NewStatement returns an empty license findings statement
+func NewStatement() *Statement {
+	return &Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: PredicateType,
+			Subject:       []intoto.Subject{},
+		},
+		Predicate: Predicate{
+			Scanner:   "tejolote",
+			ScannedOn: time.Now().UTC(),
+			Results:   []Result{},
+		},
+	}
+}
+
+// AddResult adds a subject and its findings to the statement
+func (st *Statement) AddResult(subject intoto.Subject, findings *Findings) {
+	st.Subject = append(st.Subject, subject)
+	st.Predicate.Results = append(st.Predicate.Results, Result{
+		Subject: subject.Name, Findings: *findings,
+	})
+}
+
+func (st *Statement) ToJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(st); err != nil {
+		return nil, fmt.Errorf("encoding license statement: %w", err)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package license
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxScanBytes is the maximum number of bytes read from each file
+// when looking for license texts and SPDX identifiers
+const maxScanBytes = 256 * 1024
+
+// FileFinding is a license found in a file of an artifact
+type FileFinding struct {
+	Path    string `json:"path"`
+	License string `json:"license"`
+	Method  string `json:"method"` // license-file or spdx-identifier
+}
+
+// Findings are the results of scanning an artifact
+type Findings struct {
+	Licenses []string      `json:"licenses"`
+	Files    []FileFinding `json:"files,omitempty"`
+}
+
+// licenseFileRE matches the names of files conventionally holding
+// the license of a project
+var licenseFileRE = regexp.MustCompile(`(?i)^(LICEN[CS]E|COPYING|COPYRIGHT|NOTICE)([.-].*)?$`)
+
+// spdxIdentifierRE matches SPDX short identifiers in source headers
+var spdxIdentifierRE = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+()\- ]+?)\s*(?:\*/|-->|$)`)
+
+// licenseTexts are the phrases used to classify license files. This
+// is a lightweight heuristic, not a full license classifier: the
+// first entry with all its phrases present in the text wins.
+var licenseTexts = []struct {
+	id      string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"permission is hereby granted, free of charge", "the above copyright notice"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// classify returns the SPDX identifier of a license text
+func classify(text []byte) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(string(text)), " "))
+	for _, lt := range licenseTexts {
+		found := true
+		for _, p := range lt.phrases {
+			if !strings.Contains(normalized, p) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return lt.id
+		}
+	}
+	return "NOASSERTION"
+}
+
+// ScanFile scans the file at localPath. Archives (tarballs, zip files,
+// python wheels and java archives) are opened and each of their files
+// is scanned. The name is the artifact name used to detect its type.
+func ScanFile(localPath, name string) (*Findings, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening artifact: %w", err)
+	}
+	defer f.Close()
+
+	findings := &Findings{Licenses: []string{}, Files: []FileFinding{}}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		defer gz.Close()
+		if err := scanTar(gz, findings); err != nil {
+			return nil, err
+		}
+	case strings.HasSuffix(lower, ".tar"):
+		if err := scanTar(f, findings); err != nil {
+			return nil, err
+		}
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".whl"), strings.HasSuffix(lower, ".jar"):
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("reading file info: %w", err)
+		}
+		if err := scanZip(f, info.Size(), findings); err != nil {
+			return nil, err
+		}
+	default:
+		if err := scanReader(path.Base(name), f, findings); err != nil {
+			return nil, err
+		}
+	}
+	findings.summarize()
+	return findings, nil
+}
+
+func scanTar(r io.Reader, findings *Findings) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := scanReader(hdr.Name, tr, findings); err != nil {
+			return err
+		}
+	}
+}
+
+func scanZip(r io.ReaderAt, size int64, findings *Findings) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("opening zip archive: %w", err)
+	}
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("opening %s in zip archive: %w", zf.Name, err)
+		}
+		err = scanReader(zf.Name, rc, findings)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanReader looks for license data in a file. License files are
+// classified by their text, other files are checked for SPDX
+// identifiers in their header.
+func scanReader(name string, r io.Reader, findings *Findings) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxScanBytes))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	if licenseFileRE.MatchString(path.Base(name)) {
+		findings.Files = append(findings.Files, FileFinding{
+			Path: name, License: classify(data), Method: "license-file",
+		})
+		return nil
+	}
+	if bytes.IndexByte(data, 0) != -1 {
+		// Skip binary files
+		return nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 0, 64*1024), maxScanBytes)
+	for s.Scan() {
+		if m := spdxIdentifierRE.FindStringSubmatch(s.Text()); m != nil {
+			findings.Files = append(findings.Files, FileFinding{
+				Path: name, License: strings.TrimSpace(m[1]), Method: "spdx-identifier",
+			})
+			return nil
+		}
+	}
+	return nil
+}
+
+// summarize computes the sorted list of unique licenses found
+func (f *Findings) summarize() {
+	seen := map[string]struct{}{}
+	for _, ff := range f.Files {
+		if _, ok := seen[ff.License]; ok {
+			continue
+		}
+		seen[ff.License] = struct{}{}
+		f.Licenses = append(f.Licenses, ff.License)
+	}
+	sort.Strings(f.Licenses)
+}
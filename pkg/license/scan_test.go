@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package license
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testFiles = map[string]string{
+	"project/LICENSE": `Apache License
+                           Version 2.0, January 2004`,
+	"project/main.go":  "// SPDX-License-Identifier: MIT\npackage main\n",
+	"project/util.c":   "/* SPDX-License-Identifier: BSD-2-Clause */\n",
+	"project/data.bin": "\x00\x01SPDX-License-Identifier: GPL-2.0",
+}
+
+func TestScanFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// tarball
+	tgz := filepath.Join(dir, "project.tar.gz")
+	f, err := os.Create(tgz)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, data := range testFiles {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	// wheel
+	whl := filepath.Join(dir, "project-1.0-py3-none-any.whl")
+	f, err = os.Create(whl)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, data := range testFiles {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	for _, path := range []string{tgz, whl} {
+		findings, err := ScanFile(path, filepath.Base(path))
+		require.NoError(t, err)
+		require.Equal(t, []string{"Apache-2.0", "BSD-2-Clause", "MIT"}, findings.Licenses, path)
+		require.Len(t, findings.Files, 3)
+	}
+
+	// Plain files
+	plain := filepath.Join(dir, "COPYING")
+	require.NoError(t, os.WriteFile(plain, []byte("Some custom terms"), os.FileMode(0o644)))
+	findings, err := ScanFile(plain, "dist/COPYING")
+	require.NoError(t, err)
+	require.Equal(t, []string{"NOASSERTION"}, findings.Licenses)
+
+	_, err = ScanFile(filepath.Join(dir, "missing"), "missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ociauth configures the authentication used to talk to container
+// registries.
+package ociauth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// githubRegistries are the registries that accept a GitHub token
+var githubRegistries = []string{"ghcr.io", "docker.pkg.github.com"}
+
+var (
+	credsMu     sync.RWMutex
+	credentials = map[string]authn.AuthConfig{}
+)
+
+// AddCredentials registers credentials for a registry. The spec has the
+// form registry=username:password. To keep secrets off the command line,
+// the password can reference an environment variable as env:NAME.
+func AddCredentials(spec string) error {
+	registry, userpass, ok := strings.Cut(spec, "=")
+	if !ok || registry == "" {
+		return errors.New("registry credentials must be specified as registry=username:password")
+	}
+	username, password, ok := strings.Cut(userpass, ":")
+	if !ok || username == "" {
+		return fmt.Errorf("credentials for %s must be specified as username:password", registry)
+	}
+	if name, ok := strings.CutPrefix(password, "env:"); ok {
+		password = os.Getenv(name)
+		if password == "" {
+			return fmt.Errorf("environment variable %s with the password for %s is not set", name, registry)
+		}
+	}
+	credsMu.Lock()
+	defer credsMu.Unlock()
+	credentials[registry] = authn.AuthConfig{Username: username, Password: password}
+	return nil
+}
+
+// SetDockerConfig sets the directory of the docker config.json to read
+// registry credentials and credential helpers from. It is exported as
+// DOCKER_CONFIG so that cosign uses the same credentials.
+func SetDockerConfig(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("checking docker config directory: %w", err)
+	}
+	return os.Setenv("DOCKER_CONFIG", dir)
+}
+
+// Keychain returns the keychain to authenticate to registries. It looks
+// up credentials in this order: credentials registered with
+// AddCredentials, the docker config (including credential helpers) and,
+// for GitHub's registries, the token in GITHUB_TOKEN.
+func Keychain() authn.Keychain {
+	return authn.NewMultiKeychain(flagKeychain{}, authn.DefaultKeychain, githubKeychain{})
+}
+
+// flagKeychain resolves the credentials registered with AddCredentials
+type flagKeychain struct{}
+
+func (flagKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	credsMu.RLock()
+	defer credsMu.RUnlock()
+	if c, ok := credentials[target.RegistryStr()]; ok {
+		return authn.FromConfig(c), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// githubKeychain authenticates to the GitHub container registry with
+// the token in GITHUB_TOKEN, as available in GitHub Actions jobs
+type githubKeychain struct{}
+
+func (githubKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return authn.Anonymous, nil
+	}
+	for _, r := range githubRegistries {
+		if target.RegistryStr() != r {
+			continue
+		}
+		// The registry ignores the username but requires one
+		username := os.Getenv("GITHUB_ACTOR")
+		if username == "" {
+			username = "tejolote"
+		}
+		return &authn.Basic{Username: username, Password: token}, nil
+	}
+	return authn.Anonymous, nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ociauth
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeychain(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+	t.Setenv("REGISTRY_PASSWORD", "secret")
+
+	require.NoError(t, AddCredentials("registry.example.com=user:env:REGISTRY_PASSWORD"))
+	require.Error(t, AddCredentials("registry.example.com"))
+	require.Error(t, AddCredentials("registry.example.com=user"))
+	require.Error(t, AddCredentials("registry.example.com=user:env:UNSET_PASSWORD_VAR"))
+
+	for ref, expected := range map[string]*authn.AuthConfig{
+		"registry.example.com/image:v1": {Username: "user", Password: "secret"},
+		"ghcr.io/org/image:v1":          {Username: "octocat", Password: "gh-token"},
+		"quay.io/org/image:v1":          {},
+	} {
+		r, err := name.ParseReference(ref)
+		require.NoError(t, err)
+		auth, err := Keychain().Resolve(r.Context())
+		require.NoError(t, err)
+		conf, err := auth.Authorization()
+		require.NoError(t, err)
+		require.Equal(t, expected, conf, ref)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package output writes the files tejolote produces so that reruns of
+// a step never leave a truncated file behind or silently replace one
+// written before.
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Overwrite modes controlling what happens when the output file exists
+const (
+	// OverwriteNever refuses to replace an existing file. Check fails as
+	// soon as the file exists, WriteFile accepts rewriting identical
+	// contents.
+	OverwriteNever = "never"
+
+	// OverwriteIfDifferent replaces existing files only when the new
+	// contents differ, warning about it
+	OverwriteIfDifferent = "if-different"
+
+	// OverwriteAlways writes the file even when it has the same contents
+	OverwriteAlways = "always"
+)
+
+// Modes lists the supported overwrite modes
+var Modes = []string{OverwriteNever, OverwriteIfDifferent, OverwriteAlways}
+
+// ErrExists is returned when the output file exists and the overwrite
+// mode does not allow replacing it
+var ErrExists = errors.New("output file already exists")
+
+// Check returns ErrExists if the file at path exists and the mode never
+// replaces files. Commands check their outputs before doing any work so
+// that a rerun fails before signing or uploading anything.
+func Check(path, mode string) error {
+	if path == "" || mode != OverwriteNever {
+		return nil
+	}
+	_, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("checking existing output: %w", err)
+	}
+	return fmt.Errorf("%s: %w", path, ErrExists)
+}
+
+// WriteFile writes data to path atomically: the data is written to a
+// temporary file in the same directory which is then renamed over the
+// destination, so readers see either the old or the new file complete.
+// It returns false when the file was left untouched.
+func WriteFile(path string, data []byte, mode string) (bool, error) {
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, fmt.Errorf("reading existing output: %w", err)
+	case mode != OverwriteAlways && bytes.Equal(existing, data):
+		logrus.Infof("%s is already up to date", path)
+		return false, nil
+	case mode == OverwriteNever:
+		return false, fmt.Errorf("%s: %w", path, ErrExists)
+	case mode == OverwriteIfDifferent:
+		logrus.Warnf("replacing %s, its contents differ from the new output", path)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return false, fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return false, fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return false, fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), os.FileMode(0o644)); err != nil {
+		return false, fmt.Errorf("setting output permissions: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return false, fmt.Errorf("moving output into place: %w", err)
+	}
+	return true, nil
+}
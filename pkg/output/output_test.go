@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "attestation.json")
+
+	for _, tc := range []struct {
+		mode        string
+		data        string
+		written     bool
+		shouldError bool
+	}{
+		{mode: OverwriteNever, data: "v1", written: true},
+		// Identical reruns are fine in every mode
+		{mode: OverwriteNever, data: "v1", written: false},
+		{mode: OverwriteIfDifferent, data: "v1", written: false},
+		{mode: OverwriteAlways, data: "v1", written: true},
+		{mode: OverwriteNever, data: "v2", shouldError: true},
+		{mode: OverwriteIfDifferent, data: "v2", written: true},
+		{mode: OverwriteAlways, data: "v3", written: true},
+	} {
+		written, err := WriteFile(path, []byte(tc.data), tc.mode)
+		if tc.shouldError {
+			require.ErrorIs(t, err, ErrExists)
+			continue
+		}
+		require.NoError(t, err)
+		require.Equal(t, tc.written, written, "%s %s", tc.mode, tc.data)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, tc.data, string(data))
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "attestation.json")
+	for _, mode := range Modes {
+		require.NoError(t, Check(path, mode))
+	}
+	require.NoError(t, Check("", OverwriteNever))
+
+	require.NoError(t, os.WriteFile(path, []byte("v1"), os.FileMode(0o644)))
+	require.ErrorIs(t, Check(path, OverwriteNever), ErrExists)
+	require.NoError(t, Check(path, OverwriteIfDifferent))
+	require.NoError(t, Check(path, OverwriteAlways))
+}
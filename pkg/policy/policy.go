@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/wasm"
+)
+
+// Result is the outcome of a policy evaluation
+type Result struct {
+	Allow      bool     `json:"allow"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Implementation is the interface of the policy types
+type Implementation interface {
+	Evaluate(context.Context, *attestation.Attestation) (*Result, error)
+}
+
+// Policy wraps a policy implementation with its configuration
+type Policy struct {
+	Name string
+	Type string
+	impl Implementation
+}
+
+// New returns a policy from its configuration
+func New(conf config.Policy) (*Policy, error) {
+	p := &Policy{Name: conf.Name, Type: conf.Type}
+	if p.Name == "" {
+		p.Name = conf.Type
+	}
+	var err error
+	switch conf.Type {
+	case "wasm":
+		p.impl, err = NewWASM(conf.Options)
+	case "rules":
+		p.impl, err = NewRules(conf.Options)
+	default:
+		return nil, fmt.Errorf("unknown policy type %q", conf.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s policy: %w", p.Name, err)
+	}
+	return p, nil
+}
+
+// Evaluate runs the policy over the attestation
+func (p *Policy) Evaluate(ctx context.Context, att *attestation.Attestation) (*Result, error) {
+	res, err := p.impl.Evaluate(ctx, att)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating %s policy: %w", p.Name, err)
+	}
+	return res, nil
+}
+
+// FromFile returns a policy from a file passed in the command line.
+// WASM modules (.wasm) are loaded as WASM policies, other files are
+// read as rules.
+func FromFile(file string) (*Policy, error) {
+	conf := config.Policy{Type: "rules", Name: filepath.Base(file), Options: map[string]string{"rules": file}}
+	if filepath.Ext(file) == ".wasm" {
+		conf.Type, conf.Options = "wasm", map[string]string{"module": file}
+	}
+	return New(conf)
+}
+
+// EvaluateAll runs all the policies and returns an error listing the
+// violations of those that do not allow the attestation
+func EvaluateAll(ctx context.Context, policies []*Policy, att *attestation.Attestation) error {
+	failed := []string{}
+	for _, p := range policies {
+		res, err := p.Evaluate(ctx, att)
+		if err != nil {
+			return err
+		}
+		if res.Allow {
+			continue
+		}
+		msg := p.Name
+		if len(res.Violations) > 0 {
+			msg += ": " + strings.Join(res.Violations, "; ")
+		}
+		failed = append(failed, msg)
+	}
+	if len(failed) > 0 {
+		return errors.New("attestation denied by policy " + strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// WASM evaluates the attestation with the "evaluate" entry point of
+// a WASM plugin. The plugin receives the in-toto statement as JSON and
+// returns a Result object. The module path is set in the "module"
+// option, "timeout" and "memory-pages" limit the resources of each call.
+type WASM struct {
+	plugin *wasm.Plugin
+}
+
+func NewWASM(options map[string]string) (*WASM, error) {
+	path, ok := options["module"]
+	if !ok || path == "" {
+		return nil, errors.New("wasm policy needs a module option")
+	}
+	opts, err := wasm.PluginOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	plugin, err := wasm.LoadPlugin(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("loading wasm plugin: %w", err)
+	}
+	return &WASM{plugin: plugin}, nil
+}
+
+func (w *WASM) Evaluate(ctx context.Context, att *attestation.Attestation) (*Result, error) {
+	res := &Result{}
+	if err := w.plugin.Call(ctx, "evaluate", att, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+)
+
+type fakePolicy struct {
+	res *Result
+}
+
+func (f *fakePolicy) Evaluate(context.Context, *attestation.Attestation) (*Result, error) {
+	return f.res, nil
+}
+
+func TestEvaluateAll(t *testing.T) {
+	ctx := context.Background()
+	allow := &Policy{Name: "allow", impl: &fakePolicy{res: &Result{Allow: true}}}
+	deny := &Policy{Name: "deny", impl: &fakePolicy{res: &Result{Violations: []string{"no subjects", "unsigned"}}}}
+
+	require.NoError(t, EvaluateAll(ctx, nil, nil))
+	require.NoError(t, EvaluateAll(ctx, []*Policy{allow}, nil))
+
+	err := EvaluateAll(ctx, []*Policy{allow, deny}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "deny: no subjects; unsigned")
+
+	_, err = New(config.Policy{Type: "rego"})
+	require.Error(t, err)
+	_, err = New(config.Policy{Type: "wasm"})
+	require.Error(t, err)
+}
+
+func TestRules(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "release.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+builderIds: ["https://builders.example.com/*"]
+subjects: ["gs://bucket/release/*"]
+minSubjects: 1
+requireDigest: sha256
+requireMaterials: true
+requireComplete: [materials]
+outcomes: [success]
+`), 0o600))
+
+	p, err := FromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "rules", p.Type)
+	require.Equal(t, "release.yaml", p.Name)
+
+	att := attestation.New().SLSA()
+	att.Predicate.Builder.ID = "https://builders.example.com/release"
+	att.Predicate.Materials = []slsa.ProvenanceMaterial{{URI: "git+https://github.com/example/repo"}}
+	att.Predicate.Metadata.Completeness.Materials = true
+	att.Predicate.RunOutcome = &attestation.RunOutcome{Status: "success"}
+	att.Subject = []attestation.Subject{{Subject: intoto.Subject{Name: "gs://bucket/release/app.tar.gz", Digest: slsa.DigestSet{"sha256": "abc"}}}}
+	res, err := p.Evaluate(ctx, att)
+	require.NoError(t, err)
+	require.True(t, res.Allow, res.Violations)
+
+	att.Predicate.Builder.ID = "https://evil.example.com/builder"
+	att.Predicate.Materials = nil
+	att.Predicate.Metadata.Completeness.Materials = false
+	att.Predicate.RunOutcome.Status = "cancelled"
+	att.Subject = append(att.Subject, attestation.Subject{Subject: intoto.Subject{Name: "gs://bucket/staging/app.tar.gz", Digest: slsa.DigestSet{"sha512": "abc"}}})
+	res, err = p.Evaluate(ctx, att)
+	require.NoError(t, err)
+	require.False(t, res.Allow)
+	require.Equal(t, []string{
+		`builder "https://evil.example.com/builder" is not allowed`,
+		"subject gs://bucket/staging/app.tar.gz is outside the allowed patterns",
+		"subject gs://bucket/staging/app.tar.gz has no sha256 digest",
+		"attestation has no materials",
+		"materials are not complete",
+		`run outcome "cancelled" is not allowed`,
+	}, res.Violations)
+
+	require.NoError(t, os.WriteFile(file, []byte("requireComplete: [everything]\n"), 0o600))
+	_, err = FromFile(file)
+	require.Error(t, err)
+	require.NoError(t, os.WriteFile(file, []byte("builder: x\n"), 0o600))
+	_, err = FromFile(file)
+	require.Error(t, err)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+)
+
+// Rules checks the attestation against a set of declarative rules read
+// from the YAML or JSON file in the "rules" option. Patterns are glob
+// patterns as matched by path.Match, * does not match a slash.
+type Rules struct {
+	// BuilderIDs are patterns of the allowed builder IDs
+	BuilderIDs []string `json:"builderIds,omitempty"`
+
+	// BuildTypes are patterns of the allowed build types
+	BuildTypes []string `json:"buildTypes,omitempty"`
+
+	// Subjects are patterns every subject name must match
+	Subjects []string `json:"subjects,omitempty"`
+
+	// MinSubjects is the minimum number of subjects
+	MinSubjects int `json:"minSubjects,omitempty"`
+
+	// RequireDigest is a digest algorithm every subject must have (eg sha256)
+	RequireDigest string `json:"requireDigest,omitempty"`
+
+	// RequireMaterials denies attestations without materials
+	RequireMaterials bool `json:"requireMaterials,omitempty"`
+
+	// RequireComplete lists the completeness claims the predicate must
+	// make (parameters, environment, materials)
+	RequireComplete []string `json:"requireComplete,omitempty"`
+
+	// Outcomes are the allowed run outcomes (success, failure,
+	// cancelled, timeout). Attestations without an outcome are denied.
+	Outcomes []string `json:"outcomes,omitempty"`
+}
+
+// completenessClaims are the values accepted in RequireComplete
+var completenessClaims = []string{"parameters", "environment", "materials"}
+
+func NewRules(options map[string]string) (*Rules, error) {
+	file, ok := options["rules"]
+	if !ok || file == "" {
+		return nil, errors.New("rules policy needs a rules option")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file: %w", err)
+	}
+	rules := &Rules{}
+	if err := yaml.UnmarshalStrict(data, rules); err != nil {
+		return nil, fmt.Errorf("parsing rules file %s: %w", file, err)
+	}
+	for _, p := range slices.Concat(rules.BuilderIDs, rules.BuildTypes, rules.Subjects) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	for _, c := range rules.RequireComplete {
+		if !slices.Contains(completenessClaims, c) {
+			return nil, fmt.Errorf("unknown completeness claim %q", c)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rules) Evaluate(_ context.Context, att *attestation.Attestation) (*Result, error) {
+	pred := att.Predicate
+	violations := []string{}
+	if len(r.BuilderIDs) > 0 && !matchAny(r.BuilderIDs, pred.Builder.ID) {
+		violations = append(violations, fmt.Sprintf("builder %q is not allowed", pred.Builder.ID))
+	}
+	if len(r.BuildTypes) > 0 && !matchAny(r.BuildTypes, pred.BuildType) {
+		violations = append(violations, fmt.Sprintf("build type %q is not allowed", pred.BuildType))
+	}
+	if len(att.Subject) < r.MinSubjects {
+		violations = append(violations, fmt.Sprintf("attestation has %d subjects, at least %d required", len(att.Subject), r.MinSubjects))
+	}
+	for _, s := range att.Subject {
+		if len(r.Subjects) > 0 && !matchAny(r.Subjects, s.Name) {
+			violations = append(violations, fmt.Sprintf("subject %s is outside the allowed patterns", s.Name))
+		}
+		if r.RequireDigest != "" && s.Digest[r.RequireDigest] == "" {
+			violations = append(violations, fmt.Sprintf("subject %s has no %s digest", s.Name, r.RequireDigest))
+		}
+	}
+	if r.RequireMaterials && len(pred.Materials) == 0 {
+		violations = append(violations, "attestation has no materials")
+	}
+	for _, c := range r.RequireComplete {
+		complete := false
+		if pred.Metadata != nil {
+			switch c {
+			case "parameters":
+				complete = pred.Metadata.Completeness.Parameters
+			case "environment":
+				complete = pred.Metadata.Completeness.Environment
+			case "materials":
+				complete = pred.Metadata.Completeness.Materials
+			}
+		}
+		if !complete {
+			violations = append(violations, fmt.Sprintf("%s are not complete", c))
+		}
+	}
+	if len(r.Outcomes) > 0 {
+		switch {
+		case pred.RunOutcome == nil:
+			violations = append(violations, "attestation does not record the run outcome")
+		case !slices.Contains(r.Outcomes, pred.RunOutcome.Status):
+			violations = append(violations, fmt.Sprintf("run outcome %q is not allowed", pred.RunOutcome.Status))
+		}
+	}
+	return &Result{Allow: len(violations) == 0, Violations: violations}, nil
+}
+
+// matchAny returns true if the value matches any of the patterns
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package progress counts the work done by long operations (objects
+// listed, bytes downloaded, files hashed) so it can be reported while
+// tejolote snapshots huge stores. The counters are global, drivers
+// update them and a Reporter prints them.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var listed, downloaded, hashed atomic.Int64
+
+// Counters is a reading of the progress counters
+type Counters struct {
+	Listed     int64 // Objects listed in the stores
+	Downloaded int64 // Bytes downloaded
+	Hashed     int64 // Files hashed
+}
+
+// Listed records n objects listed in a store
+func Listed(n int) {
+	listed.Add(int64(n))
+}
+
+// Downloaded records n bytes downloaded
+func Downloaded(n int64) {
+	downloaded.Add(n)
+}
+
+// Hashed records n files hashed
+func Hashed(n int) {
+	hashed.Add(int64(n))
+}
+
+// Read returns the current value of the counters
+func Read() Counters {
+	return Counters{
+		Listed:     listed.Load(),
+		Downloaded: downloaded.Load(),
+		Hashed:     hashed.Load(),
+	}
+}
+
+// Reset sets all the counters to zero
+func Reset() {
+	listed.Store(0)
+	downloaded.Store(0)
+	hashed.Store(0)
+}
+
+// String returns the counters as a human readable line
+func (c Counters) String() string {
+	return fmt.Sprintf(
+		"listed %d objects, downloaded %s, hashed %d files",
+		c.Listed, formatBytes(c.Downloaded), c.Hashed,
+	)
+}
+
+// IsZero returns true if no work was recorded
+func (c Counters) IsZero() bool {
+	return c == Counters{}
+}
+
+// formatBytes returns a byte count in binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// countingWriter records the bytes written through it as downloaded
+type countingWriter struct {
+	w io.Writer
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	Downloaded(int64(n))
+	return n, err
+}
+
+// Writer wraps w to record the data written to it as downloaded bytes
+func Writer(w io.Writer) io.Writer {
+	return countingWriter{w: w}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounters(t *testing.T) {
+	Reset()
+	defer Reset()
+	require.True(t, Read().IsZero())
+
+	Listed(3)
+	Hashed(2)
+	var b bytes.Buffer
+	_, err := Writer(&b).Write(make([]byte, 1536))
+	require.NoError(t, err)
+	require.Len(t, b.Bytes(), 1536)
+	require.Equal(t, Counters{Listed: 3, Downloaded: 1536, Hashed: 2}, Read())
+	require.Equal(t, "listed 3 objects, downloaded 1.5 KiB, hashed 2 files", Read().String())
+
+	require.Equal(t, "512 B", formatBytes(512))
+	require.Equal(t, "50.0 GiB", formatBytes(50<<30))
+}
+
+func TestReporter(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	_, err := Start("spinner", time.Second)
+	require.Error(t, err)
+
+	// Log lines are only written when the counters change
+	var out bytes.Buffer
+	logger := logrus.StandardLogger()
+	prev := logger.Out
+	logger.SetOutput(&out)
+	defer logger.SetOutput(prev)
+	r, err := Start(ModeLog, 10*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	Hashed(1)
+	time.Sleep(100 * time.Millisecond)
+	r.Stop()
+	require.Equal(t, 1, strings.Count(out.String(), "Progress:"))
+	require.Contains(t, out.String(), "hashed 1 files")
+
+	// The progress line is erased when stopping
+	var bar bytes.Buffer
+	r = &Reporter{mode: ModeBar, out: &bar}
+	r.report(0)
+	require.Contains(t, bar.String(), "| listed 0 objects, downloaded 0 B, hashed 1 files")
+	require.NoError(t, r.Fire(nil))
+	require.True(t, strings.HasSuffix(bar.String(), "\r\033[K"))
+	require.False(t, r.drawn)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// Reporting modes
+const (
+	// ModeAuto draws a progress line when stderr is a terminal outside
+	// of CI and logs the progress otherwise
+	ModeAuto = "auto"
+
+	// ModeLog logs the counters periodically
+	ModeLog = "log"
+
+	// ModeBar redraws a live progress line on stderr
+	ModeBar = "bar"
+
+	// ModeNone disables progress reporting
+	ModeNone = "none"
+)
+
+// Modes are the supported reporting modes
+var Modes = []string{ModeAuto, ModeLog, ModeBar, ModeNone}
+
+// barInterval is how often the progress line is redrawn
+const barInterval = 200 * time.Millisecond
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+// Reporter reports the progress counters until it is stopped. Nothing
+// is reported while the counters do not change, so short commands
+// print nothing.
+type Reporter struct {
+	mode     string
+	interval time.Duration
+	out      io.Writer
+	stop     chan struct{}
+	done     chan struct{}
+
+	mtx   sync.Mutex
+	last  Counters
+	drawn bool
+}
+
+// Start starts reporting the progress in the mode. In log mode the
+// counters are logged every interval.
+func Start(mode string, interval time.Duration) (*Reporter, error) {
+	if !slices.Contains(Modes, mode) {
+		return nil, fmt.Errorf("unknown progress mode %q, must be one of %s", mode, strings.Join(Modes, ", "))
+	}
+	if mode == ModeAuto {
+		mode = ModeLog
+		if os.Getenv("CI") == "" && term.IsTerminal(int(os.Stderr.Fd())) {
+			mode = ModeBar
+		}
+	}
+	r := &Reporter{
+		mode:     mode,
+		interval: interval,
+		out:      os.Stderr,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if mode == ModeNone {
+		close(r.done)
+		return r, nil
+	}
+	if mode == ModeBar {
+		r.interval = barInterval
+		logrus.AddHook(r)
+	}
+	go r.run()
+	return r, nil
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.report(i)
+		}
+	}
+}
+
+// report prints the counters if they changed since the last report
+func (r *Reporter) report(tick int) {
+	c := Read()
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.mode == ModeLog {
+		if c != r.last {
+			logrus.Infof("Progress: %s", c)
+		}
+		r.last = c
+		return
+	}
+	if c.IsZero() {
+		return
+	}
+	fmt.Fprintf(r.out, "\r\033[K%s %s", spinner[tick%len(spinner)], c)
+	r.drawn = true
+}
+
+// clear erases the progress line, it must be called with the lock held
+func (r *Reporter) clear() {
+	if r.drawn {
+		fmt.Fprint(r.out, "\r\033[K")
+		r.drawn = false
+	}
+}
+
+// Stop stops reporting, erasing the progress line
+func (r *Reporter) Stop() {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	close(r.stop)
+	<-r.done
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.clear()
+}
+
+// Levels implements logrus.Hook, the progress line is erased before
+// any log entry is written so they do not mix
+func (r *Reporter) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (r *Reporter) Fire(*logrus.Entry) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.clear()
+	return nil
+}
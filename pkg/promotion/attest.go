@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+)
+
+const (
+	// BuildType is the build type recorded in promotion provenance
+	BuildType = "https://sigs.k8s.io/tejolote/image-promotion@v1"
+
+	// BuilderID identifies tejolote as the verifier of the promotion
+	BuilderID = "https://sigs.k8s.io/tejolote/promotion"
+)
+
+// Attest checks that every digest in the manifest is present in the
+// source registry and that each destination serves it under the tags
+// listed in the manifest. If all images match, it returns a provenance
+// attestation where the promoted images are the subjects and the
+// source images the materials. Mismatches are returned joined in the
+// error.
+func Attest(ctx context.Context, manifestPath string, m *Manifest) (*attestation.Attestation, error) {
+	src, err := m.Source()
+	if err != nil {
+		return nil, err
+	}
+	opts := []crane.Option{
+		crane.WithAuthFromKeychain(ociauth.Keychain()), crane.WithContext(ctx),
+	}
+
+	started := time.Now()
+	att := attestation.New().SLSA()
+	pred := &att.Predicate
+	pred.Builder.ID = BuilderID
+	pred.BuildType = BuildType
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	pred.Invocation.ConfigSource.EntryPoint = filepath.Base(manifestPath)
+	pred.Invocation.ConfigSource.Digest = map[string]string{
+		"sha256": fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+
+	errs := []error{}
+	for _, img := range m.Images {
+		digests := []string{}
+		for d := range img.Dmap {
+			digests = append(digests, d)
+		}
+		sort.Strings(digests)
+
+		for _, d := range digests {
+			digest, err := name.NewDigest(src.Name + "/" + img.Name + "@" + d)
+			if err != nil {
+				return nil, fmt.Errorf("parsing image digest: %w", err)
+			}
+			if _, err := crane.Head(digest.String(), opts...); err != nil {
+				errs = append(errs, fmt.Errorf("source image %s: %w", digest, err))
+				continue
+			}
+			pred.AddMaterial("oci://"+digest.String(), digestSet(digest))
+
+			for _, dest := range m.Destinations() {
+				subjects, err := checkDestination(dest.Name+"/"+img.Name, digest, img.Dmap[d], opts)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				att.AddSubjects(subjects...)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("verifying promotion: %w", errors.Join(errs...))
+	}
+
+	logrus.Infof("Verified %d promoted images in %d registries", len(att.Subject), len(m.Destinations()))
+	finished := time.Now()
+	pred.Metadata.BuildStartedOn = &started
+	pred.Metadata.BuildFinishedOn = &finished
+	pred.Metadata.Completeness.Materials = true
+	return att, nil
+}
+
+// checkDestination verifies that the tags of the promoted image point
+// to the expected digest in a destination repository. Images promoted
+// without tags are checked by digest.
+func checkDestination(repo string, digest name.Digest, tags []string, opts []crane.Option) ([]intoto.Subject, error) {
+	set := digestSet(digest)
+	if len(tags) == 0 {
+		ref := repo + "@" + digest.DigestStr()
+		if _, err := crane.Head(ref, opts...); err != nil {
+			return nil, fmt.Errorf("promoted image %s: %w", ref, err)
+		}
+		return []intoto.Subject{{Name: "oci://" + ref, Digest: set}}, nil
+	}
+
+	subjects := []intoto.Subject{}
+	for _, tag := range tags {
+		ref := repo + ":" + tag
+		got, err := crane.Digest(ref, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("promoted image %s: %w", ref, err)
+		}
+		if got != digest.DigestStr() {
+			return nil, fmt.Errorf("promoted image %s has digest %s, manifest lists %s", ref, got, digest.DigestStr())
+		}
+		subjects = append(subjects, intoto.Subject{Name: "oci://" + ref, Digest: set})
+	}
+	return subjects, nil
+}
+
+// digestSet returns the digest of an image reference as a digest set
+func digestSet(digest name.Digest) common.DigestSet {
+	algo, hex, _ := strings.Cut(digest.DigestStr(), ":")
+	return common.DigestSet{algo: hex}
+}
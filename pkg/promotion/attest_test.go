@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttest(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, host+"/staging/foo:"+digest.Hex[:12]))
+	require.NoError(t, crane.Push(img, host+"/prod/foo:v1.0.0"))
+
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "promoter-manifest.yaml")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(fmt.Sprintf(`registries:
+- name: %s/staging
+  src: true
+- name: %s/prod
+  service-account: promoter@example.iam.gserviceaccount.com
+`, host, host)), os.FileMode(0o644)))
+	imagesPath := filepath.Join(dir, "images.yaml")
+	require.NoError(t, os.WriteFile(imagesPath, []byte(fmt.Sprintf(`- name: foo
+  dmap:
+    "%s": ["v1.0.0"]
+`, digest)), os.FileMode(0o644)))
+
+	m, err := LoadManifest(manifestPath, imagesPath)
+	require.NoError(t, err)
+	require.Len(t, m.Images, 1)
+	require.Len(t, m.Destinations(), 1)
+
+	att, err := Attest(context.Background(), manifestPath, m)
+	require.NoError(t, err)
+	require.Equal(t, BuildType, att.Predicate.BuildType)
+	require.Len(t, att.Subject, 1)
+	require.Equal(t, "oci://"+host+"/prod/foo:v1.0.0", att.Subject[0].Name)
+	require.Equal(t, digest.Hex, att.Subject[0].Digest["sha256"])
+	require.Len(t, att.Predicate.Materials, 1)
+	require.Equal(t, "oci://"+host+"/staging/foo@"+digest.String(), att.Predicate.Materials[0].URI)
+
+	// Moving the tag breaks the promotion
+	other, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(other, host+"/prod/foo:v1.0.0"))
+	_, err = Attest(context.Background(), manifestPath, m)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "prod/foo:v1.0.0 has digest")
+}
+
+func TestLoadManifestSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "promoter-manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("registries:\n- name: gcr.io/prod\n"), os.FileMode(0o644)))
+	_, err := LoadManifest(path, "")
+	require.Error(t, err)
+}
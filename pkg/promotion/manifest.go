@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package promotion verifies image promotions described in the
+// manifests of the Kubernetes image promoter (cip) and attests them.
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest is an image promoter manifest. It lists the source registry,
+// the registries where images are promoted to and the image digests
+// with the tags they get in the destination registries.
+type Manifest struct {
+	Registries []Registry `json:"registries"`
+	Images     []Image    `json:"images,omitempty"`
+}
+
+// Registry is a registry path listed in the manifest
+type Registry struct {
+	Name           string `json:"name"`
+	ServiceAccount string `json:"service-account,omitempty"`
+	Src            bool   `json:"src,omitempty"`
+}
+
+// Image is an image to promote. Dmap maps the digests to promote to
+// the list of tags they get in the destination.
+type Image struct {
+	Name string              `json:"name"`
+	Dmap map[string][]string `json:"dmap"`
+}
+
+// LoadManifest reads a promoter manifest. Manifests in the split layout
+// used in k8s.io keep the image list in a separate images.yaml file,
+// when imagesPath is set the images are read from it.
+func LoadManifest(manifestPath, imagesPath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+
+	if imagesPath != "" {
+		data, err := os.ReadFile(imagesPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading images file: %w", err)
+		}
+		images := []Image{}
+		if err := yaml.Unmarshal(data, &images); err != nil {
+			return nil, fmt.Errorf("parsing images file: %w", err)
+		}
+		m.Images = append(m.Images, images...)
+	}
+
+	if _, err := m.Source(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Source returns the source registry of the manifest
+func (m *Manifest) Source() (Registry, error) {
+	var src *Registry
+	for i := range m.Registries {
+		if !m.Registries[i].Src {
+			continue
+		}
+		if src != nil {
+			return Registry{}, errors.New("manifest has more than one source registry")
+		}
+		src = &m.Registries[i]
+	}
+	if src == nil {
+		return Registry{}, errors.New("manifest has no source registry")
+	}
+	return *src, nil
+}
+
+// Destinations returns the registries images are promoted to
+func (m *Manifest) Destinations() []Registry {
+	dests := []Registry{}
+	for _, r := range m.Registries {
+		if !r.Src {
+			dests = append(dests, r)
+		}
+	}
+	return dests
+}
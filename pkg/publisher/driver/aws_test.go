@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWS records the requests it receives. SNS speaks the query API
+// and SQS the JSON protocol, respond answers each request.
+func fakeAWS(t *testing.T, endpointEnv string, respond func(w http.ResponseWriter, r *http.Request)) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		respond(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv(endpointEnv, srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+}
+
+func TestSNS(t *testing.T) {
+	requests := []url.Values{}
+	fakeAWS(t, "AWS_ENDPOINT_URL_SNS", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, r.PostForm)
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, "<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>")
+	})
+
+	s, err := NewSNS("sns://arn:aws:sns:us-east-2:123456789012:tejolote")
+	require.NoError(t, err)
+	require.Equal(t, "arn:aws:sns:us-east-2:123456789012:tejolote", s.TopicARN)
+	require.Equal(t, "us-east-2", s.Region)
+
+	_, err = NewSNS("sns://arn:aws:sqs:us-east-2:123456789012:tejolote")
+	require.Error(t, err)
+
+	require.NoError(t, s.Publish(context.Background(), []byte(`{"spec":"gcb://p/b"}`)))
+	require.Len(t, requests, 1)
+	require.Equal(t, "Publish", requests[0].Get("Action"))
+	require.Equal(t, s.TopicARN, requests[0].Get("TopicArn"))
+	require.Equal(t, `{"spec":"gcb://p/b"}`, requests[0].Get("Message"))
+}
+
+func TestSQS(t *testing.T) {
+	type sendMessage struct {
+		QueueURL    string `json:"QueueUrl"`
+		MessageBody string `json:"MessageBody"`
+	}
+	requests := []sendMessage{}
+	targets := []string{}
+	fakeAWS(t, "AWS_ENDPOINT_URL_SQS", func(w http.ResponseWriter, r *http.Request) {
+		m := sendMessage{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, m)
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		// The client checks the digest of the body the queue received
+		fmt.Fprintf(w, `{"MessageId":"1","MD5OfMessageBody":"%x"}`, md5.Sum([]byte(m.MessageBody))) //nolint: gosec
+	})
+
+	q, err := NewSQS("sqs://sqs.us-east-2.amazonaws.com/123456789012/tejolote")
+	require.NoError(t, err)
+	require.Equal(t, "https://sqs.us-east-2.amazonaws.com/123456789012/tejolote", q.QueueURL)
+	require.Equal(t, "us-east-2", q.Region)
+
+	_, err = NewSQS("sqs://sqs.us-east-2.amazonaws.com/tejolote")
+	require.Error(t, err)
+
+	require.NoError(t, q.Publish(context.Background(), []byte(`{"spec":"gcb://p/b"}`)))
+	require.Len(t, requests, 1)
+	require.Equal(t, "AmazonSQS.SendMessage", targets[0])
+	require.Equal(t, q.QueueURL, requests[0].QueueURL)
+	require.Equal(t, `{"spec":"gcb://p/b"}`, requests[0].MessageBody)
+}
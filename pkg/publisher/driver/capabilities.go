@@ -0,0 +1,25 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+// Capabilities describes the features supported by a publisher driver
+type Capabilities struct {
+	// MaxMessageSize is the largest message the driver publishes, larger
+	// messages are sent as claim checks. It is zero when the limit is
+	// announced by the server on connection.
+	MaxMessageSize int `json:"max_message_size"`
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const natsTimeout = 10 * time.Second
+
+// NATS publishes messages to a NATS subject. The connection to the
+// server is opened when the publisher is created:
+// nats://[user:pass@]server[:port]/subject
+type NATS struct {
+	Server   string
+	Subject  string
+	User     string
+	Password string
+	conn     *nats.Conn
+}
+
+// NewNATS returns a NATS publisher connected to the server in the
+// spec url. A user without a password is sent as a token.
+func NewNATS(specURL string) (*NATS, error) {
+	n, err := parseNATSURL(specURL)
+	if err != nil {
+		return nil, err
+	}
+	opts := []nats.Option{
+		nats.Name("tejolote"),
+		nats.Timeout(natsTimeout),
+		nats.NoReconnect(),
+	}
+	if n.User != "" && n.Password == "" {
+		opts = append(opts, nats.Token(n.User))
+	} else if n.User != "" {
+		opts = append(opts, nats.UserInfo(n.User, n.Password))
+	}
+	n.conn, err = nats.Connect("nats://"+n.Server, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS server: %w", err)
+	}
+	return n, nil
+}
+
+// parseNATSURL reads the server, subject and credentials from the spec url
+func parseNATSURL(specURL string) (*NATS, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing NATS spec url: %w", err)
+	}
+	if u.Scheme != "nats" {
+		return nil, errors.New("spec url is not a nats url")
+	}
+	subject := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", ".")
+	if subject == "" {
+		return nil, errors.New("nats url does not specify a subject")
+	}
+	server := u.Host
+	if u.Port() == "" {
+		server = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	n := &NATS{
+		Server:  server,
+		Subject: subject,
+	}
+	if u.User != nil {
+		n.User = u.User.Username()
+		n.Password, _ = u.User.Password()
+	}
+	return n, nil
+}
+
+// Publish publishes the data to the subject. To make sure the server
+// processed the message, it flushes the connection, waiting for the
+// server to answer a PING.
+func (n *NATS) Publish(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, natsTimeout)
+	defer cancel()
+	if err := n.conn.Publish(n.Subject, data); err != nil {
+		return fmt.Errorf("publishing to NATS subject: %w", err)
+	}
+	if err := n.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("flushing NATS connection: %w", err)
+	}
+	if err := n.conn.LastError(); err != nil {
+		return fmt.Errorf("NATS server error: %w", err)
+	}
+	logrus.Infof("published %d bytes to NATS subject %s", len(data), n.Subject)
+	return nil
+}
+
+// Capabilities returns the features supported by the driver, the message
+// size limit is announced by the server
+func (n *NATS) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// MaxMessageSize returns the max_payload announced by the server
+func (n *NATS) MaxMessageSize() int {
+	return int(n.conn.MaxPayload())
+}
+
+// Close closes the connection to the server
+func (n *NATS) Close() error {
+	n.conn.Close()
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/gcp"
+)
+
+// MaxPubSubMessageSize is the largest payload we will send inline to
+// a Pub/Sub topic. The service limit is 10MB, we leave some room for
+// the message attributes and envelope.
+const MaxPubSubMessageSize = 9 * 1024 * 1024
+
+type PubSub struct {
+	ProjectID string
+	Topic     string
+}
+
+func NewPubSub(specURL string) (*PubSub, error) {
+	// projects/puerco-chainguard/topics/slsa
+	parts := strings.Split(specURL, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" {
+		return nil, errors.New("invalid topic specifier, format: projects/PROJECTID/topics/TOPICNAME")
+	}
+	return &PubSub{
+		ProjectID: parts[1],
+		Topic:     parts[3],
+	}, nil
+}
+
+// Publish sends the data to the Pub/Sub topic
+func (ps *PubSub) Publish(ctx context.Context, data []byte) error {
+	client, err := gcp.NewPubSubClient(ctx, ps.ProjectID)
+	if err != nil {
+		return fmt.Errorf("creating pubsub client: %w", err)
+	}
+	defer client.Close()
+
+	topic := client.Topic(ps.Topic)
+	if _, err := topic.Publish(ctx, &pubsub.Message{Data: data}).Get(ctx); err != nil {
+		return fmt.Errorf("publishing to pubsub topic: %w", err)
+	}
+	logrus.Infof("pushed build data to topic projects/%s/topics/%s", ps.ProjectID, ps.Topic)
+	return nil
+}
+
+// Capabilities returns the features supported by the driver
+func (ps *PubSub) Capabilities() Capabilities {
+	return Capabilities{MaxMessageSize: ps.MaxMessageSize()}
+}
+
+func (ps *PubSub) MaxMessageSize() int {
+	return MaxPubSubMessageSize
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/sirupsen/logrus"
+)
+
+// SNS publishes messages to an Amazon SNS topic, specified by its ARN:
+// sns://arn:aws:sns:REGION:ACCOUNT:TOPIC
+type SNS struct {
+	TopicARN string
+	Region   string
+	client   *sns.Client
+}
+
+func NewSNS(specURL string) (*SNS, error) {
+	arn, ok := strings.CutPrefix(specURL, "sns://")
+	if !ok {
+		return nil, fmt.Errorf("spec url is not an sns url")
+	}
+	// arn:partition:sns:region:account:topic
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" || parts[3] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("invalid topic ARN, format: sns://arn:aws:sns:REGION:ACCOUNT:TOPIC")
+	}
+	cfg, err := awsConfig("sns", parts[3])
+	if err != nil {
+		return nil, err
+	}
+	return &SNS{TopicARN: arn, Region: parts[3], client: sns.NewFromConfig(cfg)}, nil
+}
+
+// Publish sends the data to the SNS topic
+func (s *SNS) Publish(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, awsTimeout)
+	defer cancel()
+	if _, err := s.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Message:  aws.String(string(data)),
+	}); err != nil {
+		return fmt.Errorf("publishing to sns topic: %w", err)
+	}
+	logrus.Infof("published %d bytes to SNS topic %s", len(data), s.TopicARN)
+	return nil
+}
+
+// Capabilities returns the features supported by the driver
+func (s *SNS) Capabilities() Capabilities {
+	return Capabilities{MaxMessageSize: s.MaxMessageSize()}
+}
+
+func (s *SNS) MaxMessageSize() int {
+	return awsMaxMessageSize
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/sirupsen/logrus"
+)
+
+// SQS sends messages to an Amazon SQS queue. The spec URL is the queue
+// URL with the sqs scheme:
+// sqs://sqs.REGION.amazonaws.com/ACCOUNT/QUEUE
+type SQS struct {
+	QueueURL string
+	Region   string
+	client   *sqs.Client
+}
+
+func NewSQS(specURL string) (*SQS, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing SQS spec url: %w", err)
+	}
+	if u.Scheme != "sqs" {
+		return nil, fmt.Errorf("spec url is not an sqs url")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) != 2 {
+		return nil, fmt.Errorf("invalid queue url, format: sqs://sqs.REGION.amazonaws.com/ACCOUNT/QUEUE")
+	}
+	q := &SQS{QueueURL: "https://" + u.Host + "/" + strings.Join(parts, "/")}
+	// Queue hosts are sqs.REGION.amazonaws.com, others take the region
+	// from the AWS configuration
+	if hostParts := strings.Split(u.Hostname(), "."); len(hostParts) > 2 && hostParts[0] == "sqs" {
+		q.Region = hostParts[1]
+	}
+	cfg, err := awsConfig("sqs", q.Region)
+	if err != nil {
+		return nil, err
+	}
+	q.client = sqs.NewFromConfig(cfg)
+	return q, nil
+}
+
+// Publish sends the data as a message to the queue
+func (q *SQS) Publish(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, awsTimeout)
+	defer cancel()
+	if _, err := q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.QueueURL),
+		MessageBody: aws.String(string(data)),
+	}); err != nil {
+		return fmt.Errorf("sending message to sqs queue: %w", err)
+	}
+	logrus.Infof("sent %d bytes to SQS queue %s", len(data), q.QueueURL)
+	return nil
+}
+
+// Capabilities returns the features supported by the driver
+func (q *SQS) Capabilities() Capabilities {
+	return Capabilities{MaxMessageSize: q.MaxMessageSize()}
+}
+
+func (q *SQS) MaxMessageSize() int {
+	return awsMaxMessageSize
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookSecretEnv is the environment variable holding the key used
+// to sign the messages posted to webhooks
+const WebhookSecretEnv = "TEJOLOTE_WEBHOOK_SECRET"
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of the request body,
+// in the same format GitHub uses for its webhooks: sha256=HEXDIGEST
+const WebhookSignatureHeader = "X-Tejolote-Signature-256"
+
+// MaxWebhookMessageSize is the largest message posted to a webhook,
+// larger payloads are sent as claim checks
+const MaxWebhookMessageSize = 9 * 1024 * 1024
+
+const webhookTimeout = 30 * time.Second
+
+// Webhook publishes messages by POSTing them as JSON to an HTTP
+// endpoint. When a secret is set in the environment, the body is
+// signed with HMAC-SHA256 so the receiver can authenticate it.
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+func NewWebhook(specURL string) (*Webhook, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook spec url: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, errors.New("spec url is not an http(s) url")
+	}
+	if u.Host == "" {
+		return nil, errors.New("webhook url does not specify a host")
+	}
+	if u.Scheme == "http" {
+		logrus.Warnf("Messages to %s will be posted without TLS", u.Host)
+	}
+	return &Webhook{
+		URL:    specURL,
+		Secret: os.Getenv(WebhookSecretEnv),
+	}, nil
+}
+
+// signature returns the value of the signature header for the data
+func (wh *Webhook) signature(data []byte) string {
+	mac := hmac.New(sha256.New, []byte(wh.Secret))
+	mac.Write(data)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publish posts the data to the webhook. Any response other than
+// 2xx is considered a failure.
+func (wh *Webhook) Publish(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tejolote")
+	if wh.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, wh.signature(data))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint: errcheck
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	logrus.Infof("posted %d bytes to webhook %s", len(data), req.URL.Redacted())
+	return nil
+}
+
+// Capabilities returns the features supported by the driver
+func (wh *Webhook) Capabilities() Capabilities {
+	return Capabilities{MaxMessageSize: wh.MaxMessageSize()}
+}
+
+func (wh *Webhook) MaxMessageSize() int {
+	return MaxWebhookMessageSize
+}
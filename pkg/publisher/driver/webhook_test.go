@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhook(t *testing.T) {
+	t.Setenv(WebhookSecretEnv, "s3cr3t")
+	wh, err := NewWebhook("https://hooks.example.com/tejolote")
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", wh.Secret)
+
+	_, err = NewWebhook("nats://nats.example.com/subject")
+	require.Error(t, err)
+
+	_, err = NewWebhook("https:///tejolote")
+	require.Error(t, err)
+}
+
+func TestWebhookPublish(t *testing.T) {
+	var body []byte
+	var method, contentType, signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body) //nolint: errcheck
+		signature = r.Header.Get(WebhookSignatureHeader)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	data := []byte(`{"spec":"gcb://project/build"}`)
+
+	// Without a secret messages are not signed
+	wh := &Webhook{URL: srv.URL + "/hook"}
+	require.NoError(t, wh.Publish(context.Background(), data))
+	require.Equal(t, http.MethodPost, method)
+	require.Equal(t, "application/json", contentType)
+	require.Equal(t, data, body)
+	require.Empty(t, signature)
+
+	wh.Secret = "It's a Secret to Everybody"
+	require.NoError(t, wh.Publish(context.Background(), data))
+	require.Equal(t, "sha256=1fec7ae43ae967e9adf7f0ffaabcecf0f27877dfccde974acaa475261ad5f6e5", signature)
+
+	wh.URL = srv.URL + "/fail"
+	err := wh.Publish(context.Background(), data)
+	require.ErrorContains(t, err, "403")
+}
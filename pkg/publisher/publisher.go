@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/tejolote/pkg/publisher/driver"
+)
+
+// Publisher sends tejolote messages to an event transport
+type Publisher struct {
+	SpecURL string
+	Driver  Implementation
+}
+
+type Implementation interface {
+	Publish(context.Context, []byte) error
+	MaxMessageSize() int
+	Capabilities() driver.Capabilities
+}
+
+// New returns a publisher with the driver derived from the spec URL.
+// GCP Pub/Sub topics are specified by their resource name
+// (projects/PROJECTID/topics/TOPICNAME) and SNS topics by their ARN
+// (sns://arn:aws:sns:REGION:ACCOUNT:TOPIC), other transports use a URL.
+func New(specURL string) (p Publisher, err error) {
+	p = Publisher{}
+	var impl Implementation
+	switch {
+	case strings.HasPrefix(specURL, "projects/"):
+		impl, err = driver.NewPubSub(specURL)
+	case strings.HasPrefix(specURL, "sns://"):
+		// ARNs are not valid URL hosts
+		impl, err = driver.NewSNS(specURL)
+	default:
+		u, err2 := url.Parse(specURL)
+		if err2 != nil {
+			return p, fmt.Errorf("parsing publisher spec URL %s: %w", specURL, err2)
+		}
+		switch u.Scheme {
+		case "nats":
+			impl, err = driver.NewNATS(specURL)
+		case "https", "http":
+			impl, err = driver.NewWebhook(specURL)
+		case "sqs":
+			impl, err = driver.NewSQS(specURL)
+		default:
+			err = fmt.Errorf("%s is not a supported publisher URL", specURL)
+		}
+	}
+	if err != nil {
+		return p, fmt.Errorf("initializing publisher: %w", err)
+	}
+	p.SpecURL = specURL
+	p.Driver = impl
+	return p, nil
+}
+
+// Publish sends the message data through the driver
+func (p *Publisher) Publish(ctx context.Context, data []byte) error {
+	return p.Driver.Publish(ctx, data)
+}
+
+// MaxMessageSize returns the largest message the driver can send
+func (p *Publisher) MaxMessageSize() int {
+	return p.Driver.MaxMessageSize()
+}
+
+// Schemes returns the spec URL schemes of the publisher drivers and the
+// capabilities of each one. Pub/Sub topics are listed by the form of their
+// resource name.
+func Schemes() map[string]driver.Capabilities {
+	return map[string]driver.Capabilities{
+		"projects/*/topics/*": (&driver.PubSub{}).Capabilities(),
+		"sns":                 (&driver.SNS{}).Capabilities(),
+		"sqs":                 (&driver.SQS{}).Capabilities(),
+		"nats":                (&driver.NATS{}).Capabilities(),
+		"http":                (&driver.Webhook{}).Capabilities(),
+		"https":               (&driver.Webhook{}).Capabilities(),
+	}
+}
+
+// Close releases the connections held by the driver, if any
+func (p *Publisher) Close() error {
+	if c, ok := p.Driver.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
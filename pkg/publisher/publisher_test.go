@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package publisher
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// natsServer accepts a NATS connection on a local port and returns
+// its address
+func natsServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"max_payload\":65536}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "PING") {
+				fmt.Fprint(conn, "PONG\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// TestSchemes checks that every scheme listed has a driver and that the
+// driver reports the capabilities listed
+func TestSchemes(t *testing.T) {
+	specs := map[string]string{
+		"projects/*/topics/*": "projects/example-project/topics/builds",
+		"sns":                 "sns://arn:aws:sns:us-east-1:123456789012:builds",
+		"sqs":                 "sqs://sqs.us-east-1.amazonaws.com/123456789012/builds",
+		"nats":                "nats://" + natsServer(t) + "/tejolote.start",
+		"http":                "http://127.0.0.1/hooks/tejolote",
+		"https":               "https://hooks.example.com/tejolote",
+	}
+	schemes := Schemes()
+	require.Len(t, schemes, len(specs))
+	for scheme, caps := range schemes {
+		t.Run(scheme, func(t *testing.T) {
+			spec, ok := specs[scheme]
+			require.True(t, ok, "no spec URL to test scheme %s", scheme)
+			p, err := New(spec)
+			require.NoError(t, err)
+			defer p.Close()
+			require.Equal(t, caps, p.Driver.Capabilities())
+		})
+	}
+
+	_, err := New("amqp://broker.example.com/builds")
+	require.Error(t, err)
+}
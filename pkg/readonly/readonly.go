@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package readonly implements the global read-only mode. When enabled,
+// every code path that would write to the stores or build systems
+// tejolote observes (uploads to buckets, release assets, mutating API
+// calls) fails before contacting the remote system.
+//
+// Write paths call Check before doing anything. As a second line of
+// defense, the HTTP clients of the stores, build systems and
+// attestation stores are built with NewClient or Transport, which
+// refuse any request that could change the remote state, so a write
+// that misses its Check still never leaves the process.
+//
+// Writing to local files and publishing messages to topics, queues and
+// webhooks are not affected, but a message too large to publish inline
+// fails as its claim check would be uploaded to a bucket.
+package readonly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// ErrReadOnly is returned when a write is attempted in read-only mode
+var ErrReadOnly = errors.New("refusing to write in read-only mode")
+
+var enabled atomic.Bool
+
+// Enable turns on read-only mode for the rest of the process
+func Enable() {
+	enabled.Store(true)
+}
+
+// Disable turns off read-only mode
+func Disable() {
+	enabled.Store(false)
+}
+
+// Enabled returns true if read-only mode is on
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Check returns an error wrapping ErrReadOnly if read-only mode is
+// enabled. It must be called before any write to a remote system,
+// operation describes the write in the error.
+func Check(operation string) error {
+	if !enabled.Load() {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", operation, ErrReadOnly)
+}
+
+// safeMethods are the HTTP methods that do not change the remote state
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// transport refuses unsafe requests in read-only mode
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport returns a RoundTripper that fails requests with methods
+// other than GET, HEAD and OPTIONS while read-only mode is enabled.
+// Other requests are sent with base, http.DefaultTransport if nil.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := CheckRequest(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CheckRequest returns an error wrapping ErrReadOnly if read-only mode
+// is enabled and the request method is other than GET, HEAD or OPTIONS.
+// It guards http clients that cannot be built with Transport.
+func CheckRequest(req *http.Request) error {
+	if safeMethods[req.Method] {
+		return nil
+	}
+	return Check(req.Method + " " + req.URL.Redacted())
+}
+
+// NewClient returns an http client whose transport refuses unsafe
+// requests in read-only mode
+func NewClient() *http.Client {
+	return &http.Client{Transport: Transport(nil)}
+}
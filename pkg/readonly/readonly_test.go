@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readonly_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/archivista"
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/gitlab"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+func TestCheck(t *testing.T) {
+	defer readonly.Disable()
+	require.NoError(t, readonly.Check("writing"))
+	readonly.Enable()
+	require.True(t, readonly.Enabled())
+	err := readonly.Check("writing")
+	require.Error(t, err)
+	require.True(t, errors.Is(err, readonly.ErrReadOnly))
+}
+
+// TestNoMutatingCalls asserts that in read-only mode writes fail before
+// any mutating request reaches the API servers while reads still work
+func TestNoMutatingCalls(t *testing.T) {
+	var mu sync.Mutex
+	methods := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`)) //nolint: errcheck
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+	t.Setenv("GITLAB_TOKEN", "test")
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	readonly.Enable()
+	defer readonly.Disable()
+
+	ctx := context.Background()
+	for _, releaseURL := range []string{
+		"github://github.com/org/repo/v1.0.0",
+		"gitlab://" + host + "/group/project/-/releases/v1.0.0",
+	} {
+		err := watcher.UploadReleaseAssets(ctx, releaseURL, watcher.ReleaseAssets("provenance", []byte("{}")))
+		require.ErrorIs(t, err, readonly.ErrReadOnly, releaseURL)
+	}
+	_, err := gitlab.APIPostRequest(ctx, host, "projects/1/uploads", "text/plain", strings.NewReader("x"))
+	require.ErrorIs(t, err, readonly.ErrReadOnly)
+
+	// Attestation stores
+	archivistaClient, err := archivista.New(srv.URL)
+	require.NoError(t, err)
+	_, err = archivistaClient.Upload(ctx, []byte("{}"))
+	require.ErrorIs(t, err, readonly.ErrReadOnly)
+
+	// Uploads to the stores, the chunked snapshot state goes through
+	// the same path
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("STORAGE_EMULATOR_HOST", host)
+	for _, destURL := range []string{
+		"gs://bucket/attestation.json",
+		"s3://bucket/attestation.json",
+		"oci://" + host + "/repo:attestation",
+	} {
+		err := driver.UploadURL(ctx, destURL, strings.NewReader("{}"))
+		require.ErrorIs(t, err, readonly.ErrReadOnly, destURL)
+	}
+	for _, statePath := range []string{"gs://bucket/state.json", "s3://bucket/state.json"} {
+		err := watcher.WriteSnapshotState(ctx, statePath, []byte("{}"))
+		require.ErrorIs(t, err, readonly.ErrReadOnly, statePath)
+	}
+
+	// Messages are published but oversized ones need a claim check
+	// uploaded to a bucket
+	message := watcher.FinishMessage{Digest: strings.Repeat("x", 10*1024*1024)}
+	for _, topic := range []string{
+		srv.URL + "/hook",
+		"sns://arn:aws:sns:us-east-1:123456789012:topic",
+		"sqs://sqs.us-east-1.amazonaws.com/123456789012/queue",
+	} {
+		for _, location := range []string{"gs://bucket/claims", "s3://bucket/claims"} {
+			w := &watcher.Watcher{Options: watcher.Options{ClaimCheckLocation: location}}
+			err := w.PublishToTopic(ctx, topic, message)
+			require.ErrorIs(t, err, readonly.ErrReadOnly, topic)
+		}
+	}
+
+	// Any other mutating request fails in the http clients
+	res, err := readonly.NewClient().Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err == nil {
+		res.Body.Close()
+	}
+	require.ErrorIs(t, err, readonly.ErrReadOnly)
+
+	// Reads still work
+	res, err = github.APIGetRequest(ctx, srv.URL+"/repos/org/repo")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.NoError(t, driver.DownloadURL(ctx, "gs://bucket/attestation.json", io.Discard))
+	require.NoError(t, driver.DownloadURL(ctx, "s3://bucket/attestation.json", io.Discard))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{http.MethodGet, http.MethodGet, http.MethodGet}, methods)
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := readonly.NewClient()
+
+	// Writes go through when read-only mode is off
+	res, err := client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	require.NoError(t, err)
+	res.Body.Close()
+
+	readonly.Enable()
+	defer readonly.Disable()
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		req, err := http.NewRequest(method, srv.URL, strings.NewReader("x"))
+		require.NoError(t, err)
+		res, err := client.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		require.ErrorIs(t, err, readonly.ErrReadOnly, method)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		req, err := http.NewRequest(method, srv.URL, http.NoBody)
+		require.NoError(t, err)
+		res, err := client.Do(req)
+		require.NoError(t, err, method)
+		res.Body.Close()
+	}
+}
@@ -20,10 +20,19 @@ import (
 	"time"
 )
 
+// Outcomes of finished runs
+const (
+	OutcomeSuccess   = "success"
+	OutcomeFailure   = "failure"
+	OutcomeCancelled = "cancelled"
+	OutcomeTimeout   = "timeout"
+)
+
 type Run struct {
 	SpecURL    string
 	IsSuccess  bool
 	IsRunning  bool
+	Outcome    string // Set by drivers able to tell cancelled and timed out runs from failures
 	Params     []string
 	Steps      []Step
 	Artifacts  []Artifact
@@ -32,6 +41,21 @@ type Run struct {
 	SystemData interface{}
 }
 
+// Result returns the outcome of the run, empty while it is running.
+// When the driver does not set Outcome it is derived from IsSuccess.
+func (r *Run) Result() string {
+	switch {
+	case r.IsRunning:
+		return ""
+	case r.IsSuccess:
+		return OutcomeSuccess
+	case r.Outcome != "":
+		return r.Outcome
+	default:
+		return OutcomeFailure
+	}
+}
+
 // Step is the interface that defines the behaviour of a build step
 // the exec runner can execute
 type Step struct {
@@ -49,4 +73,7 @@ type Artifact struct {
 	Path     string
 	Checksum map[string]string
 	Time     time.Time
+	// Annotations holds metadata the storage driver knows about the
+	// artifact, they are recorded in the attestation subject.
+	Annotations map[string]string `json:",omitempty"`
 }
@@ -0,0 +1,39 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResult(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		run    Run
+		expect string
+	}{
+		{"running", Run{IsRunning: true}, ""},
+		{"success", Run{IsSuccess: true}, OutcomeSuccess},
+		{"failure", Run{}, OutcomeFailure},
+		{"cancelled", Run{Outcome: OutcomeCancelled}, OutcomeCancelled},
+		{"timeout", Run{Outcome: OutcomeTimeout}, OutcomeTimeout},
+	} {
+		require.Equal(t, tc.expect, tc.run.Result(), tc.name)
+	}
+}
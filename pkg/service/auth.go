@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUnauthenticated is returned for requests without a valid token
+var ErrUnauthenticated = errors.New("missing or invalid bearer token")
+
+// TokenAuthenticator accepts the requests carrying one of a list of
+// bearer tokens in their authorization header
+type TokenAuthenticator struct {
+	tokens [][]byte
+}
+
+// NewTokenAuthenticator returns an authenticator accepting the tokens
+func NewTokenAuthenticator(tokens ...string) (*TokenAuthenticator, error) {
+	a := &TokenAuthenticator{}
+	for _, t := range tokens {
+		if t == "" {
+			return nil, errors.New("empty bearer token")
+		}
+		a.tokens = append(a.tokens, []byte(t))
+	}
+	if len(a.tokens) == 0 {
+		return nil, errors.New("no bearer tokens defined")
+	}
+	return a, nil
+}
+
+// LoadTokenAuthenticator reads the accepted tokens from a file, one per
+// line. Blank lines and lines starting with # are skipped.
+func LoadTokenAuthenticator(path string) (*TokenAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening token file: %w", err)
+	}
+	defer f.Close()
+	tokens := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading token file: %w", err)
+	}
+	return NewTokenAuthenticator(tokens...)
+}
+
+// Authenticate checks the value of an authorization header
+func (a *TokenAuthenticator) Authenticate(authorization string) error {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ErrUnauthenticated
+	}
+	found := 0
+	for _, t := range a.tokens {
+		// Compare with every token to not leak which one matched
+		found |= subtle.ConstantTimeCompare(t, []byte(token))
+	}
+	if found == 0 {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// UnaryInterceptor returns a gRPC interceptor rejecting the calls
+// without a valid token with codes.Unauthenticated
+func (a *TokenAuthenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		header := ""
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+		if err := a.Authenticate(header); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Handler wraps an HTTP handler, rejecting the requests without a
+// valid token with 401 Unauthorized
+func (a *TokenAuthenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.Authenticate(r.Header.Get("Authorization")); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken sends a token with each call
+type bearerToken string
+
+// BearerToken returns the call credentials of a gRPC client sending the
+// token to a server using a TokenAuthenticator. It requires a
+// connection secured with TLS.
+func BearerToken(token string) credentials.PerRPCCredentials {
+	return bearerToken(token)
+}
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (bearerToken) RequireTransportSecurity() bool {
+	return true
+}
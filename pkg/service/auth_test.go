@@ -0,0 +1,44 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("# CI pipelines\nfirst\n\n  second  \n"), os.FileMode(0o600)))
+	auth, err := LoadTokenAuthenticator(path)
+	require.NoError(t, err)
+
+	require.NoError(t, auth.Authenticate("Bearer first"))
+	require.NoError(t, auth.Authenticate("bearer second"))
+	for _, header := range []string{"", "first", "Bearer", "Bearer ", "Bearer third", "Basic first", "Bearer # CI pipelines"} {
+		require.ErrorIs(t, auth.Authenticate(header), ErrUnauthenticated, header)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("# no tokens\n"), os.FileMode(0o600)))
+	_, err = LoadTokenAuthenticator(path)
+	require.Error(t, err)
+	_, err = NewTokenAuthenticator("")
+	require.Error(t, err)
+}
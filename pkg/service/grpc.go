@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	servicev1 "sigs.k8s.io/tejolote/pkg/service/v1"
+)
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnixTime(i int64) time.Time {
+	if i == 0 {
+		return time.Time{}
+	}
+	return time.Unix(i, 0).UTC()
+}
+
+// toProto converts an observation to its protobuf message
+func toProto(obs *Observation) *servicev1.Observation {
+	return &servicev1.Observation{
+		Id:             obs.ID,
+		Run:            obs.Run,
+		Artifacts:      obs.Artifacts,
+		Sign:           obs.Sign,
+		Phase:          obs.Phase,
+		Message:        obs.Message,
+		StartTime:      unixTime(obs.StartTime),
+		CompletionTime: unixTime(obs.CompletionTime),
+	}
+}
+
+// fromProto converts a protobuf message to an observation
+func fromProto(m *servicev1.Observation) *Observation {
+	return &Observation{
+		ID:             m.GetId(),
+		Run:            m.GetRun(),
+		Artifacts:      m.GetArtifacts(),
+		Sign:           m.GetSign(),
+		Phase:          m.GetPhase(),
+		Message:        m.GetMessage(),
+		StartTime:      fromUnixTime(m.GetStartTime()),
+		CompletionTime: fromUnixTime(m.GetCompletionTime()),
+	}
+}
+
+// grpcServer serves a Service over gRPC
+type grpcServer struct {
+	servicev1.UnimplementedObservationServiceServer
+	service *Service
+}
+
+// NewGRPCServer returns a gRPC server exposing the service
+func NewGRPCServer(s *Service, opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(opts...)
+	servicev1.RegisterObservationServiceServer(srv, &grpcServer{service: s})
+	return srv
+}
+
+func (g *grpcServer) StartObservation(
+	ctx context.Context, req *servicev1.StartObservationRequest,
+) (*servicev1.Observation, error) {
+	if req.GetRun() == "" {
+		return nil, status.Error(codes.InvalidArgument, "run spec URL is required")
+	}
+	obs, err := g.service.Start(ctx, req.GetRun(), req.GetArtifacts(), req.GetSign())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(obs), nil
+}
+
+func (g *grpcServer) FinishObservation(
+	_ context.Context, req *servicev1.FinishObservationRequest,
+) (*servicev1.Observation, error) {
+	obs, err := g.service.Finish(req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(obs), nil
+}
+
+func (g *grpcServer) GetAttestation(
+	_ context.Context, req *servicev1.GetAttestationRequest,
+) (*servicev1.GetAttestationResponse, error) {
+	obs, err := g.service.Get(req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &servicev1.GetAttestationResponse{
+		Observation: toProto(obs),
+		Attestation: obs.Attestation,
+	}, nil
+}
+
+// statusError maps the service errors to gRPC status codes
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrPhase):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Client drives a tejolote gRPC server
+type Client struct {
+	conn   *grpc.ClientConn
+	client servicev1.ObservationServiceClient
+}
+
+// NewClient returns a client of the server at target. The options must
+// set the transport credentials.
+func NewClient(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating grpc client: %w", err)
+	}
+	return &Client{conn: conn, client: servicev1.NewObservationServiceClient(conn)}, nil
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// StartObservation snapshots the artifact stores of a run
+func (c *Client) StartObservation(ctx context.Context, run string, artifacts []string, sign bool) (*Observation, error) {
+	resp, err := c.client.StartObservation(ctx, &servicev1.StartObservationRequest{
+		Run: run, Artifacts: artifacts, Sign: sign,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromProto(resp), nil
+}
+
+// FinishObservation starts the attestation of the run of an observation
+func (c *Client) FinishObservation(ctx context.Context, id string) (*Observation, error) {
+	resp, err := c.client.FinishObservation(ctx, &servicev1.FinishObservationRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	return fromProto(resp), nil
+}
+
+// GetAttestation returns an observation with its attestation, if ready
+func (c *Client) GetAttestation(ctx context.Context, id string) (*Observation, error) {
+	resp, err := c.client.GetAttestation(ctx, &servicev1.GetAttestationRequest{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	obs := fromProto(resp.GetObservation())
+	obs.Attestation = resp.GetAttestation()
+	return obs, nil
+}
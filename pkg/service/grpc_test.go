@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestProtoConversion(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	obs := &Observation{
+		ID: "abc", Run: "github://org/repo/1", Artifacts: []string{"gs://a", "oci://b"},
+		Sign: true, Phase: PhaseAttested, Message: "done", StartTime: start,
+	}
+	m := toProto(obs)
+	require.Equal(t, start.Unix(), m.GetStartTime())
+	require.Zero(t, m.GetCompletionTime())
+	require.Equal(t, obs, fromProto(m))
+
+	// Unset messages convert to an empty observation
+	require.Equal(t, &Observation{}, fromProto(nil))
+}
+
+func TestGRPC(t *testing.T) {
+	s := newTestService(t)
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(s)
+	go srv.Serve(lis) //nolint: errcheck
+	t.Cleanup(srv.Stop)
+
+	client, err := NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+
+	obs, err := client.StartObservation(ctx, "github://org/repo/1", []string{"gs://bucket/dist"}, false)
+	require.NoError(t, err)
+	require.Equal(t, PhaseObserving, obs.Phase)
+	require.Equal(t, []string{"gs://bucket/dist"}, obs.Artifacts)
+
+	obs, err = client.FinishObservation(ctx, obs.ID)
+	require.NoError(t, err)
+	require.Equal(t, PhaseAttesting, obs.Phase)
+
+	_, err = client.FinishObservation(ctx, obs.ID)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	require.Eventually(t, func() bool {
+		obs, err = client.GetAttestation(ctx, obs.ID)
+		require.NoError(t, err)
+		return obs.Phase == PhaseAttested
+	}, 5*time.Second, 10*time.Millisecond)
+	require.JSONEq(t, `{"run":"github://org/repo/1"}`, string(obs.Attestation))
+
+	_, err = client.GetAttestation(ctx, "unknown")
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.StartObservation(ctx, "", nil, false)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.StartObservation(ctx, "github://org/repo/1", []string{"file:///etc"}, false)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGRPCAuth(t *testing.T) {
+	auth, err := NewTokenAuthenticator("secret")
+	require.NoError(t, err)
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(newTestService(t), grpc.ChainUnaryInterceptor(auth.UnaryInterceptor()))
+	go srv.Serve(lis) //nolint: errcheck
+	t.Cleanup(srv.Stop)
+
+	client, err := NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := context.Background()
+	_, err = client.StartObservation(ctx, "github://org/repo/1", nil, false)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	_, err = client.StartObservation(ctx, "github://org/repo/1", nil, false)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	_, err = client.StartObservation(ctx, "github://org/repo/1", nil, false)
+	require.NoError(t, err)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxRequestSize is the maximum size of the request bodies
+const maxRequestSize = 1 << 20
+
+// AttestationRequest is the body of POST /v1/attestations
+type AttestationRequest struct {
+	Run       string   `json:"run"`                 // Spec URL of the run (eg github://org/repo/1234)
+	Artifacts []string `json:"artifacts,omitempty"` // Spec URLs of the artifact stores
+	Sign      bool     `json:"sign,omitempty"`      // Sign the attestation
+}
+
+// NewHTTPHandler returns the HTTP API of the service:
+//
+//	POST /v1/attestations       observes a run, responds 202 with the observation
+//	                            while the stores are snapshotted in the background
+//	GET  /v1/attestations/{id}  returns the observation and, once Attested, the attestation
+func NewHTTPHandler(s *Service) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/attestations", func(w http.ResponseWriter, r *http.Request) {
+		req := AttestationRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "parsing request: "+err.Error())
+			return
+		}
+		if req.Run == "" {
+			writeError(w, http.StatusBadRequest, "run spec URL is required")
+			return
+		}
+		obs, err := s.Observe(req.Run, req.Artifacts, req.Sign)
+		if errors.Is(err, ErrNotAllowed) {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Location", "/v1/attestations/"+obs.ID)
+		writeJSON(w, http.StatusAccepted, obs)
+	})
+	mux.HandleFunc("GET /v1/attestations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		obs, err := s.Get(r.PathValue("id"))
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, obs)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("Writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP(t *testing.T) {
+	s := newTestService(t)
+	srv := httptest.NewServer(NewHTTPHandler(s))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/attestations", "application/json",
+		strings.NewReader(`{"run":"github://org/repo/1","artifacts":["gs://bucket/dist"]}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	obs := &Observation{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(obs))
+	require.Equal(t, "/v1/attestations/"+obs.ID, resp.Header.Get("Location"))
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(srv.URL + "/v1/attestations/" + obs.ID)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(obs))
+		return obs.Phase == PhaseAttested
+	}, 5*time.Second, 10*time.Millisecond)
+	require.JSONEq(t, `{"run":"github://org/repo/1"}`, string(obs.Attestation))
+
+	for body, code := range map[string]int{
+		`{}`: http.StatusBadRequest,
+		`{`:  http.StatusBadRequest,
+		`{"run":"github://org/repo/1","artifacts":["file:///etc"]}`: http.StatusForbidden,
+	} {
+		resp, err := http.Post(srv.URL+"/v1/attestations", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/attestations/unknown")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHTTPAuth(t *testing.T) {
+	auth, err := NewTokenAuthenticator("secret")
+	require.NoError(t, err)
+	srv := httptest.NewServer(auth.Handler(NewHTTPHandler(newTestService(t))))
+	defer srv.Close()
+
+	for header, code := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusNotFound,
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/attestations/unknown", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode, header)
+		if code == http.StatusUnauthorized {
+			require.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package service runs tejolote as a network service. Build
+// orchestrators start an observation before the build, which snapshots
+// the artifact stores, finish it when the build is done and fetch the
+// attestation once it is generated. The service is exposed over gRPC,
+// see v1/service.proto for the API, and over HTTP.
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Observation phases
+const (
+	PhaseSnapshotting = "Snapshotting" // The stores are being snapshotted in the background
+	PhaseObserving    = "Observing"    // The stores were snapshotted, waiting for the build
+	PhaseAttesting    = "Attesting"    // The build finished, the run is being attested
+	PhaseAttested     = "Attested"     // The attestation is ready
+	PhaseFailed       = "Failed"       // The attestation could not be generated
+)
+
+var (
+	// ErrNotFound is returned for unknown observation IDs
+	ErrNotFound = errors.New("observation not found")
+
+	// ErrPhase is returned when an observation is finished twice
+	ErrPhase = errors.New("observation is not waiting for the build")
+
+	// ErrNotAllowed is returned for observations the service options
+	// do not accept: a run or store scheme not in the allowlists or a
+	// signed attestation when signing is off
+	ErrNotAllowed = errors.New("observation not allowed")
+)
+
+// Observation is the attestation of a build run requested to the service
+type Observation struct {
+	ID        string   `json:"id"`
+	Run       string   `json:"run"`                 // Spec URL of the run (eg github://org/repo/1234)
+	Artifacts []string `json:"artifacts,omitempty"` // Spec URLs of the artifact stores
+	Sign      bool     `json:"sign,omitempty"`      // Sign the attestation
+
+	Phase          string    `json:"phase"`
+	Message        string    `json:"message,omitempty"`
+	StartTime      time.Time `json:"startTime"`
+	CompletionTime time.Time `json:"completionTime,omitempty"`
+
+	// Attestation is the attestation once the phase is Attested
+	Attestation json.RawMessage `json:"attestation,omitempty"`
+}
+
+// SnapshotFunc snapshots the artifact stores of a new observation,
+// saving the snapshots to statePath
+type SnapshotFunc func(ctx context.Context, obs *Observation, statePath string) error
+
+// AttestFunc waits for the run of an observation and returns its
+// attestation, reading the snapshots from statePath
+type AttestFunc func(ctx context.Context, obs *Observation, statePath string) ([]byte, error)
+
+// Options configure the service
+type Options struct {
+	StateDir    string // Directory to keep the snapshot states of the observations
+	ResultsDir  string // Directory to store the finished observations, kept in memory if empty
+	Concurrency int    // Number of runs attested at the same time
+
+	// SnapshotTimeout bounds the snapshots taken in the background by
+	// Observe, no limit when zero
+	SnapshotTimeout time.Duration
+
+	// RunSchemes are the spec URL schemes of the runs accepted. Any
+	// run is accepted when empty.
+	RunSchemes []string
+
+	// StoreSchemes are the spec URL schemes of the artifact stores
+	// accepted. Every part of a composed scheme (intoto+gs) must be
+	// listed. When empty, all stores but file:// are accepted: they
+	// read the filesystem of the server.
+	StoreSchemes []string
+
+	// AllowSign accepts observations asking to sign the attestation
+	// with the identity of the server
+	AllowSign bool
+}
+
+// Service keeps the observations in memory and attests their runs in
+// the background
+type Service struct {
+	Options  Options
+	Snapshot SnapshotFunc
+	Attest   AttestFunc
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	slots        chan struct{}
+	mu           sync.Mutex
+	observations map[string]*Observation
+}
+
+// New returns a new service attesting the runs with the functions
+func New(opts Options, snapshot SnapshotFunc, attest AttestFunc) (*Service, error) {
+	if opts.StateDir == "" {
+		opts.StateDir = os.TempDir()
+	}
+	if err := os.MkdirAll(opts.StateDir, os.FileMode(0o700)); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	observations := map[string]*Observation{}
+	if opts.ResultsDir != "" {
+		var err error
+		if observations, err = loadResults(opts.ResultsDir); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		Options:      opts,
+		Snapshot:     snapshot,
+		Attest:       attest,
+		ctx:          ctx,
+		cancel:       cancel,
+		slots:        make(chan struct{}, opts.Concurrency),
+		observations: observations,
+	}, nil
+}
+
+// loadResults reads the observations stored in the results directory
+func loadResults(dir string) (map[string]*Observation, error) {
+	if err := os.MkdirAll(dir, os.FileMode(0o700)); err != nil {
+		return nil, fmt.Errorf("creating results directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing results: %w", err)
+	}
+	observations := map[string]*Observation{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading result: %w", err)
+		}
+		obs := &Observation{}
+		if err := json.Unmarshal(data, obs); err != nil {
+			return nil, fmt.Errorf("parsing result %s: %w", path, err)
+		}
+		observations[obs.ID] = obs
+	}
+	logrus.Debugf("Loaded %d observations from %s", len(observations), dir)
+	return observations, nil
+}
+
+// Start registers a new observation, returning once the artifact stores
+// are snapshotted. The build must not write to the stores before.
+func (s *Service) Start(ctx context.Context, run string, artifacts []string, sign bool) (*Observation, error) {
+	obs, err := s.newObservation(run, artifacts, sign, PhaseObserving)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Snapshot(ctx, obs, s.statePath(obs)); err != nil {
+		return nil, fmt.Errorf("snapshotting artifact stores: %w", err)
+	}
+	s.mu.Lock()
+	s.observations[obs.ID] = obs
+	s.mu.Unlock()
+	logrus.Infof("Observation %s of %s started", obs.ID, run)
+	return s.copy(obs), nil
+}
+
+// newObservation checks the request and returns a new observation
+func (s *Service) newObservation(run string, artifacts []string, sign bool, phase string) (*Observation, error) {
+	if run == "" {
+		return nil, errors.New("observation has no run spec URL")
+	}
+	if err := s.admit(run, artifacts, sign); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating observation ID: %w", err)
+	}
+	return &Observation{
+		ID:        hex.EncodeToString(id),
+		Run:       run,
+		Artifacts: artifacts,
+		Sign:      sign,
+		Phase:     phase,
+		StartTime: time.Now().UTC(),
+	}, nil
+}
+
+// admit checks the run, stores and signing of a new observation
+// against the service options
+func (s *Service) admit(run string, artifacts []string, sign bool) error {
+	if sign && !s.Options.AllowSign {
+		return fmt.Errorf("%w: signing is disabled", ErrNotAllowed)
+	}
+	u, err := url.Parse(run)
+	if err != nil {
+		return fmt.Errorf("parsing run spec URL: %w", err)
+	}
+	if len(s.Options.RunSchemes) > 0 && !slices.Contains(s.Options.RunSchemes, u.Scheme) {
+		return fmt.Errorf("%w: run scheme %q", ErrNotAllowed, u.Scheme)
+	}
+	for _, a := range artifacts {
+		u, err := url.Parse(a)
+		if err != nil {
+			return fmt.Errorf("parsing artifact store spec URL: %w", err)
+		}
+		for _, scheme := range strings.Split(u.Scheme, "+") {
+			if !s.storeSchemeAllowed(scheme) {
+				return fmt.Errorf("%w: artifact store scheme %q", ErrNotAllowed, scheme)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Service) storeSchemeAllowed(scheme string) bool {
+	if len(s.Options.StoreSchemes) == 0 {
+		return scheme != "file"
+	}
+	return slices.Contains(s.Options.StoreSchemes, scheme)
+}
+
+// Observe registers an observation and returns it right away in the
+// Snapshotting phase. The artifact stores are snapshotted in the
+// background and the run is attested as soon as it is done, without
+// waiting for the caller to finish it.
+func (s *Service) Observe(run string, artifacts []string, sign bool) (*Observation, error) {
+	obs, err := s.newObservation(run, artifacts, sign, PhaseSnapshotting)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.observations[obs.ID] = obs
+	s.wg.Add(1)
+	go s.snapshot(obs)
+	s.mu.Unlock()
+	logrus.Infof("Observation %s of %s registered", obs.ID, run)
+	return s.copy(obs), nil
+}
+
+// snapshot takes the snapshot of an observation registered by Observe
+// and attests it
+func (s *Service) snapshot(obs *Observation) {
+	defer s.wg.Done()
+	if err := s.snapshotStores(obs); err != nil {
+		os.Remove(s.statePath(obs)) //nolint: errcheck
+		s.fail(obs, fmt.Errorf("snapshotting artifact stores: %w", err))
+		return
+	}
+	logrus.Infof("Observation %s of %s started", obs.ID, obs.Run)
+	s.mu.Lock()
+	obs.Phase = PhaseAttesting
+	s.wg.Add(1)
+	go s.attest(obs)
+	s.mu.Unlock()
+}
+
+// Finish attests the run of an observation in the background. The
+// attestation is read with Get once the observation is Attested.
+func (s *Service) Finish(id string) (*Observation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obs, ok := s.observations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if obs.Phase != PhaseObserving {
+		return nil, fmt.Errorf("%w (phase %s)", ErrPhase, obs.Phase)
+	}
+	obs.Phase = PhaseAttesting
+	s.wg.Add(1)
+	go s.attest(obs)
+	return s.copyLocked(obs), nil
+}
+
+// Get returns an observation
+func (s *Service) Get(id string) (*Observation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obs, ok := s.observations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.copyLocked(obs), nil
+}
+
+// Close cancels the attestations in progress and waits for them
+func (s *Service) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// attest runs the attestation of an observation
+func (s *Service) attest(obs *Observation) {
+	defer s.wg.Done()
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		return
+	}
+
+	statePath := s.statePath(obs)
+	data, err := s.Attest(s.ctx, s.copy(obs), statePath)
+	os.Remove(statePath) //nolint: errcheck
+
+	if err != nil {
+		s.fail(obs, err)
+		return
+	}
+	logrus.Infof("Observation %s attested", obs.ID)
+	s.complete(obs, PhaseAttested, data, "")
+}
+
+// snapshotStores runs the snapshot function of an observation taking
+// one of the attestation slots
+func (s *Service) snapshotStores(obs *Observation) error {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+	ctx := s.ctx
+	if s.Options.SnapshotTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Options.SnapshotTimeout)
+		defer cancel()
+	}
+	return s.Snapshot(ctx, s.copy(obs), s.statePath(obs))
+}
+
+// fail marks an observation as Failed
+func (s *Service) fail(obs *Observation, err error) {
+	logrus.Errorf("Observation %s failed: %v", obs.ID, err)
+	s.complete(obs, PhaseFailed, nil, err.Error())
+}
+
+// complete sets the final phase of an observation and stores it in the
+// results directory
+func (s *Service) complete(obs *Observation, phase string, attestation []byte, message string) {
+	s.mu.Lock()
+	obs.Phase = phase
+	obs.Attestation = attestation
+	obs.Message = message
+	obs.CompletionTime = time.Now().UTC()
+	result := s.copyLocked(obs)
+	s.mu.Unlock()
+
+	if s.Options.ResultsDir != "" {
+		if err := s.storeResult(result); err != nil {
+			logrus.Errorf("Storing observation %s: %v", obs.ID, err)
+		}
+	}
+}
+
+// storeResult writes a finished observation to the results directory
+func (s *Service) storeResult(obs *Observation) error {
+	data, err := json.MarshalIndent(obs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling observation: %w", err)
+	}
+	path := filepath.Join(s.Options.ResultsDir, obs.ID+".json")
+	if err := os.WriteFile(path, data, os.FileMode(0o600)); err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) statePath(obs *Observation) string {
+	return filepath.Join(s.Options.StateDir, obs.ID+".storage-snap.json")
+}
+
+// copy returns a copy of the observation safe to hand to callers
+func (s *Service) copy(obs *Observation) *Observation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.copyLocked(obs)
+}
+
+func (s *Service) copyLocked(obs *Observation) *Observation {
+	c := *obs
+	c.Artifacts = append([]string(nil), obs.Artifacts...)
+	return &c
+}
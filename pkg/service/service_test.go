@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService returns a service recording the state files and
+// returning the run in its attestation. Runs named "fail" fail.
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	return newTestServiceOptions(t, Options{StateDir: t.TempDir(), Concurrency: 2, AllowSign: true})
+}
+
+func newTestServiceOptions(t *testing.T, opts Options) *Service {
+	t.Helper()
+	s, err := New(opts,
+		func(_ context.Context, _ *Observation, statePath string) error {
+			return os.WriteFile(statePath, []byte("{}"), os.FileMode(0o600))
+		},
+		func(_ context.Context, obs *Observation, statePath string) ([]byte, error) {
+			if _, err := os.Stat(statePath); err != nil {
+				return nil, err
+			}
+			if obs.Run == "fail" {
+				return nil, errors.New("build failed")
+			}
+			return json.Marshal(map[string]string{"run": obs.Run})
+		},
+	)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitPhase waits for the observation to reach its final phase
+func waitPhase(t *testing.T, s *Service, id string) *Observation {
+	t.Helper()
+	var obs *Observation
+	require.Eventually(t, func() bool {
+		var err error
+		obs, err = s.Get(id)
+		require.NoError(t, err)
+		return obs.Phase == PhaseAttested || obs.Phase == PhaseFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return obs
+}
+
+func TestService(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	obs, err := s.Start(ctx, "github://org/repo/1", []string{"gs://bucket/dist"}, true)
+	require.NoError(t, err)
+	require.Equal(t, PhaseObserving, obs.Phase)
+	require.NotEmpty(t, obs.ID)
+	require.FileExists(t, s.statePath(obs))
+
+	obs, err = s.Finish(obs.ID)
+	require.NoError(t, err)
+	require.Equal(t, PhaseAttesting, obs.Phase)
+
+	_, err = s.Finish(obs.ID)
+	require.ErrorIs(t, err, ErrPhase)
+
+	obs = waitPhase(t, s, obs.ID)
+	require.Equal(t, PhaseAttested, obs.Phase)
+	require.JSONEq(t, `{"run":"github://org/repo/1"}`, string(obs.Attestation))
+	require.False(t, obs.CompletionTime.IsZero())
+	require.NoFileExists(t, s.statePath(obs))
+
+	failed, err := s.Start(ctx, "fail", nil, false)
+	require.NoError(t, err)
+	_, err = s.Finish(failed.ID)
+	require.NoError(t, err)
+	failed = waitPhase(t, s, failed.ID)
+	require.Equal(t, PhaseFailed, failed.Phase)
+	require.Equal(t, "build failed", failed.Message)
+
+	_, err = s.Get("unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Start(ctx, "", nil, false)
+	require.Error(t, err)
+}
+
+func TestServiceAdmit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServiceOptions(t, Options{StateDir: t.TempDir()})
+	for _, tc := range []struct {
+		run       string
+		artifacts []string
+		sign      bool
+	}{
+		{"github://org/repo/1", []string{"file:///etc"}, false},
+		{"github://org/repo/1", []string{"intoto+file:///etc/att.json"}, false},
+		{"github://org/repo/1", []string{"gs://bucket"}, true},
+	} {
+		_, err := s.Start(ctx, tc.run, tc.artifacts, tc.sign)
+		require.ErrorIs(t, err, ErrNotAllowed, tc.artifacts)
+	}
+	_, err := s.Start(ctx, "github://org/repo/1", []string{"gs://bucket", "intoto+oci://ghcr.io/org/att"}, false)
+	require.NoError(t, err)
+
+	// Configured allowlists
+	s = newTestServiceOptions(t, Options{
+		StateDir: t.TempDir(), RunSchemes: []string{"gcb"}, StoreSchemes: []string{"file"},
+	})
+	_, err = s.Start(ctx, "github://org/repo/1", nil, false)
+	require.ErrorIs(t, err, ErrNotAllowed)
+	_, err = s.Start(ctx, "gcb://project/1", []string{"gs://bucket"}, false)
+	require.ErrorIs(t, err, ErrNotAllowed)
+	_, err = s.Start(ctx, "gcb://project/1", []string{"file:///tmp"}, false)
+	require.NoError(t, err)
+}
+
+func TestServiceResults(t *testing.T) {
+	opts := Options{StateDir: t.TempDir(), ResultsDir: t.TempDir()}
+	s := newTestServiceOptions(t, opts)
+
+	obs, err := s.Observe("github://org/repo/1", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, PhaseSnapshotting, obs.Phase)
+	obs = waitPhase(t, s, obs.ID)
+	require.Equal(t, PhaseAttested, obs.Phase)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(opts.ResultsDir, obs.ID+".json"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A new service returns the stored observations
+	s2 := newTestServiceOptions(t, opts)
+	stored, err := s2.Get(obs.ID)
+	require.NoError(t, err)
+	require.Equal(t, PhaseAttested, stored.Phase)
+	require.JSONEq(t, string(obs.Attestation), string(stored.Attestation))
+}
+
+func TestServiceObserve(t *testing.T) {
+	release := make(chan struct{})
+	s, err := New(Options{StateDir: t.TempDir(), SnapshotTimeout: 100 * time.Millisecond},
+		func(ctx context.Context, obs *Observation, statePath string) error {
+			if obs.Run == "slow" {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			<-release
+			return os.WriteFile(statePath, []byte("{}"), os.FileMode(0o600))
+		},
+		func(context.Context, *Observation, string) ([]byte, error) {
+			return []byte("{}"), nil
+		},
+	)
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	// Observe returns before the snapshot is done
+	obs, err := s.Observe("github://org/repo/1", []string{"gs://bucket"}, false)
+	require.NoError(t, err)
+	require.Equal(t, PhaseSnapshotting, obs.Phase)
+	close(release)
+	require.Equal(t, PhaseAttested, waitPhase(t, s, obs.ID).Phase)
+
+	// Snapshots running over the timeout fail the observation
+	slow, err := s.Observe("slow", nil, false)
+	require.NoError(t, err)
+	slow = waitPhase(t, s, slow.ID)
+	require.Equal(t, PhaseFailed, slow.Phase)
+	require.Contains(t, slow.Message, context.DeadlineExceeded.Error())
+
+	_, err = s.Observe("github://org/repo/1", []string{"file:///etc"}, false)
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
@@ -17,30 +17,29 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 
-	"sigs.k8s.io/release-utils/hash"
-
 	"sigs.k8s.io/tejolote/pkg/github"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
 
-const actionsArtifactsURL = "https://api.github.com/repos/%s/%s/actions/runs/%d/artifacts"
+const actionsArtifactsURL = "%s/repos/%s/%s/actions/runs/%d/artifacts"
 
 // const actionsArtifactsURL =    "https://api.github.com/repos/%s/%s/actions/artifacts/%d"
 
 type Actions struct {
+	Host         string // GitHub Enterprise Server hostname, empty for github.com
 	Organization string
 	Repository   string
 	RunID        int
@@ -56,28 +55,36 @@ func NewActions(specURL string) (*Actions, error) {
 	if u.Scheme != "actions" {
 		return nil, errors.New("spec url is not an actions run")
 	}
-	repo, runids, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	a := &Actions{
+		Organization: u.Hostname(),
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+
+	// Hostnames with a dot are GitHub Enterprise Server instances:
+	// actions://ghe.example.com/org/repo/runid
+	if strings.Contains(u.Hostname(), ".") {
+		a.Host = u.Host
+		a.Organization, path, _ = strings.Cut(path, "/")
+	}
+	repo, runids, _ := strings.Cut(path, "/")
 	runid, err := strconv.Atoi(runids)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read runid from %s", u.Path)
 	}
 
-	a := &Actions{
-		Organization: u.Hostname(),
-		Repository:   repo,
-		RunID:        runid,
-	}
+	a.Repository = repo
+	a.RunID = runid
 	return a, nil
 }
 
 // readArtifacts gets the artiofacts from the run
-func (a *Actions) readArtifacts() ([]run.Artifact, error) {
+func (a *Actions) readArtifacts(ctx context.Context) ([]run.Artifact, error) {
 	runURL := fmt.Sprintf(
-		actionsArtifactsURL,
+		actionsArtifactsURL, github.ServerAPIURL(a.Host),
 		a.Organization, a.Repository, a.RunID,
 	)
 
-	res, err := github.APIGetRequest(runURL)
+	res, err := github.APIGetRequest(ctx, runURL)
 	if err != nil {
 		return nil, fmt.Errorf("querying GitHub api for artifacts: %w", err)
 	}
@@ -97,33 +104,21 @@ func (a *Actions) readArtifacts() ([]run.Artifact, error) {
 		return nil, fmt.Errorf("unmarshalling GitHub response: %w", err)
 	}
 
-	// Now we need to download the artifacts to hash them
-	tmpdir, err := os.MkdirTemp("", "artifacts-")
-	if err != nil {
-		return nil, fmt.Errorf("creating temp dir: %w", err)
-	}
-
+	// Now we need to download the artifacts to hash them. The
+	// downloads are streamed into the hasher, nothing is written to disk.
 	ret := []run.Artifact{}
 
 	for _, a := range artifacts.Artifacts {
-		f, err := os.Create(filepath.Join(tmpdir, a.Name))
-		if err != nil {
-			return nil, fmt.Errorf("creating artifact file: %w", err)
-		}
-		defer f.Close()
-		if err := github.Download(a.URL, f); err != nil {
+		h := sha256.New()
+		if err := github.Download(ctx, a.URL, h); err != nil {
 			return nil, fmt.Errorf(
 				"downloading artifact from %s: %w", a.URL, err,
 			)
 		}
-		shaVal, err := hash.SHA256ForFile(f.Name())
-		if err != nil {
-			return nil, fmt.Errorf("hashing file: %w", err)
-		}
 		ret = append(ret, run.Artifact{
 			Path: runURL + "/" + a.Name,
 			Checksum: map[string]string{
-				"SHA256": shaVal,
+				"SHA256": fmt.Sprintf("%x", h.Sum(nil)),
 			},
 			Time: a.UpdatedAt,
 		})
@@ -133,8 +128,8 @@ func (a *Actions) readArtifacts() ([]run.Artifact, error) {
 }
 
 // Snap returns a snapshot of the current state
-func (a *Actions) Snap() (*snapshot.Snapshot, error) {
-	artifacts, err := a.readArtifacts()
+func (a *Actions) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	artifacts, err := a.readArtifacts(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("collecting artifacts: %w", err)
 	}
@@ -144,3 +139,12 @@ func (a *Actions) Snap() (*snapshot.Snapshot, error) {
 	}
 	return &snap, nil
 }
+
+// Capabilities returns the features supported by the driver
+func (a *Actions) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: false,
+		Streaming:         false,
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,7 +28,44 @@ func TestActions(t *testing.T) {
 	a, err := NewActions("actions://puerco/tejolote-test/2969514606")
 	require.NoError(t, err)
 
-	snap, err := a.Snap()
+	snap, err := a.Snap(context.Background())
 	require.NoError(t, err)
 	require.Nil(t, snap)
 }
+
+func TestNewActions(t *testing.T) {
+	a, err := NewActions("actions://puerco/tejolote-test/2969514606")
+	require.NoError(t, err)
+	require.Equal(t, "", a.Host)
+	require.Equal(t, "puerco", a.Organization)
+	require.Equal(t, "tejolote-test", a.Repository)
+	require.Equal(t, 2969514606, a.RunID)
+
+	a, err = NewActions("actions://ghe.example.com/puerco/tejolote-test/2969514606")
+	require.NoError(t, err)
+	require.Equal(t, "ghe.example.com", a.Host)
+	require.Equal(t, "puerco", a.Organization)
+	require.Equal(t, "tejolote-test", a.Repository)
+	require.Equal(t, 2969514606, a.RunID)
+}
+
+func FuzzNewActions(f *testing.F) {
+	for _, seed := range []string{
+		"actions://puerco/tejolote-test/2969514606",
+		"actions://ghe.example.com/puerco/tejolote-test/2969514606",
+		"actions://puerco/",
+		"actions://ghe.example.com",
+		"actions://a/b/c/d",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		a, err := NewActions(spec)
+		if err != nil {
+			return
+		}
+		if a == nil {
+			t.Errorf("no driver and no error parsing %q", spec)
+		}
+	})
+}
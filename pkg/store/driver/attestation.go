@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,9 +28,12 @@ import (
 	"os"
 	"strings"
 
+	"cloud.google.com/go/storage"
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/sirupsen/logrus"
 
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/readonly"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
@@ -55,23 +59,28 @@ func NewAttestation(specURL string) (*Attestation, error) {
 	}, nil
 }
 
-// downloadURL universal download function
+// DownloadURL universal download function. It copies the data at a
+// location specified by a URL (gs://, s3://, oci://, http(s):// or file://)
+// to w.
 // TODO: Move these to methods in each driver
-func downloadURL(sourceURL string, w io.Writer) error {
+func DownloadURL(ctx context.Context, sourceURL string, w io.Writer) error {
 	u, err := url.Parse(sourceURL)
 	if err != nil {
 		return fmt.Errorf("parsing url %w", err)
 	}
-	ctx := context.Background()
 	switch u.Scheme {
 	case "gs":
-		client, err := newGCSClient(ctx)
+		client, err := newGCSClient(ctx, "gs://"+u.Hostname())
 		if err != nil {
 			return fmt.Errorf("creating GCS client: %w", err)
 		}
-		return downloadGCSObject(client, sourceURL, w)
+		return downloadGCSObject(ctx, client, sourceURL, w)
+	case "s3":
+		return downloadS3Object(ctx, sourceURL, w)
+	case "oci":
+		return downloadOCIFile(ctx, sourceURL, w)
 	case "http", "https":
-		return downloadHTTP(sourceURL, w)
+		return downloadHTTP(ctx, sourceURL, w)
 	case "file":
 		f, err := os.Open(strings.TrimPrefix(sourceURL, "file://"))
 		if err != nil {
@@ -87,10 +96,80 @@ func downloadURL(sourceURL string, w io.Writer) error {
 	}
 }
 
-func (att *Attestation) Snap() (*snapshot.Snapshot, error) {
+// UploadURL universal upload function. It writes the data read from r
+// to a location specified by a URL (gs://, s3://, oci:// or file://).
+// Uploads to remote locations are refused in read-only mode.
+func UploadURL(ctx context.Context, destURL string, r io.Reader) error {
+	u, err := url.Parse(destURL)
+	if err != nil {
+		return fmt.Errorf("parsing url %w", err)
+	}
+	if u.Scheme != "file" {
+		if err := readonly.Check("uploading " + destURL); err != nil {
+			return err
+		}
+	}
+	switch u.Scheme {
+	case "gs":
+		client, err := newGCSClient(ctx, "gs://"+u.Hostname())
+		if err != nil {
+			return fmt.Errorf("creating GCS client: %w", err)
+		}
+		return uploadGCSObject(ctx, client, destURL, r)
+	case "s3":
+		return uploadS3Object(ctx, destURL, r)
+	case "oci":
+		return uploadOCIFile(ctx, destURL, r)
+	case "file":
+		f, err := os.Create(strings.TrimPrefix(destURL, "file://"))
+		if err != nil {
+			return fmt.Errorf("opening file: %w", err)
+		}
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			return fmt.Errorf("writing file data: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("scheme not supported for uploads")
+	}
+}
+
+// ExistsURL checks if there is an object at a location specified by a
+// URL (gs://, s3:// or file://). Other schemes always report false.
+func ExistsURL(ctx context.Context, objectURL string) (bool, error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return false, fmt.Errorf("parsing url %w", err)
+	}
+	switch u.Scheme {
+	case "gs":
+		client, err := newGCSClient(ctx, "gs://"+u.Hostname())
+		if err != nil {
+			return false, fmt.Errorf("creating GCS client: %w", err)
+		}
+		_, err = client.Bucket(u.Hostname()).Object(strings.TrimPrefix(u.Path, "/")).Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	case "s3":
+		return existsS3Object(ctx, objectURL)
+	case "file":
+		_, err := os.Stat(strings.TrimPrefix(objectURL, "file://"))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, nil
+	}
+}
+
+func (att *Attestation) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
 	inTotoAtt := intoto.Statement{}
 	// Parse the attestation
-	rawData, err := att.downloadAttestation()
+	rawData, err := att.downloadAttestation(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("downloading attestation data: %w", err)
 	}
@@ -116,17 +195,17 @@ func (att *Attestation) Snap() (*snapshot.Snapshot, error) {
 	return &snap, nil
 }
 
-func (att *Attestation) downloadAttestation() ([]byte, error) {
+func (att *Attestation) downloadAttestation(ctx context.Context) ([]byte, error) {
 	var b bytes.Buffer
-	if err := downloadURL(att.URL, &b); err != nil {
+	if err := DownloadURL(ctx, att.URL, &b); err != nil {
 		return nil, fmt.Errorf("downloading attestation data: %w", err)
 	}
 	return b.Bytes(), nil
 }
 
-func downloadHTTP(urlPath string, f io.Writer) error {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", urlPath, nil)
+func downloadHTTP(ctx context.Context, urlPath string, f io.Writer) error {
+	client := readonly.NewClient()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlPath, nil)
 	if err != nil {
 		return fmt.Errorf("creating http request: %w", err)
 	}
@@ -144,10 +223,19 @@ func downloadHTTP(urlPath string, f io.Writer) error {
 	defer resp.Body.Close()
 
 	// Writer the body to file
-	numBytes, err := io.Copy(f, resp.Body)
+	numBytes, err := io.Copy(progress.Writer(f), resp.Body)
 	if err != nil {
 		return fmt.Errorf("writing http response to disk: %w", err)
 	}
 	logrus.Debugf("%d MB downloaded from %s", (numBytes / 1024 / 1024), urlPath)
 	return nil
 }
+
+// Capabilities returns the features supported by the driver
+func (att *Attestation) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: false,
+		Streaming:         false,
+	}
+}
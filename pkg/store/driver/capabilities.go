@@ -0,0 +1,32 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+// Capabilities describes the features supported by a storage driver
+type Capabilities struct {
+	// MetadataHashing is true when the driver reads the artifact digests
+	// from the storage metadata instead of downloading and hashing them.
+	MetadataHashing bool `json:"metadata_hashing"`
+
+	// DeletionDetection is true when a snapshot lists everything in the
+	// store, so artifacts missing from a later snapshot were deleted.
+	DeletionDetection bool `json:"deletion_detection"`
+
+	// Streaming is true when the driver hashes artifacts as they are
+	// read without writing them to disk first.
+	Streaming bool `json:"streaming"`
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 	"sigs.k8s.io/release-utils/hash"
 
+	"sigs.k8s.io/tejolote/pkg/progress"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
@@ -34,58 +42,201 @@ func NewDirectory(specURL string) (*Directory, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parsing SpecURL %s: %w", specURL, err)
 	}
+	sampling, err := parseSampling(u.Query())
+	if err != nil {
+		return nil, fmt.Errorf("parsing sampling policy: %w", err)
+	}
+	workers := 0
+	if w := u.Query().Get("workers"); w != "" {
+		workers, err = strconv.Atoi(w)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("invalid number of workers %q", w)
+		}
+	}
 	return &Directory{
-		Path: u.Path,
+		Path:     u.Path,
+		Sampling: sampling,
+		Workers:  workers,
 	}, nil
 }
 
+// Directory reads the files in a local directory. Directories with a
+// huge number of files can be sampled, see Sampling.
 type Directory struct {
-	Path string
+	Path     string
+	Sampling *Sampling
+
+	// Workers is the number of files hashed concurrently, it
+	// defaults to GOMAXPROCS
+	Workers int
 }
 
 // Snap takes a snapshot of the directory
-func (d *Directory) Snap() (*snapshot.Snapshot, error) {
-	if d.Path == "" {
-		return nil, fmt.Errorf("directory watcher has no path defined")
+func (d *Directory) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	snap := snapshot.Snapshot{}
+	if err := d.walk(ctx, func(a run.Artifact) error {
+		snap[a.Path] = a
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	return &snap, nil
+}
 
-	snap := snapshot.Snapshot{}
+// Index writes the directory listing to an on-disk snapshot index
+func (d *Directory) Index(ctx context.Context, idx *snapshot.Index) error {
+	return d.walk(ctx, idx.Add)
+}
 
-	// Walk the files in the directory
-	if err := filepath.Walk(d.Path,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return nil
-			}
+// walkedFile is a file found in the directory, waiting to be hashed
+type walkedFile struct {
+	path    string
+	relPath string
+	reason  string
+	modTime time.Time
+}
 
-			// Hash the file
-			sha, err := hash.SHA256ForFile(path)
-			if err != nil {
-				return fmt.Errorf("hashing %s: %w", path, err)
-			}
+// walk hashes the files in the directory, calling fn with each of them.
+// Files are hashed concurrently by Workers goroutines but fn is always
+// called from the calling goroutine.
+func (d *Directory) walk(ctx context.Context, fn func(run.Artifact) error) error {
+	if d.Path == "" {
+		return fmt.Errorf("directory watcher has no path defined")
+	}
 
-			// Normalize the path....
-			path, err = filepath.Abs(path)
-			if err != nil {
-				return fmt.Errorf("normalizing path %s: %w", path, err)
-			}
+	workers := d.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-			// .. and trim the working directory to make it relative
-			path = strings.TrimPrefix(path, d.Path+"/")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg, ctx := errgroup.WithContext(ctx)
 
-			// Register the file with the path normalized
-			snap[path] = run.Artifact{
-				Path:     path,
-				Checksum: map[string]string{"SHA256": sha},
-				Time:     info.ModTime(),
+	// Walk the files in the directory
+	files, hashed := 0, 0
+	pending := make(chan walkedFile)
+	wg.Go(func() error {
+		defer close(pending)
+		if err := filepath.Walk(d.Path,
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if info.IsDir() {
+					return nil
+				}
+				files++
+				progress.Listed(1)
+
+				// Normalize the path....
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					return fmt.Errorf("normalizing path %s: %w", path, err)
+				}
+
+				// .. and trim the working directory to make it relative
+				relPath := strings.TrimPrefix(absPath, d.Path+"/")
+
+				reason := ""
+				if d.Sampling != nil {
+					var ok bool
+					if reason, ok = d.Sampling.selected(relPath); !ok {
+						return nil
+					}
+				}
+
+				select {
+				case pending <- walkedFile{path: path, relPath: relPath, reason: reason, modTime: info.ModTime()}:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}); err != nil {
+			return fmt.Errorf("walking directory: %w", err)
+		}
+		return nil
+	})
+
+	// Hash the files
+	artifacts := make(chan run.Artifact)
+	var hashers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		hashers.Add(1)
+		wg.Go(func() error {
+			defer hashers.Done()
+			for f := range pending {
+				sha, err := hash.SHA256ForFile(f.path)
+				if err != nil {
+					return fmt.Errorf("hashing %s: %w", f.path, err)
+				}
+				progress.Hashed(1)
+
+				// Register the file with the path normalized
+				a := run.Artifact{
+					Path:     f.relPath,
+					Checksum: map[string]string{"SHA256": sha},
+					Time:     f.modTime,
+				}
+				if d.Sampling != nil {
+					d.Sampling.annotate(&a, f.reason)
+				}
+				select {
+				case artifacts <- a:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 			return nil
-		}); err != nil {
-		return nil, fmt.Errorf("walking directory: %w", err)
+		})
+	}
+	go func() {
+		hashers.Wait()
+		close(artifacts)
+	}()
+
+	var fnErr error
+	for a := range artifacts {
+		if fnErr != nil {
+			continue
+		}
+		hashed++
+		if fnErr = fn(a); fnErr != nil {
+			cancel()
+		}
+	}
+	err := wg.Wait()
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return err
 	}
 
-	return &snap, nil
+	if d.Sampling != nil {
+		logrus.WithField("driver", "directory").Infof(
+			"Hashed a sample of %d out of %d files in %s", hashed, files, d.Path,
+		)
+	}
+	return nil
+}
+
+// LocalPath returns the path of an artifact found in the directory
+func (d *Directory) LocalPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	return filepath.Join(d.Path, path), nil
+}
+
+// Capabilities returns the features supported by the driver
+func (d *Directory) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: d.Sampling == nil,
+		Streaming:         false,
+	}
 }
@@ -17,6 +17,8 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -117,15 +119,95 @@ func TestDirectorySnap(t *testing.T) {
 
 		require.NoError(t, tc.prepare(dir))
 
-		snap1, err := sut.Snap()
+		snap1, err := sut.Snap(context.Background())
 		require.NoError(t, err, "creating first snapshot")
 
 		require.NoError(t, tc.mutate(dir))
 
-		snap2, err := sut.Snap()
+		snap2, err := sut.Snap(context.Background())
 		require.NoError(t, err, "creating mutated fs snapshot")
 
 		delta := snap1.Delta(snap2)
 		require.Equal(t, delta, tc.expect)
 	}
 }
+
+// makeTree writes a synthetic tree of files files of size bytes,
+// spread in subdirectories of 100 files each
+func makeTree(b *testing.B, files, size int) string {
+	b.Helper()
+	dir := b.TempDir()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for i := 0; i < files; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%04d", i/100))
+		require.NoError(b, os.MkdirAll(sub, os.FileMode(0o755)))
+		require.NoError(b, os.WriteFile(
+			filepath.Join(sub, fmt.Sprintf("file%06d", i)), data, os.FileMode(0o644),
+		))
+	}
+	return dir
+}
+
+// BenchmarkDirectorySnap measures snapshotting trees dominated by the
+// directory walk (many small files) and by hashing (few large files),
+// hashing one file at a time and with the default worker pool
+func BenchmarkDirectorySnap(b *testing.B) {
+	for _, bc := range []struct {
+		files int
+		size  int
+	}{
+		{files: 100, size: 1024},
+		{files: 10000, size: 1024},
+		{files: 10, size: 16 << 20},
+		{files: 1000, size: 64 << 10},
+	} {
+		dir := makeTree(b, bc.files, bc.size)
+		for _, workers := range []int{1, 0} {
+			b.Run(fmt.Sprintf("files=%d/size=%d/workers=%d", bc.files, bc.size, workers), func(b *testing.B) {
+				sut := Directory{Path: dir, Workers: workers}
+				b.SetBytes(int64(bc.files * bc.size))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := sut.Snap(context.Background()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDirectoryWorkers(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, fmt.Sprintf("file%02d", i)), []byte(fmt.Sprintf("file %d", i)), os.FileMode(0o644),
+		))
+	}
+
+	// The snapshot does not depend on the number of workers
+	single, err := (&Directory{Path: dir, Workers: 1}).Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *single, 50)
+	parallel, err := (&Directory{Path: dir, Workers: 8}).Snap(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, single, parallel)
+
+	// Errors from the callback stop the walk
+	calls := 0
+	err = (&Directory{Path: dir, Workers: 8}).walk(context.Background(), func(run.Artifact) error {
+		calls++
+		return fmt.Errorf("index full")
+	})
+	require.EqualError(t, err, "index full")
+	require.Equal(t, 1, calls)
+
+	d, err := NewDirectory("file://" + dir + "?workers=4")
+	require.NoError(t, err)
+	require.Equal(t, 4, d.Workers)
+	_, err = NewDirectory("file://" + dir + "?workers=none")
+	require.Error(t, err)
+}
@@ -19,23 +19,22 @@ package driver
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/url"
-	"os"
 	"strings"
 	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
-	"google.golang.org/api/cloudbuild/v1"
-
-	"sigs.k8s.io/release-utils/hash"
 
+	"sigs.k8s.io/tejolote/pkg/gcp"
+	"sigs.k8s.io/tejolote/pkg/progress"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
@@ -53,7 +52,7 @@ func NewGCB(specURL string) (*GCB, error) {
 	}
 
 	ctx := context.Background()
-	client, err := newGCSClient(ctx)
+	client, err := newGCSClient(ctx, u.Hostname())
 	if err != nil {
 		return nil, fmt.Errorf("creating storage client: %w", err)
 	}
@@ -65,13 +64,12 @@ func NewGCB(specURL string) (*GCB, error) {
 	}, nil
 }
 
-func (gcb *GCB) readArtifacts() ([]run.Artifact, error) {
-	ctx := context.Background()
-	cloudbuildService, err := cloudbuild.NewService(ctx)
+func (gcb *GCB) readArtifacts(ctx context.Context) ([]run.Artifact, error) {
+	cloudbuildService, err := gcp.NewCloudBuildService(ctx, gcb.ProjectID)
 	if err != nil {
 		return nil, fmt.Errorf("creating cloudbuild client: %w", err)
 	}
-	build, err := cloudbuildService.Projects.Builds.Get(gcb.ProjectID, gcb.BuildID).Do()
+	build, err := cloudbuildService.Projects.Builds.Get(gcb.ProjectID, gcb.BuildID).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("getting build %s from GCB: %w", gcb.BuildID, err)
 	}
@@ -84,43 +82,35 @@ func (gcb *GCB) readArtifacts() ([]run.Artifact, error) {
 	logrus.Infof("pulling artifact manifest from %s", manifest)
 
 	// Get the artifacts list from th build service
-	gcbArtifacts, err := gcb.readArtifactManifest(manifest)
+	gcbArtifacts, err := gcb.readArtifactManifest(ctx, manifest)
 	if err != nil {
 		return nil, fmt.Errorf("reading build artifact manifest: %w", err)
 	}
 	logrus.Debugf("%+v", gcbArtifacts)
 
 	// Hash the artifacts list
-	var wg errgroup.Group
+	wg, ctx := errgroup.WithContext(ctx)
 	var mtx sync.Mutex
 	artifacts := []run.Artifact{}
 	for _, artifactData := range gcbArtifacts {
 		artifactData := artifactData
 		wg.Go(func() error {
-			f, err := os.CreateTemp("", "artifact-temp-")
-			if err != nil {
-				return fmt.Errorf("creating temporary artifact file")
-			}
-			defer os.Remove(f.Name())
-
-			if err := downloadGCSObject(gcb.client, artifactData.Location, f); err != nil {
+			// Hash the object while it is downloaded
+			h := sha256.New()
+			if err := downloadGCSObject(ctx, gcb.client, artifactData.Location, h); err != nil {
 				return fmt.Errorf("downloading artifact: %w", err)
 			}
 
-			attrs, err := readGCSObjectAttributes(gcb.client, artifactData.Location)
+			attrs, err := readGCSObjectAttributes(ctx, gcb.client, artifactData.Location)
 			if err != nil {
 				return fmt.Errorf("reading object artifacts: %w", err)
 			}
 
-			hashValue, err := hash.SHA256ForFile(f.Name())
-			if err != nil {
-				return fmt.Errorf("hashing artifact: %w", err)
-			}
 			mtx.Lock()
 			artifacts = append(artifacts, run.Artifact{
 				Path: artifactData.Location,
 				Checksum: map[string]string{
-					"SHA256": hashValue,
+					"SHA256": fmt.Sprintf("%x", h.Sum(nil)),
 				},
 				Time: attrs.Updated,
 			})
@@ -156,14 +146,14 @@ type ghcsManifestArtifact struct {
 	} `json:"file_hash"`
 }
 
-func readGCSObjectAttributes(client *storage.Client, objectURL string) (*storage.ObjectAttrs, error) {
+func readGCSObjectAttributes(ctx context.Context, client *storage.Client, objectURL string) (*storage.ObjectAttrs, error) {
 	bucket, path, err := parseGCSObjectURL(objectURL)
 	if err != nil {
 		return nil, fmt.Errorf("parsing GCS url: %w", err)
 	}
 
 	// Create the reader to copy data
-	attrs, err := client.Bucket(bucket).Object(strings.TrimPrefix(path, "/")).Attrs(context.Background())
+	attrs, err := client.Bucket(bucket).Object(strings.TrimPrefix(path, "/")).Attrs(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("creating bucket reader: %w", err)
 	}
@@ -171,31 +161,50 @@ func readGCSObjectAttributes(client *storage.Client, objectURL string) (*storage
 	return attrs, nil
 }
 
-func downloadGCSObject(client *storage.Client, objectURL string, f io.Writer) error {
+func downloadGCSObject(ctx context.Context, client *storage.Client, objectURL string, f io.Writer) error {
 	bucket, path, err := parseGCSObjectURL(objectURL)
 	if err != nil {
 		return fmt.Errorf("parsing GCS url: %w", err)
 	}
 
 	// Create the reader to copy data
-	rc, err := client.Bucket(bucket).Object(strings.TrimPrefix(path, "/")).NewReader(context.Background())
+	rc, err := client.Bucket(bucket).Object(strings.TrimPrefix(path, "/")).NewReader(ctx)
 	if err != nil {
 		return fmt.Errorf("creating bucket reader: %w", err)
 	}
 	defer rc.Close()
 	var b int64
-	if b, err = io.Copy(f, rc); err != nil {
+	if b, err = io.Copy(progress.Writer(f), rc); err != nil {
 		return fmt.Errorf("copying data: %w", err)
 	}
 	logrus.Debugf("Wrote %d bytes from %s", b, objectURL)
 	return nil
 }
 
+func uploadGCSObject(ctx context.Context, client *storage.Client, objectURL string, r io.Reader) error {
+	bucket, path, err := parseGCSObjectURL(objectURL)
+	if err != nil {
+		return fmt.Errorf("parsing GCS url: %w", err)
+	}
+
+	wr := client.Bucket(bucket).Object(strings.TrimPrefix(path, "/")).NewWriter(ctx)
+	b, err := io.Copy(wr, r)
+	if err != nil {
+		wr.Close()
+		return fmt.Errorf("copying data: %w", err)
+	}
+	if err := wr.Close(); err != nil {
+		return fmt.Errorf("closing bucket writer: %w", err)
+	}
+	logrus.Debugf("Wrote %d bytes to %s", b, objectURL)
+	return nil
+}
+
 // Downloads the manifest from the bucket
-func (gcb *GCB) readArtifactManifest(manifestURL string) ([]ghcsManifestArtifact, error) {
+func (gcb *GCB) readArtifactManifest(ctx context.Context, manifestURL string) ([]ghcsManifestArtifact, error) {
 	var b bytes.Buffer
 
-	if err := downloadGCSObject(gcb.client, manifestURL, &b); err != nil {
+	if err := downloadGCSObject(ctx, gcb.client, manifestURL, &b); err != nil {
 		return nil, fmt.Errorf("reading manifest from GCS: %w", err)
 	}
 
@@ -215,9 +224,9 @@ func (gcb *GCB) readArtifactManifest(manifestURL string) ([]ghcsManifestArtifact
 	return ret, nil
 }
 
-func (gcb *GCB) Snap() (*snapshot.Snapshot, error) {
+func (gcb *GCB) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
 	snap := snapshot.Snapshot{}
-	artifacts, err := gcb.readArtifacts()
+	artifacts, err := gcb.readArtifacts(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("reading artifacts: %w", err)
 	}
@@ -228,3 +237,12 @@ func (gcb *GCB) Snap() (*snapshot.Snapshot, error) {
 
 	return &snap, err
 }
+
+// Capabilities returns the features supported by the driver
+func (gcb *GCB) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: false,
+		Streaming:         false,
+	}
+}
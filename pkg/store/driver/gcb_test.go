@@ -18,6 +18,7 @@ package driver
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"cloud.google.com/go/storage"
@@ -29,7 +30,7 @@ func TestGCB(t *testing.T) {
 	gcb, err := NewGCB("gcb://puerco-chainguard/5dda8a10-abff-4c32-b003-758eea81ac83")
 	require.NoError(t, err)
 
-	artifacts, err := gcb.readArtifacts()
+	artifacts, err := gcb.readArtifacts(context.Background())
 	require.NoError(t, err)
 	require.Nil(t, artifacts)
 }
@@ -39,7 +40,24 @@ func TestGCSAttrs(t *testing.T) {
 	client, err := storage.NewClient(context.Background())
 	require.NoError(t, err)
 
-	attrs, err := readGCSObjectAttributes(client, "gs://puerco-chainguard-public/test-build/7a3bd0e/README.md")
+	attrs, err := readGCSObjectAttributes(context.Background(), client, "gs://puerco-chainguard-public/test-build/7a3bd0e/README.md")
 	require.Error(t, err)
 	require.NotNil(t, attrs)
 }
+
+func FuzzParseGCSObjectURL(f *testing.F) {
+	for _, seed := range []string{
+		"gs://puerco-chainguard-public/test-build/7a3bd0e/README.md",
+		"gs://bucket",
+		"gs:///object",
+		"https://bucket/object",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, objectURL string) {
+		_, _, err := parseGCSObjectURL(objectURL)
+		if err == nil && !strings.HasPrefix(strings.ToLower(objectURL), "gs:") {
+			t.Errorf("parsed non gs url %q", objectURL)
+		}
+	})
+}
@@ -18,12 +18,14 @@ package driver
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
-	"log"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -31,7 +33,11 @@ import (
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/iterator"
 
+	"sigs.k8s.io/tejolote/pkg/gcp"
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 func NewGCS(specURL string) (*GCS, error) {
@@ -41,14 +47,14 @@ func NewGCS(specURL string) (*GCS, error) {
 	}
 
 	ctx := context.Background()
-	client, err := newGCSClient(ctx)
+	client, err := newGCSClient(ctx, "gs://"+u.Hostname())
 	if err != nil {
 		return nil, fmt.Errorf("creating storage client: %w", err)
 	}
 
-	tmpdir, err := os.MkdirTemp("", "tejolote-gcs")
+	tmpdir, err := workdir.MkdirTemp("gcs-")
 	if err != nil {
-		return nil, fmt.Errorf("creating temporary directory")
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
 	}
 	logrus.Infof("GCS driver init: Bucket: %s Path: %s", u.Hostname(), u.Path)
 	return &GCS{
@@ -56,11 +62,12 @@ func NewGCS(specURL string) (*GCS, error) {
 		Path:    u.Path,
 		WorkDir: tmpdir,
 		client:  client,
+		synced:  snapshot.Snapshot{},
 	}, nil
 }
 
-func newGCSClient(ctx context.Context) (*storage.Client, error) {
-	client, err := storage.NewClient(ctx)
+func newGCSClient(ctx context.Context, scope string) (*storage.Client, error) {
+	client, err := gcp.NewStorageClient(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +79,11 @@ type GCS struct {
 	Path    string
 	WorkDir string
 	client  *storage.Client
+
+	// synced are the objects copied to the work directory, hashed
+	// while they were downloaded
+	mtx    sync.Mutex
+	synced snapshot.Snapshot
 }
 
 // syncGCSPrefix synchs a prefix in the bucket (a directory) and
@@ -91,12 +103,14 @@ func (gcs *GCS) syncGCSPrefix(ctx context.Context, prefix string, seen map[strin
 			break
 		}
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("listing bucket objects: %w", err)
 		}
 
 		// If name is empty, then it is a new prefix, lets index it:
 		if _, ok := seen[attrs.Prefix]; !ok && attrs.Name == "" {
-			_ = gcs.syncGCSPrefix(ctx, attrs.Prefix, seen) //nolint: errcheck
+			if err := gcs.syncGCSPrefix(ctx, attrs.Prefix, seen); err != nil {
+				return fmt.Errorf("synching prefix %s: %w", attrs.Prefix, err)
+			}
 			continue
 		}
 
@@ -105,7 +119,9 @@ func (gcs *GCS) syncGCSPrefix(ctx context.Context, prefix string, seen map[strin
 		if strings.HasSuffix(attrs.Name, "/") {
 			trimmed := strings.TrimSuffix(attrs.Name, "/")
 			if _, ok := seen[trimmed]; !ok {
-				_ = gcs.syncGCSPrefix(ctx, trimmed, seen) //nolint: errcheck
+				if err := gcs.syncGCSPrefix(ctx, trimmed, seen); err != nil {
+					return fmt.Errorf("synching prefix %s: %w", trimmed, err)
+				}
 				continue
 			}
 		}
@@ -123,14 +139,15 @@ func (gcs *GCS) syncGCSPrefix(ctx context.Context, prefix string, seen map[strin
 		if attrs.Name != "" {
 			// TODO: Check file md5 to see if it needs sync
 			filesToSync = append(filesToSync, attrs.Prefix+attrs.Name)
+			progress.Listed(1)
 		}
 	}
 
-	var wg errgroup.Group
+	wg, ctx := errgroup.WithContext(ctx)
 	for _, filename := range filesToSync {
 		filename := filename
 		wg.Go(func() error {
-			if err := gcs.syncGSFile(filename); err != nil {
+			if err := gcs.syncGSFile(ctx, filename); err != nil {
 				return fmt.Errorf("synching file: %w", err)
 			}
 			return nil
@@ -142,26 +159,28 @@ func (gcs *GCS) syncGCSPrefix(ctx context.Context, prefix string, seen map[strin
 	return nil
 }
 
-// syncGSFile copies a file from the bucket to local workdir
-func (gcs *GCS) syncGSFile(filePath string) error {
+// syncGSFile copies a file from the bucket to local workdir, computing
+// its SHA256 digest as the data is written
+func (gcs *GCS) syncGSFile(ctx context.Context, filePath string) error {
 	logrus.WithField("driver", "gcs").Debugf("Copying file from bucket: %s", filePath)
 	localpath := filepath.Join(gcs.WorkDir, filePath)
 	// Ensure the directory exists
 	_ = os.MkdirAll(filepath.Dir(localpath), os.FileMode(0o755)) //nolint: errcheck
 
 	// Open the local file
-	f, err := os.OpenFile(localpath, os.O_RDWR|os.O_CREATE, 0o644)
+	f, err := os.OpenFile(localpath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
 	if err != nil {
 		return fmt.Errorf("opening localfile: %w", err)
 	}
 	defer f.Close()
 
 	objectURL := fmt.Sprintf("gs://%s/%s", gcs.Bucket, filePath)
-	if err := downloadGCSObject(gcs.client, objectURL, f); err != nil {
+	h := sha256.New()
+	if err := downloadGCSObject(ctx, gcs.client, objectURL, io.MultiWriter(f, h)); err != nil {
 		return fmt.Errorf("downloading object: %w", err)
 	}
 
-	attrs, err := readGCSObjectAttributes(gcs.client, objectURL)
+	attrs, err := readGCSObjectAttributes(ctx, gcs.client, objectURL)
 	if err != nil {
 		return fmt.Errorf("reading file attributes: %w", err)
 	}
@@ -171,11 +190,20 @@ func (gcs *GCS) syncGSFile(filePath string) error {
 		return fmt.Errorf("updating local file modification time: %w", err)
 	}
 
+	progress.Hashed(1)
+	path := "gs://" + filepath.Join(gcs.Bucket, filePath)
+	gcs.mtx.Lock()
+	gcs.synced[path] = run.Artifact{
+		Path:     path,
+		Checksum: map[string]string{"SHA256": fmt.Sprintf("%x", h.Sum(nil))},
+		Time:     attrs.Updated,
+	}
+	gcs.mtx.Unlock()
 	return nil
 }
 
 // Snap takes a snapshot of the directory
-func (gcs *GCS) Snap() (*snapshot.Snapshot, error) {
+func (gcs *GCS) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
 	if gcs.Path == "" {
 		return nil, fmt.Errorf("gcs store has no path defined")
 	}
@@ -184,29 +212,101 @@ func (gcs *GCS) Snap() (*snapshot.Snapshot, error) {
 		return nil, fmt.Errorf("gcs store has no bucket defined")
 	}
 
+	gcs.mtx.Lock()
+	gcs.synced = snapshot.Snapshot{}
+	gcs.mtx.Unlock()
+
 	if err := gcs.syncGCSPrefix(
-		context.Background(), strings.TrimPrefix(gcs.Path, "/"), map[string]struct{}{},
+		ctx, strings.TrimPrefix(gcs.Path, "/"), map[string]struct{}{},
 	); err != nil {
+		// If we were interrupted, the partial mirror is useless
+		if ctx.Err() != nil {
+			os.RemoveAll(gcs.WorkDir)
+		}
 		return nil, fmt.Errorf("synching bucket: %w", err)
 	}
 
-	// To snapshot the directory, we reuse the directory
-	// store and use its artifacts
-	dir, err := NewDirectory(fmt.Sprintf("file://%s", gcs.WorkDir))
-	if err != nil {
-		return nil, fmt.Errorf("creating temp directory store: %w", err)
-	}
-	snapDir, err := dir.Snap()
-	if err != nil {
-		return nil, fmt.Errorf("snapshotting work directory: %w", err)
-	}
+	// The objects were hashed while synching, there is no need
+	// to read the work directory again
+	gcs.mtx.Lock()
+	defer gcs.mtx.Unlock()
 	snap := snapshot.Snapshot{}
-
-	for _, a := range *snapDir {
-		path := "gs://" + filepath.Join(gcs.Bucket, strings.TrimPrefix(a.Path, gcs.WorkDir))
-		a.Path = path
-		// Perhaps we should null the artifact dates
+	for path, a := range gcs.synced {
 		snap[path] = a
 	}
 	return &snap, nil
 }
+
+// Index writes the object listing of the bucket path to an on-disk
+// snapshot index. Unlike Snap, the bucket is not mirrored: artifacts are
+// recorded with the MD5 and CRC32C digests kept in the object metadata.
+// HashArtifact computes the SHA256 digest of the objects that changed.
+func (gcs *GCS) Index(ctx context.Context, idx *snapshot.Index) error {
+	if gcs.Bucket == "" {
+		return fmt.Errorf("gcs store has no bucket defined")
+	}
+	it := gcs.client.Bucket(gcs.Bucket).Objects(ctx, &storage.Query{
+		Prefix: strings.TrimPrefix(gcs.Path, "/"),
+	})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("listing bucket objects: %w", err)
+		}
+		// Skip the directory markers, see syncGCSPrefix
+		if strings.HasSuffix(attrs.Name, "/") || (attrs.Size > 0 && attrs.ContentType == "text/plain") {
+			continue
+		}
+		a := run.Artifact{
+			Path:     "gs://" + gcs.Bucket + "/" + attrs.Name,
+			Checksum: map[string]string{"CRC32C": fmt.Sprintf("%08x", attrs.CRC32C)},
+			Time:     attrs.Updated,
+		}
+		// Composite objects have no MD5
+		if len(attrs.MD5) > 0 {
+			a.Checksum["MD5"] = fmt.Sprintf("%x", attrs.MD5)
+		}
+		if err := idx.Add(a); err != nil {
+			return err
+		}
+		progress.Listed(1)
+	}
+}
+
+// HashArtifact adds the SHA256 digest to an artifact read from the
+// bucket by streaming the object contents through the hasher
+func (gcs *GCS) HashArtifact(ctx context.Context, a run.Artifact) (run.Artifact, error) {
+	h := sha256.New()
+	if err := downloadGCSObject(ctx, gcs.client, a.Path, h); err != nil {
+		return a, fmt.Errorf("hashing %s: %w", a.Path, err)
+	}
+	progress.Hashed(1)
+	checksum := map[string]string{"SHA256": fmt.Sprintf("%x", h.Sum(nil))}
+	for algo, val := range a.Checksum {
+		checksum[algo] = val
+	}
+	a.Checksum = checksum
+	return a, nil
+}
+
+// LocalPath returns the path of an object in the local mirror of the
+// bucket. The mirror is populated when taking a snapshot.
+func (gcs *GCS) LocalPath(path string) (string, error) {
+	name, ok := strings.CutPrefix(path, "gs://"+gcs.Bucket+"/")
+	if !ok {
+		return "", fmt.Errorf("%s is not an object in bucket %s", path, gcs.Bucket)
+	}
+	return filepath.Join(gcs.WorkDir, name), nil
+}
+
+// Capabilities returns the features supported by the driver
+func (gcs *GCS) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
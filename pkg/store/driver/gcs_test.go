@@ -17,9 +17,19 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
 )
 
 func TestGCSSnap(t *testing.T) {
@@ -27,7 +37,7 @@ func TestGCSSnap(t *testing.T) {
 	gcs, err := NewGCS("gs://kubernetes-release/release/v1.24.4/bin/windows/386/")
 	require.NoError(t, err)
 
-	snap, err := gcs.Snap()
+	snap, err := gcs.Snap(context.Background())
 	require.Error(t, err)
 	require.NotNil(t, snap)
 }
@@ -36,5 +46,123 @@ func TestSyncGSFile(t *testing.T) {
 	t.Skip("Review this test")
 	gcs, err := NewGCS("gs://kubernetes-release/release/v1.24.4/bin/")
 	require.NoError(t, err)
-	require.NoError(t, gcs.syncGSFile("release/v1.24.4/bin/windows/386/kubectl.exe.sha256"))
+	require.NoError(t, gcs.syncGSFile(context.Background(), "release/v1.24.4/bin/windows/386/kubectl.exe.sha256"))
+}
+
+func TestUploadURLReadOnly(t *testing.T) {
+	readonly.Enable()
+	defer readonly.Disable()
+	// Remote uploads must fail before any client is created
+	for _, dest := range []string{"gs://bucket/file.txt", "s3://bucket/file.txt", "oci://registry.example.com/repo:file"} {
+		err := UploadURL(context.Background(), dest, strings.NewReader("data"))
+		require.ErrorIs(t, err, readonly.ErrReadOnly, dest)
+	}
+
+	// Local files are not affected
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, UploadURL(context.Background(), "file://"+path, strings.NewReader("data")))
+	require.FileExists(t, path)
+}
+
+// fakeGCSBucket serves the object listings and reads of the storage
+// client pointed to it with STORAGE_EMULATOR_HOST. Requests for the
+// names in denied fail with 403, which the client does not retry.
+type fakeGCSBucket struct {
+	objects map[string]string
+	denied  map[string]bool
+}
+
+func (b *fakeGCSBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const listPath = "/storage/v1/b/bucket/o"
+	var name string
+	switch {
+	case r.URL.Path == listPath:
+		name = r.URL.Query().Get("prefix")
+	case strings.HasPrefix(r.URL.Path, listPath+"/"):
+		name = strings.TrimPrefix(r.URL.Path, listPath+"/")
+	case strings.HasPrefix(r.URL.Path, "/bucket/"):
+		name = strings.TrimPrefix(r.URL.Path, "/bucket/")
+	}
+	if b.denied[name] {
+		http.Error(w, `{"error":{"code":403,"message":"denied"}}`, http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.URL.Path == listPath:
+		b.list(w, name)
+	case strings.HasPrefix(r.URL.Path, listPath+"/"):
+		json.NewEncoder(w).Encode(b.attrs(name)) //nolint: errcheck
+	case strings.HasPrefix(r.URL.Path, "/bucket/"):
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b.objects[name])))
+		fmt.Fprint(w, b.objects[name])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *fakeGCSBucket) attrs(name string) map[string]string {
+	return map[string]string{
+		"bucket":      "bucket",
+		"name":        name,
+		"size":        fmt.Sprintf("%d", len(b.objects[name])),
+		"contentType": "application/octet-stream",
+		"updated":     time.Unix(1700000000, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+func (b *fakeGCSBucket) list(w http.ResponseWriter, prefix string) {
+	items := []map[string]string{}
+	prefixes := []string{}
+	seen := map[string]bool{}
+	for name := range b.objects {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		if dir, _, ok := strings.Cut(rest, "/"); ok {
+			if !seen[dir] {
+				prefixes = append(prefixes, prefix+dir+"/")
+				seen[dir] = true
+			}
+			continue
+		}
+		items = append(items, b.attrs(name))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "prefixes": prefixes}) //nolint: errcheck
+}
+
+func TestGCSSnapErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		denied string
+	}{
+		{name: "complete"},
+		{name: "nested prefix", denied: "release/bin/"},
+		{name: "object", denied: "release/bin/kubectl"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(&fakeGCSBucket{
+				objects: map[string]string{
+					"release/README":      "readme",
+					"release/bin/kubectl": "binary",
+				},
+				denied: map[string]bool{tc.denied: true},
+			})
+			defer srv.Close()
+			t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+			gcs, err := NewGCS("gs://bucket/release/")
+			require.NoError(t, err)
+			snap, err := gcs.Snap(context.Background())
+			if tc.denied == "" {
+				require.NoError(t, err)
+				require.Len(t, *snap, 2)
+				return
+			}
+			// An incomplete sync must not produce a partial snapshot
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "release/bin/")
+			require.Nil(t, snap)
+		})
+	}
 }
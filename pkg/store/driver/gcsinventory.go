@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+	"sigs.k8s.io/tejolote/pkg/workdir"
+)
+
+// GCSInventory reads the objects of a bucket from a Cloud Storage
+// Insights inventory report instead of listing the bucket. Inventory
+// reports are generated periodically, so the snapshot is only as fresh
+// as the report, but it allows attesting buckets with millions of
+// objects. The spec URL points to the report manifest or to a single
+// CSV shard and can filter the objects with a prefix:
+//
+//	gcsinventory+gs://reports/config/2024-01-01T00:00_manifest.json?prefix=releases/v1.0/
+type GCSInventory struct {
+	URL    string
+	Prefix string
+}
+
+func NewGCSInventory(specURL string) (*GCSInventory, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing inventory spec url: %w", err)
+	}
+	if !strings.HasPrefix(u.Scheme, "gcsinventory+") {
+		return nil, fmt.Errorf("spec URL %s is not an inventory report url", specURL)
+	}
+	prefix := u.Query().Get("prefix")
+	u.Scheme = strings.TrimPrefix(u.Scheme, "gcsinventory+")
+	u.RawQuery = ""
+	logrus.Infof("Initialized new GCS inventory report storage backend (%s)", specURL)
+	return &GCSInventory{
+		URL:    u.String(),
+		Prefix: prefix,
+	}, nil
+}
+
+// inventoryManifest is the manifest written with each inventory report
+type inventoryManifest struct {
+	ReportConfig struct {
+		CSVOptions struct {
+			Delimiter string `json:"delimiter"`
+		} `json:"csv_options"`
+	} `json:"report_config"`
+	SnapshotTime string   `json:"snapshot_time"`
+	ShardCount   int      `json:"shard_count"`
+	Shards       []string `json:"report_shards_file_names"`
+}
+
+// Snap reads the inventory report shards into a snapshot
+func (inv *GCSInventory) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	shards := []string{inv.URL}
+	delimiter := ","
+	if !strings.HasSuffix(inv.URL, ".csv") {
+		var b bytes.Buffer
+		if err := DownloadURL(ctx, inv.URL, &b); err != nil {
+			return nil, fmt.Errorf("downloading inventory manifest: %w", err)
+		}
+		manifest := &inventoryManifest{}
+		if err := json.Unmarshal(b.Bytes(), manifest); err != nil {
+			return nil, fmt.Errorf("parsing inventory manifest: %w", err)
+		}
+		if len(manifest.Shards) == 0 {
+			return nil, errors.New("inventory manifest lists no report shards")
+		}
+		if manifest.ReportConfig.CSVOptions.Delimiter != "" {
+			delimiter = manifest.ReportConfig.CSVOptions.Delimiter
+		}
+		logrus.Infof(
+			"Reading %d inventory shards, objects are listed as of %s",
+			len(manifest.Shards), manifest.SnapshotTime,
+		)
+		// Shards are written next to the manifest
+		shards = []string{}
+		base := inv.URL[:strings.LastIndex(inv.URL, "/")+1]
+		for _, s := range manifest.Shards {
+			shards = append(shards, base+path.Base(s))
+		}
+	}
+
+	snap := snapshot.Snapshot{}
+	for _, shard := range shards {
+		if err := inv.readShard(ctx, shard, delimiter, snap); err != nil {
+			return nil, fmt.Errorf("reading inventory shard %s: %w", shard, err)
+		}
+	}
+	return &snap, nil
+}
+
+// readShard downloads a report shard and adds its objects to the snapshot
+func (inv *GCSInventory) readShard(ctx context.Context, shardURL, delimiter string, snap snapshot.Snapshot) error {
+	f, err := workdir.CreateTemp("inventory-shard-")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := DownloadURL(ctx, shardURL, f); err != nil {
+		return fmt.Errorf("downloading shard: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("rewinding shard file: %w", err)
+	}
+	return parseInventoryCSV(f, []rune(delimiter)[0], inv.Prefix, snap)
+}
+
+// parseInventoryCSV reads the objects of an inventory report in CSV
+// format. The report must include the header row and at least the
+// bucket, name and md5Hash or crc32c columns.
+func parseInventoryCSV(r io.Reader, delimiter rune, prefix string, snap snapshot.Snapshot) error {
+	cr := csv.NewReader(r)
+	cr.Comma = delimiter
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading report header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[h] = i
+	}
+	for _, required := range []string{"bucket", "name"} {
+		if _, ok := cols[required]; !ok {
+			return fmt.Errorf("inventory report has no %s column", required)
+		}
+	}
+	_, hasMD5 := cols["md5Hash"]
+	_, hasCRC := cols["crc32c"]
+	if !hasMD5 && !hasCRC {
+		return errors.New("inventory report has no md5Hash or crc32c column")
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading report record: %w", err)
+		}
+		name := record[cols["name"]]
+		if !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, "/") {
+			continue
+		}
+		a := run.Artifact{
+			Path:     "gs://" + record[cols["bucket"]] + "/" + name,
+			Checksum: map[string]string{},
+		}
+		// Object hashes are base64 encoded in the report
+		for col, algo := range map[string]string{"md5Hash": "MD5", "crc32c": "CRC32C"} {
+			i, ok := cols[col]
+			if !ok || record[i] == "" {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(record[i])
+			if err != nil {
+				return fmt.Errorf("decoding %s of %s: %w", col, name, err)
+			}
+			a.Checksum[algo] = fmt.Sprintf("%x", sum)
+		}
+		if i, ok := cols["updated"]; ok {
+			if t, err := time.Parse(time.RFC3339Nano, record[i]); err == nil {
+				a.Time = t
+			}
+		}
+		snap[a.Path] = a
+		progress.Listed(1)
+	}
+}
+
+// Capabilities returns the features supported by the driver
+func (inv *GCSInventory) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
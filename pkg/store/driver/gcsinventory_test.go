@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCSInventorySnap(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `{
+  "report_config": {"csv_options": {"delimiter": ","}},
+  "snapshot_time": "2024-01-01T00:00:00Z",
+  "shard_count": 2,
+  "report_shards_file_names": ["report_0.csv", "report_1.csv"]
+}`
+	shard0 := "bucket,name,size,md5Hash,crc32c,updated\n" +
+		"data,releases/v1/bin,4,CY9rzUYh03PK3k6DJie09g==,hqBywA==,2024-01-01T10:00:00.5Z\n" +
+		"data,releases/v1/,0,1B2M2Y8AsgTpgAmY7PhCfg==,AAAAAA==,2024-01-01T10:00:00Z\n"
+	shard1 := "bucket,name,size,md5Hash,crc32c,updated\n" +
+		"data,releases/v2/bin,4,,hqBywA==,2024-01-02T10:00:00Z\n" +
+		"data,other/file,4,CY9rzUYh03PK3k6DJie09g==,hqBywA==,2024-01-02T10:00:00Z\n"
+	for name, content := range map[string]string{
+		"manifest.json": manifest, "report_0.csv": shard0, "report_1.csv": shard1,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0o644)))
+	}
+
+	inv, err := NewGCSInventory("gcsinventory+file://" + filepath.Join(dir, "manifest.json") + "?prefix=releases/")
+	require.NoError(t, err)
+	require.Equal(t, "releases/", inv.Prefix)
+
+	snap, err := inv.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 2)
+
+	a, ok := (*snap)["gs://data/releases/v1/bin"]
+	require.True(t, ok)
+	require.Equal(t, "098f6bcd4621d373cade4e832627b4f6", a.Checksum["MD5"])
+	require.Equal(t, "86a072c0", a.Checksum["CRC32C"])
+	require.Equal(t, 2024, a.Time.Year())
+
+	// Composite objects have no MD5 in the report
+	a, ok = (*snap)["gs://data/releases/v2/bin"]
+	require.True(t, ok)
+	require.NotContains(t, a.Checksum, "MD5")
+
+	// Reading a single shard
+	inv, err = NewGCSInventory("gcsinventory+file://" + filepath.Join(dir, "report_1.csv"))
+	require.NoError(t, err)
+	snap, err = inv.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 2)
+
+	_, err = NewGCSInventory("gs://data/manifest.json")
+	require.Error(t, err)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -31,6 +32,7 @@ import (
 	"sigs.k8s.io/release-utils/hash"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 type GitHubRelease struct {
@@ -76,9 +78,9 @@ func NewGithub(specURL string) (*GitHubRelease, error) {
 	return ghr, nil
 }
 
-func (ghr *GitHubRelease) Snap() (*snapshot.Snapshot, error) {
+func (ghr *GitHubRelease) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
 	// Download assets to temporary directory
-	tmp, err := os.MkdirTemp("", "github-assets-")
+	tmp, err := workdir.MkdirTemp("github-assets-")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp dir: %w", err)
 	}
@@ -97,6 +99,9 @@ func (ghr *GitHubRelease) Snap() (*snapshot.Snapshot, error) {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -126,3 +131,12 @@ func (ghr *GitHubRelease) Snap() (*snapshot.Snapshot, error) {
 	}
 	return &snap, nil
 }
+
+// Capabilities returns the features supported by the driver
+func (ghr *GitHubRelease) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -27,7 +28,7 @@ import (
 func TestGitHubRelease(t *testing.T) {
 	gh, err := NewGithub("github://puerco/hello/v0.0.1")
 	require.NoError(t, err)
-	snap, err := gh.Snap()
+	snap, err := gh.Snap(context.Background())
 	require.NoError(t, err)
 	require.NotNil(t, snap)
 	ns := snapshot.Snapshot{}
@@ -38,3 +39,23 @@ func TestGitHubRelease(t *testing.T) {
 		(*snap)["sbom.spdx"].Checksum["SHA256"],
 	)
 }
+
+func FuzzNewGithub(f *testing.F) {
+	for _, seed := range []string{
+		"github://puerco/hello/v0.0.1",
+		"github://puerco/hello",
+		"github://puerco//",
+		"github:///a/b",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		ghr, err := NewGithub(spec)
+		if err != nil {
+			return
+		}
+		if ghr == nil {
+			t.Errorf("no driver and no error parsing %q", spec)
+		}
+	})
+}
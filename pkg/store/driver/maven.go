@@ -0,0 +1,235 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// mavenSidecars are the checksum files published next to each artifact,
+// in order of preference, with the checksum key they map to
+var mavenSidecars = []struct{ suffix, algo string }{
+	{".sha256", "SHA256"},
+	{".sha1", "SHA1"},
+}
+
+// mavenDefaultFiles are the files probed when the repository does not
+// serve directory listings, relative to artifactId-version
+var mavenDefaultFiles = []string{".pom", ".jar", "-sources.jar", "-javadoc.jar", ".module"}
+
+var mavenHref = regexp.MustCompile(`href="([^"]+)"`)
+
+// Maven reads the files of a release in a Maven repository. The spec URL
+// has the repository URL (without scheme) followed by the coordinates of
+// the release, with the groupId written with dots:
+//
+//	maven://repo1.maven.org/maven2/org.example/app/1.0.0
+//
+// Digests are read from the .sha256 (or .sha1) sidecar files published
+// with each artifact. Add ?plain-http=true to talk to the repository
+// without TLS. Credentials are read from $MAVEN_USERNAME and
+// $MAVEN_PASSWORD.
+type Maven struct {
+	RepositoryURL string
+	GroupID       string
+	ArtifactID    string
+	Version       string
+}
+
+func NewMaven(specURL string) (*Maven, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing maven spec url: %w", err)
+	}
+	if u.Scheme != "maven" {
+		return nil, errors.New("spec url is not a maven url")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 3 {
+		return nil, errors.New("maven spec url must be maven://repository/groupId/artifactId/version")
+	}
+	// The last three segments are the coordinates, the rest is the
+	// path of the repository in the server
+	n := len(parts)
+	scheme := "https"
+	if u.Query().Get("plain-http") == "true" {
+		scheme = "http"
+	}
+	m := &Maven{
+		RepositoryURL: fmt.Sprintf("%s://%s/%s", scheme, u.Host, strings.Join(parts[:n-3], "/")),
+		GroupID:       parts[n-3],
+		ArtifactID:    parts[n-2],
+		Version:       parts[n-1],
+	}
+	m.RepositoryURL = strings.TrimSuffix(m.RepositoryURL, "/")
+	logrus.Infof("Initialized new Maven storage backend (%s)", specURL)
+	return m, nil
+}
+
+// versionURL returns the URL of the directory holding the release files
+func (m *Maven) versionURL() string {
+	return fmt.Sprintf(
+		"%s/%s/%s/%s/", m.RepositoryURL, strings.ReplaceAll(m.GroupID, ".", "/"), m.ArtifactID, m.Version,
+	)
+}
+
+// Snap records the release files with the digests from their sidecars
+func (m *Maven) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	files, err := m.listFiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing release files: %w", err)
+	}
+	snap := snapshot.Snapshot{}
+	for _, name := range files {
+		a, err := m.readChecksum(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading checksum of %s: %w", name, err)
+		}
+		if a == nil {
+			logrus.Debugf("Skipping %s, it has no checksum sidecar", name)
+			continue
+		}
+		snap[name] = *a
+	}
+	return &snap, nil
+}
+
+// listFiles returns the names of the release files. It reads the
+// directory listing served by the repository and falls back to the
+// default file names when there is none.
+func (m *Maven) listFiles(ctx context.Context) ([]string, error) {
+	prefix := m.ArtifactID + "-" + m.Version
+	res, err := m.get(ctx, m.versionURL())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		logrus.Debugf("No directory listing for %s (%s), probing default files", m.versionURL(), res.Status)
+		files := []string{}
+		for _, suffix := range mavenDefaultFiles {
+			files = append(files, prefix+suffix)
+		}
+		return files, nil
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory listing: %w", err)
+	}
+	files := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range mavenHref.FindAllStringSubmatch(string(data), -1) {
+		name := match[1][strings.LastIndex(match[1], "/")+1:]
+		if !strings.HasPrefix(name, prefix) || isMavenSidecar(name) {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// isMavenSidecar returns true for checksum and signature files
+func isMavenSidecar(name string) bool {
+	for _, ext := range []string{".sha256", ".sha1", ".sha512", ".md5", ".asc"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readChecksum reads the preferred sidecar of a file. It returns nil
+// when the file has no sidecar published.
+func (m *Maven) readChecksum(ctx context.Context, name string) (*run.Artifact, error) {
+	fileURL := m.versionURL() + name
+	for _, sidecar := range mavenSidecars {
+		res, err := m.get(ctx, fileURL+sidecar.suffix)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusNotFound {
+			res.Body.Close()
+			continue
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("http error reading %s: %s", sidecar.suffix, res.Status)
+		}
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", sidecar.suffix, err)
+		}
+		// Sidecars may be in the sha256sum format (digest  filename)
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s sidecar is empty", sidecar.suffix)
+		}
+		a := &run.Artifact{
+			Path:     fileURL,
+			Checksum: map[string]string{sidecar.algo: strings.ToLower(fields[0])},
+		}
+		if t, err := time.Parse(http.TimeFormat, res.Header.Get("Last-Modified")); err == nil {
+			a.Time = t
+		}
+		return a, nil
+	}
+	return nil, nil
+}
+
+func (m *Maven) get(ctx context.Context, getURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating http request: %w", err)
+	}
+	if user := os.Getenv("MAVEN_USERNAME"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("MAVEN_PASSWORD"))
+	}
+	res, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying maven repository: %w", err)
+	}
+	return res, nil
+}
+
+// Capabilities returns the features supported by the driver
+func (m *Maven) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
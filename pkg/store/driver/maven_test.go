@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMavenSnap(t *testing.T) {
+	const dir = "/maven2/org/example/app/1.0.0/"
+	sidecars := map[string]string{
+		dir + "app-1.0.0.jar.sha256":         "AA  app-1.0.0.jar\n",
+		dir + "app-1.0.0.jar.sha1":           "ff",
+		dir + "app-1.0.0.pom.sha1":           "bb",
+		dir + "app-1.0.0-sources.jar.sha256": "cc",
+	}
+	listing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == dir {
+			if !listing {
+				http.NotFound(w, r)
+				return
+			}
+			for _, name := range []string{
+				"app-1.0.0.jar", "app-1.0.0.jar.sha1", "app-1.0.0.jar.sha256", "app-1.0.0.jar.asc",
+				"app-1.0.0.pom", "app-1.0.0.pom.sha1", "app-1.0.0-sources.jar", "../",
+			} {
+				fmt.Fprintf(w, "<a href=%q>%s</a>\n", name, name)
+			}
+			return
+		}
+		data, ok := sidecars[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	m, err := NewMaven("maven://" + host + "/maven2/org.example/app/1.0.0?plain-http=true")
+	require.NoError(t, err)
+	require.Equal(t, srv.URL+"/maven2", m.RepositoryURL)
+	require.Equal(t, srv.URL+dir, m.versionURL())
+
+	for _, listing = range []bool{true, false} {
+		snap, err := m.Snap(context.Background())
+		require.NoError(t, err)
+		require.Len(t, *snap, 3)
+		require.Equal(t, map[string]string{"SHA256": "aa"}, (*snap)["app-1.0.0.jar"].Checksum)
+		require.Equal(t, map[string]string{"SHA1": "bb"}, (*snap)["app-1.0.0.pom"].Checksum)
+		require.Equal(t, srv.URL+dir+"app-1.0.0-sources.jar", (*snap)["app-1.0.0-sources.jar"].Path)
+	}
+
+	_, err = NewMaven("maven://host/app/1.0.0")
+	require.Error(t, err)
+}
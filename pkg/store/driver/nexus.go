@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// nexusAssetsPath is the REST API endpoint listing the assets of a repository
+const nexusAssetsPath = "/service/rest/v1/assets"
+
+// Nexus reads the assets of a Sonatype Nexus repository using the REST
+// API. Digests are read from the checksums Nexus publishes for each
+// asset. The spec URL points to a repository and, optionally, a path
+// inside it to restrict the assets collected:
+//
+//	nexus://nexus.example.com/repository/maven-releases/org/example/app/
+//
+// Add ?plain-http=true to talk to the server without TLS. Credentials
+// are read from $NEXUS_USERNAME and $NEXUS_PASSWORD.
+type Nexus struct {
+	Host       string
+	Repository string
+	Path       string
+	PlainHTTP  bool
+}
+
+func NewNexus(specURL string) (*Nexus, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing nexus spec url: %w", err)
+	}
+	if u.Scheme != "nexus" {
+		return nil, errors.New("spec url is not a nexus url")
+	}
+	if u.Host == "" {
+		return nil, errors.New("nexus spec url has no host")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != "repository" || parts[1] == "" {
+		return nil, errors.New("unable to parse repository/NAME/path from nexus spec url")
+	}
+	n := &Nexus{
+		Host:       u.Host,
+		Repository: parts[1],
+		PlainHTTP:  u.Query().Get("plain-http") == "true",
+	}
+	if len(parts) == 3 {
+		n.Path = parts[2]
+	}
+	logrus.Infof("Initialized new Nexus storage backend (%s)", specURL)
+	return n, nil
+}
+
+// nexusAsset is an asset as returned by the Nexus REST API
+type nexusAsset struct {
+	Path         string            `json:"path"`
+	DownloadURL  string            `json:"downloadUrl"`
+	Checksum     map[string]string `json:"checksum"`
+	LastModified string            `json:"lastModified"`
+}
+
+type nexusAssetPage struct {
+	Items             []nexusAsset `json:"items"`
+	ContinuationToken string       `json:"continuationToken"`
+}
+
+// Snap lists the assets in the repository path into a snapshot
+func (n *Nexus) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	scheme := "https"
+	if n.PlainHTTP {
+		scheme = "http"
+	}
+	snap := snapshot.Snapshot{}
+	token := ""
+	for {
+		q := url.Values{"repository": []string{n.Repository}}
+		if token != "" {
+			q.Set("continuationToken", token)
+		}
+		page := &nexusAssetPage{}
+		if err := n.getJSON(ctx, fmt.Sprintf("%s://%s%s?%s", scheme, n.Host, nexusAssetsPath, q.Encode()), page); err != nil {
+			return nil, fmt.Errorf("listing repository assets: %w", err)
+		}
+		for _, asset := range page.Items {
+			assetPath := strings.TrimPrefix(asset.Path, "/")
+			if !strings.HasPrefix(assetPath, n.Path) {
+				continue
+			}
+			a := run.Artifact{
+				Path:     fmt.Sprintf("nexus://%s/repository/%s/%s", n.Host, n.Repository, assetPath),
+				Checksum: map[string]string{},
+			}
+			for algo, value := range asset.Checksum {
+				a.Checksum[strings.ToUpper(algo)] = value
+			}
+			if asset.LastModified != "" {
+				t, err := time.Parse(time.RFC3339, asset.LastModified)
+				if err != nil {
+					return nil, fmt.Errorf("parsing modification time of %s: %w", assetPath, err)
+				}
+				a.Time = t
+			}
+			snap[assetPath] = a
+		}
+		if page.ContinuationToken == "" {
+			break
+		}
+		token = page.ContinuationToken
+	}
+	return &snap, nil
+}
+
+// getJSON queries the Nexus API and unmarshals the response
+func (n *Nexus) getJSON(ctx context.Context, apiURL string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if user := os.Getenv("NEXUS_USERNAME"); user != "" {
+		req.SetBasicAuth(user, os.Getenv("NEXUS_PASSWORD"))
+	}
+	resp, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("querying nexus api: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http error from nexus api: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return fmt.Errorf("decoding nexus response: %w", err)
+	}
+	return nil
+}
+
+// Capabilities returns the features supported by the driver
+func (n *Nexus) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
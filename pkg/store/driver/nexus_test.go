@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNexusSnap(t *testing.T) {
+	pages := map[string]nexusAssetPage{
+		"": {
+			Items: []nexusAsset{
+				{
+					Path:         "/org/example/app/1.0/app-1.0.jar",
+					Checksum:     map[string]string{"sha1": "aa", "sha256": "bb", "md5": "cc"},
+					LastModified: "2024-01-01T10:00:00.000+00:00",
+				},
+				{Path: "/org/other/lib/1.0/lib-1.0.jar", Checksum: map[string]string{"sha256": "dd"}},
+			},
+			ContinuationToken: "next",
+		},
+		"next": {
+			Items: []nexusAsset{
+				{Path: "/org/example/app/1.0/app-1.0.pom", Checksum: map[string]string{"sha256": "ee"}},
+			},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, nexusAssetsPath, r.URL.Path)
+		require.Equal(t, "maven-releases", r.URL.Query().Get("repository"))
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "reader", user)
+		require.Equal(t, "secret", pass)
+		json.NewEncoder(w).Encode(pages[r.URL.Query().Get("continuationToken")]) //nolint: errcheck
+	}))
+	defer srv.Close()
+	t.Setenv("NEXUS_USERNAME", "reader")
+	t.Setenv("NEXUS_PASSWORD", "secret")
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	n, err := NewNexus("nexus://" + host + "/repository/maven-releases/org/example/?plain-http=true")
+	require.NoError(t, err)
+	require.Equal(t, "maven-releases", n.Repository)
+	require.Equal(t, "org/example/", n.Path)
+
+	snap, err := n.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 2)
+	a := (*snap)["org/example/app/1.0/app-1.0.jar"]
+	require.Equal(t, "nexus://"+host+"/repository/maven-releases/org/example/app/1.0/app-1.0.jar", a.Path)
+	require.Equal(t, map[string]string{"SHA1": "aa", "SHA256": "bb", "MD5": "cc"}, a.Checksum)
+	require.Equal(t, 2024, a.Time.Year())
+	require.Contains(t, *snap, "org/example/app/1.0/app-1.0.pom")
+
+	for _, bad := range []string{"nexus:///repository/x", "nexus://host/x/y", "nexus://host/repository/"} {
+		_, err := NewNexus(bad)
+		require.Error(t, err, bad)
+	}
+}
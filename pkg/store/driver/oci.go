@@ -17,22 +17,31 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
+	"sigs.k8s.io/tejolote/pkg/ociauth"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
 
+// OCI reads the tags of an image repository. The spec URL can restrict
+// the tags to snapshot with a comma separated list of glob patterns:
+//
+//	oci://ghcr.io/org/repo?tags=v*,latest
 type OCI struct {
 	Repository string
 	Image      string
+	Tags       []string
 }
 
 func NewOCI(specURL string) (*OCI, error) {
@@ -44,30 +53,104 @@ func NewOCI(specURL string) (*OCI, error) {
 		return nil, errors.New("spec url is not wel formed")
 	}
 	oci := &OCI{}
+	if tags := u.Query().Get("tags"); tags != "" {
+		for _, pattern := range strings.Split(tags, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+			}
+			oci.Tags = append(oci.Tags, pattern)
+		}
+	}
 	parts := strings.Split(u.Path, "/")
 	oci.Image = parts[len(parts)-1]
-	oci.Repository = u.Hostname()
+	oci.Repository = u.Host
 	if len(parts) > 1 {
 		oci.Repository += strings.Join(parts[0:len(parts)-1], "/")
 	}
 	return oci, nil
 }
 
-// Snap
-func (oci *OCI) Snap() (*snapshot.Snapshot, error) {
+// Snap records the digest of each tag in the repository. When a tag
+// points to an image index, the manifests of each platform are added
+// as separate artifacts annotated with their platform.
+func (oci *OCI) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	repo := oci.Repository + "/" + oci.Image
 	tags, err := crane.ListTags(
-		oci.Repository+"/"+oci.Image, crane.WithAuthFromKeychain(authn.DefaultKeychain),
+		repo, crane.WithAuthFromKeychain(ociauth.Keychain()), crane.WithContext(ctx),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("fetching tags from registry: %w", err)
 	}
 	snap := &snapshot.Snapshot{}
 	for _, t := range tags {
+		if !oci.matchTag(t) {
+			continue
+		}
+		ref, err := name.ParseReference(repo + ":" + t)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reference: %w", err)
+		}
+		desc, err := remote.Get(
+			ref, remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s descriptor: %w", ref, err)
+		}
+		tagPath := "oci://" + repo + ":" + t
 		(*snap)["oci://"+t] = run.Artifact{
-			Path:     "oci://" + oci.Repository + "/" + oci.Image + ":" + t,
-			Checksum: map[string]string{},
+			Path:     tagPath,
+			Checksum: map[string]string{desc.Digest.Algorithm: desc.Digest.Hex},
 			Time:     time.Time{},
 		}
+		if !desc.MediaType.IsIndex() {
+			continue
+		}
+
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("reading image index of %s: %w", ref, err)
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("parsing index manifest of %s: %w", ref, err)
+		}
+		for _, m := range manifest.Manifests {
+			a := run.Artifact{
+				Path:     "oci://" + repo + "@" + m.Digest.String(),
+				Checksum: map[string]string{m.Digest.Algorithm: m.Digest.Hex},
+				Time:     time.Time{},
+				Annotations: map[string]string{
+					"oci.index": desc.Digest.String(),
+				},
+			}
+			if m.Platform != nil {
+				a.Annotations["oci.platform"] = m.Platform.String()
+			}
+			(*snap)[a.Path] = a
+		}
 	}
 	return snap, nil
 }
+
+// matchTag returns true if the tag matches any of the tag patterns of
+// the spec URL. If there are no patterns, all tags match.
+func (oci *OCI) matchTag(tag string) bool {
+	if len(oci.Tags) == 0 {
+		return true
+	}
+	for _, pattern := range oci.Tags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities returns the features supported by the driver
+func (oci *OCI) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
@@ -17,8 +17,21 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,7 +41,85 @@ func TestOCISnapshot(t *testing.T) {
 	require.Equal(t, "miniprow", oci.Image)
 	require.Equal(t, "ghcr.io/uservers/miniprow", oci.Repository)
 
-	snap, err := oci.Snap()
+	snap, err := oci.Snap(context.Background())
 	require.NoError(t, err)
 	require.Len(t, *snap, 5)
 }
+
+func TestOCISnapshotIndex(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	repo := strings.TrimPrefix(srv.URL, "http://") + "/test/image"
+
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, repo+":single"))
+
+	platforms := []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64", Variant: "v8"}}
+	var index v1.ImageIndex = empty.Index
+	for i := range platforms {
+		img, err := random.Image(1024, 1)
+		require.NoError(t, err)
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img, Descriptor: v1.Descriptor{Platform: &platforms[i]},
+		})
+	}
+	ref, err := name.ParseReference(repo + ":multi")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+	indexDigest, err := index.Digest()
+	require.NoError(t, err)
+
+	oci, err := NewOCI("oci://" + repo)
+	require.NoError(t, err)
+	snap, err := oci.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 4)
+
+	require.Equal(t, indexDigest.Hex, (*snap)["oci://multi"].Checksum["sha256"])
+	require.NotEmpty(t, (*snap)["oci://single"].Checksum["sha256"])
+
+	seen := []string{}
+	for _, a := range *snap {
+		if !strings.Contains(a.Path, "@sha256:") {
+			continue
+		}
+		require.Equal(t, indexDigest.String(), a.Annotations["oci.index"])
+		require.Equal(t, "oci://"+repo+"@sha256:"+a.Checksum["sha256"], a.Path)
+		seen = append(seen, a.Annotations["oci.platform"])
+	}
+	require.ElementsMatch(t, []string{"linux/amd64", "linux/arm64/v8"}, seen)
+
+	// Restrict the snapshot to some tags
+	oci, err = NewOCI("oci://" + repo + "?tags=sing*,v1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"sing*", "v1"}, oci.Tags)
+	require.Equal(t, "image", oci.Image)
+	snap, err = oci.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 1)
+	require.Contains(t, *snap, "oci://single")
+
+	_, err = NewOCI("oci://" + repo + "?tags=[v")
+	require.Error(t, err)
+}
+
+func FuzzNewOCI(f *testing.F) {
+	for _, seed := range []string{
+		"oci://ghcr.io/uservers/miniprow/miniprow",
+		"oci://localhost:5000/image",
+		"oci://registry",
+		"oci:///",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		oci, err := NewOCI(spec)
+		if err != nil {
+			return
+		}
+		if oci == nil {
+			t.Errorf("no driver and no error parsing %q", spec)
+		}
+	})
+}
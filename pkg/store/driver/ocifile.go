@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+	"sigs.k8s.io/tejolote/pkg/progress"
+)
+
+// Media types of the OCI artifacts used to store single files
+const (
+	OCIFileMediaType       types.MediaType = "application/vnd.tejolote.file.v1"
+	OCIFileConfigMediaType types.MediaType = "application/vnd.tejolote.file.config.v1+json"
+)
+
+// ociFileReference parses the reference of an OCI file URL
+// (oci://registry/repository:tag)
+func ociFileReference(fileURL string) (name.Reference, error) {
+	ref, err := name.ParseReference(strings.TrimPrefix(fileURL, "oci://"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCI reference: %w", err)
+	}
+	return ref, nil
+}
+
+// uploadOCIFile pushes the data read from r as a single layer OCI
+// artifact tagged with the reference in the URL
+func uploadOCIFile(ctx context.Context, fileURL string, r io.Reader) error {
+	ref, err := ociFileReference(fileURL)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading upload data: %w", err)
+	}
+	img, err := mutate.Append(
+		mutate.MediaType(empty.Image, types.OCIManifestSchema1),
+		mutate.Addendum{
+			Layer: static.NewLayer(data, OCIFileMediaType),
+			Annotations: map[string]string{
+				"org.opencontainers.image.title": path.Base(ref.Context().RepositoryStr()),
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("building OCI artifact: %w", err)
+	}
+	img = mutate.ConfigMediaType(img, OCIFileConfigMediaType)
+	if err := remote.Write(
+		ref, img, remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx),
+	); err != nil {
+		return fmt.Errorf("pushing OCI artifact: %w", err)
+	}
+	return nil
+}
+
+// downloadOCIFile copies the file stored in an OCI artifact written by
+// uploadOCIFile to w
+func downloadOCIFile(ctx context.Context, fileURL string, w io.Writer) error {
+	ref, err := ociFileReference(fileURL)
+	if err != nil {
+		return err
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("fetching OCI artifact: %w", err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("reading OCI artifact layers: %w", err)
+	}
+	if len(layers) != 1 {
+		return fmt.Errorf("OCI artifact %s has %d layers, expected a single file", ref, len(layers))
+	}
+	mt, err := layers[0].MediaType()
+	if err != nil {
+		return fmt.Errorf("reading layer media type: %w", err)
+	}
+	if mt != OCIFileMediaType {
+		return fmt.Errorf("OCI artifact %s does not hold a file (media type %s)", ref, mt)
+	}
+	// The file is stored as is, the compressed blob is the file
+	rc, err := layers[0].Compressed()
+	if err != nil {
+		return fmt.Errorf("reading OCI artifact layer: %w", err)
+	}
+	defer rc.Close()
+	if _, err := io.Copy(progress.Writer(w), rc); err != nil {
+		return fmt.Errorf("reading OCI artifact data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// Annotations recording the Prow job metadata in the subjects read
+// from a prow-artifacts store
+const (
+	AnnotationProwJob      = "prow.job"
+	AnnotationProwBuild    = "prow.build"
+	AnnotationProwResult   = "prow.result"
+	AnnotationProwRevision = "prow.revision"
+)
+
+// Prow reads the artifacts a Prow job uploaded to its GCS bucket. Prow
+// writes each run of a job to its own directory holding started.json,
+// finished.json and the artifacts/ directory. Only the artifacts are
+// recorded, and the job metadata is used to skip objects older than the
+// job start and to annotate the artifacts with the job run data.
+//
+// The spec URL points to the run directory in the bucket. When only
+// the job name and build ID are given, the periodic and postsubmit
+// layout (logs/job/build) is assumed:
+//
+//	prow-artifacts://kubernetes-jenkins/ci-kubernetes-build/1785224352541446144
+//	prow-artifacts://kubernetes-jenkins/pr-logs/pull/org_repo/123/pull-job/1785224352541446144
+type Prow struct {
+	Bucket string
+	Path   string
+	Job    string
+	Build  string
+	gcs    *GCS
+}
+
+// prowStarted is the subset of started.json read by the driver
+type prowStarted struct {
+	Timestamp  int64  `json:"timestamp"`
+	RepoCommit string `json:"repo-commit"`
+}
+
+// prowFinished is the subset of finished.json read by the driver
+type prowFinished struct {
+	Timestamp int64  `json:"timestamp"`
+	Passed    *bool  `json:"passed"`
+	Result    string `json:"result"`
+	Revision  string `json:"revision"`
+}
+
+func NewProw(specURL string) (*Prow, error) {
+	p, err := parseProwURL(specURL)
+	if err != nil {
+		return nil, err
+	}
+	p.gcs, err = NewGCS(fmt.Sprintf("gs://%s/%s/artifacts/", p.Bucket, p.Path))
+	if err != nil {
+		return nil, fmt.Errorf("creating gcs driver: %w", err)
+	}
+	logrus.Infof("Initialized new Prow artifacts storage backend (%s)", specURL)
+	return p, nil
+}
+
+// parseProwURL reads the bucket and run directory from the spec URL
+func parseProwURL(specURL string) (*Prow, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing prow spec url: %w", err)
+	}
+	if u.Scheme != "prow-artifacts" {
+		return nil, fmt.Errorf("spec URL %s is not a prow-artifacts url", specURL)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Hostname() == "" || len(parts) < 2 || parts[len(parts)-2] == "" {
+		return nil, fmt.Errorf("prow spec url must have the form prow-artifacts://bucket/job/build")
+	}
+	p := &Prow{
+		Bucket: u.Hostname(),
+		Path:   strings.Join(parts, "/"),
+		Job:    parts[len(parts)-2],
+		Build:  parts[len(parts)-1],
+	}
+	if len(parts) == 2 {
+		p.Path = "logs/" + p.Path
+	}
+	return p, nil
+}
+
+// readMetadata decodes one of the job metadata files of the run. It
+// returns false when the file has not been uploaded yet.
+func (p *Prow) readMetadata(ctx context.Context, name string, v interface{}) (bool, error) {
+	var b bytes.Buffer
+	objectURL := fmt.Sprintf("gs://%s/%s/%s", p.Bucket, p.Path, name)
+	if err := downloadGCSObject(ctx, p.gcs.client, objectURL, &b); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	if err := json.Unmarshal(b.Bytes(), v); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// Snap takes a snapshot of the artifacts of the job run
+func (p *Prow) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	started := &prowStarted{}
+	hasStarted, err := p.readMetadata(ctx, "started.json", started)
+	if err != nil {
+		return nil, err
+	}
+	finished := &prowFinished{}
+	hasFinished, err := p.readMetadata(ctx, "finished.json", finished)
+	if err != nil {
+		return nil, err
+	}
+	if !hasStarted {
+		started = nil
+	}
+	if !hasFinished {
+		finished = nil
+	}
+
+	snap, err := p.gcs.Snap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("snapshotting job artifacts: %w", err)
+	}
+	return p.filter(snap, started, finished), nil
+}
+
+// filter drops the artifacts updated before the job started and
+// annotates the rest with the job metadata. Objects older than the
+// job cannot be an output of it, they are left over from a previous
+// run writing to the same path.
+func (p *Prow) filter(snap *snapshot.Snapshot, started *prowStarted, finished *prowFinished) *snapshot.Snapshot {
+	annotations := map[string]string{
+		AnnotationProwJob:   p.Job,
+		AnnotationProwBuild: p.Build,
+	}
+	var startTime time.Time
+	if started != nil {
+		startTime = time.Unix(started.Timestamp, 0)
+		if started.RepoCommit != "" {
+			annotations[AnnotationProwRevision] = started.RepoCommit
+		}
+	}
+	if finished != nil {
+		if finished.Revision != "" {
+			annotations[AnnotationProwRevision] = finished.Revision
+		}
+		switch {
+		case finished.Result != "":
+			annotations[AnnotationProwResult] = finished.Result
+		case finished.Passed != nil:
+			annotations[AnnotationProwResult] = "FAILURE"
+			if *finished.Passed {
+				annotations[AnnotationProwResult] = "SUCCESS"
+			}
+		}
+	} else if started != nil {
+		logrus.Warnf("Prow job %s/%s has not finished, its artifacts may be incomplete", p.Job, p.Build)
+	}
+
+	filtered := snapshot.Snapshot{}
+	for path, a := range *snap {
+		if !startTime.IsZero() && a.Time.Before(startTime) {
+			logrus.Debugf("Skipping %s, updated before the job started", path)
+			continue
+		}
+		if a.Annotations == nil {
+			a.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			a.Annotations[k] = v
+		}
+		filtered[path] = a
+	}
+	if skipped := len(*snap) - len(filtered); skipped > 0 {
+		logrus.Infof(
+			"Skipped %d artifacts of %s/%s updated before the job started at %s",
+			skipped, p.Job, p.Build, startTime.UTC().Format(time.RFC3339),
+		)
+	}
+	return &filtered
+}
+
+// LocalPath returns the path to the artifact in the local mirror
+func (p *Prow) LocalPath(path string) (string, error) {
+	return p.gcs.LocalPath(path)
+}
+
+// Capabilities returns the features supported by the driver
+func (p *Prow) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   false,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
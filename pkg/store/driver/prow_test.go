@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+func TestParseProwURL(t *testing.T) {
+	for _, tc := range []struct {
+		url         string
+		path        string
+		job, build  string
+		shouldError bool
+	}{
+		{
+			url:  "prow-artifacts://kubernetes-jenkins/ci-kubernetes-build/1785224352541446144",
+			path: "logs/ci-kubernetes-build/1785224352541446144",
+			job:  "ci-kubernetes-build", build: "1785224352541446144",
+		},
+		{
+			url:  "prow-artifacts://kubernetes-jenkins/pr-logs/pull/org_repo/123/pull-job/42/",
+			path: "pr-logs/pull/org_repo/123/pull-job/42",
+			job:  "pull-job", build: "42",
+		},
+		{url: "prow-artifacts://kubernetes-jenkins/ci-kubernetes-build", shouldError: true},
+		{url: "gs://kubernetes-jenkins/logs/job/42", shouldError: true},
+	} {
+		p, err := parseProwURL(tc.url)
+		if tc.shouldError {
+			require.Error(t, err, tc.url)
+			continue
+		}
+		require.NoError(t, err, tc.url)
+		require.Equal(t, "kubernetes-jenkins", p.Bucket)
+		require.Equal(t, tc.path, p.Path)
+		require.Equal(t, tc.job, p.Job)
+		require.Equal(t, tc.build, p.Build)
+	}
+}
+
+func TestProwFilter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	p := &Prow{Job: "ci-job", Build: "42"}
+	snap := func() *snapshot.Snapshot {
+		return &snapshot.Snapshot{
+			"gs://b/old": run.Artifact{Path: "gs://b/old", Time: start.Add(-time.Hour)},
+			"gs://b/new": run.Artifact{Path: "gs://b/new", Time: start.Add(time.Minute)},
+		}
+	}
+
+	// Objects from before the job started are skipped
+	passed := false
+	filtered := p.filter(snap(),
+		&prowStarted{Timestamp: start.Unix(), RepoCommit: "abc"},
+		&prowFinished{Passed: &passed, Revision: "def"},
+	)
+	require.Len(t, *filtered, 1)
+	a := (*filtered)["gs://b/new"]
+	require.Equal(t, map[string]string{
+		AnnotationProwJob:      "ci-job",
+		AnnotationProwBuild:    "42",
+		AnnotationProwRevision: "def",
+		AnnotationProwResult:   "FAILURE",
+	}, a.Annotations)
+
+	// Without started.json there is no window to apply
+	filtered = p.filter(snap(), nil, nil)
+	require.Len(t, *filtered, 2)
+	require.NotContains(t, (*filtered)["gs://b/old"].Annotations, AnnotationProwResult)
+}
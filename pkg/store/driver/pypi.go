@@ -0,0 +1,255 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// pypiAPIURL is the base URL of the PyPI JSON API
+var pypiAPIURL = "https://pypi.org/pypi"
+
+// pypiSimpleJSON is the content type of the PEP 691 simple repository API
+const pypiSimpleJSON = "application/vnd.pypi.simple.v1+json"
+
+// PyPI reads the files published for a Python project. By default the
+// files are read from the pypi.org JSON API. Projects in private indexes
+// (devpi, Artifactory, etc) are read from the JSON flavor of the simple
+// repository API (PEP 691) when the index root is passed in the spec URL.
+// Files can be restricted to a single release with the version option:
+//
+//	pypi://project?version=1.2.0
+//	pypi://project?index=https://devpi.example.com/root/prod/+simple/
+//
+// Credentials for private indexes are read from the index URL.
+type PyPI struct {
+	Project string
+	Version string
+	Index   string
+}
+
+func NewPyPI(specURL string) (*PyPI, error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pypi spec url: %w", err)
+	}
+	if u.Scheme != "pypi" {
+		return nil, errors.New("spec url is not a pypi url")
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") != "" {
+		return nil, errors.New("pypi spec url must be pypi://project")
+	}
+	// Index URLs often contain a literal plus sign (devpi's +simple)
+	// so it is not decoded as a space
+	query, err := url.ParseQuery(strings.ReplaceAll(u.RawQuery, "+", "%2B"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing pypi spec url options: %w", err)
+	}
+	p := &PyPI{
+		Project: u.Host,
+		Version: query.Get("version"),
+		Index:   query.Get("index"),
+	}
+	if p.Index != "" {
+		if _, err := url.Parse(p.Index); err != nil {
+			return nil, fmt.Errorf("parsing index url: %w", err)
+		}
+	}
+	logrus.Infof("Initialized new PyPI storage backend (%s)", specURL)
+	return p, nil
+}
+
+// pypiFile is a distribution file as returned by the PyPI JSON API
+type pypiFile struct {
+	Filename   string            `json:"filename"`
+	URL        string            `json:"url"`
+	Digests    map[string]string `json:"digests"`
+	UploadTime string            `json:"upload_time_iso_8601"`
+}
+
+type pypiProject struct {
+	Releases map[string][]pypiFile `json:"releases"`
+	URLs     []pypiFile            `json:"urls"`
+}
+
+// pypiSimpleFile is a file entry in the PEP 691 project page
+type pypiSimpleFile struct {
+	Filename   string            `json:"filename"`
+	URL        string            `json:"url"`
+	Hashes     map[string]string `json:"hashes"`
+	UploadTime string            `json:"upload-time"`
+}
+
+type pypiSimpleProject struct {
+	Files []pypiSimpleFile `json:"files"`
+}
+
+// Snap records the files published for the project
+func (p *PyPI) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	var files []pypiFile
+	var err error
+	if p.Index != "" {
+		files, err = p.readSimpleIndex(ctx)
+	} else {
+		files, err = p.readJSONAPI(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := snapshot.Snapshot{}
+	for _, f := range files {
+		if p.Version != "" && distributionVersion(f.Filename) != p.Version {
+			continue
+		}
+		a := run.Artifact{
+			Path:     f.URL,
+			Checksum: map[string]string{},
+		}
+		if sha, ok := f.Digests["sha256"]; ok {
+			a.Checksum["SHA256"] = sha
+		}
+		if f.UploadTime != "" {
+			t, err := time.Parse(time.RFC3339, f.UploadTime)
+			if err != nil {
+				return nil, fmt.Errorf("parsing upload time of %s: %w", f.Filename, err)
+			}
+			a.Time = t
+		}
+		snap[f.Filename] = a
+	}
+	return &snap, nil
+}
+
+// readJSONAPI lists the project files from the PyPI JSON API
+func (p *PyPI) readJSONAPI(ctx context.Context) ([]pypiFile, error) {
+	apiURL := fmt.Sprintf("%s/%s/json", pypiAPIURL, p.Project)
+	if p.Version != "" {
+		apiURL = fmt.Sprintf("%s/%s/%s/json", pypiAPIURL, p.Project, p.Version)
+	}
+	project := &pypiProject{}
+	if err := getPyPIJSON(ctx, apiURL, "application/json", project); err != nil {
+		return nil, fmt.Errorf("reading project %s: %w", p.Project, err)
+	}
+	if p.Version != "" {
+		return project.URLs, nil
+	}
+	files := []pypiFile{}
+	for _, release := range project.Releases {
+		files = append(files, release...)
+	}
+	return files, nil
+}
+
+// readSimpleIndex lists the project files from a PEP 691 index
+func (p *PyPI) readSimpleIndex(ctx context.Context) ([]pypiFile, error) {
+	base, err := url.Parse(strings.TrimSuffix(p.Index, "/") + "/" + normalizeProjectName(p.Project) + "/")
+	if err != nil {
+		return nil, fmt.Errorf("building project url: %w", err)
+	}
+	project := &pypiSimpleProject{}
+	if err := getPyPIJSON(ctx, base.String(), pypiSimpleJSON, project); err != nil {
+		return nil, fmt.Errorf("reading project %s from index: %w", p.Project, err)
+	}
+	files := []pypiFile{}
+	for _, f := range project.Files {
+		// File URLs may be relative to the project page
+		fileURL, err := base.Parse(f.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing url of %s: %w", f.Filename, err)
+		}
+		fileURL.User = nil
+		fileURL.Fragment = ""
+		files = append(files, pypiFile{
+			Filename:   f.Filename,
+			URL:        fileURL.String(),
+			Digests:    f.Hashes,
+			UploadTime: f.UploadTime,
+		})
+	}
+	return files, nil
+}
+
+func getPyPIJSON(ctx context.Context, apiURL, accept string, data interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("creating http request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := readonly.NewClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("querying index: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http error from index: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return fmt.Errorf("decoding index response: %w", err)
+	}
+	return nil
+}
+
+var projectNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizeProjectName returns the normalized form of a project name
+// as defined in PEP 503
+func normalizeProjectName(name string) string {
+	return strings.ToLower(projectNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// distributionVersion extracts the version from the filename of a wheel
+// (name-version-tags.whl) or a source distribution (name-version.ext)
+func distributionVersion(filename string) string {
+	if strings.HasSuffix(filename, ".whl") {
+		parts := strings.Split(filename, "-")
+		if len(parts) < 3 {
+			return ""
+		}
+		return parts[1]
+	}
+	for _, ext := range []string{".tar.gz", ".tar.bz2", ".zip", ".tgz"} {
+		if strings.HasSuffix(filename, ext) {
+			base := strings.TrimSuffix(filename, ext)
+			return base[strings.LastIndex(base, "-")+1:]
+		}
+	}
+	return ""
+}
+
+// Capabilities returns the features supported by the driver
+func (p *PyPI) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: true,
+		Streaming:         false,
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPyPISnap(t *testing.T) {
+	wheel := pypiFile{
+		Filename:   "my_project-1.0.0-py3-none-any.whl",
+		URL:        "https://files.example.com/my_project-1.0.0-py3-none-any.whl",
+		Digests:    map[string]string{"sha256": "aa", "md5": "bb"},
+		UploadTime: "2024-01-01T10:00:00.000000Z",
+	}
+	sdist := pypiFile{
+		Filename: "my-project-1.0.0.tar.gz",
+		URL:      "https://files.example.com/my-project-1.0.0.tar.gz",
+		Digests:  map[string]string{"sha256": "cc"},
+	}
+	old := pypiFile{
+		Filename: "my-project-0.9.tar.gz",
+		URL:      "https://files.example.com/my-project-0.9.tar.gz",
+		Digests:  map[string]string{"sha256": "dd"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/My.Project/json":
+			json.NewEncoder(w).Encode(pypiProject{ //nolint: errcheck
+				Releases: map[string][]pypiFile{"1.0.0": {wheel, sdist}, "0.9": {old}},
+			})
+		case "/pypi/My.Project/1.0.0/json":
+			json.NewEncoder(w).Encode(pypiProject{URLs: []pypiFile{wheel, sdist}}) //nolint: errcheck
+		case "/root/prod/+simple/my-project/":
+			require.Equal(t, pypiSimpleJSON, r.Header.Get("Accept"))
+			json.NewEncoder(w).Encode(pypiSimpleProject{Files: []pypiSimpleFile{ //nolint: errcheck
+				{Filename: wheel.Filename, URL: "../../+f/aa/" + wheel.Filename + "#sha256=aa", Hashes: wheel.Digests},
+				{Filename: old.Filename, URL: "../../+f/dd/" + old.Filename, Hashes: old.Digests},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer func(u string) { pypiAPIURL = u }(pypiAPIURL)
+	pypiAPIURL = srv.URL + "/pypi"
+
+	for _, tc := range []struct {
+		spec     string
+		expected map[string]string
+	}{
+		{"pypi://My.Project", map[string]string{wheel.Filename: wheel.URL, sdist.Filename: sdist.URL, old.Filename: old.URL}},
+		{"pypi://My.Project?version=1.0.0", map[string]string{wheel.Filename: wheel.URL, sdist.Filename: sdist.URL}},
+		{
+			"pypi://My.Project?version=1.0.0&index=" + srv.URL + "/root/prod/+simple/",
+			map[string]string{wheel.Filename: srv.URL + "/root/prod/+f/aa/" + wheel.Filename},
+		},
+	} {
+		p, err := NewPyPI(tc.spec)
+		require.NoError(t, err)
+		snap, err := p.Snap(context.Background())
+		require.NoError(t, err, tc.spec)
+		require.Len(t, *snap, len(tc.expected), tc.spec)
+		for filename, u := range tc.expected {
+			require.Equal(t, u, (*snap)[filename].Path, tc.spec)
+		}
+	}
+
+	p, err := NewPyPI("pypi://My.Project?version=1.0.0")
+	require.NoError(t, err)
+	snap, err := p.Snap(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"SHA256": "aa"}, (*snap)[wheel.Filename].Checksum)
+	require.Equal(t, 2024, (*snap)[wheel.Filename].Time.Year())
+
+	_, err = NewPyPI("pypi://project/extra")
+	require.Error(t, err)
+}
+
+func TestDistributionVersion(t *testing.T) {
+	for filename, version := range map[string]string{
+		"my_project-1.0.0-py3-none-any.whl":                  "1.0.0",
+		"my-project-1.0.0.tar.gz":                            "1.0.0",
+		"pkg-2.1rc1.zip":                                     "2.1rc1",
+		"numpy-1.26.0-cp312-cp312-manylinux_2_17_x86_64.whl": "1.26.0",
+		"README.txt":                                         "",
+	} {
+		require.Equal(t, version, distributionVersion(filename), filename)
+	}
+}
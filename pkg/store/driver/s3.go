@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"sigs.k8s.io/tejolote/pkg/progress"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+)
+
+// s3DefaultRegion is used when the AWS configuration sets no region
+const s3DefaultRegion = "us-east-1"
+
+// readOnlyHTTPClient refuses writes in read-only mode before they
+// reach the http client of the AWS configuration
+type readOnlyHTTPClient struct {
+	client s3.HTTPClient
+}
+
+func (c readOnlyHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if err := readonly.CheckRequest(req); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+// newS3Client returns an S3 client configured from the standard AWS
+// configuration chain. Its http client refuses writes in read-only
+// mode. S3 compatible services set in AWS_ENDPOINT_URL_S3 or
+// AWS_ENDPOINT_URL are addressed with path-style URLs.
+func newS3Client(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = s3DefaultRegion
+	}
+	pathStyle := os.Getenv("AWS_ENDPOINT_URL_S3") != "" || cfg.BaseEndpoint != nil
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.HTTPClient = readOnlyHTTPClient{client: o.HTTPClient}
+		o.UsePathStyle = pathStyle
+	}), nil
+}
+
+// parseS3URL returns the bucket and key of an S3 object URL
+func parseS3URL(objectURL string) (bucket, key string, err error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing S3 url: %w", err)
+	}
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("%s is not an S3 object URL (s3://bucket/key)", objectURL)
+	}
+	return u.Host, key, nil
+}
+
+// uploadS3Object streams the data read from r to an S3 object. Large
+// uploads are sent in parts.
+func uploadS3Object(ctx context.Context, objectURL string, r io.Reader) error {
+	bucket, key, err := parseS3URL(objectURL)
+	if err != nil {
+		return err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("creating S3 client: %w", err)
+	}
+	if _, err := manager.NewUploader(client).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	}); err != nil {
+		return fmt.Errorf("uploading object: %w", err)
+	}
+	return nil
+}
+
+// downloadS3Object copies the contents of an S3 object to w
+func downloadS3Object(ctx context.Context, objectURL string, w io.Writer) error {
+	bucket, key, err := parseS3URL(objectURL)
+	if err != nil {
+		return err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("creating S3 client: %w", err)
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("downloading object: %w", err)
+	}
+	defer out.Body.Close()
+	if _, err := io.Copy(progress.Writer(w), out.Body); err != nil {
+		return fmt.Errorf("reading object data: %w", err)
+	}
+	return nil
+}
+
+// existsS3Object checks if an S3 object exists
+func existsS3Object(ctx context.Context, objectURL string) (bool, error) {
+	bucket, key, err := parseS3URL(objectURL)
+	if err != nil {
+		return false, err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return false, fmt.Errorf("creating S3 client: %w", err)
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true, nil
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return false, fmt.Errorf("checking object: %w", err)
+}
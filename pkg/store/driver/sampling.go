@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// Annotations recording the sampling policy in the subjects of a
+// sampled store
+const (
+	AnnotationSamplingRate     = "sampling.rate"
+	AnnotationSamplingCritical = "sampling.critical"
+	AnnotationSamplingSeed     = "sampling.seed"
+	AnnotationSamplingSelected = "sampling.selected"
+)
+
+// Reasons a file was selected by the sampling policy
+const (
+	SampledCritical = "critical"
+	SampledRandom   = "sample"
+)
+
+// samplingScale is the resolution of the sampling rate
+const samplingScale = 1_000_000
+
+// Sampling is a policy to hash only part of a store with a huge number of
+// files. Files matching one of the critical glob patterns are always
+// hashed, the rest are selected with probability Rate. The selection is
+// derived from the file path and the seed, so snapshots taken before and
+// after the build pick the same files. Files left out are not recorded.
+type Sampling struct {
+	Rate     float64
+	Critical []string
+	Seed     string
+}
+
+// parseSampling reads the sampling policy from the spec URL query:
+//
+//	file:///docs?sample=0.01&critical=*.tar.gz,index.html&seed=nightly
+//
+// It returns nil when the URL does not set a sampling rate.
+func parseSampling(q url.Values) (*Sampling, error) {
+	rate := q.Get("sample")
+	if rate == "" {
+		return nil, nil
+	}
+	s := &Sampling{Seed: q.Get("seed")}
+	var err error
+	s.Rate, err = strconv.ParseFloat(rate, 64)
+	if err != nil || s.Rate < 0 || s.Rate > 1 {
+		return nil, fmt.Errorf("invalid sampling rate %q, it must be between 0 and 1", rate)
+	}
+	if critical := q.Get("critical"); critical != "" {
+		for _, pattern := range strings.Split(critical, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid critical pattern %q: %w", pattern, err)
+			}
+			s.Critical = append(s.Critical, pattern)
+		}
+	}
+	return s, nil
+}
+
+// selected returns true and the reason if the file at the relative
+// path p is part of the sample. Critical patterns match the full path
+// or the file name.
+func (s *Sampling) selected(p string) (string, bool) {
+	for _, pattern := range s.Critical {
+		if ok, _ := path.Match(pattern, p); ok { //nolint: errcheck // validated when parsed
+			return SampledCritical, true
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok { //nolint: errcheck
+			return SampledCritical, true
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s.Seed + "\x00" + p)) //nolint: errcheck
+	if float64(h.Sum64()%samplingScale) < s.Rate*samplingScale {
+		return SampledRandom, true
+	}
+	return "", false
+}
+
+// annotate records the sampling policy in the annotations of an artifact
+func (s *Sampling) annotate(a *run.Artifact, reason string) {
+	if a.Annotations == nil {
+		a.Annotations = map[string]string{}
+	}
+	a.Annotations[AnnotationSamplingRate] = strconv.FormatFloat(s.Rate, 'g', -1, 64)
+	a.Annotations[AnnotationSamplingSelected] = reason
+	if len(s.Critical) > 0 {
+		a.Annotations[AnnotationSamplingCritical] = strings.Join(s.Critical, ",")
+	}
+	if s.Seed != "" {
+		a.Annotations[AnnotationSamplingSeed] = s.Seed
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSampling(t *testing.T) {
+	for _, tc := range []struct {
+		query   string
+		expect  *Sampling
+		invalid bool
+	}{
+		{"", nil, false},
+		{"critical=*.tar.gz", nil, false},
+		{"sample=0.05", &Sampling{Rate: 0.05}, false},
+		{
+			"sample=0&critical=*.tar.gz,docs/index.html&seed=nightly",
+			&Sampling{Critical: []string{"*.tar.gz", "docs/index.html"}, Seed: "nightly"},
+			false,
+		},
+		{"sample=1.5", nil, true},
+		{"sample=lots", nil, true},
+		{"sample=0.1&critical=[", nil, true},
+	} {
+		q, err := url.ParseQuery(tc.query)
+		require.NoError(t, err)
+		s, err := parseSampling(q)
+		if tc.invalid {
+			require.Error(t, err, tc.query)
+			continue
+		}
+		require.NoError(t, err, tc.query)
+		require.Equal(t, tc.expect, s, tc.query)
+	}
+}
+
+func TestSamplingSelected(t *testing.T) {
+	s := &Sampling{Rate: 0.1, Critical: []string{"*.tar.gz", "docs/index.html"}, Seed: "nightly"}
+
+	reason, ok := s.selected("release/app.tar.gz")
+	require.True(t, ok)
+	require.Equal(t, SampledCritical, reason)
+	_, ok = s.selected("docs/index.html")
+	require.True(t, ok)
+
+	// Selection is deterministic and close to the rate
+	selected := 0
+	for i := 0; i < 10000; i++ {
+		p := fmt.Sprintf("docs/page%05d.html", i)
+		reason, ok := s.selected(p)
+		again, _ := s.selected(p)
+		require.Equal(t, reason, again)
+		if ok {
+			require.Equal(t, SampledRandom, reason)
+			selected++
+		}
+	}
+	require.InDelta(t, 1000, selected, 150)
+
+	// Only critical files with a zero rate
+	s.Rate = 0
+	_, ok = s.selected("docs/page00001.html")
+	require.False(t, ok)
+}
+
+func TestDirectorySampling(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("page%02d.html", i)), []byte("page"), os.FileMode(0o644)))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "site.tar.gz"), []byte("archive"), os.FileMode(0o644)))
+
+	d, err := NewDirectory("file://" + dir + "?sample=0&critical=*.tar.gz")
+	require.NoError(t, err)
+	require.False(t, d.Capabilities().DeletionDetection)
+	snap, err := d.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 1)
+	a := (*snap)["site.tar.gz"]
+	require.NotEmpty(t, a.Checksum["SHA256"])
+	require.Equal(t, map[string]string{
+		AnnotationSamplingRate:     "0",
+		AnnotationSamplingCritical: "*.tar.gz",
+		AnnotationSamplingSelected: SampledCritical,
+	}, a.Annotations)
+
+	// Everything is hashed with a full sample
+	d, err = NewDirectory("file://" + dir + "?sample=1")
+	require.NoError(t, err)
+	snap, err = d.Snap(context.Background())
+	require.NoError(t, err)
+	require.Len(t, *snap, 51)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
@@ -28,6 +29,7 @@ import (
 
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+	"sigs.k8s.io/tejolote/pkg/workdir"
 )
 
 type SPDX struct {
@@ -53,14 +55,14 @@ func NewSPDX(specURL string) (*SPDX, error) {
 	}, nil
 }
 
-func (s *SPDX) Snap() (*snapshot.Snapshot, error) {
-	f, err := os.CreateTemp("", "temp-sbom-")
+func (s *SPDX) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	f, err := workdir.CreateTemp("temp-sbom-")
 	if err != nil {
 		return nil, fmt.Errorf("creating temporary sbom file: %w", err)
 	}
 	defer os.Remove(f.Name())
 
-	if err := downloadURL(s.URL, f); err != nil {
+	if err := DownloadURL(ctx, s.URL, f); err != nil {
 		return nil, fmt.Errorf("downloading sbom to temp file: %w", err)
 	}
 
@@ -113,3 +115,12 @@ func (s *SPDX) Snap() (*snapshot.Snapshot, error) {
 	}
 	return &snap, nil
 }
+
+// Capabilities returns the features supported by the driver
+func (s *SPDX) Capabilities() Capabilities {
+	return Capabilities{
+		MetadataHashing:   true,
+		DeletionDetection: false,
+		Streaming:         false,
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	// Pure Go sqlite driver, registered as "sqlite"
+	_ "github.com/glebarez/go-sqlite"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// indexBatchSize is the number of artifacts inserted in each
+// transaction when building an index
+const indexBatchSize = 5000
+
+// Index is a snapshot stored in an on-disk sqlite database instead of
+// a map. Indexes are used to snapshot stores too large to list in memory:
+// artifacts are written as they are read from the store and the delta
+// between two indexes is computed by walking both in path order, so
+// memory use does not grow with the number of artifacts.
+type Index struct {
+	Path    string
+	db      *sql.DB
+	tx      *sql.Tx
+	insert  *sql.Stmt
+	pending int
+}
+
+// NewIndex creates an empty index at path, replacing any existing one
+func NewIndex(path string) (*Index, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("removing previous index: %w", err)
+	}
+	idx, err := openIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := idx.db.Exec(
+		`CREATE TABLE artifacts (path TEXT PRIMARY KEY, time INTEGER NOT NULL, checksum TEXT NOT NULL) WITHOUT ROWID`,
+	); err != nil {
+		idx.db.Close()
+		return nil, fmt.Errorf("creating index table: %w", err)
+	}
+	return idx, nil
+}
+
+// OpenIndex opens an index written before, eg by the start subcommand
+func OpenIndex(path string) (*Index, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("checking index file: %w", err)
+	}
+	return openIndex(path)
+}
+
+func openIndex(path string) (*Index, error) {
+	// The index can always be rebuilt from the store, so trade
+	// durability for write speed
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(off)")
+	q.Add("_pragma", "synchronous(off)")
+	db, err := sql.Open("sqlite", path+"?"+q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("opening index database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening index database: %w", err)
+	}
+	return &Index{Path: path, db: db}, nil
+}
+
+// Add records an artifact in the index. Writes are batched, Flush
+// commits the pending ones.
+func (idx *Index) Add(a run.Artifact) error {
+	if idx.tx == nil {
+		tx, err := idx.db.Begin()
+		if err != nil {
+			return fmt.Errorf("starting index transaction: %w", err)
+		}
+		stmt, err := tx.Prepare(`INSERT OR REPLACE INTO artifacts (path, time, checksum) VALUES (?, ?, ?)`)
+		if err != nil {
+			tx.Rollback() //nolint: errcheck
+			return fmt.Errorf("preparing index insert: %w", err)
+		}
+		idx.tx, idx.insert = tx, stmt
+	}
+	checksum, err := json.Marshal(a.Checksum)
+	if err != nil {
+		return fmt.Errorf("marshaling checksum of %s: %w", a.Path, err)
+	}
+	if _, err := idx.insert.Exec(a.Path, a.Time.UnixNano(), string(checksum)); err != nil {
+		return fmt.Errorf("indexing %s: %w", a.Path, err)
+	}
+	idx.pending++
+	if idx.pending >= indexBatchSize {
+		return idx.Flush()
+	}
+	return nil
+}
+
+// Flush commits the artifacts added since the last flush
+func (idx *Index) Flush() error {
+	if idx.tx == nil {
+		return nil
+	}
+	idx.insert.Close()
+	err := idx.tx.Commit()
+	idx.tx, idx.insert, idx.pending = nil, nil, 0
+	if err != nil {
+		return fmt.Errorf("committing index transaction: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of artifacts in the index
+func (idx *Index) Len() (int, error) {
+	if err := idx.Flush(); err != nil {
+		return 0, err
+	}
+	var n int
+	if err := idx.db.QueryRow(`SELECT COUNT(*) FROM artifacts`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting indexed artifacts: %w", err)
+	}
+	return n, nil
+}
+
+// Walk calls fn with every artifact in the index, sorted by path
+func (idx *Index) Walk(fn func(run.Artifact) error) error {
+	if err := idx.Flush(); err != nil {
+		return err
+	}
+	rows, err := idx.db.Query(`SELECT path, time, checksum FROM artifacts ORDER BY path`)
+	if err != nil {
+		return fmt.Errorf("querying index: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+	return nil
+}
+
+// Delta streams the artifacts created or modified in post, an index
+// assumed to be later in time. It applies the same rules as
+// Snapshot.Delta, calling fn for each changed artifact in path order.
+func (idx *Index) Delta(post *Index, fn func(run.Artifact) error) error {
+	if err := idx.Flush(); err != nil {
+		return err
+	}
+	if err := post.Flush(); err != nil {
+		return err
+	}
+	query := `SELECT path, time, checksum FROM artifacts ORDER BY path`
+	preRows, err := idx.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("querying index: %w", err)
+	}
+	defer preRows.Close()
+	postRows, err := post.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("querying index: %w", err)
+	}
+	defer postRows.Close()
+
+	// Merge both sorted listings
+	var pre *run.Artifact
+	nextPre := func() error {
+		pre = nil
+		if !preRows.Next() {
+			return preRows.Err()
+		}
+		a, err := scanArtifact(preRows)
+		if err != nil {
+			return err
+		}
+		pre = &a
+		return nil
+	}
+	if err := nextPre(); err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+	for postRows.Next() {
+		a, err := scanArtifact(postRows)
+		if err != nil {
+			return err
+		}
+		for pre != nil && pre.Path < a.Path {
+			if err := nextPre(); err != nil {
+				return fmt.Errorf("reading index: %w", err)
+			}
+		}
+		if pre != nil && pre.Path == a.Path && !changed(*pre, a) {
+			continue
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	if err := postRows.Err(); err != nil {
+		return fmt.Errorf("reading index: %w", err)
+	}
+	return nil
+}
+
+// Close commits any pending writes and closes the index database
+func (idx *Index) Close() error {
+	ferr := idx.Flush()
+	if err := idx.db.Close(); err != nil {
+		return fmt.Errorf("closing index: %w", err)
+	}
+	return ferr
+}
+
+func scanArtifact(rows *sql.Rows) (run.Artifact, error) {
+	var (
+		a        run.Artifact
+		nsec     int64
+		checksum string
+	)
+	if err := rows.Scan(&a.Path, &nsec, &checksum); err != nil {
+		return a, fmt.Errorf("scanning indexed artifact: %w", err)
+	}
+	a.Time = time.Unix(0, nsec)
+	if err := json.Unmarshal([]byte(checksum), &a.Checksum); err != nil {
+		return a, fmt.Errorf("unmarshaling checksum of %s: %w", a.Path, err)
+	}
+	return a, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// newTestIndex writes the artifacts of a snapshot to a new index
+func newTestIndex(t testing.TB, snap Snapshot) *Index {
+	idx, err := NewIndex(filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { idx.Close() })
+	for _, a := range snap {
+		require.NoError(t, idx.Add(a))
+	}
+	return idx
+}
+
+func TestIndexDelta(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	file := func(path, sha string, ts time.Time) run.Artifact {
+		return run.Artifact{Path: path, Checksum: map[string]string{"SHA256": sha}, Time: ts}
+	}
+	pre := Snapshot{
+		"a.txt":     file("a.txt", "aa", now),
+		"b.txt":     file("b.txt", "bb", now),
+		"c.txt":     file("c.txt", "cc", now),
+		"d.txt":     file("d.txt", "dd", now),
+		"removed":   file("removed", "ee", now),
+		"z/nested":  file("z/nested", "ff", now),
+		"unchanged": file("unchanged", "00", now),
+	}
+	post := Snapshot{
+		"0-new":     file("0-new", "11", now),
+		"a.txt":     file("a.txt", "aa", now),
+		"b.txt":     file("b.txt", "b2", now),
+		"c.txt":     file("c.txt", "cc", now.Add(time.Second)),
+		"d.txt":     {Path: "d.txt", Checksum: map[string]string{"MD5": "dd"}, Time: now},
+		"y-new":     file("y-new", "22", now),
+		"z/nested":  file("z/nested", "ff", now),
+		"unchanged": file("unchanged", "00", now),
+	}
+
+	got := []string{}
+	require.NoError(t, newTestIndex(t, pre).Delta(newTestIndex(t, post), func(a run.Artifact) error {
+		got = append(got, a.Path)
+		return nil
+	}))
+	require.Equal(t, []string{"0-new", "b.txt", "c.txt", "y-new"}, got)
+
+	// The streamed delta matches the in-memory one
+	expect := []string{}
+	for _, a := range pre.Delta(&post) {
+		expect = append(expect, a.Path)
+	}
+	require.ElementsMatch(t, expect, got)
+
+	// Errors from the callback stop the walk
+	require.Error(t, newTestIndex(t, Snapshot{}).Delta(newTestIndex(t, post), func(run.Artifact) error {
+		return fmt.Errorf("stop")
+	}))
+}
+
+func TestIndexReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	_, err := OpenIndex(path)
+	require.Error(t, err)
+
+	idx, err := NewIndex(path)
+	require.NoError(t, err)
+	a := run.Artifact{
+		Path:     "gs://bucket/file.tar.gz",
+		Checksum: map[string]string{"MD5": "d41d8cd98f00b204e9800998ecf8427e", "CRC32C": "00000000"},
+		Time:     time.Unix(1700000000, 123456789),
+	}
+	require.NoError(t, idx.Add(a))
+	require.NoError(t, idx.Close())
+
+	idx, err = OpenIndex(path)
+	require.NoError(t, err)
+	defer idx.Close()
+	n, err := idx.Len()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, idx.Walk(func(got run.Artifact) error {
+		require.Equal(t, a.Path, got.Path)
+		require.Equal(t, a.Checksum, got.Checksum)
+		require.True(t, a.Time.Equal(got.Time))
+		return nil
+	}))
+
+	// Creating an index again replaces the existing one
+	idx2, err := NewIndex(path)
+	require.NoError(t, err)
+	defer idx2.Close()
+	n, err = idx2.Len()
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// BenchmarkIndexDelta measures the streamed delta of indexes where one in
+// ten files changed between them
+func BenchmarkIndexDelta(b *testing.B) {
+	now := time.Now()
+	files := 100000
+	pre, post := Snapshot{}, Snapshot{}
+	for i := 0; i < files; i++ {
+		path := fmt.Sprintf("dir%04d/file%06d", i/100, i)
+		a := run.Artifact{
+			Path:     path,
+			Checksum: map[string]string{"SHA256": fmt.Sprintf("%064x", i)},
+			Time:     now,
+		}
+		pre[path] = a
+		if i%10 == 0 {
+			a.Checksum = map[string]string{"SHA256": fmt.Sprintf("%064x", i+files)}
+		}
+		post[path] = a
+	}
+	preIdx, postIdx := newTestIndex(b, pre), newTestIndex(b, post)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		if err := preIdx.Delta(postIdx, func(run.Artifact) error {
+			n++
+			return nil
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if n != files/10 {
+			b.Fatal("unexpected delta size")
+		}
+	}
+}
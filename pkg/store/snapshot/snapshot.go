@@ -26,26 +26,24 @@ func (snap *Snapshot) Delta(post *Snapshot) []run.Artifact {
 	results := []run.Artifact{}
 	for path, f := range *post {
 		// If the file was not there in the first snap, add it
-		if _, ok := (*snap)[path]; !ok {
+		pre, ok := (*snap)[path]
+		if !ok || changed(pre, f) {
 			results = append(results, f)
-			continue
-		}
-
-		// Check the file attributes to if they were changed
-		if (*snap)[path].Time != f.Time {
-			results = append(results, f)
-			continue
 		}
+	}
+	return results
+}
 
-		checksum := (*snap)[path].Checksum
-		for algo, val := range checksum {
-			if fv, ok := f.Checksum[algo]; ok {
-				if fv != val {
-					results = append(results, f)
-					break
-				}
-			}
+// changed returns true if the attributes of an artifact differ between
+// two snapshots: its time or any checksum present in both.
+func changed(pre, post run.Artifact) bool {
+	if !pre.Time.Equal(post.Time) {
+		return true
+	}
+	for algo, val := range pre.Checksum {
+		if fv, ok := post.Checksum[algo]; ok && fv != val {
+			return true
 		}
 	}
-	return results
+	return false
 }
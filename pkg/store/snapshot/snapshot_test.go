@@ -17,6 +17,7 @@ limitations under the License.
 package snapshot
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -80,3 +81,33 @@ func TestDelta(t *testing.T) {
 		require.Equal(t, tc.expect, tc.preSnap.Delta(&tc.postSnap)) //nolint: gosec
 	}
 }
+
+// BenchmarkDelta measures computing the delta of large snapshots where
+// one in ten files changed between them
+func BenchmarkDelta(b *testing.B) {
+	now := time.Now()
+	for _, files := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("files=%d", files), func(b *testing.B) {
+			pre, post := Snapshot{}, Snapshot{}
+			for i := 0; i < files; i++ {
+				path := fmt.Sprintf("dir%04d/file%06d", i/100, i)
+				a := run.Artifact{
+					Path:     path,
+					Checksum: map[string]string{"SHA256": fmt.Sprintf("%064x", i)},
+					Time:     now,
+				}
+				pre[path] = a
+				if i%10 == 0 {
+					a.Checksum = map[string]string{"SHA256": fmt.Sprintf("%064x", i+files)}
+				}
+				post[path] = a
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if len(pre.Delta(&post)) != files/10 {
+					b.Fatal("unexpected delta size")
+				}
+			}
+		})
+	}
+}
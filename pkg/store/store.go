@@ -17,6 +17,8 @@ limitations under the License.
 package store
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
@@ -26,13 +28,42 @@ import (
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
 )
 
+// Store is an artifact store, read by the driver picked from the scheme
+// of its spec URL
 type Store struct {
 	SpecURL string
 	Driver  Implementation
 }
 
+// Implementation is the interface of the storage drivers. Snap lists the
+// artifacts in the store with their digests. Drivers can implement the
+// optional interfaces below to keep local copies, write on-disk indexes
+// or hash artifacts on demand.
 type Implementation interface {
-	Snap() (*snapshot.Snapshot, error)
+	Snap(context.Context) (*snapshot.Snapshot, error)
+	Capabilities() driver.Capabilities
+}
+
+// ErrNoLocalCopy is returned when the store driver does not keep
+// a copy of the artifacts in the local filesystem
+var ErrNoLocalCopy = errors.New("store does not keep local copies of its artifacts")
+
+// localCopier is implemented by drivers that have the artifacts
+// available in the local filesystem
+type localCopier interface {
+	LocalPath(string) (string, error)
+}
+
+// indexer is implemented by drivers that can write their listing to an
+// on-disk snapshot index as they read it
+type indexer interface {
+	Index(context.Context, *snapshot.Index) error
+}
+
+// artifactHasher is implemented by drivers that can complete the
+// digests of artifacts listed without reading their contents
+type artifactHasher interface {
+	HashArtifact(context.Context, run.Artifact) (run.Artifact, error)
 }
 
 func New(specURL string) (s Store, err error) {
@@ -55,6 +86,14 @@ func New(specURL string) (s Store, err error) {
 		impl, err = driver.NewGCB(specURL)
 	case "github":
 		impl, err = driver.NewGithub(specURL)
+	case "nexus":
+		impl, err = driver.NewNexus(specURL)
+	case "pypi":
+		impl, err = driver.NewPyPI(specURL)
+	case "maven":
+		impl, err = driver.NewMaven(specURL)
+	case "prow-artifacts":
+		impl, err = driver.NewProw(specURL)
 	default:
 		// Attestation use a composed scheme
 		format, _, ok := strings.Cut(u.Scheme, "+")
@@ -66,6 +105,8 @@ func New(specURL string) (s Store, err error) {
 			impl, err = driver.NewAttestation(specURL)
 		case "spdx":
 			impl, err = driver.NewSPDX(specURL)
+		case "gcsinventory":
+			impl, err = driver.NewGCSInventory(specURL)
 		default:
 			err = fmt.Errorf("unknown storage backend %s", format)
 		}
@@ -81,9 +122,9 @@ func New(specURL string) (s Store, err error) {
 
 // ReadArtifacts returns the combined list of artifacts from
 // every store attached to the watcher
-func (s *Store) ReadArtifacts() ([]run.Artifact, error) {
+func (s *Store) ReadArtifacts(ctx context.Context) ([]run.Artifact, error) {
 	artifacts := []run.Artifact{}
-	snap, err := s.Driver.Snap()
+	snap, err := s.Driver.Snap(ctx)
 	if err != nil {
 		return artifacts, fmt.Errorf("snapshotting storage: %w", err)
 	}
@@ -95,6 +136,86 @@ func (s *Store) ReadArtifacts() ([]run.Artifact, error) {
 
 // Snap calls the underlying driver's Snap method to capture
 // the current store's state into a snapshot
-func (s *Store) Snap() (*snapshot.Snapshot, error) {
-	return s.Driver.Snap()
+func (s *Store) Snap(ctx context.Context) (*snapshot.Snapshot, error) {
+	return s.Driver.Snap(ctx)
+}
+
+// SnapIndex captures the store's state into an on-disk snapshot index
+// created at path. Drivers that cannot stream their listing are
+// snapshotted in memory and copied to the index.
+func (s *Store) SnapIndex(ctx context.Context, path string) (*snapshot.Index, error) {
+	idx, err := snapshot.NewIndex(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshot index: %w", err)
+	}
+	if ix, ok := s.Driver.(indexer); ok {
+		err = ix.Index(ctx, idx)
+	} else {
+		var snap *snapshot.Snapshot
+		snap, err = s.Driver.Snap(ctx)
+		if err == nil {
+			for _, a := range *snap {
+				if err = idx.Add(a); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		idx.Close()
+		return nil, fmt.Errorf("indexing %s: %w", s.SpecURL, err)
+	}
+	if err := idx.Flush(); err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// HashArtifact completes the digests of an artifact read from a snapshot
+// index. Artifacts from drivers that hash their listings are returned
+// unchanged.
+func (s *Store) HashArtifact(ctx context.Context, a run.Artifact) (run.Artifact, error) {
+	h, ok := s.Driver.(artifactHasher)
+	if !ok {
+		return a, nil
+	}
+	return h.HashArtifact(ctx, a)
+}
+
+// LocalPath returns the path in the local filesystem of an artifact
+// read from the store
+func (s *Store) LocalPath(artifactPath string) (string, error) {
+	lc, ok := s.Driver.(localCopier)
+	if !ok {
+		return "", ErrNoLocalCopy
+	}
+	return lc.LocalPath(artifactPath)
+}
+
+// Capabilities returns the features supported by the store driver
+func (s *Store) Capabilities() driver.Capabilities {
+	return s.Driver.Capabilities()
+}
+
+// Schemes returns the URL schemes supported by the storage drivers
+// and the capabilities of each driver. Composed schemes take any
+// transport after the plus sign.
+func Schemes() map[string]driver.Capabilities {
+	return map[string]driver.Capabilities{
+		"file":     (&driver.Directory{}).Capabilities(),
+		"gs":       (&driver.GCS{}).Capabilities(),
+		"oci":      (&driver.OCI{}).Capabilities(),
+		"actions":  (&driver.Actions{}).Capabilities(),
+		"gcb":      (&driver.GCB{}).Capabilities(),
+		"github":   (&driver.GitHubRelease{}).Capabilities(),
+		"nexus":    (&driver.Nexus{}).Capabilities(),
+		"pypi":     (&driver.PyPI{}).Capabilities(),
+		"maven":    (&driver.Maven{}).Capabilities(),
+		"intoto+*": (&driver.Attestation{}).Capabilities(),
+		"spdx+*":   (&driver.SPDX{}).Capabilities(),
+
+		"gcsinventory+*": (&driver.GCSInventory{}).Capabilities(),
+		"prow-artifacts": (&driver.Prow{}).Capabilities(),
+	}
 }
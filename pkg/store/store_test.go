@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemes checks that every scheme listed has a driver and that the
+// driver reports the capabilities listed
+func TestSchemes(t *testing.T) {
+	// The GCS clients are created without credentials
+	t.Setenv("STORAGE_EMULATOR_HOST", "127.0.0.1:1")
+	dir := t.TempDir()
+	specs := map[string]string{
+		"file":           "file://" + dir,
+		"gs":             "gs://release-bucket/bin/",
+		"oci":            "oci://ghcr.io/uservers/miniprow/miniprow",
+		"actions":        "actions://puerco/tejolote-test/2969514606",
+		"gcb":            "gcb://puerco-chainguard/5dda8a10-abff-4c32-b003-758eea81ac83",
+		"github":         "github://puerco/hello/v0.0.1",
+		"nexus":          "nexus://nexus.example.com/repository/maven-releases/org/example/",
+		"pypi":           "pypi://project?version=1.2.0",
+		"maven":          "maven://repo.example.com/maven2/org.example/app/1.0.0",
+		"intoto+*":       "intoto+file://" + filepath.Join(dir, "provenance.json"),
+		"spdx+*":         "spdx+file://" + filepath.Join(dir, "sbom.spdx.json"),
+		"gcsinventory+*": "gcsinventory+file://" + filepath.Join(dir, "manifest.json"),
+		"prow-artifacts": "prow-artifacts://kubernetes-jenkins/ci-kubernetes-build/1785224352541446144",
+	}
+	schemes := Schemes()
+	require.Len(t, schemes, len(specs))
+	for scheme, caps := range schemes {
+		t.Run(scheme, func(t *testing.T) {
+			spec, ok := specs[scheme]
+			require.True(t, ok, "no spec URL to test scheme %s", scheme)
+			s, err := New(spec)
+			require.NoError(t, err)
+			require.Equal(t, caps, s.Capabilities())
+		})
+	}
+
+	_, err := New("ftp://example.com/pub")
+	require.Error(t, err)
+	_, err = New("cbor+file://" + dir)
+	require.Error(t, err)
+}
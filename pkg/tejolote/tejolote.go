@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tejolote is the API to embed tejolote in other tools. It
+// wraps the watcher in the two calls release tooling needs: Start,
+// before the build writes its artifacts, and Attest, once it is done.
+//
+//	a, err := tejolote.New(
+//		"github://org/repo/1234",
+//		tejolote.WithArtifactStores("gs://bucket/release/"),
+//	)
+//	if err := a.Start(ctx); err != nil { ... }
+//	// ... the build runs ...
+//	att, err := a.Attest(ctx)
+//
+// The functions and types in this package follow semantic versioning.
+// The packages it re-exports (attestation, run, watcher) can be used for
+// finer control, their exported API is kept backwards compatible too.
+package tejolote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/builder/driver"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/watcher"
+)
+
+type (
+	// Attestation is a SLSA provenance attestation
+	Attestation = attestation.Attestation
+
+	// Signature is the signature of an attestation
+	Signature = attestation.BlobSignature
+
+	// SigstoreOptions point signing to a Sigstore deployment
+	SigstoreOptions = attestation.SigstoreOptions
+
+	// Run is a run of a build system
+	Run = run.Run
+
+	// Option configures an Attester
+	Option = watcher.Option
+
+	// Options are the settings of the watcher behind an Attester
+	Options = watcher.Options
+
+	// BuildSystem is the interface implemented by the build system
+	// drivers, selected by the scheme of the run spec URL
+	BuildSystem = driver.BuildSystem
+
+	// Store is the interface implemented by the artifact storage
+	// drivers, selected by the scheme of the store spec URL
+	Store = store.Implementation
+)
+
+// Options to create an Attester, see the watcher package for details
+var (
+	WithOptions        = watcher.WithOptions
+	WithArtifactStores = watcher.WithArtifactStores
+	WithWaitForBuild   = watcher.WithWaitForBuild
+	WithPollInterval   = watcher.WithPollInterval
+	WithIndexDir       = watcher.WithIndexDir
+	WithAnnotators     = watcher.WithAnnotators
+	WithPolicies       = watcher.WithPolicies
+	WithBuilderID      = watcher.WithBuilderID
+	WithBuildType      = watcher.WithBuildType
+	WithSigstore       = watcher.WithSigstore
+)
+
+// ErrNotAttested is returned when signing before calling Attest
+var ErrNotAttested = errors.New("the run has not been attested")
+
+// Attester generates the provenance attestation of a build run
+type Attester struct {
+	specURL string
+	watcher *watcher.Watcher
+	run     *run.Run
+}
+
+// New returns an attester for the run at the spec URL (eg
+// github://org/repo/1234 or gcb://project/build-id)
+func New(specURL string, opts ...Option) (*Attester, error) {
+	w, err := watcher.New(specURL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating watcher: %w", err)
+	}
+	return &Attester{specURL: specURL, watcher: w}, nil
+}
+
+// Watcher returns the watcher used by the attester, for the features
+// not exposed in this package
+func (a *Attester) Watcher() *watcher.Watcher {
+	return a.watcher
+}
+
+// Start takes the snapshots of the artifact stores before the build.
+// Artifacts found in the stores after the build which were not in these
+// snapshots (or changed) are recorded as the attestation subjects.
+func (a *Attester) Start(ctx context.Context) error {
+	if err := a.watcher.Snap(ctx); err != nil {
+		return fmt.Errorf("snapshotting artifact stores: %w", err)
+	}
+	return a.watcher.CheckEmptyStores()
+}
+
+// SaveState writes the snapshots taken by Start to a file, to attest
+// the run from another process with LoadState
+func (a *Attester) SaveState(ctx context.Context, path string) error {
+	return a.watcher.SaveSnapshots(ctx, path)
+}
+
+// LoadState reads the snapshots written by SaveState
+func (a *Attester) LoadState(ctx context.Context, path string) error {
+	return a.watcher.LoadSnapshots(ctx, path)
+}
+
+// Attest waits for the run to finish (unless WithWaitForBuild(false)
+// was passed), collects the artifacts it produced and returns its
+// attestation after checking it against the configured policies. The
+// attestation records the outcome of the run, failed runs are attested
+// too.
+func (a *Attester) Attest(ctx context.Context) (*Attestation, error) {
+	r, err := a.watcher.GetRun(ctx, a.specURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching run: %w", err)
+	}
+	if err := a.watcher.Watch(ctx, r); err != nil {
+		return nil, fmt.Errorf("watching run: %w", err)
+	}
+	if err := a.watcher.CollectArtifacts(ctx, r); err != nil {
+		return nil, fmt.Errorf("collecting artifacts: %w", err)
+	}
+	att, err := a.watcher.AttestRun(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("attesting run: %w", err)
+	}
+	if err := a.watcher.EvaluatePolicies(ctx, att); err != nil {
+		return nil, fmt.Errorf("evaluating policies: %w", err)
+	}
+	a.run = r
+	return att, nil
+}
+
+// Run returns the run data read by Attest
+func (a *Attester) Run() (*Run, error) {
+	if a.run == nil {
+		return nil, ErrNotAttested
+	}
+	return a.run, nil
+}
+
+// Sign signs the attestation returned by Attest with sigstore
+func (a *Attester) Sign(ctx context.Context, att *Attestation) (*Signature, error) {
+	if a.run == nil {
+		return nil, ErrNotAttested
+	}
+	return a.watcher.SignAttestation(ctx, att, a.run)
+}
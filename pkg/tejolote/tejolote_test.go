@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tejolote
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttester(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), os.FileMode(0o644)))
+
+	_, err := New("github://org/repo/1", WithPollInterval(time.Minute, time.Second))
+	require.Error(t, err)
+	_, err = New("unknown://org/repo/1")
+	require.Error(t, err)
+
+	a, err := New(
+		"github://org/repo/1",
+		WithArtifactStores("file://"+dir),
+		WithPollInterval(time.Second, time.Minute),
+		WithBuilderID("https://example.com/builder"),
+	)
+	require.NoError(t, err)
+	opts := a.Watcher().Options
+	require.Equal(t, time.Second, opts.PollInterval)
+	require.Equal(t, "https://example.com/builder", opts.BuilderID)
+	require.Len(t, a.Watcher().ArtifactStores, 1)
+
+	// The state of the stores can be saved and loaded in another attester
+	require.NoError(t, a.Start(ctx))
+	state := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, a.SaveState(ctx, state))
+	b, err := New("github://org/repo/1", WithArtifactStores("file://"+dir))
+	require.NoError(t, err)
+	require.NoError(t, b.LoadState(ctx, state))
+	require.Len(t, b.Watcher().Snapshots, 1)
+	loaded := *b.Watcher().Snapshots[0]["file://"+dir]
+	require.Equal(t, (*a.Watcher().Snapshots[0]["file://"+dir])["old.txt"].Checksum, loaded["old.txt"].Checksum)
+
+	_, err = a.Run()
+	require.ErrorIs(t, err, ErrNotAttested)
+	_, err = a.Sign(ctx, nil)
+	require.ErrorIs(t, err, ErrNotAttested)
+}
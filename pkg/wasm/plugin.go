@@ -0,0 +1,230 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wasm runs tejolote plugins compiled to WebAssembly with the
+// wazero runtime. Modules run without any access to the host other
+// than the plugin host functions, and each call is bounded by a time
+// limit and a memory limit.
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	// DefaultMaxMemoryPages caps the linear memory at 16MB
+	DefaultMaxMemoryPages = 256
+
+	// DefaultTimeout is the time a plugin call can run
+	DefaultTimeout = 10 * time.Second
+
+	// hostModule is the name of the module with the host functions
+	hostModule = "tejolote"
+)
+
+// ErrTimeout is returned when a plugin call runs out of time
+var ErrTimeout = errors.New("plugin call timed out")
+
+// Options limit the resources a plugin call can use
+type Options struct {
+	// MaxMemoryPages is the maximum size of the linear memory in
+	// pages of 64KB. Defaults to DefaultMaxMemoryPages.
+	MaxMemoryPages uint32
+
+	// Timeout is the time a call can run before it is aborted.
+	// Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Plugin is a module implementing the tejolote plugin ABI:
+//
+//   - It exports its linear memory as "memory".
+//   - It exports alloc(size i32) i32 returning a buffer in memory.
+//   - Each entry point takes the pointer and length of a JSON document
+//     and returns an i64 packing the pointer (high 32 bits) and the
+//     length (low 32 bits) of the JSON result.
+//
+// The only host function available is tejolote.log(ptr, len i32) to
+// write a message to the tejolote log.
+type Plugin struct {
+	Path     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	options  Options
+}
+
+// LoadPlugin reads and compiles a plugin module
+func LoadPlugin(path string, opts Options) (*Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading wasm module: %w", err)
+	}
+	if opts.MaxMemoryPages == 0 {
+		opts.MaxMemoryPages = DefaultMaxMemoryPages
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	ctx := context.Background()
+	p := &Plugin{Path: path, options: opts}
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(opts.MaxMemoryPages).
+		WithCloseOnContextDone(true),
+	)
+	if err := p.load(ctx, data); err != nil {
+		p.runtime.Close(ctx) //nolint: errcheck
+		return nil, err
+	}
+	return p, nil
+}
+
+// load compiles the module and checks it implements the plugin ABI
+func (p *Plugin) load(ctx context.Context, data []byte) error {
+	if _, err := p.runtime.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(p.log).Export("log").
+		Instantiate(ctx); err != nil {
+		return fmt.Errorf("instantiating host functions: %w", err)
+	}
+
+	compiled, err := p.runtime.CompileModule(ctx, data)
+	if err != nil {
+		return fmt.Errorf("compiling wasm module %s: %w", p.Path, err)
+	}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("wasm module %s does not export memory", p.Path)
+	}
+	if _, ok := compiled.ExportedFunctions()["alloc"]; !ok {
+		return fmt.Errorf("wasm module %s does not export alloc", p.Path)
+	}
+	for _, f := range compiled.ImportedFunctions() {
+		module, name, _ := f.Import()
+		if module != hostModule || name != "log" {
+			return fmt.Errorf("wasm module %s imports unsupported function %s.%s", p.Path, module, name)
+		}
+	}
+	if len(compiled.ImportedMemories()) > 0 {
+		return fmt.Errorf("wasm module %s imports memory", p.Path)
+	}
+	p.compiled = compiled
+	return nil
+}
+
+// log is the tejolote.log host function
+func (p *Plugin) log(_ context.Context, m api.Module, ptr, size uint32) {
+	msg, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Sprintf("log message out of bounds (%d+%d)", ptr, size))
+	}
+	logrus.WithField("plugin", p.Path).Info(string(msg))
+}
+
+// Call runs an entry point of the plugin, marshalling the input and
+// unmarshalling the result into output. Every call runs in a new
+// instance so no state is shared between calls.
+func (p *Plugin) Call(ctx context.Context, export string, input, output interface{}) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("marshalling plugin input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.options.Timeout)
+	defer cancel()
+
+	// Instances are anonymous so several calls can run at the same time
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return fmt.Errorf("instantiating wasm module: %w", err)
+	}
+	defer mod.Close(ctx) //nolint: errcheck
+
+	fn := mod.ExportedFunction(export)
+	if fn == nil {
+		return fmt.Errorf("wasm module %s does not export %s", p.Path, export)
+	}
+
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return fmt.Errorf("allocating plugin input: %w", callError(err))
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, payload) {
+		return fmt.Errorf("writing plugin input: buffer out of bounds (%d+%d)", ptr, len(payload))
+	}
+
+	res, err = fn.Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return fmt.Errorf("calling plugin %s: %w", export, callError(err))
+	}
+	if len(res) != 1 {
+		return fmt.Errorf("plugin %s returned %d values", export, len(res))
+	}
+	data, ok := mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return fmt.Errorf("reading plugin %s result: out of bounds", export)
+	}
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("unmarshalling plugin %s result: %w", export, err)
+	}
+	return nil
+}
+
+// Close releases the compiled module and the runtime of the plugin
+func (p *Plugin) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// callError returns ErrTimeout when the call was aborted because its
+// context expired
+func callError(err error) error {
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == sys.ExitCodeDeadlineExceeded {
+		return ErrTimeout
+	}
+	return err
+}
+
+// PluginOptions reads the resource limits from the "timeout" and
+// "memory-pages" plugin options
+func PluginOptions(options map[string]string) (Options, error) {
+	opts := Options{}
+	if v, ok := options["timeout"]; ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("parsing timeout option: %w", err)
+		}
+		opts.Timeout = timeout
+	}
+	if v, ok := options["memory-pages"]; ok {
+		pages, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return opts, fmt.Errorf("parsing memory-pages option: %w", err)
+		}
+		opts.MaxMemoryPages = uint32(pages)
+	}
+	return opts, nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Helpers to hand-assemble modules in the binary format
+
+func uleb(v uint64) []byte {
+	out := []byte{}
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	out := []byte{}
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	out := []byte{}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(uleb(uint64(len(items))), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(uleb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, items ...[]byte) []byte {
+	content := vec(items...)
+	return cat([]byte{id}, uleb(uint64(len(content))), content)
+}
+
+func funcType(params, results []byte) []byte {
+	return cat([]byte{0x60}, uleb(uint64(len(params))), params, uleb(uint64(len(results))), results)
+}
+
+// body encodes a function body with groups of locals of one type
+func body(locals []byte, code ...byte) []byte {
+	l := []byte{}
+	for _, t := range locals {
+		l = append(l, cat(uleb(1), []byte{t})...)
+	}
+	content := cat(uleb(uint64(len(locals))), l, code)
+	return cat(uleb(uint64(len(content))), content)
+}
+
+func export(name string, kind byte, idx uint64) []byte {
+	return cat(str(name), []byte{kind}, uleb(idx))
+}
+
+func module(sections ...[]byte) []byte {
+	return cat([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, cat(sections...))
+}
+
+const (
+	i32 = byte(0x7f)
+	i64 = byte(0x7e)
+
+	exportFunc   = byte(0x00)
+	exportMemory = byte(0x02)
+)
+
+// pluginModule assembles a plugin with minPages of memory whose
+// annotate entry point logs its input and returns a constant result,
+// and whose spin entry point never returns
+func pluginModule(logImport, result string, minPages byte) []byte {
+	packed := int64(16)<<32 | int64(len(result))
+	return module(
+		section(1,
+			funcType([]byte{i32, i32}, nil),
+			funcType([]byte{i32}, []byte{i32}),
+			funcType([]byte{i32, i32}, []byte{i64}),
+		),
+		section(2, cat(str("tejolote"), str(logImport), []byte{0x00}, uleb(0))),
+		section(3, uleb(1), uleb(2), uleb(2)),
+		section(5, []byte{0x00, minPages}),
+		section(7,
+			export("memory", exportMemory, 0),
+			export("alloc", exportFunc, 1),
+			export("annotate", exportFunc, 2),
+			export("spin", exportFunc, 3),
+		),
+		section(10,
+			// alloc: always returns a buffer at 1024
+			body(nil, 0x41, 0x80, 0x08, 0x0b),
+			// annotate: logs its input and returns the constant result
+			body(nil, cat([]byte{0x20, 0, 0x20, 1, 0x10, 0, 0x42}, sleb(packed), []byte{0x0b})...),
+			// spin: loops forever
+			body(nil, 0x03, 0x40, 0x0c, 0, 0x0b, 0x00, 0x0b),
+		),
+		section(11, cat([]byte{0x00, 0x41, 16, 0x0b}, str(result))),
+	)
+}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	require.NoError(t, os.WriteFile(path, pluginModule("log", `{"kind":"test"}`, 1), 0o644))
+
+	p, err := LoadPlugin(path, Options{Timeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	defer p.Close(ctx) //nolint: errcheck
+	out := map[string]string{}
+	require.NoError(t, p.Call(ctx, "annotate", map[string]string{"path": "a.txt"}, &out))
+	require.Equal(t, map[string]string{"kind": "test"}, out)
+
+	require.Error(t, p.Call(ctx, "missing", nil, &out))
+
+	// Calls are aborted when they run out of time
+	require.ErrorIs(t, p.Call(ctx, "spin", nil, &out), ErrTimeout)
+
+	// Modules can only import the plugin host functions
+	require.NoError(t, os.WriteFile(path, pluginModule("exec", "{}", 1), 0o644))
+	_, err = LoadPlugin(path, Options{})
+	require.Error(t, err)
+
+	// Modules cannot ask for more memory than allowed
+	require.NoError(t, os.WriteFile(path, pluginModule("log", "{}", 2), 0o644))
+	_, err = LoadPlugin(path, Options{MaxMemoryPages: 1})
+	require.Error(t, err)
+}
+
+func TestPluginOptions(t *testing.T) {
+	opts, err := PluginOptions(map[string]string{"timeout": "2s", "memory-pages": "16"})
+	require.NoError(t, err)
+	require.Equal(t, Options{Timeout: 2 * time.Second, MaxMemoryPages: 16}, opts)
+
+	_, err = PluginOptions(map[string]string{"timeout": "2"})
+	require.Error(t, err)
+	_, err = PluginOptions(map[string]string{"memory-pages": "-1"})
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultPollInterval    = 3 * time.Second
+	defaultMaxPollInterval = time.Minute
+)
+
+// nextPollInterval doubles the polling interval up to limit
+func nextPollInterval(current, limit time.Duration) time.Duration {
+	next := current * 2
+	if next > limit || next <= 0 {
+		return limit
+	}
+	return next
+}
+
+// withJitter returns the duration randomly shifted up to 20% in either
+// direction, to avoid many watchers polling an API in lockstep
+func withJitter(d time.Duration) time.Duration {
+	spread := int64(d) / 5
+	if spread <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*spread)-spread) //nolint: gosec
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	require.Equal(t, 6*time.Second, nextPollInterval(3*time.Second, time.Minute))
+	require.Equal(t, time.Minute, nextPollInterval(48*time.Second, time.Minute))
+	require.Equal(t, time.Minute, nextPollInterval(time.Minute, time.Minute))
+
+	for i := 0; i < 100; i++ {
+		d := withJitter(10 * time.Second)
+		require.GreaterOrEqual(t, d, 8*time.Second)
+		require.Less(t, d, 12*time.Second)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/ociauth"
+)
+
+// Annotations of the OCI image spec recording the base image an image
+// was built from
+const (
+	baseNameAnnotation   = "org.opencontainers.image.base.name"
+	baseDigestAnnotation = "org.opencontainers.image.base.digest"
+)
+
+// AddBaseImageMaterials records the base images of the container images
+// in the attestation subjects as materials. Base images are read from
+// the org.opencontainers.image.base.* annotations of the image manifest
+// or, when not annotated, from the labels of the image config. The
+// platform images of an index are inspected when the index itself is
+// not annotated.
+func (w *Watcher) AddBaseImageMaterials(ctx context.Context, att *attestation.Attestation) error {
+	opts := []remote.Option{remote.WithAuthFromKeychain(ociauth.Keychain()), remote.WithContext(ctx)}
+	total := 0
+	for _, s := range att.Subject {
+		ref, ok := subjectImageReference(s.Name, s.Digest)
+		if !ok {
+			continue
+		}
+		digestRef, err := name.NewDigest(ref)
+		if err != nil {
+			return fmt.Errorf("parsing image reference %s: %w", ref, err)
+		}
+		desc, err := remote.Get(digestRef, opts...)
+		if err != nil {
+			return fmt.Errorf("fetching %s: %w", ref, err)
+		}
+
+		images := []v1.Image{}
+		if desc.MediaType.IsIndex() {
+			index, err := desc.ImageIndex()
+			if err != nil {
+				return fmt.Errorf("reading image index of %s: %w", ref, err)
+			}
+			manifest, err := index.IndexManifest()
+			if err != nil {
+				return fmt.Errorf("parsing index manifest of %s: %w", ref, err)
+			}
+			if baseName, baseDigest := baseImage(manifest.Annotations); baseName != "" {
+				total += addBaseImage(att, baseName, baseDigest)
+				continue
+			}
+			for _, m := range manifest.Manifests {
+				if !m.MediaType.IsImage() {
+					continue
+				}
+				img, err := index.Image(m.Digest)
+				if err != nil {
+					return fmt.Errorf("reading image %s of %s: %w", m.Digest, ref, err)
+				}
+				images = append(images, img)
+			}
+		} else {
+			img, err := desc.Image()
+			if err != nil {
+				return fmt.Errorf("reading image %s: %w", ref, err)
+			}
+			images = append(images, img)
+		}
+
+		for _, img := range images {
+			baseName, baseDigest, err := imageBase(img)
+			if err != nil {
+				return fmt.Errorf("reading base image of %s: %w", ref, err)
+			}
+			if baseName == "" {
+				logrus.Debugf("Image %s has no base image annotations", ref)
+				continue
+			}
+			total += addBaseImage(att, baseName, baseDigest)
+		}
+	}
+	logrus.Infof("Recorded %d base images as materials", total)
+	return nil
+}
+
+// subjectImageReference returns the digest reference of a subject if it
+// is a container image (oci://repo:tag or oci://repo@digest)
+func subjectImageReference(subject string, digest map[string]string) (string, bool) {
+	ref, ok := strings.CutPrefix(subject, "oci://")
+	if !ok {
+		return "", false
+	}
+	if strings.Contains(ref, "@") {
+		return ref, true
+	}
+	d := digest["sha256"]
+	if d == "" {
+		d = digest["SHA256"]
+	}
+	if d == "" {
+		return "", false
+	}
+	// Strip the tag, taking care of registries with a port
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref + "@sha256:" + d, true
+}
+
+// imageBase reads the base image of an image from its manifest
+// annotations, falling back to the labels in its config
+func imageBase(img v1.Image) (baseName, baseDigest string, err error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return "", "", fmt.Errorf("reading manifest: %w", err)
+	}
+	if baseName, baseDigest = baseImage(manifest.Annotations); baseName != "" {
+		return baseName, baseDigest, nil
+	}
+	conf, err := img.ConfigFile()
+	if err != nil {
+		return "", "", fmt.Errorf("reading config: %w", err)
+	}
+	baseName, baseDigest = baseImage(conf.Config.Labels)
+	return baseName, baseDigest, nil
+}
+
+// baseImage returns the base image name and digest from a set of
+// annotations or labels
+func baseImage(annotations map[string]string) (baseName, baseDigest string) {
+	return annotations[baseNameAnnotation], annotations[baseDigestAnnotation]
+}
+
+// addBaseImage adds a base image material to the attestation, returning
+// the number of materials added
+func addBaseImage(att *attestation.Attestation, baseName, baseDigest string) int {
+	uri := "oci://" + baseName
+	for _, m := range att.Predicate.Materials {
+		if m.URI == uri {
+			return 0
+		}
+	}
+	digest := map[string]string{}
+	if algo, hex, ok := strings.Cut(baseDigest, ":"); ok {
+		digest[algo] = hex
+	} else {
+		logrus.Warnf("Base image %s is not annotated with its digest", baseName)
+	}
+	att.Predicate.AddMaterial(uri, digest)
+	return 1
+}
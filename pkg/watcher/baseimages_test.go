@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+)
+
+func TestAddBaseImageMaterials(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	repo := strings.TrimPrefix(reg.URL, "http://") + "/tejolote/app"
+
+	// An image annotated with its base and one only labeled
+	annotated := mutate.Annotations(empty.Image, map[string]string{
+		"org.opencontainers.image.base.name":   "cgr.dev/chainguard/static:latest",
+		"org.opencontainers.image.base.digest": "sha256:1234",
+	}).(v1.Image)
+	labeled, err := mutate.Config(empty.Image, v1.Config{Labels: map[string]string{
+		"org.opencontainers.image.base.name": "docker.io/library/alpine:3.19",
+	}})
+	require.NoError(t, err)
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: labeled})
+
+	subjects := []intoto.Subject{}
+	for tag, img := range map[string]v1.Image{"annotated": annotated, "labeled": labeled} {
+		ref, err := name.ParseReference(repo + ":" + tag)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(ref, img))
+	}
+	d, err := annotated.Digest()
+	require.NoError(t, err)
+	subjects = append(subjects, intoto.Subject{Name: "oci://" + repo + ":annotated", Digest: map[string]string{"sha256": d.Hex}})
+	ref, err := name.ParseReference(repo + ":index")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(ref, index))
+	d, err = index.Digest()
+	require.NoError(t, err)
+	subjects = append(subjects,
+		intoto.Subject{Name: "oci://" + repo + "@" + d.String()},
+		intoto.Subject{Name: "gs://bucket/bin", Digest: map[string]string{"sha256": "abc"}},
+	)
+
+	att := attestation.New().SLSA()
+	att.AddSubjects(subjects...)
+	require.NoError(t, (&Watcher{}).AddBaseImageMaterials(context.Background(), att))
+	require.Len(t, att.Predicate.Materials, 2)
+	require.Equal(t, "oci://cgr.dev/chainguard/static:latest", att.Predicate.Materials[0].URI)
+	require.Equal(t, "1234", att.Predicate.Materials[0].Digest["sha256"])
+	require.Equal(t, "oci://docker.io/library/alpine:3.19", att.Predicate.Materials[1].URI)
+	require.Empty(t, att.Predicate.Materials[1].Digest)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+// AnnotateArtifactSignatures records in the subject annotations where
+// SignArtifacts writes the detached signature and the signing certificate
+// of each artifact. It only changes the attestation so that policies are
+// evaluated on its final contents before anything is signed or uploaded.
+//
+// Only artifacts with a local copy (directories and buckets) are
+// signed, container images and artifacts from other stores are skipped.
+func (w *Watcher) AnnotateArtifactSignatures(att *attestation.Attestation) error {
+	for i := range att.Subject {
+		s := &att.Subject[i]
+		localPath, err := w.localArtifact(s.Name)
+		if err != nil {
+			return err
+		}
+		if localPath == "" {
+			continue
+		}
+		if s.Annotations == nil {
+			s.Annotations = map[string]string{}
+		}
+		s.Annotations["signature"] = s.Name + ".sig"
+		s.Annotations["certificate"] = s.Name + ".pem"
+	}
+	return nil
+}
+
+// SignArtifacts signs the blobs annotated by AnnotateArtifactSignatures
+// and writes detached signatures (.sig, base64 encoded as cosign does)
+// and signing certificates (.pem) next to them. Each artifact is checked
+// against the subject digest before signing so that the signatures and
+// the provenance cover exactly the same set.
+func (w *Watcher) SignArtifacts(ctx context.Context, att *attestation.Attestation, signer attestation.BlobSigner) error {
+	signed := 0
+	for i := range att.Subject {
+		s := &att.Subject[i]
+		if _, ok := s.Annotations["signature"]; !ok {
+			continue
+		}
+		localPath, err := w.localArtifact(s.Name)
+		if err != nil {
+			return err
+		}
+		if localPath == "" {
+			return fmt.Errorf("signing %s: artifact has no local copy", s.Name)
+		}
+
+		sig, err := signLocalBlob(ctx, signer, localPath, s.Digest)
+		if err != nil {
+			return fmt.Errorf("signing %s: %w", s.Name, err)
+		}
+		if sig.Certificate == nil {
+			return fmt.Errorf("signing %s: signature has no certificate", s.Name)
+		}
+
+		dest := "file://" + localPath
+		if strings.HasPrefix(s.Name, "gs://") {
+			dest = s.Name
+		}
+		if err := driver.UploadURL(
+			ctx, dest+".sig", strings.NewReader(base64.StdEncoding.EncodeToString(sig.Signature)),
+		); err != nil {
+			return fmt.Errorf("writing signature of %s: %w", s.Name, err)
+		}
+		if err := driver.UploadURL(ctx, dest+".pem", bytes.NewReader(sig.Certificate)); err != nil {
+			return fmt.Errorf("writing certificate of %s: %w", s.Name, err)
+		}
+		signed++
+	}
+	logrus.Infof("Signed %d artifacts", signed)
+	return nil
+}
+
+// localArtifact returns the path to the local copy of an artifact
+// collected from the stores, or an empty string if it has none
+func (w *Watcher) localArtifact(name string) (string, error) {
+	source, ok := w.artifactSources[name]
+	if !ok {
+		return "", nil
+	}
+	localPath, err := source.LocalPath(name)
+	if err != nil {
+		if !errors.Is(err, store.ErrNoLocalCopy) {
+			return "", fmt.Errorf("locating %s: %w", name, err)
+		}
+		logrus.Debugf("Not signing %s: %v", name, err)
+		return "", nil
+	}
+	return localPath, nil
+}
+
+// signLocalBlob signs a file after checking it still matches the digest
+func signLocalBlob(
+	ctx context.Context, signer attestation.BlobSigner, path string, digest map[string]string,
+) (*attestation.BlobSignature, error) {
+	expected := ""
+	for algo, val := range digest {
+		if strings.EqualFold(algo, "sha256") {
+			expected = val
+		}
+	}
+	if expected == "" {
+		return nil, errors.New("subject has no sha256 digest")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening artifact: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("hashing artifact: %w", err)
+	}
+	if fmt.Sprintf("%x", h.Sum(nil)) != expected {
+		return nil, errors.New("artifact changed since it was attested")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewinding artifact: %w", err)
+	}
+	return signer.SignBlob(ctx, f)
+}
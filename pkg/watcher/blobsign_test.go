@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+type fakeBlobSigner struct{}
+
+func (fakeBlobSigner) SignBlob(_ context.Context, r io.ReadSeeker) (*attestation.BlobSignature, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &attestation.BlobSignature{Signature: append([]byte("sig:"), data...), Certificate: []byte("cert")}, nil
+}
+
+func TestSignArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "binary"), []byte("data"), os.FileMode(0o644)))
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	artifacts, err := s.ReadArtifacts(context.Background())
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+
+	w := &Watcher{artifactSources: map[string]store.Store{"binary": s}}
+	att := attestation.New().SLSA()
+	att.AddSubjects(
+		intoto.Subject{Name: "binary", Digest: artifacts[0].Checksum},
+		// Subjects not collected from stores are not signed
+		intoto.Subject{Name: "oci://example.com/image:v1", Digest: map[string]string{"sha256": "abc"}},
+	)
+	require.NoError(t, w.AnnotateArtifactSignatures(att))
+	require.Equal(t, map[string]string{"signature": "binary.sig", "certificate": "binary.pem"}, att.Subject[0].Annotations)
+	require.Nil(t, att.Subject[1].Annotations)
+	// Annotating does not sign anything
+	require.NoFileExists(t, filepath.Join(dir, "binary.sig"))
+
+	require.NoError(t, w.SignArtifacts(context.Background(), att, fakeBlobSigner{}))
+
+	sig, err := os.ReadFile(filepath.Join(dir, "binary.sig"))
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("sig:data")), string(sig))
+	require.FileExists(t, filepath.Join(dir, "binary.pem"))
+
+	// Artifacts modified after attesting are not signed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "binary"), []byte("changed"), os.FileMode(0o644)))
+	require.Error(t, w.SignArtifacts(context.Background(), att, fakeBlobSigner{}))
+}
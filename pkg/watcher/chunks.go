@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// snapshotChunkEntries is the average number of entries in a snapshot
+// chunk. Chunk boundaries are set by the hash of the artifact paths so
+// adding or removing artifacts only changes the chunks around them.
+const snapshotChunkEntries = 1024
+
+// SnapshotChunk references a piece of a store snapshot saved as a
+// content-addressed object in the chunk location
+type SnapshotChunk struct {
+	Digest  string `json:"digest"`
+	Entries int    `json:"entries"`
+}
+
+// chunkURL returns the location of a chunk in the chunk store
+func chunkURL(location, digest string) string {
+	return strings.TrimSuffix(location, "/") + "/" + digest + ".json"
+}
+
+// splitSnapshot divides a snapshot in content-defined chunks. A chunk
+// ends after each path whose hash is a multiple of the average size.
+func splitSnapshot(snap *snapshot.Snapshot) []snapshot.Snapshot {
+	paths := make([]string, 0, len(*snap))
+	for p := range *snap {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	chunks := []snapshot.Snapshot{}
+	current := snapshot.Snapshot{}
+	for _, p := range paths {
+		current[p] = (*snap)[p]
+		h := sha256.Sum256([]byte(p))
+		if binary.BigEndian.Uint64(h[:8])%snapshotChunkEntries == 0 {
+			chunks = append(chunks, current)
+			current = snapshot.Snapshot{}
+		}
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// chunkedSnapshotState writes the snapshots of the watcher to the chunk
+// location and returns the state manifest referencing them. Chunks
+// already in the location, eg written by an earlier run over the same
+// stores, are not uploaded again.
+func (w *Watcher) chunkedSnapshotState(ctx context.Context) (*SnapshotState, error) {
+	location := w.Options.SnapshotChunkLocation
+	if strings.HasPrefix(location, "oci://") {
+		return nil, errors.New("snapshot chunks can only be stored in buckets or local directories")
+	}
+	if !IsRemoteState(location) {
+		if err := os.MkdirAll(strings.TrimPrefix(location, "file://"), os.FileMode(0o755)); err != nil {
+			return nil, fmt.Errorf("creating chunk directory: %w", err)
+		}
+	}
+
+	state := w.snapshotState()
+	state.Snapshots = nil
+	state.ChunkLocation = location
+	state.Chunks = []map[string][]SnapshotChunk{}
+
+	written := map[string]struct{}{}
+	uploaded := 0
+	for _, set := range w.Snapshots {
+		refs := map[string][]SnapshotChunk{}
+		for specURL, snap := range set {
+			// Indexed stores keep their null snapshot
+			if snap == nil {
+				refs[specURL] = nil
+				continue
+			}
+			refs[specURL] = []SnapshotChunk{}
+			for _, chunk := range splitSnapshot(snap) {
+				data, err := json.Marshal(chunk)
+				if err != nil {
+					return nil, fmt.Errorf("marshaling snapshot chunk: %w", err)
+				}
+				digest := fmt.Sprintf("%x", sha256.Sum256(data))
+				refs[specURL] = append(refs[specURL], SnapshotChunk{Digest: digest, Entries: len(chunk)})
+				if _, ok := written[digest]; ok {
+					continue
+				}
+				written[digest] = struct{}{}
+				exists, err := chunkExists(ctx, chunkURL(location, digest))
+				if err != nil {
+					return nil, fmt.Errorf("checking snapshot chunk: %w", err)
+				}
+				if exists {
+					continue
+				}
+				if err := WriteSnapshotState(ctx, chunkURL(location, digest), data); err != nil {
+					return nil, fmt.Errorf("writing snapshot chunk: %w", err)
+				}
+				uploaded++
+			}
+		}
+		state.Chunks = append(state.Chunks, refs)
+	}
+	logrus.Infof(
+		"Snapshot state split in %d chunks, %d new written to %s",
+		len(written), uploaded, location,
+	)
+	return state, nil
+}
+
+// chunkExists checks if a chunk is already in the chunk location
+func chunkExists(ctx context.Context, path string) (bool, error) {
+	if !IsRemoteState(path) && !strings.HasPrefix(path, "file://") {
+		path = "file://" + path
+	}
+	return driver.ExistsURL(ctx, path)
+}
+
+// readChunks rebuilds the snapshot sets of a chunked state, verifying
+// the digest of each chunk read
+func readChunks(ctx context.Context, state *SnapshotState) ([]map[string]*snapshot.Snapshot, error) {
+	cache := map[string]snapshot.Snapshot{}
+	sets := []map[string]*snapshot.Snapshot{}
+	for _, refs := range state.Chunks {
+		set := map[string]*snapshot.Snapshot{}
+		for specURL, chunks := range refs {
+			if chunks == nil {
+				set[specURL] = nil
+				continue
+			}
+			snap := snapshot.Snapshot{}
+			for _, ref := range chunks {
+				chunk, ok := cache[ref.Digest]
+				if !ok {
+					data, err := ReadSnapshotState(ctx, chunkURL(state.ChunkLocation, ref.Digest))
+					if err != nil {
+						return nil, fmt.Errorf("reading snapshot chunk %s: %w", ref.Digest, err)
+					}
+					if got := fmt.Sprintf("%x", sha256.Sum256(data)); got != ref.Digest {
+						return nil, fmt.Errorf("snapshot chunk digest mismatch (expected %s got %s)", ref.Digest, got)
+					}
+					if err := json.Unmarshal(data, &chunk); err != nil {
+						return nil, fmt.Errorf("unmarshaling snapshot chunk %s: %w", ref.Digest, err)
+					}
+					cache[ref.Digest] = chunk
+				}
+				for p, a := range chunk {
+					snap[p] = a
+				}
+			}
+			set[specURL] = &snap
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
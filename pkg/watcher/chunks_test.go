@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+func TestChunkedSnapshotState(t *testing.T) {
+	s, err := store.New("file://" + t.TempDir())
+	require.NoError(t, err)
+	snap := snapshot.Snapshot{}
+	for i := 0; i < 5000; i++ {
+		p := fmt.Sprintf("release/file-%05d", i)
+		snap[p] = run.Artifact{Path: p, Checksum: map[string]string{"SHA256": fmt.Sprintf("%064d", i)}}
+	}
+	chunkDir := filepath.Join(t.TempDir(), "chunks")
+	w := &Watcher{
+		ArtifactStores: []store.Store{s},
+		Snapshots:      []map[string]*snapshot.Snapshot{{s.SpecURL: &snap}},
+		Options:        Options{SnapshotChunkLocation: chunkDir},
+	}
+
+	path := filepath.Join(t.TempDir(), "run1.json")
+	require.NoError(t, w.SaveSnapshots(context.Background(), path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	state := SnapshotState{}
+	require.NoError(t, json.Unmarshal(data, &state))
+	require.Nil(t, state.Snapshots)
+	require.Len(t, state.Chunks, 1)
+	require.Greater(t, len(state.Chunks[0][s.SpecURL]), 1)
+	chunks, err := os.ReadDir(chunkDir)
+	require.NoError(t, err)
+	first := len(chunks)
+
+	// A later run over the same store only writes the chunks that changed
+	snap["release/file-02500-new"] = run.Artifact{Path: "release/file-02500-new"}
+	require.NoError(t, w.SaveSnapshots(context.Background(), filepath.Join(t.TempDir(), "run2.json")))
+	chunks, err = os.ReadDir(chunkDir)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(chunks)-first, 2)
+
+	w2 := &Watcher{ArtifactStores: []store.Store{s}}
+	require.NoError(t, w2.LoadSnapshots(context.Background(), path))
+	require.Len(t, *w2.Snapshots[0][s.SpecURL], 5000)
+	stores, err := SnapshotStores(context.Background(), path)
+	require.NoError(t, err)
+	require.Equal(t, []string{s.SpecURL}, stores)
+
+	// Modified chunks fail the digest check
+	require.NoError(t, os.WriteFile(
+		filepath.Join(chunkDir, state.Chunks[0][s.SpecURL][0].Digest+".json"), []byte("{}"), os.FileMode(0o644),
+	))
+	require.Error(t, w2.LoadSnapshots(context.Background(), path))
+}
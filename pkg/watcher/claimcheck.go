@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+// ClaimCheckMessage is published instead of the real message when the
+// payload is too big to be sent inline. The payload is uploaded to a
+// bucket and the message only carries its location and digest.
+type ClaimCheckMessage struct {
+	ClaimCheck ClaimCheck `json:"claim_check"`
+}
+
+type ClaimCheck struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+	Size   int               `json:"size"`
+}
+
+// claimCheckData uploads the message payload to the configured claim
+// check location and returns the reference message to publish in its
+// place. The object name is the sha256 of the payload.
+func (w *Watcher) claimCheckData(ctx context.Context, data []byte) ([]byte, error) {
+	if w.Options.ClaimCheckLocation == "" {
+		return nil, fmt.Errorf(
+			"message is %d bytes, larger than the publisher limit and no claim check location is set",
+			len(data),
+		)
+	}
+
+	u, err := url.Parse(w.Options.ClaimCheckLocation)
+	if err != nil {
+		return nil, fmt.Errorf("parsing claim check location: %w", err)
+	}
+	if u.Scheme != "gs" && u.Scheme != "s3" {
+		return nil, errors.New("claim check location must be a gs:// or s3:// url")
+	}
+
+	digest := fmt.Sprintf("%x", sha256.Sum256(data))
+	objectPath := strings.TrimPrefix(path.Join(u.Path, digest+".json"), "/")
+	uri := fmt.Sprintf("%s://%s/%s", u.Scheme, u.Hostname(), objectPath)
+	if err := driver.UploadURL(ctx, uri, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("uploading claim check payload: %w", err)
+	}
+	logrus.Infof("message too large to publish, payload uploaded to %s", uri)
+	return json.Marshal(ClaimCheckMessage{
+		ClaimCheck: ClaimCheck{
+			URI:    uri,
+			Digest: map[string]string{"sha256": digest},
+			Size:   len(data),
+		},
+	})
+}
+
+// ResolveClaimCheck takes the data of a received message. If the message
+// is a claim check, it downloads the referenced payload, verifies its
+// sha256 digest and returns it. Any other message is returned unchanged.
+func ResolveClaimCheck(ctx context.Context, data []byte) ([]byte, error) {
+	msg := ClaimCheckMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.ClaimCheck.URI == "" {
+		return data, nil
+	}
+
+	expected, ok := msg.ClaimCheck.Digest["sha256"]
+	if !ok {
+		return nil, errors.New("claim check has no sha256 digest")
+	}
+	u, err := url.Parse(msg.ClaimCheck.URI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing claim check uri: %w", err)
+	}
+	if u.Scheme != "gs" && u.Scheme != "s3" {
+		return nil, fmt.Errorf("unsupported claim check location %s", msg.ClaimCheck.URI)
+	}
+
+	var payload bytes.Buffer
+	if err := driver.DownloadURL(ctx, msg.ClaimCheck.URI, &payload); err != nil {
+		return nil, fmt.Errorf("reading claim check payload: %w", err)
+	}
+	if got := fmt.Sprintf("%x", sha256.Sum256(payload.Bytes())); got != expected {
+		return nil, fmt.Errorf(
+			"claim check payload digest mismatch (expected %s got %s)", expected, got,
+		)
+	}
+	return payload.Bytes(), nil
+}
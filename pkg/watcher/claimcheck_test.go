@@ -0,0 +1,208 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCS stores the objects uploaded by the storage client pointed to
+// it with STORAGE_EMULATOR_HOST and serves them back
+func fakeGCS(t *testing.T) map[string][]byte {
+	var mtx sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+			bucket := strings.Split(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/")[0]
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mr := multipart.NewReader(r.Body, params["boundary"])
+			meta := map[string]string{}
+			part, err := mr.NextPart()
+			if err == nil {
+				err = json.NewDecoder(part).Decode(&meta)
+			}
+			if err == nil {
+				part, err = mr.NextPart()
+			}
+			var data []byte
+			if err == nil {
+				data, err = io.ReadAll(part)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			objects[bucket+"/"+meta["name"]] = data
+			json.NewEncoder(w).Encode(map[string]string{ //nolint: errcheck
+				"bucket": bucket, "name": meta["name"], "size": fmt.Sprintf("%d", len(data)),
+			})
+		case r.Method == http.MethodGet:
+			data, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+			w.Write(data) //nolint: errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	return objects
+}
+
+func TestClaimCheck(t *testing.T) {
+	objects := fakeGCS(t)
+	w := &Watcher{Options: Options{ClaimCheckLocation: "gs://bucket/claims"}}
+	payload := []byte(`{"spec":"gcb://project/build","attestation":"e30="}`)
+	digest := fmt.Sprintf("%x", sha256.Sum256(payload))
+
+	data, err := w.claimCheckData(context.Background(), payload)
+	require.NoError(t, err)
+	msg := ClaimCheckMessage{}
+	require.NoError(t, json.Unmarshal(data, &msg))
+	require.Equal(t, ClaimCheck{
+		URI:    "gs://bucket/claims/" + digest + ".json",
+		Digest: map[string]string{"sha256": digest},
+		Size:   len(payload),
+	}, msg.ClaimCheck)
+	require.Equal(t, payload, objects["bucket/claims/"+digest+".json"])
+
+	resolved, err := ResolveClaimCheck(context.Background(), data)
+	require.NoError(t, err)
+	require.Equal(t, payload, resolved)
+
+	// Tampered payloads fail the digest check
+	objects["bucket/claims/"+digest+".json"] = []byte(`{"spec":"gcb://project/other"}`)
+	_, err = ResolveClaimCheck(context.Background(), data)
+	require.ErrorContains(t, err, "digest mismatch")
+}
+
+func TestClaimCheckLocation(t *testing.T) {
+	w := &Watcher{}
+	_, err := w.claimCheckData(context.Background(), []byte("{}"))
+	require.ErrorContains(t, err, "no claim check location")
+
+	w.Options.ClaimCheckLocation = "https://example.com/claims"
+	_, err = w.claimCheckData(context.Background(), []byte("{}"))
+	require.Error(t, err)
+}
+
+func TestResolveClaimCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		data        string
+		shouldError bool
+	}{
+		{name: "regular message", data: `{"spec":"gcb://project/build"}`},
+		{name: "not json", data: `spec`},
+		{
+			name:        "no digest",
+			data:        `{"claim_check":{"uri":"gs://bucket/claims/payload.json"}}`,
+			shouldError: true,
+		},
+		{
+			name:        "no sha256 digest",
+			data:        `{"claim_check":{"uri":"gs://bucket/claims/payload.json","digest":{"sha1":"abc"}}}`,
+			shouldError: true,
+		},
+		{
+			name:        "unsupported location",
+			data:        `{"claim_check":{"uri":"https://example.com/payload.json","digest":{"sha256":"abc"}}}`,
+			shouldError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Claim checks must be refused before fetching anything
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			data, err := ResolveClaimCheck(ctx, []byte(tc.data))
+			if tc.shouldError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.data, string(data))
+		})
+	}
+}
+
+func fakeS3(t *testing.T) map[string][]byte {
+	t.Helper()
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body) //nolint: errcheck
+			objects[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data) //nolint: errcheck
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	return objects
+}
+
+func TestS3ClaimCheck(t *testing.T) {
+	objects := fakeS3(t)
+	w := &Watcher{Options: Options{ClaimCheckLocation: "s3://bucket/claims"}}
+	payload := []byte(`{"spec":"gcb://project/build","attestation":"e30="}`)
+	data, err := w.claimCheckData(context.Background(), payload)
+	require.NoError(t, err)
+
+	msg := ClaimCheckMessage{}
+	require.NoError(t, json.Unmarshal(data, &msg))
+	require.True(t, strings.HasPrefix(msg.ClaimCheck.URI, "s3://bucket/claims/"))
+	require.Len(t, objects, 1)
+
+	resolved, err := ResolveClaimCheck(context.Background(), data)
+	require.NoError(t, err)
+	require.Equal(t, payload, resolved)
+}
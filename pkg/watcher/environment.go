@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/environment"
+)
+
+// AddEnvironment records the environment of the build machine in the
+// invocation environment of the attestation, next to the values set by
+// the build system driver, and flags the environment as complete.
+func (w *Watcher) AddEnvironment(att *attestation.Attestation, env *environment.Environment) error {
+	merged := map[string]interface{}{}
+	if att.Predicate.Invocation.Environment != nil {
+		data, err := json.Marshal(att.Predicate.Invocation.Environment)
+		if err != nil {
+			return fmt.Errorf("marshalling invocation environment: %w", err)
+		}
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return fmt.Errorf("invocation environment is not an object: %w", err)
+		}
+	}
+	merged["runner"] = env.Runner
+	if len(env.Variables) > 0 {
+		merged["variables"] = env.Variables
+	}
+	att.Predicate.Invocation.Environment = merged
+
+	if att.Predicate.Metadata == nil {
+		att.Predicate.Metadata = &slsa.ProvenanceMetadata{}
+	}
+	att.Predicate.Metadata.Completeness.Environment = true
+	return nil
+}
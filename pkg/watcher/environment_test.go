@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/environment"
+)
+
+func TestAddEnvironment(t *testing.T) {
+	att := attestation.New().SLSA()
+	att.Predicate.Invocation.Environment = map[string]string{"job": "build"}
+	env := &environment.Environment{
+		Runner:    environment.Runner{OS: "Linux", Arch: "X64"},
+		Variables: map[string]string{"CI": "true"},
+	}
+	require.NoError(t, (&Watcher{}).AddEnvironment(att, env))
+	require.Equal(t, map[string]interface{}{
+		"job":       "build",
+		"runner":    env.Runner,
+		"variables": env.Variables,
+	}, att.Predicate.Invocation.Environment)
+	require.True(t, att.Predicate.Metadata.Completeness.Environment)
+
+	att.Predicate.Invocation.Environment = []string{"not", "an", "object"}
+	require.Error(t, (&Watcher{}).AddEnvironment(att, env))
+}
+
+// fakeS3 serves the object PUT and GET requests of the S3 client from
+// memory and points the AWS configuration to it
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/policy"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// EventType identifies a step in the lifecycle of a watched run
+type EventType string
+
+const (
+	EventRunStarted         EventType = "run.started"         // The run was fetched from the build system
+	EventRunRefreshed       EventType = "run.refreshed"       // The run state was polled from the build system
+	EventSnapshotDone       EventType = "snapshot.done"       // A snapshot set of the artifact stores was taken
+	EventArtifactsCollected EventType = "artifacts.collected" // The run artifacts were read from the stores
+	EventAttestationWritten EventType = "attestation.written" // The attestation of the run was generated
+	EventAttestationSigned  EventType = "attestation.signed"  // The attestation was signed
+)
+
+// Event is a notification of a lifecycle step of the watcher
+type Event struct {
+	Type EventType
+	Time time.Time
+	Run  *run.Run // Run observed, nil for snapshot events
+}
+
+// EventHandler is a function called when the watcher emits an event
+type EventHandler func(Event)
+
+// eventBus dispatches the watcher events to the subscribed handlers
+type eventBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]subscription
+}
+
+type subscription struct {
+	handler EventHandler
+	types   map[EventType]struct{}
+}
+
+// Subscribe registers a handler to be called when the watcher emits
+// an event of the listed types, or any event if no types are listed.
+// Handlers run synchronously in the goroutine emitting the event, in
+// the order they were subscribed, and must not block. It returns a
+// function to cancel the subscription.
+func (w *Watcher) Subscribe(handler EventHandler, types ...EventType) (unsubscribe func()) {
+	w.events.mu.Lock()
+	defer w.events.mu.Unlock()
+	if w.events.handlers == nil {
+		w.events.handlers = map[int]subscription{}
+	}
+	sub := subscription{handler: handler, types: map[EventType]struct{}{}}
+	for _, t := range types {
+		sub.types[t] = struct{}{}
+	}
+	id := w.events.nextID
+	w.events.nextID++
+	w.events.handlers[id] = sub
+	return func() {
+		w.events.mu.Lock()
+		defer w.events.mu.Unlock()
+		delete(w.events.handlers, id)
+	}
+}
+
+// emit sends an event to the handlers subscribed to its type
+func (w *Watcher) emit(eventType EventType, r *run.Run) {
+	w.events.mu.RLock()
+	ids := []int{}
+	for id := range w.events.handlers {
+		ids = append(ids, id)
+	}
+	handlers := []EventHandler{}
+	sort.Ints(ids)
+	for _, id := range ids {
+		sub := w.events.handlers[id]
+		if _, ok := sub.types[eventType]; ok || len(sub.types) == 0 {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	w.events.mu.RUnlock()
+
+	e := Event{Type: eventType, Time: time.Now().UTC(), Run: r}
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// SignAttestation signs the attestation and returns the signature
+// with the signed envelope, emitting EventAttestationSigned
+func (w *Watcher) SignAttestation(ctx context.Context, att *attestation.Attestation, r *run.Run) (*attestation.BlobSignature, error) {
+	if err := w.runHooks(ctx, config.HookPreSign, r, att); err != nil {
+		return nil, err
+	}
+	sig, err := att.SignEnvelope(ctx, w.Options.Sigstore)
+	if err != nil {
+		return nil, err
+	}
+	if w.Options.TimestampServer != "" {
+		sig.Timestamp, err = attestation.Timestamp(ctx, w.Options.TimestampServer, sig.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("timestamping signature: %w", err)
+		}
+	}
+	w.emit(EventAttestationSigned, r)
+	return sig, nil
+}
+
+// EvaluatePolicies checks the attestation against the configured
+// policies, returning an error if any of them denies it
+func (w *Watcher) EvaluatePolicies(ctx context.Context, att *attestation.Attestation) error {
+	if len(w.Options.Policies) == 0 {
+		return nil
+	}
+	if err := policy.EvaluateAll(ctx, w.Options.Policies, att); err != nil {
+		return err
+	}
+	logrus.Infof("attestation passed %d policies", len(w.Options.Policies))
+	return nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestSubscribe(t *testing.T) {
+	w, err := New("gcb://my-project/1234")
+	require.NoError(t, err)
+
+	all := []EventType{}
+	snaps := 0
+	w.Subscribe(func(e Event) { all = append(all, e.Type) })
+	unsubscribe := w.Subscribe(func(Event) { snaps++ }, EventSnapshotDone)
+
+	require.NoError(t, w.Snap(context.Background()))
+	r := &run.Run{SpecURL: "gcb://my-project/1234"}
+	w.emit(EventRunRefreshed, r)
+	unsubscribe()
+	require.NoError(t, w.Snap(context.Background()))
+
+	require.Equal(t, []EventType{EventSnapshotDone, EventRunRefreshed, EventSnapshotDone}, all)
+	require.Equal(t, 1, snaps)
+}
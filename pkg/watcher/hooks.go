@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// defaultHookTimeout is the time a hook can run when its
+// configuration does not set a timeout
+const defaultHookTimeout = 5 * time.Minute
+
+// HookContext is the JSON document written to the standard input
+// of the hook commands
+type HookContext struct {
+	Phase     string                `json:"phase"`
+	SpecURL   string                `json:"spec_url"`
+	Stores    []string              `json:"stores"`
+	Success   *bool                 `json:"success,omitempty"`
+	Artifacts []run.Artifact        `json:"artifacts,omitempty"`
+	Subjects  []attestation.Subject `json:"subjects,omitempty"`
+}
+
+// runHooks executes the hooks configured for a lifecycle phase. Run
+// and attestation data are passed to the hooks when not nil. A failing
+// hook stops the rest and returns an error unless it is optional.
+func (w *Watcher) runHooks(ctx context.Context, phase string, r *run.Run, att *attestation.Attestation) error {
+	hctx := HookContext{
+		Phase:  phase,
+		Stores: []string{},
+	}
+	hctx.SpecURL = w.Builder.SpecURL
+	for _, s := range w.ArtifactStores {
+		hctx.Stores = append(hctx.Stores, s.SpecURL)
+	}
+	if r != nil {
+		success := r.IsSuccess
+		hctx.Success = &success
+		hctx.Artifacts = r.Artifacts
+	}
+	if att != nil {
+		hctx.Subjects = att.Subject
+	}
+
+	var input []byte
+	for i, h := range w.Options.Hooks {
+		if h.Phase != phase {
+			continue
+		}
+		if input == nil {
+			data, err := json.Marshal(hctx)
+			if err != nil {
+				return fmt.Errorf("marshaling hook context: %w", err)
+			}
+			input = data
+		}
+		if err := runHook(ctx, h, input); err != nil {
+			if h.Optional {
+				logrus.Warnf("Optional %s hook #%d failed: %v", phase, i, err)
+				continue
+			}
+			return fmt.Errorf("running %s hook #%d: %w", phase, i, err)
+		}
+	}
+	return nil
+}
+
+// runHook executes a hook command with the context on stdin. Its
+// output goes to stderr to keep stdout free for the attestation.
+func runHook(ctx context.Context, h config.Hook, input []byte) error {
+	timeout := defaultHookTimeout
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil {
+			return fmt.Errorf("parsing hook timeout: %w", err)
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logrus.Infof("Running %s hook %v", h.Phase, h.Command)
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...) //nolint: gosec
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "TEJOLOTE_HOOK_PHASE="+h.Phase)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("hook timed out after %s: %w", timeout, err)
+		}
+		return err
+	}
+	return nil
+}
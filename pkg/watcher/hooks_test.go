@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New("gcb://my-project/1234")
+	require.NoError(t, err)
+	require.NoError(t, w.AddArtifactSource("file://"+dir))
+
+	out := filepath.Join(t.TempDir(), "hook.json")
+	w.Options.Hooks = []config.Hook{
+		{Phase: config.HookPostSnapshot, Command: []string{"sh", "-c", `cat > "$0"`, out}},
+		{Phase: config.HookPreSign, Command: []string{"false"}},
+	}
+	require.NoError(t, w.Snap(context.Background()))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	hctx := HookContext{}
+	require.NoError(t, json.Unmarshal(data, &hctx))
+	require.Equal(t, config.HookPostSnapshot, hctx.Phase)
+	require.Equal(t, "gcb://my-project/1234", hctx.SpecURL)
+	require.Equal(t, []string{"file://" + dir}, hctx.Stores)
+	require.Nil(t, hctx.Success)
+
+	// Failing hooks abort the phase unless they are optional
+	r := &run.Run{SpecURL: "gcb://my-project/1234", IsSuccess: true}
+	require.Error(t, w.runHooks(context.Background(), config.HookPreSign, r, nil))
+	w.Options.Hooks[1].Optional = true
+	require.NoError(t, w.runHooks(context.Background(), config.HookPreSign, r, nil))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/hostquote"
+)
+
+// HostQuoteMaterialPrefix is the prefix of the URI of the material
+// linking the quote of the host that observed the run. The provider
+// used to quote the host is appended to it.
+const HostQuoteMaterialPrefix = "urn:tejolote:host-quote:"
+
+// QuoteHost captures an attestation quote of the host observing the run
+// and records its digest in the materials of the attestation. The quote
+// nonce is the sha512 digest of the attestation subjects, binding the
+// hardware evidence to the attested artifacts. It returns the serialized
+// quote, which has to be published along with the attestation.
+func (w *Watcher) QuoteHost(ctx context.Context, att *attestation.Attestation, opts hostquote.Options) ([]byte, error) {
+	subjects, err := json.Marshal(att.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling subjects: %w", err)
+	}
+	nonce := sha512.Sum512(subjects)
+	q, err := hostquote.Capture(ctx, opts, nonce[:])
+	if err != nil {
+		return nil, fmt.Errorf("quoting host: %w", err)
+	}
+	data, err := q.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	att.Predicate.AddMaterial(
+		HostQuoteMaterialPrefix+q.Provider,
+		map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256(data))},
+	)
+	return data, nil
+}
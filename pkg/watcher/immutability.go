@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+// VerifyImmutable waits for Options.ImmutabilityDelay and then reads the
+// artifact stores again to check that none of the artifacts recorded in
+// the run changed after the attestation was issued. An artifact that was
+// overwritten or removed after its provenance was generated means the
+// attestation no longer describes what is published.
+func (w *Watcher) VerifyImmutable(ctx context.Context, r *run.Run) error {
+	if w.Options.ImmutabilityDelay == 0 {
+		return nil
+	}
+
+	logrus.Infof("Waiting %s to verify the artifacts did not change", w.Options.ImmutabilityDelay)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(w.Options.ImmutabilityDelay):
+	}
+
+	artifactStores := append([]store.Store{}, w.ArtifactStores...)
+	artifactStores = append(artifactStores, w.Builder.ArtifactStores()...)
+	return checkArtifactsUnchanged(ctx, artifactStores, r.Artifacts)
+}
+
+// checkArtifactsUnchanged reads the stores and compares the digests of
+// the artifacts found against those in the list.
+func checkArtifactsUnchanged(ctx context.Context, artifactStores []store.Store, artifacts []run.Artifact) error {
+	current := map[string]run.Artifact{}
+	for _, s := range artifactStores {
+		storeArtifacts, err := s.ReadArtifacts(ctx)
+		if err != nil {
+			return fmt.Errorf("reading artifacts from %s: %w", s.SpecURL, err)
+		}
+		for _, a := range storeArtifacts {
+			current[a.Path] = a
+		}
+	}
+
+	changed := []string{}
+	for _, a := range artifacts {
+		now, ok := current[a.Path]
+		if !ok {
+			changed = append(changed, a.Path+" (removed)")
+			continue
+		}
+		for algo, val := range a.Checksum {
+			if nowVal, ok := now.Checksum[algo]; ok && nowVal != val {
+				changed = append(changed, a.Path)
+				break
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf(
+			"%d artifacts changed after being attested: %s",
+			len(changed), strings.Join(changed, ", "),
+		)
+	}
+	logrus.Infof("Verified %d artifacts did not change after attestation", len(artifacts))
+	return nil
+}
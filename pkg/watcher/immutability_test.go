@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestCheckArtifactsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.txt"), []byte("original"), os.FileMode(0o644)))
+
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	artifacts, err := s.ReadArtifacts(context.Background())
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+
+	require.NoError(t, checkArtifactsUnchanged(context.Background(), []store.Store{s}, artifacts))
+
+	// Overwrite the artifact
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.txt"), []byte("changed"), os.FileMode(0o644)))
+	require.Error(t, checkArtifactsUnchanged(context.Background(), []store.Store{s}, artifacts))
+
+	// Remove it
+	require.NoError(t, os.Remove(filepath.Join(dir, "test.txt")))
+	require.Error(t, checkArtifactsUnchanged(context.Background(), []store.Store{s}, artifacts))
+}
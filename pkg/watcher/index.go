@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// indexPath returns the path of the on-disk index of a store in
+// Options.IndexDir. Names are derived from the store spec URL so that
+// indexes written by tejolote start are found when attesting.
+func (w *Watcher) indexPath(specURL, set string) string {
+	return filepath.Join(
+		w.Options.IndexDir, fmt.Sprintf("%s-%x.db", set, sha256.Sum256([]byte(specURL))),
+	)
+}
+
+// snapIndex writes the listing of a store to the index of snapshot set n
+func (w *Watcher) snapIndex(ctx context.Context, s *store.Store, n int) error {
+	if err := os.MkdirAll(w.Options.IndexDir, os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating index directory: %w", err)
+	}
+	idx, err := s.SnapIndex(ctx, w.indexPath(s.SpecURL, fmt.Sprintf("snap%d", n)))
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	count, err := idx.Len()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Indexed %d artifacts from %s in %s", count, s.SpecURL, idx.Path)
+	return nil
+}
+
+// preIndex opens the on-disk index of a store taken before the build
+func (w *Watcher) preIndex(specURL string) (*snapshot.Index, bool) {
+	if w.Options.IndexDir == "" {
+		return nil, false
+	}
+	idx, err := snapshot.OpenIndex(w.indexPath(specURL, "snap0"))
+	if err != nil {
+		logrus.Debugf("No pre-build index of %s: %v", specURL, err)
+		return nil, false
+	}
+	return idx, true
+}
+
+// indexDelta indexes a store after the build and streams the delta
+// against its pre-build index. Only the changed artifacts are kept in
+// memory, the stores are not re-listed to settle and the delta is not
+// part of captured runs.
+func (w *Watcher) indexDelta(ctx context.Context, s *store.Store, pre *snapshot.Index) ([]run.Artifact, error) {
+	defer pre.Close()
+	if w.Options.SettlePeriod > 0 {
+		logrus.Warnf("Not waiting for indexed store %s to settle", s.SpecURL)
+	}
+	post, err := s.SnapIndex(ctx, w.indexPath(s.SpecURL, "post"))
+	if err != nil {
+		return nil, fmt.Errorf("indexing store: %w", err)
+	}
+	defer post.Close()
+
+	artifacts := []run.Artifact{}
+	if err := pre.Delta(post, func(a run.Artifact) error {
+		a, err := s.HashArtifact(ctx, a)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, a)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("computing index delta: %w", err)
+	}
+	logrus.Infof("%d artifacts changed in %s since the start index", len(artifacts), s.SpecURL)
+	return artifacts, nil
+}
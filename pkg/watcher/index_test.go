@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestIndexDelta(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), os.FileMode(0o644)))
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	w := &Watcher{
+		ArtifactStores: []store.Store{s},
+		Options:        Options{IndexDir: filepath.Join(t.TempDir(), "index")},
+	}
+	require.NoError(t, w.Snap(context.Background()))
+
+	// Indexed stores are recorded in the set without a snapshot
+	require.Len(t, w.Snapshots, 1)
+	require.Contains(t, w.Snapshots[0], s.SpecURL)
+	require.Nil(t, w.Snapshots[0][s.SpecURL])
+
+	w.Options.RequireEmpty = []string{s.SpecURL}
+	require.Error(t, w.CheckEmptyStores())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), os.FileMode(0o644)))
+	pre, ok := w.preIndex(s.SpecURL)
+	require.True(t, ok)
+	artifacts, err := w.indexDelta(context.Background(), &s, pre)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	require.Equal(t, "new.txt", artifacts[0].Path)
+
+	// Without an index directory, there is no pre-build index
+	w.Options.IndexDir = ""
+	_, ok = w.preIndex(s.SpecURL)
+	require.False(t, ok)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/license"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+// ScanLicenses runs a license scan over the subjects of the attestation
+// and returns a statement with the findings for the same subjects. Only
+// artifacts collected from stores that keep a local copy of their files
+// (directories and buckets) can be scanned, the rest are skipped.
+func (w *Watcher) ScanLicenses(ctx context.Context, att *attestation.Attestation) (*license.Statement, error) {
+	st := license.NewStatement()
+	for _, s := range att.Subject {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		source, ok := w.artifactSources[s.Name]
+		if !ok {
+			continue
+		}
+		localPath, err := source.LocalPath(s.Name)
+		if err != nil {
+			if !errors.Is(err, store.ErrNoLocalCopy) {
+				return nil, fmt.Errorf("locating %s: %w", s.Name, err)
+			}
+			logrus.Debugf("Not scanning licenses of %s: %v", s.Name, err)
+			continue
+		}
+		findings, err := license.ScanFile(localPath, s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("scanning licenses of %s: %w", s.Name, err)
+		}
+		st.AddResult(s.Subject, findings)
+	}
+	logrus.Infof("Scanned licenses of %d artifacts", len(st.Subject))
+	return st, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/builder/driver"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// AddLogMaterials hashes the logs written by the run and records them
+// as materials named after the location where the build system stores
+// them, letting auditors check the logs were not altered. When dir is
+// set, a copy of each log is saved to it.
+func (w *Watcher) AddLogMaterials(ctx context.Context, att *attestation.Attestation, r *run.Run, dir string) error {
+	logs, err := w.Builder.RunLogs(ctx, r)
+	if err != nil {
+		return fmt.Errorf("listing run logs: %w", err)
+	}
+	if dir != "" && len(logs) > 0 {
+		if err := os.MkdirAll(dir, os.FileMode(0o755)); err != nil {
+			return fmt.Errorf("creating logs directory: %w", err)
+		}
+	}
+	for _, l := range logs {
+		path := ""
+		if dir != "" {
+			path = filepath.Join(dir, strings.NewReplacer("/", "-", " ", "-", ":", "-").Replace(l.Name)+".log")
+		}
+		digest, err := w.hashLog(ctx, l, path)
+		if err != nil {
+			return fmt.Errorf("reading log %s: %w", l.Name, err)
+		}
+		att.Predicate.AddMaterial(l.URI, map[string]string{"sha256": digest})
+	}
+	logrus.Infof("Recorded the digests of %d run logs", len(logs))
+	return nil
+}
+
+// hashLog returns the sha256 digest of a run log, saving it to path if
+// not empty
+func (w *Watcher) hashLog(ctx context.Context, l driver.RunLog, path string) (string, error) {
+	h := sha256.New()
+	var out io.Writer = h
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return "", fmt.Errorf("creating log file: %w", err)
+		}
+		defer f.Close()
+		out = io.MultiWriter(h, f)
+	}
+	if err := w.Builder.ReadLog(ctx, l, out); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
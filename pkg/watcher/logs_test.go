@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestAddLogMaterials(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/repo/actions/runs/1/jobs":
+			fmt.Fprint(w, `{"jobs": [{"id": 10, "name": "build / linux"}]}`)
+		case "/repos/org/repo/actions/jobs/10/logs":
+			http.Redirect(w, r, srv.URL+"/blob/10.txt", http.StatusFound)
+		case "/blob/10.txt":
+			fmt.Fprint(w, "build log")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+
+	w, err := New("github://org/repo/1")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	att := attestation.New().SLSA()
+	require.NoError(t, w.AddLogMaterials(context.Background(), att, &run.Run{SpecURL: "github://org/repo/1"}, dir))
+	require.Len(t, att.Predicate.Materials, 1)
+	require.Equal(t, srv.URL+"/repos/org/repo/actions/jobs/10/logs", att.Predicate.Materials[0].URI)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("build log"))), att.Predicate.Materials[0].Digest["sha256"])
+	data, err := os.ReadFile(filepath.Join(dir, "build---linux.log"))
+	require.NoError(t, err)
+	require.Equal(t, "build log", string(data))
+}
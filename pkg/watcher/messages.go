@@ -0,0 +1,411 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+const (
+	// CloudEvents types of the messages tejolote publishes
+	StartEventType  = "dev.sigs.tejolote.attestation.started"
+	FinishEventType = "dev.sigs.tejolote.attestation.finished"
+
+	cloudEventsSpecVersion = "1.0"
+)
+
+// MessageSchemaVersion is the version of the schema of the messages
+// tejolote publishes. It is only bumped on incompatible changes, new
+// optional fields can be added to the current version. Messages without
+// a version are the original version 1 messages. The JSON schemas of
+// each version are in docs/schemas.
+const MessageSchemaVersion = 2
+
+// Types of the attachments carried in start messages
+const (
+	AttachmentAttestation = "attestation"
+	AttachmentSnapshots   = "snapshots"
+
+	// SnapshotStateMediaType is the media type of the storage snapshots
+	SnapshotStateMediaType = "application/vnd.tejolote.snapshot-state+json"
+)
+
+// ErrUnsupportedSchema is returned when decoding a message with a schema
+// version newer than the ones this version of tejolote understands
+var ErrUnsupportedSchema = errors.New("unsupported message schema version")
+
+// StartMessage is published when starting an attestation. It carries
+// the partial attestation and the storage snapshots needed to finish it
+// as typed attachments.
+type StartMessage struct {
+	SchemaVersion int          `json:"schemaVersion,omitempty"`
+	SpecURL       string       `json:"spec"`
+	Artifacts     []string     `json:"artifacts"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+
+	// Version 1 fields, base64 encoded attestation and snapshots and a
+	// comma separated list of the artifact stores. They are read when
+	// decoding old messages and moved to the attachments.
+	Attestation  string `json:"attestation,omitempty"`
+	Snapshots    string `json:"snapshots,omitempty"`
+	ArtifactList string `json:"artifacts_list,omitempty"`
+}
+
+// Attachment is a document sent in a message. The data is base64
+// encoded in the JSON message or, for large documents, uploaded to a
+// bucket and referenced by its URI. The digest lets the consumer verify
+// it was received intact.
+type Attachment struct {
+	Type      string            `json:"type"`
+	MediaType string            `json:"media_type"`
+	Digest    map[string]string `json:"digest"`
+	Data      []byte            `json:"data,omitempty"`
+	URI       string            `json:"uri,omitempty"`
+}
+
+// NewAttachment returns an attachment of the data computing its digest
+func NewAttachment(attachmentType, mediaType string, data []byte) Attachment {
+	return Attachment{
+		Type:      attachmentType,
+		MediaType: mediaType,
+		Digest:    map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256(data))},
+		Data:      data,
+	}
+}
+
+// NewStartMessage returns a start message with the current schema
+// carrying the partial attestation and, if not nil, the snapshots
+func NewStartMessage(specURL string, artifacts []string, att, snapshots []byte) StartMessage {
+	msg := StartMessage{
+		SchemaVersion: MessageSchemaVersion,
+		SpecURL:       specURL,
+		Artifacts:     artifacts,
+		Attachments: []Attachment{
+			NewAttachment(AttachmentAttestation, "application/vnd.in-toto+json", att),
+		},
+	}
+	if snapshots != nil {
+		msg.Attachments = append(msg.Attachments, NewAttachment(AttachmentSnapshots, SnapshotStateMediaType, snapshots))
+	}
+	return msg
+}
+
+// Attachment returns the data of the first attachment of the type
+func (msg *StartMessage) Attachment(attachmentType string) ([]byte, bool) {
+	for _, a := range msg.Attachments {
+		if a.Type == attachmentType {
+			return a.Data, true
+		}
+	}
+	return nil, false
+}
+
+// upgrade converts a version 1 message to the current schema
+func (msg *StartMessage) upgrade() error {
+	for _, field := range []struct {
+		attachmentType, mediaType, encoded string
+	}{
+		{AttachmentAttestation, "application/vnd.in-toto+json", msg.Attestation},
+		{AttachmentSnapshots, SnapshotStateMediaType, msg.Snapshots},
+	} {
+		if field.encoded == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(field.encoded)
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", field.attachmentType, err)
+		}
+		msg.Attachments = append(msg.Attachments, NewAttachment(field.attachmentType, field.mediaType, data))
+	}
+	if len(msg.Artifacts) == 0 && msg.ArtifactList != "" {
+		msg.Artifacts = strings.Split(msg.ArtifactList, ",")
+	}
+	msg.Attestation, msg.Snapshots, msg.ArtifactList = "", "", ""
+	msg.SchemaVersion = MessageSchemaVersion
+	return nil
+}
+
+// referenceAttachments uploads the snapshots attached to the message to
+// the snapshots location, replacing their data with the object URI. The
+// objects are named after the sha256 of their contents.
+func (w *Watcher) referenceAttachments(ctx context.Context, msg *StartMessage) error {
+	if w.Options.SnapshotsLocation == "" {
+		return nil
+	}
+	u, err := url.Parse(w.Options.SnapshotsLocation)
+	if err != nil {
+		return fmt.Errorf("parsing snapshots location: %w", err)
+	}
+	if u.Scheme != "gs" && u.Scheme != "s3" {
+		return errors.New("snapshots location must be a gs:// or s3:// url")
+	}
+	// Copy the list to leave the message of the caller untouched
+	msg.Attachments = append([]Attachment{}, msg.Attachments...)
+	for i, a := range msg.Attachments {
+		if a.Type != AttachmentSnapshots || a.Data == nil {
+			continue
+		}
+		uri := fmt.Sprintf(
+			"%s://%s/%s", u.Scheme, u.Hostname(),
+			strings.TrimPrefix(path.Join(u.Path, a.Digest["sha256"]+".json"), "/"),
+		)
+		if err := driver.UploadURL(ctx, uri, bytes.NewReader(a.Data)); err != nil {
+			return fmt.Errorf("uploading snapshots: %w", err)
+		}
+		logrus.Infof("Snapshots (%d bytes) uploaded to %s", len(a.Data), uri)
+		msg.Attachments[i].URI = uri
+		msg.Attachments[i].Data = nil
+	}
+	return nil
+}
+
+// fetch downloads the data of the attachments sent by reference
+func (msg *StartMessage) fetch(ctx context.Context) error {
+	for i, a := range msg.Attachments {
+		if a.URI == "" || a.Data != nil {
+			continue
+		}
+		u, err := url.Parse(a.URI)
+		if err != nil {
+			return fmt.Errorf("parsing %s attachment uri: %w", a.Type, err)
+		}
+		// Only fetch from buckets, messages must not point the
+		// worker to local files or arbitrary endpoints
+		if u.Scheme != "gs" && u.Scheme != "s3" {
+			return fmt.Errorf("unsupported location of %s attachment: %s", a.Type, a.URI)
+		}
+		var b bytes.Buffer
+		if err := driver.DownloadURL(ctx, a.URI, &b); err != nil {
+			return fmt.Errorf("downloading %s attachment: %w", a.Type, err)
+		}
+		msg.Attachments[i].Data = b.Bytes()
+	}
+	return nil
+}
+
+// verify checks the digests of the attachments
+func (msg *StartMessage) verify() error {
+	for _, a := range msg.Attachments {
+		expected, ok := a.Digest["sha256"]
+		if !ok {
+			return fmt.Errorf("%s attachment has no sha256 digest", a.Type)
+		}
+		if got := fmt.Sprintf("%x", sha256.Sum256(a.Data)); got != expected {
+			return fmt.Errorf("%s attachment digest mismatch (expected %s got %s)", a.Type, expected, got)
+		}
+	}
+	return nil
+}
+
+// FinishMessage is published when an attestation has been completed.
+// It lets downstream systems trigger on provenance availability without
+// fetching the attestation first.
+type FinishMessage struct {
+	SchemaVersion   int                   `json:"schemaVersion"`
+	SpecURL         string                `json:"spec"`
+	Digest          string                `json:"digest"`
+	Subjects        []attestation.Subject `json:"subjects"`
+	SigningIdentity string                `json:"signing_identity,omitempty"`
+	RekorLogIndex   *int64                `json:"rekor_log_index,omitempty"`
+	Timestamp       []byte                `json:"rfc3161_timestamp,omitempty"`
+}
+
+// NewFinishMessage builds the message announcing a completed attestation.
+// data is the attestation as written out, the digest of the message is
+// computed from it. When the attestation was signed, sig records the
+// identity in the signing certificate and the transparency log entry.
+func (w *Watcher) NewFinishMessage(att *attestation.Attestation, data []byte, sig *attestation.BlobSignature) FinishMessage {
+	message := FinishMessage{
+		SchemaVersion: MessageSchemaVersion,
+		SpecURL:       w.Builder.SpecURL,
+		Digest:        fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Subjects:      att.Subject,
+	}
+	if sig == nil {
+		return message
+	}
+	message.RekorLogIndex = sig.LogIndex
+	message.Timestamp = sig.Timestamp
+	if sig.Certificate != nil {
+		identity, err := attestation.SigningIdentity(sig.Certificate)
+		if err != nil {
+			logrus.Warnf("reading signing identity: %v", err)
+		}
+		message.SigningIdentity = identity
+	}
+	return message
+}
+
+// CloudEvent is a CloudEvents 1.0 envelope in structured JSON mode
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// wrapCloudEvent wraps the message data in a CloudEvents envelope. The
+// event source is the build system (eg gcb://project) and the subject
+// is the full spec URL of the run, which lets routers filter events
+// by builder or run without looking into the data.
+func (w *Watcher) wrapCloudEvent(eventType string, data []byte) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating event id: %w", err)
+	}
+
+	source := "tejolote"
+	if u, err := url.Parse(w.Builder.SpecURL); err == nil && u.Scheme != "" {
+		source = fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	}
+
+	event := CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              hex.EncodeToString(id),
+		Source:          source,
+		Type:            eventType,
+		Subject:         w.Builder.SpecURL,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	return json.Marshal(event)
+}
+
+// ErrNotStartMessage is returned when decoding a message that is not
+// a tejolote start message
+var ErrNotStartMessage = errors.New("message is not a start message")
+
+// DecodeStartMessage decodes the data of a received message into a
+// StartMessage. It unwraps CloudEvents envelopes and resolves claim
+// checks when the message payload was uploaded to a bucket. Messages of
+// older schema versions are upgraded to the current one.
+func DecodeStartMessage(ctx context.Context, data []byte) (*StartMessage, error) {
+	data, err := ResolveClaimCheck(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("resolving claim check: %w", err)
+	}
+
+	event := CloudEvent{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("unmarshalling message: %w", err)
+	}
+	if event.SpecVersion != "" {
+		if event.Type != StartEventType {
+			return nil, ErrNotStartMessage
+		}
+		data, err = ResolveClaimCheck(ctx, event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("resolving claim check: %w", err)
+		}
+	}
+
+	// Plain finish messages share the spec field, they are told apart
+	// by the attestation digest only they carry
+	finish := FinishMessage{}
+	if err := json.Unmarshal(data, &finish); err == nil && finish.Digest != "" {
+		return nil, ErrNotStartMessage
+	}
+
+	msg := &StartMessage{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("unmarshalling start message: %w", err)
+	}
+	if msg.SpecURL == "" {
+		return nil, ErrNotStartMessage
+	}
+	switch {
+	case msg.SchemaVersion <= 1:
+		if err := msg.upgrade(); err != nil {
+			return nil, fmt.Errorf("upgrading version 1 message: %w", err)
+		}
+	case msg.SchemaVersion > MessageSchemaVersion:
+		return nil, fmt.Errorf(
+			"%w: message has version %d, this version of tejolote reads up to %d",
+			ErrUnsupportedSchema, msg.SchemaVersion, MessageSchemaVersion,
+		)
+	}
+	if err := msg.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if err := msg.verify(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ErrBuildNotFinished is returned when decoding a Cloud Build
+// notification of a build that did not succeed (yet)
+var ErrBuildNotFinished = errors.New("build has not finished successfully")
+
+// BuildNotification is a build status update published by Cloud Build
+// to the cloud-builds Pub/Sub topic
+type BuildNotification struct {
+	ProjectID string `json:"projectId"`
+	BuildID   string `json:"id"`
+	Status    string `json:"status"`
+}
+
+// SpecURL returns the spec URL of the notified build
+func (n *BuildNotification) SpecURL() string {
+	return fmt.Sprintf("gcb://%s/%s", n.ProjectID, n.BuildID)
+}
+
+// DecodeBuildNotification decodes a Cloud Build notification. The
+// message data is the build resource and its attributes carry the build
+// ID and status. Notifications of builds not reaching SUCCESS return
+// ErrBuildNotFinished.
+func DecodeBuildNotification(attributes map[string]string, data []byte) (*BuildNotification, error) {
+	n := &BuildNotification{}
+	if err := json.Unmarshal(data, n); err != nil {
+		return nil, fmt.Errorf("unmarshalling build notification: %w", err)
+	}
+	if n.BuildID == "" {
+		n.BuildID = attributes["buildId"]
+	}
+	if n.Status == "" {
+		n.Status = attributes["status"]
+	}
+	if n.ProjectID == "" || n.BuildID == "" {
+		return nil, errors.New("message is not a Cloud Build notification")
+	}
+	if n.Status != "SUCCESS" {
+		return n, fmt.Errorf("build %s is %s: %w", n.BuildID, n.Status, ErrBuildNotFinished)
+	}
+	return n, nil
+}
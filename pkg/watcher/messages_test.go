@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/builder"
+)
+
+func TestWrapCloudEvent(t *testing.T) {
+	w := &Watcher{
+		Builder: builder.Builder{SpecURL: "gcb://my-project/3190d867"},
+	}
+	data, err := w.wrapCloudEvent(StartEventType, []byte(`{"spec":"gcb://my-project/3190d867"}`))
+	require.NoError(t, err)
+
+	event := CloudEvent{}
+	require.NoError(t, json.Unmarshal(data, &event))
+	require.Equal(t, "1.0", event.SpecVersion)
+	require.Equal(t, StartEventType, event.Type)
+	require.Equal(t, "gcb://my-project", event.Source)
+	require.Equal(t, "gcb://my-project/3190d867", event.Subject)
+	require.Len(t, event.ID, 32)
+	require.JSONEq(t, `{"spec":"gcb://my-project/3190d867"}`, string(event.Data))
+}
+
+func TestNewFinishMessage(t *testing.T) {
+	w := &Watcher{
+		Builder: builder.Builder{SpecURL: "gcb://my-project/3190d867"},
+	}
+	att := attestation.New().SLSA()
+	att.AddSubjects(intoto.Subject{Name: "bin", Digest: map[string]string{"sha256": "abc"}})
+	data := []byte(`{"payload":""}`)
+
+	// Unsigned attestations only carry the digest and subjects
+	msg := w.NewFinishMessage(att, data, nil)
+	require.Equal(t, "gcb://my-project/3190d867", msg.SpecURL)
+	require.Equal(t, fmt.Sprintf("sha256:%x", sha256.Sum256(data)), msg.Digest)
+	require.Len(t, msg.Subjects, 1)
+	require.Equal(t, "bin", msg.Subjects[0].Name)
+	require.Empty(t, msg.SigningIdentity)
+	require.Nil(t, msg.RekorLogIndex)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		EmailAddresses: []string{"builder@example.com"},
+	}, &x509.Certificate{SerialNumber: big.NewInt(1)}, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	logIndex := int64(42)
+	msg = w.NewFinishMessage(att, data, &attestation.BlobSignature{
+		Signature:   data,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		LogIndex:    &logIndex,
+	})
+	require.Equal(t, "builder@example.com", msg.SigningIdentity)
+	require.Equal(t, &logIndex, msg.RekorLogIndex)
+
+	// Workers sharing the topic must not take it for a start message
+	data, err = json.Marshal(msg)
+	require.NoError(t, err)
+	_, err = DecodeStartMessage(context.Background(), data)
+	require.ErrorIs(t, err, ErrNotStartMessage)
+}
+
+func TestDecodeBuildNotification(t *testing.T) {
+	data := []byte(`{"id": "3190d867", "projectId": "example-project", "status": "WORKING"}`)
+	n, err := DecodeBuildNotification(map[string]string{"buildId": "3190d867", "status": "WORKING"}, data)
+	require.ErrorIs(t, err, ErrBuildNotFinished)
+	require.Equal(t, "WORKING", n.Status)
+
+	n, err = DecodeBuildNotification(
+		map[string]string{"buildId": "3190d867", "status": "SUCCESS"},
+		[]byte(`{"projectId": "example-project"}`),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "gcb://example-project/3190d867", n.SpecURL())
+
+	_, err = DecodeBuildNotification(map[string]string{}, []byte(`{"spec": "gcb://example-project/3190d867"}`))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrBuildNotFinished)
+}
+
+func TestDecodeStartMessage(t *testing.T) {
+	ctx := context.Background()
+	att := []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)
+	snaps := []byte(`{"version":2}`)
+
+	// Current messages carry the documents as attachments
+	data, err := json.Marshal(NewStartMessage("gcb://project/build", []string{"gs://bucket/path"}, att, snaps))
+	require.NoError(t, err)
+	msg, err := DecodeStartMessage(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, MessageSchemaVersion, msg.SchemaVersion)
+	require.Equal(t, []string{"gs://bucket/path"}, msg.Artifacts)
+	got, ok := msg.Attachment(AttachmentAttestation)
+	require.True(t, ok)
+	require.Equal(t, att, got)
+	got, ok = msg.Attachment(AttachmentSnapshots)
+	require.True(t, ok)
+	require.Equal(t, snaps, got)
+
+	// Version 1 messages are upgraded
+	msg, err = DecodeStartMessage(ctx, []byte(fmt.Sprintf(
+		`{"spec":"gcb://project/build","attestation":%q,"snapshots":%q,"artifacts_list":"gs://a,gs://b"}`,
+		base64.StdEncoding.EncodeToString(att), base64.StdEncoding.EncodeToString(snaps),
+	)))
+	require.NoError(t, err)
+	require.Equal(t, MessageSchemaVersion, msg.SchemaVersion)
+	require.Equal(t, []string{"gs://a", "gs://b"}, msg.Artifacts)
+	got, ok = msg.Attachment(AttachmentAttestation)
+	require.True(t, ok)
+	require.Equal(t, att, got)
+	require.Empty(t, msg.Attestation)
+
+	// Newer schemas are not guessed
+	_, err = DecodeStartMessage(ctx, []byte(`{"schemaVersion":99,"spec":"gcb://project/build"}`))
+	require.ErrorIs(t, err, ErrUnsupportedSchema)
+
+	// Attachments are verified
+	tampered := NewStartMessage("gcb://project/build", nil, att, nil)
+	tampered.Attachments[0].Data = []byte("{}")
+	data, err = json.Marshal(tampered)
+	require.NoError(t, err)
+	_, err = DecodeStartMessage(ctx, data)
+	require.ErrorContains(t, err, "digest mismatch")
+}
+
+func FuzzDecodeStartMessage(f *testing.F) {
+	f.Add([]byte(`{"spec":"gcb://project/build","attestation":"e30=","artifacts":["gs://bucket/path"]}`))
+	f.Add([]byte(`{"spec":"gcb://project/build","artifact_list":"gs://a,gs://b"}`))
+	f.Add([]byte(`{"schemaVersion":2,"spec":"gcb://p/b","attachments":[{"type":"attestation","digest":{"sha256":"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},"data":"e30="}]}`))
+	f.Add([]byte(`{"specversion":"1.0","type":"dev.sigs.tejolote.attestation.started","data":{"spec":"gcb://p/b"}}`))
+	f.Add([]byte(`{"specversion":"1.0","type":"other"}`))
+	f.Add([]byte(`{"claim_check":{"uri":"https://example.com/payload"}}`))
+	f.Add([]byte(`null`))
+
+	// Cancelled so that claim checks never reach the network
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := DecodeStartMessage(ctx, data)
+		if err != nil {
+			return
+		}
+		if msg.SpecURL == "" {
+			t.Errorf("decoded start message without spec url from %q", data)
+		}
+	})
+}
+
+func TestSnapshotsByReference(t *testing.T) {
+	objects := fakeS3(t)
+	w, err := New("gcb://project/build")
+	require.NoError(t, err)
+	w.Options.SnapshotsLocation = "s3://bucket/snapshots"
+
+	snapshots := []byte(`{"version":2}`)
+	msg := NewStartMessage("gcb://project/build", nil, []byte("{}"), snapshots)
+	sent := msg
+	require.NoError(t, w.referenceAttachments(context.Background(), &sent))
+	require.Len(t, objects, 1)
+	require.Equal(t, msg.Attachments[1].Data, snapshots, "message of the caller modified")
+	require.Nil(t, sent.Attachments[1].Data)
+	require.Equal(t, "s3://bucket/snapshots/"+sent.Attachments[1].Digest["sha256"]+".json", sent.Attachments[1].URI)
+
+	data, err := json.Marshal(sent)
+	require.NoError(t, err)
+	decoded, err := DecodeStartMessage(context.Background(), data)
+	require.NoError(t, err)
+	got, ok := decoded.Attachment(AttachmentSnapshots)
+	require.True(t, ok)
+	require.Equal(t, snapshots, got)
+
+	// Tampered objects fail the digest check
+	for k := range objects {
+		objects[k] = []byte(`{"version":3}`)
+	}
+	_, err = DecodeStartMessage(context.Background(), data)
+	require.Error(t, err)
+
+	// Attachments can only be fetched from buckets
+	sent.Attachments[1].URI = "file:///etc/passwd"
+	data, err = json.Marshal(sent)
+	require.NoError(t, err)
+	_, err = DecodeStartMessage(context.Background(), data)
+	require.Error(t, err)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/tejolote/pkg/annotator"
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/policy"
+)
+
+// Option configures a Watcher when it is created with New
+type Option func(*Watcher) error
+
+// WithOptions replaces all the options of the watcher. Options passed
+// after it to New are applied on top.
+func WithOptions(opts Options) Option {
+	return func(w *Watcher) error {
+		w.Options = opts
+		return nil
+	}
+}
+
+// WithArtifactStores adds the artifact stores to watch, see
+// Watcher.AddArtifactSource
+func WithArtifactStores(specURLs ...string) Option {
+	return func(w *Watcher) error {
+		for _, u := range specURLs {
+			if err := w.AddArtifactSource(u); err != nil {
+				return fmt.Errorf("adding artifact store %s: %w", u, err)
+			}
+		}
+		return nil
+	}
+}
+
+// WithWaitForBuild sets if the watcher waits for the run to finish
+// before attesting it
+func WithWaitForBuild(wait bool) Option {
+	return func(w *Watcher) error {
+		w.Options.WaitForBuild = wait
+		return nil
+	}
+}
+
+// WithPollInterval sets the initial and maximum interval between run
+// status checks
+func WithPollInterval(initial, maxInterval time.Duration) Option {
+	return func(w *Watcher) error {
+		if initial <= 0 || maxInterval < initial {
+			return fmt.Errorf("invalid poll intervals %s and %s", initial, maxInterval)
+		}
+		w.Options.PollInterval = initial
+		w.Options.MaxPollInterval = maxInterval
+		return nil
+	}
+}
+
+// WithIndexDir keeps on-disk snapshot indexes in dir instead of
+// in-memory snapshots
+func WithIndexDir(dir string) Option {
+	return func(w *Watcher) error {
+		w.Options.IndexDir = dir
+		return nil
+	}
+}
+
+// WithAnnotators adds annotators run over the collected artifacts
+func WithAnnotators(annotators ...*annotator.Annotator) Option {
+	return func(w *Watcher) error {
+		w.Options.Annotators = append(w.Options.Annotators, annotators...)
+		return nil
+	}
+}
+
+// WithPolicies adds policies the attestation must pass
+func WithPolicies(policies ...*policy.Policy) Option {
+	return func(w *Watcher) error {
+		w.Options.Policies = append(w.Options.Policies, policies...)
+		return nil
+	}
+}
+
+// WithBuilderID records id as the builder.id of the attestations
+func WithBuilderID(id string) Option {
+	return func(w *Watcher) error {
+		w.Options.BuilderID = id
+		return nil
+	}
+}
+
+// WithSigstore signs the attestations with the Sigstore deployment
+// in opts instead of the public good instance
+func WithSigstore(opts attestation.SigstoreOptions) Option {
+	return func(w *Watcher) error {
+		if err := opts.Validate(); err != nil {
+			return fmt.Errorf("configuring sigstore: %w", err)
+		}
+		w.Options.Sigstore = opts
+		return nil
+	}
+}
+
+// WithBuildType records buildType as the build type of the attestations
+func WithBuildType(buildType string) Option {
+	return func(w *Watcher) error {
+		w.Options.BuildType = buildType
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// AnnotationOriginUnreported marks artifacts observed in a location
+// the build system reports on but that it did not report pushing
+const AnnotationOriginUnreported = "origin.unreported"
+
+// CheckArtifactOrigins cross-checks the collected artifacts against the
+// artifacts the build system reports the run pushed. Artifacts observed
+// in a location covered by the build system report but missing from it
+// were likely uploaded outside the observed build, they are annotated
+// and their paths returned. Reported artifacts that were not observed
+// are logged.
+func (w *Watcher) CheckArtifactOrigins(r *run.Run) []string {
+	reportedList, scopes := w.Builder.ArtifactReport(r)
+	reported := map[string]struct{}{}
+	for p := range w.reportedArtifacts {
+		reported[p] = struct{}{}
+	}
+	for _, p := range reportedList {
+		reported[p] = struct{}{}
+	}
+	if len(scopes) == 0 {
+		return []string{}
+	}
+
+	observed := map[string]struct{}{}
+	unreported := []string{}
+	for i := range r.Artifacts {
+		a := &r.Artifacts[i]
+		observed[a.Path] = struct{}{}
+		if digestPath := imageDigestPath(a); digestPath != "" {
+			observed[digestPath] = struct{}{}
+		}
+		if !inScopes(a.Path, scopes) || isReported(a, reported) {
+			continue
+		}
+		logrus.Warnf("Artifact %s was not reported by the build system", a.Path)
+		if a.Annotations == nil {
+			a.Annotations = map[string]string{}
+		}
+		a.Annotations[AnnotationOriginUnreported] = "true"
+		unreported = append(unreported, a.Path)
+	}
+
+	for p := range reported {
+		if _, ok := observed[p]; !ok && inScopes(p, scopes) {
+			logrus.Warnf("Artifact %s reported by the build system was not observed", p)
+		}
+	}
+	sort.Strings(unreported)
+	return unreported
+}
+
+// isReported checks if an artifact is in the reported set. Platform
+// manifests of an image index are reported through the index digest.
+func isReported(a *run.Artifact, reported map[string]struct{}) bool {
+	if _, ok := reported[a.Path]; ok {
+		return true
+	}
+	if index, ok := a.Annotations["oci.index"]; ok {
+		repo, _, _ := strings.Cut(a.Path, "@")
+		_, ok := reported[repo+"@"+index]
+		return ok
+	}
+	return false
+}
+
+// imageDigestPath returns the repo@digest path of an image tag artifact
+func imageDigestPath(a *run.Artifact) string {
+	sum, ok := a.Checksum["sha256"]
+	if !ok || !strings.HasPrefix(a.Path, "oci://") || strings.Contains(a.Path, "@") {
+		return ""
+	}
+	i := strings.LastIndex(a.Path, ":")
+	if i < strings.LastIndex(a.Path, "/") {
+		return ""
+	}
+	return a.Path[:i] + "@sha256:" + sum
+}
+
+func inScopes(path string, scopes []string) bool {
+	for _, s := range scopes {
+		if strings.HasPrefix(path, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/cloudbuild/v1"
+
+	"sigs.k8s.io/tejolote/pkg/builder"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+func TestCheckArtifactOrigins(t *testing.T) {
+	b, err := builder.New("gcb://my-project/1234")
+	require.NoError(t, err)
+	w := &Watcher{Builder: b, reportedArtifacts: map[string]struct{}{"gs://bucket/1234/bin": {}}}
+	index := "sha256:" + strings.Repeat("b", 64)
+	r := &run.Run{
+		SystemData: &cloudbuild.Build{
+			Id: "1234", ProjectId: "my-project",
+			Images:    []string{"gcr.io/my-project/app:v1"},
+			Artifacts: &cloudbuild.Artifacts{Objects: &cloudbuild.ArtifactObjects{Location: "gs://bucket/1234/"}},
+			Results: &cloudbuild.Results{Images: []*cloudbuild.BuiltImage{
+				{Name: "gcr.io/my-project/app:v1", Digest: index},
+			}},
+		},
+		Artifacts: []run.Artifact{
+			{Path: "gs://bucket/1234/bin"},
+			{Path: "gs://bucket/1234/extra"},
+			{Path: "gs://bucket/other/file"},
+			{Path: "oci://gcr.io/my-project/app:v1", Checksum: map[string]string{"sha256": strings.Repeat("b", 64)}},
+			{Path: "oci://gcr.io/my-project/app@sha256:" + strings.Repeat("c", 64), Annotations: map[string]string{"oci.index": index}},
+			{Path: "oci://gcr.io/my-project/app:v2"},
+		},
+	}
+	unreported := w.CheckArtifactOrigins(r)
+	require.Equal(t, []string{"gs://bucket/1234/extra", "oci://gcr.io/my-project/app:v2"}, unreported)
+	require.Equal(t, "true", r.Artifacts[1].Annotations[AnnotationOriginUnreported])
+	require.Nil(t, r.Artifacts[2].Annotations)
+	require.NotContains(t, r.Artifacts[4].Annotations, AnnotationOriginUnreported)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+// Suffixes of the files uploaded by PublishFile
+const (
+	PublishAttestationSuffix = ".intoto.json"
+	PublishSnapshotsSuffix   = ".storage-snap.json"
+)
+
+// IsPublishLocation returns true if the location is a storage location
+// files can be published to (gs://, s3:// or oci://)
+func IsPublishLocation(location string) bool {
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "gs", "s3", "oci":
+		return u.Host != ""
+	default:
+		return false
+	}
+}
+
+// PublishFile uploads the data to a storage location under a content
+// addressed name, its sha256 digest followed by the suffix, and returns
+// its URL. Objects already in the location are not uploaded again.
+func PublishFile(ctx context.Context, location string, data []byte, suffix string) (string, error) {
+	if !IsPublishLocation(location) {
+		return "", fmt.Errorf("%s is not a gs://, s3:// or oci:// location", location)
+	}
+	fileURL := fmt.Sprintf("%s/%x%s", strings.TrimSuffix(location, "/"), sha256.Sum256(data), suffix)
+	exists, err := driver.ExistsURL(ctx, fileURL)
+	if err != nil {
+		return "", fmt.Errorf("checking %s: %w", fileURL, err)
+	}
+	if exists {
+		logrus.Infof("%s is already published", fileURL)
+		return fileURL, nil
+	}
+	if err := driver.UploadURL(ctx, fileURL, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("uploading %s: %w", fileURL, err)
+	}
+	logrus.Infof("published %s", fileURL)
+	return fileURL, nil
+}
+
+// PublishSnapshots uploads the storage snapshot state to a storage
+// location, see PublishFile
+func (w *Watcher) PublishSnapshots(ctx context.Context, location string) (string, error) {
+	if len(w.Snapshots) == 0 {
+		return "", nil
+	}
+	data, err := w.snapshotStateData(ctx)
+	if err != nil {
+		return "", err
+	}
+	return PublishFile(ctx, location, data, PublishSnapshotsSuffix)
+}
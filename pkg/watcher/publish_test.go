@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+func TestPublish(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	location := "oci://" + strings.TrimPrefix(reg.URL, "http://") + "/provenance/"
+
+	att := []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)
+	fileURL, err := PublishFile(context.Background(), location, att, PublishAttestationSuffix)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%s%x.intoto.json", location, sha256.Sum256(att)), fileURL)
+	var b bytes.Buffer
+	require.NoError(t, driver.DownloadURL(context.Background(), fileURL, &b))
+	require.Equal(t, att, b.Bytes())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.txt"), []byte("test"), os.FileMode(0o644)))
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	w := &Watcher{ArtifactStores: []store.Store{s}}
+	stateURL, err := w.PublishSnapshots(context.Background(), location)
+	require.NoError(t, err)
+	require.Empty(t, stateURL)
+	require.NoError(t, w.Snap(context.Background()))
+	stateURL, err = w.PublishSnapshots(context.Background(), location)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(stateURL, PublishSnapshotsSuffix))
+	w2 := &Watcher{ArtifactStores: []store.Store{s}}
+	require.NoError(t, w2.LoadSnapshots(context.Background(), stateURL))
+	require.Len(t, w2.Snapshots, 1)
+
+	_, err = PublishFile(context.Background(), "https://example.com/upload", att, PublishAttestationSuffix)
+	require.Error(t, err)
+	require.True(t, IsPublishLocation("gs://bucket/prefix"))
+	require.False(t, IsPublishLocation("file:///tmp"))
+}
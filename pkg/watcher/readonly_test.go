@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/readonly"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+)
+
+// TestReadOnlyUploads checks that every upload path refuses to write in
+// read-only mode without sending a request
+func TestReadOnlyUploads(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("GITLAB_TOKEN", "token")
+
+	// An artifact synced from a bucket, its signatures go to the bucket
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "binary"), []byte("data"), os.FileMode(0o644)))
+	name := "gs://release-bucket/bin/binary"
+	w := &Watcher{artifactSources: map[string]store.Store{
+		name: {SpecURL: "gs://release-bucket/bin/", Driver: &driver.GCS{Bucket: "release-bucket", WorkDir: dir}},
+	}}
+	att := attestation.New().SLSA()
+	att.AddSubjects(intoto.Subject{
+		Name: name, Digest: map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256([]byte("data")))},
+	})
+	require.NoError(t, w.AnnotateArtifactSignatures(att))
+
+	readonly.Enable()
+	defer readonly.Disable()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name   string
+		upload func() error
+	}{
+		{"gcs", func() error {
+			return driver.UploadURL(ctx, "gs://release-bucket/provenance.json", strings.NewReader("{}"))
+		}},
+		{"s3", func() error {
+			return driver.UploadURL(ctx, "s3://release-bucket/provenance.json", strings.NewReader("{}"))
+		}},
+		{"github release", func() error {
+			return UploadReleaseAssets(ctx, "github://org/repo/v1.0.0", ReleaseAssets("provenance.json", []byte("{}")))
+		}},
+		{"gitlab release", func() error {
+			return UploadReleaseAssets(
+				ctx, "gitlab://"+strings.TrimPrefix(srv.URL, "http://")+"/group/project/-/releases/v1.0.0",
+				ReleaseAssets("provenance.json", []byte("{}")),
+			)
+		}},
+		{"artifact signatures", func() error {
+			return w.SignArtifacts(ctx, att, fakeBlobSigner{})
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, tc.upload(), readonly.ErrReadOnly)
+		})
+	}
+	require.Zero(t, requests.Load())
+	require.NoFileExists(t, filepath.Join(dir, "bin", "binary.sig"))
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+// Subject refresh statuses
+const (
+	SubjectMatch      = "match"      // The artifact digests match the attested ones
+	SubjectChanged    = "changed"    // The artifact digests differ from the attested ones
+	SubjectMissing    = "missing"    // The artifact is not in the stores anymore
+	SubjectUnverified = "unverified" // The stores report no digest algorithm in common
+)
+
+// SubjectRefresh is the result of checking a subject against the
+// current contents of the artifact stores
+type SubjectRefresh struct {
+	Name     string           `json:"name"`
+	Status   string           `json:"status"`
+	Attested common.DigestSet `json:"attested"`
+	Current  common.DigestSet `json:"current,omitempty"`
+}
+
+// RefreshReport lists the status of each subject of an attestation
+type RefreshReport struct {
+	Stores   []string         `json:"stores"`
+	Subjects []SubjectRefresh `json:"subjects"`
+	Counts   map[string]int   `json:"counts"`
+}
+
+// RefreshSubjects re-reads the artifact stores and recomputes the
+// digests of the attestation subjects to check if the published
+// artifacts still match the attested ones. When no store URLs are
+// passed, those recorded in the subject completeness of the predicate
+// are used.
+func RefreshSubjects(ctx context.Context, att *attestation.Attestation, storeURLs []string) (*RefreshReport, error) {
+	if len(storeURLs) == 0 && att.Predicate.SubjectCompleteness != nil {
+		seen := map[string]struct{}{}
+		for _, s := range att.Predicate.SubjectCompleteness.Stores {
+			if _, ok := seen[s.Store]; ok {
+				continue
+			}
+			seen[s.Store] = struct{}{}
+			storeURLs = append(storeURLs, s.Store)
+		}
+	}
+	if len(storeURLs) == 0 {
+		return nil, errors.New("attestation does not record its artifact stores, they need to be specified")
+	}
+
+	current := map[string]common.DigestSet{}
+	for _, specURL := range storeURLs {
+		s, err := store.New(specURL)
+		if err != nil {
+			return nil, fmt.Errorf("creating store for %s: %w", specURL, err)
+		}
+		artifacts, err := s.ReadArtifacts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading artifacts from %s: %w", specURL, err)
+		}
+		for _, a := range artifacts {
+			if _, ok := current[a.Path]; ok {
+				continue
+			}
+			current[a.Path] = common.DigestSet(a.Checksum)
+		}
+	}
+
+	report := &RefreshReport{
+		Stores:   storeURLs,
+		Subjects: []SubjectRefresh{},
+		Counts:   map[string]int{},
+	}
+	for _, sub := range att.Subject {
+		res := SubjectRefresh{Name: sub.Name, Attested: sub.Digest, Status: SubjectMissing}
+		if digests, ok := current[sub.Name]; ok {
+			res.Current = digests
+			res.Status = compareDigests(sub.Digest, digests)
+		}
+		report.Counts[res.Status]++
+		report.Subjects = append(report.Subjects, res)
+	}
+	logrus.Infof(
+		"Refreshed %d subjects from %d stores: %d match, %d changed, %d missing",
+		len(report.Subjects), len(storeURLs), report.Counts[SubjectMatch],
+		report.Counts[SubjectChanged], report.Counts[SubjectMissing],
+	)
+	return report, nil
+}
+
+// compareDigests compares the digests of the algorithms in both sets
+func compareDigests(attested, current common.DigestSet) string {
+	compared := 0
+	for algo, val := range attested {
+		nowVal, ok := current[algo]
+		if !ok {
+			continue
+		}
+		if nowVal != val {
+			return SubjectChanged
+		}
+		compared++
+	}
+	if compared == 0 {
+		return SubjectUnverified
+	}
+	return SubjectMatch
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestRefreshSubjects(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), os.FileMode(0o644)))
+	}
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	artifacts, err := s.ReadArtifacts(context.Background())
+	require.NoError(t, err)
+	require.Len(t, artifacts, 3)
+
+	att := attestation.New().SLSA()
+	for _, a := range artifacts {
+		att.Subject = append(att.Subject, attestation.Subject{
+			Subject: intoto.Subject{Name: a.Path, Digest: a.Checksum},
+		})
+	}
+
+	// Without stores recorded in the predicate they must be passed
+	_, err = RefreshSubjects(context.Background(), att, nil)
+	require.Error(t, err)
+	att.Predicate.SubjectCompleteness = &attestation.SubjectCompleteness{
+		Stores: []attestation.StoreCompleteness{{Store: s.SpecURL}, {Store: s.SpecURL}},
+	}
+
+	report, err := RefreshSubjects(context.Background(), att, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{s.SpecURL}, report.Stores)
+	require.Equal(t, map[string]int{SubjectMatch: 3}, report.Counts)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("changed"), os.FileMode(0o644)))
+	require.NoError(t, os.Remove(filepath.Join(dir, "c.txt")))
+	report, err = RefreshSubjects(context.Background(), att, []string{s.SpecURL})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{SubjectMatch: 1, SubjectChanged: 1, SubjectMissing: 1}, report.Counts)
+	for _, sub := range report.Subjects {
+		switch filepath.Base(sub.Name) {
+		case "b.txt":
+			require.Equal(t, SubjectChanged, sub.Status)
+			require.NotEqual(t, sub.Attested, sub.Current)
+		case "c.txt":
+			require.Equal(t, SubjectMissing, sub.Status)
+		}
+	}
+}
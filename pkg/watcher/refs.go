@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/github"
+)
+
+// RefSubjects returns the subjects describing a git tag and the release
+// object created from it. Refs are specified using the same format as
+// the GitHub release store: github://owner/repo/tag. Tags in a GitHub
+// Enterprise Server instance include its hostname:
+// github://ghe.example.com/owner/repo/tag
+//
+// The tag subject is digested with the commit it points to. The release
+// subject digest is the sha256 of the canonical form of the release
+// object described in github.Release.Digest.
+func RefSubjects(ctx context.Context, specURL string) ([]intoto.Subject, error) {
+	host, owner, repo, tag, err := parseRefURL(specURL)
+	if err != nil {
+		return nil, err
+	}
+	// Without a hostname, refs live in the server of the configured API
+	apiBase := github.ServerAPIURL(host)
+	if host == "" {
+		host = github.ServerHost(apiBase)
+	}
+
+	commit, err := github.TagCommit(ctx, apiBase, owner, repo, tag)
+	if err != nil {
+		return nil, fmt.Errorf("resolving tag: %w", err)
+	}
+
+	subjects := []intoto.Subject{
+		{
+			Name:   fmt.Sprintf("git+https://%s/%s/%s@refs/tags/%s", host, owner, repo, tag),
+			Digest: common.DigestSet{"sha1": commit},
+		},
+	}
+
+	release, err := github.GetRelease(ctx, apiBase, owner, repo, tag)
+	if err != nil {
+		logrus.Warnf("no release object found for tag %s, not adding it as subject: %v", tag, err)
+		return subjects, nil
+	}
+	digest, err := release.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("digesting release: %w", err)
+	}
+	subjects = append(subjects, intoto.Subject{
+		Name:   fmt.Sprintf("https://%s/%s/%s/releases/tag/%s", host, owner, repo, tag),
+		Digest: common.DigestSet{"sha256": digest},
+	})
+	return subjects, nil
+}
+
+// parseRefURL parses a ref spec URL. As owner names cannot contain dots,
+// a hostname with a dot is always a GitHub Enterprise Server.
+func parseRefURL(specURL string) (host, owner, repo, tag string, err error) {
+	u, err := url.Parse(specURL)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("parsing ref spec url: %w", err)
+	}
+	if u.Scheme != "github" {
+		return "", "", "", "", errors.New("only github:// refs are supported")
+	}
+	path := strings.Trim(u.Path, "/")
+	owner = u.Hostname()
+	if strings.Contains(u.Hostname(), ".") {
+		host = u.Host
+		owner, path, _ = strings.Cut(path, "/")
+	}
+	repo, tag, ok := strings.Cut(path, "/")
+	if !ok || owner == "" || repo == "" || tag == "" {
+		return "", "", "", "", fmt.Errorf("unable to find owner/repo/tag in %s", specURL)
+	}
+	return host, owner, repo, tag, nil
+}
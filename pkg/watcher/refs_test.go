@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/github"
+)
+
+func TestRefSubjects(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/org/repo/commits/v1.0.0", "/repos/org/repo/commits/v2.0.0":
+			fmt.Fprintf(w, `{"sha": %q}`, commit)
+		case "/repos/org/repo/releases/tags/v1.0.0":
+			// Fields not in the canonical form do not change the digest
+			downloads++
+			fmt.Fprintf(w, `{"id": 1, "tag_name": "v1.0.0", "body": "notes", "assets": [
+				{"id": 2, "name": "tool.tar.gz", "size": 20, "download_count": %d}
+			]}`, downloads)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+	// Subjects are named after the server of the configured API
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	release := &github.Release{
+		ID: 1, TagName: "v1.0.0", Assets: []github.ReleaseAsset{{ID: 2, Name: "tool.tar.gz", Size: 20}},
+	}
+	releaseDigest, err := release.Digest()
+	require.NoError(t, err)
+
+	subjects, err := RefSubjects(context.Background(), "github://org/repo/v1.0.0")
+	require.NoError(t, err)
+	require.Len(t, subjects, 2)
+	require.Equal(t, "git+https://"+host+"/org/repo@refs/tags/v1.0.0", subjects[0].Name)
+	require.Equal(t, commit, subjects[0].Digest["sha1"])
+	require.Equal(t, "https://"+host+"/org/repo/releases/tag/v1.0.0", subjects[1].Name)
+	require.Equal(t, releaseDigest, subjects[1].Digest["sha256"])
+
+	again, err := RefSubjects(context.Background(), "github://org/repo/v1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, subjects, again)
+
+	// Tags without a release only record the tag
+	subjects, err = RefSubjects(context.Background(), "github://org/repo/v2.0.0")
+	require.NoError(t, err)
+	require.Len(t, subjects, 1)
+
+	for _, spec := range []string{"oci://registry/image:v1.0.0", "github://org/repo", "github://org/repo/v3.0.0"} {
+		_, err := RefSubjects(context.Background(), spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestParseRefURL(t *testing.T) {
+	for _, tc := range []struct {
+		spec                   string
+		host, owner, repo, tag string
+		shouldError            bool
+	}{
+		{spec: "github://org/repo/v1.0.0", owner: "org", repo: "repo", tag: "v1.0.0"},
+		{spec: "github://ghe.example.com/org/repo/v1.0.0", host: "ghe.example.com", owner: "org", repo: "repo", tag: "v1.0.0"},
+		{spec: "github://org/repo", shouldError: true},
+		{spec: "github://ghe.example.com/org/repo", shouldError: true},
+		{spec: "gitlab://gitlab.com/group/project/v1.0.0", shouldError: true},
+	} {
+		host, owner, repo, tag, err := parseRefURL(tc.spec)
+		if tc.shouldError {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, []string{tc.host, tc.owner, tc.repo, tc.tag}, []string{host, owner, repo, tag}, tc.spec)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/gitlab"
+	"sigs.k8s.io/tejolote/pkg/run"
+)
+
+// ReleaseURL returns the release to upload the attestation to. If the
+// release spec URL is empty, it is detected from the tag the run built.
+func (w *Watcher) ReleaseURL(r *run.Run, releaseURL string) (string, error) {
+	if releaseURL != "" {
+		return releaseURL, nil
+	}
+	releaseURL, ok := w.Builder.ReleaseURL(r)
+	if !ok {
+		return "", errors.New("unable to detect the release, the run did not build a tag")
+	}
+	return releaseURL, nil
+}
+
+// ReleaseAssets returns the files to upload to a release: the
+// attestation and a file with its sha256 checksum
+func ReleaseAssets(name string, attestation []byte) map[string][]byte {
+	return map[string][]byte{
+		name:             attestation,
+		name + ".sha256": []byte(fmt.Sprintf("%x  %s\n", sha256.Sum256(attestation), name)),
+	}
+}
+
+// UploadReleaseAssets uploads files as assets of a GitHub or GitLab
+// release. Releases are specified as github://owner/repo/tag or
+// gitlab://host/group/project/-/releases/tag.
+func UploadReleaseAssets(ctx context.Context, releaseURL string, assets map[string][]byte) error {
+	u, err := url.Parse(releaseURL)
+	if err != nil {
+		return fmt.Errorf("parsing release url: %w", err)
+	}
+	var upload func(name string, data []byte) error
+	switch u.Scheme {
+	case "github":
+		repo, tag, ok := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		if !ok || repo == "" || tag == "" {
+			return fmt.Errorf("unable to find repo/tag in %s", releaseURL)
+		}
+		upload = func(name string, data []byte) error {
+			return github.UploadReleaseAsset(ctx, u.Hostname(), repo, tag, name, data)
+		}
+	case "gitlab":
+		project, tag, ok := strings.Cut(strings.Trim(u.Path, "/"), "/-/releases/")
+		if !ok || project == "" || tag == "" {
+			return fmt.Errorf("unable to find project/-/releases/tag in %s", releaseURL)
+		}
+		upload = func(name string, data []byte) error {
+			return gitlab.UploadReleaseAsset(ctx, u.Host, project, tag, name, data)
+		}
+	default:
+		return fmt.Errorf("%s is not a github or gitlab release url", releaseURL)
+	}
+
+	names := []string{}
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := upload(name, assets[name]); err != nil {
+			return fmt.Errorf("uploading %s: %w", name, err)
+		}
+		logrus.Infof("Uploaded %s to release %s", name, releaseURL)
+	}
+	return nil
+}
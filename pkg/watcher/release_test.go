@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadReleaseAssets(t *testing.T) {
+	uploaded := map[string]string{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/org/repo/releases/tags/v1.0.0":
+			fmt.Fprintf(w, `{"id": 1, "upload_url": "%s/uploads/releases/1/assets{?name,label}", "assets": []}`, srv.URL)
+		case r.Method == http.MethodPost && r.URL.Path == "/uploads/releases/1/assets":
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			uploaded[r.URL.Query().Get("name")] = string(data)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+
+	assets := ReleaseAssets("provenance.intoto.json", []byte("{}"))
+	require.NoError(t, UploadReleaseAssets(context.Background(), "github://org/repo/v1.0.0", assets))
+	require.Equal(t, map[string]string{
+		"provenance.intoto.json":        "{}",
+		"provenance.intoto.json.sha256": "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a  provenance.intoto.json\n",
+	}, uploaded)
+
+	require.Error(t, UploadReleaseAssets(context.Background(), "github://org/repo/v2.0.0", assets))
+	require.Error(t, UploadReleaseAssets(context.Background(), "gitlab://gitlab.com/group/project/v1.0.0", assets))
+	require.Error(t, UploadReleaseAssets(context.Background(), "oci://registry/image", assets))
+}
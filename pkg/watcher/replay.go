@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// Names of the files written when capturing a run
+const (
+	CaptureRunFile  = "run.json"
+	CapturePreFile  = "pre.json"
+	CapturePostFile = "post.json"
+)
+
+// CaptureRun writes the data observed from a run to a directory to
+// regenerate its attestation later with ReplayRun. It writes the run
+// as returned by the build system and the snapshot sets of the artifact
+// stores before the build and after collecting the artifacts.
+func (w *Watcher) CaptureRun(r *run.Run, dir string) error {
+	if w.postSnapshots == nil {
+		return errors.New("artifacts have not been collected from the run")
+	}
+	if err := os.MkdirAll(dir, os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating capture directory: %w", err)
+	}
+
+	pre := map[string]*snapshot.Snapshot{}
+	if len(w.Snapshots) > 0 {
+		pre = w.Snapshots[0]
+	}
+
+	for name, data := range map[string]interface{}{
+		CaptureRunFile:  r,
+		CapturePreFile:  pre,
+		CapturePostFile: w.postSnapshots,
+	} {
+		if err := writeJSONFile(filepath.Join(dir, name), data); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	logrus.Infof("Run data captured to %s", dir)
+	return nil
+}
+
+// ReplayRun rebuilds a run from the data written by CaptureRun without
+// querying the build system or the artifact stores. The artifacts of the
+// run are the delta between the pre and post snapshots of each store.
+// If prePath is empty, all artifacts in the post snapshots are new.
+func ReplayRun(runPath, prePath, postPath string) (*Watcher, *run.Run, error) {
+	data, err := os.ReadFile(runPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading run data: %w", err)
+	}
+	spec := struct{ SpecURL string }{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, nil, fmt.Errorf("unmarshaling run data: %w", err)
+	}
+	if spec.SpecURL == "" {
+		return nil, nil, errors.New("captured run has no spec URL")
+	}
+
+	w, err := New(spec.SpecURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating watcher: %w", err)
+	}
+	r, err := w.Builder.DecodeRun(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding captured run: %w", err)
+	}
+
+	pre := map[string]*snapshot.Snapshot{}
+	if prePath != "" {
+		if err := readJSONFile(prePath, &pre); err != nil {
+			return nil, nil, fmt.Errorf("reading pre snapshots: %w", err)
+		}
+	}
+	post := map[string]*snapshot.Snapshot{}
+	if err := readJSONFile(postPath, &post); err != nil {
+		return nil, nil, fmt.Errorf("reading post snapshots: %w", err)
+	}
+
+	r.Artifacts = replayArtifacts(pre, post)
+	logrus.Infof("Replayed run %s with %d artifacts", r.SpecURL, len(r.Artifacts))
+	return w, r, nil
+}
+
+// replayArtifacts computes the artifacts of a run from the snapshot
+// sets. Stores and artifacts are sorted to make the result deterministic.
+func replayArtifacts(pre, post map[string]*snapshot.Snapshot) []run.Artifact {
+	specs := []string{}
+	for spec := range post {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+
+	seen := map[string]struct{}{}
+	artifacts := []run.Artifact{}
+	for _, spec := range specs {
+		if post[spec] == nil {
+			continue
+		}
+		before := &snapshot.Snapshot{}
+		if pre[spec] != nil {
+			before = pre[spec]
+		}
+		delta := before.Delta(post[spec])
+		sort.Slice(delta, func(i, j int) bool { return delta[i].Path < delta[j].Path })
+		for _, a := range delta {
+			if _, ok := seen[a.Path]; ok {
+				continue
+			}
+			seen[a.Path] = struct{}{}
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts
+}
+
+func writeJSONFile(path string, data interface{}) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling data: %w", err)
+	}
+	return os.WriteFile(path, raw, os.FileMode(0o644))
+}
+
+func readJSONFile(path string, data interface{}) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	if err := json.Unmarshal(raw, data); err != nil {
+		return fmt.Errorf("unmarshaling data: %w", err)
+	}
+	return nil
+}
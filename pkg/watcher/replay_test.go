@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/cloudbuild/v1"
+
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+func TestReplayRun(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New("gcb://my-project/1234")
+	require.NoError(t, err)
+	w.Snapshots = []map[string]*snapshot.Snapshot{{
+		"file:///out": {
+			"old": {Path: "/out/old", Checksum: map[string]string{"sha256": "aa"}},
+			"mod": {Path: "/out/mod", Checksum: map[string]string{"sha256": "bb"}},
+		},
+	}}
+	w.postSnapshots = map[string]*snapshot.Snapshot{
+		"file:///out": {
+			"old": {Path: "/out/old", Checksum: map[string]string{"sha256": "aa"}},
+			"mod": {Path: "/out/mod", Checksum: map[string]string{"sha256": "cc"}},
+			"new": {Path: "/out/new", Checksum: map[string]string{"sha256": "dd"}},
+		},
+		"gcb://my-project/1234": {
+			"img": {Path: "gcr.io/my-project/app", Checksum: map[string]string{"sha256": "ee"}},
+		},
+	}
+	r := &run.Run{
+		SpecURL:    "gcb://my-project/1234",
+		IsSuccess:  true,
+		SystemData: &cloudbuild.Build{Id: "1234", ProjectId: "my-project", Substitutions: map[string]string{"COMMIT_SHA": "abc"}},
+	}
+	require.NoError(t, w.CaptureRun(r, dir))
+
+	w2, r2, err := ReplayRun(
+		filepath.Join(dir, CaptureRunFile), filepath.Join(dir, CapturePreFile), filepath.Join(dir, CapturePostFile),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "gcb://my-project/1234", w2.Builder.SpecURL)
+	require.True(t, r2.IsSuccess)
+	build, ok := r2.SystemData.(*cloudbuild.Build)
+	require.True(t, ok)
+	require.Equal(t, "abc", build.Substitutions["COMMIT_SHA"])
+
+	paths := []string{}
+	for _, a := range r2.Artifacts {
+		paths = append(paths, a.Path)
+	}
+	require.Equal(t, []string{"/out/mod", "/out/new", "gcr.io/my-project/app"}, paths)
+
+	// Without the pre snapshots everything is new
+	_, r3, err := ReplayRun(filepath.Join(dir, CaptureRunFile), "", filepath.Join(dir, CapturePostFile))
+	require.NoError(t, err)
+	require.Len(t, r3.Artifacts, 4)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// defaultSettleInterval is the time between store listings while
+// waiting for a store to settle
+const defaultSettleInterval = 10 * time.Second
+
+// settle lists a store again until two consecutive listings match or
+// Options.SettlePeriod runs out. Stores replicated across regions or
+// behind a CDN can lag behind the build and show artifacts after the
+// run finishes. It returns the last snapshot read from the store.
+func (w *Watcher) settle(ctx context.Context, s *store.Store, snap *snapshot.Snapshot) (*snapshot.Snapshot, error) {
+	if w.Options.SettlePeriod <= 0 {
+		return snap, nil
+	}
+	interval := w.Options.SettleInterval
+	if interval <= 0 {
+		interval = defaultSettleInterval
+	}
+
+	deadline := time.Now().Add(w.Options.SettlePeriod)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			logrus.Warnf("%s did not settle within %s, using its last listing", s.SpecURL, w.Options.SettlePeriod)
+			return snap, nil
+		}
+		wait := interval
+		if wait > remaining {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		next, err := s.Snap(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s again: %w", s.SpecURL, err)
+		}
+		if sameSnapshot(snap, next) {
+			logrus.Infof("%s settled with %d artifacts", s.SpecURL, len(*next))
+			return next, nil
+		}
+		logrus.Infof("%s changed since the last listing, waiting for it to settle", s.SpecURL)
+		snap = next
+	}
+}
+
+// sameSnapshot returns true if two snapshots list the same artifacts
+func sameSnapshot(a, b *snapshot.Snapshot) bool {
+	return len(*a) == len(*b) && len(a.Delta(b)) == 0
+}
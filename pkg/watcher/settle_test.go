@@ -0,0 +1,54 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestSettle(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	w := &Watcher{Options: Options{SettlePeriod: 5 * time.Second, SettleInterval: 50 * time.Millisecond}}
+
+	snap, err := s.Snap(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, *snap)
+
+	// An artifact replicated after the first listing
+	time.AfterFunc(20*time.Millisecond, func() {
+		os.WriteFile(filepath.Join(dir, "late.bin"), []byte("late"), os.FileMode(0o644)) //nolint: errcheck
+	})
+	settled, err := w.settle(context.Background(), &s, snap)
+	require.NoError(t, err)
+	require.Len(t, *settled, 1)
+
+	// Disabled settling returns the same snapshot
+	w.Options.SettlePeriod = 0
+	same, err := w.settle(context.Background(), &s, snap)
+	require.NoError(t, err)
+	require.Equal(t, snap, same)
+}
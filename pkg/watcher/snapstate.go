@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/release-utils/version"
+
+	"sigs.k8s.io/tejolote/pkg/output"
+	"sigs.k8s.io/tejolote/pkg/store/driver"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+// SnapshotStateVersion is the version of the snapshot state format
+// written by SaveSnapshots. Files of version 1 are the bare list of
+// snapshot sets written before the format was versioned, they are
+// upgraded when loaded. Version 3 adds the chunked states. Files of
+// newer versions are rejected.
+const SnapshotStateVersion = 3
+
+// SnapshotState is the storage state saved by tejolote start to
+// compute the artifacts delta when attesting
+type SnapshotState struct {
+	// Version of the state format
+	Version int `json:"version"`
+
+	// Generator is the version of tejolote that wrote the state
+	Generator string `json:"generator,omitempty"`
+
+	// Indexed lists the stores snapshotted to on-disk indexes. Their
+	// entries in the snapshot sets are null.
+	Indexed []string `json:"indexed,omitempty"`
+
+	// Snapshots are the snapshot sets, keyed by store spec URL
+	Snapshots []map[string]*snapshot.Snapshot `json:"snapshots"`
+
+	// ChunkLocation is the directory or bucket URL holding the chunks
+	// of a chunked state
+	ChunkLocation string `json:"chunk_location,omitempty"`
+
+	// Chunks replace the snapshot sets in chunked states, listing the
+	// chunks of each store snapshot. Indexed stores have null lists.
+	Chunks []map[string][]SnapshotChunk `json:"chunks,omitempty"`
+}
+
+// IsRemoteState returns true if a snapshot state path is the URL of a
+// remote location instead of a local file
+func IsRemoteState(path string) bool {
+	u, err := url.Parse(path)
+	return err == nil && u.Scheme != "" && u.Scheme != "file" && strings.Contains(path, "://")
+}
+
+// ReadSnapshotState reads the raw snapshot state from a local file or a
+// remote location (gs://, s3:// or oci://). The machine running
+// tejolote start is often not the one running the final attest.
+func ReadSnapshotState(ctx context.Context, path string) ([]byte, error) {
+	if !IsRemoteState(path) {
+		return os.ReadFile(strings.TrimPrefix(path, "file://"))
+	}
+	var b bytes.Buffer
+	if err := driver.DownloadURL(ctx, path, &b); err != nil {
+		return nil, fmt.Errorf("downloading snapshot state: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// WriteSnapshotState writes the raw snapshot state to a local file or a
+// remote location
+func WriteSnapshotState(ctx context.Context, path string, data []byte) error {
+	if !IsRemoteState(path) {
+		_, err := output.WriteFile(strings.TrimPrefix(path, "file://"), data, output.OverwriteAlways)
+		return err
+	}
+	if err := driver.UploadURL(ctx, path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("uploading snapshot state: %w", err)
+	}
+	return nil
+}
+
+// snapshotState returns the state of the watcher's snapshots
+func (w *Watcher) snapshotState() *SnapshotState {
+	state := &SnapshotState{
+		Version:   SnapshotStateVersion,
+		Generator: version.GetVersionInfo().GitVersion,
+		Snapshots: w.Snapshots,
+	}
+	if w.Options.IndexDir != "" && len(w.Snapshots) > 0 {
+		for specURL, snap := range w.Snapshots[0] {
+			if snap == nil {
+				state.Indexed = append(state.Indexed, specURL)
+			}
+		}
+		sort.Strings(state.Indexed)
+	}
+	return state
+}
+
+// decodeSnapshotState parses a saved snapshot state, upgrading the
+// unversioned format
+func decodeSnapshotState(data []byte) (*SnapshotState, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		sets := []map[string]*snapshot.Snapshot{}
+		if err := json.Unmarshal(data, &sets); err != nil {
+			return nil, fmt.Errorf("unmarshaling version 1 snapshot data: %w", err)
+		}
+		logrus.Warn("Upgrading unversioned (version 1) snapshot state")
+		return &SnapshotState{Version: 1, Snapshots: sets}, nil
+	}
+
+	state := &SnapshotState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unmarshaling snapshot data: %w", err)
+	}
+	switch {
+	case state.Version == 0:
+		return nil, errors.New("snapshot state has no format version")
+	case state.Version > SnapshotStateVersion:
+		generator := "a newer tejolote"
+		if state.Generator != "" {
+			generator = "tejolote " + state.Generator
+		}
+		return nil, fmt.Errorf(
+			"snapshot state format version %d written by %s is not supported, this version reads up to %d",
+			state.Version, generator, SnapshotStateVersion,
+		)
+	}
+	return state, nil
+}
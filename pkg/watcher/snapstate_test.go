@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestSnapshotState(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	w := &Watcher{ArtifactStores: []store.Store{s}}
+	require.NoError(t, w.Snap(context.Background()))
+
+	// Saved states are versioned
+	path := filepath.Join(t.TempDir(), "state.storage-snap.json")
+	require.NoError(t, w.SaveSnapshots(context.Background(), path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	state := SnapshotState{}
+	require.NoError(t, json.Unmarshal(data, &state))
+	require.Equal(t, SnapshotStateVersion, state.Version)
+	require.NoError(t, w.LoadSnapshots(context.Background(), path))
+
+	// Unversioned states are upgraded
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`[{%q: {}}]`, s.SpecURL)), os.FileMode(0o644)))
+	require.NoError(t, w.LoadSnapshots(context.Background(), path))
+	require.Len(t, w.Snapshots, 1)
+
+	for _, tc := range []string{
+		// Missing version
+		fmt.Sprintf(`{"snapshots": [{%q: {}}]}`, s.SpecURL),
+		// Written by a newer version
+		fmt.Sprintf(`{"version": %d, "generator": "v9.0.0", "snapshots": [{%q: {}}]}`, SnapshotStateVersion+1, s.SpecURL),
+		// Indexed stores without an index directory
+		fmt.Sprintf(`{"version": 2, "indexed": [%q], "snapshots": [{%q: null}]}`, s.SpecURL, s.SpecURL),
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(tc), os.FileMode(0o644)))
+		require.Error(t, w.LoadSnapshots(context.Background(), path), tc)
+	}
+
+	// Indexed stores are recorded in the state
+	w.Snapshots = nil
+	w.Options.IndexDir = filepath.Join(t.TempDir(), "index")
+	require.NoError(t, w.Snap(context.Background()))
+	require.NoError(t, w.SaveSnapshots(context.Background(), path))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	state = SnapshotState{}
+	require.NoError(t, json.Unmarshal(data, &state))
+	require.Equal(t, []string{s.SpecURL}, state.Indexed)
+	require.NoError(t, w.LoadSnapshots(context.Background(), path))
+}
+
+func TestRemoteSnapshotState(t *testing.T) {
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer reg.Close()
+	stateURL := "oci://" + strings.TrimPrefix(reg.URL, "http://") + "/tejolote/state:run-1"
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.txt"), []byte("test"), os.FileMode(0o644)))
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+	w := &Watcher{ArtifactStores: []store.Store{s}}
+	require.NoError(t, w.Snap(context.Background()))
+	require.NoError(t, w.SaveSnapshots(context.Background(), stateURL))
+
+	w2 := &Watcher{ArtifactStores: []store.Store{s}}
+	require.NoError(t, w2.LoadSnapshots(context.Background(), stateURL))
+	require.Len(t, w2.Snapshots, 1)
+	require.Len(t, *w2.Snapshots[0][s.SpecURL], 1)
+	require.Empty(t, w.Snapshots[0][s.SpecURL].Delta(w2.Snapshots[0][s.SpecURL]))
+
+	stores, err := SnapshotStores(context.Background(), stateURL)
+	require.NoError(t, err)
+	require.Equal(t, []string{s.SpecURL}, stores)
+
+	require.True(t, IsRemoteState("gs://bucket/state.json"))
+	require.False(t, IsRemoteState("file:///tmp/state.json"))
+	require.False(t, IsRemoteState("state.json"))
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+const (
+	sourceDateEpochVar = "SOURCE_DATE_EPOCH"
+
+	// Subject annotations recording the SOURCE_DATE_EPOCH of the build
+	// and whether the timestamps in the artifact are clamped to it
+	AnnotationSourceDateEpoch        = "source_date_epoch"
+	AnnotationSourceDateEpochHonored = "source_date_epoch.honored"
+)
+
+// SourceDateEpoch returns the SOURCE_DATE_EPOCH defined in the
+// environment of the run steps, if the build system exposes it.
+func SourceDateEpoch(r *run.Run) (string, bool) {
+	epoch := ""
+	for _, s := range r.Steps {
+		v, ok := s.Environment[sourceDateEpochVar]
+		if !ok {
+			continue
+		}
+		if epoch != "" && v != epoch {
+			logrus.Warnf("Build steps set different values of %s (%s, %s)", sourceDateEpochVar, epoch, v)
+			continue
+		}
+		epoch = v
+	}
+	return epoch, epoch != ""
+}
+
+// CheckSourceDateEpoch records the SOURCE_DATE_EPOCH of the build in the
+// attestation subjects. If epoch is empty, it is read from the build
+// steps environment. Archives with a local copy (tarballs, zip files,
+// wheels and jars) are inspected to check that none of their entries
+// is newer than the epoch, the result is recorded in each subject for
+// reproducibility audits and policy checks.
+func (w *Watcher) CheckSourceDateEpoch(ctx context.Context, att *attestation.Attestation, r *run.Run, epoch string) error {
+	if epoch == "" {
+		var ok bool
+		if epoch, ok = SourceDateEpoch(r); !ok {
+			logrus.Infof("The build does not define %s", sourceDateEpochVar)
+			return nil
+		}
+	}
+	secs, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parsing %s %q: %w", sourceDateEpochVar, epoch, err)
+	}
+	clamp := time.Unix(secs, 0)
+
+	honored, checked := 0, 0
+	for i := range att.Subject {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := &att.Subject[i]
+		if s.Annotations == nil {
+			s.Annotations = map[string]string{}
+		}
+		s.Annotations[AnnotationSourceDateEpoch] = epoch
+
+		source, ok := w.artifactSources[s.Name]
+		if !ok {
+			continue
+		}
+		localPath, err := source.LocalPath(s.Name)
+		if err != nil {
+			if !errors.Is(err, store.ErrNoLocalCopy) {
+				return fmt.Errorf("locating %s: %w", s.Name, err)
+			}
+			continue
+		}
+		ok, err = clampedArchive(localPath, s.Name, clamp)
+		if err != nil {
+			if errors.Is(err, errNotArchive) {
+				continue
+			}
+			return fmt.Errorf("checking timestamps of %s: %w", s.Name, err)
+		}
+		checked++
+		if ok {
+			honored++
+		}
+		s.Annotations[AnnotationSourceDateEpochHonored] = strconv.FormatBool(ok)
+	}
+	logrus.Infof(
+		"%s=%s honored in %d of %d archives checked", sourceDateEpochVar, epoch, honored, checked,
+	)
+	return nil
+}
+
+var errNotArchive = errors.New("artifact is not an archive")
+
+// clampedArchive returns true if no entry in the archive is newer than
+// the clamp time. Zip files store times with two second precision.
+func clampedArchive(localPath, name string, clamp time.Time) (bool, error) {
+	lname := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lname, ".tar.gz"), strings.HasSuffix(lname, ".tgz"), strings.HasSuffix(lname, ".tar"):
+		f, err := os.Open(localPath)
+		if err != nil {
+			return false, fmt.Errorf("opening archive: %w", err)
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if !strings.HasSuffix(lname, ".tar") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return false, fmt.Errorf("opening gzip stream: %w", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return true, nil
+			}
+			if err != nil {
+				return false, fmt.Errorf("reading tar archive: %w", err)
+			}
+			if hdr.ModTime.After(clamp) {
+				return false, nil
+			}
+		}
+	case strings.HasSuffix(lname, ".zip"), strings.HasSuffix(lname, ".whl"), strings.HasSuffix(lname, ".jar"):
+		zr, err := zip.OpenReader(localPath)
+		if err != nil {
+			return false, fmt.Errorf("opening zip archive: %w", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.Modified.After(clamp.Add(2 * time.Second)) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, errNotArchive
+}
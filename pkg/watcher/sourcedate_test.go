@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+)
+
+func TestCheckSourceDateEpoch(t *testing.T) {
+	dir := t.TempDir()
+	epoch := time.Unix(1700000000, 0)
+	for name, mtime := range map[string]time.Time{
+		"clamped.tar":   epoch,
+		"unclamped.tar": epoch.Add(time.Hour),
+	} {
+		f, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+		tw := tar.NewWriter(f)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "file", Mode: 0o644, Size: 4, ModTime: mtime}))
+		_, err = tw.Write([]byte("data"))
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		require.NoError(t, f.Close())
+	}
+	s, err := store.New("file://" + dir)
+	require.NoError(t, err)
+
+	w := &Watcher{artifactSources: map[string]store.Store{"clamped.tar": s, "unclamped.tar": s}}
+	att := attestation.New().SLSA()
+	att.AddSubjects(
+		intoto.Subject{Name: "clamped.tar", Digest: map[string]string{"sha256": "abc"}},
+		intoto.Subject{Name: "unclamped.tar", Digest: map[string]string{"sha256": "def"}},
+	)
+	r := &run.Run{Steps: []run.Step{
+		{Environment: map[string]string{}},
+		{Environment: map[string]string{"SOURCE_DATE_EPOCH": "1700000000"}},
+	}}
+	require.NoError(t, w.CheckSourceDateEpoch(context.Background(), att, r, ""))
+	require.Equal(t, "1700000000", att.Subject[0].Annotations[AnnotationSourceDateEpoch])
+	require.Equal(t, "true", att.Subject[0].Annotations[AnnotationSourceDateEpochHonored])
+	require.Equal(t, "false", att.Subject[1].Annotations[AnnotationSourceDateEpochHonored])
+
+	// Builds without SOURCE_DATE_EPOCH are not annotated
+	att = attestation.New().SLSA()
+	att.AddSubjects(intoto.Subject{Name: "clamped.tar", Digest: map[string]string{"sha256": "abc"}})
+	require.NoError(t, w.CheckSourceDateEpoch(context.Background(), att, &run.Run{}, ""))
+	require.Nil(t, att.Subject[0].Annotations)
+
+	require.Error(t, w.CheckSourceDateEpoch(context.Background(), att, &run.Run{}, "yesterday"))
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	"sigs.k8s.io/tejolote/pkg/git"
+	"sigs.k8s.io/tejolote/pkg/github"
+	"sigs.k8s.io/tejolote/pkg/gomod"
+)
+
+// AddSourceMaterials records the source inputs pinned by a local
+// checkout of the built repository as materials: the Git LFS objects,
+// whose pointer files tracked in git do not pin their contents, the
+// submodule commits and, if vendor is true, a digest of the vendor
+// directory. Materials are named after the main VCS URL if set,
+// otherwise after the origin remote of the checkout.
+func (w *Watcher) AddSourceMaterials(att *attestation.Attestation, repoDir string, vendor bool) error {
+	repo, err := git.NewRepository(repoDir)
+	if err != nil {
+		return fmt.Errorf("opening repository: %w", err)
+	}
+	commit, err := repo.HeadCommitSHA()
+	if err != nil {
+		return fmt.Errorf("reading checkout commit: %w", err)
+	}
+
+	mainVCSURL := ""
+	if len(w.Builder.VCSURLs) > 0 {
+		mainVCSURL = w.Builder.VCSURLs[0]
+	}
+	base, vcsCommit, _ := strings.Cut(mainVCSURL, "@")
+	if base == "" {
+		base, err = repo.SourceURL()
+		if err != nil {
+			return fmt.Errorf("reading repository url: %w", err)
+		}
+	}
+	if vcsCommit != "" && vcsCommit != commit {
+		logrus.Warnf("Source materials read from commit %s, VCS URL points to %s", commit, vcsCommit)
+	}
+
+	materials, err := repo.SourceMaterials(fmt.Sprintf("%s@%s", base, commit), vendor)
+	if err != nil {
+		return fmt.Errorf("reading source materials: %w", err)
+	}
+	for _, m := range materials {
+		att.Predicate.AddMaterial(m.URI, m.Digest)
+	}
+	logrus.Infof("Recorded %d source materials from %s", len(materials), repoDir)
+	return nil
+}
+
+// AddGoModuleMaterials records the dependencies of the Go modules in
+// the modDirs directories of the built repository as materials, named
+// after their purl and pinned by their go.sum hash. The go.mod and
+// go.sum files are read from the repoDir checkout if set, otherwise
+// they are fetched from GitHub at the commit of the main VCS URL or, if
+// not set, of the config source.
+func (w *Watcher) AddGoModuleMaterials(
+	ctx context.Context, att *attestation.Attestation, repoDir string, modDirs []string,
+) error {
+	readFile := func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(repoDir, filepath.FromSlash(name)))
+	}
+	if repoDir == "" {
+		source := ""
+		if len(w.Builder.VCSURLs) > 0 {
+			source = w.Builder.VCSURLs[0]
+		} else if cs := att.Predicate.Invocation.ConfigSource; cs.URI != "" && cs.Digest["sha1"] != "" {
+			source = strings.SplitN(cs.URI, "@", 2)[0] + "@" + cs.Digest["sha1"]
+		}
+		host, owner, repo, commit, err := parseGitHubSource(source)
+		if err != nil {
+			return fmt.Errorf("locating the source repository, use a checkout: %w", err)
+		}
+		readFile = func(name string) ([]byte, error) {
+			data, _, err := github.FileContents(ctx, github.ServerAPIURL(host), owner, repo, name, commit)
+			return data, err
+		}
+	}
+
+	total := 0
+	for _, dir := range modDirs {
+		goMod, err := readFile(path.Join(dir, "go.mod"))
+		if err != nil {
+			return fmt.Errorf("reading go.mod: %w", err)
+		}
+		goSum, err := readFile(path.Join(dir, "go.sum"))
+		if err != nil {
+			return fmt.Errorf("reading go.sum: %w", err)
+		}
+		modules, err := gomod.Dependencies(goMod, goSum)
+		if err != nil {
+			return fmt.Errorf("resolving dependencies of %s: %w", dir, err)
+		}
+		for i := range modules {
+			digest := map[string]string{}
+			if modules[i].Sum != "" {
+				digest["dirHash"] = modules[i].Sum
+			}
+			att.Predicate.AddMaterial(modules[i].PackageURL(), digest)
+		}
+		total += len(modules)
+	}
+	logrus.Infof("Recorded %d Go module dependencies as materials", total)
+	return nil
+}
+
+// parseGitHubSource splits a git+https://host/owner/repo@commit source
+// URL into its parts
+func parseGitHubSource(source string) (host, owner, repo, commit string, err error) {
+	base, commit, _ := strings.Cut(strings.TrimPrefix(source, "git+"), "@")
+	if commit == "" {
+		return "", "", "", "", fmt.Errorf("no commit in source URL %q", source)
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("parsing source URL: %w", err)
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), "/")
+	if u.Scheme != "https" || len(parts) != 2 {
+		return "", "", "", "", fmt.Errorf("source %q is not a GitHub repository URL", source)
+	}
+	return u.Host, parts[0], parts[1], commit, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+)
+
+func TestAddGoModuleMaterials(t *testing.T) {
+	goMod := "module example.com/app\n\ngo 1.22\n\nrequire github.com/sirupsen/logrus v1.9.3\n"
+	goSum := "github.com/sirupsen/logrus v1.9.3 h1:dueUQJ1C2q9oE3F7wvmSGAaVtTmUizReu6fjN8uqzbQ=\n"
+	purl := "pkg:golang/github.com/sirupsen/logrus@v1.9.3"
+
+	// From a checkout
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tools"), os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tools", "go.mod"), []byte(goMod), os.FileMode(0o644)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tools", "go.sum"), []byte(goSum), os.FileMode(0o644)))
+	w := &Watcher{}
+	att := attestation.New().SLSA()
+	require.NoError(t, w.AddGoModuleMaterials(context.Background(), att, dir, []string{"tools"}))
+	require.Len(t, att.Predicate.Materials, 1)
+	require.Equal(t, purl, att.Predicate.Materials[0].URI)
+	require.Equal(t, "h1:dueUQJ1C2q9oE3F7wvmSGAaVtTmUizReu6fjN8uqzbQ=", att.Predicate.Materials[0].Digest["dirHash"])
+
+	// From GitHub at the commit of the config source
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files := map[string]string{"go.mod": goMod, "go.sum": goSum}
+		name := strings.TrimPrefix(r.URL.Path, "/repos/org/repo/contents/")
+		if r.URL.Query().Get("ref") != "abc123" || files[name] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "content": %q, "sha": "0"}`,
+			base64.StdEncoding.EncodeToString([]byte(files[name])))
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	att = attestation.New().SLSA()
+	att.Predicate.Invocation.ConfigSource.URI = "git+https://github.com/org/repo.git"
+	att.Predicate.Invocation.ConfigSource.Digest = map[string]string{"sha1": "abc123"}
+	require.NoError(t, w.AddGoModuleMaterials(context.Background(), att, "", []string{"."}))
+	require.Len(t, att.Predicate.Materials, 1)
+	require.Equal(t, purl, att.Predicate.Materials[0].URI)
+
+	// The main VCS URL takes precedence
+	w.Builder.VCSURLs = []string{"git+https://github.com/org/repo@def456"}
+	require.Error(t, w.AddGoModuleMaterials(context.Background(), attestation.New().SLSA(), "", []string{"."}))
+}
@@ -22,18 +22,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"maps"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/pubsub"
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	"github.com/sirupsen/logrus"
 
+	"sigs.k8s.io/tejolote/pkg/annotator"
 	"sigs.k8s.io/tejolote/pkg/attestation"
 	"sigs.k8s.io/tejolote/pkg/builder"
+	"sigs.k8s.io/tejolote/pkg/builder/driver"
+	"sigs.k8s.io/tejolote/pkg/config"
+	"sigs.k8s.io/tejolote/pkg/policy"
+	"sigs.k8s.io/tejolote/pkg/publisher"
 	"sigs.k8s.io/tejolote/pkg/run"
 	"sigs.k8s.io/tejolote/pkg/store"
 	"sigs.k8s.io/tejolote/pkg/store/snapshot"
@@ -45,16 +51,58 @@ type Watcher struct {
 	ArtifactStores   []store.Store
 	Snapshots        []map[string]*snapshot.Snapshot
 	Options          Options
+
+	// artifactSources records the store each collected artifact was read from
+	artifactSources map[string]store.Store
+
+	// reportedArtifacts are the artifacts listed by the build system
+	// native stores, used to cross-check the origin of artifacts
+	reportedArtifacts map[string]struct{}
+
+	// postSnapshots are the snapshots of the stores read when collecting
+	// the artifacts, kept to capture the run for replays
+	postSnapshots map[string]*snapshot.Snapshot
+
+	// events dispatches the lifecycle events to the subscribers
+	events eventBus
+
+	// completeness are the collection metrics of each artifact store,
+	// recorded in the predicate
+	completeness []attestation.StoreCompleteness
 }
 
 type Options struct {
-	WaitForBuild bool // When true, the watcher will keep observing the run until it's done
+	WaitForBuild          bool                        // When true, the watcher will keep observing the run until it's done
+	ClaimCheckLocation    string                      // Bucket URL to upload pubsub payloads too large to send inline
+	SnapshotsLocation     string                      // Bucket URL to upload the start message snapshots to instead of embedding them
+	SnapshotChunkLocation string                      // Directory or bucket URL to save the snapshot state as content-addressed chunks
+	CloudEvents           bool                        // Wrap the published messages in a CloudEvents envelope
+	RefSubjects           []string                    // Git tags/releases to record as subjects (github://owner/repo/tag)
+	RequireEmpty          []string                    // Spec URLs of artifact stores that must be empty before the build
+	ImmutabilityDelay     time.Duration               // Time to wait before checking the artifacts did not change after attesting
+	PollInterval          time.Duration               // Initial time to wait between run status checks
+	MaxPollInterval       time.Duration               // Cap of the exponential backoff when polling the run
+	Annotators            []*annotator.Annotator      // Annotators run over the artifacts to annotate their subjects
+	SettlePeriod          time.Duration               // Maximum time to re-list the stores after the build until their contents settle
+	SettleInterval        time.Duration               // Time between store listings while waiting for them to settle
+	StreamLogs            bool                        // Follow the build logs to refresh the run as soon as it changes phase
+	IndexDir              string                      // Directory to keep on-disk snapshot indexes instead of in-memory snapshots
+	Hooks                 []config.Hook               // Commands run before and after the snapshots and before signing
+	Policies              []*policy.Policy            // Policies the attestation must pass before it is signed or written
+	BuilderID             string                      // URI recorded as the builder.id instead of the one set by the driver
+	BuildType             string                      // URI recorded as the buildType instead of the one set by the driver
+	TimestampServer       string                      // URL of an RFC 3161 timestamp authority to timestamp the attestation signature
+	Sigstore              attestation.SigstoreOptions // Sigstore deployment used to sign, empty fields use the public instance
 }
 
-func New(uri string) (w *Watcher, err error) {
+// New returns a watcher for the run at the spec URL, configured with
+// the options. The build system driver is picked from the URL scheme.
+func New(uri string, opts ...Option) (w *Watcher, err error) {
 	w = &Watcher{
 		Options: Options{
-			WaitForBuild: true, // By default we watch the build run
+			WaitForBuild:    true, // By default we watch the build run
+			PollInterval:    defaultPollInterval,
+			MaxPollInterval: defaultMaxPollInterval,
 		},
 	}
 
@@ -65,20 +113,92 @@ func New(uri string) (w *Watcher, err error) {
 	}
 	w.Builder = b
 
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			return nil, err
+		}
+	}
 	return w, nil
 }
 
 // GetRun returns a run from the build system
-func (w *Watcher) GetRun(specURL string) (*run.Run, error) {
-	r, err := w.Builder.GetRun(specURL)
+func (w *Watcher) GetRun(ctx context.Context, specURL string) (*run.Run, error) {
+	r, err := w.Builder.GetRun(ctx, specURL)
 	if err != nil {
 		return nil, fmt.Errorf("getting run: %w", err)
 	}
+	w.emit(EventRunStarted, r)
 	return r, nil
 }
 
-// Watch watches a run, updating the run data as it runs
-func (w *Watcher) Watch(r *run.Run) error {
+// WaitForRun gets a run from the build system, polling while the build
+// system does not know it yet, as happens with queued workflows or
+// builds not submitted yet. It fails if the run does not appear within
+// timeout, a zero timeout gets the run without waiting.
+func (w *Watcher) WaitForRun(ctx context.Context, specURL string, timeout time.Duration) (*run.Run, error) {
+	if timeout <= 0 {
+		return w.GetRun(ctx, specURL)
+	}
+	interval := w.Options.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	maxInterval := w.Options.MaxPollInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		r, err := w.GetRun(ctx, specURL)
+		if err == nil {
+			return r, nil
+		}
+
+		wait := withJitter(interval)
+		retryErr := &driver.RetryAfterError{}
+		switch {
+		case errors.As(err, &retryErr):
+			logrus.Warnf("build system is throttling requests, waiting %s", retryErr.RetryAfter)
+			wait = retryErr.RetryAfter
+		case errors.Is(err, driver.ErrRunNotFound):
+			logrus.Infof("run %s has not started yet, checking again in %s", specURL, wait.Round(time.Second))
+			interval = nextPollInterval(interval, maxInterval)
+		default:
+			return nil, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("run did not start within %s: %w", timeout, err)
+		}
+		if wait > remaining {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Watch watches a run, updating the run data as it runs. The polling
+// interval backs off exponentially up to Options.MaxPollInterval and
+// throttling responses from the build system are honored. When
+// Options.StreamLogs is set and the build system supports it, the run is
+// refreshed as soon as its logs show a phase change, polling remains as
+// a fallback. If the context is cancelled before the run finishes, Watch
+// returns the context error.
+func (w *Watcher) Watch(ctx context.Context, r *run.Run) error {
+	streaming := w.Options.StreamLogs
+	interval := w.Options.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	maxInterval := w.Options.MaxPollInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
 	for {
 		if !r.IsRunning {
 			return nil
@@ -88,14 +208,50 @@ func (w *Watcher) Watch(r *run.Run) error {
 			logrus.Warn("run is still running but watcher won't wait (WaitForBuild = false)")
 		}
 
+		wait := withJitter(interval)
+		if err := w.Builder.RefreshRun(ctx, r); err != nil {
+			retryErr := &driver.RetryAfterError{}
+			if !errors.As(err, &retryErr) {
+				return fmt.Errorf("refreshing run data: %w", err)
+			}
+			logrus.Warnf("build system is throttling requests, waiting %s", retryErr.RetryAfter)
+			wait = retryErr.RetryAfter
+		} else {
+			interval = nextPollInterval(interval, maxInterval)
+			w.emit(EventRunRefreshed, r)
+		}
+
+		if streaming && r.IsRunning {
+			err := w.followLogs(ctx, r, wait)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logrus.Warnf("not following the build logs, falling back to polling: %v", err)
+			streaming = false
+		}
+
 		// Sleep to wait for a status change
-		if err := w.Builder.RefreshRun(r); err != nil {
-			return fmt.Errorf("refreshing run data: %w", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
 		}
+	}
+}
 
-		// Sleep
-		time.Sleep(3 * time.Second)
+// followLogs waits until the logs of the run show a phase change or the
+// wait period passes, whatever happens first
+func (w *Watcher) followLogs(ctx context.Context, r *run.Run, wait time.Duration) error {
+	wctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	err := w.Builder.WaitTransition(wctx, r)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil
 	}
+	return err
 }
 
 // LoadAttestation loads a partial attestation to complete
@@ -121,7 +277,7 @@ func (w *Watcher) LoadAttestation(path string) error {
 }
 
 // AttestRun generates an attestation from a run tejolote can watch
-func (w *Watcher) AttestRun(r *run.Run) (att *attestation.Attestation, err error) {
+func (w *Watcher) AttestRun(ctx context.Context, r *run.Run) (att *attestation.Attestation, err error) {
 	if r.IsRunning {
 		logrus.Warn("run is still running, attestation may not capture en result")
 	}
@@ -133,16 +289,26 @@ func (w *Watcher) AttestRun(r *run.Run) (att *attestation.Attestation, err error
 
 	// Here, we need to check if its empty
 	pred := &att.Predicate
-	predicate, err := w.Builder.BuildPredicate(r, pred)
+	predicate, err := w.Builder.BuildPredicate(ctx, r, pred)
 	if err != nil {
 		return nil, fmt.Errorf("building predicate: %w", err)
 	}
 
 	// Add the run artifacts to the attestation
 	for _, a := range r.Artifacts {
-		s := intoto.Subject{
-			Name:   a.Path,
-			Digest: common.DigestSet{},
+		s := attestation.Subject{
+			Subject: intoto.Subject{
+				Name:   a.Path,
+				Digest: common.DigestSet{},
+			},
+		}
+		// Annotations from the storage driver can be overridden by
+		// the configured annotators
+		annotations := map[string]string{}
+		maps.Copy(annotations, a.Annotations)
+		maps.Copy(annotations, annotator.AnnotateAll(ctx, w.Options.Annotators, a))
+		if len(annotations) > 0 {
+			s.Annotations = annotations
 		}
 		for a, v := range a.Checksum {
 			s.Digest[a] = v
@@ -150,12 +316,53 @@ func (w *Watcher) AttestRun(r *run.Run) (att *attestation.Attestation, err error
 		att.Subject = append(att.Subject, s)
 	}
 
+	// Add the tags and releases as subjects
+	for _, ref := range w.Options.RefSubjects {
+		subjects, err := RefSubjects(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("reading subjects from %s: %w", ref, err)
+		}
+		att.AddSubjects(subjects...)
+	}
+
 	att.Predicate = *predicate
+	if w.Options.BuilderID != "" {
+		att.Predicate.Builder.ID = w.Options.BuilderID
+	}
+	if w.Options.BuildType != "" {
+		att.Predicate.BuildType = w.Options.BuildType
+	}
+
+	// The completeness claims are derived from what the driver recorded,
+	// nothing is complete if the run was not observed to the end
+	complete, reproducible := w.Builder.Completeness(r)
+	if r.IsRunning {
+		complete, reproducible = slsa.ProvenanceComplete{}, false
+	}
+	if att.Predicate.Metadata == nil {
+		att.Predicate.Metadata = &slsa.ProvenanceMetadata{}
+	}
+	att.Predicate.Metadata.Completeness = complete
+	att.Predicate.Metadata.Reproducible = reproducible
+
+	if w.completeness != nil {
+		att.Predicate.SubjectCompleteness = &attestation.SubjectCompleteness{Stores: w.completeness}
+	}
+	if outcome := r.Result(); outcome != "" {
+		att.Predicate.RunOutcome = &attestation.RunOutcome{Status: outcome}
+	}
+	w.emit(EventAttestationWritten, r)
 	return att, nil
 }
 
-// AddArtifactSource adds a new source to look for artifacts
+// AddArtifactSource adds a new source to look for artifacts. Sources
+// already added are ignored.
 func (w *Watcher) AddArtifactSource(specURL string) error {
+	for i := range w.ArtifactStores {
+		if w.ArtifactStores[i].SpecURL == specURL {
+			return nil
+		}
+	}
 	s, err := store.New(specURL)
 	if err != nil {
 		return fmt.Errorf("getting artifact store: %w", err)
@@ -164,37 +371,156 @@ func (w *Watcher) AddArtifactSource(specURL string) error {
 	return nil
 }
 
+// DiscoverArtifactStores adds the artifact locations declared in the
+// run definition as artifact stores of the watcher
+func (w *Watcher) DiscoverArtifactStores(r *run.Run) error {
+	for _, spec := range w.Builder.DeclaredArtifactStores(r) {
+		logrus.Infof("Adding artifact store %s declared in the build", spec)
+		if err := w.AddArtifactSource(spec); err != nil {
+			return fmt.Errorf("adding declared artifact store: %w", err)
+		}
+	}
+	return nil
+}
+
 // CollectArtifacts queries the storage drivers attached to the run and
-// collects any artifacts found after the build is done
-func (w *Watcher) CollectArtifacts(r *run.Run) error {
+// collects any artifacts found after the build is done. If the watcher
+// has a snapshot of a store taken before the build (attest --continue
+// and resume), only the artifacts created or modified since then are
+// collected, files already in the store are not subjects of the run.
+// Stores without a start snapshot report all their artifacts. The
+// artifacts of each store are sorted by path.
+func (w *Watcher) CollectArtifacts(ctx context.Context, r *run.Run) error {
 	r.Artifacts = nil
-	artifactStores := w.ArtifactStores
+	w.artifactSources = map[string]store.Store{}
+	w.reportedArtifacts = map[string]struct{}{}
+	w.postSnapshots = map[string]*snapshot.Snapshot{}
+	w.completeness = []attestation.StoreCompleteness{}
+	artifactStores := append([]store.Store{}, w.ArtifactStores...)
 	// TODO: Support disabling the native driver
 	artifactStores = append(artifactStores, w.Builder.ArtifactStores()...)
-	for _, s := range artifactStores {
+	if err := w.runHooks(ctx, config.HookPreSnapshot, r, nil); err != nil {
+		return err
+	}
+	for i, s := range artifactStores {
 		logrus.Infof("Collecting artifacts from %s", s.SpecURL)
-		artifacts, err := s.ReadArtifacts()
+		if i < len(w.ArtifactStores) {
+			if pre, ok := w.preIndex(s.SpecURL); ok {
+				artifacts, err := w.indexDelta(ctx, &artifactStores[i], pre)
+				if err != nil {
+					return fmt.Errorf("collecting artifacts from %s: %w", s.SpecURL, err)
+				}
+				w.addArtifacts(r, s, artifacts, false, nil)
+				continue
+			}
+		}
+		post, err := s.Snap(ctx)
 		if err != nil {
 			return fmt.Errorf("collecting artfiacts from %s: %w", s.SpecURL, err)
 		}
-		r.Artifacts = append(r.Artifacts, artifacts...)
+		post, err = w.settle(ctx, &artifactStores[i], post)
+		if err != nil {
+			return fmt.Errorf("waiting for %s to settle: %w", s.SpecURL, err)
+		}
+		w.postSnapshots[s.SpecURL] = post
+		artifacts := []run.Artifact{}
+		if pre, ok := w.preSnapshot(s.SpecURL); ok && i < len(w.ArtifactStores) {
+			artifacts = pre.Delta(post)
+			logrus.Infof("%d artifacts changed in %s since the start snapshot", len(artifacts), s.SpecURL)
+		} else {
+			for _, a := range *post {
+				artifacts = append(artifacts, a)
+			}
+		}
+		sort.Slice(artifacts, func(i, j int) bool {
+			return artifacts[i].Path < artifacts[j].Path
+		})
+		observed := len(*post)
+		w.addArtifacts(r, s, artifacts, i >= len(w.ArtifactStores), &observed)
 	}
 	logrus.Infof(
 		"Run produced %d artifacts collected from %d sources",
 		len(r.Artifacts), len(w.ArtifactStores),
 	)
+	if err := w.runHooks(ctx, config.HookPostSnapshot, r, nil); err != nil {
+		return err
+	}
+	w.emit(EventArtifactsCollected, r)
 	return nil
 }
 
+// addArtifacts adds the artifacts read from a store to the run and
+// records the collection metrics of the store. When reported is true,
+// the store is native to the build system and its artifacts were
+// reported by it. observed is the number of artifacts listed in the
+// store, nil when it is not known.
+func (w *Watcher) addArtifacts(r *run.Run, s store.Store, artifacts []run.Artifact, reported bool, observed *int) {
+	driverName, _, _ := strings.Cut(s.SpecURL, "://")
+	metrics := attestation.StoreCompleteness{
+		Store:        s.SpecURL,
+		Driver:       driverName,
+		Native:       reported,
+		Observed:     observed,
+		DigestSource: attestation.DigestComputed,
+	}
+	if reported || s.Capabilities().MetadataHashing {
+		metrics.DigestSource = attestation.DigestReported
+	}
+	for _, a := range artifacts {
+		if reported {
+			w.reportedArtifacts[a.Path] = struct{}{}
+		}
+		// Stores may overlap, eg a bucket listed in the build
+		// and read from the build system artifact manifest
+		if source, ok := w.artifactSources[a.Path]; ok {
+			logrus.Debugf("Skipping duplicate artifact %s", a.Path)
+			metrics.Skipped = append(metrics.Skipped, attestation.SkippedArtifact{
+				Path: a.Path, Reason: "duplicate of " + source.SpecURL,
+			})
+			continue
+		}
+		w.artifactSources[a.Path] = s
+		r.Artifacts = append(r.Artifacts, a)
+		metrics.Collected++
+		if len(a.Checksum) == 0 {
+			metrics.WithoutDigest++
+		}
+	}
+	w.completeness = append(w.completeness, metrics)
+}
+
+// preSnapshot returns the snapshot of a store taken before the build
+func (w *Watcher) preSnapshot(specURL string) (*snapshot.Snapshot, bool) {
+	if len(w.Snapshots) == 0 {
+		return nil, false
+	}
+	snap, ok := w.Snapshots[0][specURL]
+	if !ok || snap == nil {
+		return nil, false
+	}
+	return snap, true
+}
+
 // Snap adds a new snapshot set to the watcher by querying
 // each of the storage drivers
-func (w *Watcher) Snap() error {
+func (w *Watcher) Snap(ctx context.Context) error {
+	if err := w.runHooks(ctx, config.HookPreSnapshot, nil, nil); err != nil {
+		return err
+	}
 	snaps := map[string]*snapshot.Snapshot{}
 	for _, s := range w.ArtifactStores {
 		if s.SpecURL == "" {
 			return errors.New("artifact store has no spec url defined")
 		}
-		snap, err := s.Snap()
+		if w.Options.IndexDir != "" {
+			// Indexed stores are recorded in the set without a snapshot
+			if err := w.snapIndex(ctx, &s, len(w.Snapshots)); err != nil {
+				return fmt.Errorf("snapshotting storage: %w", err)
+			}
+			snaps[s.SpecURL] = nil
+			continue
+		}
+		snap, err := s.Snap(ctx)
 		if err != nil {
 			return fmt.Errorf("snapshotting storage: %w", err)
 		}
@@ -202,42 +528,120 @@ func (w *Watcher) Snap() error {
 	}
 	// TODO: Add some metrics to measure snapshot time
 	w.Snapshots = append(w.Snapshots, snaps)
+	if err := w.runHooks(ctx, config.HookPostSnapshot, nil, nil); err != nil {
+		return err
+	}
+	w.emit(EventSnapshotDone, nil)
+	return nil
+}
+
+// CheckEmptyStores verifies that the artifact stores listed in
+// Options.RequireEmpty had no artifacts in the first snapshot set. Finding
+// files in a destination before the build starts is a sign of path reuse
+// and stale artifacts would contaminate the delta.
+func (w *Watcher) CheckEmptyStores() error {
+	if len(w.Options.RequireEmpty) == 0 {
+		return nil
+	}
+	if len(w.Snapshots) == 0 {
+		return errors.New("no pre-build snapshots available to check for empty stores")
+	}
+	for _, specURL := range w.Options.RequireEmpty {
+		for i := range w.ArtifactStores {
+			if w.ArtifactStores[i].SpecURL == specURL && !w.ArtifactStores[i].Capabilities().DeletionDetection {
+				return fmt.Errorf("store %s does not list its full contents, it cannot be required to be empty", specURL)
+			}
+		}
+		snap, ok := w.Snapshots[0][specURL]
+		if !ok {
+			return fmt.Errorf("store %s required to be empty is not an artifact source", specURL)
+		}
+		n := 0
+		if snap != nil {
+			n = len(*snap)
+		} else if idx, ok := w.preIndex(specURL); ok {
+			var err error
+			n, err = idx.Len()
+			idx.Close()
+			if err != nil {
+				return fmt.Errorf("counting artifacts in %s: %w", specURL, err)
+			}
+		}
+		if n > 0 {
+			return fmt.Errorf(
+				"artifact store %s is required to be empty but already has %d artifacts",
+				specURL, n,
+			)
+		}
+	}
 	return nil
 }
 
 // SaveSnapshots stores the current state of the storage locations
-// to a file which can be reused when continuing an attestation
-func (w *Watcher) SaveSnapshots(path string) error {
-	var b bytes.Buffer
-	enc := json.NewEncoder(&b)
-	enc.SetIndent("", "  ")
-	enc.SetEscapeHTML(false)
+// to a file which can be reused when continuing an attestation. The
+// path can also be a remote location, see WriteSnapshotState.
+func (w *Watcher) SaveSnapshots(ctx context.Context, path string) error {
 	if len(w.Snapshots) == 0 {
 		logrus.Debug("no storage snapshots set, not saving file")
 		return nil
 	}
-	if err := enc.Encode(w.Snapshots); err != nil {
-		return fmt.Errorf("encoding snapshot data sbom: %w", err)
+	data, err := w.snapshotStateData(ctx)
+	if err != nil {
+		return err
 	}
-
-	if err := os.WriteFile(path, b.Bytes(), os.FileMode(0o644)); err != nil {
+	if err := WriteSnapshotState(ctx, path, data); err != nil {
 		return fmt.Errorf("writing file store state: %w", err)
 	}
 	return nil
 }
 
-// LoadSnapshots loads saved snapshot state from a file to continue
-func (w *Watcher) LoadSnapshots(path string) error {
+// snapshotStateData returns the serialized snapshot state, writing the
+// chunks first when chunking is enabled
+func (w *Watcher) snapshotStateData(ctx context.Context) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	state := w.snapshotState()
+	if w.Options.SnapshotChunkLocation != "" {
+		var err error
+		if state, err = w.chunkedSnapshotState(ctx); err != nil {
+			return nil, fmt.Errorf("writing snapshot chunks: %w", err)
+		}
+	}
+	if err := enc.Encode(state); err != nil {
+		return nil, fmt.Errorf("encoding snapshot data sbom: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// LoadSnapshots loads saved snapshot state from a file or a remote
+// location to continue
+func (w *Watcher) LoadSnapshots(ctx context.Context, path string) error {
 	if path == "" {
 		return nil
 	}
-	rawData, err := os.ReadFile(path)
+	rawData, err := ReadSnapshotState(ctx, path)
 	if err != nil {
 		return fmt.Errorf("opening saved snapshot data: %w", err)
 	}
-	snapData := []map[string]*snapshot.Snapshot{}
-	if err := json.Unmarshal(rawData, &snapData); err != nil {
-		return fmt.Errorf("unmarshaling snapshot data: %w", err)
+	state, err := decodeSnapshotState(rawData)
+	if err != nil {
+		return fmt.Errorf("decoding snapshot state: %w", err)
+	}
+	if state.ChunkLocation != "" {
+		if state.Snapshots, err = readChunks(ctx, state); err != nil {
+			return fmt.Errorf("reading chunked snapshot state: %w", err)
+		}
+	}
+	snapData := state.Snapshots
+
+	// Indexed stores have no snapshot to compute the delta from
+	if len(state.Indexed) > 0 && w.Options.IndexDir == "" {
+		return fmt.Errorf(
+			"stores %s were snapshotted to on-disk indexes, their index directory is needed to continue",
+			strings.Join(state.Indexed, ", "),
+		)
 	}
 
 	// Check the loaded snapshots
@@ -252,9 +656,37 @@ func (w *Watcher) LoadSnapshots(path string) error {
 	return nil
 }
 
+// SnapshotStores returns the spec URLs of the artifact stores recorded
+// in a saved snapshot state file
+func SnapshotStores(ctx context.Context, path string) ([]string, error) {
+	rawData, err := ReadSnapshotState(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("opening saved snapshot data: %w", err)
+	}
+	state, err := decodeSnapshotState(rawData)
+	if err != nil {
+		return nil, fmt.Errorf("decoding snapshot state: %w", err)
+	}
+	stores := []string{}
+	switch {
+	case len(state.Chunks) > 0:
+		for specURL := range state.Chunks[0] {
+			stores = append(stores, specURL)
+		}
+	case len(state.Snapshots) > 0:
+		for specURL := range state.Snapshots[0] {
+			stores = append(stores, specURL)
+		}
+	default:
+		return nil, errors.New("snapshot state has no snapshot sets")
+	}
+	sort.Strings(stores)
+	return stores, nil
+}
+
 // checkSnapshotMatch checks that a snapshot set matches the configured
-// storage backends in the watcher. The snapshots need to match in order
-// and in the SpecURL
+// storage backends in the watcher. Each configured store needs to have
+// a snapshot with its SpecURL in the set.
 func (w *Watcher) checkSnapshotMatch(snapset map[string]*snapshot.Snapshot) error {
 	if len(snapset) != len(w.ArtifactStores) {
 		return fmt.Errorf(
@@ -263,60 +695,63 @@ func (w *Watcher) checkSnapshotMatch(snapset map[string]*snapshot.Snapshot) erro
 		)
 	}
 
-	// Check that the SpecURLs match those in the configured stores:
-	i := 0
-	for specurl := range snapset {
-		if w.ArtifactStores[i].SpecURL != specurl {
+	// Check that the SpecURLs match those in the configured stores. The
+	// set is a map so we cannot rely on its order.
+	for i, s := range w.ArtifactStores {
+		if _, ok := snapset[s.SpecURL]; !ok {
 			return fmt.Errorf(
-				"spec url #%d in stored state, does not match storage %s",
-				i, w.ArtifactStores[i].SpecURL,
+				"storage #%d (%s) not found in stored state", i, s.SpecURL,
 			)
 		}
-		i++
 	}
 	return nil
 }
 
-type StartMessage struct {
-	SpecURL      string   `json:"spec"`
-	Attestation  string   `json:"attestation"`
-	Snapshots    string   `json:"snapshots"`
-	ArtifactList string   `json:"artifacts_list"`
-	Artifacts    []string `json:"artifacts"`
-}
-
 // PublishToTopic sends the data of a partial attestation to a Pub/Sub
-// topic.
-func (w *Watcher) PublishToTopic(topicString string, message interface{}) (err error) {
-	// projects/puerco-chainguard/topics/slsa
-	parts := strings.Split(topicString, "/")
-	if len(parts) != 4 {
-		return errors.New("invalid topic specifier, format: projects/PROJECTID/topics/TOPICNAME")
-	}
-
-	ctx := context.Background()
-
-	client, err := pubsub.NewClient(ctx, parts[1])
+// topic or any of the other supported publisher transports.
+func (w *Watcher) PublishToTopic(ctx context.Context, topicString string, message interface{}) (err error) {
+	pub, err := publisher.New(topicString)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("getting publisher: %w", err)
 	}
-	defer client.Close()
-	topic := client.Topic(parts[3])
+	defer pub.Close()
 
 	var data []byte
-	if m, ok := message.(StartMessage); ok {
+	var eventType string
+	switch m := message.(type) {
+	case StartMessage:
+		if err := w.referenceAttachments(ctx, &m); err != nil {
+			return fmt.Errorf("uploading message attachments: %w", err)
+		}
+		data, err = json.Marshal(m)
+		eventType = StartEventType
+	case FinishMessage:
 		data, err = json.Marshal(m)
-	} else {
+		eventType = FinishEventType
+	default:
 		return errors.New("unknown message format")
 	}
 
 	if err != nil {
 		return fmt.Errorf("marshalling message into json: %w", err)
 	}
+
+	if len(data) > pub.MaxMessageSize() {
+		data, err = w.claimCheckData(ctx, data)
+		if err != nil {
+			return fmt.Errorf("creating claim check: %w", err)
+		}
+	}
+
+	if w.Options.CloudEvents {
+		data, err = w.wrapCloudEvent(eventType, data)
+		if err != nil {
+			return fmt.Errorf("wrapping message in cloudevent: %w", err)
+		}
+	}
 	logrus.Debugf("Message: " + string(data))
-	if _, err := topic.Publish(ctx, &pubsub.Message{Data: data}).Get(ctx); err != nil {
-		return fmt.Errorf("publishing to pubsub topic: %w", err)
+	if err := pub.Publish(ctx, data); err != nil {
+		return fmt.Errorf("publishing message: %w", err)
 	}
-	logrus.Infof("pushed build data to topic %s", topicString)
 	return nil
 }
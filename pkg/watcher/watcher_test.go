@@ -15,3 +15,236 @@ limitations under the License.
 */
 
 package watcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/cloudbuild/v1"
+
+	"sigs.k8s.io/tejolote/pkg/attestation"
+	builderdriver "sigs.k8s.io/tejolote/pkg/builder/driver"
+	"sigs.k8s.io/tejolote/pkg/run"
+	"sigs.k8s.io/tejolote/pkg/store"
+	"sigs.k8s.io/tejolote/pkg/store/snapshot"
+)
+
+func TestCheckEmptyStores(t *testing.T) {
+	full := snapshot.Snapshot{"test.txt": run.Artifact{Path: "test.txt"}}
+	w := &Watcher{
+		Snapshots: []map[string]*snapshot.Snapshot{
+			{
+				"file:///empty": &snapshot.Snapshot{},
+				"file:///full":  &full,
+			},
+		},
+	}
+
+	// Nothing required, nothing checked
+	require.NoError(t, w.CheckEmptyStores())
+
+	w.Options.RequireEmpty = []string{"file:///empty"}
+	require.NoError(t, w.CheckEmptyStores())
+
+	w.Options.RequireEmpty = []string{"file:///empty", "file:///full"}
+	require.Error(t, w.CheckEmptyStores())
+
+	// Unknown store
+	w.Options.RequireEmpty = []string{"file:///other"}
+	require.Error(t, w.CheckEmptyStores())
+
+	// No snapshots to check
+	w.Snapshots = nil
+	w.Options.RequireEmpty = []string{"file:///empty"}
+	require.Error(t, w.CheckEmptyStores())
+}
+
+func FuzzLoadSnapshots(f *testing.F) {
+	f.Add([]byte(`[{"file:///tmp/artifacts":{"test.txt":{"path":"test.txt","checksum":{"SHA256":"abc"}}}}]`))
+	f.Add([]byte(`[{"file:///tmp/artifacts":null}]`))
+	f.Add([]byte(`[{}]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`{}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "snapshots.json")
+		require.NoError(t, os.WriteFile(path, data, os.FileMode(0o644)))
+
+		s, err := store.New("file:///tmp/artifacts")
+		require.NoError(t, err)
+		w := &Watcher{ArtifactStores: []store.Store{s}}
+		if err := w.LoadSnapshots(context.Background(), path); err != nil {
+			return
+		}
+		// Loaded snapshots must be usable
+		_ = w.CheckEmptyStores() //nolint: errcheck
+		for _, snapset := range w.Snapshots {
+			for _, snap := range snapset {
+				snap.Delta(&snapshot.Snapshot{})
+			}
+		}
+	})
+}
+
+func TestStoreCompleteness(t *testing.T) {
+	s1, err := store.New("file://" + t.TempDir())
+	require.NoError(t, err)
+	s2, err := store.New("oci://registry.example.com/app")
+	require.NoError(t, err)
+	w := &Watcher{
+		artifactSources:   map[string]store.Store{},
+		reportedArtifacts: map[string]struct{}{},
+	}
+	r := &run.Run{}
+	observed := 3
+	w.addArtifacts(r, s1, []run.Artifact{
+		{Path: "bin", Checksum: map[string]string{"sha256": strings.Repeat("a", 64)}},
+		{Path: "lib"},
+	}, false, &observed)
+	w.addArtifacts(r, s2, []run.Artifact{
+		{Path: "bin", Checksum: map[string]string{"sha256": strings.Repeat("a", 64)}},
+	}, true, nil)
+
+	require.Len(t, r.Artifacts, 2)
+	require.Len(t, w.completeness, 2)
+	require.Equal(t, "file", w.completeness[0].Driver)
+	require.Equal(t, 3, *w.completeness[0].Observed)
+	require.Equal(t, 2, w.completeness[0].Collected)
+	require.Equal(t, 1, w.completeness[0].WithoutDigest)
+	require.Equal(t, attestation.DigestComputed, w.completeness[0].DigestSource)
+
+	require.True(t, w.completeness[1].Native)
+	require.Nil(t, w.completeness[1].Observed)
+	require.Equal(t, 0, w.completeness[1].Collected)
+	require.Equal(t, attestation.DigestReported, w.completeness[1].DigestSource)
+	require.Equal(t, []attestation.SkippedArtifact{
+		{Path: "bin", Reason: "duplicate of " + s1.SpecURL},
+	}, w.completeness[1].Skipped)
+}
+
+func TestSnapshotStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.storage-snap.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"oci://ghcr.io/org/image": {}, "file:///tmp/artifacts": {}}
+	]`), os.FileMode(0o644)))
+
+	stores, err := SnapshotStores(context.Background(), path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"file:///tmp/artifacts", "oci://ghcr.io/org/image"}, stores)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[]`), os.FileMode(0o644)))
+	_, err = SnapshotStores(context.Background(), path)
+	require.Error(t, err)
+}
+
+func TestAttestRunBuilderIdentity(t *testing.T) {
+	w, err := New("gcb://my-project/1234")
+	require.NoError(t, err)
+	r := &run.Run{
+		SpecURL:    "gcb://my-project/1234",
+		IsSuccess:  true,
+		SystemData: &cloudbuild.Build{Id: "1234", ProjectId: "my-project", ServiceAccount: "builder@my-project"},
+	}
+	att, err := w.AttestRun(context.Background(), r)
+	require.NoError(t, err)
+	require.Equal(t, "builder@my-project", att.Predicate.Builder.ID)
+	require.Equal(t, "https://cloudbuild.googleapis.com/CloudBuildYaml@v1", att.Predicate.BuildType)
+	require.True(t, att.Predicate.Metadata.Completeness.Parameters)
+
+	w.Options.BuilderID = "https://builders.example.com/release@v1"
+	w.Options.BuildType = "https://builders.example.com/types/release@v1"
+	att, err = w.AttestRun(context.Background(), r)
+	require.NoError(t, err)
+	require.Equal(t, "https://builders.example.com/release@v1", att.Predicate.Builder.ID)
+	require.Equal(t, "https://builders.example.com/types/release@v1", att.Predicate.BuildType)
+
+	// Nothing is complete while the run is still running
+	r.IsRunning = true
+	att, err = w.AttestRun(context.Background(), r)
+	require.NoError(t, err)
+	require.False(t, att.Predicate.Metadata.Completeness.Parameters)
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"old.txt", "mod.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), os.FileMode(0o644)))
+	}
+	w, err := New("gitlab://gitlab.com/group/project/pipelines/42")
+	require.NoError(t, err)
+	require.NoError(t, w.AddArtifactSource("file://"+dir))
+
+	paths := func(r *run.Run) []string {
+		res := []string{}
+		for _, a := range r.Artifacts {
+			res = append(res, filepath.Base(a.Path))
+		}
+		return res
+	}
+
+	// Without a start snapshot all the artifacts in the store are
+	// collected, sorted by path
+	r := &run.Run{}
+	require.NoError(t, w.CollectArtifacts(context.Background(), r))
+	require.Equal(t, []string{"mod.txt", "old.txt"}, paths(r))
+
+	// With a start snapshot (attest --continue) only the artifacts
+	// created or modified during the build are collected
+	require.NoError(t, w.Snap(context.Background()))
+	for _, name := range []string{"zz.txt", "new.txt", "mod.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("changed "+name), os.FileMode(0o644)))
+	}
+	r = &run.Run{}
+	require.NoError(t, w.CollectArtifacts(context.Background(), r))
+	require.Equal(t, []string{"mod.txt", "new.txt", "zz.txt"}, paths(r))
+}
+
+func TestWaitForRun(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/repos/org/repo/actions/runs/2" && requests > 2:
+			fmt.Fprint(w, `{"id": 2, "status": "queued"}`)
+		case r.URL.Path == "/repos/org/repo/actions/runs/3":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "test")
+
+	w, err := New("github://org/repo/2")
+	require.NoError(t, err)
+	w.Options.PollInterval = 10 * time.Millisecond
+	w.Options.MaxPollInterval = 10 * time.Millisecond
+
+	// Without waiting, the missing run is an error
+	_, err = w.WaitForRun(context.Background(), "github://org/repo/2", 0)
+	require.ErrorIs(t, err, builderdriver.ErrRunNotFound)
+
+	r, err := w.WaitForRun(context.Background(), "github://org/repo/2", time.Minute)
+	require.NoError(t, err)
+	require.True(t, r.IsRunning)
+	require.Equal(t, 3, requests)
+
+	_, err = w.WaitForRun(context.Background(), "github://org/repo/1", 50*time.Millisecond)
+	require.ErrorIs(t, err, builderdriver.ErrRunNotFound)
+	require.ErrorContains(t, err, "did not start within")
+
+	// Other errors are not retried
+	requests = 0
+	_, err = w.WaitForRun(context.Background(), "github://org/repo/3", time.Minute)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, builderdriver.ErrRunNotFound)
+	require.Equal(t, 1, requests)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workdir manages the temporary files and directories tejolote
+// creates while it runs. They are all created in a session directory
+// under the base directory (the system temporary directory unless set
+// with SetBase) which is removed by Cleanup when the command finishes.
+// Session directories record the PID of their owner so the ones left
+// behind by killed processes can be found and removed with Clean.
+package workdir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Prefix is the name prefix of the session directories
+const Prefix = "tejolote-"
+
+// ownerFile holds the PID of the process owning a session directory
+const ownerFile = ".tejolote-owner"
+
+var (
+	mtx     sync.Mutex
+	base    string
+	session string
+)
+
+// SetBase sets the directory where the session directory is created,
+// creating it if needed
+func SetBase(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("resolving workdir path: %w", err)
+	}
+	if err := os.MkdirAll(abs, os.FileMode(0o755)); err != nil {
+		return fmt.Errorf("creating workdir: %w", err)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	base = abs
+	return nil
+}
+
+// Base returns the directory where the session directory is created
+func Base() string {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if base == "" {
+		return os.TempDir()
+	}
+	return base
+}
+
+// sessionDir returns the session directory, creating it on first use
+func sessionDir() (string, error) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if session != "" {
+		return session, nil
+	}
+	b := base
+	if b == "" {
+		b = os.TempDir()
+	}
+	dir, err := os.MkdirTemp(b, Prefix)
+	if err != nil {
+		return "", fmt.Errorf("creating session workdir: %w", err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(dir, ownerFile), []byte(strconv.Itoa(os.Getpid())), os.FileMode(0o644),
+	); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("writing workdir owner: %w", err)
+	}
+	session = dir
+	return session, nil
+}
+
+// MkdirTemp creates a new temporary directory in the session directory,
+// see os.MkdirTemp for the pattern format
+func MkdirTemp(pattern string) (string, error) {
+	dir, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, pattern)
+}
+
+// CreateTemp creates a new temporary file in the session directory,
+// see os.CreateTemp for the pattern format
+func CreateTemp(pattern string) (*os.File, error) {
+	dir, err := sessionDir()
+	if err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// Cleanup removes the session directory and everything in it. It is
+// safe to call it more than once, a new session directory is created
+// if temporary files are requested afterwards.
+func Cleanup() error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	if session == "" {
+		return nil
+	}
+	if err := os.RemoveAll(session); err != nil {
+		return fmt.Errorf("removing workdir %s: %w", session, err)
+	}
+	logrus.Debugf("Removed workdir %s", session)
+	session = ""
+	return nil
+}
+
+// Clean removes the session directories in dir left behind by processes
+// which are no longer running. Directories modified less than olderThan
+// ago are kept. Only directories with an owner marker are removed, those
+// without one may belong to another program. It returns the directories
+// removed, or the ones that would be removed if dryRun is set.
+func Clean(dir string, olderThan time.Duration, dryRun bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+	mtx.Lock()
+	current := session
+	mtx.Unlock()
+
+	removed := []string{}
+	errs := []error{}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), Prefix) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if path == current {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < olderThan {
+			continue
+		}
+		if pid, ok := readOwner(path); !ok || processRunning(pid) {
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				errs = append(errs, fmt.Errorf("removing %s: %w", path, err))
+				continue
+			}
+		}
+		removed = append(removed, path)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// readOwner returns the PID of the process that created a session directory
+func readOwner(dir string) (int, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, ownerFile))
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return pid, true
+}
+
+// processRunning returns true if a process with the PID exists
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
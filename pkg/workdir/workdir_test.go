@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workdir
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkdir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, SetBase(dir))
+	defer func() { base = "" }()
+
+	tmp, err := MkdirTemp("gcs-")
+	require.NoError(t, err)
+	f, err := CreateTemp("sbom-")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, filepath.Dir(tmp), filepath.Dir(f.Name()))
+	sessionPath := filepath.Dir(tmp)
+	require.Equal(t, dir, filepath.Dir(sessionPath))
+
+	// The running session is never cleaned
+	removed, err := Clean(dir, 0, false)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+
+	require.NoError(t, Cleanup())
+	require.NoDirExists(t, sessionPath)
+	require.NoError(t, Cleanup())
+}
+
+func TestClean(t *testing.T) {
+	dir := t.TempDir()
+	mkdir := func(name, owner string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.Mkdir(path, os.FileMode(0o755)))
+		if owner != "" {
+			require.NoError(t, os.WriteFile(filepath.Join(path, ownerFile), []byte(owner), os.FileMode(0o644)))
+		}
+		mtime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+		return path
+	}
+	orphaned := mkdir("tejolote-1", "99999999", 2*time.Hour)
+	legacy := mkdir("tejolote-gcs123", "", 2*time.Hour)
+	running := mkdir("tejolote-2", strconv.Itoa(os.Getpid()), 2*time.Hour)
+	recent := mkdir("tejolote-3", "99999999", time.Minute)
+	other := mkdir("other-4", "", 2*time.Hour)
+
+	removed, err := Clean(dir, time.Hour, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{orphaned}, removed)
+	require.DirExists(t, orphaned)
+
+	removed, err = Clean(dir, time.Hour, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{orphaned}, removed)
+	require.NoDirExists(t, orphaned)
+	require.DirExists(t, legacy)
+	require.DirExists(t, running)
+	require.DirExists(t, recent)
+	require.DirExists(t, other)
+}
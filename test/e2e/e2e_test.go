@@ -0,0 +1,318 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/stretchr/testify/require"
+)
+
+// tejoloteBin is the path to the binary built for the test run
+var tejoloteBin string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tejolote-e2e-")
+	if err != nil {
+		panic(err)
+	}
+	tejoloteBin = filepath.Join(dir, "tejolote")
+	build := exec.Command("go", "build", "-o", tejoloteBin, "../../cmd/tejolote")
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// tejolote runs the tejolote binary with the emulator environment
+func tejolote(t *testing.T, env []string, args ...string) {
+	t.Helper()
+	cmd := exec.Command(tejoloteBin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	require.NoError(t, cmd.Run(), "running tejolote %s", strings.Join(args, " "))
+}
+
+// tejoloteFails runs the tejolote binary expecting it to fail and
+// returns its combined output
+func tejoloteFails(t *testing.T, env []string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(tejoloteBin, args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	require.Error(t, err, "tejolote %s did not fail", strings.Join(args, " "))
+	return string(out)
+}
+
+// TestLifecycle exercises the full start → build → finish flow against
+// a mock GitHub API, a fake GCS server and a local registry.
+func TestLifecycle(t *testing.T) {
+	gh := newFakeGitHub("org", "repo", 1, 2)
+	ghServer := httptest.NewServer(gh)
+	defer ghServer.Close()
+	gh.serverURL = ghServer.URL
+
+	gcs := newFakeGCS("bucket")
+	gcsServer := httptest.NewServer(gcs)
+	defer gcsServer.Close()
+
+	regServer := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer regServer.Close()
+	imageRef := strings.TrimPrefix(regServer.URL, "http://") + "/test/image"
+
+	// The repository needs to exist before the build starts
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, imageRef+":v0"))
+
+	env := []string{
+		"GITHUB_API_URL=" + ghServer.URL,
+		"GITHUB_TOKEN=e2e-test-token",
+		"STORAGE_EMULATOR_HOST=" + strings.TrimPrefix(gcsServer.URL, "http://"),
+	}
+
+	workDir := t.TempDir()
+	artifactsDir := filepath.Join(workDir, "artifacts")
+	require.NoError(t, os.Mkdir(artifactsDir, os.FileMode(0o755)))
+	startPath := filepath.Join(workDir, "start.json")
+	attestationPath := filepath.Join(workDir, "attestation.json")
+	specURL := "github://org/repo/1"
+	stores := []string{
+		"--artifacts", "file://" + artifactsDir,
+		"--artifacts", "gs://bucket/test/",
+		"--artifacts", "oci://" + imageRef,
+	}
+
+	// Start
+	tejolote(t, env, append([]string{
+		"start", "attestation", specURL, "--output", startPath,
+		"--vcs-url", "git+https://github.com/org/repo@" + strings.Repeat("a", 40),
+		"--vcs-url", "git+https://github.com/org/config@" + strings.Repeat("c", 40),
+	}, stores...)...)
+	require.FileExists(t, startPath)
+	require.FileExists(t, strings.TrimSuffix(startPath, ".json")+".storage-snap.json")
+
+	// Build
+	require.NoError(t, os.WriteFile(
+		filepath.Join(artifactsDir, "binary"), []byte("local artifact"), os.FileMode(0o644),
+	))
+	gcs.put("test/release/binary.tar.gz", []byte("bucket artifact"))
+	img, err = random.Image(1024, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, imageRef+":v1"))
+
+	// Finish, the poll interval is set from the environment
+	captureDir := filepath.Join(workDir, "capture")
+	tmpDir := filepath.Join(workDir, "tmp")
+	tejolote(t, append(env, "TEJOLOTE_POLL_INTERVAL=50ms"), append([]string{
+		"attest", specURL, "--continue", startPath, "--output", attestationPath,
+		"--capture", captureDir, "--workdir", tmpDir,
+	}, stores...)...)
+
+	// The bucket mirror is removed when the command finishes
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// The normalized run data can be inspected
+	tejolote(t, env, "inspect", "run", specURL, "--output", "yaml", "--system-data")
+
+	data, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	att := intoto.ProvenanceStatementSLSA02{}
+	require.NoError(t, json.Unmarshal(data, &att))
+	completeness := struct {
+		Predicate struct {
+			SubjectCompleteness struct {
+				Stores []struct{ Store string } `json:"stores"`
+			} `json:"subjectCompleteness"`
+		} `json:"predicate"`
+	}{}
+	require.NoError(t, json.Unmarshal(data, &completeness))
+	require.Len(t, completeness.Predicate.SubjectCompleteness.Stores, len(stores)/2+1, "stores missing from the completeness section")
+
+	require.GreaterOrEqual(t, gh.runRequests(), 3, "the run was not polled until it finished")
+	require.Equal(t, "https://github.com/Attestations/GitHubActionsWorkflow@v1", att.Predicate.BuildType)
+	require.Equal(t, ".github/workflows/release.yaml", att.Predicate.Invocation.ConfigSource.EntryPoint)
+	workflowMaterial := false
+	for _, m := range att.Predicate.Materials {
+		if strings.HasSuffix(m.URI, "#.github/workflows/release.yaml") {
+			workflowMaterial = m.Digest["gitBlob"] == strings.Repeat("b", 40)
+		}
+	}
+	require.True(t, workflowMaterial, "workflow file not recorded in materials")
+	configMaterial := false
+	for _, m := range att.Predicate.Materials {
+		if m.URI == "git+https://github.com/org/config" {
+			configMaterial = m.Digest["sha1"] == strings.Repeat("c", 40)
+		}
+	}
+	require.True(t, configMaterial, "second repository not recorded in materials")
+
+	// Bucket objects are hashed while they are downloaded
+	bucketDigest := fmt.Sprintf("%x", sha256.Sum256([]byte("bucket artifact")))
+	for _, s := range att.Subject {
+		if s.Name == "gs://bucket/test/release/binary.tar.gz" {
+			require.Equal(t, bucketDigest, s.Digest["SHA256"])
+		}
+	}
+
+	for _, suffix := range []string{
+		"binary",
+		"gs://bucket/test/release/binary.tar.gz",
+		"oci://" + imageRef + ":v1",
+		"/actions/runs/1/artifacts/build-output",
+	} {
+		found := false
+		for _, s := range att.Subject {
+			if strings.HasSuffix(s.Name, suffix) {
+				found = true
+			}
+		}
+		require.True(t, found, "no subject found for %s in %v", suffix, att.Subject)
+	}
+
+	// Artifacts already in the stores when the attestation started
+	// are not part of the run output
+	for _, s := range att.Subject {
+		require.NotEqual(t, "oci://"+imageRef+":v0", s.Name, "artifact from before the build recorded")
+	}
+
+	// The published artifacts still match the attestation
+	tejolote(t, env,
+		"refresh-subjects", attestationPath, "--artifacts", "file://"+artifactsDir,
+		"--allow-missing", "--format", "json",
+	)
+
+	// Replaying the captured run without the emulators must produce the
+	// same subjects
+	replayPath := filepath.Join(workDir, "replay.json")
+	tejolote(t, nil,
+		"replay", "--run", filepath.Join(captureDir, "run.json"),
+		"--pre", filepath.Join(captureDir, "pre.json"), "--post", filepath.Join(captureDir, "post.json"),
+		"--continue", startPath, "--output", replayPath,
+	)
+	data, err = os.ReadFile(replayPath)
+	require.NoError(t, err)
+	replayed := intoto.ProvenanceStatementSLSA02{}
+	require.NoError(t, json.Unmarshal(data, &replayed))
+	require.ElementsMatch(t, att.Subject, replayed.Subject)
+	require.Equal(t, att.Predicate.Invocation.ConfigSource, replayed.Predicate.Invocation.ConfigSource)
+
+	// The captured run can be rendered as a diagram
+	graphPath := filepath.Join(workDir, "run.dot")
+	tejolote(t, nil,
+		"graph", "--run", filepath.Join(captureDir, "run.json"),
+		"--pre", filepath.Join(captureDir, "pre.json"), "--post", filepath.Join(captureDir, "post.json"),
+		"--continue", startPath, "--format", "dot", "--output", graphPath,
+	)
+	data, err = os.ReadFile(graphPath)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "digraph run {")
+	require.Contains(t, string(data), "gs://bucket/test/release/binary.tar.gz")
+}
+
+// TestPolicyDenied checks an attestation denied by a policy fails the
+// command before anything is signed or written.
+func TestPolicyDenied(t *testing.T) {
+	gh := newFakeGitHub("org", "repo", 1, 0)
+	ghServer := httptest.NewServer(gh)
+	defer ghServer.Close()
+	gh.serverURL = ghServer.URL
+
+	env := []string{
+		"GITHUB_API_URL=" + ghServer.URL,
+		"GITHUB_TOKEN=e2e-test-token",
+	}
+
+	workDir := t.TempDir()
+	artifactsDir := filepath.Join(workDir, "artifacts")
+	require.NoError(t, os.Mkdir(artifactsDir, os.FileMode(0o755)))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(artifactsDir, "binary"), []byte("local artifact"), os.FileMode(0o644),
+	))
+	policyPath := filepath.Join(workDir, "policy.yaml")
+	require.NoError(t, os.WriteFile(
+		policyPath, []byte("builderIds: [\"https://builders.example.com/*\"]\n"), os.FileMode(0o644),
+	))
+
+	outputs := []string{
+		filepath.Join(workDir, "attestation.json"),
+		filepath.Join(workDir, "licenses.json"),
+		filepath.Join(workDir, "quote.bin"),
+		filepath.Join(workDir, "blobs"),
+	}
+	out := tejoloteFails(t, env,
+		"attest", "github://org/repo/1", "--artifacts", "file://"+artifactsDir,
+		"--policy", policyPath, "--sign-artifacts",
+		"--output", outputs[0], "--license-scan", outputs[1],
+		"--host-quote", outputs[2], "--blob-attestations", outputs[3],
+	)
+	require.Contains(t, out, "evaluating policies")
+	for _, path := range outputs {
+		require.NoFileExists(t, path)
+		require.NoDirExists(t, path)
+	}
+}
+
+func TestOverwriteNever(t *testing.T) {
+	gh := newFakeGitHub("org", "repo", 1, 0)
+	ghServer := httptest.NewServer(gh)
+	defer ghServer.Close()
+	gh.serverURL = ghServer.URL
+
+	env := []string{
+		"GITHUB_API_URL=" + ghServer.URL,
+		"GITHUB_TOKEN=e2e-test-token",
+	}
+
+	attestationPath := filepath.Join(t.TempDir(), "attestation.json")
+	require.NoError(t, os.WriteFile(attestationPath, []byte("previous"), os.FileMode(0o644)))
+
+	// The existing output is refused before the run is observed
+	out := tejoloteFails(t, env,
+		"attest", "github://org/repo/1", "--output", attestationPath, "--overwrite", "never",
+	)
+	require.Contains(t, out, "already exists")
+	require.Zero(t, gh.runRequests())
+
+	data, err := os.ReadFile(attestationPath)
+	require.NoError(t, err)
+	require.Equal(t, "previous", string(data))
+}
@@ -0,0 +1,209 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"crypto/md5" //nolint: gosec
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeGitHub serves the subset of the GitHub API used by the actions
+// builder and store. The run reports in_progress for the first
+// pendingPolls requests and then completes successfully.
+type fakeGitHub struct {
+	sync.Mutex
+	org, repo    string
+	runID        int
+	pendingPolls int
+	requests     int
+	serverURL    string
+}
+
+func newFakeGitHub(org, repo string, runID, pendingPolls int) *fakeGitHub {
+	return &fakeGitHub{org: org, repo: repo, runID: runID, pendingPolls: pendingPolls}
+}
+
+func (gh *fakeGitHub) runRequests() int {
+	gh.Lock()
+	defer gh.Unlock()
+	return gh.requests
+}
+
+func (gh *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gh.Lock()
+	defer gh.Unlock()
+	runPath := fmt.Sprintf("/repos/%s/%s/actions/runs/%d", gh.org, gh.repo, gh.runID)
+	switch r.URL.Path {
+	case runPath:
+		gh.requests++
+		status, conclusion := "in_progress", ""
+		if gh.requests > gh.pendingPolls {
+			status, conclusion = "completed", "success"
+		}
+		writeJSON(w, map[string]interface{}{
+			"id":         gh.runID,
+			"status":     status,
+			"conclusion": conclusion,
+			"head_sha":   strings.Repeat("a", 40),
+			"path":       ".github/workflows/release.yaml",
+		})
+	case runPath + "/artifacts":
+		writeJSON(w, map[string]interface{}{
+			"total_count": 1,
+			"artifacts": []map[string]interface{}{
+				{
+					"id":                   1,
+					"name":                 "build-output",
+					"archive_download_url": gh.serverURL + "/download/build-output.zip",
+					"updated_at":           time.Now().UTC().Format(time.RFC3339),
+				},
+			},
+		})
+	case fmt.Sprintf("/repos/%s/%s/contents/.github/workflows/release.yaml", gh.org, gh.repo):
+		writeJSON(w, map[string]interface{}{
+			"type":     "file",
+			"encoding": "base64",
+			"sha":      strings.Repeat("b", 40),
+			"content":  base64.StdEncoding.EncodeToString([]byte("on: push\n")),
+		})
+	case "/download/build-output.zip":
+		fmt.Fprint(w, "actions artifact")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeGCS emulates the parts of the GCS JSON and XML APIs the storage
+// client uses to list, stat and read objects when pointed to it with
+// STORAGE_EMULATOR_HOST.
+type fakeGCS struct {
+	sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	updated map[string]time.Time
+}
+
+func newFakeGCS(bucket string) *fakeGCS {
+	return &fakeGCS{
+		bucket:  bucket,
+		objects: map[string][]byte{},
+		updated: map[string]time.Time{},
+	}
+}
+
+func (gcs *fakeGCS) put(name string, data []byte) {
+	gcs.Lock()
+	defer gcs.Unlock()
+	gcs.objects[name] = data
+	gcs.updated[name] = time.Now().UTC()
+}
+
+func (gcs *fakeGCS) attrs(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":        "storage#object",
+		"bucket":      gcs.bucket,
+		"name":        name,
+		"size":        fmt.Sprintf("%d", len(gcs.objects[name])),
+		"contentType": "application/octet-stream",
+		"md5Hash":     fmt.Sprintf("%x", md5.Sum(gcs.objects[name])), //nolint: gosec
+		"updated":     gcs.updated[name].Format(time.RFC3339Nano),
+	}
+}
+
+func (gcs *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gcs.Lock()
+	defer gcs.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/download")
+	listPath := "/storage/v1/b/" + gcs.bucket + "/o"
+	switch {
+	case path == listPath:
+		gcs.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+	case strings.HasPrefix(path, listPath+"/"):
+		name := strings.TrimPrefix(path, listPath+"/")
+		if _, ok := gcs.objects[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("alt") == "media" {
+			w.Write(gcs.objects[name]) //nolint: errcheck
+			return
+		}
+		writeJSON(w, gcs.attrs(name))
+	case strings.HasPrefix(path, "/"+gcs.bucket+"/"):
+		// XML API read
+		name := strings.TrimPrefix(path, "/"+gcs.bucket+"/")
+		data, ok := gcs.objects[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.Header().Set("Last-Modified", gcs.updated[name].Format(http.TimeFormat))
+		w.Write(data) //nolint: errcheck
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (gcs *fakeGCS) list(w http.ResponseWriter, prefix, delimiter string) {
+	items := []map[string]interface{}{}
+	prefixes := map[string]struct{}{}
+	names := []string{}
+	for name := range gcs.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if delimiter != "" {
+			if dir, _, ok := strings.Cut(rest, delimiter); ok {
+				prefixes[prefix+dir+delimiter] = struct{}{}
+				continue
+			}
+		}
+		items = append(items, gcs.attrs(name))
+	}
+	prefixList := []string{}
+	for p := range prefixes {
+		prefixList = append(prefixList, p)
+	}
+	sort.Strings(prefixList)
+	writeJSON(w, map[string]interface{}{
+		"kind":     "storage#objects",
+		"items":    items,
+		"prefixes": prefixList,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data) //nolint: errcheck
+}